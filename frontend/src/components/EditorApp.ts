@@ -15,6 +15,50 @@ import {
     saveDocument,
     saveSettings,
     SAVE_CANCELLED_ERROR,
+    NOTE_LOCKED_ERROR,
+    NOTE_NEEDS_PASSPHRASE_ERROR,
+    unlockNote,
+    lockAllNotes,
+    getActiveFile,
+    createVault,
+    unlockVault,
+    lockVault,
+    rotateVaultKey,
+    exportVault,
+    listTemplates,
+    createFromTemplate,
+    loadPeriodicSettings,
+    savePeriodicSettings,
+    openPeriodicNote,
+    adjacentPeriodicNote,
+    loadPeriodicCalendar,
+    lintDocument,
+    fixLintProblems,
+    formatDocument,
+    formatSelection,
+    documentOutline,
+    updateTOC,
+    splitNote,
+    mergeNotes,
+    previewBatchRename,
+    batchRename,
+    copyItems,
+    moveItems,
+    duplicateItem,
+    cancelTransfer,
+    chooseFolder,
+    previewReplaceInWorkspace,
+    replaceInWorkspace,
+    undoReplaceInWorkspace,
+    canUndoReplaceInWorkspace,
+    checkSpelling,
+    addToDictionary,
+    loadSpellcheckSettings,
+    saveSpellcheckSettings,
+    documentStats,
+    workspaceStats,
+    writingProgress,
+    setWritingGoal,
     setActiveFile,
     showAboutDialog,
     loadDocument as loadDocumentFromBackend,
@@ -23,11 +67,59 @@ import {
     deleteFile,
     renameFile,
     backendLog,
+    runQuery,
+    notifyDocumentClosed,
+    notifyDocumentOpened,
+    setDirtyDocuments,
+    completeRPCRequest,
+    refreshGitStatus,
+    gitStage,
+    gitUnstage,
+    gitCommit,
+    gitDiff,
+    gitLog,
+    gitShowRevision,
+    gitQuickSync,
+    loadSyncTarget,
+    saveSyncTarget,
+    syncNow,
+    loadBackupTarget,
+    saveBackupTarget,
+    backupNow,
+    listBackups,
+    listBackupFiles,
+    restoreBackup,
 } from "@/services/api";
 import type {
+    BackupFile,
+    BackupProgress,
+    BackupReport,
+    BackupSummary,
+    BackupTarget,
     EditorTheme,
+    GitCommit,
+    GitDiff,
+    GitStatus,
+    LintDiagnostic,
+    NoteTemplate,
+    PeriodicKind,
+    PeriodicNoteConfig,
     PreviewTheme,
+    QueryResult,
+    ConflictPolicy,
+    TransferProgress,
+    TransferResult,
+    RenameOptions,
+    RenamePreview,
+    ReplaceOptions,
+    ReplacePreview,
+    ReplaceResult,
     Settings as AppSettings,
+    SyncProgress,
+    SyncReport,
+    SyncTarget,
+    SpellingError,
+    OutlineHeading,
 } from "@/services/api";
 
 const APP_NAME = "MarkdownDaoNote";
@@ -44,6 +136,29 @@ const EVENT_FOLDER_OPENED = "folder:opened";
 const EVENT_TOOLBAR_THEME_CHANGED = "theme:toolbar-changed";
 const EVENT_EDITOR_THEME_CHANGED = "theme:editor-changed";
 const EVENT_PREVIEW_THEME_CHANGED = "theme:preview-changed";
+const EVENT_WORKSPACE_INDEXED = "workspace:indexed";
+const EVENT_RPC_REQUEST = "rpc:request";
+const EVENT_GIT_STATUS = "git:status";
+const EVENT_SYNC_PROGRESS = "sync:progress";
+const EVENT_SYNC_FINISHED = "sync:finished";
+const EVENT_SYNC_FAILED = "sync:failed";
+const EVENT_BACKUP_PROGRESS = "backup:progress";
+const EVENT_BACKUP_FINISHED = "backup:finished";
+const EVENT_BACKUP_FAILED = "backup:failed";
+const EVENT_NOTE_LOCKED = "note:locked";
+const EVENT_DOCUMENT_FORMATTED = "document:formatted";
+const EVENT_WRITING_RECORDED = "writing:recorded";
+const EVENT_WORKSPACE_REPLACED = "workspace:replaced";
+const EVENT_TRANSFER_PROGRESS = "transfer:progress";
+const GIT_STATUS_BADGES: Record<string, string> = {
+    modified: "M",
+    added: "A",
+    deleted: "D",
+    renamed: "R",
+    untracked: "U",
+    ignored: "I",
+    conflicted: "C",
+};
 const DEFAULT_WELCOME_MARKDOWN =
     "# Welcome to MarkdownDaoNote\n\nStart iterating on your notes.";
 
@@ -85,10 +200,18 @@ const PREVIEW_THEME_OPTIONS: PreviewTheme[] = ["default", "dark"];
 
 // 文件类型检测函数
 function isMarkdownFile(filePath: string): boolean {
+    if (isEncryptedNote(filePath)) {
+        return true;
+    }
     const ext = filePath.toLowerCase().split('.').pop();
     return ['md', 'markdown', 'mdown', 'mkd', 'mdx'].includes(ext || '');
 }
 
+// 加密笔记（scrypt + XChaCha20-Poly1305），内容由后端解密
+function isEncryptedNote(filePath: string): boolean {
+    return filePath.toLowerCase().endsWith('.md.enc');
+}
+
 // 检测是否为二进制文件
 function isBinaryFile(filePath: string): boolean {
     const ext = filePath.toLowerCase().split('.').pop();
@@ -184,6 +307,8 @@ interface FileTreeNode {
     hasChildren?: boolean;
     children?: FileTreeNode[];
     isLoading?: boolean;
+    gitStatus?: string;
+    vault?: "locked" | "unlocked";
 }
 
 interface OpenDocument {
@@ -214,6 +339,8 @@ export class EditorApp {
     private tabBarElement!: HTMLDivElement;
     private openDocuments = new Map<string, OpenDocument>();
     private tabOrder: string[] = [];
+    // 上次告知后端的未保存文档，避免每次渲染都调用
+    private reportedDirty = "";
     private statusElement!: HTMLDivElement;
     private menuElement!: HTMLDivElement;
     private sidebarTreeRoot: FileTreeNode | null = null;
@@ -226,6 +353,10 @@ export class EditorApp {
     private pendingEditorTheme: EditorTheme | null = null;
     private pendingPreviewTheme: PreviewTheme | null = null;
     private suppressChangeHandler = false;
+    private lintTimer: number | null = null;
+    private lintMarks: Array<{ clear: () => void }> = [];
+    private spellMarks: Array<{ clear: () => void }> = [];
+    private spellingErrors: SpellingError[] = [];
     private statusResetTimeout: number | undefined;
     private subscriptions: Array<() => void> = [];
     private pendingActiveSync: Promise<void> | null = null;
@@ -234,6 +365,9 @@ export class EditorApp {
     private readonly suppressNativeContextMenu = (event: MouseEvent) => event.preventDefault();
     private contextMenu: HTMLDivElement | null = null;
     private contextMenuTarget: FileTreeNode | null = null;
+    private queryResultCache = new Map<string, Promise<QueryResult>>();
+    private gitStatus: GitStatus | null = null;
+    private gitDiffSideBySide = false;
 
     /**
      * 统一的MessageDialog方法
@@ -720,6 +854,10 @@ export class EditorApp {
             }
         });
 
+        this.editorElement.addEventListener("contextmenu", (event) => {
+            this.handleEditorContextMenu(event);
+        });
+
         body.append(sidebarWrapper, editorWrapper);
 
         // 将 header 和 body 添加到容器中
@@ -1041,27 +1179,44 @@ export class EditorApp {
         this.subscriptions.forEach((unsubscribe) => unsubscribe());
         this.subscriptions = [
             // 打开文件（来自操作系统文件关联或其他后端触发）
-            EventsOn(EVENT_OPEN_FILE, async (rawPath: string) => {
-                await backendLog("info", "open-file event detected: " + rawPath);
-                try {
-                    const incomingPath =
-                        typeof rawPath === "string"
-                            ? rawPath
-                            : String(rawPath ?? "");
-                    const path = this.normalizePath(incomingPath);
-                    await backendLog("info", "normalized path: " + path);
-                    if (!path) {
-                        await backendLog("error", "path is null");
-                        return;
+            EventsOn(
+                EVENT_OPEN_FILE,
+                async (rawPath: string, line?: number, column?: number) => {
+                    await backendLog("info", "open-file event detected: " + rawPath);
+                    let path = "";
+                    try {
+                        const incomingPath =
+                            typeof rawPath === "string"
+                                ? rawPath
+                                : String(rawPath ?? "");
+                        path = this.normalizePath(incomingPath);
+                        await backendLog("info", "normalized path: " + path);
+                        if (!path) {
+                            await backendLog("error", "path is null");
+                            return;
+                        }
+                        // 从后端读取文件内容
+                        const content = await this.loadNote(path);
+                        if (content === null) {
+                            // 没有打开标签，--wait 的调用方不必再等
+                            void notifyDocumentClosed(path);
+                            return;
+                        }
+                        await this.handleFileOpened(path, content);
+                        if (line && line > 0) {
+                            this.revealPosition(line, column ?? 1);
+                        }
+                        // 转发请求的实例或脚本等到标签打开后才得到应答
+                        void notifyDocumentOpened(path);
+                    } catch (error) {
+                        await backendLog("error", "open-file event failed: " + error);
+                        this.flashStatus("Open failed");
+                        if (path && !this.openDocuments.has(path)) {
+                            void notifyDocumentClosed(path);
+                        }
                     }
-                    // 从后端读取文件内容
-                    const content = await loadDocumentFromBackend(path);
-                    await this.handleFileOpened(path, content);
-                } catch (error) {
-                    await backendLog("error", "open-file event failed: " + error);
-                    this.flashStatus("Open failed");
-                }
-            }),
+                },
+            ),
             EventsOn(EVENT_FILE_OPENED, async (path: string, content: string) => {
                 const filePath =
                     typeof path === "string" ? path : String(path ?? "");
@@ -1075,6 +1230,7 @@ export class EditorApp {
                 void this.handleSaveRequested(Boolean(force));
             }),
             EventsOn(EVENT_FILE_SAVED, (path: string) => {
+                this.queryResultCache.clear();
                 const rawPath =
                     typeof path === "string" ? path : String(path ?? "");
                 const filePath = this.normalizePath(rawPath);
@@ -1107,6 +1263,10 @@ export class EditorApp {
                             : tree.path;
 
                     this.sidebarTreeRoot = tree;
+                    if (this.gitStatus) {
+                        this.gitStatus = null;
+                        this.renderMenuBar();
+                    }
                     this.expandedPaths = new Set<string>();
                     if (tree.path) {
                         this.expandedPaths.add(tree.path);
@@ -1131,9 +1291,167 @@ export class EditorApp {
             EventsOn(EVENT_PREVIEW_THEME_CHANGED, (theme: string) => {
                 this.applyPreviewTheme(theme, false);
             }),
+            EventsOn(EVENT_WORKSPACE_INDEXED, () => {
+                this.queryResultCache.clear();
+                this.renderQueryBlocks();
+            }),
+            EventsOn(EVENT_GIT_STATUS, (status: GitStatus | null) => {
+                // 打开文件夹时目录树已带状态标记，之后的变化需重新加载
+                const initial = this.gitStatus === null;
+                this.gitStatus = status ?? null;
+                if (initial !== (this.gitStatus === null)) {
+                    // Git 菜单仅在仓库中显示
+                    this.renderMenuBar();
+                }
+                if (initial) {
+                    this.renderSidebar();
+                } else {
+                    void this.refreshSidebar();
+                }
+            }),
+            EventsOn(EVENT_SYNC_PROGRESS, (progress: SyncProgress) => {
+                if (progress.phase === "syncing" && progress.total > 0) {
+                    this.updateStatus(
+                        `Syncing ${progress.done + 1}/${progress.total}: ${progress.path ?? ""}`,
+                    );
+                }
+            }),
+            EventsOn(
+                EVENT_SYNC_FINISHED,
+                (workspace: string, report: SyncReport) => {
+                    void this.handleSyncFinished(workspace, report);
+                },
+            ),
+            EventsOn(EVENT_SYNC_FAILED, (message: string) => {
+                this.showStatus(`Sync failed: ${message}`, "error");
+            }),
+            EventsOn(EVENT_BACKUP_PROGRESS, (progress: BackupProgress) => {
+                if (progress.phase !== "done" && progress.total > 0) {
+                    const verb =
+                        progress.phase === "restoring" ? "Restoring" : "Backing up";
+                    this.updateStatus(
+                        `${verb} ${progress.done + 1}/${progress.total}: ${progress.path ?? ""}`,
+                    );
+                }
+            }),
+            EventsOn(
+                EVENT_BACKUP_FINISHED,
+                (_workspace: string, report: BackupReport) => {
+                    const pruned = report.pruned?.length ?? 0;
+                    this.showStatus(
+                        `Backup ${report.snapshot.id}: ${report.newChunks} new chunk(s), ${report.reusedChunks} reused` +
+                            (pruned ? `, ${pruned} old snapshot(s) pruned` : ""),
+                        "success",
+                    );
+                },
+            ),
+            EventsOn(EVENT_BACKUP_FAILED, (message: string) => {
+                this.showStatus(`Backup failed: ${message}`, "error");
+            }),
+            EventsOn(EVENT_NOTE_LOCKED, (path: string) => {
+                this.handleNoteLocked(path);
+            }),
+            EventsOn(EVENT_DOCUMENT_FORMATTED, (_path: string, content: string) => {
+                this.handleDocumentFormatted(content);
+            }),
+            EventsOn(EVENT_WRITING_RECORDED, () => {
+                const container = document.getElementById("writing-progress");
+                if (container) {
+                    void this.renderWritingProgress(container);
+                }
+            }),
+            EventsOn(EVENT_WORKSPACE_REPLACED, (result: ReplaceResult) => {
+                void this.reloadDocumentsFromDisk(result.files ?? []);
+            }),
+            EventsOn(EVENT_TRANSFER_PROGRESS, (progress: TransferProgress) => {
+                this.renderTransferProgress(progress);
+            }),
+            EventsOn(
+                EVENT_RPC_REQUEST,
+                (id: string, method: string, rawParams: string) => {
+                    void this.handleRPCRequest(id, method, rawParams);
+                },
+            ),
         ];
     }
 
+    // 脚本 API 转交的请求：读写标签页缓冲区，结果回传给后端
+    private async handleRPCRequest(id: string, method: string, rawParams: string) {
+        try {
+            const params = rawParams ? JSON.parse(rawParams) : {};
+            const result = this.runRPCMethod(method, params ?? {});
+            await completeRPCRequest(id, result);
+        } catch (error) {
+            const message = error instanceof Error ? error.message : String(error);
+            await completeRPCRequest(id, null, message || "request failed");
+        }
+    }
+
+    private runRPCMethod(method: string, params: Record<string, any>): unknown {
+        this.persistActiveDocument();
+
+        switch (method) {
+            case "editor.listTabs":
+                return this.tabOrder.map((path) => {
+                    const doc = this.openDocuments.get(path);
+                    return {
+                        path,
+                        name: doc?.name ?? this.displayNameForPath(path),
+                        dirty: Boolean(doc?.isDirty),
+                        active: path === this.currentFilePath,
+                    };
+                });
+            case "editor.getBuffer": {
+                const doc = this.rpcDocument(params.path);
+                return { path: doc.path, content: doc.currentContent, dirty: doc.isDirty };
+            }
+            case "editor.setBuffer": {
+                const doc = this.rpcDocument(params.path);
+                doc.currentContent = String(params.content ?? "");
+                doc.isDirty = doc.currentContent !== doc.savedContent;
+                if (doc.path === this.currentFilePath) {
+                    this.applyMarkdownContent(doc.currentContent);
+                }
+                this.renderTabs();
+                return null;
+            }
+            case "editor.insertText": {
+                const doc = this.rpcDocument(params.path);
+                if (doc.path !== this.currentFilePath) {
+                    this.switchToDocument(doc.path);
+                }
+                const cm = this.editorInstance?.cm;
+                if (!cm) {
+                    throw new Error("editor is not ready");
+                }
+                cm.replaceSelection(String(params.text ?? ""));
+                this.persistActiveDocument();
+                return null;
+            }
+            case "editor.markSaved": {
+                const doc = this.rpcDocument(params.path);
+                doc.savedContent = String(params.content ?? "");
+                doc.isDirty = doc.currentContent !== doc.savedContent;
+                this.renderTabs();
+                return null;
+            }
+            default:
+                throw new Error(`unknown method ${method}`);
+        }
+    }
+
+    private rpcDocument(path: unknown): OpenDocument {
+        const target =
+            typeof path === "string" && path.trim()
+                ? this.normalizePath(path)
+                : this.currentFilePath;
+        const doc = target ? this.openDocuments.get(target) : undefined;
+        if (!doc) {
+            throw new Error(target ? `document is not open: ${target}` : "no active document");
+        }
+        return doc;
+    }
+
     private async handleFileOpened(path: string, content: string) {
         if (!this.ensureEditorReady()) {
             await backendLog("error", "Editor not ready when handling file open");
@@ -1183,7 +1501,30 @@ export class EditorApp {
         await (this.pendingActiveSync ?? Promise.resolve());
 
         try {
-            const savedPath = await saveDocument(markdown, forceDialog);
+            let savedPath: string;
+            try {
+                savedPath = await saveDocument(markdown, forceDialog);
+            } catch (error) {
+                const message =
+                    error instanceof Error ? error.message : String(error);
+                if (
+                    message !== NOTE_LOCKED_ERROR &&
+                    message !== NOTE_NEEDS_PASSPHRASE_ERROR
+                ) {
+                    throw error;
+                }
+                // 后端已记下保存目标，取得口令后原地重试
+                const target = await getActiveFile();
+                const unlocked = await this.promptNotePassphrase(
+                    target,
+                    message === NOTE_NEEDS_PASSPHRASE_ERROR,
+                );
+                if (unlocked === null) {
+                    this.setCurrentFile(previousPath);
+                    throw new Error(SAVE_CANCELLED_ERROR);
+                }
+                savedPath = await saveDocument(markdown, false);
+            }
             const filePath =
                 typeof savedPath === "string"
                     ? savedPath
@@ -1208,6 +1549,144 @@ export class EditorApp {
         }
     }
 
+    // 读取笔记；加密笔记未解锁时先询问口令，取消则返回 null
+    private async loadNote(path: string): Promise<string | null> {
+        try {
+            return await loadDocumentFromBackend(path);
+        } catch (error) {
+            const message =
+                error instanceof Error ? error.message : String(error);
+            if (message !== NOTE_LOCKED_ERROR) {
+                throw error;
+            }
+            return this.promptNotePassphrase(path, false);
+        }
+    }
+
+    // 询问加密笔记的口令并解锁，返回解密后的内容；取消时返回 null
+    private promptNotePassphrase(
+        path: string,
+        isNew: boolean,
+    ): Promise<string | null> {
+        const name = path.split(/[\\/]/).pop() || path;
+        return this.promptPassphrase(
+            isNew ? `Encrypt ${name}` : `Unlock ${name}`,
+            isNew ? "Encrypt" : "Unlock",
+            isNew,
+            (passphrase) => unlockNote(path, passphrase),
+        );
+    }
+
+    // 口令对话框：action 失败时在对话框内显示错误并允许重试，取消时返回 null
+    private promptPassphrase<T>(
+        title: string,
+        submitLabel: string,
+        confirmNew: boolean,
+        action: (passphrase: string) => Promise<T>,
+        hint = "",
+    ): Promise<T | null> {
+        return new Promise((resolve) => {
+            const { body, footer } = this.createPanelModal(title);
+
+            if (hint) {
+                const text = document.createElement("p");
+                text.className = "mb-3 text-sm text-white/60";
+                text.textContent = hint;
+                body.appendChild(text);
+            }
+
+            const passwordInput = (label: string) => {
+                const row = document.createElement("label");
+                row.className = "flex flex-col gap-1 mb-3 text-sm text-white/70";
+                const input = document.createElement("input");
+                input.type = "password";
+                input.autocomplete = "off";
+                input.className =
+                    "px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+                row.append(label, input);
+                body.appendChild(row);
+                return input;
+            };
+
+            const passphrase = passwordInput("Passphrase");
+            const confirm = confirmNew ? passwordInput("Confirm passphrase") : null;
+            const errorLine = document.createElement("div");
+            errorLine.className = "text-sm text-red-400";
+            body.appendChild(errorLine);
+
+            let settled = false;
+            const finish = (result: T | null) => {
+                if (settled) {
+                    return;
+                }
+                settled = true;
+                this.removeExistingModal();
+                resolve(result);
+            };
+
+            const submit = async () => {
+                if (!passphrase.value) {
+                    errorLine.textContent = "Enter a passphrase.";
+                    return;
+                }
+                if (confirm && confirm.value !== passphrase.value) {
+                    errorLine.textContent = "The passphrases do not match.";
+                    return;
+                }
+                errorLine.textContent = "Deriving key…";
+                try {
+                    finish(await action(passphrase.value));
+                } catch (error) {
+                    passphrase.value = "";
+                    passphrase.focus();
+                    errorLine.textContent =
+                        error instanceof Error ? error.message : String(error);
+                }
+            };
+
+            [passphrase, confirm].forEach((input) =>
+                input?.addEventListener("keydown", (event) => {
+                    if (event.key === "Enter") {
+                        event.preventDefault();
+                        void submit();
+                    } else if (event.key === "Escape") {
+                        finish(null);
+                    }
+                }),
+            );
+            footer.append(
+                this.createPanelButton("Cancel", () => finish(null)),
+                this.createPanelButton(submitLabel, () => void submit(), true),
+            );
+            passphrase.focus();
+        });
+    }
+
+    // 密钥超时后关闭未修改的标签页，不在界面上保留明文
+    private handleNoteLocked(path: string) {
+        const doc = this.openDocuments.get(path);
+        if (!doc) {
+            return;
+        }
+        if (!doc.isDirty) {
+            this.closeDocument(path);
+        }
+        this.flashStatus(`Locked: ${doc.name || path}`);
+    }
+
+    private async handleLockAllNotes() {
+        try {
+            await lockAllNotes();
+        } catch (error) {
+            console.warn("lockAllNotes failed", error);
+            return;
+        }
+        Array.from(this.openDocuments.values())
+            .filter((doc) => isEncryptedNote(doc.path) && !doc.isDirty)
+            .forEach((doc) => this.closeDocument(doc.path));
+        this.flashStatus("Encrypted notes locked");
+    }
+
     private setCurrentFile(path: string | null) {
         const normalized =
             typeof path === "string" && path.trim().length > 0
@@ -1372,14 +1851,16 @@ export class EditorApp {
                 this.editorInstance.showToolbar();                
                 this.editorInstance.config('mode', 'markdown');
                 this.editorInstance.config('codeFold', false);
-                this.editorInstance.config('gutters', ['CodeMirror-linenumbers']);
+                this.editorInstance.config('gutters', ['CodeMirror-linenumbers', 'lint-gutter']);
                 this.editorInstance.watch();
             } else {
                 this.editorInstance.hideToolbar();                
                 this.editorInstance.config('mode', language);
                 this.editorInstance.config('codeFold', true);
                 this.editorInstance.config('gutters', ['CodeMirror-linenumbers', 'CodeMirror-foldgutter']);  
-                this.editorInstance.unwatch();              
+                this.editorInstance.unwatch();
+                this.showLintDiagnostics([]);              
+                this.showSpellingErrors([]);
             }
             backendLog('info', 'Editor re-configured for file type: ' + language);
             /* this.editorInstance.loadedDisplay(true);
@@ -1431,7 +1912,21 @@ export class EditorApp {
         this.setCurrentFile(normalized);
     }
 
+    // 修改状态的每次变化都会重新渲染标签，在这里同步给后端
+    private reportDirtyDocuments() {
+        const dirty = [...this.openDocuments.values()]
+            .filter((doc) => doc.isDirty)
+            .map((doc) => doc.path)
+            .sort();
+        const key = JSON.stringify(dirty);
+        if (key !== this.reportedDirty) {
+            this.reportedDirty = key;
+            void setDirtyDocuments(dirty);
+        }
+    }
+
     private renderTabs() {
+        this.reportDirtyDocuments();
         if (!this.tabBarElement) {
             return;
         }
@@ -1626,6 +2121,8 @@ export class EditorApp {
     }
 
     private handleEditorChange() {
+        this.renderQueryBlocks();
+        this.scheduleLint();
         if (this.suppressChangeHandler) {
             return;
         }
@@ -1643,632 +2140,2875 @@ export class EditorApp {
         }
     }
 
-    private updateDocumentAfterSave(
-        previousPath: string | null,
-        savedPath: string,
-    ) {
-        const normalized = this.normalizePath(savedPath);
-        if (!normalized) {
-            return;
-        }
-
-        const previous = this.normalizePath(previousPath) || normalized;
-        let doc =
-            this.openDocuments.get(previous) ??
-            this.openDocuments.get(normalized);
-        if (!doc) {
-            doc = this.upsertDocument(normalized, this.getMarkdown());
-        }
-
-        const oldPath = doc.path;
-        if (!doc.currentContent) {
-            doc.currentContent = this.getMarkdown();
-        }
-        doc.savedContent = doc.currentContent;
-        doc.isDirty = false;
-
-        if (normalized !== oldPath) {
-            this.openDocuments.delete(oldPath);
-            doc.path = normalized;
-            doc.name = this.displayNameForPath(normalized);
-            this.openDocuments.set(normalized, doc);
-            this.tabOrder = this.tabOrder.map((p) =>
-                p === oldPath ? normalized : p,
-            );
+    // 编辑停顿后再检查，避免每次按键都调用后端
+    private scheduleLint() {
+        if (this.lintTimer !== null) {
+            window.clearTimeout(this.lintTimer);
         }
-
-        if (!this.tabOrder.includes(normalized)) {
-            this.tabOrder.push(normalized);
-        }
-
-        this.renderTabs();
+        this.lintTimer = window.setTimeout(() => {
+            this.lintTimer = null;
+            void this.runLint();
+            void this.runSpellcheck();
+        }, 600);
     }
 
-    private upsertDocument(path: string, content: string): OpenDocument {
-        const normalized = this.normalizePath(path);
-        const resolvedContent = content ?? "";
-        if (!normalized) {
-            const fallbackPath = `untitled-${Date.now()}`;
-            const fallback: OpenDocument = {
-                path: fallbackPath,
-                name: "Untitled",
-                savedContent: resolvedContent,
-                currentContent: resolvedContent,
-                isDirty: false,
-            };
-            this.openDocuments.set(fallbackPath, fallback);
-            if (!this.tabOrder.includes(fallbackPath)) {
-                this.tabOrder.push(fallbackPath);
-            }
-            return fallback;
+    private async runLint() {
+        const cm = this.editorInstance?.cm;
+        const path = this.currentFilePath;
+        if (!cm || !path || !isMarkdownFile(path)) {
+            this.showLintDiagnostics([]);
+            return;
         }
-
-        let doc = this.openDocuments.get(normalized);
-        if (!doc) {
-            doc = {
-                path: normalized,
-                name: this.displayNameForPath(normalized),
-                savedContent: resolvedContent,
-                currentContent: resolvedContent,
-                isDirty: false,
-            };
-            this.openDocuments.set(normalized, doc);
-        } else {
-            doc.savedContent = resolvedContent;
-            doc.currentContent = resolvedContent;
-            doc.isDirty = false;
+        const content = cm.getValue();
+        try {
+            const diagnostics = await lintDocument(path, content);
+            // 结果返回前内容已变化时丢弃，等待下一次检查
+            if (this.currentFilePath === path && cm.getValue() === content) {
+                this.showLintDiagnostics(diagnostics);
+            }
+        } catch (error) {
+            console.warn("lintDocument failed", error);
+            this.showLintDiagnostics([]);
         }
+    }
 
-        if (!this.tabOrder.includes(normalized)) {
-            this.tabOrder.push(normalized);
+    private showLintDiagnostics(diagnostics: LintDiagnostic[]) {
+        const cm = this.editorInstance?.cm;
+        this.lintMarks.forEach((mark) => mark.clear());
+        this.lintMarks = [];
+        if (!cm) {
+            return;
         }
+        cm.clearGutter("lint-gutter");
+
+        const severityRank = { error: 3, warning: 2, info: 1 };
+        const byLine = new Map<number, LintDiagnostic[]>();
+        diagnostics.forEach((diagnostic) => {
+            const list = byLine.get(diagnostic.line) ?? [];
+            list.push(diagnostic);
+            byLine.set(diagnostic.line, list);
+            this.lintMarks.push(
+                cm.markText(
+                    { line: diagnostic.line - 1, ch: diagnostic.column - 1 },
+                    { line: diagnostic.endLine - 1, ch: diagnostic.endColumn - 1 },
+                    {
+                        className: `lint-mark lint-mark-${diagnostic.severity}`,
+                        attributes: { title: `${diagnostic.message} [${diagnostic.rule}]` },
+                    },
+                ),
+            );
+        });
 
-        return doc;
+        byLine.forEach((list, line) => {
+            const worst = list.reduce((a, b) =>
+                severityRank[b.severity] > severityRank[a.severity] ? b : a,
+            );
+            const fixes = list.filter((diagnostic) => diagnostic.fix);
+            const marker = document.createElement("div");
+            marker.className = `lint-marker lint-marker-${worst.severity}`;
+            marker.textContent = "●";
+            marker.title = list
+                .map((d) => `${d.severity}: ${d.message} [${d.rule}]`)
+                .concat(fixes.length ? ["Click to fix"] : [])
+                .join("\n");
+            if (fixes.length) {
+                marker.classList.add("lint-marker-fixable");
+                marker.addEventListener("click", () => this.applyLintFixes(fixes));
+            }
+            cm.setGutterMarker(line - 1, "lint-gutter", marker);
+        });
     }
 
-    private displayNameForPath(path: string): string {
-        const normalized = this.normalizePath(path);
-        if (!normalized) {
-            return "Untitled";
+    private async runSpellcheck() {
+        const cm = this.editorInstance?.cm;
+        const path = this.currentFilePath;
+        if (!cm || !path || !isMarkdownFile(path)) {
+            this.showSpellingErrors([]);
+            return;
         }
-        const lastSlash = Math.max(
-            normalized.lastIndexOf("/"),
-            normalized.lastIndexOf("\\"),
-        );
-        if (lastSlash >= 0 && lastSlash < normalized.length - 1) {
-            return normalized.slice(lastSlash + 1);
+        const content = cm.getValue();
+        try {
+            const errors = await checkSpelling(path, content);
+            if (this.currentFilePath === path && cm.getValue() === content) {
+                this.showSpellingErrors(errors);
+            }
+        } catch (error) {
+            // 未安装词典时只记录，不打扰编辑
+            console.warn("checkSpelling failed", error);
+            this.showSpellingErrors([]);
         }
-        return normalized;
     }
 
-    private normalizePath(input: string | null | undefined): string {
-        if (typeof input !== "string") {
-            return "";
+    private showSpellingErrors(errors: SpellingError[]) {
+        const cm = this.editorInstance?.cm;
+        this.spellMarks.forEach((mark) => mark.clear());
+        this.spellMarks = [];
+        this.spellingErrors = errors;
+        if (!cm) {
+            return;
         }
-        return input.trim();
+        errors.forEach((error) => {
+            this.spellMarks.push(
+                cm.markText(
+                    { line: error.line - 1, ch: error.column - 1 },
+                    { line: error.endLine - 1, ch: error.endColumn - 1 },
+                    {
+                        className: "spell-mark",
+                        attributes: {
+                            title: error.suggestions.length
+                                ? `Did you mean: ${error.suggestions.join(", ")}? (right-click for options)`
+                                : "Unknown word (right-click for options)",
+                        },
+                    },
+                ),
+            );
+        });
     }
 
-    private closeDocument(path: string) {
-        const normalized = this.normalizePath(path);
-        if (!normalized) {
+    // 在拼写错误上右键时显示建议；其他位置保留默认菜单
+    private handleEditorContextMenu(event: MouseEvent) {
+        const cm = this.editorInstance?.cm;
+        if (!cm || !this.spellingErrors.length) {
             return;
         }
-
-        const doc = this.openDocuments.get(normalized);
-        if (!doc) {
+        const pos = cm.coordsChar({ left: event.clientX, top: event.clientY }, "window");
+        const error = this.spellingErrors.find(
+            (item) =>
+                item.line - 1 === pos.line &&
+                item.column - 1 <= pos.ch &&
+                pos.ch <= item.endColumn - 1,
+        );
+        if (!error) {
             return;
         }
+        event.preventDefault();
+        this.hideContextMenu();
 
-        const wasActive = normalized === this.currentFilePath;
-        const index = this.tabOrder.indexOf(normalized);
-
-        this.openDocuments.delete(normalized);
-        if (index >= 0) {
-            this.tabOrder.splice(index, 1);
+        const menu = document.createElement("div");
+        menu.className = "no-drag";
+        menu.style.position = "fixed";
+        menu.style.top = `${event.clientY}px`;
+        menu.style.left = `${event.clientX}px`;
+        menu.style.minWidth = "12rem";
+        menu.style.maxWidth = "20rem";
+        menu.style.maxHeight = "20rem";
+        menu.style.overflowY = "auto";
+        menu.style.background = "rgba(15, 15, 20, 0.95)";
+        menu.style.border = "1px solid rgba(255, 255, 255, 0.1)";
+        menu.style.borderRadius = "0.375rem";
+        menu.style.boxShadow = "0 10px 24px rgba(0, 0, 0, 0.35)";
+        menu.style.padding = "0.25rem 0";
+        menu.style.zIndex = "3000";
+        menu.style.backdropFilter = "blur(8px)";
+
+        const from = { line: error.line - 1, ch: error.column - 1 };
+        const to = { line: error.endLine - 1, ch: error.endColumn - 1 };
+        if (error.suggestions.length === 0) {
+            const empty = document.createElement("div");
+            empty.className = "px-3 py-2 text-sm text-white/50";
+            empty.textContent = "No suggestions";
+            menu.appendChild(empty);
+        }
+        error.suggestions.forEach((suggestion) => {
+            menu.appendChild(
+                this.createContextMenuItem(suggestion, "✎", () => {
+                    // 替换前确认单词未被编辑过
+                    if (cm.getRange(from, to) === error.word) {
+                        cm.replaceRange(suggestion, from, to);
+                    }
+                }),
+            );
+        });
+
+        const separator = document.createElement("div");
+        separator.style.margin = "4px 0";
+        separator.style.borderTop = "1px solid rgba(255, 255, 255, 0.07)";
+        menu.appendChild(separator);
+        menu.appendChild(
+            this.createContextMenuItem("Add to Dictionary", "📖", () => {
+                void this.handleAddToDictionary(error.word, false);
+            }),
+        );
+        if (this.currentFolderPath) {
+            menu.appendChild(
+                this.createContextMenuItem("Add to Workspace Words", "🗂️", () => {
+                    void this.handleAddToDictionary(error.word, true);
+                }),
+            );
         }
 
-        const label = doc.name || normalized || "Untitled";
+        document.body.appendChild(menu);
+        this.contextMenu = menu;
 
-        if (wasActive) {
-            const fallbackPath =
-                this.tabOrder[index] ??
-                this.tabOrder[index - 1] ??
-                (this.tabOrder.length ? this.tabOrder[0] : "");
+        const hideMenu = (e: MouseEvent) => {
+            if (!menu.contains(e.target as Node)) {
+                this.hideContextMenu();
+                document.removeEventListener("click", hideMenu);
+            }
+        };
+        setTimeout(() => {
+            document.addEventListener("click", hideMenu);
+        }, 10);
+    }
 
-            if (fallbackPath) {
-                const fallbackDoc = this.openDocuments.get(fallbackPath);
-                this.reconfigureEditorForFileType(fallbackPath);
-                this.setCurrentFile(fallbackPath);
-                this.applyMarkdownContent(fallbackDoc?.currentContent ?? "");
+    private async handleAddToDictionary(word: string, workspace: boolean) {
+        try {
+            await addToDictionary(word, workspace);
+            this.flashStatus(`Added "${word}" to ${workspace ? "workspace words" : "dictionary"}`);
+            void this.runSpellcheck();
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+        }
+    }
+
+    // 从后往前应用修复，前面的位置不受影响；合并为一次撤销
+    private applyLintFixes(diagnostics: LintDiagnostic[]) {
+        const cm = this.editorInstance?.cm;
+        if (!cm) {
+            return;
+        }
+        const fixes = diagnostics
+            .map((diagnostic) => diagnostic.fix!)
+            .sort((a, b) => b.line - a.line || b.column - a.column);
+        cm.operation(() => {
+            fixes.forEach((fix) =>
+                cm.replaceRange(
+                    fix.text,
+                    { line: fix.line - 1, ch: fix.column - 1 },
+                    { line: fix.endLine - 1, ch: fix.endColumn - 1 },
+                ),
+            );
+        });
+    }
+
+    private async handleFixLintProblems() {
+        const cm = this.editorInstance?.cm;
+        const path = this.currentFilePath;
+        if (!cm || !path || !isMarkdownFile(path)) {
+            this.flashStatus("Open a Markdown file to fix");
+            return;
+        }
+        const content = cm.getValue();
+        try {
+            const fixed = await fixLintProblems(path, content);
+            if (fixed === content) {
+                this.flashStatus("Nothing to fix");
+                return;
+            }
+            this.replaceEditorBuffer(fixed);
+            this.flashStatus("Lint problems fixed");
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+        }
+    }
+
+    // 保持光标位置，整体替换仍可撤销
+    private replaceEditorBuffer(content: string) {
+        const cm = this.editorInstance?.cm;
+        if (!cm) {
+            return;
+        }
+        const cursor = cm.getCursor();
+        cm.operation(() => {
+            cm.replaceRange(
+                content,
+                { line: 0, ch: 0 },
+                { line: cm.lastLine(), ch: cm.getLine(cm.lastLine()).length },
+            );
+            cm.setCursor(cursor);
+        });
+    }
+
+    private async handleFormatDocument(selectionOnly: boolean) {
+        const cm = this.editorInstance?.cm;
+        const path = this.currentFilePath;
+        if (!cm || !path || !isMarkdownFile(path)) {
+            this.flashStatus("Open a Markdown file to format");
+            return;
+        }
+        const content = cm.getValue();
+        try {
+            let formatted: string;
+            if (selectionOnly) {
+                const from = cm.getCursor("from");
+                const to = cm.getCursor("to");
+                // 选区结束于行首时不包含该行
+                const endLine = to.ch === 0 && to.line > from.line ? to.line : to.line + 1;
+                formatted = await formatSelection(path, content, from.line + 1, endLine);
             } else {
-                this.setCurrentFile(null);
-                this.applyMarkdownContent(DEFAULT_WELCOME_MARKDOWN);
+                formatted = await formatDocument(path, content);
             }
-        } else {
-            this.renderTabs();
+            if (formatted === content) {
+                this.flashStatus("Already formatted");
+                return;
+            }
+            this.replaceEditorBuffer(formatted);
+            this.flashStatus(selectionOnly ? "Selection formatted" : "Document formatted");
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
         }
+    }
 
-        this.flashStatus(`Closed: ${label}`);
+    // 保存时格式化：后端写入的是格式化后的内容，编辑器同步显示
+    private handleDocumentFormatted(content: string) {
+        const path = this.currentFilePath;
+        if (!this.editorInstance?.cm || typeof content !== "string") {
+            return;
+        }
+        this.suppressChangeHandler = true;
+        try {
+            this.replaceEditorBuffer(content);
+        } finally {
+            this.suppressChangeHandler = false;
+        }
+        const doc = path ? this.openDocuments.get(path) : undefined;
+        if (doc) {
+            doc.currentContent = content;
+        }
     }
 
-    private renderMenuBar() {
-        if (!this.menuElement) {
+    private toggleFormatOnSave() {
+        if (!this.currentSettings) {
             return;
         }
+        const enabled = !this.currentSettings.formatOnSave;
+        this.currentSettings = { ...this.currentSettings, formatOnSave: enabled };
+        saveSettings({ formatOnSave: enabled }).catch((error) =>
+            console.warn("failed saving settings", error),
+        );
+        this.flashStatus(enabled ? "Format on save enabled" : "Format on save disabled");
+    }
 
-        this.menuElement.innerHTML = "";
-        if (this.activeDropdown) {
-            this.activeDropdown.remove();
-            this.activeDropdown = null;
+    // 刷新 <!-- toc --> 标记的目录；没有标记时插入到光标所在行之前
+    private async handleUpdateTOC() {
+        const cm = this.editorInstance?.cm;
+        const path = this.currentFilePath;
+        if (!cm || !path || !isMarkdownFile(path)) {
+            this.flashStatus("Open a Markdown file to update its table of contents");
+            return;
         }
+        const content = cm.getValue();
+        try {
+            const updated = await updateTOC(path, content, cm.getCursor().line + 1);
+            if (updated === content) {
+                this.flashStatus("Table of contents is up to date");
+                return;
+            }
+            this.replaceEditorBuffer(updated);
+            this.flashStatus("Table of contents updated");
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+        }
+    }
 
-        const menus: Array<{
-            id: string;
-            label: string;
-            builder: (dropdown: HTMLDivElement) => void;
-        }> = [
-            {
-                id: "file",
-                label: "File",
-                builder: (dropdown) => this.buildFileMenu(dropdown),
-            },
-            {
-                id: "theme",
-                label: "Theme",
-                builder: (dropdown) => this.buildThemeMenu(dropdown),
-            },
-            {
-                id: "help",
-                label: "Help",
-                builder: (dropdown) => this.buildHelpMenu(dropdown),
-            },
-        ];
+    private toggleTOCOnSave() {
+        if (!this.currentSettings) {
+            return;
+        }
+        const enabled = !this.currentSettings.tocOnSave;
+        this.currentSettings = { ...this.currentSettings, tocOnSave: enabled };
+        saveSettings({ tocOnSave: enabled }).catch((error) =>
+            console.warn("failed saving settings", error),
+        );
+        this.flashStatus(enabled ? "TOC update on save enabled" : "TOC update on save disabled");
+    }
 
-        menus.forEach((menu) => {
-            const wrapper = document.createElement("div");
-            wrapper.className = "relative";
+    // 文档标题大纲，输入过滤，点击跳转到标题所在行
+    private async showOutlineDialog() {
+        const cm = this.editorInstance?.cm;
+        if (!cm) {
+            return;
+        }
+        let headings: OutlineHeading[];
+        try {
+            headings = await documentOutline(cm.getValue());
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+            return;
+        }
 
-            const button = document.createElement("button");
-            button.type = "button";
-            button.textContent = menu.label;
-            button.className =
-                "px-2 py-1 rounded hover:bg-white/10 focus:outline-none";
-            button.addEventListener("click", (event) => {
+        const { body, footer } = this.createPanelModal("Outline");
+        const filter = document.createElement("input");
+        filter.type = "text";
+        filter.placeholder = "Filter headings";
+        filter.className =
+            "w-full mb-2 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const list = document.createElement("div");
+        list.className = "flex flex-col text-sm";
+        body.append(filter, list);
+
+        const minLevel = Math.min(...headings.map((heading) => heading.level), 6);
+        const render = () => {
+            list.replaceChildren();
+            const query = filter.value.trim().toLowerCase();
+            const matches = headings.filter((heading) =>
+                heading.text.toLowerCase().includes(query),
+            );
+            if (matches.length === 0) {
+                const empty = document.createElement("div");
+                empty.className = "text-white/50";
+                empty.textContent = headings.length === 0 ? "No headings" : "No matching headings";
+                list.appendChild(empty);
+                return;
+            }
+            matches.forEach((heading) => {
+                const item = document.createElement("button");
+                item.type = "button";
+                item.className =
+                    "flex items-center gap-2 px-2 py-1 rounded text-left text-white/80 hover:bg-white/10";
+                item.style.paddingLeft = `${0.5 + (heading.level - minLevel) * 1}rem`;
+                const text = document.createElement("span");
+                text.className = "flex-1 truncate";
+                text.textContent = heading.text;
+                const line = document.createElement("span");
+                line.className = "text-xs text-white/40 tabular-nums";
+                line.textContent = String(heading.line);
+                item.append(text, line);
+                item.addEventListener("click", () => {
+                    this.removeExistingModal();
+                    this.revealPosition(heading.line, 1);
+                });
+                list.appendChild(item);
+            });
+        };
+        filter.addEventListener("input", render);
+        filter.addEventListener("keydown", (event) => {
+            if (event.key === "Enter") {
+                (list.querySelector("button") as HTMLButtonElement | null)?.click();
+            }
+        });
+        render();
+
+        footer.append(
+            this.createPanelButton("Close", () => this.removeExistingModal()),
+        );
+        filter.focus();
+    }
+
+    // 按所选标题级别把当前笔记拆成多篇，原笔记改为链接各篇的索引
+    private showSplitNoteDialog() {
+        const cm = this.editorInstance?.cm;
+        const path = this.currentFilePath;
+        if (!cm || !path || !isMarkdownFile(path) || isEncryptedNote(path)) {
+            this.flashStatus("Open a Markdown note to split it");
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Split Note");
+        const hint = document.createElement("p");
+        hint.className = "mb-3 text-sm text-white/60";
+        hint.textContent = "Each heading of the chosen level becomes a note of its own. This note keeps the rest and links to the new notes; relative links and images are updated.";
+        body.appendChild(hint);
+
+        const field = (label: string, input: HTMLElement) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-3 mb-2 text-sm text-white/80";
+            const name = document.createElement("span");
+            name.className = "w-28";
+            name.textContent = label;
+            row.append(name, input);
+            body.appendChild(row);
+        };
+        const inputClass =
+            "flex-1 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const level = document.createElement("select");
+        level.className = inputClass;
+        [1, 2, 3, 4, 5, 6].forEach((value) => {
+            const option = document.createElement("option");
+            option.value = String(value);
+            option.textContent = `${"#".repeat(value)} Heading ${value}`;
+            option.selected = value === 2;
+            level.appendChild(option);
+        });
+        field("Split at", level);
+        const folder = document.createElement("input");
+        folder.type = "text";
+        folder.className = inputClass;
+        folder.placeholder = "Beside this note";
+        folder.value = this.displayNameForPath(path).replace(/\.[^.]+$/, "");
+        field("Folder", folder);
+
+        const checkbox = (label: string, checked: boolean) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-2 mb-1 text-sm text-white/80";
+            const input = document.createElement("input");
+            input.type = "checkbox";
+            input.checked = checked;
+            row.append(input, document.createTextNode(label));
+            body.appendChild(row);
+            return input;
+        };
+        const promote = checkbox("Start each note at heading level 1", true);
+        const numbered = checkbox("Number the file names", false);
+
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        const split = async () => {
+            try {
+                const result = await splitNote(path, cm.getValue(), {
+                    level: Number(level.value),
+                    folder: folder.value.trim(),
+                    promote: promote.checked,
+                    numbered: numbered.checked,
+                });
+                this.removeExistingModal();
+                // 索引笔记已写入磁盘，编辑器内容同步为已保存状态
+                this.handleDocumentFormatted(result.content);
+                const doc = this.openDocuments.get(path);
+                if (doc) {
+                    doc.savedContent = result.content;
+                    doc.isDirty = false;
+                    this.renderTabs();
+                }
+                this.refreshSidebar();
+                this.flashStatus(`Split into ${result.files.length} notes`);
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+            }
+        };
+
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Split", () => void split(), true),
+        );
+    }
+
+    // 合并文件夹中选中的笔记，按勾选顺序排列，标题级别下移
+    private async showMergeNotesDialog(folder: string) {
+        if (!folder) {
+            return;
+        }
+        let notes: string[];
+        try {
+            notes = (await loadDirectoryEntries(folder))
+                .filter((entry) => !entry.isDir && isMarkdownFile(entry.path) && !isEncryptedNote(entry.path))
+                .map((entry) => entry.path);
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Merge Notes");
+        const hint = document.createElement("p");
+        hint.className = "mb-3 text-sm text-white/60";
+        hint.textContent = "Tick the notes in the order they should appear. Links between them become links to their headings in the merged note.";
+        body.appendChild(hint);
+
+        const selected: string[] = [];
+        const list = document.createElement("div");
+        list.className = "flex flex-col mb-3 max-h-64 overflow-y-auto text-sm";
+        body.appendChild(list);
+        const badges = new Map<string, HTMLSpanElement>();
+        const renumber = () => {
+            badges.forEach((badge, note) => {
+                const position = selected.indexOf(note);
+                badge.textContent = position >= 0 ? String(position + 1) : "";
+            });
+        };
+        if (notes.length === 0) {
+            const empty = document.createElement("div");
+            empty.className = "text-white/50";
+            empty.textContent = "No Markdown notes in this folder";
+            list.appendChild(empty);
+        }
+        notes.forEach((note) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-2 px-1 py-0.5 rounded text-white/80 hover:bg-white/5";
+            const input = document.createElement("input");
+            input.type = "checkbox";
+            const badge = document.createElement("span");
+            badge.className = "w-5 text-xs text-blue-300 tabular-nums";
+            badges.set(note, badge);
+            input.addEventListener("change", () => {
+                const position = selected.indexOf(note);
+                if (input.checked && position < 0) {
+                    selected.push(note);
+                } else if (!input.checked && position >= 0) {
+                    selected.splice(position, 1);
+                }
+                renumber();
+            });
+            row.append(input, badge, document.createTextNode(this.displayNameForPath(note)));
+            list.appendChild(row);
+        });
+
+        const inputClass =
+            "flex-1 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const field = (label: string, input: HTMLElement) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-3 mb-2 text-sm text-white/80";
+            const name = document.createElement("span");
+            name.className = "w-28";
+            name.textContent = label;
+            row.append(name, input);
+            body.appendChild(row);
+        };
+        const name = document.createElement("input");
+        name.type = "text";
+        name.className = inputClass;
+        name.value = "Merged.md";
+        field("New note", name);
+        const shift = document.createElement("select");
+        shift.className = inputClass;
+        [0, 1, 2, 3].forEach((value) => {
+            const option = document.createElement("option");
+            option.value = String(value);
+            option.textContent = value === 0 ? "Keep heading levels" : `Shift headings down ${value}`;
+            option.selected = value === 1;
+            shift.appendChild(option);
+        });
+        field("Headings", shift);
+
+        const checkbox = (label: string, checked: boolean) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-2 mb-1 text-sm text-white/80";
+            const input = document.createElement("input");
+            input.type = "checkbox";
+            input.checked = checked;
+            row.append(input, document.createTextNode(label));
+            body.appendChild(row);
+            return input;
+        };
+        const titles = checkbox("Start each part with the note's title", true);
+        const deleteSources = checkbox("Delete the merged notes", false);
+
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        const merge = async () => {
+            const fileName = name.value.trim();
+            if (selected.length === 0 || !fileName) {
+                errorLine.textContent = "Select the notes and name the merged note";
+                return;
+            }
+            if (fileName.includes("/") || fileName.includes("\\")) {
+                errorLine.textContent = "File name cannot contain path separators";
+                return;
+            }
+            try {
+                const result = await mergeNotes([...selected], `${folder}/${fileName}`, {
+                    shift: Number(shift.value),
+                    titles: titles.checked,
+                    deleteSources: deleteSources.checked,
+                });
+                this.removeExistingModal();
+                this.refreshSidebar();
+                await this.openFile(result.path);
+                this.flashStatus(`Merged ${selected.length} notes`);
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+            }
+        };
+
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Merge", () => void merge(), true),
+        );
+    }
+
+    // 按模板或正则批量重命名文件夹中的文件，预览冲突后应用并更新链接
+    private showBatchRenameDialog(folder: string) {
+        if (!folder) {
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Batch Rename");
+        const hint = document.createElement("p");
+        hint.className = "mb-3 text-sm text-white/60";
+        hint.textContent = "Templates can use {{index:03}}, {{title}} (front matter title or first heading), {{name}}, {{ext}}, {{date}} and helpers like {{slug title}}. Links to the renamed files are updated across the workspace.";
+        body.appendChild(hint);
+
+        const inputClass =
+            "flex-1 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const field = (label: string, input: HTMLElement) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-3 mb-2 text-sm text-white/80";
+            const name = document.createElement("span");
+            name.className = "w-28";
+            name.textContent = label;
+            row.append(name, input);
+            body.appendChild(row);
+            return row;
+        };
+        const textInput = (value: string, placeholder: string) => {
+            const input = document.createElement("input");
+            input.type = "text";
+            input.className = inputClass;
+            input.value = value;
+            input.placeholder = placeholder;
+            return input;
+        };
+        const select = (options: Array<[string, string]>) => {
+            const input = document.createElement("select");
+            input.className = inputClass;
+            options.forEach(([value, label]) => {
+                const option = document.createElement("option");
+                option.value = value;
+                option.textContent = label;
+                input.appendChild(option);
+            });
+            return input;
+        };
+
+        const mode = select([
+            ["template", "Name template"],
+            ["regex", "Regular expression"],
+        ]);
+        field("Rename by", mode);
+        const pattern = textInput("", "Only files matching this regular expression");
+        field("Pattern", pattern);
+        const template = textInput("{{index:03}}-{{slug title}}.md", "");
+        const templateRow = field("Template", template);
+        const replacement = textInput("", "$1 and ${name} insert groups of the pattern");
+        const replacementRow = field("Replace with", replacement);
+        const sort = select([
+            ["name", "File name"],
+            ["title", "Title"],
+            ["modified", "Last modified"],
+        ]);
+        const sortRow = field("Number by", sort);
+        const start = document.createElement("input");
+        start.type = "number";
+        start.min = "0";
+        start.value = "1";
+        start.className = inputClass;
+        const startRow = field("Start at", start);
+        const allFilesRow = document.createElement("label");
+        allFilesRow.className = "flex items-center gap-2 mb-3 text-sm text-white/80";
+        const allFiles = document.createElement("input");
+        allFiles.type = "checkbox";
+        allFilesRow.append(allFiles, document.createTextNode("Include files that are not Markdown"));
+        body.appendChild(allFilesRow);
+
+        const summary = document.createElement("div");
+        summary.className = "mb-2 text-sm text-white/60";
+        body.appendChild(summary);
+        const list = document.createElement("div");
+        list.className = "flex flex-col max-h-72 overflow-y-auto text-sm font-mono";
+        body.appendChild(list);
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        let preview: RenamePreview | null = null;
+        const options = (): RenameOptions => ({
+            template: template.value,
+            regex: mode.value === "regex",
+            pattern: pattern.value,
+            replacement: replacement.value,
+            sort: sort.value as RenameOptions["sort"],
+            start: Number(start.value) || 0,
+            allFiles: allFiles.checked,
+        });
+
+        const renderPreview = () => {
+            list.replaceChildren();
+            if (!preview) {
+                summary.textContent = "";
+                return;
+            }
+            const ready = preview.items.length - preview.conflicts;
+            summary.textContent =
+                preview.items.length === 0
+                    ? "No files would be renamed"
+                    : `${ready} of ${preview.items.length} files can be renamed`;
+            preview.items.forEach((item) => {
+                const row = document.createElement("div");
+                row.className = "flex items-baseline gap-2 px-1 py-0.5";
+                const from = document.createElement("span");
+                from.className = "text-white/70 truncate";
+                from.textContent = item.name;
+                const arrow = document.createElement("span");
+                arrow.className = "text-white/40";
+                arrow.textContent = "→";
+                const to = document.createElement("span");
+                to.className = item.conflict ? "text-red-300 truncate" : "text-green-300 truncate";
+                to.textContent = item.newName;
+                row.append(from, arrow, to);
+                if (item.conflict) {
+                    const reason = document.createElement("span");
+                    reason.className = "ml-auto text-xs text-red-400 font-sans whitespace-nowrap";
+                    reason.textContent = item.conflict;
+                    row.appendChild(reason);
+                }
+                list.appendChild(row);
+            });
+        };
+
+        let previewTimer: number | undefined;
+        const runPreview = async () => {
+            const regex = mode.value === "regex";
+            templateRow.style.display = regex ? "none" : "";
+            sortRow.style.display = regex ? "none" : "";
+            startRow.style.display = regex ? "none" : "";
+            replacementRow.style.display = regex ? "" : "none";
+            errorLine.textContent = "";
+            try {
+                preview = await previewBatchRename(folder, options());
+            } catch (error) {
+                preview = null;
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+            }
+            renderPreview();
+        };
+        const schedulePreview = () => {
+            window.clearTimeout(previewTimer);
+            previewTimer = window.setTimeout(() => void runPreview(), 250);
+        };
+        [mode, pattern, template, replacement, sort, start, allFiles].forEach((input) => {
+            input.addEventListener("input", schedulePreview);
+            input.addEventListener("change", schedulePreview);
+        });
+
+        const rename = async () => {
+            window.clearTimeout(previewTimer);
+            await runPreview();
+            const items = (preview?.items ?? []).filter((item) => !item.conflict);
+            if (items.length === 0) {
+                errorLine.textContent = "No files can be renamed";
+                return;
+            }
+            // 链接改写直接作用于磁盘文件，未保存的文档会与之冲突
+            const dirty = [...this.openDocuments.values()].find((doc) => doc.isDirty);
+            if (dirty) {
+                errorLine.textContent = `Save ${dirty.name} before renaming`;
+                return;
+            }
+            try {
+                const result = await batchRename(items);
+                // 先改成临时路径再改成新路径，批次内互换名称时标签页不会互相覆盖
+                result.renamed.forEach((item) =>
+                    this.updateOpenDocumentsAfterRename(item.path, `${item.path}\u0000renaming`, false),
+                );
+                result.renamed.forEach((item) =>
+                    this.updateOpenDocumentsAfterRename(`${item.path}\u0000renaming`, item.newPath, false),
+                );
+                await this.reloadDocumentsFromDisk(result.updated);
+                this.removeExistingModal();
+                this.refreshSidebar();
+                this.flashStatus(
+                    `Renamed ${result.renamed.length} files, updated ${result.links} links`,
+                );
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+                this.refreshSidebar();
+            }
+        };
+
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Rename", () => void rename(), true),
+        );
+        void runPreview();
+        template.focus();
+    }
+
+    // 工作区查找替换：先预览全部改动，勾选后一次写入，可整体撤销
+    private showReplaceInWorkspaceDialog() {
+        if (!this.currentFolderPath) {
+            this.flashStatus("Open a folder to replace in it");
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Replace in Workspace");
+        const inputClass =
+            "flex-1 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const field = (label: string, placeholder: string) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-3 mb-2 text-sm text-white/80";
+            const name = document.createElement("span");
+            name.className = "w-28";
+            name.textContent = label;
+            const input = document.createElement("input");
+            input.type = "text";
+            input.className = inputClass;
+            input.placeholder = placeholder;
+            row.append(name, input);
+            body.appendChild(row);
+            return input;
+        };
+        const find = field("Find", "Text or regular expression");
+        const replacement = field("Replace with", "$1 and ${name} insert groups of a regular expression");
+        const include = field("Include", "e.g. notes/**, *.md");
+        const exclude = field("Exclude", "e.g. archive/**");
+
+        const options = document.createElement("div");
+        options.className = "flex flex-wrap gap-4 mb-3 text-sm text-white/80";
+        body.appendChild(options);
+        const checkbox = (label: string) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-2";
+            const input = document.createElement("input");
+            input.type = "checkbox";
+            row.append(input, document.createTextNode(label));
+            options.appendChild(row);
+            return input;
+        };
+        const regex = checkbox("Regular expression");
+        const caseSensitive = checkbox("Match case");
+        const wholeWord = checkbox("Whole word");
+        const allFiles = checkbox("All text files");
+
+        const summary = document.createElement("div");
+        summary.className = "mb-2 text-sm text-white/60";
+        body.appendChild(summary);
+        const list = document.createElement("div");
+        list.className = "flex flex-col max-h-80 overflow-y-auto text-sm font-mono";
+        body.appendChild(list);
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        const globs = (input: HTMLInputElement) =>
+            input.value
+                .split(",")
+                .map((glob) => glob.trim())
+                .filter(Boolean);
+        const reportError = (error: unknown) => {
+            errorLine.textContent =
+                error instanceof Error ? error.message : String(error);
+        };
+
+        // 预览时的选项与结果，替换时原样提交，输入框改动后需重新预览
+        let previewed: { options: ReplaceOptions; preview: ReplacePreview } | null = null;
+        const selected = new Map<string, Set<number>>();
+
+        const renderPreview = () => {
+            list.replaceChildren();
+            selected.clear();
+            if (!previewed) {
+                summary.textContent = "";
+                return;
+            }
+            const { preview } = previewed;
+            summary.textContent =
+                preview.changes === 0
+                    ? "No matches"
+                    : `${preview.changes} changes in ${preview.files.length} files`;
+            preview.files.forEach((file) => {
+                const offsets = new Set(file.changes.map((change) => change.offset));
+                selected.set(file.path, offsets);
+
+                const header = document.createElement("label");
+                header.className = "flex items-center gap-2 mt-2 px-1 text-white/90 font-sans";
+                const all = document.createElement("input");
+                all.type = "checkbox";
+                all.checked = true;
+                header.append(all, document.createTextNode(`${file.relPath} (${file.changes.length})`));
+                list.appendChild(header);
+
+                const boxes: HTMLInputElement[] = [];
+                file.changes.forEach((change) => {
+                    const row = document.createElement("label");
+                    row.className = "flex items-start gap-2 pl-6 pr-1 py-0.5 rounded hover:bg-white/5";
+                    const input = document.createElement("input");
+                    input.type = "checkbox";
+                    input.checked = true;
+                    input.className = "mt-1";
+                    input.addEventListener("change", () => {
+                        if (input.checked) {
+                            offsets.add(change.offset);
+                        } else {
+                            offsets.delete(change.offset);
+                        }
+                        all.checked = offsets.size === file.changes.length;
+                    });
+                    boxes.push(input);
+
+                    const lines = document.createElement("div");
+                    lines.className = "flex flex-col min-w-0";
+                    const before = document.createElement("span");
+                    before.className = "text-red-300 truncate";
+                    before.textContent = `${change.line}: - ${change.text}`;
+                    const after = document.createElement("span");
+                    after.className = "text-green-300 truncate";
+                    after.textContent = `${change.line}: + ${change.preview}`;
+                    lines.append(before, after);
+                    row.append(input, lines);
+                    list.appendChild(row);
+                });
+                all.addEventListener("change", () => {
+                    boxes.forEach((box) => {
+                        box.checked = all.checked;
+                    });
+                    offsets.clear();
+                    if (all.checked) {
+                        file.changes.forEach((change) => offsets.add(change.offset));
+                    }
+                });
+            });
+        };
+
+        const runPreview = async () => {
+            errorLine.textContent = "";
+            if (!find.value) {
+                previewed = null;
+                renderPreview();
+                return;
+            }
+            const request: ReplaceOptions = {
+                query: find.value,
+                replacement: replacement.value,
+                regex: regex.checked,
+                caseSensitive: caseSensitive.checked,
+                wholeWord: wholeWord.checked,
+                include: globs(include),
+                exclude: globs(exclude),
+                allFiles: allFiles.checked,
+                maxResults: 5000,
+            };
+            try {
+                previewed = { options: request, preview: await previewReplaceInWorkspace(request) };
+            } catch (error) {
+                previewed = null;
+                reportError(error);
+            }
+            renderPreview();
+        };
+
+        const undoButton = this.createPanelButton("Undo Last Replace", () => void undo());
+        const refreshUndo = async () => {
+            undoButton.disabled = !(await canUndoReplaceInWorkspace());
+            undoButton.classList.toggle("opacity-40", undoButton.disabled);
+        };
+
+        const replace = async () => {
+            errorLine.textContent = "";
+            if (!previewed) {
+                errorLine.textContent = "Preview the changes first";
+                return;
+            }
+            const selections = previewed.preview.files
+                .map((file) => ({
+                    path: file.path,
+                    hash: file.hash,
+                    offsets: [...(selected.get(file.path) ?? [])],
+                }))
+                .filter((selection) => selection.offsets.length > 0);
+            if (selections.length === 0) {
+                errorLine.textContent = "No changes selected";
+                return;
+            }
+            // 已打开且未保存的文档会与磁盘内容冲突，先让用户保存
+            const dirty = selections.find(
+                (selection) => this.openDocuments.get(selection.path)?.isDirty,
+            );
+            if (dirty) {
+                errorLine.textContent = `Save ${this.displayNameForPath(dirty.path)} before replacing in it`;
+                return;
+            }
+            try {
+                const result = await replaceInWorkspace(previewed.options, selections);
+                this.flashStatus(`Replaced ${result.changes} matches in ${result.files.length} files`);
+                await runPreview();
+            } catch (error) {
+                reportError(error);
+            }
+            await refreshUndo();
+        };
+
+        const undo = async () => {
+            errorLine.textContent = "";
+            // 后端只在被恢复的文件有未保存修改时拒绝
+            try {
+                const result = await undoReplaceInWorkspace();
+                this.flashStatus(`Restored ${result.files.length} files`);
+                await runPreview();
+            } catch (error) {
+                reportError(error);
+            }
+            await refreshUndo();
+        };
+
+        find.addEventListener("keydown", (event) => {
+            if (event.key === "Enter") {
+                event.preventDefault();
+                void runPreview();
+            }
+        });
+        [find, replacement, include, exclude, regex, caseSensitive, wholeWord, allFiles].forEach((input) => {
+            input.addEventListener("input", () => {
+                if (previewed) {
+                    previewed = null;
+                    renderPreview();
+                    summary.textContent = "Options changed; preview again";
+                }
+            });
+        });
+
+        const selection = this.editorInstance?.cm?.getSelection() ?? "";
+        if (selection && !selection.includes("\n")) {
+            find.value = selection;
+        }
+        footer.append(
+            undoButton,
+            this.createPanelButton("Close", () => this.removeExistingModal()),
+            this.createPanelButton("Preview", () => void runPreview()),
+            this.createPanelButton("Replace", () => void replace(), true),
+        );
+        void refreshUndo();
+        find.focus();
+    }
+
+    // 将预览中的 ```query 代码块替换为后端查询结果表格
+    private renderQueryBlocks() {
+        const container: HTMLElement | undefined =
+            this.editorInstance?.previewContainer?.[0];
+        if (!container) {
+            return;
+        }
+
+        const blocks = container.querySelectorAll<HTMLElement>(
+            "pre > code.lang-query",
+        );
+        blocks.forEach((code) => {
+            const pre = code.parentElement;
+            if (!pre) {
+                return;
+            }
+            const query = (code.textContent ?? "").trim();
+            const host = document.createElement("div");
+            host.className = "markdowndaonote-query";
+            host.textContent = "Running query…";
+            pre.replaceWith(host);
+
+            let pending = this.queryResultCache.get(query);
+            if (!pending) {
+                pending = runQuery(query);
+                this.queryResultCache.set(query, pending);
+            }
+            pending
+                .then((result) => this.renderQueryTable(host, result))
+                .catch((error) => {
+                    this.queryResultCache.delete(query);
+                    host.textContent = `Query error: ${error}`;
+                    host.classList.add("markdowndaonote-query-error");
+                });
+        });
+    }
+
+    private renderQueryTable(host: HTMLElement, result: QueryResult) {
+        host.textContent = "";
+        if (result.rows.length === 0) {
+            host.textContent = "No results";
+            return;
+        }
+
+        const table = document.createElement("table");
+        const headRow = table.createTHead().insertRow();
+        result.columns.forEach((column) => {
+            const th = document.createElement("th");
+            th.textContent = column;
+            headRow.appendChild(th);
+        });
+
+        const body = table.createTBody();
+        result.rows.forEach((row) => {
+            const tr = body.insertRow();
+            row.values.forEach((value, index) => {
+                const cell = tr.insertCell();
+                const text = Array.isArray(value)
+                    ? value.join(", ")
+                    : String(value ?? "");
+                if (index === 0) {
+                    const link = document.createElement("a");
+                    link.href = "#";
+                    link.textContent = text;
+                    link.addEventListener("click", (event) => {
+                        event.preventDefault();
+                        void this.openFile(row.path);
+                    });
+                    cell.appendChild(link);
+                } else {
+                    cell.textContent = text;
+                }
+            });
+        });
+        host.appendChild(table);
+    }
+
+    // 将光标移动到 1 起始的行列位置并滚动到可见区域
+    private revealPosition(line: number, column: number) {
+        const cm = this.editorInstance?.cm;
+        if (!cm) {
+            return;
+        }
+        const position = {
+            line: Math.max(0, line - 1),
+            ch: Math.max(0, column - 1),
+        };
+        cm.setCursor(position);
+        cm.scrollIntoView(position, 120);
+        cm.focus();
+    }
+
+    private updateDocumentAfterSave(
+        previousPath: string | null,
+        savedPath: string,
+    ) {
+        const normalized = this.normalizePath(savedPath);
+        if (!normalized) {
+            return;
+        }
+
+        const previous = this.normalizePath(previousPath) || normalized;
+        let doc =
+            this.openDocuments.get(previous) ??
+            this.openDocuments.get(normalized);
+        if (!doc) {
+            doc = this.upsertDocument(normalized, this.getMarkdown());
+        }
+
+        const oldPath = doc.path;
+        if (!doc.currentContent) {
+            doc.currentContent = this.getMarkdown();
+        }
+        doc.savedContent = doc.currentContent;
+        doc.isDirty = false;
+
+        if (normalized !== oldPath) {
+            this.openDocuments.delete(oldPath);
+            doc.path = normalized;
+            doc.name = this.displayNameForPath(normalized);
+            this.openDocuments.set(normalized, doc);
+            this.tabOrder = this.tabOrder.map((p) =>
+                p === oldPath ? normalized : p,
+            );
+        }
+
+        if (!this.tabOrder.includes(normalized)) {
+            this.tabOrder.push(normalized);
+        }
+
+        this.renderTabs();
+    }
+
+    private upsertDocument(path: string, content: string): OpenDocument {
+        const normalized = this.normalizePath(path);
+        const resolvedContent = content ?? "";
+        if (!normalized) {
+            const fallbackPath = `untitled-${Date.now()}`;
+            const fallback: OpenDocument = {
+                path: fallbackPath,
+                name: "Untitled",
+                savedContent: resolvedContent,
+                currentContent: resolvedContent,
+                isDirty: false,
+            };
+            this.openDocuments.set(fallbackPath, fallback);
+            if (!this.tabOrder.includes(fallbackPath)) {
+                this.tabOrder.push(fallbackPath);
+            }
+            return fallback;
+        }
+
+        let doc = this.openDocuments.get(normalized);
+        if (!doc) {
+            doc = {
+                path: normalized,
+                name: this.displayNameForPath(normalized),
+                savedContent: resolvedContent,
+                currentContent: resolvedContent,
+                isDirty: false,
+            };
+            this.openDocuments.set(normalized, doc);
+        } else {
+            doc.savedContent = resolvedContent;
+            doc.currentContent = resolvedContent;
+            doc.isDirty = false;
+        }
+
+        if (!this.tabOrder.includes(normalized)) {
+            this.tabOrder.push(normalized);
+        }
+
+        return doc;
+    }
+
+    private displayNameForPath(path: string): string {
+        const normalized = this.normalizePath(path);
+        if (!normalized) {
+            return "Untitled";
+        }
+        const lastSlash = Math.max(
+            normalized.lastIndexOf("/"),
+            normalized.lastIndexOf("\\"),
+        );
+        if (lastSlash >= 0 && lastSlash < normalized.length - 1) {
+            return normalized.slice(lastSlash + 1);
+        }
+        return normalized;
+    }
+
+    private normalizePath(input: string | null | undefined): string {
+        if (typeof input !== "string") {
+            return "";
+        }
+        return input.trim();
+    }
+
+    private closeDocument(path: string) {
+        const normalized = this.normalizePath(path);
+        if (!normalized) {
+            return;
+        }
+
+        const doc = this.openDocuments.get(normalized);
+        if (!doc) {
+            return;
+        }
+
+        const wasActive = normalized === this.currentFilePath;
+        const index = this.tabOrder.indexOf(normalized);
+
+        this.openDocuments.delete(normalized);
+        if (index >= 0) {
+            this.tabOrder.splice(index, 1);
+        }
+        void notifyDocumentClosed(normalized);
+
+        const label = doc.name || normalized || "Untitled";
+
+        if (wasActive) {
+            const fallbackPath =
+                this.tabOrder[index] ??
+                this.tabOrder[index - 1] ??
+                (this.tabOrder.length ? this.tabOrder[0] : "");
+
+            if (fallbackPath) {
+                const fallbackDoc = this.openDocuments.get(fallbackPath);
+                this.reconfigureEditorForFileType(fallbackPath);
+                this.setCurrentFile(fallbackPath);
+                this.applyMarkdownContent(fallbackDoc?.currentContent ?? "");
+            } else {
+                this.setCurrentFile(null);
+                this.applyMarkdownContent(DEFAULT_WELCOME_MARKDOWN);
+            }
+        } else {
+            this.renderTabs();
+        }
+
+        this.flashStatus(`Closed: ${label}`);
+    }
+
+    private renderMenuBar() {
+        if (!this.menuElement) {
+            return;
+        }
+
+        this.menuElement.innerHTML = "";
+        if (this.activeDropdown) {
+            this.activeDropdown.remove();
+            this.activeDropdown = null;
+        }
+
+        const menus: Array<{
+            id: string;
+            label: string;
+            builder: (dropdown: HTMLDivElement) => void;
+        }> = [
+            {
+                id: "file",
+                label: "File",
+                builder: (dropdown) => this.buildFileMenu(dropdown),
+            },
+            ...(this.currentFolderPath
+                ? [
+                      {
+                          id: "journal",
+                          label: "Journal",
+                          builder: (dropdown: HTMLDivElement) =>
+                              this.buildJournalMenu(dropdown),
+                      },
+                  ]
+                : []),
+            {
+                id: "theme",
+                label: "Theme",
+                builder: (dropdown) => this.buildThemeMenu(dropdown),
+            },
+            ...(this.gitStatus
+                ? [
+                      {
+                          id: "git",
+                          label: "Git",
+                          builder: (dropdown: HTMLDivElement) =>
+                              this.buildGitMenu(dropdown),
+                      },
+                  ]
+                : []),
+            {
+                id: "help",
+                label: "Help",
+                builder: (dropdown) => this.buildHelpMenu(dropdown),
+            },
+        ];
+
+        menus.forEach((menu) => {
+            const wrapper = document.createElement("div");
+            wrapper.className = "relative";
+
+            const button = document.createElement("button");
+            button.type = "button";
+            button.textContent = menu.label;
+            button.className =
+                "px-2 py-1 rounded hover:bg-white/10 focus:outline-none";
+            button.addEventListener("click", (event) => {
+                event.stopPropagation();
+                if (this.openMenuId === menu.id) {
+                    this.closeMenu();
+                } else {
+                    this.openMenuId = menu.id;
+                    this.renderMenuBar();
+                }
+            });
+
+            wrapper.appendChild(button);
+
+            if (this.openMenuId === menu.id) {
+                const dropdown = document.createElement("div");
+                dropdown.className = "no-drag dropdown-menu";
+                dropdown.style.position = "fixed";
+                dropdown.style.minWidth = "12rem";
+                dropdown.style.maxWidth = "20rem";
+                dropdown.style.maxHeight = "20rem"; // 设置最大高度
+                dropdown.style.overflowY = "auto"; // 超出时显示滚动条
+                dropdown.style.background = "rgba(15, 15, 20, 0.95)";
+                dropdown.style.border = "1px solid rgba(255, 255, 255, 0.1)";
+                dropdown.style.borderRadius = "0.375rem";
+                dropdown.style.boxShadow = "0 10px 24px rgba(0, 0, 0, 0.35)";
+                dropdown.style.padding = "0.25rem 0";
+                dropdown.style.zIndex = "2000";
+
+                // 禁用下拉菜单的右键菜单
+                dropdown.addEventListener("contextmenu", (event) => {
+                    event.preventDefault();
+                    event.stopPropagation();
+                });
+
+                menu.builder(dropdown);
+
+                // 确保在计算位置前，wrapper 已经在 DOM 中
+                this.menuElement.appendChild(wrapper);
+
+                const rect = button.getBoundingClientRect();
+
+                // 直接使用 button 的位置，但确保计算正确
+                dropdown.style.top = `${rect.bottom + window.scrollY + 6}px`;
+                dropdown.style.left = `${rect.left + window.scrollX}px`;
+
+                console.log("Menu positioning debug:", {
+                    buttonRect: rect,
+                    scrollX: window.scrollX,
+                    scrollY: window.scrollY,
+                    dropdownTop: dropdown.style.top,
+                    dropdownLeft: dropdown.style.left,
+                    buttonBottom: rect.bottom,
+                    buttonLeft: rect.left,
+                });
+
+                document.body.appendChild(dropdown);
+                this.activeDropdown = dropdown;
+            } else {
+                this.menuElement.appendChild(wrapper);
+            }
+        });
+    }
+
+    private buildFileMenu(dropdown: HTMLDivElement) {
+        this.createMenuItem(dropdown, "New from Template…", () =>
+            this.showTemplateDialog(""),
+        );
+        this.createMenuItem(dropdown, "Open…", () =>
+            this.handleOpenFileDialog(),
+        );
+        this.createMenuItem(dropdown, "Open Folder…", () =>
+            this.handleOpenFolderDialog(),
+        );
+        this.createMenuSeparator(dropdown);
+        this.createMenuItem(dropdown, "Save", () =>
+            this.handleSaveRequested(false),
+        );
+        this.createMenuItem(dropdown, "Save As…", () =>
+            this.handleSaveRequested(true),
+        );
+        this.createMenuItem(dropdown, "Fix Lint Problems", () =>
+            this.handleFixLintProblems(),
+        );
+        this.createMenuItem(dropdown, "Format Document", () =>
+            this.handleFormatDocument(false),
+        );
+        this.createMenuItem(dropdown, "Format Selection", () =>
+            this.handleFormatDocument(true),
+        );
+        this.createMenuItem(
+            dropdown,
+            "Format on Save",
+            () => this.toggleFormatOnSave(),
+            { selected: Boolean(this.currentSettings?.formatOnSave) },
+        );
+        this.createMenuItem(dropdown, "Insert/Update Table of Contents", () =>
+            this.handleUpdateTOC(),
+        );
+        this.createMenuItem(
+            dropdown,
+            "Update TOC on Save",
+            () => this.toggleTOCOnSave(),
+            { selected: Boolean(this.currentSettings?.tocOnSave) },
+        );
+        this.createMenuItem(dropdown, "Outline…", () =>
+            this.showOutlineDialog(),
+        );
+        this.createMenuItem(dropdown, "Split Note…", () =>
+            this.showSplitNoteDialog(),
+        );
+        if (this.currentFolderPath) {
+            this.createMenuItem(dropdown, "Merge Notes…", () => {
+                const folder = this.currentFilePath
+                    ? this.currentFilePath.replace(/[\\/][^\\/]*$/, "")
+                    : this.currentFolderPath;
+                void this.showMergeNotesDialog(folder || this.currentFolderPath || "");
+            });
+            this.createMenuItem(dropdown, "Batch Rename…", () => {
+                const folder = this.currentFilePath
+                    ? this.currentFilePath.replace(/[\\/][^\\/]*$/, "")
+                    : this.currentFolderPath;
+                this.showBatchRenameDialog(folder || this.currentFolderPath || "");
+            });
+            this.createMenuItem(dropdown, "Replace in Workspace…", () =>
+                this.showReplaceInWorkspaceDialog(),
+            );
+        }
+        this.createMenuSeparator(dropdown);
+        this.createMenuItem(
+            dropdown,
+            "Check Spelling",
+            () => this.toggleSpellcheck(),
+            { selected: this.currentSettings?.spellcheck?.enabled !== false },
+        );
+        this.createMenuItem(dropdown, "Spelling Settings…", () =>
+            this.showSpellcheckSettingsDialog(),
+        );
+        this.createMenuItem(dropdown, "Statistics…", () =>
+            this.showStatisticsDialog(),
+        );
+        this.createMenuItem(dropdown, "Lock Encrypted Notes", () =>
+            this.handleLockAllNotes(),
+        );
+        if (this.currentFolderPath) {
+            this.createMenuSeparator(dropdown);
+            this.createMenuItem(dropdown, "Sync Now", () =>
+                this.handleSyncNow(),
+            );
+            this.createMenuItem(dropdown, "Sync Settings…", () =>
+                this.showSyncSettingsDialog(),
+            );
+            this.createMenuItem(dropdown, "Back Up Now", () =>
+                this.handleBackupNow(),
+            );
+            this.createMenuItem(dropdown, "Backups…", () =>
+                this.showBackupDialog(),
+            );
+        }
+    }
+
+    private buildJournalMenu(dropdown: HTMLDivElement) {
+        this.createMenuItem(dropdown, "Today's Note", () =>
+            this.openPeriodic("daily"),
+        );
+        this.createMenuItem(dropdown, "This Week's Note", () =>
+            this.openPeriodic("weekly"),
+        );
+        this.createMenuItem(dropdown, "This Month's Note", () =>
+            this.openPeriodic("monthly"),
+        );
+        this.createMenuItem(dropdown, "This Year's Note", () =>
+            this.openPeriodic("yearly"),
+        );
+        this.createMenuSeparator(dropdown);
+        this.createMenuItem(dropdown, "Previous Periodic Note", () =>
+            this.navigatePeriodic(-1),
+        );
+        this.createMenuItem(dropdown, "Next Periodic Note", () =>
+            this.navigatePeriodic(1),
+        );
+        this.createMenuSeparator(dropdown);
+        this.createMenuItem(dropdown, "Calendar…", () =>
+            this.showPeriodicCalendar(),
+        );
+        this.createMenuItem(dropdown, "Periodic Notes Settings…", () =>
+            this.showPeriodicSettingsDialog(),
+        );
+    }
+
+    private buildThemeMenu(dropdown: HTMLDivElement) {
+        const sectionHeader = (title: string) => {
+            const header = document.createElement("div");
+            header.textContent = title;
+            header.style.fontSize = "11px";
+            header.style.opacity = "0.6";
+            header.style.textTransform = "uppercase";
+            header.style.letterSpacing = "0.08em";
+            header.style.padding = "6px 12px 2px";
+            dropdown.appendChild(header);
+        };
+
+        sectionHeader("Toolbar Theme");
+        TOOLBAR_THEME_OPTIONS.forEach((option) =>
+            this.createMenuItem(
+                dropdown,
+                this.displayNameForTheme(option),
+                () => this.applyToolbarTheme(option, true),
+                { selected: this.currentSettings?.theme === option },
+            ),
+        );
+
+        this.createMenuSeparator(dropdown);
+        sectionHeader("Editor Theme");
+        EDITOR_THEME_OPTIONS.forEach((option) =>
+            this.createMenuItem(
+                dropdown,
+                this.displayNameForTheme(option),
+                () => this.applyEditorTheme(option, true),
+                { selected: this.currentSettings?.editorTheme === option },
+            ),
+        );
+
+        this.createMenuSeparator(dropdown);
+        sectionHeader("Preview Theme");
+        PREVIEW_THEME_OPTIONS.forEach((option) =>
+            this.createMenuItem(
+                dropdown,
+                this.displayNameForTheme(option),
+                () => this.applyPreviewTheme(option, true),
+                { selected: this.currentSettings?.previewTheme === option },
+            ),
+        );
+    }
+
+    private buildGitMenu(dropdown: HTMLDivElement) {
+        this.createMenuItem(dropdown, "Commit…", () =>
+            this.showGitCommitDialog(),
+        );
+        this.createMenuItem(dropdown, "Quick Sync", () =>
+            this.handleGitQuickSync(),
+        );
+        if (this.activeFilePath) {
+            const path = this.activeFilePath;
+            this.createMenuSeparator(dropdown);
+            this.createMenuItem(dropdown, "Diff with HEAD", () =>
+                this.showGitDiff(path),
+            );
+            this.createMenuItem(dropdown, "File History", () =>
+                this.showGitHistory(path),
+            );
+        }
+        this.createMenuSeparator(dropdown);
+        this.createMenuItem(dropdown, "Refresh Status", () => {
+            void refreshGitStatus();
+        });
+    }
+
+    private buildHelpMenu(dropdown: HTMLDivElement) {
+        this.createMenuItem(dropdown, "About MarkdownPad…", () =>
+            this.handleShowAbout(),
+        );
+    }
+
+    private createMenuItem(
+        dropdown: HTMLDivElement,
+        label: string,
+        action: () => void | Promise<void>,
+        options?: { selected?: boolean; shortcut?: string },
+    ) {
+        const item = document.createElement("button");
+        item.type = "button";
+        item.className =
+            "w-full px-3 py-1 text-left text-sm text-white/85 hover:bg-white/10 flex items-center gap-2";
+        item.addEventListener("click", (event) => {
+            event.stopPropagation();
+            const result = action();
+            this.closeMenu();
+            if (result instanceof Promise) {
+                result.catch((error) =>
+                    console.warn("menu action failed", error),
+                );
+            }
+        });
+
+        const marker = document.createElement("span");
+        marker.textContent = options?.selected ? "✓" : "";
+        marker.style.width = "1rem";
+
+        const labelSpan = document.createElement("span");
+        labelSpan.textContent = label;
+        labelSpan.style.flex = "1";
+
+        item.append(marker, labelSpan);
+
+        if (options?.shortcut) {
+            const shortcut = document.createElement("span");
+            shortcut.textContent = options.shortcut;
+            shortcut.style.opacity = "0.6";
+            item.append(shortcut);
+        }
+
+        dropdown.appendChild(item);
+    }
+
+    private createMenuSeparator(dropdown: HTMLDivElement) {
+        const separator = document.createElement("div");
+        separator.style.margin = "4px 0";
+        separator.style.borderTop = "1px solid rgba(255, 255, 255, 0.07)";
+        dropdown.appendChild(separator);
+    }
+
+    private closeMenu() {
+        if (this.openMenuId === null) {
+            return;
+        }
+        this.openMenuId = null;
+        this.renderMenuBar();
+    }
+
+    private displayNameForTheme(theme: string): string {
+        return theme
+            .split("-")
+            .map((part) =>
+                part.length ? part[0].toUpperCase() + part.slice(1) : part,
+            )
+            .join(" ");
+    }
+
+    private handleOpenFileDialog() {
+        openFileDialog().catch((error) =>
+            console.warn("Open file dialog failed", error),
+        );
+    }
+
+    private handleOpenFolderDialog() {
+        openFolderDialog().catch((error) =>
+            console.warn("Open folder dialog failed", error),
+        );
+    }
+
+    private handleShowAbout() {
+        this.showAboutDialog();
+    }
+
+    /**
+     * 显示关于对话框，包含GitHub超链接
+     */
+    private showAboutDialog(): void {
+        // 移除现有的模态框
+        this.removeExistingModal();
+
+        // 创建遮罩层
+        const overlay = document.createElement("div");
+        overlay.className =
+            "fixed inset-0 bg-black/50 backdrop-blur-sm z-50 flex items-center justify-center";
+        overlay.id = "modal-overlay";
+        overlay.style.zIndex = "99999";
+
+        // 创建模态框容器
+        const modal = document.createElement("div");
+        modal.className =
+            "bg-gray-800 border border-gray-600 rounded-lg shadow-2xl max-w-md w-full mx-4 transform transition-all duration-300 scale-100";
+        modal.style.minWidth = "320px";
+        modal.style.maxWidth = "500px";
+        modal.style.borderLeftColor = "#3b82f6";
+        modal.style.borderLeftWidth = "4px";
+
+        // 创建标题栏
+        const header = document.createElement("div");
+        header.className =
+            "flex items-center gap-3 px-6 py-4 border-b border-gray-700";
+
+        const icon = document.createElement("img");
+        icon.src = APP_ICON;
+        icon.alt = "App Icon";
+        icon.className = "w-8 h-8";
+
+        const titleElement = document.createElement("h3");
+        titleElement.textContent = `About ${APP_NAME}`;
+        titleElement.className = "text-lg font-semibold text-white flex-1";
+
+        const closeButton = document.createElement("button");
+        closeButton.innerHTML = "×";
+        closeButton.className =
+            "text-gray-400 hover:text-white text-2xl font-bold w-8 h-8 flex items-center justify-center rounded hover:bg-gray-700 transition-colors";
+        closeButton.addEventListener("click", () => this.removeExistingModal());
+
+        header.appendChild(icon);
+        header.appendChild(titleElement);
+        header.appendChild(closeButton);
+
+        // 创建内容区域
+        const content = document.createElement("div");
+        content.className = "px-6 py-4";
+
+        // 应用信息
+        const appInfo = document.createElement("div");
+        appInfo.className = "text-gray-200 leading-relaxed space-y-2";
+        
+        const appName = document.createElement("div");
+        appName.textContent = APP_NAME;
+        appName.className = "text-xl font-semibold text-white mb-3";
+        
+        const version = document.createElement("div");
+        version.textContent = `Version: ${APP_VERSION}`;
+        version.className = "text-sm text-gray-300";
+        
+        const author = document.createElement("div");
+        author.textContent = `Author: ${APP_AUTHOR}`;
+        author.className = "text-sm text-gray-300";
+        
+        // GitHub 链接
+        const githubContainer = document.createElement("div");
+        githubContainer.className = "mt-4";
+        
+        const githubLabel = document.createElement("div");
+        githubLabel.textContent = "GitHub: ";
+        githubLabel.className = "text-sm text-gray-300 inline";
+        
+        const githubLink = document.createElement("a");
+        githubLink.textContent = APP_GITHUB;
+        githubLink.href = APP_GITHUB;
+        githubLink.target = "_blank";
+        githubLink.className = "text-blue-400 hover:text-blue-300 underline text-sm transition-colors";
+        githubLink.addEventListener("click", (e) => {
+            e.preventDefault();
+            // 使用 Wails 的 BrowserOpenURL 方法打开链接
+            if (window.go && window.go.main && window.go.main.App) {
+                window.go.main.App.BrowserOpenURL(APP_GITHUB);
+            } else {
+                // 备用方案：使用 window.open
+                window.open(APP_GITHUB, "_blank");
+            }
+        });
+        
+        githubContainer.appendChild(githubLabel);
+        githubContainer.appendChild(githubLink);
+        
+        appInfo.appendChild(appName);
+        appInfo.appendChild(version);
+        appInfo.appendChild(author);
+        appInfo.appendChild(githubContainer);
+        
+        content.appendChild(appInfo);
+
+        // 创建按钮区域
+        const footer = document.createElement("div");
+        footer.className =
+            "flex justify-end gap-3 px-6 py-4 border-t border-gray-700";
+
+        const okButton = document.createElement("button");
+        okButton.textContent = "OK";
+        okButton.className = "px-4 py-2 rounded font-medium transition-colors bg-blue-600 hover:bg-blue-700 text-white";
+        okButton.addEventListener("click", () => this.removeExistingModal());
+
+        footer.appendChild(okButton);
+
+        // 组装模态框
+        modal.appendChild(header);
+        modal.appendChild(content);
+        modal.appendChild(footer);
+        overlay.appendChild(modal);
+
+        // 添加到页面
+        document.body.appendChild(overlay);
+
+        // 添加动画效果
+        setTimeout(() => {
+            modal.style.transform = "scale(1)";
+        }, 10);
+
+        // 点击遮罩层关闭
+        overlay.addEventListener("click", (e) => {
+            if (e.target === overlay) {
+                this.removeExistingModal();
+            }
+        });
+
+        // ESC键关闭
+        const handleKeyDown = (e: KeyboardEvent) => {
+            if (e.key === "Escape") {
+                this.removeExistingModal();
+                document.removeEventListener("keydown", handleKeyDown);
+            }
+        };
+        document.addEventListener("keydown", handleKeyDown);
+    }
+
+    private renderSidebar() {
+        if (!this.sidebarElement) {
+            return;
+        }
+
+        this.sidebarElement.innerHTML = "";
+
+        if (!this.sidebarTreeRoot) {
+            // 创建空状态容器
+            const emptyState = document.createElement("div");
+            emptyState.className = "flex flex-col items-center justify-center h-full px-4 py-8 text-center";
+            
+            // 创建logo区域
+            const logoSection = document.createElement("div");
+            logoSection.className = "mb-6";
+            
+            // 创建logo图标（使用SVG）
+            const logoIcon = document.createElement("div");
+            logoIcon.className = "w-12 h-12 mx-auto mb-3";
+            logoIcon.innerHTML = `
+                <svg viewBox="0 0 24 24" fill="none" xmlns="http://www.w3.org/2000/svg" class="w-full h-full text-blue-400">
+                    <path d="M3 7V5C3 3.89543 3.89543 3 5 3H9L11 5H19C20.1046 5 21 5.89543 21 7V19C21 20.1046 20.1046 21 19 21H5C3.89543 21 3 20.1046 3 19V7Z" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
+                    <path d="M8 11H16M8 15H12" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
+                </svg>
+            `;
+            
+            // 创建应用名称
+            const appName = document.createElement("div");
+            appName.className = "text-lg font-semibold text-white mb-1";
+            appName.textContent = APP_NAME;
+            
+            // 创建版本信息
+            const version = document.createElement("div");
+            version.className = "text-xs text-white/60 mb-4";
+            version.textContent = `Version: ${APP_VERSION}`;
+            
+            logoSection.appendChild(logoIcon);
+            logoSection.appendChild(appName);
+            logoSection.appendChild(version);
+            
+            // 创建打开文件夹按钮
+            const openButton = document.createElement("button");
+            openButton.className = "flex items-center gap-3 px-4 py-3 bg-blue-600 hover:bg-blue-700 text-white rounded-lg transition-colors duration-200 font-medium text-sm";
+            openButton.innerHTML = `
+                <svg viewBox="0 0 24 24" fill="none" xmlns="http://www.w3.org/2000/svg" class="w-5 h-5">
+                    <path d="M3 7V5C3 3.89543 3.89543 3 5 3H9L11 5H19C20.1046 5 21 5.89543 21 7V19C21 20.1046 20.1046 21 19 21H5C3.89543 21 3 20.1046 3 19V7Z" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
+                    <path d="M8 11H16M8 15H12" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
+                </svg>
+                Open Folder
+            `;
+            
+            // 添加点击事件
+            openButton.addEventListener("click", () => {
+                this.handleOpenFolderDialog();
+            });
+            
+            // 创建提示文本
+            const hintText = document.createElement("div");
+            hintText.className = "text-xs text-white/50 mt-4";
+            hintText.textContent = "Select a folder to browse your files";
+            
+            // 组装空状态
+            emptyState.appendChild(logoSection);
+            emptyState.appendChild(openButton);
+            emptyState.appendChild(hintText);
+            
+            // 为整个空状态添加右键菜单支持
+            emptyState.addEventListener("contextmenu", (event) => {
+                event.preventDefault();
                 event.stopPropagation();
-                if (this.openMenuId === menu.id) {
-                    this.closeMenu();
-                } else {
-                    this.openMenuId = menu.id;
-                    this.renderMenuBar();
-                }
+                this.showContextMenu(event, null);
             });
 
-            wrapper.appendChild(button);
+            this.sidebarElement.appendChild(emptyState);
+            return;
+        }
 
-            if (this.openMenuId === menu.id) {
-                const dropdown = document.createElement("div");
-                dropdown.className = "no-drag dropdown-menu";
-                dropdown.style.position = "fixed";
-                dropdown.style.minWidth = "12rem";
-                dropdown.style.maxWidth = "20rem";
-                dropdown.style.maxHeight = "20rem"; // 设置最大高度
-                dropdown.style.overflowY = "auto"; // 超出时显示滚动条
-                dropdown.style.background = "rgba(15, 15, 20, 0.95)";
-                dropdown.style.border = "1px solid rgba(255, 255, 255, 0.1)";
-                dropdown.style.borderRadius = "0.375rem";
-                dropdown.style.boxShadow = "0 10px 24px rgba(0, 0, 0, 0.35)";
-                dropdown.style.padding = "0.25rem 0";
-                dropdown.style.zIndex = "2000";
+        const container = document.createElement("div");
+        container.className = "py-2 text-sm";
 
-                // 禁用下拉菜单的右键菜单
-                dropdown.addEventListener("contextmenu", (event) => {
-                    event.preventDefault();
-                    event.stopPropagation();
-                });
+        if (this.gitStatus?.branch) {
+            container.appendChild(this.renderGitBranch(this.gitStatus));
+        }
+        this.renderTreeNode(this.sidebarTreeRoot, 0, container);
+        this.sidebarElement.appendChild(container);
+    }
 
-                menu.builder(dropdown);
+    private renderGitBranch(status: GitStatus): HTMLElement {
+        const row = document.createElement("div");
+        row.className =
+            "flex items-center gap-2 px-3 pb-2 text-xs text-white/60 cursor-pointer select-none";
+        row.title = status.upstream
+            ? `${status.branch} → ${status.upstream} (click to refresh)`
+            : `${status.branch} (click to refresh)`;
 
-                // 确保在计算位置前，wrapper 已经在 DOM 中
-                this.menuElement.appendChild(wrapper);
+        let text = `⎇ ${status.branch}`;
+        if (status.ahead > 0) {
+            text += ` ↑${status.ahead}`;
+        }
+        if (status.behind > 0) {
+            text += ` ↓${status.behind}`;
+        }
+        row.textContent = text;
+        row.addEventListener("click", () => {
+            void refreshGitStatus();
+        });
+        return row;
+    }
 
-                const rect = button.getBoundingClientRect();
+    private async runGitAction(
+        action: () => Promise<unknown>,
+        message: string,
+    ): Promise<boolean> {
+        try {
+            await action();
+            this.showStatus(message, "success");
+            return true;
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Git",
+                "error",
+            );
+            return false;
+        }
+    }
 
-                // 直接使用 button 的位置，但确保计算正确
-                dropdown.style.top = `${rect.bottom + window.scrollY + 6}px`;
-                dropdown.style.left = `${rect.left + window.scrollX}px`;
+    /**
+     * 创建 Git、同步等面板使用的大尺寸模态框
+     */
+    private createPanelModal(title: string): {
+        body: HTMLDivElement;
+        footer: HTMLDivElement;
+    } {
+        this.removeExistingModal();
 
-                console.log("Menu positioning debug:", {
-                    buttonRect: rect,
-                    scrollX: window.scrollX,
-                    scrollY: window.scrollY,
-                    dropdownTop: dropdown.style.top,
-                    dropdownLeft: dropdown.style.left,
-                    buttonBottom: rect.bottom,
-                    buttonLeft: rect.left,
-                });
+        const overlay = document.createElement("div");
+        overlay.className =
+            "fixed inset-0 bg-black/50 backdrop-blur-sm flex items-center justify-center";
+        overlay.id = "modal-overlay";
+        overlay.style.zIndex = "99999";
 
-                document.body.appendChild(dropdown);
-                this.activeDropdown = dropdown;
-            } else {
-                this.menuElement.appendChild(wrapper);
+        const modal = document.createElement("div");
+        modal.className =
+            "panel-modal bg-gray-800 border border-gray-600 rounded-lg shadow-2xl flex flex-col mx-4";
+
+        const header = document.createElement("div");
+        header.className =
+            "flex items-center gap-3 px-5 py-3 border-b border-gray-700";
+        const titleElement = document.createElement("h3");
+        titleElement.textContent = title;
+        titleElement.className =
+            "text-base font-semibold text-white flex-1 truncate";
+        const closeButton = document.createElement("button");
+        closeButton.innerHTML = "×";
+        closeButton.className =
+            "text-gray-400 hover:text-white text-2xl font-bold w-8 h-8 flex items-center justify-center rounded hover:bg-gray-700 transition-colors";
+        closeButton.addEventListener("click", () => this.removeExistingModal());
+        header.append(titleElement, closeButton);
+
+        const body = document.createElement("div");
+        body.className = "panel-modal-body px-5 py-3";
+
+        const footer = document.createElement("div");
+        footer.className =
+            "flex items-center justify-end gap-3 px-5 py-3 border-t border-gray-700";
+
+        modal.append(header, body, footer);
+        overlay.appendChild(modal);
+        document.body.appendChild(overlay);
+
+        overlay.addEventListener("click", (e) => {
+            if (e.target === overlay) {
+                this.removeExistingModal();
             }
         });
+        const handleKeyDown = (e: KeyboardEvent) => {
+            if (e.key === "Escape") {
+                this.removeExistingModal();
+                document.removeEventListener("keydown", handleKeyDown);
+            }
+        };
+        document.addEventListener("keydown", handleKeyDown);
+
+        return { body, footer };
     }
 
-    private buildFileMenu(dropdown: HTMLDivElement) {
-        this.createMenuItem(dropdown, "Open…", () =>
-            this.handleOpenFileDialog(),
-        );
-        this.createMenuItem(dropdown, "Open Folder…", () =>
-            this.handleOpenFolderDialog(),
-        );
-        this.createMenuSeparator(dropdown);
-        this.createMenuItem(dropdown, "Save", () =>
-            this.handleSaveRequested(false),
-        );
-        this.createMenuItem(dropdown, "Save As…", () =>
-            this.handleSaveRequested(true),
-        );
+    private createPanelButton(
+        label: string,
+        onClick: () => void,
+        primary = false,
+    ): HTMLButtonElement {
+        const button = document.createElement("button");
+        button.type = "button";
+        button.textContent = label;
+        button.className = primary
+            ? "px-4 py-1.5 rounded text-sm font-medium bg-blue-600 hover:bg-blue-700 text-white"
+            : "px-4 py-1.5 rounded text-sm text-white/80 hover:bg-white/10";
+        button.addEventListener("click", onClick);
+        return button;
     }
 
-    private buildThemeMenu(dropdown: HTMLDivElement) {
-        const sectionHeader = (title: string) => {
-            const header = document.createElement("div");
-            header.textContent = title;
-            header.style.fontSize = "11px";
-            header.style.opacity = "0.6";
-            header.style.textTransform = "uppercase";
-            header.style.letterSpacing = "0.08em";
-            header.style.padding = "6px 12px 2px";
-            dropdown.appendChild(header);
-        };
+    private gitRelativePath(path: string): string {
+        const root = this.gitStatus?.root;
+        if (root && path.startsWith(root)) {
+            return path.slice(root.length).replace(/^[\\/]+/, "");
+        }
+        return path;
+    }
 
-        sectionHeader("Toolbar Theme");
-        TOOLBAR_THEME_OPTIONS.forEach((option) =>
-            this.createMenuItem(
-                dropdown,
-                this.displayNameForTheme(option),
-                () => this.applyToolbarTheme(option, true),
-                { selected: this.currentSettings?.theme === option },
-            ),
-        );
+    private showGitCommitDialog() {
+        const status = this.gitStatus;
+        if (!status) {
+            return;
+        }
 
-        this.createMenuSeparator(dropdown);
-        sectionHeader("Editor Theme");
-        EDITOR_THEME_OPTIONS.forEach((option) =>
-            this.createMenuItem(
-                dropdown,
-                this.displayNameForTheme(option),
-                () => this.applyEditorTheme(option, true),
-                { selected: this.currentSettings?.editorTheme === option },
-            ),
+        const { body, footer } = this.createPanelModal(
+            `Commit on ${status.branch || "HEAD"}`,
         );
 
-        this.createMenuSeparator(dropdown);
-        sectionHeader("Preview Theme");
-        PREVIEW_THEME_OPTIONS.forEach((option) =>
-            this.createMenuItem(
-                dropdown,
-                this.displayNameForTheme(option),
-                () => this.applyPreviewTheme(option, true),
-                { selected: this.currentSettings?.previewTheme === option },
-            ),
-        );
-    }
+        const changes = Object.entries(status.files)
+            .filter(([, state]) => state !== "ignored")
+            .sort(([a], [b]) => a.localeCompare(b));
+        const list = document.createElement("div");
+        list.className = "git-change-list text-sm mb-3";
+        if (changes.length === 0) {
+            list.textContent = "No changes.";
+            list.classList.add("text-white/50");
+        }
+        changes.forEach(([file, state]) => {
+            const row = document.createElement("div");
+            row.className = "flex items-center gap-2 py-0.5 text-white/80";
+            const badge = document.createElement("span");
+            badge.className = `git-status-badge git-status-${state}`;
+            badge.textContent = GIT_STATUS_BADGES[state] ?? "";
+            const name = document.createElement("span");
+            name.className = "truncate";
+            name.textContent = file;
+            row.append(badge, name);
+            list.appendChild(row);
+        });
 
-    private buildHelpMenu(dropdown: HTMLDivElement) {
-        this.createMenuItem(dropdown, "About MarkdownPad…", () =>
-            this.handleShowAbout(),
-        );
-    }
+        const message = document.createElement("textarea");
+        message.className =
+            "w-full h-24 p-2 rounded bg-gray-900 border border-gray-600 text-sm text-white focus:outline-none focus:border-blue-500";
+        message.placeholder = "Commit message";
+
+        const stageAllLabel = document.createElement("label");
+        stageAllLabel.className =
+            "flex items-center gap-2 mt-2 text-sm text-white/70";
+        const stageAll = document.createElement("input");
+        stageAll.type = "checkbox";
+        stageAll.checked = true;
+        stageAllLabel.append(stageAll, "Stage all changes before committing");
+
+        body.append(list, message, stageAllLabel);
+
+        const commit = async (sync: boolean) => {
+            const text = message.value.trim();
+            if (!text) {
+                message.focus();
+                return;
+            }
+            this.removeExistingModal();
+            const committed = await this.runGitAction(async () => {
+                if (stageAll.checked) {
+                    await gitStage([status.root]);
+                }
+                await gitCommit(text);
+            }, "Committed");
+            if (committed && sync) {
+                await this.handleGitQuickSync();
+            }
+        };
 
-    private createMenuItem(
-        dropdown: HTMLDivElement,
-        label: string,
-        action: () => void | Promise<void>,
-        options?: { selected?: boolean; shortcut?: string },
-    ) {
-        const item = document.createElement("button");
-        item.type = "button";
-        item.className =
-            "w-full px-3 py-1 text-left text-sm text-white/85 hover:bg-white/10 flex items-center gap-2";
-        item.addEventListener("click", (event) => {
-            event.stopPropagation();
-            const result = action();
-            this.closeMenu();
-            if (result instanceof Promise) {
-                result.catch((error) =>
-                    console.warn("menu action failed", error),
-                );
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Commit & Sync", () => void commit(true)),
+            this.createPanelButton("Commit", () => void commit(false), true),
+        );
+        message.addEventListener("keydown", (event) => {
+            if (event.key === "Enter" && (event.ctrlKey || event.metaKey)) {
+                event.preventDefault();
+                void commit(false);
             }
         });
+        message.focus();
+    }
 
-        const marker = document.createElement("span");
-        marker.textContent = options?.selected ? "✓" : "";
-        marker.style.width = "1rem";
-
-        const labelSpan = document.createElement("span");
-        labelSpan.textContent = label;
-        labelSpan.style.flex = "1";
-
-        item.append(marker, labelSpan);
+    private async handleGitQuickSync() {
+        this.showStatus("Syncing…");
+        try {
+            const result = await gitQuickSync();
+            const parts: string[] = [];
+            if (result.committed) {
+                parts.push(`committed ${result.hash.slice(0, 7)}`);
+            }
+            if (result.message) {
+                parts.push(result.message);
+            }
+            this.showStatus(parts.join(", ") || "Up to date", "success");
+        } catch (error) {
+            this.showStatus("Sync failed", "error");
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Git Sync",
+                "error",
+            );
+        }
+    }
 
-        if (options?.shortcut) {
-            const shortcut = document.createElement("span");
-            shortcut.textContent = options.shortcut;
-            shortcut.style.opacity = "0.6";
-            item.append(shortcut);
+    private async showGitDiff(path: string) {
+        let diff: GitDiff;
+        try {
+            diff = await gitDiff(path);
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Git",
+                "error",
+            );
+            return;
         }
 
-        dropdown.appendChild(item);
-    }
+        const { body, footer } = this.createPanelModal(
+            `${this.gitRelativePath(path)} ↔ HEAD`,
+        );
+        const view = document.createElement("div");
+        body.appendChild(view);
+
+        let sideBySide = this.gitDiffSideBySide;
+        const toggle = this.createPanelButton("", () => {
+            sideBySide = !sideBySide;
+            this.gitDiffSideBySide = sideBySide;
+            render();
+        });
+        const render = () => {
+            toggle.textContent = sideBySide ? "Unified" : "Side by Side";
+            view.innerHTML = "";
+            if (diff.hunks.length === 0) {
+                view.className = "text-sm text-white/50";
+                view.textContent = "No changes against HEAD.";
+                return;
+            }
+            view.className = "";
+            view.appendChild(
+                sideBySide
+                    ? this.renderSideBySideDiff(diff)
+                    : this.renderUnifiedDiff(diff),
+            );
+        };
+        render();
 
-    private createMenuSeparator(dropdown: HTMLDivElement) {
-        const separator = document.createElement("div");
-        separator.style.margin = "4px 0";
-        separator.style.borderTop = "1px solid rgba(255, 255, 255, 0.07)";
-        dropdown.appendChild(separator);
+        footer.append(
+            toggle,
+            this.createPanelButton("History", () => void this.showGitHistory(path)),
+            this.createPanelButton("Close", () => this.removeExistingModal(), true),
+        );
     }
 
-    private closeMenu() {
-        if (this.openMenuId === null) {
-            return;
+    private async handleSyncNow() {
+        this.persistActiveDocument();
+        this.showStatus("Syncing…");
+        try {
+            // 结果由 sync:finished 事件展示
+            await syncNow();
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Sync",
+                "error",
+            );
         }
-        this.openMenuId = null;
-        this.renderMenuBar();
     }
 
-    private displayNameForTheme(theme: string): string {
-        return theme
-            .split("-")
-            .map((part) =>
-                part.length ? part[0].toUpperCase() + part.slice(1) : part,
-            )
-            .join(" ");
+    // 磁盘上的文件被同步或恢复改写：未修改的已打开标签重新载入
+    private async reloadDocumentsFromDisk(paths: string[]) {
+        for (const path of paths) {
+            const doc = this.openDocuments.get(path);
+            if (!doc || doc.isDirty) {
+                continue;
+            }
+            try {
+                const content = await loadDocumentFromBackend(path);
+                doc.savedContent = content;
+                doc.currentContent = content;
+                if (path === this.currentFilePath) {
+                    this.applyMarkdownContent(content);
+                }
+            } catch (error) {
+                console.warn("reloading document failed", error);
+            }
+        }
     }
 
-    private handleOpenFileDialog() {
-        openFileDialog().catch((error) =>
-            console.warn("Open file dialog failed", error),
-        );
-    }
+    private async handleSyncFinished(workspace: string, report: SyncReport) {
+        const downloaded = report.downloaded ?? [];
+        const deleted = report.deletedLocal ?? [];
+        const conflicts = report.conflicts ?? [];
+        const errors = report.errors ?? [];
 
-    private handleOpenFolderDialog() {
-        openFolderDialog().catch((error) =>
-            console.warn("Open folder dialog failed", error),
+        const separator = workspace.includes("\\") ? "\\" : "/";
+        await this.reloadDocumentsFromDisk(
+            downloaded.map(
+                (rel) => `${workspace}${separator}${rel.split("/").join(separator)}`,
+            ),
         );
-    }
+        if (downloaded.length || deleted.length || conflicts.length) {
+            this.renderTabs();
+            await this.refreshSidebar();
+        }
 
-    private handleShowAbout() {
-        this.showAboutDialog();
+        const changes =
+            (report.uploaded?.length ?? 0) +
+            downloaded.length +
+            deleted.length +
+            (report.deletedRemote?.length ?? 0);
+        if (errors.length) {
+            this.showStatus(`Sync finished with ${errors.length} error(s)`, "error");
+            console.warn("sync errors", errors);
+        } else {
+            this.showStatus(
+                changes ? `Synced ${changes} file(s)` : "Sync: up to date",
+                "success",
+            );
+        }
+        if (conflicts.length) {
+            await this.showMessageDialog(
+                `Both copies changed; the server versions were kept as:\n\n${conflicts.join("\n")}`,
+                "Sync Conflicts",
+                "warning",
+            );
+        }
+        const skipped = report.skipped ?? [];
+        if (skipped.length) {
+            await this.showMessageDialog(
+                `These files have unsaved changes and were not updated from the server; save them and sync again:\n\n${skipped.join("\n")}`,
+                "Sync Skipped Files",
+                "warning",
+            );
+        }
     }
 
-    /**
-     * 显示关于对话框，包含GitHub超链接
-     */
-    private showAboutDialog(): void {
-        // 移除现有的模态框
-        this.removeExistingModal();
+    private async showSyncSettingsDialog() {
+        let target: SyncTarget;
+        try {
+            target = await loadSyncTarget();
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Sync",
+                "error",
+            );
+            return;
+        }
 
-        // 创建遮罩层
-        const overlay = document.createElement("div");
-        overlay.className =
-            "fixed inset-0 bg-black/50 backdrop-blur-sm z-50 flex items-center justify-center";
-        overlay.id = "modal-overlay";
-        overlay.style.zIndex = "99999";
+        const { body, footer } = this.createPanelModal(
+            `WebDAV Sync – ${target.workspace}`,
+        );
+        body.classList.add("sync-settings");
+
+        const field = (label: string, input: HTMLInputElement) => {
+            const row = document.createElement("label");
+            row.className = "flex flex-col gap-1 mb-3 text-sm text-white/70";
+            row.append(label, input);
+            body.appendChild(row);
+            return input;
+        };
+        const textInput = (type: string, value: string, placeholder = "") => {
+            const input = document.createElement("input");
+            input.type = type;
+            input.value = value;
+            input.placeholder = placeholder;
+            input.className =
+                "px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+            return input;
+        };
 
-        // 创建模态框容器
-        const modal = document.createElement("div");
-        modal.className =
-            "bg-gray-800 border border-gray-600 rounded-lg shadow-2xl max-w-md w-full mx-4 transform transition-all duration-300 scale-100";
-        modal.style.minWidth = "320px";
-        modal.style.maxWidth = "500px";
-        modal.style.borderLeftColor = "#3b82f6";
-        modal.style.borderLeftWidth = "4px";
+        const url = field(
+            "Server folder URL",
+            textInput(
+                "url",
+                target.url,
+                "https://cloud.example.com/remote.php/dav/files/me/Notes",
+            ),
+        );
+        const username = field("Username", textInput("text", target.username));
+        const password = field(
+            "Password or app token",
+            textInput("password", target.password ?? ""),
+        );
+        const interval = field(
+            "Sync every (minutes, 0 = off)",
+            textInput("number", String(target.intervalMinutes || 0)),
+        );
+        interval.min = "0";
 
-        // 创建标题栏
-        const header = document.createElement("div");
-        header.className =
-            "flex items-center gap-3 px-6 py-4 border-b border-gray-700";
+        const onSaveLabel = document.createElement("label");
+        onSaveLabel.className = "flex items-center gap-2 text-sm text-white/70";
+        const onSave = document.createElement("input");
+        onSave.type = "checkbox";
+        onSave.checked = target.syncOnSave;
+        onSaveLabel.append(onSave, "Sync after saving");
+        body.appendChild(onSaveLabel);
 
-        const icon = document.createElement("img");
-        icon.src = APP_ICON;
-        icon.alt = "App Icon";
-        icon.className = "w-8 h-8";
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
 
-        const titleElement = document.createElement("h3");
-        titleElement.textContent = `About ${APP_NAME}`;
-        titleElement.className = "text-lg font-semibold text-white flex-1";
+        const save = async (thenSync: boolean) => {
+            try {
+                await saveSyncTarget({
+                    workspace: target.workspace,
+                    url: url.value.trim(),
+                    username: username.value,
+                    password: password.value,
+                    intervalMinutes: Math.max(0, Number.parseInt(interval.value, 10) || 0),
+                    syncOnSave: onSave.checked,
+                });
+            } catch (error) {
+                // 保留对话框中已填写的内容
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+                return;
+            }
+            this.removeExistingModal();
+            if (thenSync && url.value.trim()) {
+                await this.handleSyncNow();
+            } else {
+                this.flashStatus("Sync settings saved");
+            }
+        };
 
-        const closeButton = document.createElement("button");
-        closeButton.innerHTML = "×";
-        closeButton.className =
-            "text-gray-400 hover:text-white text-2xl font-bold w-8 h-8 flex items-center justify-center rounded hover:bg-gray-700 transition-colors";
-        closeButton.addEventListener("click", () => this.removeExistingModal());
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Save", () => void save(false)),
+            this.createPanelButton("Save & Sync", () => void save(true), true),
+        );
+        url.focus();
+    }
 
-        header.appendChild(icon);
-        header.appendChild(titleElement);
-        header.appendChild(closeButton);
+    private async handleBackupNow() {
+        this.showStatus("Backing up…");
+        try {
+            // 结果由 backup:finished 事件展示
+            await backupNow();
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Backup",
+                "error",
+            );
+        }
+    }
 
-        // 创建内容区域
-        const content = document.createElement("div");
-        content.className = "px-6 py-4";
+    private async showBackupDialog() {
+        let target: BackupTarget;
+        try {
+            target = await loadBackupTarget();
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Backup",
+                "error",
+            );
+            return;
+        }
 
-        // 应用信息
-        const appInfo = document.createElement("div");
-        appInfo.className = "text-gray-200 leading-relaxed space-y-2";
-        
-        const appName = document.createElement("div");
-        appName.textContent = APP_NAME;
-        appName.className = "text-xl font-semibold text-white mb-3";
-        
-        const version = document.createElement("div");
-        version.textContent = `Version: ${APP_VERSION}`;
-        version.className = "text-sm text-gray-300";
-        
-        const author = document.createElement("div");
-        author.textContent = `Author: ${APP_AUTHOR}`;
-        author.className = "text-sm text-gray-300";
-        
-        // GitHub 链接
-        const githubContainer = document.createElement("div");
-        githubContainer.className = "mt-4";
-        
-        const githubLabel = document.createElement("div");
-        githubLabel.textContent = "GitHub: ";
-        githubLabel.className = "text-sm text-gray-300 inline";
-        
-        const githubLink = document.createElement("a");
-        githubLink.textContent = APP_GITHUB;
-        githubLink.href = APP_GITHUB;
-        githubLink.target = "_blank";
-        githubLink.className = "text-blue-400 hover:text-blue-300 underline text-sm transition-colors";
-        githubLink.addEventListener("click", (e) => {
-            e.preventDefault();
-            // 使用 Wails 的 BrowserOpenURL 方法打开链接
-            if (window.go && window.go.main && window.go.main.App) {
-                window.go.main.App.BrowserOpenURL(APP_GITHUB);
-            } else {
-                // 备用方案：使用 window.open
-                window.open(APP_GITHUB, "_blank");
+        const { body, footer } = this.createPanelModal(
+            `S3 Backup – ${target.workspace}`,
+        );
+
+        const grid = document.createElement("div");
+        grid.className = "grid grid-cols-2 gap-x-4";
+        body.appendChild(grid);
+        const field = (label: string, type: string, value: string, placeholder = "") => {
+            const row = document.createElement("label");
+            row.className = "flex flex-col gap-1 mb-3 text-sm text-white/70";
+            const input = document.createElement("input");
+            input.type = type;
+            input.value = value;
+            input.placeholder = placeholder;
+            input.className =
+                "px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+            row.append(label, input);
+            grid.appendChild(row);
+            return input;
+        };
+        const numberField = (label: string, value: number) => {
+            const input = field(label, "number", String(value || 0));
+            input.min = "0";
+            return input;
+        };
+        const count = (input: HTMLInputElement) =>
+            Math.max(0, Number.parseInt(input.value, 10) || 0);
+
+        const endpoint = field("Endpoint", "url", target.endpoint, "http://localhost:9000");
+        const region = field("Region", "text", target.region, "us-east-1");
+        const bucket = field("Bucket", "text", target.bucket);
+        const prefix = field("Key prefix", "text", target.prefix);
+        const accessKey = field("Access key", "text", target.accessKey);
+        const secretKey = field("Secret key", "password", target.secretKey ?? "");
+        const interval = numberField("Back up every (hours, 0 = off)", target.intervalHours);
+        const keepLast = numberField("Keep last", target.retention?.keepLast ?? 0);
+        const keepDaily = numberField("Keep daily", target.retention?.keepDaily ?? 0);
+        const keepWeekly = numberField("Keep weekly", target.retention?.keepWeekly ?? 0);
+        const keepMonthly = numberField("Keep monthly", target.retention?.keepMonthly ?? 0);
+
+        const errorLine = document.createElement("div");
+        errorLine.className = "mb-3 text-sm text-red-400";
+        const snapshots = document.createElement("div");
+        snapshots.className = "backup-snapshots text-sm";
+        body.append(errorLine, snapshots);
+
+        const save = async (): Promise<boolean> => {
+            errorLine.textContent = "";
+            try {
+                await saveBackupTarget({
+                    workspace: target.workspace,
+                    endpoint: endpoint.value.trim(),
+                    region: region.value.trim(),
+                    bucket: bucket.value.trim(),
+                    prefix: prefix.value.trim(),
+                    accessKey: accessKey.value.trim(),
+                    secretKey: secretKey.value,
+                    intervalHours: count(interval),
+                    retention: {
+                        keepLast: count(keepLast),
+                        keepDaily: count(keepDaily),
+                        keepWeekly: count(keepWeekly),
+                        keepMonthly: count(keepMonthly),
+                    },
+                });
+                return true;
+            } catch (error) {
+                // 保留对话框中已填写的内容
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+                return false;
             }
-        });
-        
-        githubContainer.appendChild(githubLabel);
-        githubContainer.appendChild(githubLink);
-        
-        appInfo.appendChild(appName);
-        appInfo.appendChild(version);
-        appInfo.appendChild(author);
-        appInfo.appendChild(githubContainer);
-        
-        content.appendChild(appInfo);
+        };
 
-        // 创建按钮区域
-        const footer = document.createElement("div");
-        footer.className =
-            "flex justify-end gap-3 px-6 py-4 border-t border-gray-700";
+        footer.append(
+            this.createPanelButton("Close", () => this.removeExistingModal()),
+            this.createPanelButton("Save", async () => {
+                if (await save()) {
+                    this.flashStatus("Backup settings saved");
+                    await this.renderBackupSnapshots(snapshots);
+                }
+            }),
+            this.createPanelButton(
+                "Save & Back Up",
+                async () => {
+                    if (!(await save()) || !endpoint.value.trim()) {
+                        return;
+                    }
+                    await this.handleBackupNow();
+                    await this.renderBackupSnapshots(snapshots);
+                },
+                true,
+            ),
+        );
 
-        const okButton = document.createElement("button");
-        okButton.textContent = "OK";
-        okButton.className = "px-4 py-2 rounded font-medium transition-colors bg-blue-600 hover:bg-blue-700 text-white";
-        okButton.addEventListener("click", () => this.removeExistingModal());
+        if (target.endpoint) {
+            await this.renderBackupSnapshots(snapshots);
+        }
+    }
 
-        footer.appendChild(okButton);
+    private async renderBackupSnapshots(container: HTMLElement) {
+        container.innerHTML = "";
+        const heading = document.createElement("div");
+        heading.className = "mb-2 text-xs uppercase tracking-wider text-white/50";
+        heading.textContent = "Snapshots";
+        container.appendChild(heading);
 
-        // 组装模态框
-        modal.appendChild(header);
-        modal.appendChild(content);
-        modal.appendChild(footer);
-        overlay.appendChild(modal);
+        let summaries: BackupSummary[];
+        try {
+            summaries = await listBackups();
+        } catch (error) {
+            const message = document.createElement("div");
+            message.className = "text-red-400";
+            message.textContent = error instanceof Error ? error.message : String(error);
+            container.appendChild(message);
+            return;
+        }
+        if (summaries.length === 0) {
+            const empty = document.createElement("div");
+            empty.className = "text-white/50";
+            empty.textContent = "No snapshots yet.";
+            container.appendChild(empty);
+            return;
+        }
 
-        // 添加到页面
-        document.body.appendChild(overlay);
+        summaries.forEach((summary) => {
+            const row = document.createElement("div");
+            row.className =
+                "flex items-baseline gap-3 px-2 py-1.5 rounded cursor-pointer hover:bg-white/10";
+            const when = document.createElement("span");
+            when.className = "flex-1 text-white/85";
+            when.textContent = new Date(summary.time).toLocaleString();
+            const meta = document.createElement("span");
+            meta.className = "text-xs text-white/50";
+            meta.textContent = `${summary.files} file(s) · ${this.formatBytes(summary.size)} · ${summary.host}`;
+            row.append(when, meta);
+
+            const files = document.createElement("div");
+            files.className = "hidden pl-4 pb-2";
+            row.addEventListener("click", () => {
+                const opening = files.classList.toggle("hidden") === false;
+                if (opening && !files.childElementCount) {
+                    void this.renderBackupFiles(files, summary.id);
+                }
+            });
+            container.append(row, files);
+        });
+    }
 
-        // 添加动画效果
-        setTimeout(() => {
-            modal.style.transform = "scale(1)";
-        }, 10);
+    private async renderBackupFiles(container: HTMLElement, snapshotId: string) {
+        let files: BackupFile[];
+        try {
+            files = await listBackupFiles(snapshotId);
+        } catch (error) {
+            container.textContent = error instanceof Error ? error.message : String(error);
+            return;
+        }
 
-        // 点击遮罩层关闭
-        overlay.addEventListener("click", (e) => {
-            if (e.target === overlay) {
-                this.removeExistingModal();
+        // 文件夹行可整体恢复
+        const folders = new Set<string>();
+        files.forEach((file) => {
+            const parts = file.path.split("/");
+            for (let i = 1; i < parts.length; i++) {
+                folders.add(parts.slice(0, i).join("/"));
             }
         });
+        const entries = [
+            { path: "", label: "Entire snapshot", icon: "🗂️" },
+            ...[...folders].sort().map((path) => ({ path, label: `${path}/`, icon: "📁" })),
+            ...files.map((file) => ({ path: file.path, label: file.path, icon: "📄" })),
+        ];
 
-        // ESC键关闭
-        const handleKeyDown = (e: KeyboardEvent) => {
-            if (e.key === "Escape") {
-                this.removeExistingModal();
-                document.removeEventListener("keydown", handleKeyDown);
-            }
-        };
-        document.addEventListener("keydown", handleKeyDown);
+        entries.forEach((entry) => {
+            const row = document.createElement("div");
+            row.className = "flex items-center gap-2 py-0.5 text-white/75";
+            const name = document.createElement("span");
+            name.className = "flex-1 truncate";
+            name.textContent = `${entry.icon} ${entry.label}`;
+
+            const restore = document.createElement("button");
+            restore.type = "button";
+            restore.className = "px-2 text-xs rounded text-blue-300 hover:bg-white/10";
+            restore.textContent = "Restore";
+            let armed = false;
+            restore.addEventListener("click", async () => {
+                // 打开标签里未保存的修改会被恢复覆盖，或在之后保存时覆盖恢复结果
+                const root = (this.currentFolderPath ?? "").replace(/[\\/]+$/, "");
+                const separator = root.includes("\\") ? "\\" : "/";
+                const restoredPath = entry.path
+                    ? root + separator + entry.path.split("/").join(separator)
+                    : root;
+                const dirty = [...this.openDocuments.values()].filter(
+                    (doc) =>
+                        doc.isDirty &&
+                        (doc.path === restoredPath || doc.path.startsWith(restoredPath + separator)),
+                );
+                if (dirty.length) {
+                    this.showStatus(
+                        `Save or close ${dirty.map((doc) => doc.name).join(", ")} before restoring`,
+                        "error",
+                    );
+                    return;
+                }
+                // 恢复会覆盖现有文件，需再次点击确认
+                if (!armed) {
+                    armed = true;
+                    restore.textContent = "Overwrite?";
+                    restore.classList.add("text-amber-300");
+                    return;
+                }
+                restore.disabled = true;
+                restore.textContent = "Restoring…";
+                try {
+                    const restored = await restoreBackup(snapshotId, entry.path);
+                    await this.reloadDocumentsFromDisk(restored);
+                    this.renderTabs();
+                    await this.refreshSidebar();
+                    restore.textContent = "Restored";
+                    this.showStatus(`Restored ${restored.length} file(s)`, "success");
+                } catch (error) {
+                    restore.textContent = "Failed";
+                    this.showStatus(
+                        `Restore failed: ${error instanceof Error ? error.message : String(error)}`,
+                        "error",
+                    );
+                }
+            });
+
+            row.append(name, restore);
+            container.appendChild(row);
+        });
+    }
+
+    private formatBytes(size: number): string {
+        const units = ["B", "KB", "MB", "GB"];
+        let value = size;
+        let unit = 0;
+        while (value >= 1024 && unit < units.length - 1) {
+            value /= 1024;
+            unit++;
+        }
+        return `${value.toFixed(unit ? 1 : 0)} ${units[unit]}`;
+    }
+
+    private renderUnifiedDiff(diff: GitDiff): HTMLElement {
+        const table = document.createElement("table");
+        table.className = "git-diff";
+        diff.hunks.forEach((hunk) => {
+            const header = table.insertRow();
+            header.className = "git-diff-hunk";
+            const cell = header.insertCell();
+            cell.colSpan = 3;
+            cell.textContent = hunk.header;
+
+            hunk.lines.forEach((line) => {
+                const row = table.insertRow();
+                row.className = `git-diff-${line.kind}`;
+                row.insertCell().textContent = line.oldLine ? String(line.oldLine) : "";
+                row.insertCell().textContent = line.newLine ? String(line.newLine) : "";
+                const text = row.insertCell();
+                text.className = "git-diff-text";
+                const sign = line.kind === "add" ? "+" : line.kind === "delete" ? "-" : " ";
+                text.textContent = `${sign} ${line.text}`;
+            });
+        });
+        return table;
     }
 
-    private renderSidebar() {
-        if (!this.sidebarElement) {
+    private renderSideBySideDiff(diff: GitDiff): HTMLElement {
+        const table = document.createElement("table");
+        table.className = "git-diff git-diff-split";
+        diff.sideBySide.forEach((pair) => {
+            const row = table.insertRow();
+            [
+                { line: pair.left, number: pair.left?.oldLine },
+                { line: pair.right, number: pair.right?.newLine },
+            ].forEach(({ line, number }) => {
+                const gutter = row.insertCell();
+                const text = row.insertCell();
+                text.className = "git-diff-text";
+                if (!line) {
+                    gutter.className = text.className = "git-diff-empty";
+                    return;
+                }
+                gutter.textContent = number ? String(number) : "";
+                text.textContent = line.text;
+                if (line.kind !== "context") {
+                    text.classList.add(`git-diff-${line.kind}`);
+                }
+            });
+        });
+        return table;
+    }
+
+    private async showGitHistory(path: string) {
+        let commits: GitCommit[];
+        try {
+            commits = await gitLog(path);
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Git",
+                "error",
+            );
             return;
         }
 
-        this.sidebarElement.innerHTML = "";
-
-        if (!this.sidebarTreeRoot) {
-            // 创建空状态容器
-            const emptyState = document.createElement("div");
-            emptyState.className = "flex flex-col items-center justify-center h-full px-4 py-8 text-center";
-            
-            // 创建logo区域
-            const logoSection = document.createElement("div");
-            logoSection.className = "mb-6";
-            
-            // 创建logo图标（使用SVG）
-            const logoIcon = document.createElement("div");
-            logoIcon.className = "w-12 h-12 mx-auto mb-3";
-            logoIcon.innerHTML = `
-                <svg viewBox="0 0 24 24" fill="none" xmlns="http://www.w3.org/2000/svg" class="w-full h-full text-blue-400">
-                    <path d="M3 7V5C3 3.89543 3.89543 3 5 3H9L11 5H19C20.1046 5 21 5.89543 21 7V19C21 20.1046 20.1046 21 19 21H5C3.89543 21 3 20.1046 3 19V7Z" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
-                    <path d="M8 11H16M8 15H12" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
-                </svg>
-            `;
-            
-            // 创建应用名称
-            const appName = document.createElement("div");
-            appName.className = "text-lg font-semibold text-white mb-1";
-            appName.textContent = APP_NAME;
-            
-            // 创建版本信息
-            const version = document.createElement("div");
-            version.className = "text-xs text-white/60 mb-4";
-            version.textContent = `Version: ${APP_VERSION}`;
-            
-            logoSection.appendChild(logoIcon);
-            logoSection.appendChild(appName);
-            logoSection.appendChild(version);
-            
-            // 创建打开文件夹按钮
-            const openButton = document.createElement("button");
-            openButton.className = "flex items-center gap-3 px-4 py-3 bg-blue-600 hover:bg-blue-700 text-white rounded-lg transition-colors duration-200 font-medium text-sm";
-            openButton.innerHTML = `
-                <svg viewBox="0 0 24 24" fill="none" xmlns="http://www.w3.org/2000/svg" class="w-5 h-5">
-                    <path d="M3 7V5C3 3.89543 3.89543 3 5 3H9L11 5H19C20.1046 5 21 5.89543 21 7V19C21 20.1046 20.1046 21 19 21H5C3.89543 21 3 20.1046 3 19V7Z" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
-                    <path d="M8 11H16M8 15H12" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"/>
-                </svg>
-                Open Folder
-            `;
-            
-            // 添加点击事件
-            openButton.addEventListener("click", () => {
-                this.handleOpenFolderDialog();
-            });
-            
-            // 创建提示文本
-            const hintText = document.createElement("div");
-            hintText.className = "text-xs text-white/50 mt-4";
-            hintText.textContent = "Select a folder to browse your files";
-            
-            // 组装空状态
-            emptyState.appendChild(logoSection);
-            emptyState.appendChild(openButton);
-            emptyState.appendChild(hintText);
-            
-            // 为整个空状态添加右键菜单支持
-            emptyState.addEventListener("contextmenu", (event) => {
-                event.preventDefault();
-                event.stopPropagation();
-                this.showContextMenu(event, null);
+        const { body, footer } = this.createPanelModal(
+            `History of ${this.gitRelativePath(path)}`,
+        );
+        if (commits.length === 0) {
+            body.className += " text-sm text-white/50";
+            body.textContent = "This file has not been committed yet.";
+        }
+        commits.forEach((commit) => {
+            const row = document.createElement("div");
+            row.className =
+                "git-history-row flex items-baseline gap-3 px-2 py-1.5 rounded text-sm cursor-pointer hover:bg-white/10";
+            row.title = "Open this revision read-only";
+
+            const hash = document.createElement("span");
+            hash.className = "font-mono text-xs text-amber-300";
+            hash.textContent = commit.shortHash;
+            const subject = document.createElement("span");
+            subject.className = "flex-1 truncate text-white/85";
+            subject.textContent = commit.subject;
+            const meta = document.createElement("span");
+            meta.className = "text-xs text-white/50 whitespace-nowrap";
+            meta.textContent = `${commit.author} · ${new Date(commit.date).toLocaleString()}`;
+
+            row.append(hash, subject, meta);
+            row.addEventListener("click", () => {
+                void this.showGitRevision(path, commit.shortHash, commit.subject);
             });
+            body.appendChild(row);
+        });
 
-            this.sidebarElement.appendChild(emptyState);
+        footer.append(
+            this.createPanelButton("Diff with HEAD", () => void this.showGitDiff(path)),
+            this.createPanelButton("Close", () => this.removeExistingModal(), true),
+        );
+    }
+
+    private async showGitRevision(path: string, revision: string, subject: string) {
+        let content: string;
+        try {
+            content = await gitShowRevision(path, revision);
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Git",
+                "error",
+            );
             return;
         }
 
-        const container = document.createElement("div");
-        container.className = "py-2 text-sm";
-
-        this.renderTreeNode(this.sidebarTreeRoot, 0, container);
-        this.sidebarElement.appendChild(container);
+        const { body, footer } = this.createPanelModal(
+            `${this.gitRelativePath(path)} @ ${revision} (read-only)`,
+        );
+        const caption = document.createElement("div");
+        caption.className = "text-xs text-white/50 mb-2 truncate";
+        caption.textContent = subject;
+        const pre = document.createElement("pre");
+        pre.className = "git-revision";
+        pre.textContent = content;
+        body.append(caption, pre);
+
+        footer.append(
+            this.createPanelButton("Copy", () => {
+                void navigator.clipboard
+                    ?.writeText(content)
+                    .then(() => this.flashStatus("Revision copied"));
+            }),
+            this.createPanelButton("Back", () => void this.showGitHistory(path)),
+            this.createPanelButton("Close", () => this.removeExistingModal(), true),
+        );
     }
 
     private renderTreeNode(
@@ -2310,6 +5050,24 @@ export class EditorApp {
 
         row.append(marker, label);
 
+        if (node.gitStatus) {
+            label.classList.add(`git-status-${node.gitStatus}`);
+            const badge = document.createElement("span");
+            badge.className = `git-status-badge git-status-${node.gitStatus}`;
+            badge.textContent = GIT_STATUS_BADGES[node.gitStatus] ?? "";
+            badge.title = node.gitStatus;
+            row.appendChild(badge);
+        }
+
+        if (node.vault) {
+            const lock = document.createElement("span");
+            lock.className = "vault-badge";
+            lock.textContent = node.vault === "locked" ? "🔒" : "🔓";
+            lock.title =
+                node.vault === "locked" ? "Locked vault" : "Unlocked vault";
+            row.appendChild(lock);
+        }
+
         // 添加右键菜单事件监听器
         row.addEventListener("contextmenu", (event) => {
             event.preventDefault();
@@ -2320,6 +5078,10 @@ export class EditorApp {
         if (node.isDir) {
             row.addEventListener("click", (event) => {
                 event.stopPropagation();
+                if (node.vault === "locked") {
+                    void this.handleUnlockVault(node);
+                    return;
+                }
                 const expanded = this.expandedPaths.has(node.path);
                 if (expanded) {
                     this.expandedPaths.delete(node.path);
@@ -2416,6 +5178,14 @@ export class EditorApp {
             name,
             path,
             isDir,
+            gitStatus:
+                typeof value.gitStatus === "string" && value.gitStatus
+                    ? value.gitStatus
+                    : undefined,
+            vault:
+                value.vault === "locked" || value.vault === "unlocked"
+                    ? value.vault
+                    : undefined,
             hasChildren:
                 typeof value.hasChildren === "boolean"
                     ? Boolean(value.hasChildren)
@@ -2640,7 +5410,11 @@ export class EditorApp {
             indentWithTabs: true,
             styleActiveLine: true,
             foldGutter: true,
-            gutters: ["CodeMirror-linenumbers", "CodeMirror-foldgutter"],
+            gutters: [
+                "CodeMirror-linenumbers",
+                "lint-gutter",
+                "CodeMirror-foldgutter",
+            ],
         });
 
         // 设置尺寸
@@ -2719,7 +5493,10 @@ export class EditorApp {
         }
 
         try {
-            const content = await loadDocumentFromBackend(target);
+            const content = await this.loadNote(target);
+            if (content === null) {
+                return;
+            }
             this.persistActiveDocument();
             const doc = this.upsertDocument(target, content ?? "");
             this.setCurrentFile(doc.path);
@@ -2844,6 +5621,13 @@ export class EditorApp {
                         this.handleNewFileInFolder(target);
                     },
                 );
+                const newFromTemplateItem = this.createContextMenuItem(
+                    "New from Template…",
+                    "🧩",
+                    () => {
+                        this.showTemplateDialog(target.path);
+                    },
+                );
                 const newFolderInFolderItem = this.createContextMenuItem(
                     "New Folder",
                     "📁",
@@ -2866,10 +5650,30 @@ export class EditorApp {
                     },
                 );
 
+                const mergeItem = this.createContextMenuItem(
+                    "Merge Notes…",
+                    "🧷",
+                    () => {
+                        this.showMergeNotesDialog(target.path);
+                    },
+                );
+
+                const batchRenameItem = this.createContextMenuItem(
+                    "Batch Rename…",
+                    "🔢",
+                    () => {
+                        this.showBatchRenameDialog(target.path);
+                    },
+                );
+
                 menu.appendChild(newFileInFolderItem);
+                menu.appendChild(newFromTemplateItem);
                 menu.appendChild(newFolderInFolderItem);
                 menu.appendChild(renameItem);
                 menu.appendChild(deleteItem);
+                menu.appendChild(mergeItem);
+                menu.appendChild(batchRenameItem);
+                this.appendVaultContextItems(menu, target);
             } else {
                 // 文件右键菜单：重命名、删除
                 const renameItem = this.createContextMenuItem(
@@ -2887,48 +5691,417 @@ export class EditorApp {
                     },
                 );
 
-                menu.appendChild(renameItem);
-                menu.appendChild(deleteItem);
+                menu.appendChild(renameItem);
+                menu.appendChild(deleteItem);
+                if (isMarkdownFile(target.path) && !isEncryptedNote(target.path)) {
+                    menu.appendChild(
+                        this.createContextMenuItem("Split Note…", "✂️", async () => {
+                            await this.openFile(target.path);
+                            this.showSplitNoteDialog();
+                        }),
+                    );
+                }
+            }
+            this.appendTransferContextItems(menu, target);
+            if (this.gitStatus) {
+                this.appendGitContextItems(menu, target);
+            }
+        } else {
+            // 右键点击空白区域
+            const newFileItem = this.createContextMenuItem(
+                "New File",
+                "📄",
+                () => {
+                    this.handleNewFile();
+                },
+            );
+            const newFolderItem = this.createContextMenuItem(
+                "New Folder",
+                "📁",
+                () => {
+                    this.handleNewFolder();
+                },
+            );
+            const refreshItem = this.createContextMenuItem("Refresh", "🔄", () => {
+                this.handleRefresh();
+            });
+            menu.appendChild(newFileItem);
+            menu.appendChild(newFolderItem);
+            menu.appendChild(refreshItem);
+        }
+
+        document.body.appendChild(menu);
+        this.contextMenu = menu;
+
+        // 添加全局点击事件监听器来隐藏菜单
+        const hideMenu = (e: MouseEvent) => {
+            if (!menu.contains(e.target as Node)) {
+                this.hideContextMenu();
+                document.removeEventListener("click", hideMenu);
+            }
+        };
+
+        // 延迟添加事件监听器，避免立即触发
+        setTimeout(() => {
+            document.addEventListener("click", hideMenu);
+        }, 10);
+    }
+
+    private appendTransferContextItems(menu: HTMLDivElement, target: FileTreeNode) {
+        menu.appendChild(
+            this.createContextMenuItem("Duplicate", "📑", () => {
+                void this.handleDuplicateItem(target);
+            }),
+        );
+        menu.appendChild(
+            this.createContextMenuItem("Copy to…", "📋", () => {
+                this.showTransferDialog(target, "copy");
+            }),
+        );
+        menu.appendChild(
+            this.createContextMenuItem("Move to…", "📦", () => {
+                this.showTransferDialog(target, "move");
+            }),
+        );
+    }
+
+    private async handleDuplicateItem(target: FileTreeNode) {
+        try {
+            const result = await duplicateItem(target.path);
+            await this.refreshSidebar();
+            if (result.error) {
+                throw new Error(result.error);
+            }
+            const copy = result.items[0]?.to;
+            this.flashStatus(copy ? `Duplicated as ${this.displayNameForPath(copy)}` : "Nothing duplicated");
+        } catch (error) {
+            this.showStatus(
+                `Duplicate failed: ${error instanceof Error ? error.message : String(error)}`,
+                "error",
+            );
+        }
+    }
+
+    // 复制或移动到其他文件夹：选择目标和冲突处理方式，显示进度并可取消
+    private showTransferDialog(target: FileTreeNode, mode: "copy" | "move") {
+        const verb = mode === "copy" ? "Copy" : "Move";
+        const { body, footer } = this.createPanelModal(`${verb} ${target.name}`);
+        const inputClass =
+            "flex-1 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const field = (label: string, ...inputs: HTMLElement[]) => {
+            const row = document.createElement("label");
+            row.className = "flex items-center gap-3 mb-2 text-sm text-white/80";
+            const name = document.createElement("span");
+            name.className = "w-28";
+            name.textContent = label;
+            row.append(name, ...inputs);
+            body.appendChild(row);
+        };
+
+        const destination = document.createElement("input");
+        destination.type = "text";
+        destination.className = inputClass;
+        destination.value = this.currentFolderPath ?? "";
+        const browse = this.createPanelButton("Browse…", () => {
+            void chooseFolder(`${verb} To`, destination.value).then((folder) => {
+                if (folder) {
+                    destination.value = folder;
+                }
+            });
+        });
+        field("To folder", destination, browse);
+
+        const policy = document.createElement("select");
+        policy.className = inputClass;
+        (
+            [
+                ["keepBoth", "Keep both (number the new name)"],
+                ["skip", "Skip files that exist"],
+                ["overwrite", "Replace files that exist"],
+            ] as Array<[ConflictPolicy, string]>
+        ).forEach(([value, label]) => {
+            const option = document.createElement("option");
+            option.value = value;
+            option.textContent = label;
+            policy.appendChild(option);
+        });
+        field("If names clash", policy);
+        const hint = document.createElement("p");
+        hint.className = "mb-3 text-sm text-white/60";
+        hint.textContent = "Skipping or replacing merges folders that already exist; keeping both copies the folder under a new name.";
+        body.appendChild(hint);
+
+        const progress = document.createElement("div");
+        progress.id = "transfer-progress";
+        progress.className = "text-sm text-white/60";
+        body.appendChild(progress);
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        let running = false;
+        const start = async () => {
+            const folder = destination.value.trim();
+            if (running || !folder) {
+                return;
+            }
+            errorLine.textContent = "";
+            if (policy.value === "overwrite") {
+                // 覆盖会丢弃打开标签里未保存的修改，先让用户保存
+                const separator = folder.includes("\\") ? "\\" : "/";
+                const replaced = folder.replace(/[\\/]+$/, "") + separator + target.name;
+                const dirty = [...this.openDocuments.values()].filter(
+                    (doc) =>
+                        doc.isDirty &&
+                        (doc.path === replaced || doc.path.startsWith(replaced + separator)),
+                );
+                if (dirty.length) {
+                    errorLine.textContent = `Save or close ${dirty.map((doc) => doc.name).join(", ")} first; replacing would discard unsaved changes.`;
+                    return;
+                }
+            }
+            running = true;
+            startButton.disabled = true;
+            startButton.classList.add("opacity-40");
+            try {
+                const transfer = mode === "copy" ? copyItems : moveItems;
+                const result = await transfer([target.path], folder, policy.value as ConflictPolicy);
+                // 中途失败或取消时已移动的部分也要跟随
+                if (mode === "move") {
+                    this.followMovedDocuments(result);
+                }
+                if (result.error) {
+                    throw new Error(result.error);
+                }
+                this.removeExistingModal();
+                const done = mode === "copy" ? "Copied" : "Moved";
+                const skipped = result.skipped.length ? `, skipped ${result.skipped.length}` : "";
+                this.flashStatus(`${done} ${result.files} files${skipped}`);
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+            } finally {
+                running = false;
+                startButton.disabled = false;
+                startButton.classList.remove("opacity-40");
+                // 被覆盖的文件可能正打开着，未修改的标签重新载入
+                await this.reloadDocumentsFromDisk([...this.openDocuments.keys()]);
+                await this.refreshSidebar();
+            }
+        };
+
+        const startButton = this.createPanelButton(verb, () => void start(), true);
+        footer.append(
+            this.createPanelButton("Cancel", () => {
+                if (running) {
+                    void cancelTransfer();
+                    return;
+                }
+                this.removeExistingModal();
+            }),
+            startButton,
+        );
+        destination.focus();
+    }
+
+    private renderTransferProgress(progress: TransferProgress) {
+        const container = document.getElementById("transfer-progress");
+        if (!container) {
+            if (progress.phase !== "done" && progress.total > 0) {
+                this.flashStatus(`Copying… ${progress.done}/${progress.total}`);
+            }
+            return;
+        }
+        container.replaceChildren();
+        if (progress.phase === "scanning") {
+            container.textContent = "Counting files…";
+            return;
+        }
+        const bar = document.createElement("div");
+        bar.className = "h-1.5 mb-1 rounded bg-white/10 overflow-hidden";
+        const fill = document.createElement("div");
+        fill.className = "h-full bg-blue-500";
+        fill.style.width = `${progress.total ? Math.round((progress.done / progress.total) * 100) : 100}%`;
+        bar.appendChild(fill);
+        const label = document.createElement("div");
+        label.textContent = `${progress.done} of ${progress.total} files${progress.path ? ` · ${this.displayNameForPath(progress.path)}` : ""}`;
+        container.append(bar, label);
+    }
+
+    // 移动后已打开的标签跟随到新路径，没有移走的文件保持原路径
+    private followMovedDocuments(result: TransferResult) {
+        result.moved.forEach(({ from, to }) => {
+            const separator = from.includes("\\") ? "\\" : "/";
+            [...this.openDocuments.keys()].forEach((path) => {
+                if (path === from) {
+                    this.updateOpenDocumentsAfterRename(path, to, false);
+                } else if (path.startsWith(from + separator)) {
+                    this.updateOpenDocumentsAfterRename(path, to + path.slice(from.length), false);
+                }
+            });
+        });
+    }
+
+    private appendVaultContextItems(menu: HTMLDivElement, target: FileTreeNode) {
+        const separator = document.createElement("div");
+        separator.style.margin = "4px 0";
+        separator.style.borderTop = "1px solid rgba(255, 255, 255, 0.07)";
+        menu.appendChild(separator);
+
+        if (target.vault === "locked") {
+            menu.appendChild(
+                this.createContextMenuItem("Unlock Vault…", "🔓", () => {
+                    void this.handleUnlockVault(target);
+                }),
+            );
+        } else if (target.vault === "unlocked") {
+            menu.appendChild(
+                this.createContextMenuItem("Lock Vault", "🔒", () => {
+                    void this.handleLockVault(target);
+                }),
+            );
+            menu.appendChild(
+                this.createContextMenuItem("Rotate Vault Key…", "🔑", () => {
+                    void this.handleRotateVaultKey(target);
+                }),
+            );
+            menu.appendChild(
+                this.createContextMenuItem("Export Decrypted Copy…", "📤", () => {
+                    void this.handleExportVault(target);
+                }),
+            );
+        } else {
+            menu.appendChild(
+                this.createContextMenuItem("Encrypt as Vault…", "🔐", () => {
+                    void this.handleCreateVault(target);
+                }),
+            );
+        }
+    }
+
+    private async handleCreateVault(node: FileTreeNode) {
+        const created = await this.promptPassphrase(
+            `Encrypt ${node.name} as a vault`,
+            "Encrypt",
+            true,
+            (passphrase) => createVault(node.path, passphrase).then(() => true),
+            "All file and folder names and contents below this folder will be encrypted in place and the plaintext removed. Without the passphrase they cannot be recovered.",
+        );
+        if (!created) {
+            return;
+        }
+        node.vault = "unlocked";
+        await this.refreshSidebar();
+        this.flashStatus(`Vault created: ${node.name}`);
+    }
+
+    private async handleUnlockVault(node: FileTreeNode) {
+        const unlocked = await this.promptPassphrase(
+            `Unlock ${node.name}`,
+            "Unlock",
+            false,
+            (passphrase) => unlockVault(node.path, passphrase).then(() => true),
+        );
+        if (!unlocked) {
+            return;
+        }
+        node.vault = "unlocked";
+        node.children = undefined;
+        node.hasChildren = true;
+        this.expandedPaths.add(node.path);
+        await this.ensureDirectoryChildren(node);
+        this.renderSidebar();
+        this.flashStatus(`Unlocked: ${node.name}`);
+    }
+
+    private async handleLockVault(node: FileTreeNode) {
+        try {
+            await lockVault(node.path);
+        } catch (error) {
+            console.warn("lockVault failed", error);
+            return;
+        }
+        // 关闭保险库内未修改的标签页，不在界面上保留明文
+        const prefix = node.path.replace(/[\\/]+$/, "");
+        Array.from(this.openDocuments.values())
+            .filter(
+                (doc) =>
+                    !doc.isDirty &&
+                    doc.path.length > prefix.length &&
+                    doc.path.startsWith(prefix) &&
+                    /[\\/]/.test(doc.path[prefix.length]),
+            )
+            .forEach((doc) => this.closeDocument(doc.path));
+
+        node.vault = "locked";
+        node.children = undefined;
+        node.hasChildren = false;
+        this.expandedPaths.delete(node.path);
+        this.renderSidebar();
+        this.flashStatus(`Locked: ${node.name}`);
+    }
+
+    private async handleRotateVaultKey(node: FileTreeNode) {
+        const rotated = await this.promptPassphrase(
+            `Rotate key of ${node.name}`,
+            "Rotate",
+            true,
+            (passphrase) =>
+                rotateVaultKey(node.path, passphrase).then(() => true),
+            "Every file is re-encrypted under a new key. Enter the passphrase that should protect the vault from now on (it may stay the same).",
+        );
+        if (rotated) {
+            await this.refreshSidebar();
+            this.flashStatus(`Vault key rotated: ${node.name}`);
+        }
+    }
+
+    private async handleExportVault(node: FileTreeNode) {
+        try {
+            const dest = await exportVault(node.path);
+            if (dest) {
+                this.flashStatus(`Exported to ${dest}`);
             }
-        } else {
-            // 右键点击空白区域
-            const newFileItem = this.createContextMenuItem(
-                "New File",
-                "📄",
-                () => {
-                    this.handleNewFile();
-                },
-            );
-            const newFolderItem = this.createContextMenuItem(
-                "New Folder",
-                "📁",
-                () => {
-                    this.handleNewFolder();
-                },
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Export Vault",
+                "error",
             );
-            const refreshItem = this.createContextMenuItem("Refresh", "🔄", () => {
-                this.handleRefresh();
-            });
-            menu.appendChild(newFileItem);
-            menu.appendChild(newFolderItem);
-            menu.appendChild(refreshItem);
         }
+    }
 
-        document.body.appendChild(menu);
-        this.contextMenu = menu;
-
-        // 添加全局点击事件监听器来隐藏菜单
-        const hideMenu = (e: MouseEvent) => {
-            if (!menu.contains(e.target as Node)) {
-                this.hideContextMenu();
-                document.removeEventListener("click", hideMenu);
-            }
-        };
+    private appendGitContextItems(menu: HTMLDivElement, target: FileTreeNode) {
+        const separator = document.createElement("div");
+        separator.style.margin = "4px 0";
+        separator.style.borderTop = "1px solid rgba(255, 255, 255, 0.07)";
+        menu.appendChild(separator);
 
-        // 延迟添加事件监听器，避免立即触发
-        setTimeout(() => {
-            document.addEventListener("click", hideMenu);
-        }, 10);
+        menu.appendChild(
+            this.createContextMenuItem("Stage", "＋", () => {
+                void this.runGitAction(() => gitStage([target.path]), "Staged");
+            }),
+        );
+        menu.appendChild(
+            this.createContextMenuItem("Unstage", "－", () => {
+                void this.runGitAction(
+                    () => gitUnstage([target.path]),
+                    "Unstaged",
+                );
+            }),
+        );
+        if (!target.isDir) {
+            menu.appendChild(
+                this.createContextMenuItem("Diff with HEAD", "±", () => {
+                    void this.showGitDiff(target.path);
+                }),
+            );
+            menu.appendChild(
+                this.createContextMenuItem("File History", "🕘", () => {
+                    void this.showGitHistory(target.path);
+                }),
+            );
+        }
     }
 
     private createContextMenuItem(
@@ -2974,6 +6147,669 @@ export class EditorApp {
         });
     }
 
+    // 打开（必要时创建）包含 date 的周期笔记，date 为空表示今天
+    private async openPeriodic(kind: PeriodicKind, date = "") {
+        try {
+            const note = await openPeriodicNote(kind, date);
+            this.refreshSidebar();
+            await this.openFile(note.path);
+            if (note.line > 0) {
+                this.revealPosition(note.line, note.column);
+            }
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Journal",
+                "error",
+            );
+        }
+    }
+
+    private async navigatePeriodic(offset: number) {
+        if (!this.currentFilePath) {
+            this.flashStatus("Open a periodic note first");
+            return;
+        }
+        try {
+            const path = await adjacentPeriodicNote(this.currentFilePath, offset);
+            await this.openFile(path);
+        } catch (error) {
+            this.flashStatus(
+                error instanceof Error ? error.message : String(error),
+            );
+        }
+    }
+
+    private async showPeriodicCalendar(
+        year = new Date().getFullYear(),
+        month = new Date().getMonth() + 1,
+    ) {
+        let calendar;
+        try {
+            calendar = await loadPeriodicCalendar(year, month);
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Journal",
+                "error",
+            );
+            return;
+        }
+
+        const monthName = new Date(year, month - 1, 1).toLocaleDateString(
+            undefined,
+            { year: "numeric", month: "long" },
+        );
+        const { body, footer } = this.createPanelModal("Calendar");
+
+        const pad = (value: number) => String(value).padStart(2, "0");
+        const dateKey = (date: Date) =>
+            `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())}`;
+        const days = new Map(calendar.days.map((note) => [note.date, note]));
+        const weeks = new Map(calendar.weeks.map((note) => [note.date, note]));
+        const cellClass =
+            "px-1 py-1.5 rounded text-center text-sm hover:bg-white/10 cursor-pointer";
+
+        const navigation = document.createElement("div");
+        navigation.className = "flex items-center gap-2 mb-3";
+        const title = document.createElement("button");
+        title.type = "button";
+        title.className = `flex-1 text-base font-semibold hover:underline ${
+            calendar.monthNote ? "text-blue-300" : "text-white"
+        }`;
+        title.textContent = monthName;
+        title.title = calendar.monthNote
+            ? "Open the monthly note"
+            : "Create the monthly note";
+        title.addEventListener("click", () => {
+            this.removeExistingModal();
+            void this.openPeriodic("monthly", `${year}-${pad(month)}-01`);
+        });
+        const shift = (delta: number) => {
+            const target = new Date(year, month - 1 + delta, 1);
+            void this.showPeriodicCalendar(
+                target.getFullYear(),
+                target.getMonth() + 1,
+            );
+        };
+        navigation.append(
+            this.createPanelButton("‹", () => shift(-1)),
+            title,
+            this.createPanelButton("›", () => shift(1)),
+        );
+        body.appendChild(navigation);
+
+        const grid = document.createElement("div");
+        grid.className = "grid grid-cols-8 gap-1";
+        ["Wk", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"].forEach(
+            (label) => {
+                const header = document.createElement("div");
+                header.className = "text-center text-xs text-white/50";
+                header.textContent = label;
+                grid.appendChild(header);
+            },
+        );
+
+        // 周一为一周的第一天，与 ISO 周保持一致
+        const first = new Date(year, month - 1, 1);
+        const cursor = new Date(year, month - 1, 1 - ((first.getDay() + 6) % 7));
+        const today = dateKey(new Date());
+        while (cursor.getFullYear() * 12 + cursor.getMonth() <= year * 12 + month - 1) {
+            const weekStart = dateKey(cursor);
+            const weekNote = weeks.get(weekStart);
+            const weekCell = document.createElement("div");
+            weekCell.className = `${cellClass} text-xs ${
+                weekNote ? "text-blue-300 font-semibold" : "text-white/40"
+            }`;
+            weekCell.textContent = String(this.isoWeek(cursor));
+            weekCell.title = weekNote
+                ? `${weekNote.words} words`
+                : "Create the weekly note";
+            weekCell.addEventListener("click", () => {
+                this.removeExistingModal();
+                void this.openPeriodic("weekly", weekStart);
+            });
+            grid.appendChild(weekCell);
+
+            for (let i = 0; i < 7; i++) {
+                const key = dateKey(cursor);
+                const note = days.get(key);
+                const inMonth = cursor.getMonth() === month - 1;
+                const cell = document.createElement("div");
+                cell.className = `${cellClass} ${
+                    inMonth ? "text-white/80" : "text-white/30"
+                } ${note ? "bg-blue-500/20 font-semibold" : ""} ${
+                    key === today ? "ring-1 ring-blue-400" : ""
+                }`;
+                cell.textContent = String(cursor.getDate());
+                cell.title = note
+                    ? `${note.words} words`
+                    : "Create the daily note";
+                cell.addEventListener("click", () => {
+                    this.removeExistingModal();
+                    void this.openPeriodic("daily", key);
+                });
+                grid.appendChild(cell);
+                cursor.setDate(cursor.getDate() + 1);
+            }
+        }
+        body.appendChild(grid);
+
+        const summary = document.createElement("div");
+        summary.className = "mt-3 text-sm text-white/60";
+        const words = calendar.days.reduce((total, note) => total + note.words, 0);
+        summary.textContent = `${calendar.days.length} daily notes, ${words} words`;
+        body.appendChild(summary);
+
+        footer.append(
+            this.createPanelButton("Today", () => {
+                this.removeExistingModal();
+                void this.openPeriodic("daily");
+            }),
+            this.createPanelButton("Close", () => this.removeExistingModal(), true),
+        );
+    }
+
+    private isoWeek(date: Date): number {
+        const thursday = new Date(date.getFullYear(), date.getMonth(), date.getDate());
+        thursday.setDate(thursday.getDate() + 3 - ((thursday.getDay() + 6) % 7));
+        const firstThursday = new Date(thursday.getFullYear(), 0, 4);
+        return (
+            1 +
+            Math.round(
+                ((thursday.getTime() - firstThursday.getTime()) / 86400000 -
+                    3 +
+                    ((firstThursday.getDay() + 6) % 7)) /
+                    7,
+            )
+        );
+    }
+
+    private async showPeriodicSettingsDialog() {
+        let settings;
+        try {
+            settings = await loadPeriodicSettings();
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Journal",
+                "error",
+            );
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Periodic Notes");
+        const hint = document.createElement("p");
+        hint.className = "mb-3 text-sm text-white/60";
+        hint.textContent =
+            "Folders are relative to the workspace. Formats use 2006, 01, 02, Jan, Mon, GGGG (ISO year) and WW (ISO week); [text] is literal and / creates subfolders.";
+        body.appendChild(hint);
+
+        const inputClass =
+            "px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const kinds: Array<[PeriodicKind, string]> = [
+            ["daily", "Daily"],
+            ["weekly", "Weekly"],
+            ["monthly", "Monthly"],
+            ["yearly", "Yearly"],
+        ];
+        const rows = new Map<
+            PeriodicKind,
+            { folder: HTMLInputElement; format: HTMLInputElement; template: HTMLInputElement }
+        >();
+        kinds.forEach(([kind, label]) => {
+            const config: PeriodicNoteConfig = settings[kind];
+            const heading = document.createElement("div");
+            heading.className = "mt-2 mb-1 text-sm font-semibold text-white/80";
+            heading.textContent = label;
+            const row = document.createElement("div");
+            row.className = "grid grid-cols-3 gap-2 mb-2";
+            const input = (value: string, placeholder: string) => {
+                const element = document.createElement("input");
+                element.type = "text";
+                element.value = value;
+                element.placeholder = placeholder;
+                element.title = placeholder;
+                element.className = inputClass;
+                row.appendChild(element);
+                return element;
+            };
+            rows.set(kind, {
+                folder: input(config.folder, "Folder"),
+                format: input(config.format, "Format"),
+                template: input(config.template ?? "", "Template (optional)"),
+            });
+            body.append(heading, row);
+        });
+
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        const save = async () => {
+            const next = { ...settings };
+            rows.forEach((inputs, kind) => {
+                next[kind] = {
+                    folder: inputs.folder.value.trim(),
+                    format: inputs.format.value.trim(),
+                    template: inputs.template.value.trim(),
+                };
+            });
+            try {
+                await savePeriodicSettings(next);
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+                return;
+            }
+            this.removeExistingModal();
+            this.flashStatus("Periodic note settings saved");
+        };
+
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Save", () => void save(), true),
+        );
+    }
+
+    private async toggleSpellcheck() {
+        try {
+            const state = await loadSpellcheckSettings();
+            state.enabled = !state.enabled;
+            await saveSpellcheckSettings(state);
+            this.rememberSpellcheckSettings(state.enabled, state.languages);
+            this.flashStatus(state.enabled ? "Spell checking enabled" : "Spell checking disabled");
+            void this.runSpellcheck();
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+        }
+    }
+
+    // 同步本地设置快照，退出时保存快照不会覆盖拼写设置
+    private rememberSpellcheckSettings(enabled: boolean, languages: string[]) {
+        if (this.currentSettings) {
+            this.currentSettings = {
+                ...this.currentSettings,
+                spellcheck: { enabled, languages },
+            };
+        }
+    }
+
+    private async showSpellcheckSettingsDialog() {
+        let state;
+        try {
+            state = await loadSpellcheckSettings();
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Spelling",
+                "error",
+            );
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Spelling");
+        const hint = document.createElement("p");
+        hint.className = "mb-3 text-sm text-white/60";
+        hint.textContent = `Install Hunspell dictionaries (name.aff and name.dic) in ${state.dictionaryDir}. Notes can pick their own languages with "spellcheck: [en_US, de_DE]" or "lang: en" in front matter; no selection checks with every installed dictionary.`;
+        body.appendChild(hint);
+
+        const enabledLabel = document.createElement("label");
+        enabledLabel.className = "flex items-center gap-2 mb-3 text-sm text-white/80";
+        const enabled = document.createElement("input");
+        enabled.type = "checkbox";
+        enabled.checked = state.enabled;
+        enabledLabel.append(enabled, document.createTextNode("Check spelling as you type"));
+        body.appendChild(enabledLabel);
+
+        const heading = document.createElement("div");
+        heading.className = "mb-1 text-sm font-semibold text-white/80";
+        heading.textContent = "Languages";
+        body.appendChild(heading);
+        const languages = new Map<string, HTMLInputElement>();
+        if (state.available.length === 0) {
+            const empty = document.createElement("div");
+            empty.className = "mb-3 text-sm text-white/50";
+            empty.textContent = "No dictionaries installed";
+            body.appendChild(empty);
+        }
+        state.available.forEach((language) => {
+            const label = document.createElement("label");
+            label.className = "flex items-center gap-2 text-sm text-white/80";
+            const checkbox = document.createElement("input");
+            checkbox.type = "checkbox";
+            checkbox.checked = state.languages.includes(language);
+            label.append(checkbox, document.createTextNode(language));
+            languages.set(language, checkbox);
+            body.appendChild(label);
+        });
+
+        const wordsHeading = document.createElement("div");
+        wordsHeading.className = "mt-3 mb-1 text-sm font-semibold text-white/80";
+        wordsHeading.textContent = "Personal dictionary (one word per line)";
+        const words = document.createElement("textarea");
+        words.className =
+            "w-full h-32 px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white font-mono text-sm focus:outline-none focus:border-blue-500";
+        words.value = state.personalWords.join("\n");
+        body.append(wordsHeading, words);
+
+        const errorLine = document.createElement("div");
+        errorLine.className = "mt-3 text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        const save = async () => {
+            // 保留已选但当前未安装的语言
+            const selected = state.languages.filter((language) => !languages.has(language));
+            languages.forEach((checkbox, language) => {
+                if (checkbox.checked) {
+                    selected.push(language);
+                }
+            });
+            const next = {
+                ...state,
+                enabled: enabled.checked,
+                languages: selected,
+                personalWords: words.value.split("\n").map((word) => word.trim()).filter(Boolean),
+            };
+            try {
+                await saveSpellcheckSettings(next);
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+                return;
+            }
+            this.rememberSpellcheckSettings(next.enabled, next.languages);
+            this.removeExistingModal();
+            this.flashStatus("Spelling settings saved");
+            void this.runSpellcheck();
+        };
+
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Save", () => void save(), true),
+        );
+    }
+
+    // 当前文档与工作区的统计，以及工作区的每日写作目标
+    private async showStatisticsDialog() {
+        const content = this.editorInstance?.cm?.getValue() ?? "";
+        let stats;
+        try {
+            stats = await documentStats(content);
+        } catch (error) {
+            await this.showMessageDialog(
+                error instanceof Error ? error.message : String(error),
+                "Statistics",
+                "error",
+            );
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("Statistics");
+        const section = (title: string) => {
+            const heading = document.createElement("div");
+            heading.className = "mt-3 mb-1 text-sm font-semibold text-white/80";
+            heading.textContent = title;
+            const grid = document.createElement("div");
+            grid.className = "grid grid-cols-2 gap-x-6 gap-y-0.5 text-sm";
+            body.append(heading, grid);
+            return (label: string, value: string | number) => {
+                const name = document.createElement("span");
+                name.className = "text-white/60";
+                name.textContent = label;
+                const amount = document.createElement("span");
+                amount.className = "text-white text-right tabular-nums";
+                amount.textContent =
+                    typeof value === "number" ? value.toLocaleString() : value;
+                grid.append(name, amount);
+            };
+        };
+
+        const documentRow = section("This document");
+        documentRow("Words", stats.words);
+        if (stats.cjkCharacters > 0) {
+            documentRow("CJK characters", stats.cjkCharacters);
+        }
+        documentRow("Characters", stats.characters);
+        documentRow("Characters (no spaces)", stats.charactersNoSpaces);
+        documentRow("Paragraphs", stats.paragraphs);
+        documentRow("Reading time", `${stats.readingMinutes} min`);
+        documentRow("Headings", stats.headings);
+        documentRow("Links", stats.links);
+        documentRow("Images", stats.images);
+        documentRow("Code blocks", stats.codeBlocks);
+
+        if (this.currentFolderPath) {
+            try {
+                const workspace = await workspaceStats();
+                const workspaceRow = section("Workspace");
+                workspaceRow("Notes", workspace.notes);
+                workspaceRow("Words", workspace.totals.words);
+                workspaceRow("Reading time", `${workspace.totals.readingMinutes} min`);
+                workspaceRow("Links", workspace.totals.links);
+                workspaceRow("Images", workspace.totals.images);
+                workspace.longest.slice(0, 5).forEach((note) => {
+                    workspaceRow(note.relPath, note.words);
+                });
+            } catch (error) {
+                console.warn("workspaceStats failed", error);
+            }
+
+            const writing = document.createElement("div");
+            writing.id = "writing-progress";
+            body.appendChild(writing);
+            await this.renderWritingProgress(writing);
+        }
+
+        footer.append(
+            this.createPanelButton("Close", () => this.removeExistingModal(), true),
+        );
+    }
+
+    // 最近 14 天的写作字数柱状图和目标设置；保存后收到事件时重新绘制
+    private async renderWritingProgress(container: HTMLElement) {
+        let progress;
+        try {
+            progress = await writingProgress(14);
+        } catch (error) {
+            console.warn("writingProgress failed", error);
+            return;
+        }
+        container.replaceChildren();
+
+        const heading = document.createElement("div");
+        heading.className = "mt-3 mb-1 text-sm font-semibold text-white/80";
+        heading.textContent = "Writing goal";
+        const summary = document.createElement("div");
+        summary.className = "mb-2 text-sm text-white/70";
+        summary.textContent = progress.goal > 0
+            ? `Today ${progress.today.toLocaleString()} / ${progress.goal.toLocaleString()} words · streak ${progress.streak} days`
+            : `Today ${progress.today.toLocaleString()} words · streak ${progress.streak} days`;
+        container.append(heading, summary);
+
+        const chart = document.createElement("div");
+        chart.className = "flex items-end gap-1 h-20 mb-2";
+        const peak = Math.max(progress.goal, ...progress.days.map((day) => day.words), 1);
+        progress.days.forEach((day) => {
+            const bar = document.createElement("div");
+            const reached = progress.goal > 0 ? day.words >= progress.goal : day.words > 0;
+            bar.className = `flex-1 rounded-t ${reached ? "bg-green-500" : "bg-blue-500/60"}`;
+            bar.style.height = `${Math.max(0, day.words) / peak * 100}%`;
+            bar.style.minHeight = "2px";
+            bar.title = `${day.date}: ${day.words.toLocaleString()} words`;
+            chart.appendChild(bar);
+        });
+        container.appendChild(chart);
+
+        const row = document.createElement("div");
+        row.className = "flex items-center gap-2 text-sm text-white/80";
+        const label = document.createElement("span");
+        label.textContent = "Daily goal (words)";
+        const input = document.createElement("input");
+        input.type = "number";
+        input.min = "0";
+        input.step = "50";
+        input.value = String(progress.goal);
+        input.className =
+            "w-24 px-2 py-1 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+        const save = this.createPanelButton("Set Goal", async () => {
+            const goal = Math.max(0, Math.floor(Number(input.value) || 0));
+            try {
+                await setWritingGoal(goal);
+            } catch (error) {
+                this.showStatus(
+                    error instanceof Error ? error.message : String(error),
+                    "error",
+                );
+                return;
+            }
+            this.flashStatus(goal > 0 ? `Daily goal set to ${goal} words` : "Daily goal removed");
+            await this.renderWritingProgress(container);
+        });
+        row.append(label, input, save);
+        container.appendChild(row);
+    }
+
+    // 选择模板并填写标题和提示变量；targetDir 为空时使用工作区根目录
+    private async showTemplateDialog(targetDir: string) {
+        if (!targetDir && !this.currentFolderPath) {
+            await this.showMessageDialog(
+                "Please open a folder first",
+                "MarkdownDaoNote",
+                "warning",
+            );
+            return;
+        }
+
+        let templates: NoteTemplate[];
+        try {
+            templates = await listTemplates();
+        } catch (error) {
+            this.showStatus(
+                error instanceof Error ? error.message : String(error),
+                "error",
+            );
+            return;
+        }
+        if (templates.length === 0) {
+            await this.showMessageDialog(
+                "No templates found.\n\nAdd Markdown files to the workspace's .templates folder or to the templates folder in the settings directory.",
+                "New from Template",
+                "info",
+            );
+            return;
+        }
+
+        const { body, footer } = this.createPanelModal("New from Template");
+        const field = (label: string, control: HTMLElement) => {
+            const row = document.createElement("label");
+            row.className = "flex flex-col gap-1 mb-3 text-sm text-white/70";
+            row.append(label, control);
+            body.appendChild(row);
+        };
+        const inputClass =
+            "px-2 py-1.5 rounded bg-gray-900 border border-gray-600 text-white focus:outline-none focus:border-blue-500";
+
+        const select = document.createElement("select");
+        select.className = inputClass;
+        templates.forEach((template, index) => {
+            const option = document.createElement("option");
+            option.value = String(index);
+            option.textContent = template.description
+                ? `${template.name} — ${template.description}`
+                : template.name;
+            // 加载失败的模板仍列出，但不可选，并提示原因
+            if (template.error) {
+                option.textContent += " (broken)";
+                option.title = template.error;
+                option.disabled = true;
+            }
+            select.appendChild(option);
+        });
+        const usable = templates.findIndex((template) => !template.error);
+        select.value = String(Math.max(usable, 0));
+        field("Template", select);
+
+        const title = document.createElement("input");
+        title.type = "text";
+        title.className = inputClass;
+        title.placeholder = "Untitled";
+        field("Title", title);
+
+        const promptsContainer = document.createElement("div");
+        body.appendChild(promptsContainer);
+        const errorLine = document.createElement("div");
+        errorLine.className = "text-sm text-red-400";
+        body.appendChild(errorLine);
+
+        let promptInputs = new Map<string, HTMLInputElement>();
+        const renderPrompts = () => {
+            const previous = promptInputs;
+            promptInputs = new Map();
+            promptsContainer.innerHTML = "";
+            const template = templates[Number(select.value)];
+            errorLine.textContent = template?.error ?? "";
+            (template?.prompts ?? []).forEach((name) => {
+                const input = document.createElement("input");
+                input.type = "text";
+                input.className = inputClass;
+                input.value = previous.get(name)?.value ?? "";
+                const row = document.createElement("label");
+                row.className = "flex flex-col gap-1 mb-3 text-sm text-white/70";
+                row.append(name, input);
+                promptsContainer.appendChild(row);
+                promptInputs.set(name, input);
+            });
+        };
+        select.addEventListener("change", renderPrompts);
+        renderPrompts();
+
+        const submit = async () => {
+            const template = templates[Number(select.value)];
+            if (!template || template.error) {
+                return;
+            }
+            const vars: Record<string, string> = { title: title.value.trim() };
+            promptInputs.forEach((input, name) => {
+                vars[name] = input.value;
+            });
+            try {
+                const created = await createFromTemplate(
+                    template.path,
+                    targetDir,
+                    vars,
+                );
+                this.removeExistingModal();
+                if (targetDir) {
+                    this.expandedPaths.add(targetDir);
+                }
+                this.refreshSidebar();
+                await this.openFile(created.path);
+                if (created.line > 0) {
+                    this.revealPosition(created.line, created.column);
+                }
+            } catch (error) {
+                errorLine.textContent =
+                    error instanceof Error ? error.message : String(error);
+            }
+        };
+
+        body.addEventListener("keydown", (event) => {
+            if (event.key === "Enter" && event.target instanceof HTMLInputElement) {
+                event.preventDefault();
+                void submit();
+            }
+        });
+        footer.append(
+            this.createPanelButton("Cancel", () => this.removeExistingModal()),
+            this.createPanelButton("Create", () => void submit(), true),
+        );
+        title.focus();
+    }
+
     private async handleNewFileInFolder(targetFolder: FileTreeNode) {
         this.showInlineInput(
             `New File in "${targetFolder.name}"`,
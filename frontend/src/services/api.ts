@@ -39,6 +39,8 @@ export interface DirectoryEntry {
     isDir: boolean;
     hasChildren?: boolean;
     children?: DirectoryEntry[];
+    gitStatus?: string;
+    vault?: "locked" | "unlocked";
 }
 
 export interface Settings {
@@ -49,6 +51,11 @@ export interface Settings {
     fontSize: number;
     wordWrap: boolean;
     lastFile: string;
+    encryptionTimeoutMinutes?: number;
+    periodicNotes?: PeriodicSettings;
+    formatOnSave?: boolean;
+    tocOnSave?: boolean;
+    spellcheck?: SpellcheckSettings;
 }
 
 declare global {
@@ -71,6 +78,8 @@ export const DEFAULT_SETTINGS: Settings = {
 };
 
 export const SAVE_CANCELLED_ERROR = "save cancelled";
+export const NOTE_LOCKED_ERROR = "encrypted note is locked";
+export const NOTE_NEEDS_PASSPHRASE_ERROR = "encrypted note needs a new passphrase";
 
 function bindings() {
     const pkg = window.go?.app;
@@ -101,7 +110,8 @@ export async function saveSettings(settings: Partial<Settings>): Promise<void> {
         return;
     }
 
-    const merged: Settings = { ...DEFAULT_SETTINGS, ...settings } as Settings;
+    // 合并已保存的设置，避免覆盖前端未持有的字段（同步、周期笔记、拼写检查等）
+    const merged: Settings = { ...(await loadSettings()), ...settings } as Settings;
     try {
         await backend.SaveSettings(merged);
     } catch (error) {
@@ -122,6 +132,16 @@ export async function setActiveFile(path: string | null): Promise<void> {
     }
 }
 
+export async function getActiveFile(): Promise<string> {
+    const backend = bindings();
+    if (!backend?.ActiveFile) {
+        throw new Error("ActiveFile binding unavailable");
+    }
+
+    const result = await backend.ActiveFile();
+    return (result ?? "") as string;
+}
+
 export async function loadDirectoryEntries(
     path: string,
 ): Promise<DirectoryEntry[]> {
@@ -204,6 +224,717 @@ export async function loadDocument(path: string): Promise<string> {
     return (result ?? "") as string;
 }
 
+export async function unlockNote(
+    path: string,
+    passphrase: string,
+): Promise<string> {
+    const backend = bindings();
+    if (!backend?.UnlockNote) {
+        throw new Error("UnlockNote binding unavailable");
+    }
+
+    const result = await backend.UnlockNote(path, passphrase);
+    return (result ?? "") as string;
+}
+
+export async function lockAllNotes(): Promise<void> {
+    const backend = bindings();
+    if (!backend?.LockAllNotes) {
+        throw new Error("LockAllNotes binding unavailable");
+    }
+
+    await backend.LockAllNotes();
+}
+
+export async function createVault(
+    path: string,
+    passphrase: string,
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.CreateVault) {
+        throw new Error("CreateVault binding unavailable");
+    }
+
+    await backend.CreateVault(path, passphrase);
+}
+
+export async function unlockVault(
+    path: string,
+    passphrase: string,
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.UnlockVault) {
+        throw new Error("UnlockVault binding unavailable");
+    }
+
+    await backend.UnlockVault(path, passphrase);
+}
+
+export async function lockVault(path: string): Promise<void> {
+    const backend = bindings();
+    if (!backend?.LockVault) {
+        throw new Error("LockVault binding unavailable");
+    }
+
+    await backend.LockVault(path);
+}
+
+export async function rotateVaultKey(
+    path: string,
+    passphrase: string,
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.RotateVaultKey) {
+        throw new Error("RotateVaultKey binding unavailable");
+    }
+
+    await backend.RotateVaultKey(path, passphrase);
+}
+
+// 返回导出目录；取消时返回空字符串
+export async function exportVault(path: string): Promise<string> {
+    const backend = bindings();
+    if (!backend?.ExportVault) {
+        throw new Error("ExportVault binding unavailable");
+    }
+
+    return ((await backend.ExportVault(path)) ?? "") as string;
+}
+
+export interface NoteTemplate {
+    name: string;
+    path: string;
+    description?: string;
+    filename?: string;
+    prompts?: string[];
+    error?: string;
+}
+
+export interface CreatedNote {
+    path: string;
+    line: number;
+    column: number;
+}
+
+export async function listTemplates(): Promise<NoteTemplate[]> {
+    const backend = bindings();
+    if (!backend?.ListTemplates) {
+        throw new Error("ListTemplates binding unavailable");
+    }
+
+    return ((await backend.ListTemplates()) ?? []) as NoteTemplate[];
+}
+
+// targetDir 为空时在工作区根目录创建
+export async function createFromTemplate(
+    template: string,
+    targetDir: string,
+    vars: Record<string, string>,
+): Promise<CreatedNote> {
+    const backend = bindings();
+    if (!backend?.CreateFromTemplate) {
+        throw new Error("CreateFromTemplate binding unavailable");
+    }
+
+    return (await backend.CreateFromTemplate(
+        template,
+        targetDir,
+        vars,
+    )) as CreatedNote;
+}
+
+export interface LintFix {
+    line: number;
+    column: number;
+    endLine: number;
+    endColumn: number;
+    text: string;
+}
+
+export interface LintDiagnostic {
+    rule: string;
+    message: string;
+    severity: "error" | "warning" | "info";
+    line: number;
+    column: number;
+    endLine: number;
+    endColumn: number;
+    fix?: LintFix;
+}
+
+export async function lintDocument(
+    path: string,
+    content: string,
+): Promise<LintDiagnostic[]> {
+    const backend = bindings();
+    if (!backend?.LintDocument) {
+        throw new Error("LintDocument binding unavailable");
+    }
+
+    return ((await backend.LintDocument(path, content)) ?? []) as LintDiagnostic[];
+}
+
+export async function fixLintProblems(
+    path: string,
+    content: string,
+): Promise<string> {
+    const backend = bindings();
+    if (!backend?.FixLintProblems) {
+        throw new Error("FixLintProblems binding unavailable");
+    }
+
+    return (await backend.FixLintProblems(path, content)) as string;
+}
+
+export async function formatDocument(
+    path: string,
+    content: string,
+): Promise<string> {
+    const backend = bindings();
+    if (!backend?.FormatDocument) {
+        throw new Error("FormatDocument binding unavailable");
+    }
+
+    return (await backend.FormatDocument(path, content)) as string;
+}
+
+export async function formatSelection(
+    path: string,
+    content: string,
+    startLine: number,
+    endLine: number,
+): Promise<string> {
+    const backend = bindings();
+    if (!backend?.FormatSelection) {
+        throw new Error("FormatSelection binding unavailable");
+    }
+
+    return (await backend.FormatSelection(
+        path,
+        content,
+        startLine,
+        endLine,
+    )) as string;
+}
+
+export interface OutlineHeading {
+    level: number;
+    text: string;
+    line: number;
+    slug: string;
+    anchor: string;
+}
+
+export async function documentOutline(content: string): Promise<OutlineHeading[]> {
+    const backend = bindings();
+    if (!backend?.DocumentOutline) {
+        throw new Error("DocumentOutline binding unavailable");
+    }
+
+    return ((await backend.DocumentOutline(content)) ?? []) as OutlineHeading[];
+}
+
+export async function updateTOC(
+    path: string,
+    content: string,
+    line: number,
+): Promise<string> {
+    const backend = bindings();
+    if (!backend?.UpdateTOC) {
+        throw new Error("UpdateTOC binding unavailable");
+    }
+
+    return (await backend.UpdateTOC(path, content, line)) as string;
+}
+
+export interface SplitOptions {
+    level: number;
+    folder: string;
+    promote: boolean;
+    numbered: boolean;
+}
+
+export interface SplitResult {
+    index: string;
+    content: string;
+    files: string[];
+}
+
+export interface MergeOptions {
+    shift: number;
+    titles: boolean;
+    deleteSources: boolean;
+}
+
+export interface MergeResult {
+    path: string;
+    content: string;
+    deleted: string[];
+}
+
+export async function splitNote(
+    path: string,
+    content: string,
+    options: SplitOptions,
+): Promise<SplitResult> {
+    const backend = bindings();
+    if (!backend?.SplitNote) {
+        throw new Error("SplitNote binding unavailable");
+    }
+
+    return (await backend.SplitNote(path, content, options)) as SplitResult;
+}
+
+export async function mergeNotes(
+    paths: string[],
+    target: string,
+    options: MergeOptions,
+): Promise<MergeResult> {
+    const backend = bindings();
+    if (!backend?.MergeNotes) {
+        throw new Error("MergeNotes binding unavailable");
+    }
+
+    return (await backend.MergeNotes(paths, target, options)) as MergeResult;
+}
+
+export interface RenameOptions {
+    template: string;
+    regex: boolean;
+    pattern: string;
+    replacement: string;
+    sort: "name" | "title" | "modified";
+    start: number;
+    allFiles: boolean;
+}
+
+export interface RenameItem {
+    path: string;
+    newPath: string;
+    name: string;
+    newName: string;
+    title: string;
+    conflict?: string;
+}
+
+export interface RenamePreview {
+    items: RenameItem[];
+    conflicts: number;
+}
+
+export interface RenameResult {
+    renamed: RenameItem[];
+    updated: string[];
+    links: number;
+}
+
+export async function previewBatchRename(
+    folder: string,
+    options: RenameOptions,
+): Promise<RenamePreview> {
+    const backend = bindings();
+    if (!backend?.PreviewBatchRename) {
+        throw new Error("PreviewBatchRename binding unavailable");
+    }
+
+    return (await backend.PreviewBatchRename(folder, options)) as RenamePreview;
+}
+
+export async function batchRename(items: RenameItem[]): Promise<RenameResult> {
+    const backend = bindings();
+    if (!backend?.BatchRename) {
+        throw new Error("BatchRename binding unavailable");
+    }
+
+    return (await backend.BatchRename(items)) as RenameResult;
+}
+
+export type ConflictPolicy = "skip" | "overwrite" | "keepBoth";
+
+export interface TransferProgress {
+    phase: string;
+    path?: string;
+    done: number;
+    total: number;
+}
+
+export interface TransferItem {
+    from: string;
+    to: string;
+}
+
+export interface TransferResult {
+    items: TransferItem[];
+    files: number;
+    skipped: string[];
+    moved: TransferItem[];
+    error?: string;
+}
+
+export async function copyItems(
+    sources: string[],
+    destination: string,
+    policy: ConflictPolicy,
+): Promise<TransferResult> {
+    const backend = bindings();
+    if (!backend?.CopyItems) {
+        throw new Error("CopyItems binding unavailable");
+    }
+
+    return (await backend.CopyItems(sources, destination, policy)) as TransferResult;
+}
+
+export async function moveItems(
+    sources: string[],
+    destination: string,
+    policy: ConflictPolicy,
+): Promise<TransferResult> {
+    const backend = bindings();
+    if (!backend?.MoveItems) {
+        throw new Error("MoveItems binding unavailable");
+    }
+
+    return (await backend.MoveItems(sources, destination, policy)) as TransferResult;
+}
+
+export async function duplicateItem(path: string): Promise<TransferResult> {
+    const backend = bindings();
+    if (!backend?.DuplicateItem) {
+        throw new Error("DuplicateItem binding unavailable");
+    }
+
+    return (await backend.DuplicateItem(path)) as TransferResult;
+}
+
+export async function cancelTransfer(): Promise<void> {
+    const backend = bindings();
+    if (!backend?.CancelTransfer) {
+        throw new Error("CancelTransfer binding unavailable");
+    }
+
+    await backend.CancelTransfer();
+}
+
+export async function chooseFolder(title: string, directory: string): Promise<string> {
+    const backend = bindings();
+    if (!backend?.ChooseFolder) {
+        throw new Error("ChooseFolder binding unavailable");
+    }
+
+    return (await backend.ChooseFolder(title, directory)) as string;
+}
+
+export interface ReplaceOptions {
+    query: string;
+    replacement: string;
+    regex: boolean;
+    caseSensitive: boolean;
+    wholeWord: boolean;
+    include: string[];
+    exclude: string[];
+    allFiles: boolean;
+    maxResults: number;
+}
+
+export interface ReplaceChange {
+    offset: number;
+    line: number;
+    column: number;
+    length: number;
+    match: string;
+    replacement: string;
+    text: string;
+    preview: string;
+}
+
+export interface ReplaceFile {
+    path: string;
+    relPath: string;
+    hash: string;
+    changes: ReplaceChange[];
+}
+
+export interface ReplacePreview {
+    files: ReplaceFile[];
+    changes: number;
+}
+
+export interface ReplaceSelection {
+    path: string;
+    hash: string;
+    offsets: number[];
+}
+
+export interface ReplaceResult {
+    files: string[];
+    changes: number;
+}
+
+export async function previewReplaceInWorkspace(options: ReplaceOptions): Promise<ReplacePreview> {
+    const backend = bindings();
+    if (!backend?.PreviewReplaceInWorkspace) {
+        throw new Error("PreviewReplaceInWorkspace binding unavailable");
+    }
+
+    return (await backend.PreviewReplaceInWorkspace(options)) as ReplacePreview;
+}
+
+export async function replaceInWorkspace(
+    options: ReplaceOptions,
+    selections: ReplaceSelection[],
+): Promise<ReplaceResult> {
+    const backend = bindings();
+    if (!backend?.ReplaceInWorkspace) {
+        throw new Error("ReplaceInWorkspace binding unavailable");
+    }
+
+    return (await backend.ReplaceInWorkspace(options, selections)) as ReplaceResult;
+}
+
+export async function undoReplaceInWorkspace(): Promise<ReplaceResult> {
+    const backend = bindings();
+    if (!backend?.UndoReplaceInWorkspace) {
+        throw new Error("UndoReplaceInWorkspace binding unavailable");
+    }
+
+    return (await backend.UndoReplaceInWorkspace()) as ReplaceResult;
+}
+
+export async function canUndoReplaceInWorkspace(): Promise<boolean> {
+    const backend = bindings();
+    if (!backend?.CanUndoReplaceInWorkspace) {
+        return false;
+    }
+
+    return (await backend.CanUndoReplaceInWorkspace()) as boolean;
+}
+
+export interface SpellcheckSettings {
+    enabled: boolean;
+    languages?: string[];
+}
+
+export interface SpellingError {
+    word: string;
+    line: number;
+    column: number;
+    endLine: number;
+    endColumn: number;
+    suggestions: string[];
+}
+
+export interface SpellcheckState {
+    enabled: boolean;
+    languages: string[];
+    available: string[];
+    personalWords: string[];
+    dictionaryDir: string;
+}
+
+export async function checkSpelling(
+    path: string,
+    content: string,
+): Promise<SpellingError[]> {
+    const backend = bindings();
+    if (!backend?.CheckSpelling) {
+        throw new Error("CheckSpelling binding unavailable");
+    }
+
+    return ((await backend.CheckSpelling(path, content)) ?? []) as SpellingError[];
+}
+
+export async function addToDictionary(
+    word: string,
+    workspace: boolean,
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.AddToDictionary) {
+        throw new Error("AddToDictionary binding unavailable");
+    }
+
+    await backend.AddToDictionary(word, workspace);
+}
+
+export async function loadSpellcheckSettings(): Promise<SpellcheckState> {
+    const backend = bindings();
+    if (!backend?.LoadSpellcheckSettings) {
+        throw new Error("LoadSpellcheckSettings binding unavailable");
+    }
+
+    return (await backend.LoadSpellcheckSettings()) as SpellcheckState;
+}
+
+export async function saveSpellcheckSettings(
+    state: SpellcheckState,
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.SaveSpellcheckSettings) {
+        throw new Error("SaveSpellcheckSettings binding unavailable");
+    }
+
+    await backend.SaveSpellcheckSettings(state);
+}
+
+export interface DocumentStats {
+    words: number;
+    cjkCharacters: number;
+    characters: number;
+    charactersNoSpaces: number;
+    paragraphs: number;
+    readingMinutes: number;
+    headings: number;
+    links: number;
+    images: number;
+    codeBlocks: number;
+}
+
+export interface NoteWords {
+    path: string;
+    relPath: string;
+    words: number;
+}
+
+export interface WorkspaceStats {
+    notes: number;
+    totals: DocumentStats;
+    longest: NoteWords[];
+}
+
+export interface DailyWords {
+    date: string;
+    words: number;
+}
+
+export interface WritingProgress {
+    goal: number;
+    today: number;
+    streak: number;
+    days: DailyWords[];
+}
+
+export async function documentStats(content: string): Promise<DocumentStats> {
+    const backend = bindings();
+    if (!backend?.DocumentStats) {
+        throw new Error("DocumentStats binding unavailable");
+    }
+
+    return (await backend.DocumentStats(content)) as DocumentStats;
+}
+
+export async function workspaceStats(): Promise<WorkspaceStats> {
+    const backend = bindings();
+    if (!backend?.WorkspaceStats) {
+        throw new Error("WorkspaceStats binding unavailable");
+    }
+
+    return (await backend.WorkspaceStats()) as WorkspaceStats;
+}
+
+export async function writingProgress(days: number): Promise<WritingProgress> {
+    const backend = bindings();
+    if (!backend?.WritingProgress) {
+        throw new Error("WritingProgress binding unavailable");
+    }
+
+    return (await backend.WritingProgress(days)) as WritingProgress;
+}
+
+export async function setWritingGoal(goal: number): Promise<void> {
+    const backend = bindings();
+    if (!backend?.SetWritingGoal) {
+        throw new Error("SetWritingGoal binding unavailable");
+    }
+
+    await backend.SetWritingGoal(goal);
+}
+
+export type PeriodicKind = "daily" | "weekly" | "monthly" | "yearly";
+
+export interface PeriodicNoteConfig {
+    folder: string;
+    format: string;
+    template?: string;
+}
+
+export interface PeriodicSettings {
+    daily: PeriodicNoteConfig;
+    weekly: PeriodicNoteConfig;
+    monthly: PeriodicNoteConfig;
+    yearly: PeriodicNoteConfig;
+}
+
+export interface PeriodicNote {
+    kind: PeriodicKind;
+    date: string;
+    path: string;
+    words: number;
+}
+
+export interface PeriodicCalendar {
+    year: number;
+    month: number;
+    days: PeriodicNote[];
+    weeks: PeriodicNote[];
+    monthNote?: string;
+}
+
+export async function loadPeriodicSettings(): Promise<PeriodicSettings> {
+    const backend = bindings();
+    if (!backend?.LoadPeriodicSettings) {
+        throw new Error("LoadPeriodicSettings binding unavailable");
+    }
+
+    return (await backend.LoadPeriodicSettings()) as PeriodicSettings;
+}
+
+export async function savePeriodicSettings(
+    settings: PeriodicSettings,
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.SavePeriodicSettings) {
+        throw new Error("SavePeriodicSettings binding unavailable");
+    }
+
+    await backend.SavePeriodicSettings(settings);
+}
+
+// date 为 YYYY-MM-DD，为空时表示今天；笔记不存在时按模板创建
+export async function openPeriodicNote(
+    kind: PeriodicKind,
+    date = "",
+): Promise<CreatedNote> {
+    const backend = bindings();
+    if (!backend?.OpenPeriodicNote) {
+        throw new Error("OpenPeriodicNote binding unavailable");
+    }
+
+    return (await backend.OpenPeriodicNote(kind, date)) as CreatedNote;
+}
+
+export async function adjacentPeriodicNote(
+    path: string,
+    offset: number,
+): Promise<string> {
+    const backend = bindings();
+    if (!backend?.AdjacentPeriodicNote) {
+        throw new Error("AdjacentPeriodicNote binding unavailable");
+    }
+
+    return (await backend.AdjacentPeriodicNote(path, offset)) as string;
+}
+
+export async function loadPeriodicCalendar(
+    year: number,
+    month: number,
+): Promise<PeriodicCalendar> {
+    const backend = bindings();
+    if (!backend?.PeriodicCalendar) {
+        throw new Error("PeriodicCalendar binding unavailable");
+    }
+
+    return (await backend.PeriodicCalendar(year, month)) as PeriodicCalendar;
+}
+
 export async function createFile(path: string): Promise<boolean> {
     const backend = bindings();
     if (!backend?.CreateFile) {
@@ -276,3 +1007,387 @@ export async function renameFile(oldPath: string, newPath: string): Promise<bool
         return false;
     }
 }
+
+export interface QueryResult {
+    columns: string[];
+    rows: Array<{ path: string; values: unknown[] }>;
+}
+
+export async function runQuery(query: string): Promise<QueryResult> {
+    const backend = bindings();
+    if (!backend?.RunQuery) {
+        throw new Error("RunQuery binding unavailable");
+    }
+
+    const result = await backend.RunQuery(query);
+    return {
+        columns: Array.isArray(result?.columns) ? result.columns : [],
+        rows: Array.isArray(result?.rows) ? result.rows : [],
+    };
+}
+
+export interface TaskItem {
+    path: string;
+    relPath: string;
+    line: number;
+    raw: string;
+    text: string;
+    done: boolean;
+    heading: string;
+    due: string;
+    priority: string;
+    tags: string[];
+}
+
+export interface TaskFilter {
+    status?: "all" | "open" | "done";
+    folder?: string;
+    tag?: string;
+    priority?: string;
+    dueBefore?: string;
+    dueAfter?: string;
+    text?: string;
+}
+
+export async function queryTasks(filter: TaskFilter = {}): Promise<TaskItem[]> {
+    const backend = bindings();
+    if (!backend?.QueryTasks) {
+        return [];
+    }
+
+    try {
+        const result = await backend.QueryTasks(filter);
+        return Array.isArray(result) ? (result as TaskItem[]) : [];
+    } catch (error) {
+        console.warn("QueryTasks failed", error);
+        return [];
+    }
+}
+
+export async function toggleTask(path: string, line: number): Promise<TaskItem> {
+    const backend = bindings();
+    if (!backend?.ToggleTask) {
+        throw new Error("ToggleTask binding unavailable");
+    }
+
+    return (await backend.ToggleTask(path, line)) as TaskItem;
+}
+
+export async function notifyDocumentOpened(path: string): Promise<void> {
+    const backend = bindings();
+    if (!backend?.NotifyDocumentOpened) {
+        return;
+    }
+
+    try {
+        await backend.NotifyDocumentOpened(path);
+    } catch (error) {
+        console.warn("NotifyDocumentOpened failed", error);
+    }
+}
+
+export async function notifyDocumentClosed(path: string): Promise<void> {
+    const backend = bindings();
+    if (!backend?.NotifyDocumentClosed) {
+        return;
+    }
+
+    try {
+        await backend.NotifyDocumentClosed(path);
+    } catch (error) {
+        console.warn("NotifyDocumentClosed failed", error);
+    }
+}
+
+// 告知后端哪些打开的文档有未保存修改，改写磁盘文件的操作据此拒绝执行
+export async function setDirtyDocuments(paths: string[]): Promise<void> {
+    const backend = bindings();
+    if (!backend?.SetDirtyDocuments) {
+        return;
+    }
+
+    try {
+        await backend.SetDirtyDocuments(paths);
+    } catch (error) {
+        console.warn("SetDirtyDocuments failed", error);
+    }
+}
+
+export async function completeRPCRequest(
+    id: string,
+    result: unknown,
+    errorMessage = "",
+): Promise<void> {
+    const backend = bindings();
+    if (!backend?.CompleteRPCRequest) {
+        return;
+    }
+
+    const payload = errorMessage ? "" : JSON.stringify(result ?? null);
+    await backend.CompleteRPCRequest(id, payload, errorMessage);
+}
+
+export interface GitStatus {
+    root: string;
+    branch: string;
+    detached: boolean;
+    upstream: string;
+    ahead: number;
+    behind: number;
+    files: Record<string, string>;
+}
+
+export async function refreshGitStatus(): Promise<GitStatus | null> {
+    const backend = bindings();
+    if (!backend?.RefreshGitStatus) {
+        return null;
+    }
+
+    try {
+        return ((await backend.RefreshGitStatus()) as GitStatus | null) ?? null;
+    } catch (error) {
+        console.warn("RefreshGitStatus failed", error);
+        return null;
+    }
+}
+
+export interface GitCommit {
+    hash: string;
+    shortHash: string;
+    author: string;
+    email: string;
+    date: string;
+    subject: string;
+}
+
+export interface GitDiffLine {
+    kind: "context" | "add" | "delete";
+    oldLine: number;
+    newLine: number;
+    text: string;
+}
+
+export interface GitDiffHunk {
+    header: string;
+    lines: GitDiffLine[];
+}
+
+export interface GitDiffRow {
+    left: GitDiffLine | null;
+    right: GitDiffLine | null;
+}
+
+export interface GitDiff {
+    path: string;
+    unified: string;
+    hunks: GitDiffHunk[];
+    sideBySide: GitDiffRow[];
+}
+
+export interface GitSyncResult {
+    committed: boolean;
+    hash: string;
+    pulled: boolean;
+    pushed: boolean;
+    message: string;
+}
+
+function gitBinding(name: string) {
+    const backend = bindings();
+    const method = backend?.[name];
+    if (!method) {
+        throw new Error(`${name} binding unavailable`);
+    }
+    return method;
+}
+
+export async function gitStage(paths: string[]): Promise<void> {
+    await gitBinding("GitStage")(paths);
+}
+
+export async function gitUnstage(paths: string[]): Promise<void> {
+    await gitBinding("GitUnstage")(paths);
+}
+
+export async function gitCommit(message: string): Promise<string> {
+    return (await gitBinding("GitCommit")(message)) as string;
+}
+
+export async function gitDiff(path: string): Promise<GitDiff> {
+    return (await gitBinding("GitDiff")(path)) as GitDiff;
+}
+
+export async function gitLog(path: string, limit = 100): Promise<GitCommit[]> {
+    const result = await gitBinding("GitLog")(path, limit);
+    return Array.isArray(result) ? (result as GitCommit[]) : [];
+}
+
+export async function gitShowRevision(path: string, revision: string): Promise<string> {
+    return (await gitBinding("GitShowRevision")(path, revision)) as string;
+}
+
+export async function gitQuickSync(message = ""): Promise<GitSyncResult> {
+    return (await gitBinding("GitQuickSync")(message)) as GitSyncResult;
+}
+
+export interface SyncTarget {
+    workspace: string;
+    url: string;
+    username: string;
+    password?: string;
+    intervalMinutes: number;
+    syncOnSave: boolean;
+}
+
+export interface SyncProgress {
+    phase: "scanning" | "syncing" | "done";
+    path?: string;
+    done: number;
+    total: number;
+}
+
+export interface SyncReport {
+    uploaded: string[] | null;
+    downloaded: string[] | null;
+    deletedLocal: string[] | null;
+    deletedRemote: string[] | null;
+    conflicts: string[] | null;
+    skipped: string[] | null;
+    errors: string[] | null;
+    startedAt: string;
+    finishedAt: string;
+}
+
+export async function loadSyncTarget(): Promise<SyncTarget> {
+    const backend = bindings();
+    if (!backend?.LoadSyncTarget) {
+        throw new Error("LoadSyncTarget binding unavailable");
+    }
+
+    return (await backend.LoadSyncTarget()) as SyncTarget;
+}
+
+export async function saveSyncTarget(target: SyncTarget): Promise<void> {
+    const backend = bindings();
+    if (!backend?.SaveSyncTarget) {
+        throw new Error("SaveSyncTarget binding unavailable");
+    }
+
+    await backend.SaveSyncTarget(target);
+}
+
+export async function syncNow(): Promise<SyncReport> {
+    const backend = bindings();
+    if (!backend?.SyncNow) {
+        throw new Error("SyncNow binding unavailable");
+    }
+
+    return (await backend.SyncNow()) as SyncReport;
+}
+
+export interface BackupRetention {
+    keepLast: number;
+    keepDaily: number;
+    keepWeekly: number;
+    keepMonthly: number;
+}
+
+export interface BackupTarget {
+    workspace: string;
+    endpoint: string;
+    region: string;
+    bucket: string;
+    prefix: string;
+    accessKey: string;
+    secretKey?: string;
+    intervalHours: number;
+    retention: BackupRetention;
+}
+
+export interface BackupSummary {
+    id: string;
+    time: string;
+    host: string;
+    files: number;
+    size: number;
+}
+
+export interface BackupFile {
+    path: string;
+    size: number;
+    mode: number;
+    modTime: string;
+    chunks: string[];
+}
+
+export interface BackupProgress {
+    phase: "scanning" | "uploading" | "restoring" | "done";
+    path?: string;
+    done: number;
+    total: number;
+}
+
+export interface BackupReport {
+    snapshot: BackupSummary;
+    newChunks: number;
+    reusedChunks: number;
+    uploadedBytes: number;
+    pruned: string[] | null;
+}
+
+export async function loadBackupTarget(): Promise<BackupTarget> {
+    const backend = bindings();
+    if (!backend?.LoadBackupTarget) {
+        throw new Error("LoadBackupTarget binding unavailable");
+    }
+
+    return (await backend.LoadBackupTarget()) as BackupTarget;
+}
+
+export async function saveBackupTarget(target: BackupTarget): Promise<void> {
+    const backend = bindings();
+    if (!backend?.SaveBackupTarget) {
+        throw new Error("SaveBackupTarget binding unavailable");
+    }
+
+    await backend.SaveBackupTarget(target);
+}
+
+export async function backupNow(): Promise<BackupReport> {
+    const backend = bindings();
+    if (!backend?.BackupNow) {
+        throw new Error("BackupNow binding unavailable");
+    }
+
+    return (await backend.BackupNow()) as BackupReport;
+}
+
+export async function listBackups(): Promise<BackupSummary[]> {
+    const backend = bindings();
+    if (!backend?.ListBackups) {
+        throw new Error("ListBackups binding unavailable");
+    }
+
+    const result = await backend.ListBackups();
+    return Array.isArray(result) ? (result as BackupSummary[]) : [];
+}
+
+export async function listBackupFiles(snapshotId: string): Promise<BackupFile[]> {
+    const backend = bindings();
+    if (!backend?.ListBackupFiles) {
+        throw new Error("ListBackupFiles binding unavailable");
+    }
+
+    const result = await backend.ListBackupFiles(snapshotId);
+    return Array.isArray(result) ? (result as BackupFile[]) : [];
+}
+
+export async function restoreBackup(snapshotId: string, path: string): Promise<string[]> {
+    const backend = bindings();
+    if (!backend?.RestoreBackup) {
+        throw new Error("RestoreBackup binding unavailable");
+    }
+
+    const result = await backend.RestoreBackup(snapshotId, path);
+    return Array.isArray(result) ? (result as string[]) : [];
+}
@@ -4,8 +4,9 @@ import (
 	"context"
 	"errors"
 	"log"
-	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/menu"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -18,10 +19,14 @@ type App struct {
 	ctx      context.Context
 	files    *services.FileService
 	settings *services.SettingsService
+	// credentials kept out of settings.json
+	secrets  *services.SecretStore
+	index    *services.WorkspaceIndex
 	saveMenu *menu.MenuItem
 
 	currentFilePath       string
 	currentFolderPath     string
+	workspaceRoot         string
 	editorThemeMenuItems  map[string]*menu.MenuItem
 	previewThemeMenuItems map[string]*menu.MenuItem
 	toolbarThemeMenuItems map[string]*menu.MenuItem
@@ -29,22 +34,74 @@ type App struct {
 	currentPreviewTheme   string
 	currentToolbarTheme   string
 
-	// file to open on startup (from shell association)
-	startupOpenPath string
-
-	// whether frontend has reported editor ready
-	editorReady bool
+	// launch requests (command line, shell association or forwarded instances)
+	// queued until the frontend has reported editor ready
+	launchMu        sync.Mutex
+	pendingLaunches []LaunchRequest
+	editorReady     bool
+	waiters         documentWaiters
+
+	// open documents with unsaved changes, as reported by the frontend
+	dirty dirtyDocuments
+
+	// scripting requests forwarded to the frontend, keyed by request id
+	rpcMu      sync.Mutex
+	rpcSeq     uint64
+	rpcPending map[string]chan rpcFrontendReply
+
+	// git status of the repository containing the workspace
+	gitMu      sync.Mutex
+	gitStatus  *services.GitStatus
+	gitRefresh *time.Timer
+
+	// WebDAV sync of the workspace; syncCancel is set while a sync runs
+	syncMu        sync.Mutex
+	syncTarget    *services.SyncTarget
+	syncTimer     *time.Timer
+	syncSaveTimer *time.Timer
+	syncCancel    context.CancelFunc
+
+	// S3 backups of the workspace; backupCancel is set while a backup or
+	// restore runs
+	backupMu     sync.Mutex
+	backupTarget *services.BackupTarget
+	backupTimer  *time.Timer
+	backupCancel context.CancelFunc
+
+	// copy or move of files in the tree; transferCancel is set while one runs
+	transferMu     sync.Mutex
+	transferCancel context.CancelFunc
+
+	// keys of unlocked encrypted notes, held only in memory
+	notes *services.NoteKeyring
+
+	// Hunspell dictionaries of the settings directory, loaded on first use
+	spell *services.SpellChecker
+
+	// words written per day in each workspace, for writing goals
+	writing *services.WritingHistory
+
+	// undo point of the last workspace replacement
+	replaceMu   sync.Mutex
+	replaceUndo *services.ReplaceUndo
 
 	// single instance manager
 	singleInstance *SingleInstanceManager
+	newWindow      bool
 }
 
 // New constructs the application bindings.
 func New() *App {
+	files := services.NewFileService()
 	app := &App{
-		files:    services.NewFileService(),
+		files:    files,
 		settings: services.NewSettingsService(),
+		index:    services.NewWorkspaceIndex(files),
 	}
+	app.notes = services.NewNoteKeyring(defaultNoteKeyTimeout, app.noteKeyExpired)
+	app.secrets = services.NewSecretStore(app.settings.Dir())
+	app.spell = services.NewSpellChecker(app.settings.Dir())
+	app.writing = services.NewWritingHistory(app.settings.Dir())
 	app.singleInstance = NewSingleInstanceManager("MarkdownDaoNote", app)
 	return app
 }
@@ -53,28 +110,18 @@ func New() *App {
 func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
 
-	// 检查单实例模式
-	isSecondary, err := a.singleInstance.CheckAndHandleSingleInstance(ctx)
-	if err != nil {
-		log.Printf("Single instance check failed: %v", err)
-	}
-
-	if isSecondary {
-		// 如果是次要实例，退出
-		log.Println("Secondary instance detected, exiting...")
-		os.Exit(0)
+	// 主实例监听后续实例转发的启动参数
+	if !a.newWindow {
+		if err := a.singleInstance.Listen(ctx); err != nil {
+			log.Printf("Single instance listener failed: %v", err)
+		}
 	}
 
 	// listen for editor ready from frontend
 	runtime.EventsOn(a.ctx, "editor:ready", func(_ ...interface{}) {
-		a.editorReady = true
-		// if there is a pending startup file, send it now
-		log.Printf("Event on editor:ready, startupOpenPath: %s", a.startupOpenPath)
-		if a.startupOpenPath != "" {
-			runtime.EventsEmit(a.ctx, "open-file", a.startupOpenPath)
-			// only emit once for this startup file
-			a.startupOpenPath = ""
-		}
+		// if there are pending launch requests, send them now
+		log.Println("Event on editor:ready, dispatching pending launch requests")
+		a.markEditorReady()
 	})
 }
 
@@ -86,6 +133,12 @@ func (a *App) DomReady(ctx context.Context) {
 // Shutdown is called when the app terminates.
 func (a *App) Shutdown(ctx context.Context) {
 	_ = ctx
+	a.waiters.releaseAll()
+	a.stopSync()
+	a.stopBackup()
+	a.CancelTransfer()
+	a.notes.LockAll()
+	a.files.UnmountAllVaults()
 	// 清理单实例管理器
 	if a.singleInstance != nil {
 		if err := a.singleInstance.Close(); err != nil {
@@ -94,13 +147,20 @@ func (a *App) Shutdown(ctx context.Context) {
 	}
 }
 
-// LoadFile reads a markdown file from disk.
+// ForwardToExistingInstance hands the launch request to an already running
+// instance. It returns true when the current process should exit.
+func (a *App) ForwardToExistingInstance(request LaunchRequest) (bool, error) {
+	return a.singleInstance.Forward(request)
+}
+
+// LoadFile reads a markdown file from disk. Encrypted notes fail with
+// services.ErrNoteLocked until UnlockNote has been called for them.
 func (a *App) LoadFile(path string) (string, error) {
 	if path == "" {
 		return "", errors.New("path is required")
 	}
 
-	content, err := a.files.Read(path)
+	content, err := a.readNote(path)
 	if err != nil {
 		return "", err
 	}
@@ -115,11 +175,13 @@ func (a *App) SaveFile(path string, content string) error {
 		return errors.New("path is required")
 	}
 
-	if err := a.files.Write(path, content); err != nil {
+	if err := a.writeNote(path, content); err != nil {
 		return err
 	}
 
 	a.setCurrentFile(path)
+	a.refreshIndexedFile(path)
+	a.filesChanged()
 	return nil
 }
 
@@ -133,9 +195,10 @@ func (a *App) SetActiveFile(path string) {
 	a.setCurrentFile(path)
 }
 
-// SetStartupOpenPath sets a file path passed from OS shell to open on launch.
-func (a *App) SetStartupOpenPath(path string) {
-	a.startupOpenPath = path
+// ActiveFile returns the current file path, e.g. the target picked in a
+// save dialog that is waiting for an encrypted note's passphrase.
+func (a *App) ActiveFile() string {
+	return a.currentFilePath
 }
 
 // OpenFileDialog prompts the user to select one or more files to open.
@@ -201,6 +264,7 @@ func (a *App) CreateFile(path string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	a.filesChanged()
 
 	return true, nil
 }
@@ -229,6 +293,9 @@ func (a *App) DeleteFile(path string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	a.index.Remove(path)
+	a.notes.LockTree(path)
+	a.filesChanged()
 
 	return true, nil
 }
@@ -243,6 +310,10 @@ func (a *App) RenameFile(oldPath, newPath string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	a.index.Remove(oldPath)
+	a.notes.MoveTree(oldPath, newPath)
+	a.refreshIndexedFile(newPath)
+	a.filesChanged()
 
 	return true, nil
 }
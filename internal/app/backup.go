@@ -0,0 +1,323 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const (
+	eventBackupProgress = "backup:progress"
+	eventBackupFinished = "backup:finished"
+	eventBackupFailed   = "backup:failed"
+)
+
+var errBackupRunning = errors.New("a backup or restore is already running")
+
+// LoadBackupTarget returns the S3 backup configuration of the opened
+// workspace. An unconfigured workspace yields a target with only Workspace set.
+func (a *App) LoadBackupTarget() (services.BackupTarget, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return services.BackupTarget{}, errors.New("no folder is open")
+	}
+	target, ok, err := a.loadBackupTarget(root)
+	if err != nil || ok {
+		return target, err
+	}
+	return services.BackupTarget{Workspace: root, Prefix: filepath.Base(root)}, nil
+}
+
+// SaveBackupTarget stores the backup configuration of the opened workspace
+// and reschedules backups. An empty endpoint removes the configuration.
+func (a *App) SaveBackupTarget(target services.BackupTarget) error {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return errors.New("no folder is open")
+	}
+	target.Workspace = root
+	target.Endpoint = strings.TrimSpace(target.Endpoint)
+	if target.IntervalHours < 0 {
+		target.IntervalHours = 0
+	}
+	if target.Endpoint != "" {
+		if _, err := services.NewBackupStore(target); err != nil {
+			return err
+		}
+	} else {
+		target.SecretKey = ""
+	}
+
+	settings, err := a.settings.Load()
+	if err != nil {
+		return err
+	}
+	if err := a.storeBackupTarget(settings, target); err != nil {
+		return err
+	}
+
+	a.configureBackup(root)
+	return nil
+}
+
+// loadBackupTarget returns the configuration of root with its secret key
+// from the credential store. A key still saved in settings.json by an older
+// version is moved to the store.
+func (a *App) loadBackupTarget(root string) (services.BackupTarget, bool, error) {
+	settings, err := a.settings.Load()
+	if err != nil {
+		return services.BackupTarget{}, false, err
+	}
+	target, ok := findBackupTarget(settings.BackupTargets, root)
+	if !ok {
+		return target, false, nil
+	}
+	if target.SecretKey != "" {
+		if err := a.storeBackupTarget(settings, target); err != nil {
+			// 无法迁移时沿用 settings.json 中的密钥
+			log.Printf("Failed to move the backup secret key of %s: %v", root, err)
+		}
+		return target, true, nil
+	}
+	target.SecretKey, err = a.secrets.Get(backupSecretName(root))
+	if err != nil {
+		return target, true, fmt.Errorf("cannot read the backup secret key: %w", err)
+	}
+	return target, true, nil
+}
+
+// storeBackupTarget replaces the configuration of target.Workspace in
+// settings, or removes it when the endpoint is empty. The secret key goes to
+// the credential store, never to settings.json.
+func (a *App) storeBackupTarget(settings services.Settings, target services.BackupTarget) error {
+	if err := a.secrets.Set(backupSecretName(target.Workspace), target.SecretKey); err != nil {
+		return fmt.Errorf("cannot store the backup secret key: %w", err)
+	}
+	target.SecretKey = ""
+
+	targets := []services.BackupTarget{}
+	for _, existing := range settings.BackupTargets {
+		if waitKey(existing.Workspace) != waitKey(target.Workspace) {
+			targets = append(targets, existing)
+		}
+	}
+	if target.Endpoint != "" {
+		targets = append(targets, target)
+	}
+	settings.BackupTargets = targets
+	return a.settings.Save(settings)
+}
+
+func backupSecretName(root string) string {
+	return "backup:" + waitKey(root)
+}
+
+// BackupNow snapshots the opened workspace and applies the retention policy.
+func (a *App) BackupNow() (*services.BackupReport, error) {
+	target, err := a.currentBackupTarget()
+	if err != nil {
+		return nil, err
+	}
+	return a.runBackup(target)
+}
+
+// ListBackups lists the snapshots of the opened workspace, newest first.
+func (a *App) ListBackups() ([]services.BackupSummary, error) {
+	target, err := a.currentBackupTarget()
+	if err != nil {
+		return nil, err
+	}
+	store, err := services.NewBackupStore(target)
+	if err != nil {
+		return nil, err
+	}
+	return store.Snapshots(context.Background())
+}
+
+// ListBackupFiles lists the files stored in a snapshot.
+func (a *App) ListBackupFiles(snapshotID string) ([]services.BackupFile, error) {
+	target, err := a.currentBackupTarget()
+	if err != nil {
+		return nil, err
+	}
+	store, err := services.NewBackupStore(target)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := store.Snapshot(context.Background(), snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Files, nil
+}
+
+// RestoreBackup restores a note or folder (relative to the workspace; empty
+// for everything) from a snapshot, overwriting the current files. It refuses
+// while a tab below rel has unsaved changes and returns the absolute paths
+// written.
+func (a *App) RestoreBackup(snapshotID string, rel string) ([]string, error) {
+	target, err := a.currentBackupTarget()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkNotDirty("restoring", filepath.Join(target.Workspace, filepath.FromSlash(rel))); err != nil {
+		return nil, err
+	}
+	store, err := services.NewBackupStore(target)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := a.beginBackupTask()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	restored, err := store.Restore(ctx, snapshotID, rel, target.Workspace, a.emitBackupProgress)
+	paths := make([]string, 0, len(restored))
+	for _, file := range restored {
+		paths = append(paths, filepath.Join(target.Workspace, filepath.FromSlash(file)))
+	}
+	if len(paths) > 0 {
+		a.reindexWorkspace(target.Workspace)
+		a.scheduleGitRefresh()
+	}
+	return paths, err
+}
+
+func (a *App) currentBackupTarget() (services.BackupTarget, error) {
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+	if a.backupTarget == nil {
+		return services.BackupTarget{}, errors.New("backup is not configured for this folder")
+	}
+	return *a.backupTarget, nil
+}
+
+// configureBackup loads the backup target of root and (re)starts its schedule.
+func (a *App) configureBackup(root string) {
+	var target *services.BackupTarget
+	if found, ok, err := a.loadBackupTarget(root); err != nil {
+		log.Printf("Failed to load the backup target of %s: %v", root, err)
+	} else if ok {
+		target = &found
+	}
+
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+	if a.backupTimer != nil {
+		a.backupTimer.Stop()
+		a.backupTimer = nil
+	}
+	a.backupTarget = target
+	if target != nil && target.IntervalHours > 0 {
+		a.backupTimer = time.AfterFunc(time.Duration(target.IntervalHours)*time.Hour, a.runScheduledBackup)
+	}
+}
+
+func (a *App) runScheduledBackup() {
+	a.backupMu.Lock()
+	target := a.backupTarget
+	a.backupMu.Unlock()
+	if target == nil {
+		return
+	}
+
+	if _, err := a.runBackup(*target); err != nil && !errors.Is(err, errBackupRunning) {
+		log.Printf("Backup of %s failed: %v", target.Workspace, err)
+	}
+
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+	// 期间配置可能已更换
+	if a.backupTarget == target && target.IntervalHours > 0 {
+		a.backupTimer = time.AfterFunc(time.Duration(target.IntervalHours)*time.Hour, a.runScheduledBackup)
+	}
+}
+
+// runBackup uploads a snapshot, prunes old ones and reports the outcome as events.
+func (a *App) runBackup(target services.BackupTarget) (*services.BackupReport, error) {
+	store, err := services.NewBackupStore(target)
+	if err != nil {
+		return nil, err
+	}
+	ctx, done, err := a.beginBackupTask()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report, err := store.Backup(ctx, target.Workspace, a.emitBackupProgress)
+	if err == nil {
+		// 快照已保存，清理失败只记录日志
+		if report.Pruned, err = store.Prune(ctx, target.Retention); err != nil {
+			log.Printf("Failed to prune backups of %s: %v", target.Workspace, err)
+			err = nil
+		}
+	}
+	if err != nil {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, eventBackupFailed, err.Error())
+		}
+		return nil, err
+	}
+
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventBackupFinished, target.Workspace, report)
+	}
+	return report, nil
+}
+
+// beginBackupTask allows one backup or restore at a time.
+func (a *App) beginBackupTask() (context.Context, func(), error) {
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+	if a.backupCancel != nil {
+		return nil, nil, errBackupRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.backupCancel = cancel
+	return ctx, func() {
+		cancel()
+		a.backupMu.Lock()
+		a.backupCancel = nil
+		a.backupMu.Unlock()
+	}, nil
+}
+
+func (a *App) emitBackupProgress(progress services.BackupProgress) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventBackupProgress, progress)
+	}
+}
+
+// stopBackup cancels a running backup and the schedule, e.g. on shutdown.
+func (a *App) stopBackup() {
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+	if a.backupTimer != nil {
+		a.backupTimer.Stop()
+		a.backupTimer = nil
+	}
+	if a.backupCancel != nil {
+		a.backupCancel()
+	}
+}
+
+func findBackupTarget(targets []services.BackupTarget, root string) (services.BackupTarget, bool) {
+	for _, target := range targets {
+		if waitKey(target.Workspace) == waitKey(root) {
+			return target, true
+		}
+	}
+	return services.BackupTarget{}, false
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dirtyDocuments mirrors the tabs with unsaved changes reported by the
+// frontend, so operations rewriting files on disk can refuse to run under
+// them: the tab would keep the old text and overwrite the file on save.
+type dirtyDocuments struct {
+	mu    sync.Mutex
+	paths map[string]string
+}
+
+func (d *dirtyDocuments) set(paths []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paths = make(map[string]string, len(paths))
+	for _, path := range paths {
+		d.paths[waitKey(path)] = path
+	}
+}
+
+// among returns the dirty documents that are one of paths or lie below one.
+func (d *dirtyDocuments) among(paths []string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	found := []string{}
+	for key, dirty := range d.paths {
+		for _, path := range paths {
+			prefix := waitKey(path)
+			if key == prefix || strings.HasPrefix(key, strings.TrimSuffix(prefix, string(filepath.Separator))+string(filepath.Separator)) {
+				found = append(found, dirty)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// SetDirtyDocuments is called by the frontend whenever the set of tabs with
+// unsaved changes changes.
+func (a *App) SetDirtyDocuments(paths []string) {
+	a.dirty.set(paths)
+}
+
+// checkNotDirty refuses action when it would rewrite an open document with
+// unsaved changes at or below paths.
+func (a *App) checkNotDirty(action string, paths ...string) error {
+	dirty := a.dirty.among(paths)
+	if len(dirty) == 0 {
+		return nil
+	}
+	names := make([]string, len(dirty))
+	for i, path := range dirty {
+		names[i] = filepath.Base(path)
+	}
+	return fmt.Errorf("save or close %s first; %s would overwrite unsaved changes", strings.Join(names, ", "), action)
+}
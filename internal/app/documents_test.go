@@ -0,0 +1,138 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func TestDirtyDocumentsAmong(t *testing.T) {
+	root := t.TempDir()
+	note := filepath.Join(root, "notes", "a.md")
+	other := filepath.Join(root, "notes-old", "b.md")
+	var dirty dirtyDocuments
+	dirty.set([]string{note, other})
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{name: "the file", paths: []string{note}, want: []string{note}},
+		{name: "its folder", paths: []string{filepath.Join(root, "notes")}, want: []string{note}},
+		{name: "the workspace", paths: []string{root + string(filepath.Separator)}, want: []string{other, note}},
+		{name: "a sibling file", paths: []string{filepath.Join(root, "notes", "c.md")}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dirty.among(tt.paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("among = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	dirty.set(nil)
+	if got := dirty.among([]string{root}); len(got) != 0 {
+		t.Errorf("after clearing among = %v", got)
+	}
+}
+
+func TestReplaceRefusesDirtyDocuments(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.md")
+	if err := os.WriteFile(path, []byte("old text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := &App{files: services.NewFileService(), workspaceRoot: root}
+	options := services.ReplaceOptions{SearchOptions: services.SearchOptions{Query: "old"}, Replacement: "new"}
+	preview, err := a.PreviewReplaceInWorkspace(options)
+	if err != nil || len(preview.Files) != 1 {
+		t.Fatalf("preview = %+v, %v", preview, err)
+	}
+	selections := []services.ReplaceSelection{{Path: path, Hash: preview.Files[0].Hash, Offsets: []int{preview.Files[0].Changes[0].Offset}}}
+
+	a.SetDirtyDocuments([]string{path})
+	if _, err := a.ReplaceInWorkspace(options, selections); err == nil || !strings.Contains(err.Error(), "a.md") {
+		t.Fatalf("replacing in a dirty document: err = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old text" {
+		t.Errorf("file rewritten: %q", data)
+	}
+}
+
+func TestToggleTaskRefusesDirtyDocuments(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "todo.md")
+	if err := os.WriteFile(path, []byte("- [ ] task\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := services.NewFileService()
+	a := &App{files: files, index: services.NewWorkspaceIndex(files), workspaceRoot: root}
+	if _, err := a.QueryTasks(services.TaskFilter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	a.SetDirtyDocuments([]string{path})
+	if _, err := a.ToggleTask(path, 1); err == nil || !strings.Contains(err.Error(), "todo.md") {
+		t.Fatalf("toggling in a dirty document: err = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "- [ ] task\n" {
+		t.Errorf("file rewritten: %q", data)
+	}
+}
+
+func TestBatchRenameRefusesDirtyDocuments(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"draft.md": "# Draft\n", "index.md": "[draft](draft.md)\n", "other.md": "# Other\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := services.NewFileService()
+	a := &App{files: files, index: services.NewWorkspaceIndex(files), notes: services.NewNoteKeyring(time.Hour, nil), workspaceRoot: root}
+	items := []services.RenameItem{{Name: "draft.md", Path: filepath.Join(root, "draft.md"), NewName: "final.md", NewPath: filepath.Join(root, "final.md")}}
+
+	for _, dirty := range []string{"draft.md", "index.md"} {
+		a.SetDirtyDocuments([]string{filepath.Join(root, dirty)})
+		if _, err := a.BatchRename(items); err == nil || !strings.Contains(err.Error(), dirty) {
+			t.Fatalf("renaming with %s dirty: err = %v", dirty, err)
+		}
+		if _, err := os.Stat(items[0].Path); err != nil {
+			t.Fatalf("renamed with %s dirty: %v", dirty, err)
+		}
+	}
+
+	a.SetDirtyDocuments([]string{filepath.Join(root, "other.md")})
+	if _, err := a.BatchRename(items); err != nil {
+		t.Fatalf("renaming with an unrelated note dirty: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "index.md")); string(data) != "[draft](final.md)\n" {
+		t.Errorf("index.md = %q", data)
+	}
+}
+
+func TestMergeNotesRefusesDirtyDocuments(t *testing.T) {
+	root := t.TempDir()
+	paths := []string{filepath.Join(root, "a.md"), filepath.Join(root, "b.md")}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("# "+filepath.Base(path)+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := services.NewFileService()
+	a := &App{files: files, index: services.NewWorkspaceIndex(files), workspaceRoot: root}
+
+	a.SetDirtyDocuments(paths[1:])
+	if _, err := a.MergeNotes(paths, "merged", services.MergeOptions{DeleteSources: true}); err == nil || !strings.Contains(err.Error(), "b.md") {
+		t.Fatalf("merging a dirty note: err = %v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("files = %v, %v, want a.md and b.md untouched", entries, err)
+	}
+}
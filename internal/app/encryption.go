@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const (
+	eventNoteLocked = "note:locked"
+
+	defaultNoteKeyTimeout = 15 * time.Minute
+)
+
+// errNewEncryptedNote asks the frontend for a passphrase (with confirmation)
+// before the first save of an encrypted note.
+var errNewEncryptedNote = errors.New("encrypted note needs a new passphrase")
+
+// UnlockNote derives the key of an encrypted note from passphrase, keeps it
+// in memory and returns the decrypted content. For a note that does not
+// exist yet the passphrase becomes its key and the content is empty.
+func (a *App) UnlockNote(path string, passphrase string) (string, error) {
+	if !services.IsEncryptedNote(path) {
+		return "", errors.New("not an encrypted note")
+	}
+	a.applyNoteKeyTimeout()
+
+	data, err := a.files.ReadBytes(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := services.NewNoteKey(passphrase)
+		if err != nil {
+			return "", err
+		}
+		a.notes.Put(path, key)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	key, plaintext, err := services.OpenNote(data, passphrase)
+	if err != nil {
+		return "", err
+	}
+	a.notes.Put(path, key)
+	return string(plaintext), nil
+}
+
+// LockNote forgets the key of an encrypted note.
+func (a *App) LockNote(path string) {
+	a.notes.Lock(path)
+}
+
+// LockAllNotes forgets the keys of every encrypted note.
+func (a *App) LockAllNotes() {
+	a.notes.LockAll()
+}
+
+// readNote loads a note, decrypting encrypted notes with their cached key.
+func (a *App) readNote(path string) (string, error) {
+	if !services.IsEncryptedNote(path) {
+		return a.files.Read(path)
+	}
+	key, ok := a.notes.Get(path)
+	if !ok {
+		return "", services.ErrNoteLocked
+	}
+	defer key.Wipe()
+	return a.files.ReadEncrypted(path, key)
+}
+
+// writeNote stores a note; encrypted notes are sealed before they reach the
+// disk so no plaintext is ever written for them.
+func (a *App) writeNote(path string, content string) error {
+	if !services.IsEncryptedNote(path) {
+		return a.files.Write(path, content)
+	}
+	key, ok := a.notes.Get(path)
+	if !ok {
+		if _, err := a.files.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			return errNewEncryptedNote
+		}
+		return services.ErrNoteLocked
+	}
+	defer key.Wipe()
+	return a.files.WriteEncrypted(path, content, key)
+}
+
+// applyNoteKeyTimeout picks up the idle timeout from the settings.
+func (a *App) applyNoteKeyTimeout() {
+	timeout := defaultNoteKeyTimeout
+	if settings, err := a.settings.Load(); err == nil && settings.EncryptionTimeoutMinutes > 0 {
+		timeout = time.Duration(settings.EncryptionTimeoutMinutes) * time.Minute
+	}
+	a.notes.SetTimeout(timeout)
+}
+
+func (a *App) noteKeyExpired(path string) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventNoteLocked, path)
+	}
+}
+
+// isNoteKeyError reports errors the frontend answers with a passphrase prompt.
+func isNoteKeyError(err error) bool {
+	return errors.Is(err, services.ErrNoteLocked) || errors.Is(err, errNewEncryptedNote)
+}
@@ -3,7 +3,6 @@ package app
 import (
 	"errors"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -18,6 +17,11 @@ type DirectoryEntry struct {
 	IsDir       bool             `json:"isDir"`
 	HasChildren bool             `json:"hasChildren"`
 	Children    []DirectoryEntry `json:"children,omitempty"`
+	// GitStatus is modified, added, deleted, renamed, untracked, ignored or
+	// conflicted; empty for clean files and outside a repository.
+	GitStatus string `json:"gitStatus,omitempty"`
+	// Vault is locked or unlocked for the root folder of a vault.
+	Vault string `json:"vault,omitempty"`
 }
 
 func (a *App) buildDirectoryTree(root string) (DirectoryEntry, error) {
@@ -30,20 +34,22 @@ func (a *App) buildDirectoryTree(root string) (DirectoryEntry, error) {
 }
 
 func (a *App) readDirectoryEntry(path string, includeChildren bool) (DirectoryEntry, error) {
-	info, err := os.Lstat(path)
+	info, err := a.files.Lstat(path)
 	if err != nil {
 		return DirectoryEntry{}, err
 	}
 
 	entry := DirectoryEntry{
-		Name:  info.Name(),
-		Path:  path,
-		IsDir: info.IsDir(),
+		Name:      info.Name(),
+		Path:      path,
+		IsDir:     info.IsDir(),
+		GitStatus: a.gitStatusOf(path, info.IsDir()),
 	}
 
 	if !info.IsDir() {
 		return entry, nil
 	}
+	entry.Vault = a.vaultState(path)
 
 	if includeChildren {
 		children, err := a.readDirectoryChildren(path)
@@ -62,7 +68,11 @@ func (a *App) readDirectoryEntry(path string, includeChildren bool) (DirectoryEn
 }
 
 func (a *App) readDirectoryChildren(path string) ([]DirectoryEntry, error) {
-	dirEntries, err := os.ReadDir(path)
+	if a.vaultState(path) == vaultLocked {
+		// 未解锁的保险库只有密文，不显示
+		return []DirectoryEntry{}, nil
+	}
+	dirEntries, err := a.files.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +98,9 @@ func (a *App) readDirectoryChildren(path string) ([]DirectoryEntry, error) {
 			childEntry.IsDir = false
 		} else if childEntry.IsDir {
 			childEntry.HasChildren = a.directoryHasChildren(childPath)
+			childEntry.Vault = a.vaultState(childPath)
 		}
+		childEntry.GitStatus = a.gitStatusOf(childPath, childEntry.IsDir)
 
 		children = append(children, childEntry)
 	}
@@ -104,7 +116,10 @@ func (a *App) readDirectoryChildren(path string) ([]DirectoryEntry, error) {
 }
 
 func (a *App) directoryHasChildren(path string) bool {
-	entries, err := os.ReadDir(path)
+	if a.vaultState(path) == vaultLocked {
+		return false
+	}
+	entries, err := a.files.ReadDir(path)
 	if err != nil {
 		a.logTreeWarning("read dir", path, err)
 		return false
@@ -152,13 +167,3 @@ func (a *App) logTreeWarning(action string, path string, err error) {
 	}
 	runtime.LogWarningf(a.ctx, "folder tree: %s '%s': %v", action, path, err)
 }
-
-func isMarkdownFile(name string) bool {
-	ext := strings.ToLower(filepath.Ext(name))
-	switch ext {
-	case ".md", ".markdown", ".mdown", ".mkd", ".mdx":
-		return true
-	default:
-		return false
-	}
-}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const eventDocumentFormatted = "document:formatted"
+
+// FormatDocument returns content, the editor buffer of path, formatted with
+// the style of the .markdowndaonote-lint.yml that applies to the file.
+func (a *App) FormatDocument(path string, content string) (string, error) {
+	options, err := a.formatOptions(path)
+	if err != nil {
+		return content, err
+	}
+	return services.FormatDocument(content, options), nil
+}
+
+// FormatSelection formats the blocks touching lines startLine to endLine
+// (1-based) and returns the whole buffer.
+func (a *App) FormatSelection(path string, content string, startLine int, endLine int) (string, error) {
+	options, err := a.formatOptions(path)
+	if err != nil {
+		return content, err
+	}
+	return services.FormatRange(content, startLine, endLine, options), nil
+}
+
+func (a *App) formatOptions(path string) (services.FormatOptions, error) {
+	config, err := a.lintConfig(path)
+	if err != nil {
+		return services.DefaultFormatOptions(), err
+	}
+	return config.FormatOptions(), nil
+}
+
+// formatOnSave formats a Markdown note about to be saved when the setting is
+// on. SaveDocument sends the result to the editor.
+func (a *App) formatOnSave(path string, content string) string {
+	if !services.IsMarkdownFile(path) && !services.IsEncryptedNote(path) {
+		return content
+	}
+	settings, err := a.settings.Load()
+	if err != nil || !settings.FormatOnSave {
+		return content
+	}
+	options, err := a.formatOptions(path)
+	if err != nil {
+		// 配置文件有误时照常保存，不阻塞写入
+		runtime.LogWarningf(a.ctx, "format on save skipped for '%s': %v", path, err)
+		return content
+	}
+	return services.FormatDocument(content, options)
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const (
+	eventGitStatus = "git:status"
+
+	// 连续保存时合并多次刷新
+	gitRefreshDelay = 300 * time.Millisecond
+)
+
+// LoadGitStatus returns the branch and file states of the repository that
+// contains the opened workspace, or nil when it is not under git.
+func (a *App) LoadGitStatus() (*services.GitStatus, error) {
+	a.gitMu.Lock()
+	status := a.gitStatus
+	a.gitMu.Unlock()
+	if status != nil {
+		return status, nil
+	}
+	return a.loadGitStatus(a.activeWorkspaceRoot())
+}
+
+// RefreshGitStatus re-reads the repository state and notifies the frontend.
+func (a *App) RefreshGitStatus() (*services.GitStatus, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return nil, errors.New("no folder is open")
+	}
+	status, err := a.loadGitStatus(root)
+	if err != nil {
+		return nil, err
+	}
+	a.emitGitStatus(status)
+	return status, nil
+}
+
+// loadGitStatus reads and caches the status of the repository containing root.
+func (a *App) loadGitStatus(root string) (*services.GitStatus, error) {
+	var status *services.GitStatus
+	if repo, ok := services.FindGitRoot(root); root != "" && ok {
+		var err error
+		if status, err = services.ReadGitStatus(repo); err != nil {
+			return nil, err
+		}
+	}
+
+	a.gitMu.Lock()
+	a.gitStatus = status
+	a.gitMu.Unlock()
+	return status, nil
+}
+
+// scheduleGitRefresh refreshes the git status shortly after files change.
+func (a *App) scheduleGitRefresh() {
+	a.gitMu.Lock()
+	defer a.gitMu.Unlock()
+	if a.gitStatus == nil {
+		return
+	}
+	if a.gitRefresh != nil {
+		a.gitRefresh.Stop()
+	}
+	a.gitRefresh = time.AfterFunc(gitRefreshDelay, a.refreshGitStatusAsync)
+}
+
+// filesChanged refreshes what depends on the workspace after the editor
+// changed files on disk.
+func (a *App) filesChanged() {
+	a.scheduleGitRefresh()
+	a.scheduleSyncOnSave()
+}
+
+func (a *App) emitGitStatus(status *services.GitStatus) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventGitStatus, status)
+	}
+}
+
+// gitStatusOf returns the tree decoration for path.
+func (a *App) gitStatusOf(path string, isDir bool) string {
+	a.gitMu.Lock()
+	status := a.gitStatus
+	a.gitMu.Unlock()
+	return status.StatusOf(path, isDir)
+}
+
+// GitStage stages the given files or folders.
+func (a *App) GitStage(paths []string) error {
+	root, err := a.gitRepositoryRoot(paths...)
+	if err != nil {
+		return err
+	}
+	if err := services.GitStage(root, paths...); err != nil {
+		return err
+	}
+	a.refreshGitStatusAsync()
+	return nil
+}
+
+// GitUnstage removes the given files or folders from the index.
+func (a *App) GitUnstage(paths []string) error {
+	root, err := a.gitRepositoryRoot(paths...)
+	if err != nil {
+		return err
+	}
+	if err := services.GitUnstage(root, paths...); err != nil {
+		return err
+	}
+	a.refreshGitStatusAsync()
+	return nil
+}
+
+// GitCommit commits the staged changes and returns the new commit hash.
+func (a *App) GitCommit(message string) (string, error) {
+	root, err := a.gitRepositoryRoot()
+	if err != nil {
+		return "", err
+	}
+	hash, err := services.GitCommitStaged(root, message)
+	if err != nil {
+		return "", err
+	}
+	a.refreshGitStatusAsync()
+	return hash, nil
+}
+
+// GitDiff compares the saved file with HEAD.
+func (a *App) GitDiff(path string) (*services.GitDiff, error) {
+	root, err := a.gitRepositoryRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	return services.GitDiffFile(root, path)
+}
+
+// GitLog lists the commits that touched path, newest first.
+func (a *App) GitLog(path string, limit int) ([]services.GitCommit, error) {
+	root, err := a.gitRepositoryRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	return services.GitFileLog(root, path, limit)
+}
+
+// GitShowRevision returns the content of path at revision, for read-only viewing.
+func (a *App) GitShowRevision(path string, revision string) (string, error) {
+	root, err := a.gitRepositoryRoot(path)
+	if err != nil {
+		return "", err
+	}
+	return services.GitShowFile(root, revision, path)
+}
+
+// GitQuickSync commits every change and then pulls and pushes.
+func (a *App) GitQuickSync(message string) (services.GitSyncResult, error) {
+	root, err := a.gitRepositoryRoot()
+	if err != nil {
+		return services.GitSyncResult{}, err
+	}
+	result, err := services.GitQuickSync(root, message)
+	a.refreshGitStatusAsync()
+	return result, err
+}
+
+// gitRepositoryRoot finds the repository of paths, or of the workspace.
+func (a *App) gitRepositoryRoot(paths ...string) (string, error) {
+	start := a.activeWorkspaceRoot()
+	if len(paths) > 0 {
+		start = paths[0]
+	}
+	if start == "" {
+		return "", errors.New("no folder is open")
+	}
+	root, ok := services.FindGitRoot(start)
+	if !ok {
+		return "", fmt.Errorf("%s is not inside a git repository", start)
+	}
+	for _, path := range paths {
+		if other, ok := services.FindGitRoot(path); !ok || other != root {
+			return "", fmt.Errorf("%s is not inside the repository %s", path, root)
+		}
+	}
+	return root, nil
+}
+
+func (a *App) refreshGitStatusAsync() {
+	go func() {
+		if _, err := a.RefreshGitStatus(); err != nil && a.ctx != nil {
+			runtime.LogWarningf(a.ctx, "failed refreshing git status: %v", err)
+		}
+	}()
+}
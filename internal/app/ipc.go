@@ -0,0 +1,90 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 单实例 socket 上的协议：每条消息是一行 JSON（newline-delimited JSON）。
+// 次要实例发送一条 ipcRequest，主实例在前端打开文件后回复 "accepted"，否则回复错误；
+// 对于 --wait 请求，文件全部关闭后再回复一条 "closed"。
+// 首条消息带 "jsonrpc" 字段的连接则进入脚本 API 会话（见 rpc.go）。
+
+const (
+	ipcProtocolVersion = 1
+	maxIPCMessageSize  = 16 << 20 // 脚本 API 会整篇传输文档内容
+
+	ipcCommandOpen  = "open"
+	ipcCommandFocus = "focus"
+	ipcCommandPing  = "ping"
+
+	ipcStatusAccepted = "accepted"
+	ipcStatusClosed   = "closed"
+	ipcStatusPong     = "pong"
+)
+
+var errIPCMessageTooLarge = errors.New("ipc message exceeds size limit")
+
+// ipcRequest is sent by a secondary instance to the primary.
+type ipcRequest struct {
+	Version int    `json:"version"`
+	Command string `json:"command"`
+	LaunchRequest
+}
+
+// ipcResponse acknowledges a request. Error is set when OK is false.
+type ipcResponse struct {
+	Version int    `json:"version"`
+	OK      bool   `json:"ok"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func writeIPCMessage(w io.Writer, message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// readIPCMessage decodes one newline-terminated JSON message.
+func readIPCMessage(r *bufio.Reader, message interface{}) error {
+	line, err := readIPCLine(r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(line, message); err != nil {
+		return fmt.Errorf("invalid ipc message: %w", err)
+	}
+	return nil
+}
+
+// readIPCLine reads one newline-terminated message, refusing lines longer
+// than maxIPCMessageSize.
+func readIPCLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxIPCMessageSize {
+			return nil, errIPCMessageTooLarge
+		}
+		if err == nil {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && len(bytes.TrimSpace(line)) > 0 {
+			break
+		}
+		return nil, err
+	}
+	return line, nil
+}
@@ -0,0 +1,401 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// OpenTarget is a file to open, optionally positioned at a 1-based line and column.
+type OpenTarget struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// LaunchRequest describes what a process was asked to open on the command line.
+type LaunchRequest struct {
+	Cwd       string       `json:"cwd"`
+	Files     []OpenTarget `json:"files,omitempty"`
+	Folder    string       `json:"folder,omitempty"`
+	NewWindow bool         `json:"newWindow,omitempty"`
+	Wait      bool         `json:"wait,omitempty"`
+	// New is a note to create after asking the user, see ParseLaunchURL.
+	New *NoteDraft `json:"new,omitempty"`
+}
+
+// Empty reports whether the request asks to open nothing.
+func (r LaunchRequest) Empty() bool {
+	return len(r.Files) == 0 && r.Folder == "" && r.New == nil
+}
+
+var positionSuffixPattern = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?$`)
+
+// ParseLaunchArgs interprets GUI command-line arguments: any number of files,
+// a folder to open as the workspace, `file.md:42:7` positions, `--line`/`--column`
+// (applied to the next file, or the previous one when no file follows),
+// `--new-window` and `--wait`. Relative paths are resolved against cwd. Other
+// options are rejected, except the -psn_ argument macOS adds.
+func ParseLaunchArgs(args []string, cwd string) (LaunchRequest, error) {
+	request := LaunchRequest{Cwd: cwd}
+	pendingLine, pendingColumn := 0, 0
+	onlyPaths := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if !onlyPaths && strings.HasPrefix(arg, "-") && arg != "-" {
+			name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			switch name {
+			case "":
+				onlyPaths = true
+			case "new-window", "n":
+				request.NewWindow = true
+			case "wait", "w":
+				request.Wait = true
+			case "line", "l", "column", "c":
+				if !hasValue {
+					if i+1 >= len(args) {
+						return request, fmt.Errorf("%s requires a value", arg)
+					}
+					i++
+					value = args[i]
+				}
+				number, err := strconv.Atoi(value)
+				if err != nil || number < 1 {
+					return request, fmt.Errorf("%s expects a positive number, got %q", arg, value)
+				}
+				if name == "line" || name == "l" {
+					pendingLine = number
+				} else {
+					pendingColumn = number
+				}
+			default:
+				// macOS 在 Finder 启动时会附加 -psn_ 参数
+				if strings.HasPrefix(arg, "-psn_") {
+					continue
+				}
+				return request, fmt.Errorf("unknown option %s", arg)
+			}
+			continue
+		}
+
+		target, isDir, err := resolveLaunchPath(arg, cwd)
+		if err != nil {
+			return request, err
+		}
+		if isDir {
+			request.Folder = target.Path
+			continue
+		}
+		if pendingLine > 0 {
+			target.Line, pendingLine = pendingLine, 0
+		}
+		if pendingColumn > 0 {
+			target.Column, pendingColumn = pendingColumn, 0
+		}
+		request.Files = append(request.Files, target)
+	}
+
+	if (pendingLine > 0 || pendingColumn > 0) && len(request.Files) > 0 {
+		last := &request.Files[len(request.Files)-1]
+		if pendingLine > 0 {
+			last.Line = pendingLine
+		}
+		if pendingColumn > 0 {
+			last.Column = pendingColumn
+		}
+	}
+
+	if request.Wait && len(request.Files) == 0 {
+		return request, errors.New("--wait requires a file")
+	}
+	return request, nil
+}
+
+// resolveLaunchPath makes arg absolute and splits off a `:line[:column]` suffix
+// unless a file with the literal name exists.
+func resolveLaunchPath(arg string, cwd string) (OpenTarget, bool, error) {
+	absolute := func(path string) string {
+		if filepath.IsAbs(path) || cwd == "" {
+			return filepath.Clean(path)
+		}
+		return filepath.Join(cwd, path)
+	}
+
+	// 桌面环境以 %U 启动时本地文件会以 file:// URL 传入
+	if strings.HasPrefix(arg, "file://") {
+		if parsed, err := url.Parse(arg); err == nil && (parsed.Host == "" || parsed.Host == "localhost") {
+			arg = filepath.FromSlash(parsed.Path)
+			// file:///C:/notes/a.md 的路径部分为 /C:/notes/a.md
+			if goruntime.GOOS == "windows" && len(arg) > 2 && arg[2] == ':' {
+				arg = arg[1:]
+			}
+		}
+	}
+
+	full := absolute(arg)
+	if info, err := os.Stat(full); err == nil {
+		return OpenTarget{Path: full}, info.IsDir(), nil
+	}
+
+	if match := positionSuffixPattern.FindStringSubmatch(arg); match != nil {
+		target := OpenTarget{Path: absolute(match[1])}
+		target.Line, _ = strconv.Atoi(match[2])
+		if match[3] != "" {
+			target.Column, _ = strconv.Atoi(match[3])
+		}
+		return target, false, nil
+	}
+
+	// 不存在的文件也允许打开（例如 $EDITOR 新建文件），打开前创建
+	return OpenTarget{Path: full}, false, nil
+}
+
+// SetLaunchRequest records what this process was asked to open. The files are
+// shown once the editor reports it is ready; with `--wait` the application
+// quits after they have all been closed so the calling shell can continue.
+// It fails when a missing file cannot be created.
+func (a *App) SetLaunchRequest(request LaunchRequest) error {
+	a.newWindow = request.NewWindow
+	if request.Empty() {
+		return nil
+	}
+
+	waiter, err := a.openLaunchRequest(request)
+	if err != nil {
+		return err
+	}
+	if !request.Wait {
+		a.waiters.forget(waiter)
+		return nil
+	}
+	// 作为 $EDITOR 启动的主实例：文件标签关闭后退出，让调用方继续
+	go func() {
+		<-waiter.done
+		if a.ctx != nil {
+			runtime.Quit(a.ctx)
+		}
+	}()
+	return nil
+}
+
+// NotifyDocumentOpened is called by the frontend once a file requested by
+// another instance or a script is shown in a tab.
+func (a *App) NotifyDocumentOpened(path string) {
+	a.waiters.markOpened(path)
+}
+
+// NotifyDocumentClosed is called by the frontend when a tab is closed; it
+// releases any `--wait` caller blocked on that file.
+func (a *App) NotifyDocumentClosed(path string) {
+	a.waiters.release(path)
+}
+
+// openLaunchRequest opens the request now, or queues it until the editor is
+// ready. Missing files are created first, so the editor can open them. The
+// returned waiter reports when the editor has opened the files and when
+// every one of them has been closed again (or failed to open).
+func (a *App) openLaunchRequest(request LaunchRequest) (*documentWaiter, error) {
+	paths := make([]string, 0, len(request.Files))
+	for _, file := range request.Files {
+		if err := a.createLaunchFile(file.Path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, file.Path)
+	}
+	if request.New != nil {
+		// 先定下文件名，等待方据此跟踪；文件在用户确认后才创建
+		draft := *request.New
+		draft.Path = uniqueNotePath(a.files, draft.Path)
+		request.New = &draft
+		paths = append(paths, draft.Path)
+	}
+	waiter := a.waiters.add(paths)
+
+	a.launchMu.Lock()
+	if !a.editorReady {
+		a.pendingLaunches = append(a.pendingLaunches, request)
+		a.launchMu.Unlock()
+		return waiter, nil
+	}
+	a.launchMu.Unlock()
+
+	a.dispatchLaunchRequest(request)
+	return waiter, nil
+}
+
+// createLaunchFile creates an empty file at path when nothing is there yet.
+func (a *App) createLaunchFile(path string) error {
+	if _, err := a.files.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := a.files.CreateFile(path); err != nil {
+		return fmt.Errorf("cannot create %s: %w", path, err)
+	}
+	return nil
+}
+
+// markEditorReady flushes launch requests received before the frontend was listening.
+func (a *App) markEditorReady() {
+	a.launchMu.Lock()
+	a.editorReady = true
+	pending := a.pendingLaunches
+	a.pendingLaunches = nil
+	a.launchMu.Unlock()
+
+	for _, request := range pending {
+		a.dispatchLaunchRequest(request)
+	}
+}
+
+// dispatchLaunchRequest opens the requested folder and files in the editor.
+func (a *App) dispatchLaunchRequest(request LaunchRequest) {
+	if a.ctx == nil {
+		return
+	}
+
+	if request.Folder != "" {
+		a.openWorkspaceFolder(request.Folder)
+	}
+	for _, file := range request.Files {
+		runtime.EventsEmit(a.ctx, eventOpenFile, file.Path, file.Line, file.Column)
+	}
+	if request.New != nil {
+		// 确认对话框会阻塞，不能卡住转发请求或前端就绪的调用方
+		go a.createNoteDraft(*request.New)
+	}
+	runtime.WindowShow(a.ctx)
+}
+
+// documentWaiters tracks launch requests until the editor has opened their
+// files and, for `--wait` callers, until the files are closed again.
+type documentWaiters struct {
+	mu      sync.Mutex
+	pending []*documentWaiter
+}
+
+type documentWaiter struct {
+	opening   map[string]struct{}
+	remaining map[string]struct{}
+	// opened closes once every file is open or has failed; err is set before
+	// that and not changed afterwards, so it may be read without the lock.
+	opened chan struct{}
+	err    error
+	done   chan struct{}
+}
+
+func (w *documentWaiters) add(paths []string) *documentWaiter {
+	waiter := &documentWaiter{
+		opening:   map[string]struct{}{},
+		remaining: map[string]struct{}{},
+		opened:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, path := range paths {
+		waiter.opening[waitKey(path)] = struct{}{}
+		waiter.remaining[waitKey(path)] = struct{}{}
+	}
+	if len(waiter.remaining) == 0 {
+		close(waiter.opened)
+		close(waiter.done)
+		return waiter
+	}
+
+	w.mu.Lock()
+	w.pending = append(w.pending, waiter)
+	w.mu.Unlock()
+	return waiter
+}
+
+// markOpened records that the editor shows path.
+func (w *documentWaiters) markOpened(path string) {
+	key := waitKey(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, waiter := range w.pending {
+		waiter.settle(key, nil)
+	}
+}
+
+func (w *documentWaiters) release(path string) {
+	// 打开前就报告关闭，说明前端没能打开这个文件
+	w.fail(path, fmt.Errorf("the editor could not open %s", path))
+}
+
+// fail ends the wait for path; callers still waiting for it to open get err.
+func (w *documentWaiters) fail(path string, err error) {
+	key := waitKey(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	kept := w.pending[:0]
+	for _, waiter := range w.pending {
+		waiter.settle(key, err)
+		delete(waiter.remaining, key)
+		if len(waiter.remaining) == 0 {
+			close(waiter.done)
+			continue
+		}
+		kept = append(kept, waiter)
+	}
+	w.pending = kept
+}
+
+// forget drops waiter, e.g. when the caller waiting on it has gone away.
+func (w *documentWaiters) forget(waiter *documentWaiter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, pending := range w.pending {
+		if pending == waiter {
+			w.pending = append(w.pending[:i], w.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// releaseAll unblocks every waiter, e.g. when the application shuts down.
+func (w *documentWaiters) releaseAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, waiter := range w.pending {
+		for key := range waiter.opening {
+			waiter.settle(key, errors.New("the editor is shutting down"))
+		}
+		close(waiter.done)
+	}
+	w.pending = nil
+}
+
+// settle marks key as opened or failed; the caller holds the lock.
+func (waiter *documentWaiter) settle(key string, err error) {
+	if _, ok := waiter.opening[key]; !ok {
+		return
+	}
+	delete(waiter.opening, key)
+	if err != nil && waiter.err == nil {
+		waiter.err = err
+	}
+	if len(waiter.opening) == 0 {
+		close(waiter.opened)
+	}
+}
+
+func waitKey(path string) string {
+	clean := filepath.Clean(strings.TrimSpace(path))
+	// Windows 与 macOS 默认文件系统不区分大小写
+	if goruntime.GOOS == "windows" || goruntime.GOOS == "darwin" {
+		return strings.ToLower(clean)
+	}
+	return clean
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func TestParseLaunchArgs(t *testing.T) {
+	cwd := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cwd, "a.md"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(cwd, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		want    LaunchRequest
+		wantErr bool
+	}{
+		{
+			name: "file and folder",
+			args: []string{"a.md", "notes"},
+			want: LaunchRequest{Cwd: cwd, Files: []OpenTarget{{Path: filepath.Join(cwd, "a.md")}}, Folder: filepath.Join(cwd, "notes")},
+		},
+		{
+			name: "position suffix",
+			args: []string{"b.md:12:3"},
+			want: LaunchRequest{Cwd: cwd, Files: []OpenTarget{{Path: filepath.Join(cwd, "b.md"), Line: 12, Column: 3}}},
+		},
+		{
+			name: "line flag applies to the next file",
+			args: []string{"--line", "4", "a.md", "--wait"},
+			want: LaunchRequest{Cwd: cwd, Files: []OpenTarget{{Path: filepath.Join(cwd, "a.md"), Line: 4}}, Wait: true},
+		},
+		{
+			name: "line flag after the last file",
+			args: []string{"a.md", "-l=9"},
+			want: LaunchRequest{Cwd: cwd, Files: []OpenTarget{{Path: filepath.Join(cwd, "a.md"), Line: 9}}},
+		},
+		{
+			name: "macOS process serial number",
+			args: []string{"-psn_0_12345", "a.md"},
+			want: LaunchRequest{Cwd: cwd, Files: []OpenTarget{{Path: filepath.Join(cwd, "a.md")}}},
+		},
+		{
+			name: "paths after --",
+			args: []string{"--", "-x.md"},
+			want: LaunchRequest{Cwd: cwd, Files: []OpenTarget{{Path: filepath.Join(cwd, "-x.md")}}},
+		},
+		{name: "unknown option", args: []string{"--lines", "4", "a.md"}, wantErr: true},
+		{name: "wait without file", args: []string{"--wait", "notes"}, wantErr: true},
+		{name: "bad line", args: []string{"--line", "0", "a.md"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLaunchArgs(tt.args, cwd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("request = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenLaunchRequestCreatesMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := &App{files: services.NewFileService()}
+
+	path := filepath.Join(dir, "new.md")
+	waiter, err := a.openLaunchRequest(LaunchRequest{Files: []OpenTarget{{Path: path}}, Wait: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("missing file was not created: %v", err)
+	}
+	a.NotifyDocumentClosed(path)
+	select {
+	case <-waiter.done:
+	default:
+		t.Error("waiter not released after the file was closed")
+	}
+
+	missing := filepath.Join(dir, "no-such-folder", "new.md")
+	if _, err := a.openLaunchRequest(LaunchRequest{Files: []OpenTarget{{Path: missing}}, Wait: true}); err == nil {
+		t.Error("opening a file that cannot be created succeeded")
+	}
+	if len(a.waiters.pending) != 0 {
+		t.Errorf("%d waiters left for a failed request", len(a.waiters.pending))
+	}
+}
+
+func TestDocumentWaiters(t *testing.T) {
+	tests := []struct {
+		name       string
+		paths      []string
+		opened     []string
+		closed     []string
+		wantOpened bool
+		wantErr    bool
+		wantDone   bool
+	}{
+		{name: "no files", wantOpened: true, wantDone: true},
+		{name: "opened", paths: []string{"/n/a.md", "/n/b.md"}, opened: []string{"/n/a.md", "/n/b.md"}, wantOpened: true},
+		{name: "one still opening", paths: []string{"/n/a.md", "/n/b.md"}, opened: []string{"/n/a.md"}},
+		{name: "opened then closed", paths: []string{"/n/a.md"}, opened: []string{"/n/a.md"}, closed: []string{"/n/a.md"}, wantOpened: true, wantDone: true},
+		{name: "closed before opening", paths: []string{"/n/a.md"}, closed: []string{"/n/a.md"}, wantOpened: true, wantErr: true, wantDone: true},
+		{name: "other file", paths: []string{"/n/a.md"}, opened: []string{"/n/b.md"}, closed: []string{"/n/b.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waiters documentWaiters
+			waiter := waiters.add(tt.paths)
+			for _, path := range tt.opened {
+				waiters.markOpened(path)
+			}
+			for _, path := range tt.closed {
+				waiters.release(path)
+			}
+			if got := isClosed(waiter.opened); got != tt.wantOpened {
+				t.Fatalf("opened = %v, want %v", got, tt.wantOpened)
+			}
+			if tt.wantOpened && (waiter.err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", waiter.err, tt.wantErr)
+			}
+			if got := isClosed(waiter.done); got != tt.wantDone {
+				t.Errorf("done = %v, want %v", got, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestDocumentWaitersReleaseAll(t *testing.T) {
+	var waiters documentWaiters
+	waiter := waiters.add([]string{"/n/a.md"})
+	other := waiters.add([]string{"/n/b.md"})
+	waiters.forget(other)
+	waiters.releaseAll()
+	if !isClosed(waiter.opened) || waiter.err == nil || !isClosed(waiter.done) {
+		t.Errorf("waiter not failed on shutdown: opened=%v err=%v", isClosed(waiter.opened), waiter.err)
+	}
+	if isClosed(other.opened) {
+		t.Error("a forgotten waiter was still released")
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// LintDocument checks content, the editor buffer of path, with the
+// .markdowndaonote-lint.yml that applies to the file's folder.
+func (a *App) LintDocument(path string, content string) ([]services.LintDiagnostic, error) {
+	config, err := a.lintConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return services.LintDocument(content, config), nil
+}
+
+// FixLintProblems returns content with every safe fix applied; the editor
+// replaces its buffer so the change can be undone.
+func (a *App) FixLintProblems(path string, content string) (string, error) {
+	config, err := a.lintConfig(path)
+	if err != nil {
+		return content, err
+	}
+	fixed, _ := services.FixDocument(content, config)
+	return fixed, nil
+}
+
+func (a *App) lintConfig(path string) (services.LintConfig, error) {
+	dir := a.activeWorkspaceRoot()
+	if path = strings.TrimSpace(path); path != "" {
+		dir = filepath.Dir(path)
+	}
+	if dir == "" {
+		return services.DefaultLintConfig(), nil
+	}
+	return services.LoadLintConfig(dir)
+}
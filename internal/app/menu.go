@@ -8,9 +8,12 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/menu"
 	"github.com/wailsapp/wails/v2/pkg/menu/keys"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
 )
 
 const (
+	eventOpenFile            = "open-file"
 	eventFileOpened          = "file:opened"
 	eventFileSaveRequested   = "file:save-requested"
 	eventFileSaved           = "file:saved"
@@ -79,6 +82,10 @@ var markdownFilters = []runtime.FileFilter{
 		DisplayName: "Markdown files",
 		Pattern:     "*.md;*.markdown;*.mdown;*.mkd;*.mdx",
 	},
+	{
+		DisplayName: "Encrypted notes",
+		Pattern:     "*.md.enc",
+	},
 	{
 		DisplayName: "All files",
 		Pattern:     "*",
@@ -198,6 +205,12 @@ func (a *App) handleOpen() {
 			continue
 		}
 
+		if services.IsEncryptedNote(trimmed) {
+			// 由前端通过 LoadFile 打开，以便询问口令
+			runtime.EventsEmit(a.ctx, eventOpenFile, trimmed, 0, 0)
+			continue
+		}
+
 		content, readErr := a.files.Read(trimmed)
 		if readErr != nil {
 			runtime.LogErrorf(a.ctx, "failed reading '%s': %v", trimmed, readErr)
@@ -236,6 +249,17 @@ func (a *App) handleOpenFolder() {
 		return
 	}
 
+	a.openWorkspaceFolder(selection)
+}
+
+// openWorkspaceFolder loads the folder tree, makes it the workspace and notifies the frontend.
+func (a *App) openWorkspaceFolder(selection string) {
+	// 先读取 git 状态，使目录树带上状态标记
+	gitStatus, gitErr := a.loadGitStatus(selection)
+	if gitErr != nil {
+		runtime.LogWarningf(a.ctx, "failed reading git status for '%s': %v", selection, gitErr)
+	}
+
 	tree, buildErr := a.buildDirectoryTree(selection)
 	if buildErr != nil {
 		runtime.LogErrorf(a.ctx, "failed reading folder '%s': %v", selection, buildErr)
@@ -250,7 +274,12 @@ func (a *App) handleOpenFolder() {
 	normalized := strings.TrimSpace(filepath.Clean(selection))
 	a.currentFolderPath = normalized
 	a.setCurrentFile("")
+	a.rememberWorkspace(normalized)
+	a.rebuildWorkspaceIndex(normalized)
+	a.configureSync(normalized)
+	a.configureBackup(normalized)
 	runtime.EventsEmit(a.ctx, eventFolderOpened, normalized, tree)
+	a.emitGitStatus(gitStatus)
 }
 
 func (a *App) requestSave(force bool) {
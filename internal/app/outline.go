@@ -0,0 +1,42 @@
+package app
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// DocumentOutline returns the headings of content, the editor buffer.
+func (a *App) DocumentOutline(content string) []services.OutlineHeading {
+	return services.DocumentOutline(content)
+}
+
+// UpdateTOC refreshes the marked table of contents of content, the editor
+// buffer of path, or inserts one before line (1-based) when there is none.
+// The levels come from the .markdowndaonote-lint.yml that applies to path.
+func (a *App) UpdateTOC(path string, content string, line int) (string, error) {
+	config, err := a.lintConfig(path)
+	if err != nil {
+		return content, err
+	}
+	return services.InsertTOC(content, line, config.TOCOptions()), nil
+}
+
+// tocOnSave refreshes the table of contents of a note about to be saved when
+// the setting is on. Notes without a TOC marker are left alone.
+func (a *App) tocOnSave(path string, content string) string {
+	if !services.IsMarkdownFile(path) && !services.IsEncryptedNote(path) {
+		return content
+	}
+	settings, err := a.settings.Load()
+	if err != nil || !settings.TOCOnSave {
+		return content
+	}
+	config, err := a.lintConfig(path)
+	if err != nil {
+		runtime.LogWarningf(a.ctx, "table of contents update skipped for '%s': %v", path, err)
+		return content
+	}
+	updated, _ := services.UpdateTOC(content, config.TOCOptions())
+	return updated
+}
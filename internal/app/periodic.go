@@ -0,0 +1,133 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// LoadPeriodicSettings returns where periodic notes live, defaults filled in.
+func (a *App) LoadPeriodicSettings() (services.PeriodicSettings, error) {
+	settings, err := a.settings.Load()
+	if err != nil {
+		return services.DefaultPeriodicSettings(), err
+	}
+	return settings.PeriodicNotes.Normalize(), nil
+}
+
+// SavePeriodicSettings validates and stores the periodic note folders and formats.
+func (a *App) SavePeriodicSettings(periodic services.PeriodicSettings) error {
+	if err := periodic.Validate(); err != nil {
+		return err
+	}
+	settings, err := a.settings.Load()
+	if err != nil {
+		return err
+	}
+	settings.PeriodicNotes = periodic.Normalize()
+	return a.settings.Save(settings)
+}
+
+// OpenPeriodicNote returns the note of kind (daily, weekly, monthly or
+// yearly) for the period containing date (YYYY-MM-DD, today when empty).
+// A missing note is created from the kind's template, whose {{date}} is the
+// first day of the period.
+func (a *App) OpenPeriodicNote(kind string, date string) (CreatedNote, error) {
+	notes, err := a.periodicNotes()
+	if err != nil {
+		return CreatedNote{}, err
+	}
+	day := time.Now()
+	if date = strings.TrimSpace(date); date != "" {
+		if day, err = time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+			return CreatedNote{}, err
+		}
+	}
+	periodicKind := services.PeriodicKind(strings.ToLower(strings.TrimSpace(kind)))
+	path, err := notes.Path(periodicKind, day)
+	if err != nil {
+		return CreatedNote{}, err
+	}
+	if _, err := a.files.Lstat(path); err == nil {
+		return CreatedNote{Path: path}, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return CreatedNote{}, err
+	}
+
+	title, err := notes.Title(periodicKind, day)
+	if err != nil {
+		return CreatedNote{}, err
+	}
+	rendered := services.RenderedNote{Content: "# " + title + "\n\n", CursorLine: 3, CursorColumn: 1}
+	if name := a.periodicTemplate(periodicKind); name != "" {
+		if rendered, err = a.renderTemplate(name, map[string]string{"title": title}, services.PeriodStart(periodicKind, day)); err != nil {
+			return CreatedNote{}, err
+		}
+	}
+
+	if err := a.files.CreateDirectory(filepath.Dir(path)); err != nil {
+		return CreatedNote{}, err
+	}
+	if err := a.files.CreateNew(path, rendered.Content); errors.Is(err, os.ErrExist) {
+		// 渲染期间已被创建（例如另一个窗口），直接打开现有笔记
+		return CreatedNote{Path: path}, nil
+	} else if err != nil {
+		return CreatedNote{}, err
+	}
+
+	a.refreshIndexedFile(path)
+	a.filesChanged()
+	return CreatedNote{Path: path, Line: rendered.CursorLine, Column: rendered.CursorColumn}, nil
+}
+
+// AdjacentPeriodicNote returns the existing periodic note of the same kind
+// before (offset < 0) or after (offset > 0) the periodic note at path.
+func (a *App) AdjacentPeriodicNote(path string, offset int) (string, error) {
+	notes, err := a.periodicNotes()
+	if err != nil {
+		return "", err
+	}
+	note, err := notes.Adjacent(path, offset)
+	if err != nil {
+		return "", err
+	}
+	return note.Path, nil
+}
+
+// PeriodicCalendar lists the daily and weekly notes of a month (1-12) with
+// their word counts.
+func (a *App) PeriodicCalendar(year int, month int) (services.PeriodicCalendar, error) {
+	notes, err := a.periodicNotes()
+	if err != nil {
+		return services.PeriodicCalendar{}, err
+	}
+	return notes.Calendar(year, time.Month(month))
+}
+
+func (a *App) periodicNotes() (*services.PeriodicNotes, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return nil, errors.New("no folder is open")
+	}
+	settings, err := a.settings.Load()
+	if err != nil {
+		return nil, err
+	}
+	return services.NewPeriodicNotes(a.files, root, settings.PeriodicNotes), nil
+}
+
+func (a *App) periodicTemplate(kind services.PeriodicKind) string {
+	settings, err := a.settings.Load()
+	if err != nil {
+		return ""
+	}
+	config, err := settings.PeriodicNotes.Config(kind)
+	if err != nil {
+		return ""
+	}
+	return config.Template
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const eventWorkspaceIndexed = "workspace:indexed"
+
+// RunQuery evaluates a note query (e.g. `FROM "meetings" WHERE status = "open" SORT date DESC`)
+// against the opened workspace and returns the matching notes as table rows.
+func (a *App) RunQuery(query string) (services.QueryResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return services.QueryResult{}, errors.New("query is required")
+	}
+
+	if err := a.ensureWorkspaceIndex(); err != nil {
+		return services.QueryResult{}, err
+	}
+
+	return services.RunQuery(query, a.index.Notes())
+}
+
+// RefreshWorkspaceIndex rescans the opened workspace.
+func (a *App) RefreshWorkspaceIndex() error {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return errors.New("no folder is open")
+	}
+	return a.index.Build(root)
+}
+
+// activeWorkspaceRoot returns the opened folder, falling back to the active file's folder.
+func (a *App) activeWorkspaceRoot() string {
+	if a.workspaceRoot != "" {
+		return a.workspaceRoot
+	}
+	return a.currentFolderPath
+}
+
+func (a *App) ensureWorkspaceIndex() error {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return errors.New("no folder is open")
+	}
+	if filepath.Clean(root) == a.index.Root() {
+		return nil
+	}
+	return a.index.Build(root)
+}
+
+func (a *App) rebuildWorkspaceIndex(root string) {
+	a.workspaceRoot = root
+	go func() {
+		if err := a.index.Build(root); err != nil {
+			if a.ctx != nil {
+				runtime.LogErrorf(a.ctx, "failed indexing workspace '%s': %v", root, err)
+			}
+			return
+		}
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, eventWorkspaceIndexed, root)
+		}
+	}()
+}
+
+// reindexWorkspace rebuilds the index after files below root changed behind
+// the editor's back, e.g. by a sync or restore.
+func (a *App) reindexWorkspace(root string) {
+	if filepath.Clean(root) != a.index.Root() {
+		return
+	}
+	err := a.index.Build(root)
+	if a.ctx == nil {
+		return
+	}
+	if err != nil {
+		runtime.LogErrorf(a.ctx, "failed indexing workspace '%s': %v", root, err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, eventWorkspaceIndexed, root)
+}
+
+func (a *App) refreshIndexedFile(path string) {
+	if err := a.index.Update(path); err != nil && a.ctx != nil {
+		runtime.LogWarningf(a.ctx, "failed updating index for '%s': %v", path, err)
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// SplitNote splits the note at path, whose editor buffer is content, into
+// one note per heading of the chosen level. The note is rewritten as an
+// index linking the new notes; its new text is returned with the result.
+func (a *App) SplitNote(path string, content string, options services.SplitOptions) (services.SplitResult, error) {
+	if strings.TrimSpace(path) == "" {
+		return services.SplitResult{Files: []string{}}, errors.New("save the note before splitting it")
+	}
+	result, err := a.files.SplitNote(path, content, options)
+	// 部分文件可能已写入，出错时同样刷新索引和文件树
+	for _, file := range result.Files {
+		a.refreshIndexedFile(file)
+	}
+	if err == nil {
+		a.refreshIndexedFile(path)
+	}
+	if len(result.Files) > 0 {
+		a.filesChanged()
+	}
+	return result, err
+}
+
+// MergeNotes joins the notes at paths, in order, into a new note at target.
+// A relative target is placed in the workspace root. It is refused while any
+// of the notes has unsaved changes in the editor.
+func (a *App) MergeNotes(paths []string, target string, options services.MergeOptions) (services.MergeResult, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return services.MergeResult{Deleted: []string{}}, errors.New("a name for the merged note is required")
+	}
+	if !filepath.IsAbs(target) {
+		root := a.activeWorkspaceRoot()
+		if root == "" {
+			return services.MergeResult{Deleted: []string{}}, errors.New("no folder is open")
+		}
+		target = filepath.Join(root, target)
+	}
+	if !services.IsMarkdownFile(target) && filepath.Ext(target) == "" {
+		target += ".md"
+	}
+
+	// 合并读取磁盘内容并可能删除原笔记，未保存的修改会丢失
+	if err := a.checkNotDirty("merging", paths...); err != nil {
+		return services.MergeResult{Deleted: []string{}}, err
+	}
+
+	result, err := a.files.MergeNotes(paths, target, options)
+	if result.Content != "" {
+		a.refreshIndexedFile(result.Path)
+	}
+	for _, deleted := range result.Deleted {
+		a.index.Remove(deleted)
+	}
+	if result.Content != "" || len(result.Deleted) > 0 {
+		a.filesChanged()
+	}
+	return result, err
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// PreviewBatchRename returns the new names the options give the files of
+// folder, with collisions marked, without renaming anything.
+func (a *App) PreviewBatchRename(folder string, options services.RenameOptions) (services.RenamePreview, error) {
+	if strings.TrimSpace(folder) == "" {
+		return services.RenamePreview{Items: []services.RenameItem{}}, errors.New("a folder is required")
+	}
+	return a.files.PreviewBatchRename(folder, options)
+}
+
+// BatchRename applies the renames of a preview and rewrites the links of the
+// workspace notes that point at the renamed files. It is refused while any of
+// those files has unsaved changes in the editor.
+func (a *App) BatchRename(items []services.RenameItem) (services.RenameResult, error) {
+	if len(items) == 0 {
+		return services.RenameResult{Renamed: []services.RenameItem{}, Updated: []string{}}, nil
+	}
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		root = filepath.Dir(items[0].Path)
+	}
+
+	// 改名的文件和链接将被改写的笔记都不能有未保存的修改
+	paths := make([]string, 0, len(items))
+	for _, item := range items {
+		paths = append(paths, item.Path)
+	}
+	sources, err := a.files.BatchRenameLinkSources(root, items)
+	if err == nil {
+		err = a.checkNotDirty("renaming", append(paths, sources...)...)
+	}
+	if err != nil {
+		return services.RenameResult{Renamed: []services.RenameItem{}, Updated: []string{}}, err
+	}
+
+	result, err := a.files.BatchRename(root, items)
+	for _, item := range result.Renamed {
+		a.index.Remove(item.Path)
+		// 批次内名称可能互换，直接忘记密钥而不是随文件移动
+		a.notes.Lock(item.Path)
+	}
+	for _, item := range result.Renamed {
+		a.refreshIndexedFile(item.NewPath)
+	}
+	for _, path := range result.Updated {
+		a.refreshIndexedFile(path)
+	}
+	if len(result.Renamed) > 0 {
+		a.filesChanged()
+	}
+	return result, err
+}
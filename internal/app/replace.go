@@ -0,0 +1,86 @@
+package app
+
+import (
+	"errors"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const eventWorkspaceReplaced = "workspace:replaced"
+
+// PreviewReplaceInWorkspace returns every change a replacement would make in
+// the open workspace without writing anything.
+func (a *App) PreviewReplaceInWorkspace(options services.ReplaceOptions) (services.ReplacePreview, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return services.ReplacePreview{Files: []services.ReplaceFile{}}, errors.New("no folder is open")
+	}
+	return a.files.PreviewReplace(root, options)
+}
+
+// ReplaceInWorkspace applies the selected changes of a preview to the open
+// workspace. The replacement becomes the undo point of UndoReplaceInWorkspace.
+func (a *App) ReplaceInWorkspace(options services.ReplaceOptions, selections []services.ReplaceSelection) (services.ReplaceResult, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return services.ReplaceResult{Files: []string{}}, errors.New("no folder is open")
+	}
+
+	paths := make([]string, len(selections))
+	for i, selection := range selections {
+		paths[i] = selection.Path
+	}
+	if err := a.checkNotDirty("replacing", paths...); err != nil {
+		return services.ReplaceResult{Files: []string{}}, err
+	}
+
+	a.replaceMu.Lock()
+	defer a.replaceMu.Unlock()
+	result, undo, err := a.files.ReplaceInWorkspace(root, options, selections)
+	if err != nil {
+		return result, err
+	}
+	if len(result.Files) > 0 {
+		a.replaceUndo = undo
+		a.afterReplace(result)
+	}
+	return result, nil
+}
+
+// UndoReplaceInWorkspace restores every file of the last replacement.
+func (a *App) UndoReplaceInWorkspace() (services.ReplaceResult, error) {
+	a.replaceMu.Lock()
+	defer a.replaceMu.Unlock()
+	if a.replaceUndo == nil {
+		return services.ReplaceResult{Files: []string{}}, errors.New("nothing to undo")
+	}
+	if err := a.checkNotDirty("undoing", a.replaceUndo.Files()...); err != nil {
+		return services.ReplaceResult{Files: []string{}}, err
+	}
+	result, err := a.replaceUndo.Revert()
+	if err != nil {
+		return result, err
+	}
+	a.replaceUndo = nil
+	a.afterReplace(result)
+	return result, nil
+}
+
+// CanUndoReplaceInWorkspace reports whether a replacement can be undone.
+func (a *App) CanUndoReplaceInWorkspace() bool {
+	a.replaceMu.Lock()
+	defer a.replaceMu.Unlock()
+	return a.replaceUndo != nil
+}
+
+// afterReplace reindexes the rewritten files and tells the frontend to
+// reload the open documents among them.
+func (a *App) afterReplace(result services.ReplaceResult) {
+	for _, file := range result.Files {
+		a.refreshIndexedFile(file)
+	}
+	a.filesChanged()
+	runtime.EventsEmit(a.ctx, eventWorkspaceReplaced, result)
+}
@@ -0,0 +1,408 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+	"github.com/yourname/MarkdownDaoNote/pkg/client"
+)
+
+// 脚本 API：实例 socket 上的 JSON-RPC 2.0 会话。每个连接必须先调用 auth，
+// 令牌由主实例写入运行时目录中仅当前用户可读的 rpc.token。
+// 文档缓冲区属于前端，相关方法通过 rpc:request 事件转交前端处理，
+// 前端以 CompleteRPCRequest 回传结果。
+
+const (
+	eventRPCRequest = "rpc:request"
+
+	// 仅由前端处理、不对外公开的方法：保存成功后更新标签页的已保存内容
+	frontendMethodMarkSaved = "editor.markSaved"
+
+	frontendRPCTimeout = 10 * time.Second
+)
+
+var errEditorNotReady = errors.New("editor is not ready")
+
+// rpcFrontendReply carries the frontend's answer to a forwarded request.
+type rpcFrontendReply struct {
+	result json.RawMessage
+	err    error
+}
+
+// isRPCMessage reports whether the first message of a connection is JSON-RPC.
+func isRPCMessage(line []byte) bool {
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+	}
+	return json.Unmarshal(line, &probe) == nil && probe.JSONRPC != ""
+}
+
+// publishRPCToken writes a fresh random token for this primary instance.
+func (sim *SingleInstanceManager) publishRPCToken() error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+
+	path := sim.endpoint.TokenPath
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// 先删除再以 O_EXCL 创建，避免沿用他人预先放置的文件或符号链接
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(token + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	sim.tokenMu.Lock()
+	sim.rpcToken = token
+	sim.tokenMu.Unlock()
+	return nil
+}
+
+func (sim *SingleInstanceManager) removeRPCToken() {
+	sim.tokenMu.Lock()
+	token := sim.rpcToken
+	sim.rpcToken = ""
+	sim.tokenMu.Unlock()
+	if token == "" {
+		return
+	}
+	if err := os.Remove(sim.endpoint.TokenPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: failed to remove rpc token: %v", err)
+	}
+}
+
+// serveRPC answers JSON-RPC requests until the client disconnects.
+func (sim *SingleInstanceManager) serveRPC(conn net.Conn, reader *bufio.Reader, first []byte) {
+	authenticated := false
+	line := first
+
+	for {
+		if response, ok := sim.handleRPCLine(line, &authenticated); ok {
+			if err := writeIPCMessage(conn, response); err != nil {
+				log.Printf("Failed to reply to rpc client: %v", err)
+				return
+			}
+		}
+
+		var err error
+		line, err = readIPCLine(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("RPC connection closed: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// handleRPCLine processes one request. It returns false for notifications,
+// which get no response.
+func (sim *SingleInstanceManager) handleRPCLine(line []byte, authenticated *bool) (client.Response, bool) {
+	response := client.Response{JSONRPC: client.Version, ID: json.RawMessage("null")}
+
+	var request client.Request
+	if err := json.Unmarshal(line, &request); err != nil {
+		response.Error = &client.Error{Code: client.CodeParseError, Message: err.Error()}
+		return response, true
+	}
+	if len(request.ID) > 0 {
+		response.ID = request.ID
+	}
+	if request.JSONRPC != client.Version || request.Method == "" {
+		response.Error = &client.Error{Code: client.CodeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		return response, true
+	}
+
+	var result interface{}
+	var rpcErr *client.Error
+	switch {
+	case request.Method == client.MethodAuth:
+		rpcErr = sim.authenticate(request.Params)
+		*authenticated = rpcErr == nil
+	case !*authenticated:
+		rpcErr = &client.Error{Code: client.CodeUnauthorized, Message: "call auth first"}
+	default:
+		result, rpcErr = sim.app.handleRPC(request.Method, request.Params)
+	}
+
+	if len(request.ID) == 0 {
+		return response, false
+	}
+	if rpcErr != nil {
+		response.Error = rpcErr
+		return response, true
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		response.Error = &client.Error{Code: client.CodeInternalError, Message: err.Error()}
+		return response, true
+	}
+	response.Result = data
+	return response, true
+}
+
+func (sim *SingleInstanceManager) authenticate(raw json.RawMessage) *client.Error {
+	var params client.AuthParams
+	if err := decodeRPCParams(raw, &params); err != nil {
+		return err
+	}
+	sim.tokenMu.Lock()
+	token := sim.rpcToken
+	sim.tokenMu.Unlock()
+	if token == "" || subtle.ConstantTimeCompare([]byte(params.Token), []byte(token)) != 1 {
+		return &client.Error{Code: client.CodeUnauthorized, Message: "invalid token"}
+	}
+	return nil
+}
+
+// handleRPC runs an authenticated scripting request.
+func (a *App) handleRPC(method string, raw json.RawMessage) (interface{}, *client.Error) {
+	switch method {
+	case client.MethodListTabs, client.MethodGetBuffer, client.MethodSetBuffer, client.MethodInsertText:
+		result, err := a.callFrontend(method, raw)
+		if err != nil {
+			return nil, rpcError(err)
+		}
+		return result, nil
+
+	case client.MethodOpen:
+		var params client.OpenParams
+		if err := decodeRPCParams(raw, &params); err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(params.Path) {
+			return nil, &client.Error{Code: client.CodeInvalidParams, Message: "path must be absolute"}
+		}
+		waiter, err := a.openLaunchRequest(LaunchRequest{Files: []OpenTarget{{
+			Path:   filepath.Clean(params.Path),
+			Line:   params.Line,
+			Column: params.Column,
+		}}})
+		if err != nil {
+			return nil, rpcError(err)
+		}
+		defer a.waiters.forget(waiter)
+		// 等前端确认打开后再应答，脚本随后的 getBuffer 才能找到这个标签
+		select {
+		case <-waiter.opened:
+		case <-time.After(frontendRPCTimeout):
+			return nil, rpcError(fmt.Errorf("editor did not open %s in time", params.Path))
+		}
+		if waiter.err != nil {
+			return nil, rpcError(waiter.err)
+		}
+		return nil, nil
+
+	case client.MethodSave:
+		var params client.PathParams
+		if err := decodeRPCParams(raw, &params); err != nil {
+			return nil, err
+		}
+		path, err := a.saveBuffer(params.Path)
+		if err != nil {
+			return nil, rpcError(err)
+		}
+		return client.PathResult{Path: path}, nil
+
+	case client.MethodExport:
+		var params client.ExportParams
+		if err := decodeRPCParams(raw, &params); err != nil {
+			return nil, err
+		}
+		path, err := a.exportBuffer(params)
+		if err != nil {
+			return nil, rpcError(err)
+		}
+		return client.PathResult{Path: path}, nil
+	}
+
+	return nil, &client.Error{Code: client.CodeMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+// saveBuffer writes the editor buffer of path (or the active tab) to disk.
+func (a *App) saveBuffer(path string) (string, error) {
+	buffer, err := a.frontendBuffer(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(buffer.Path) {
+		return "", fmt.Errorf("%q has never been saved; save it from the editor first", buffer.Path)
+	}
+
+	saved, err := a.writeDocument(buffer.Path, buffer.Content)
+	if err != nil {
+		return "", err
+	}
+
+	if saved != buffer.Content {
+		// 保存时整理过的文本写回这个缓冲区，而不是当前标签页
+		if _, err := a.callFrontend(client.MethodSetBuffer, client.SetBufferParams{Path: buffer.Path, Content: saved}); err != nil {
+			log.Printf("Failed to update buffer %s: %v", buffer.Path, err)
+		}
+	}
+	if _, err := a.callFrontend(frontendMethodMarkSaved, client.SetBufferParams{Path: buffer.Path, Content: saved}); err != nil {
+		log.Printf("Failed to mark %s as saved: %v", buffer.Path, err)
+	}
+	return buffer.Path, nil
+}
+
+// exportBuffer renders the editor buffer of params.Path to params.Output.
+func (a *App) exportBuffer(params client.ExportParams) (string, error) {
+	if !filepath.IsAbs(params.Output) {
+		return "", errors.New("output must be an absolute path")
+	}
+	buffer, err := a.frontendBuffer(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered string
+	switch strings.ToLower(params.Format) {
+	case "", "html":
+		title := strings.TrimSuffix(filepath.Base(buffer.Path), filepath.Ext(buffer.Path))
+		rendered, err = services.RenderHTMLDocument(buffer.Content, title)
+	case "text":
+		rendered = services.RenderPlainText(buffer.Content)
+	default:
+		return "", fmt.Errorf("unsupported export format %q", params.Format)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	output := filepath.Clean(params.Output)
+	if err := a.files.CreateDirectory(filepath.Dir(output)); err != nil {
+		return "", err
+	}
+	if err := a.files.Write(output, rendered); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+func (a *App) frontendBuffer(path string) (client.Buffer, error) {
+	var buffer client.Buffer
+	raw, err := a.callFrontend(client.MethodGetBuffer, client.PathParams{Path: path})
+	if err != nil {
+		return buffer, err
+	}
+	err = json.Unmarshal(raw, &buffer)
+	return buffer, err
+}
+
+// callFrontend forwards a request to the frontend and waits for its reply.
+func (a *App) callFrontend(method string, params interface{}) (json.RawMessage, error) {
+	a.launchMu.Lock()
+	ready := a.editorReady
+	a.launchMu.Unlock()
+	if a.ctx == nil || !ready {
+		return nil, errEditorNotReady
+	}
+
+	payload, ok := params.(json.RawMessage)
+	if !ok {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		payload = data
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		payload = json.RawMessage("{}")
+	}
+
+	reply := make(chan rpcFrontendReply, 1)
+	a.rpcMu.Lock()
+	a.rpcSeq++
+	id := strconv.FormatUint(a.rpcSeq, 10)
+	if a.rpcPending == nil {
+		a.rpcPending = map[string]chan rpcFrontendReply{}
+	}
+	a.rpcPending[id] = reply
+	a.rpcMu.Unlock()
+
+	defer func() {
+		a.rpcMu.Lock()
+		delete(a.rpcPending, id)
+		a.rpcMu.Unlock()
+	}()
+
+	runtime.EventsEmit(a.ctx, eventRPCRequest, id, method, string(payload))
+
+	select {
+	case answer := <-reply:
+		return answer.result, answer.err
+	case <-time.After(frontendRPCTimeout):
+		return nil, fmt.Errorf("editor did not answer %s in time", method)
+	}
+}
+
+// CompleteRPCRequest is called by the frontend with the JSON result of a
+// forwarded scripting request, or with an error message.
+func (a *App) CompleteRPCRequest(id string, result string, message string) {
+	// 取出即删除：重复的回复找不到通道，缓冲为 1 的发送不会阻塞
+	a.rpcMu.Lock()
+	reply, ok := a.rpcPending[id]
+	delete(a.rpcPending, id)
+	a.rpcMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if message != "" {
+		reply <- rpcFrontendReply{err: errors.New(message)}
+		return
+	}
+	if strings.TrimSpace(result) == "" {
+		result = "null"
+	}
+	reply <- rpcFrontendReply{result: json.RawMessage(result)}
+}
+
+func decodeRPCParams(raw json.RawMessage, target interface{}) *client.Error {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return &client.Error{Code: client.CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func rpcError(err error) *client.Error {
+	if errors.Is(err, errEditorNotReady) {
+		return &client.Error{Code: client.CodeNotReady, Message: err.Error()}
+	}
+	return &client.Error{Code: client.CodeInternalError, Message: err.Error()}
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/pkg/client"
+)
+
+func authParams(t *testing.T, token string) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(client.AuthParams{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestAuthenticate(t *testing.T) {
+	sim := &SingleInstanceManager{endpoint: client.Endpoint{TokenPath: filepath.Join(t.TempDir(), "rpc.token")}}
+	if err := sim.publishRPCToken(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(sim.endpoint.TokenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := strings.TrimSpace(string(data))
+
+	tests := []struct {
+		name   string
+		token  string
+		remove bool
+		ok     bool
+	}{
+		{name: "published token", token: token, ok: true},
+		{name: "wrong token", token: strings.Repeat("0", len(token))},
+		{name: "empty token", token: ""},
+		{name: "after removal", token: token, remove: true},
+		{name: "empty token after removal", token: "", remove: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.remove {
+				sim.removeRPCToken()
+				if _, err := os.Stat(sim.endpoint.TokenPath); !os.IsNotExist(err) {
+					t.Fatalf("token file still present: %v", err)
+				}
+			}
+			if rpcErr := sim.authenticate(authParams(t, tt.token)); (rpcErr == nil) != tt.ok {
+				t.Fatalf("authenticate(%q) = %v, want ok=%v", tt.token, rpcErr, tt.ok)
+			}
+		})
+	}
+}
+
+// 连接处理协程认证时，主实例可能正在重新发布或清除令牌，配合 -race 运行
+func TestAuthenticateConcurrentWithTokenChanges(t *testing.T) {
+	sim := &SingleInstanceManager{endpoint: client.Endpoint{TokenPath: filepath.Join(t.TempDir(), "rpc.token")}}
+	raw := authParams(t, "guess")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if err := sim.publishRPCToken(); err != nil {
+				t.Error(err)
+				return
+			}
+			sim.removeRPCToken()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if rpcErr := sim.authenticate(raw); rpcErr == nil {
+				t.Error("a guessed token was accepted")
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// 前端可能对同一请求回复两次（或在超时后才回复），都不能阻塞绑定调用
+func TestCompleteRPCRequestAnswersOnce(t *testing.T) {
+	a := &App{}
+	reply := make(chan rpcFrontendReply, 1)
+	a.rpcPending = map[string]chan rpcFrontendReply{"1": reply}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.CompleteRPCRequest("1", `{"ok":true}`, "")
+		a.CompleteRPCRequest("1", "", "late failure")
+		a.CompleteRPCRequest("2", "null", "")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CompleteRPCRequest blocked on a repeated reply")
+	}
+
+	answer := <-reply
+	if answer.err != nil || string(answer.result) != `{"ok":true}` {
+		t.Errorf("reply = %s, %v", answer.result, answer.err)
+	}
+	if len(a.rpcPending) != 0 {
+		t.Errorf("pending = %v, want none", a.rpcPending)
+	}
+}
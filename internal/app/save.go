@@ -48,7 +48,13 @@ func (a *App) SaveDocument(content string, forceDialog bool) (string, error) {
 		return "", errors.New("no target file selected")
 	}
 
-	if err := a.files.Write(targetPath, content); err != nil {
+	saved, err := a.writeDocument(targetPath, content)
+	if err != nil {
+		if isNoteKeyError(err) {
+			// 前端询问口令后以 forceDialog=false 重试，保存到同一目标
+			a.setCurrentFile(targetPath)
+			return "", err
+		}
 		runtime.LogErrorf(a.ctx, "failed writing '%s': %v", targetPath, err)
 		_, _ = runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
 			Type:    runtime.ErrorDialog,
@@ -57,8 +63,33 @@ func (a *App) SaveDocument(content string, forceDialog bool) (string, error) {
 		})
 		return "", err
 	}
+	if saved != content {
+		// 保存前整理过的文本同步回编辑器
+		runtime.EventsEmit(a.ctx, eventDocumentFormatted, targetPath, saved)
+	}
 
 	a.setCurrentFile(targetPath)
 	runtime.EventsEmit(a.ctx, eventFileSaved, targetPath)
 	return targetPath, nil
 }
+
+// writeDocument runs the save pipeline shared by the editor and the RPC
+// server: format and table of contents on save, the write itself, the
+// workspace index and the writing history. It returns the content written.
+func (a *App) writeDocument(path string, content string) (string, error) {
+	prepared := a.tocOnSave(path, a.formatOnSave(path, content))
+	if strings.TrimSpace(prepared) != "" {
+		content = prepared
+	}
+	wordsBefore, trackWriting := a.wordsOnDisk(path)
+	if err := a.writeNote(path, content); err != nil {
+		return "", err
+	}
+
+	a.refreshIndexedFile(path)
+	if trackWriting {
+		a.recordWriting(wordsBefore, content)
+	}
+	a.filesChanged()
+	return content, nil
+}
@@ -1,24 +1,69 @@
 package app
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
-	"os"
-	"path/filepath"
-	"runtime"
+	"sync"
+	"time"
 
 	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/pkg/client"
+)
+
+const (
+	// 主实例可能已持有锁但尚未开始监听，次要实例在此时间内重试连接
+	instanceDialRetry = 5 * time.Second
+	// 等待主实例确认请求的超时
+	instanceAckTimeout = 10 * time.Second
+	// 主实例等待前端打开文件的时间，短于 instanceAckTimeout，让对方收到错误而不是超时
+	instanceOpenTimeout = 8 * time.Second
+	// 新建笔记要等用户在对话框中确认
+	instanceConfirmTimeout = 5 * time.Minute
 )
 
+// openTimeout is how long the primary waits for the editor to open request.
+func openTimeout(request LaunchRequest) time.Duration {
+	if request.New != nil {
+		return instanceConfirmTimeout
+	}
+	return instanceOpenTimeout
+}
+
+// ackTimeout is how long a secondary waits for the primary to acknowledge request.
+func ackTimeout(request LaunchRequest) time.Duration {
+	return openTimeout(request) + instanceAckTimeout - instanceOpenTimeout
+}
+
+// instanceTransport 抽象单实例锁与进程间通信通道：
+// Unix 使用 flock + Unix socket，Windows 使用 LockFileEx + 命名管道
+type instanceTransport interface {
+	// Lock 以非阻塞方式获取单实例锁，返回 false 表示其他进程持有锁
+	Lock() (bool, error)
+	Unlock() error
+	Listen() (net.Listener, error)
+	Dial(timeout time.Duration) (net.Conn, error)
+	Cleanup() error
+	Address() string
+}
+
 // SingleInstanceManager 管理单实例模式
 type SingleInstanceManager struct {
-	appName    string
-	socketPath string
-	listener   net.Listener
-	ctx        context.Context
-	app        *App
+	appName   string
+	endpoint  client.Endpoint
+	transport instanceTransport
+	primary   bool
+	listener  net.Listener
+	tokenMu   sync.Mutex // 连接处理协程读取令牌时，退出流程可能正在清除它
+	rpcToken  string
+	ctx       context.Context
+	app       *App
 }
 
 // NewSingleInstanceManager 创建单实例管理器
@@ -29,94 +74,152 @@ func NewSingleInstanceManager(appName string, app *App) *SingleInstanceManager {
 	}
 }
 
-// CheckAndHandleSingleInstance 检查并处理单实例模式
-func (sim *SingleInstanceManager) CheckAndHandleSingleInstance(ctx context.Context) (bool, error) {
-	sim.ctx = ctx
-
-	// 设置socket路径
-	if err := sim.setupSocketPath(); err != nil {
+// Forward 尝试把启动请求转发给已运行的实例。
+// 返回 true 表示已有主实例处理了请求，当前进程应退出；
+// 请求带 --wait 时会阻塞到主实例报告文件已关闭。
+// 返回 false 且当前进程取得了单实例锁时，随后的 Listen 会让它成为主实例。
+func (sim *SingleInstanceManager) Forward(request LaunchRequest) (bool, error) {
+	if err := sim.setupTransport(); err != nil {
 		return false, err
 	}
 
-	// 尝试连接到已运行的实例
-	if sim.tryConnectToExistingInstance() {
-		log.Println("Found existing instance, forwarding arguments...")
-		return true, nil
+	locked, err := sim.transport.Lock()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire instance lock: %w", err)
 	}
-
-	// 启动监听器
-	if err := sim.startListener(); err != nil {
-		return false, err
+	if locked {
+		sim.primary = true
+		return false, nil
 	}
 
-	log.Println("Starting as primary instance")
-	return false, nil
+	// 锁被占用说明主实例进程存活（进程退出时系统会自动释放锁）
+	forwarded, err := sim.tryConnectToExistingInstance(request)
+	if !forwarded {
+		// 主实例无响应：作为独立窗口启动，但不接管监听
+		log.Printf("Existing instance holds the lock but is not reachable at %s", sim.transport.Address())
+	}
+	return forwarded, err
 }
 
-// setupSocketPath 设置socket路径
-func (sim *SingleInstanceManager) setupSocketPath() error {
-	var socketDir string
+// Listen 作为主实例启动监听，接收后续实例转发的启动请求
+func (sim *SingleInstanceManager) Listen(ctx context.Context) error {
+	sim.ctx = ctx
 
-	switch runtime.GOOS {
-	case "windows":
-		// Windows: 使用临时目录
-		socketDir = os.TempDir()
-		sim.socketPath = filepath.Join(socketDir, fmt.Sprintf("%s.sock", sim.appName))
-	case "darwin", "linux":
-		// Unix系统: 使用用户目录
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
+	if sim.transport == nil {
+		if err := sim.setupTransport(); err != nil {
 			return err
 		}
-		socketDir = filepath.Join(homeDir, ".config", sim.appName)
-		if err := os.MkdirAll(socketDir, 0755); err != nil {
-			return err
+		locked, err := sim.transport.Lock()
+		if err != nil {
+			return fmt.Errorf("failed to acquire instance lock: %w", err)
 		}
-		sim.socketPath = filepath.Join(socketDir, "instance.sock")
-	default:
-		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+		sim.primary = locked
 	}
 
+	if !sim.primary {
+		log.Println("Another instance holds the lock; not listening for launch requests")
+		return nil
+	}
+
+	if err := sim.startListener(); err != nil {
+		return err
+	}
+
+	log.Println("Starting as primary instance")
 	return nil
 }
 
-// tryConnectToExistingInstance 尝试连接到已运行的实例
-func (sim *SingleInstanceManager) tryConnectToExistingInstance() bool {
-	// 尝试连接socket
-	conn, err := net.Dial("unix", sim.socketPath)
+// setupTransport 初始化平台相关的锁与通信通道
+func (sim *SingleInstanceManager) setupTransport() error {
+	if sim.transport != nil {
+		return nil
+	}
+	endpoint, err := client.EndpointFor(sim.appName)
 	if err != nil {
-		return false
+		return err
 	}
-	defer conn.Close()
+	transport, err := newInstanceTransport(endpoint)
+	if err != nil {
+		return err
+	}
+	sim.endpoint = endpoint
+	sim.transport = transport
+	return nil
+}
 
-	// 发送启动参数
-	args := os.Args[1:]
-	if len(args) > 0 {
-		message := fmt.Sprintf("open-file:%s", args[0])
-		_, err = conn.Write([]byte(message))
-		if err != nil {
-			log.Printf("Failed to send message to existing instance: %v", err)
+// tryConnectToExistingInstance 尝试连接到已运行的实例
+func (sim *SingleInstanceManager) tryConnectToExistingInstance(request LaunchRequest) (bool, error) {
+	// 主实例可能刚取得锁还未开始监听，短暂重试
+	var conn net.Conn
+	deadline := time.Now().Add(instanceDialRetry)
+	for {
+		var err error
+		conn, err = sim.transport.Dial(time.Second)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return false, nil
 		}
+		time.Sleep(100 * time.Millisecond)
 	}
+	defer conn.Close()
 
-	return true
+	log.Println("Found existing instance, forwarding arguments...")
+
+	command := ipcCommandOpen
+	if request.Empty() {
+		command = ipcCommandFocus
+	}
+	message := ipcRequest{Version: ipcProtocolVersion, Command: command, LaunchRequest: request}
+	if err := writeIPCMessage(conn, message); err != nil {
+		return true, fmt.Errorf("failed to send message to existing instance: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(ackTimeout(request)))
+	if err := expectIPCStatus(reader, ipcStatusAccepted); err != nil {
+		return true, err
+	}
+	if !request.Wait {
+		return true, nil
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	// --wait：等待主实例通知所有文件已关闭
+	return true, expectIPCStatus(reader, ipcStatusClosed)
 }
 
-// startListener 启动监听器
-func (sim *SingleInstanceManager) startListener() error {
-	// 清理旧的socket文件
-	if err := os.Remove(sim.socketPath); err != nil && !os.IsNotExist(err) {
-		log.Printf("Warning: failed to remove old socket: %v", err)
+// expectIPCStatus reads one response and checks it carries the wanted status.
+func expectIPCStatus(reader *bufio.Reader, status string) error {
+	var response ipcResponse
+	if err := readIPCMessage(reader, &response); err != nil {
+		return fmt.Errorf("no response from existing instance: %w", err)
+	}
+	if !response.OK {
+		return fmt.Errorf("existing instance rejected request: %s", response.Error)
 	}
+	if response.Status != status {
+		return fmt.Errorf("unexpected response from existing instance: %q", response.Status)
+	}
+	return nil
+}
 
-	// 创建Unix socket监听器
-	listener, err := net.Listen("unix", sim.socketPath)
+// startListener 启动监听器
+func (sim *SingleInstanceManager) startListener() error {
+	// 持有锁时残留的 socket 一定来自已退出的进程，由 transport 负责清理
+	listener, err := sim.transport.Listen()
 	if err != nil {
-		return fmt.Errorf("failed to create listener: %v", err)
+		return fmt.Errorf("failed to create listener on %s: %w", sim.transport.Address(), err)
 	}
 
 	sim.listener = listener
 
+	// 脚本 API 的令牌只对当前用户可读，每次启动重新生成
+	if err := sim.publishRPCToken(); err != nil {
+		log.Printf("Scripting API disabled: %v", err)
+	}
+
 	// 启动goroutine处理连接
 	go sim.handleConnections()
 
@@ -128,7 +231,7 @@ func (sim *SingleInstanceManager) handleConnections() {
 	for {
 		conn, err := sim.listener.Accept()
 		if err != nil {
-			if sim.ctx.Err() != nil {
+			if errors.Is(err, net.ErrClosed) || sim.ctx.Err() != nil {
 				// 应用正在关闭
 				return
 			}
@@ -144,44 +247,134 @@ func (sim *SingleInstanceManager) handleConnections() {
 func (sim *SingleInstanceManager) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
+	reader := bufio.NewReader(conn)
+	line, err := readIPCLine(reader)
 	if err != nil {
 		log.Printf("Failed to read from connection: %v", err)
+		sim.reply(conn, ipcResponse{Error: err.Error()})
 		return
 	}
 
-	message := string(buffer[:n])
-	log.Printf("Received message from new instance: %s", message)
+	if isRPCMessage(line) {
+		sim.serveRPC(conn, reader, line)
+		return
+	}
 
-	// 解析消息
-	if len(message) > 10 && message[:10] == "open-file:" {
-		filePath := message[10:]
-		if filePath != "" {
-			// 将文件路径传递给前端
-			wailsRuntime.EventsEmit(sim.ctx, "open-file", filePath)
+	var request ipcRequest
+	if err := json.Unmarshal(line, &request); err != nil {
+		log.Printf("Failed to read from connection: %v", err)
+		sim.reply(conn, ipcResponse{Error: fmt.Sprintf("invalid ipc message: %v", err)})
+		return
+	}
 
-			// 聚焦窗口但保持最大化状态
-			//wailsRuntime.WindowUnminimise(sim.ctx)
+	log.Printf("Received %q request from new instance: %d file(s), folder %q, wait=%v",
+		request.Command, len(request.Files), request.Folder, request.Wait)
+
+	if request.Version > ipcProtocolVersion {
+		sim.reply(conn, ipcResponse{Error: fmt.Sprintf("unsupported protocol version %d", request.Version)})
+		return
+	}
+
+	switch request.Command {
+	case ipcCommandPing:
+		sim.reply(conn, ipcResponse{OK: true, Status: ipcStatusPong})
+		return
+	case ipcCommandFocus:
+		if sim.ctx != nil {
 			wailsRuntime.WindowShow(sim.ctx)
-			// 确保窗口保持最大化状态
-			//wailsRuntime.WindowToggleMaximise(sim.ctx)
 		}
+		sim.reply(conn, ipcResponse{OK: true, Status: ipcStatusAccepted})
+		return
+	case ipcCommandOpen:
+	default:
+		sim.reply(conn, ipcResponse{Error: fmt.Sprintf("unknown command %q", request.Command)})
+		return
+	}
+
+	if request.Wait && len(request.Files) == 0 {
+		sim.reply(conn, ipcResponse{Error: "--wait requires a file"})
+		return
+	}
+
+	// 客户端断开时取消等待，避免协程一直挂到文件关闭
+	connCtx, cancel := sim.connectionContext(reader)
+	defer cancel()
+
+	// 将文件交给前端打开并聚焦窗口，前端确认打开后再应答
+	waiter, err := sim.app.openLaunchRequest(request.LaunchRequest)
+	if err != nil {
+		sim.reply(conn, ipcResponse{Error: err.Error()})
+		return
+	}
+	defer sim.app.waiters.forget(waiter)
+
+	select {
+	case <-waiter.opened:
+	case <-connCtx.Done():
+		return
+	case <-time.After(openTimeout(request.LaunchRequest)):
+		sim.reply(conn, ipcResponse{Error: "the editor did not open the files in time"})
+		return
+	}
+	if waiter.err != nil {
+		sim.reply(conn, ipcResponse{Error: waiter.err.Error()})
+		return
+	}
+	if !sim.reply(conn, ipcResponse{OK: true, Status: ipcStatusAccepted}) || !request.Wait {
+		return
+	}
+
+	select {
+	case <-waiter.done:
+		sim.reply(conn, ipcResponse{OK: true, Status: ipcStatusClosed})
+	case <-connCtx.Done():
 	}
 }
 
+// connectionContext returns a context that ends when the client disconnects
+// or the application shuts down. The client sends nothing after its request,
+// so anything read from reader is discarded.
+func (sim *SingleInstanceManager) connectionContext(reader io.Reader) (context.Context, context.CancelFunc) {
+	parent := sim.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		_, _ = io.Copy(io.Discard, reader)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// reply 发送应答，失败时记录日志并返回 false
+func (sim *SingleInstanceManager) reply(conn net.Conn, response ipcResponse) bool {
+	response.Version = ipcProtocolVersion
+	if err := writeIPCMessage(conn, response); err != nil {
+		log.Printf("Failed to reply to new instance: %v", err)
+		return false
+	}
+	return true
+}
+
 // Close 关闭单实例管理器
 func (sim *SingleInstanceManager) Close() error {
 	if sim.listener != nil {
 		if err := sim.listener.Close(); err != nil {
-			return err
+			log.Printf("Warning: failed to close listener: %v", err)
 		}
+		sim.listener = nil
 	}
 
-	// 清理socket文件
-	if err := os.Remove(sim.socketPath); err != nil && !os.IsNotExist(err) {
-		log.Printf("Warning: failed to remove socket file: %v", err)
+	if sim.transport == nil || !sim.primary {
+		return nil
 	}
 
-	return nil
+	// 先清理socket文件再释放锁，避免误删下一个主实例的 socket
+	if err := sim.transport.Cleanup(); err != nil {
+		log.Printf("Warning: failed to remove socket file: %v", err)
+	}
+	sim.removeRPCToken()
+	sim.primary = false
+	return sim.transport.Unlock()
 }
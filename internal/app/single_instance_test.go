@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// openOverPipe sends an open request to handleConnection and returns the
+// client side of the connection.
+func openOverPipe(t *testing.T, sim *SingleInstanceManager, request LaunchRequest) (net.Conn, *bufio.Reader, chan struct{}) {
+	t.Helper()
+	server, conn := net.Pipe()
+	finished := make(chan struct{})
+	go func() {
+		sim.handleConnection(server)
+		close(finished)
+	}()
+	message := ipcRequest{Version: ipcProtocolVersion, Command: ipcCommandOpen, LaunchRequest: request}
+	if err := writeIPCMessage(conn, message); err != nil {
+		t.Fatal(err)
+	}
+	return conn, bufio.NewReader(conn), finished
+}
+
+func readResponse(t *testing.T, reader *bufio.Reader) ipcResponse {
+	t.Helper()
+	var response ipcResponse
+	if err := readIPCMessage(reader, &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestHandleConnectionOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		opened bool
+	}{
+		{name: "acknowledged once open", opened: true},
+		{name: "editor failed to open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{files: services.NewFileService()}
+			sim := &SingleInstanceManager{app: a}
+			path := filepath.Join(t.TempDir(), "a.md")
+
+			conn, reader, _ := openOverPipe(t, sim, LaunchRequest{Files: []OpenTarget{{Path: path}}})
+			defer conn.Close()
+			// 编辑器尚未就绪，请求排队，由测试扮演前端
+			waitForPending(t, a)
+			if tt.opened {
+				a.NotifyDocumentOpened(path)
+			} else {
+				a.NotifyDocumentClosed(path)
+			}
+
+			want := ipcResponse{Version: ipcProtocolVersion, OK: true, Status: ipcStatusAccepted}
+			if !tt.opened {
+				want = ipcResponse{Version: ipcProtocolVersion, Error: "the editor could not open " + path}
+			}
+			if got := readResponse(t, reader); got != want {
+				t.Errorf("response = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandleConnectionWaitEndsWhenClientLeaves(t *testing.T) {
+	a := &App{files: services.NewFileService()}
+	sim := &SingleInstanceManager{app: a}
+	path := filepath.Join(t.TempDir(), "a.md")
+
+	conn, reader, finished := openOverPipe(t, sim, LaunchRequest{Files: []OpenTarget{{Path: path}}, Wait: true})
+	waitForPending(t, a)
+	a.NotifyDocumentOpened(path)
+	if got := readResponse(t, reader); got.Status != ipcStatusAccepted {
+		t.Fatalf("response = %+v, want accepted", got)
+	}
+
+	// 文件仍然打开，客户端先断开
+	conn.Close()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still waiting after the client disconnected")
+	}
+	a.waiters.mu.Lock()
+	left := len(a.waiters.pending)
+	a.waiters.mu.Unlock()
+	if left != 0 {
+		t.Errorf("%d waiters left after the client disconnected", left)
+	}
+}
+
+func waitForPending(t *testing.T, a *App) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a.launchMu.Lock()
+		queued := len(a.pendingLaunches)
+		a.launchMu.Unlock()
+		if queued > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("launch request was not queued")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,125 @@
+//go:build !windows
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/yourname/MarkdownDaoNote/pkg/client"
+)
+
+// unixTransport 使用 flock 锁文件 + Unix socket，均位于仅当前用户可访问的运行时目录
+type unixTransport struct {
+	lockPath   string
+	socketPath string
+	lockFile   *os.File
+}
+
+func newInstanceTransport(endpoint client.Endpoint) (instanceTransport, error) {
+	if err := secureRuntimeDir(endpoint.Dir); err != nil {
+		return nil, err
+	}
+	return &unixTransport{
+		lockPath:   filepath.Join(endpoint.Dir, "instance.lock"),
+		socketPath: endpoint.Address,
+	}, nil
+}
+
+// secureRuntimeDir 创建仅当前用户可访问的运行时目录
+func secureRuntimeDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// 共享临时目录中可能被他人抢先创建，必须确认归属与权限
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by another user", dir)
+	}
+	if info.Mode().Perm() != 0o700 {
+		return os.Chmod(dir, 0o700)
+	}
+	return nil
+}
+
+func (t *unixTransport) Address() string {
+	return t.socketPath
+}
+
+func (t *unixTransport) Lock() (bool, error) {
+	file, err := os.OpenFile(t.lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return false, err
+	}
+
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// 记录持有者 pid，便于排查
+	_ = file.Truncate(0)
+	_, _ = fmt.Fprintf(file, "%d\n", os.Getpid())
+	t.lockFile = file
+	return true, nil
+}
+
+func (t *unixTransport) Unlock() error {
+	if t.lockFile == nil {
+		return nil
+	}
+	err := unix.Flock(int(t.lockFile.Fd()), unix.LOCK_UN)
+	closeErr := t.lockFile.Close()
+	t.lockFile = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (t *unixTransport) Listen() (net.Listener, error) {
+	// 持有锁意味着之前的主实例已退出，残留的 socket 可以安全删除
+	if err := os.Remove(t.socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	oldMask := syscall.Umask(0o177)
+	listener, err := net.Listen("unix", t.socketPath)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(t.socketPath, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func (t *unixTransport) Dial(timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", t.socketPath, timeout)
+}
+
+func (t *unixTransport) Cleanup() error {
+	if err := os.Remove(t.socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+//go:build !windows
+
+package app
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/pkg/client"
+)
+
+func TestUnixTransportLockAndStaleSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	endpoint, err := client.EndpointFor("MarkdownDaoNoteTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Address != filepath.Join(endpoint.Dir, "instance.sock") || filepath.Dir(endpoint.Dir) != os.Getenv("XDG_RUNTIME_DIR") {
+		t.Fatalf("endpoint = %+v", endpoint)
+	}
+	// 预先存在的目录权限过宽时收紧
+	if err := os.Mkdir(endpoint.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	newTransport := func() instanceTransport {
+		t.Helper()
+		transport, err := newInstanceTransport(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return transport
+	}
+	first, second := newTransport(), newTransport()
+	if info, err := os.Stat(endpoint.Dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("runtime dir = %v, %v", info.Mode(), err)
+	}
+
+	if locked, err := first.Lock(); !locked || err != nil {
+		t.Fatalf("first Lock = %v, %v", locked, err)
+	}
+	if locked, err := second.Lock(); locked || err != nil {
+		t.Fatalf("second Lock while held = %v, %v", locked, err)
+	}
+
+	// 上一个主实例崩溃后留下的 socket 无人监听
+	stale, err := net.Listen("unix", endpoint.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if conn, err := second.Dial(time.Second); err == nil {
+		conn.Close()
+		t.Fatal("dialed a stale socket")
+	}
+
+	if err := first.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if locked, err := second.Lock(); !locked || err != nil {
+		t.Fatalf("Lock after release = %v, %v", locked, err)
+	}
+	defer second.Unlock()
+	listener, err := second.Listen()
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	defer listener.Close()
+	if info, err := os.Stat(endpoint.Address); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket = %v, %v", info.Mode(), err)
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+	conn, err := first.Dial(time.Second)
+	if err != nil {
+		t.Fatalf("Dial the new primary: %v", err)
+	}
+	conn.Close()
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+
+	// 由 Cleanup 删除 socket，而不是关闭监听时自动删除
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if err := second.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(endpoint.Address); !os.IsNotExist(err) {
+		t.Errorf("socket left after Cleanup: %v", err)
+	}
+}
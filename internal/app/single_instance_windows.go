@@ -0,0 +1,96 @@
+//go:build windows
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+
+	"github.com/yourname/MarkdownDaoNote/pkg/client"
+)
+
+// pipeTransport 使用 LockFileEx 锁文件 + 命名管道，管道 ACL 仅允许当前用户访问
+type pipeTransport struct {
+	lockPath string
+	pipeName string
+	sddl     string
+	lockFile *os.File
+}
+
+func newInstanceTransport(endpoint client.Endpoint) (instanceTransport, error) {
+	if err := os.MkdirAll(endpoint.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	sid, err := client.CurrentUserSID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipeTransport{
+		lockPath: filepath.Join(endpoint.Dir, "instance.lock"),
+		pipeName: endpoint.Address,
+		sddl:     fmt.Sprintf("D:P(A;;GA;;;%s)", sid),
+	}, nil
+}
+
+func (t *pipeTransport) Address() string {
+	return t.pipeName
+}
+
+func (t *pipeTransport) Lock() (bool, error) {
+	file, err := os.OpenFile(t.lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return false, err
+	}
+
+	overlapped := new(windows.Overlapped)
+	err = windows.LockFileEx(windows.Handle(file.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, overlapped)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	t.lockFile = file
+	return true, nil
+}
+
+func (t *pipeTransport) Unlock() error {
+	if t.lockFile == nil {
+		return nil
+	}
+	err := windows.UnlockFileEx(windows.Handle(t.lockFile.Fd()), 0, 1, 0, new(windows.Overlapped))
+	closeErr := t.lockFile.Close()
+	t.lockFile = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (t *pipeTransport) Listen() (net.Listener, error) {
+	return winio.ListenPipe(t.pipeName, &winio.PipeConfig{
+		SecurityDescriptor: t.sddl,
+		MessageMode:        false,
+	})
+}
+
+func (t *pipeTransport) Dial(timeout time.Duration) (net.Conn, error) {
+	return winio.DialPipe(t.pipeName, &timeout)
+}
+
+// Cleanup 命名管道随监听器关闭自动消失，无需清理
+func (t *pipeTransport) Cleanup() error {
+	return nil
+}
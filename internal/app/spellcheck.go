@@ -0,0 +1,103 @@
+package app
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// SpellcheckState is the spell-check configuration shown in the settings
+// dialog, with the installed dictionaries and the personal dictionary.
+type SpellcheckState struct {
+	Enabled       bool     `json:"enabled"`
+	Languages     []string `json:"languages"`
+	Available     []string `json:"available"`
+	PersonalWords []string `json:"personalWords"`
+	DictionaryDir string   `json:"dictionaryDir"`
+}
+
+// LoadSpellcheckSettings returns the spell-check settings, the installed
+// dictionaries and the personal dictionary.
+func (a *App) LoadSpellcheckSettings() (SpellcheckState, error) {
+	state := SpellcheckState{DictionaryDir: a.spell.DictionaryDir()}
+	settings, err := a.settings.Load()
+	if err != nil {
+		return state, err
+	}
+	state.Enabled = settings.Spellcheck.Enabled
+	state.Languages = append([]string{}, settings.Spellcheck.Languages...)
+	if state.Available, err = a.spell.Languages(); err != nil {
+		return state, err
+	}
+	if state.PersonalWords, err = a.spell.PersonalWords(); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+// SaveSpellcheckSettings stores the spell-check settings and replaces the
+// personal dictionary.
+func (a *App) SaveSpellcheckSettings(state SpellcheckState) error {
+	settings, err := a.settings.Load()
+	if err != nil {
+		return err
+	}
+	languages := []string{}
+	for _, language := range state.Languages {
+		if language = strings.TrimSpace(language); language != "" {
+			languages = append(languages, language)
+		}
+	}
+	settings.Spellcheck = services.SpellcheckSettings{Enabled: state.Enabled, Languages: languages}
+	if err := a.settings.Save(settings); err != nil {
+		return err
+	}
+	return a.spell.SetPersonalWords(state.PersonalWords)
+}
+
+// CheckSpelling returns the misspelled words of content, the editor buffer
+// of path. Notes may pick their languages in front matter; otherwise the
+// configured languages, or every installed dictionary, apply. Words in the
+// personal dictionary and the workspace word list are accepted.
+func (a *App) CheckSpelling(path string, content string) ([]services.SpellingError, error) {
+	settings, err := a.settings.Load()
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Spellcheck.Enabled || (path != "" && !services.IsMarkdownFile(path) && !services.IsEncryptedNote(path)) {
+		return []services.SpellingError{}, nil
+	}
+
+	languages := settings.Spellcheck.Languages
+	if len(languages) == 0 {
+		if languages, err = a.spell.Languages(); err != nil {
+			return nil, err
+		}
+	}
+	words, err := a.spell.PersonalWords()
+	if err != nil {
+		return nil, err
+	}
+	if root := a.activeWorkspaceRoot(); root != "" {
+		workspaceWords, err := services.WorkspaceWords(root)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, workspaceWords...)
+	}
+	return a.spell.CheckDocument(content, services.DocumentLanguages(content, languages), words)
+}
+
+// AddToDictionary accepts word from now on, in the personal dictionary or,
+// with workspace set, in the word list of the open workspace.
+func (a *App) AddToDictionary(word string, workspace bool) error {
+	if !workspace {
+		return a.spell.AddPersonalWord(word)
+	}
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return errors.New("no folder is open")
+	}
+	return services.AddWorkspaceWord(root, word)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const eventWritingRecorded = "writing:recorded"
+
+// DocumentStats returns the statistics of content, the editor buffer.
+func (a *App) DocumentStats(content string) services.DocumentStats {
+	return services.DocumentStatistics(content)
+}
+
+// WorkspaceStats sums the statistics of every note in the open workspace.
+func (a *App) WorkspaceStats() (services.WorkspaceStats, error) {
+	if err := a.ensureWorkspaceIndex(); err != nil {
+		return services.WorkspaceStats{Longest: []services.NoteWords{}}, err
+	}
+	return a.index.Stats(), nil
+}
+
+// WritingProgress returns the daily goal of the open workspace and the words
+// written on each of the last days.
+func (a *App) WritingProgress(days int) (services.WritingProgress, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return services.WritingProgress{Days: []services.DailyWords{}}, errors.New("no folder is open")
+	}
+	return a.writing.Progress(root, time.Now(), days)
+}
+
+// SetWritingGoal sets the daily word goal of the open workspace; 0 removes it.
+func (a *App) SetWritingGoal(goal int) error {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return errors.New("no folder is open")
+	}
+	return a.writing.SetGoal(root, goal)
+}
+
+// wordsOnDisk returns the word count of the saved version of a workspace
+// note before it is overwritten, reading notes in a vault as plaintext. ok is
+// false for files outside the workspace, for notes that cannot be read and
+// for encrypted notes, whose history is not tracked.
+func (a *App) wordsOnDisk(path string) (words int, ok bool) {
+	root := a.activeWorkspaceRoot()
+	if root == "" || !services.IsMarkdownFile(path) || !isPathWithin(root, filepath.Clean(path)) {
+		return 0, false
+	}
+	data, err := a.files.ReadBytes(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, true
+	}
+	if err != nil {
+		return 0, false
+	}
+	return services.DocumentStatistics(string(data)).Words, true
+}
+
+// recordWriting adds the words gained (or lost) by a save to today's history.
+func (a *App) recordWriting(before int, content string) {
+	root := a.activeWorkspaceRoot()
+	delta := services.DocumentStatistics(content).Words - before
+	if root == "" || delta == 0 {
+		return
+	}
+	if err := a.writing.Record(root, time.Now(), delta); err != nil {
+		runtime.LogWarningf(a.ctx, "failed recording writing history: %v", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, eventWritingRecorded, root)
+}
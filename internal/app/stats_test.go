@@ -0,0 +1,57 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func TestWordsOnDisk(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"note.md":        "one two three",
+		"notes.txt":      "one two",
+		"vault/inner.md": "four words in here",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vault, err := services.CreateVault(filepath.Join(root, "vault"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{files: services.NewFileService(), workspaceRoot: root}
+	a.files.MountVault(vault)
+	defer a.files.UnmountAllVaults()
+
+	tests := []struct {
+		name  string
+		path  string
+		words int
+		ok    bool
+	}{
+		{name: "note", path: filepath.Join(root, "note.md"), words: 3, ok: true},
+		{name: "new note", path: filepath.Join(root, "new.md"), words: 0, ok: true},
+		{name: "note in an unlocked vault", path: filepath.Join(root, "vault", "inner.md"), words: 4, ok: true},
+		{name: "not markdown", path: filepath.Join(root, "notes.txt")},
+		{name: "encrypted note", path: filepath.Join(root, "secret.md.enc")},
+		{name: "outside the workspace", path: filepath.Join(filepath.Dir(root), "other.md")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, ok := a.wordsOnDisk(tt.path)
+			if words != tt.words || ok != tt.ok {
+				t.Errorf("wordsOnDisk = %d, %v; want %d, %v", words, ok, tt.words, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const (
+	eventSyncProgress = "sync:progress"
+	eventSyncFinished = "sync:finished"
+	eventSyncFailed   = "sync:failed"
+
+	// 保存后稍等片刻再同步，合并连续保存
+	syncOnSaveDelay = 5 * time.Second
+	syncStateDir    = "sync"
+)
+
+var errSyncRunning = errors.New("a sync is already running")
+
+// LoadSyncTarget returns the WebDAV configuration of the opened workspace.
+// An unconfigured workspace yields a target with only Workspace set.
+func (a *App) LoadSyncTarget() (services.SyncTarget, error) {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return services.SyncTarget{}, errors.New("no folder is open")
+	}
+	target, ok, err := a.loadSyncTarget(root)
+	if err != nil || ok {
+		return target, err
+	}
+	return services.SyncTarget{Workspace: root}, nil
+}
+
+// SaveSyncTarget stores the WebDAV configuration of the opened workspace and
+// reschedules syncing. An empty URL removes the configuration.
+func (a *App) SaveSyncTarget(target services.SyncTarget) error {
+	root := a.activeWorkspaceRoot()
+	if root == "" {
+		return errors.New("no folder is open")
+	}
+	target.Workspace = root
+	target.URL = strings.TrimSpace(target.URL)
+	if target.IntervalMinutes < 0 {
+		target.IntervalMinutes = 0
+	}
+	if target.URL != "" {
+		if _, err := services.NewWebDAVClient(target.URL, target.Username, target.Password); err != nil {
+			return err
+		}
+	} else {
+		target.Password = ""
+	}
+
+	settings, err := a.settings.Load()
+	if err != nil {
+		return err
+	}
+	if err := a.storeSyncTarget(settings, target); err != nil {
+		return err
+	}
+
+	a.configureSync(root)
+	return nil
+}
+
+// loadSyncTarget returns the configuration of root with its password from
+// the credential store. A password still saved in settings.json by an older
+// version is moved to the store.
+func (a *App) loadSyncTarget(root string) (services.SyncTarget, bool, error) {
+	settings, err := a.settings.Load()
+	if err != nil {
+		return services.SyncTarget{}, false, err
+	}
+	target, ok := findSyncTarget(settings.SyncTargets, root)
+	if !ok {
+		return target, false, nil
+	}
+	if target.Password != "" {
+		if err := a.storeSyncTarget(settings, target); err != nil {
+			// 无法迁移时沿用 settings.json 中的密码
+			log.Printf("Failed to move the sync password of %s: %v", root, err)
+		}
+		return target, true, nil
+	}
+	target.Password, err = a.secrets.Get(syncSecretName(root))
+	if err != nil {
+		return target, true, fmt.Errorf("cannot read the sync password: %w", err)
+	}
+	return target, true, nil
+}
+
+// storeSyncTarget replaces the configuration of target.Workspace in settings,
+// or removes it when the URL is empty. The password goes to the credential
+// store, never to settings.json.
+func (a *App) storeSyncTarget(settings services.Settings, target services.SyncTarget) error {
+	if err := a.secrets.Set(syncSecretName(target.Workspace), target.Password); err != nil {
+		return fmt.Errorf("cannot store the sync password: %w", err)
+	}
+	target.Password = ""
+
+	targets := []services.SyncTarget{}
+	for _, existing := range settings.SyncTargets {
+		if waitKey(existing.Workspace) != waitKey(target.Workspace) {
+			targets = append(targets, existing)
+		}
+	}
+	if target.URL != "" {
+		targets = append(targets, target)
+	}
+	settings.SyncTargets = targets
+	return a.settings.Save(settings)
+}
+
+func syncSecretName(root string) string {
+	return "sync:" + waitKey(root)
+}
+
+// SyncNow synchronizes the opened workspace immediately.
+func (a *App) SyncNow() (*services.SyncReport, error) {
+	a.syncMu.Lock()
+	target := a.syncTarget
+	a.syncMu.Unlock()
+	if target == nil {
+		return nil, errors.New("sync is not configured for this folder")
+	}
+	return a.runSync(*target)
+}
+
+// configureSync loads the sync target of root and (re)starts its schedule.
+func (a *App) configureSync(root string) {
+	var target *services.SyncTarget
+	if found, ok, err := a.loadSyncTarget(root); err != nil {
+		log.Printf("Failed to load the sync target of %s: %v", root, err)
+	} else if ok {
+		target = &found
+	}
+
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	a.stopSyncTimersLocked()
+	a.syncTarget = target
+	if target != nil && target.IntervalMinutes > 0 {
+		a.syncTimer = time.AfterFunc(time.Duration(target.IntervalMinutes)*time.Minute, a.runScheduledSync)
+	}
+}
+
+// scheduleSyncOnSave syncs shortly after a save when the workspace asks for it.
+func (a *App) scheduleSyncOnSave() {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	if a.syncTarget == nil || !a.syncTarget.SyncOnSave {
+		return
+	}
+	if a.syncSaveTimer != nil {
+		a.syncSaveTimer.Stop()
+	}
+	target := *a.syncTarget
+	a.syncSaveTimer = time.AfterFunc(syncOnSaveDelay, func() {
+		a.runBackgroundSync(target)
+	})
+}
+
+func (a *App) runScheduledSync() {
+	a.syncMu.Lock()
+	target := a.syncTarget
+	a.syncMu.Unlock()
+	if target == nil {
+		return
+	}
+
+	a.runBackgroundSync(*target)
+
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	// 期间配置可能已更换
+	if a.syncTarget == target && target.IntervalMinutes > 0 {
+		a.syncTimer = time.AfterFunc(time.Duration(target.IntervalMinutes)*time.Minute, a.runScheduledSync)
+	}
+}
+
+func (a *App) runBackgroundSync(target services.SyncTarget) {
+	if _, err := a.runSync(target); err != nil && !errors.Is(err, errSyncRunning) {
+		log.Printf("Sync of %s failed: %v", target.Workspace, err)
+	}
+}
+
+// runSync performs one sync, reporting progress and the outcome as events.
+func (a *App) runSync(target services.SyncTarget) (*services.SyncReport, error) {
+	a.syncMu.Lock()
+	if a.syncCancel != nil {
+		a.syncMu.Unlock()
+		return nil, errSyncRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.syncCancel = cancel
+	a.syncMu.Unlock()
+
+	defer func() {
+		cancel()
+		a.syncMu.Lock()
+		a.syncCancel = nil
+		a.syncMu.Unlock()
+	}()
+
+	engine, err := services.NewSyncEngine(target, filepath.Join(a.settings.Dir(), syncStateDir), func(progress services.SyncProgress) {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, eventSyncProgress, progress)
+		}
+	})
+	if err == nil {
+		// 有未保存修改的标签页对应的文件不下载也不删除，留到保存后的下一次同步
+		engine.SetProtected(func(path string) bool {
+			return len(a.dirty.among([]string{path})) > 0
+		})
+		var report *services.SyncReport
+		if report, err = engine.Run(ctx); err == nil {
+			a.finishSync(target, report)
+			return report, nil
+		}
+	}
+
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventSyncFailed, err.Error())
+	}
+	return nil, err
+}
+
+// finishSync refreshes what depends on the files a sync changed.
+func (a *App) finishSync(target services.SyncTarget, report *services.SyncReport) {
+	if report.Changed() {
+		a.reindexWorkspace(target.Workspace)
+	}
+	a.scheduleGitRefresh()
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventSyncFinished, target.Workspace, report)
+	}
+}
+
+// stopSync cancels a running sync and its timers, e.g. on shutdown.
+func (a *App) stopSync() {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	a.stopSyncTimersLocked()
+	if a.syncCancel != nil {
+		a.syncCancel()
+	}
+}
+
+func (a *App) stopSyncTimersLocked() {
+	if a.syncTimer != nil {
+		a.syncTimer.Stop()
+		a.syncTimer = nil
+	}
+	if a.syncSaveTimer != nil {
+		a.syncSaveTimer.Stop()
+		a.syncSaveTimer = nil
+	}
+}
+
+func findSyncTarget(targets []services.SyncTarget, root string) (services.SyncTarget, bool) {
+	for _, target := range targets {
+		if waitKey(target.Workspace) == waitKey(root) {
+			return target, true
+		}
+	}
+	return services.SyncTarget{}, false
+}
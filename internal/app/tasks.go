@@ -0,0 +1,61 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const eventTaskToggled = "task:toggled"
+
+// QueryTasks returns checkbox items across the opened workspace matching the filter.
+func (a *App) QueryTasks(filter services.TaskFilter) ([]services.TaskItem, error) {
+	if err := a.ensureWorkspaceIndex(); err != nil {
+		return nil, err
+	}
+	return a.index.Tasks(filter), nil
+}
+
+// ToggleTask flips the checkbox on the given 1-based line of a file. The write
+// is refused when the line no longer matches the indexed task or the file has
+// unsaved changes in the editor.
+func (a *App) ToggleTask(path string, line int) (services.TaskItem, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return services.TaskItem{}, errors.New("path is required")
+	}
+	if err := a.checkNotDirty("toggling", path); err != nil {
+		return services.TaskItem{}, err
+	}
+
+	indexed, ok := a.index.Task(path, line)
+	if !ok {
+		// 不在索引中的行无从核对，刷新索引后让调用方重新获取
+		a.refreshIndexedFile(path)
+		return services.TaskItem{}, services.ErrTaskChanged
+	}
+
+	task, err := a.files.ToggleTaskInFile(filepath.Clean(path), line, indexed.Raw)
+	if err != nil {
+		if errors.Is(err, services.ErrTaskChanged) {
+			// 索引已过期，刷新后让调用方重新获取
+			a.refreshIndexedFile(path)
+		}
+		return services.TaskItem{}, err
+	}
+
+	a.refreshIndexedFile(path)
+	a.filesChanged()
+	if indexed, ok := a.index.Task(path, line); ok {
+		task = indexed
+	}
+
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventTaskToggled, task.Path, task.Line, task.Done)
+	}
+	return task, nil
+}
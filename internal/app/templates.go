@@ -0,0 +1,79 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// configTemplateDir is the folder below the settings directory holding
+// templates available in every workspace.
+const configTemplateDir = "templates"
+
+// CreatedNote reports the note written by CreateFromTemplate and where its
+// {{cursor}} ended up (0 when the template has none).
+type CreatedNote struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// ListTemplates lists the templates of the config directory and of the
+// opened workspace; workspace templates replace those with the same name.
+func (a *App) ListTemplates() ([]services.NoteTemplate, error) {
+	return services.ListTemplates(a.templateDirs()...)
+}
+
+// CreateFromTemplate renders template (a name or path) with vars and creates
+// the note in targetDir, the workspace root when empty. The file name comes
+// from the template's filename pattern; an existing note is never replaced.
+func (a *App) CreateFromTemplate(template string, targetDir string, vars map[string]string) (CreatedNote, error) {
+	dir := strings.TrimSpace(targetDir)
+	if dir == "" {
+		dir = a.activeWorkspaceRoot()
+	}
+	if dir == "" {
+		return CreatedNote{}, errors.New("no target folder")
+	}
+
+	rendered, err := a.renderTemplate(template, vars, time.Now())
+	if err != nil {
+		return CreatedNote{}, err
+	}
+
+	if err := a.files.CreateDirectory(dir); err != nil {
+		return CreatedNote{}, err
+	}
+	target, err := createUniqueNote(a.files, filepath.Join(dir, rendered.Filename), rendered.Content)
+	if err != nil {
+		return CreatedNote{}, err
+	}
+
+	a.refreshIndexedFile(target)
+	a.filesChanged()
+	return CreatedNote{Path: target, Line: rendered.CursorLine, Column: rendered.CursorColumn}, nil
+}
+
+// renderTemplate finds the template called name and renders it at now.
+func (a *App) renderTemplate(name string, vars map[string]string, now time.Time) (services.RenderedNote, error) {
+	path, err := services.FindTemplate(name, a.templateDirs()...)
+	if err != nil {
+		return services.RenderedNote{}, err
+	}
+	source, err := a.files.Read(path)
+	if err != nil {
+		return services.RenderedNote{}, err
+	}
+	return services.RenderTemplate(source, vars, now)
+}
+
+func (a *App) templateDirs() []string {
+	dirs := []string{filepath.Join(a.settings.Dir(), configTemplateDir)}
+	if root := a.activeWorkspaceRoot(); root != "" {
+		dirs = append(dirs, filepath.Join(root, services.TemplateDirName))
+	}
+	return dirs
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const eventTransferProgress = "transfer:progress"
+
+var errTransferRunning = errors.New("another copy or move is still running")
+
+// CopyItems copies files and folders into the folder destination. policy
+// is "skip", "overwrite" or "keepBoth" and applies to names already taken.
+func (a *App) CopyItems(sources []string, destination string, policy services.ConflictPolicy) (services.TransferResult, error) {
+	return a.runTransfer(sources, func(ctx context.Context, progress func(services.TransferProgress)) (services.TransferResult, error) {
+		return a.files.Copy(ctx, sources, destination, policy, progress)
+	})
+}
+
+// MoveItems moves files and folders into the folder destination, merging
+// folders that already exist there unless policy is "keepBoth".
+func (a *App) MoveItems(sources []string, destination string, policy services.ConflictPolicy) (services.TransferResult, error) {
+	return a.runTransfer(sources, func(ctx context.Context, progress func(services.TransferProgress)) (services.TransferResult, error) {
+		result, err := a.files.Move(ctx, sources, destination, policy, progress)
+		for _, item := range result.Moved {
+			// 移走的加密笔记（包括文件夹里的）在新位置需重新解锁
+			a.notes.LockTree(item.From)
+		}
+		return result, err
+	})
+}
+
+// DuplicateItem copies a file or folder next to itself as "Name copy".
+func (a *App) DuplicateItem(path string) (services.TransferResult, error) {
+	return a.runTransfer([]string{path}, func(ctx context.Context, progress func(services.TransferProgress)) (services.TransferResult, error) {
+		return a.files.Duplicate(ctx, path, progress)
+	})
+}
+
+// CancelTransfer stops the running copy or move between two files.
+func (a *App) CancelTransfer() {
+	a.transferMu.Lock()
+	defer a.transferMu.Unlock()
+	if a.transferCancel != nil {
+		a.transferCancel()
+	}
+}
+
+// runTransfer runs one copy or move at a time, reporting progress to the
+// frontend, and reindexes the workspace afterwards, also when the transfer
+// failed or was cancelled halfway. Such a failure is reported in the result
+// rather than as an error, so the frontend still learns what was moved.
+func (a *App) runTransfer(sources []string, run func(context.Context, func(services.TransferProgress)) (services.TransferResult, error)) (services.TransferResult, error) {
+	empty := services.TransferResult{Items: []services.TransferItem{}, Skipped: []string{}, Moved: []services.TransferItem{}}
+	if len(sources) == 0 || strings.TrimSpace(sources[0]) == "" {
+		return empty, errors.New("nothing to copy or move")
+	}
+
+	a.transferMu.Lock()
+	if a.transferCancel != nil {
+		a.transferMu.Unlock()
+		return empty, errTransferRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.transferCancel = cancel
+	a.transferMu.Unlock()
+	defer func() {
+		cancel()
+		a.transferMu.Lock()
+		a.transferCancel = nil
+		a.transferMu.Unlock()
+	}()
+
+	result, err := run(ctx, func(progress services.TransferProgress) {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, eventTransferProgress, progress)
+		}
+	})
+	if root := a.activeWorkspaceRoot(); root != "" {
+		a.reindexWorkspace(root)
+	}
+	a.filesChanged()
+	switch {
+	case errors.Is(err, context.Canceled):
+		result.Error = "cancelled"
+	case err != nil:
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// ChooseFolder asks for a destination folder, starting in directory. It
+// returns "" when the dialog was cancelled.
+func (a *App) ChooseFolder(title string, directory string) (string, error) {
+	if a.ctx == nil {
+		return "", errors.New("application not ready")
+	}
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            title,
+		DefaultDirectory: directory,
+	})
+}
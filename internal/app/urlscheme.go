@@ -0,0 +1,350 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+// URLScheme is the custom scheme registered with the operating system.
+const URLScheme = "markdowndaonote"
+
+const maxRememberedWorkspaces = 20
+
+var errOutsideWorkspace = errors.New("path is outside the allowed workspaces")
+
+var errNoteDeclined = errors.New("creating the note was declined")
+
+// NoteDraft is a note a markdowndaonote://new link asks to create. Path is
+// the preferred location; a free name next to it is used when it is taken.
+type NoteDraft struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// IsLaunchURL reports whether arg is a markdowndaonote: URL rather than a path.
+func IsLaunchURL(arg string) bool {
+	return strings.HasPrefix(strings.ToLower(arg), URLScheme+":")
+}
+
+// RememberedWorkspaces returns the workspace folders markdowndaonote:// links
+// are allowed to reach.
+func RememberedWorkspaces() []string {
+	settings, err := services.NewSettingsService().Load()
+	if err != nil {
+		log.Printf("Failed to load settings: %v", err)
+	}
+	return settings.Workspaces
+}
+
+// ParseLaunchURL turns a markdowndaonote:// URL into a launch request:
+//
+//	markdowndaonote://open?path=notes/a.md&workspace=/home/me/notes&heading=Setup&line=3&column=1
+//	markdowndaonote://new?workspace=/home/me/notes&folder=inbox&name=Idea&title=Idea&template=templates/idea.md
+//
+// Relative paths are resolved against the workspace parameter, which defaults
+// to the most recent workspace. Every path must stay inside one of workspaces
+// after symlinks are resolved. Notes and templates are read through files, so
+// notes in unlocked vaults are decrypted. The new action only describes the
+// note; the application that handles the request creates it once the user
+// agrees.
+func ParseLaunchURL(raw string, workspaces []string, files *services.FileService) (LaunchRequest, error) {
+	var request LaunchRequest
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return request, fmt.Errorf("invalid URL: %w", err)
+	}
+	if !strings.EqualFold(parsed.Scheme, URLScheme) {
+		return request, fmt.Errorf("unsupported URL scheme %q", parsed.Scheme)
+	}
+
+	// markdowndaonote://open?… 与 markdowndaonote:open?… 两种写法都接受
+	action := parsed.Host
+	if action == "" {
+		action = parsed.Opaque
+	}
+	action = strings.ToLower(strings.Trim(action+parsed.Path, "/"))
+	query := parsed.Query()
+
+	root, err := urlWorkspace(query.Get("workspace"), workspaces)
+	if err != nil {
+		return request, err
+	}
+	request.Cwd = root
+
+	switch action {
+	case "open":
+		return parseOpenURL(request, root, workspaces, files, query)
+	case "new":
+		return parseNewURL(request, root, workspaces, files, query)
+	default:
+		return request, fmt.Errorf("unknown URL action %q", action)
+	}
+}
+
+func parseOpenURL(request LaunchRequest, root string, workspaces []string, files *services.FileService, query url.Values) (LaunchRequest, error) {
+	path := query.Get("path")
+	if path == "" {
+		// 只给出 workspace 时打开该文件夹
+		request.Folder = root
+		return request, nil
+	}
+
+	target, err := confineToWorkspaces(root, path, workspaces)
+	if err != nil {
+		return request, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return request, err
+	}
+	if info.IsDir() {
+		request.Folder = target
+		return request, nil
+	}
+	if !services.IsMarkdownFile(target) {
+		return request, fmt.Errorf("%s is not a Markdown file", target)
+	}
+
+	file := OpenTarget{Path: target}
+	if file.Line, err = urlNumber(query, "line"); err != nil {
+		return request, err
+	}
+	if file.Column, err = urlNumber(query, "column"); err != nil {
+		return request, err
+	}
+	if heading := query.Get("heading"); heading != "" && file.Line == 0 {
+		if data, err := files.ReadBytes(target); err == nil {
+			file.Line = services.FindHeadingLine(string(data), heading)
+		}
+		if file.Line == 0 {
+			log.Printf("Heading %q not found in %s", heading, target)
+		}
+	}
+
+	request.Files = []OpenTarget{file}
+	return request, nil
+}
+
+func parseNewURL(request LaunchRequest, root string, workspaces []string, files *services.FileService, query url.Values) (LaunchRequest, error) {
+	name := strings.TrimSpace(query.Get("name"))
+	title := strings.TrimSpace(query.Get("title"))
+	if name == "" {
+		name = title
+	}
+	if name == "" {
+		name = "Untitled"
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return request, fmt.Errorf("invalid note name %q", name)
+	}
+	if filepath.Ext(name) == "" {
+		name += ".md"
+	}
+	if !services.IsMarkdownFile(name) {
+		return request, fmt.Errorf("%s is not a Markdown file", name)
+	}
+	if title == "" {
+		title = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	target, err := confineToWorkspaces(root, filepath.Join(query.Get("folder"), name), workspaces)
+	if err != nil {
+		return request, err
+	}
+
+	content := "# " + title + "\n"
+	if templatePath := query.Get("template"); templatePath != "" {
+		template, err := confineToWorkspaces(root, templatePath, workspaces)
+		if err != nil {
+			return request, err
+		}
+		data, err := files.ReadBytes(template)
+		if err != nil {
+			return request, err
+		}
+		rendered, err := services.RenderTemplate(string(data), map[string]string{"title": title}, time.Now())
+		if err != nil {
+			return request, fmt.Errorf("template %s: %w", templatePath, err)
+		}
+		content = rendered.Content
+	}
+
+	request.New = &NoteDraft{Path: target, Content: content}
+	return request, nil
+}
+
+// urlWorkspace picks the allowed workspace a URL refers to.
+func urlWorkspace(requested string, workspaces []string) (string, error) {
+	if len(workspaces) == 0 {
+		return "", errors.New("no workspace has been opened yet; open a folder before using markdowndaonote:// links")
+	}
+	if requested == "" {
+		return filepath.Clean(workspaces[0]), nil
+	}
+
+	requestedReal := realPath(filepath.Clean(requested))
+	for _, workspace := range workspaces {
+		if realPath(filepath.Clean(workspace)) == requestedReal {
+			return filepath.Clean(workspace), nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", requested, errOutsideWorkspace)
+}
+
+// confineToWorkspaces resolves path against root and rejects anything that
+// leaves the allowed workspaces, including through symlinks.
+func confineToWorkspaces(root string, path string, workspaces []string) (string, error) {
+	target := filepath.FromSlash(path)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+
+	resolved := realPath(target)
+	for _, workspace := range workspaces {
+		if isPathWithin(realPath(filepath.Clean(workspace)), resolved) {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", path, errOutsideWorkspace)
+}
+
+// realPath resolves symlinks in the longest existing prefix of path so that
+// files which do not exist yet can be checked too.
+func realPath(path string) string {
+	existing := path
+	var rest []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(existing); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path
+		}
+		rest = append([]string{filepath.Base(existing)}, rest...)
+		existing = parent
+	}
+}
+
+func isPathWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// uniqueNotePath appends " 2", " 3", … until the name is free.
+func uniqueNotePath(files *services.FileService, path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 2; ; i++ {
+		if _, err := files.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s %d%s", base, i, ext)
+	}
+}
+
+// createUniqueNote writes content to a new note at path, or at the next free
+// name of uniqueNotePath when another note takes the name first.
+func createUniqueNote(files *services.FileService, path string, content string) (string, error) {
+	for {
+		target := uniqueNotePath(files, path)
+		err := files.CreateNew(target, content)
+		if !errors.Is(err, os.ErrExist) {
+			return target, err
+		}
+	}
+}
+
+// createNoteDraft asks before creating a note requested by a
+// markdowndaonote://new link, then opens it.
+func (a *App) createNoteDraft(draft NoteDraft) {
+	answer, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+		Type:          runtime.QuestionDialog,
+		Title:         "Create Note",
+		Message:       fmt.Sprintf("A link asks to create %s. Create it?", draft.Path),
+		Buttons:       []string{"Yes", "No"},
+		DefaultButton: "Yes",
+		CancelButton:  "No",
+	})
+	if err == nil && answer != "Yes" {
+		err = errNoteDeclined
+	}
+	if err == nil {
+		err = a.writeNoteDraft(draft)
+	}
+	if err != nil {
+		log.Printf("Not creating %s: %v", draft.Path, err)
+		a.waiters.fail(draft.Path, err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, eventOpenFile, draft.Path, 0, 0)
+}
+
+// writeNoteDraft creates the note and its folder; it never replaces a file.
+func (a *App) writeNoteDraft(draft NoteDraft) error {
+	if err := a.files.CreateDirectory(filepath.Dir(draft.Path)); err != nil {
+		return err
+	}
+	// 确认期间同名文件可能已被创建，CreateNew 不会覆盖它
+	return a.files.CreateNew(draft.Path, draft.Content)
+}
+
+func urlNumber(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%s expects a positive number, got %q", name, value)
+	}
+	return number, nil
+}
+
+// rememberWorkspace records root as the most recent workspace.
+func (a *App) rememberWorkspace(root string) {
+	if a.settings == nil || root == "" {
+		return
+	}
+
+	settings, err := a.settings.Load()
+	if err != nil {
+		log.Printf("Failed to load settings: %v", err)
+		return
+	}
+
+	workspaces := []string{root}
+	for _, existing := range settings.Workspaces {
+		if waitKey(existing) != waitKey(root) {
+			workspaces = append(workspaces, existing)
+		}
+	}
+	if len(workspaces) > maxRememberedWorkspaces {
+		workspaces = workspaces[:maxRememberedWorkspaces]
+	}
+	if len(workspaces) == len(settings.Workspaces) && workspaces[0] == settings.Workspaces[0] {
+		return
+	}
+
+	settings.Workspaces = workspaces
+	if err := a.settings.Save(settings); err != nil {
+		log.Printf("Failed to save settings: %v", err)
+	}
+}
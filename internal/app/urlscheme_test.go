@@ -0,0 +1,118 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func TestParseLaunchURLNew(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "idea.tmpl.md"), []byte("# {{.title}}\n\nbody\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	workspaces := []string{root}
+
+	tests := []struct {
+		name    string
+		url     string
+		want    NoteDraft
+		wantErr error
+	}{
+		{
+			name: "title only",
+			url:  "markdowndaonote://new?title=Idea&folder=inbox",
+			want: NoteDraft{Path: filepath.Join(root, "inbox", "Idea.md"), Content: "# Idea\n"},
+		},
+		{
+			name: "template",
+			url:  "markdowndaonote://new?name=idea&title=Big%20Idea&template=idea.tmpl.md",
+			want: NoteDraft{Path: filepath.Join(root, "idea.md"), Content: "# Big Idea\n\nbody\n"},
+		},
+		{
+			name:    "folder outside the workspace",
+			url:     "markdowndaonote://new?title=Idea&folder=../elsewhere",
+			wantErr: errOutsideWorkspace,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := ParseLaunchURL(tt.url, workspaces, services.NewFileService())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if request.New == nil || *request.New != tt.want {
+				t.Fatalf("draft = %+v, want %+v", request.New, tt.want)
+			}
+			if request.Empty() {
+				t.Error("a request with only a draft counts as empty")
+			}
+			// 解析链接时不能创建任何文件
+			if _, err := os.Lstat(tt.want.Path); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s exists before the user confirmed: %v", tt.want.Path, err)
+			}
+		})
+	}
+}
+
+func TestParseLaunchURLOpenHeading(t *testing.T) {
+	root := t.TempDir()
+	note := filepath.Join(root, "a.md")
+	if err := os.WriteFile(note, []byte("# A\n\ntext\n\n## Setup Steps\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	request, err := ParseLaunchURL("markdowndaonote://open?path=a.md&heading=Setup%20Steps", []string{root}, services.NewFileService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(request.Files) != 1 || request.Files[0] != (OpenTarget{Path: note, Line: 5}) {
+		t.Errorf("files = %+v", request.Files)
+	}
+}
+
+func TestNoteDraftCreation(t *testing.T) {
+	root := t.TempDir()
+	a := &App{files: services.NewFileService()}
+	taken := filepath.Join(root, "Idea.md")
+	if err := os.WriteFile(taken, []byte("mine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	waiter, err := a.openLaunchRequest(LaunchRequest{New: &NoteDraft{Path: taken, Content: "# Idea\n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft := *a.pendingLaunches[0].New
+	if want := filepath.Join(root, "Idea 2.md"); draft.Path != want {
+		t.Fatalf("draft path = %s, want %s", draft.Path, want)
+	}
+
+	// 用户拒绝：等待方得到原因，文件不会出现
+	a.waiters.fail(draft.Path, errNoteDeclined)
+	if !isClosed(waiter.opened) || !errors.Is(waiter.err, errNoteDeclined) {
+		t.Errorf("waiter err = %v, want %v", waiter.err, errNoteDeclined)
+	}
+	if _, err := os.Lstat(draft.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("declined note exists: %v", err)
+	}
+
+	nested := NoteDraft{Path: filepath.Join(root, "inbox", "Idea.md"), Content: "# Idea\n"}
+	if err := a.writeNoteDraft(nested); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(nested.Path); err != nil || string(data) != nested.Content {
+		t.Errorf("note = %q, %v; want %q", data, err, nested.Content)
+	}
+	if err := a.writeNoteDraft(NoteDraft{Path: taken, Content: "theirs\n"}); err == nil {
+		t.Error("writing a draft over an existing note succeeded")
+	}
+	if data, _ := os.ReadFile(taken); string(data) != "mine\n" {
+		t.Errorf("existing note = %q, want it untouched", data)
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const (
+	vaultLocked   = "locked"
+	vaultUnlocked = "unlocked"
+)
+
+// CreateVault encrypts the folder at path in place, turning it into a vault
+// that stays unlocked until LockVault is called.
+func (a *App) CreateVault(path string, passphrase string) error {
+	root := filepath.Clean(strings.TrimSpace(path))
+	if a.files.Vault(root) != nil {
+		return errors.New("folders inside a vault cannot become vaults")
+	}
+	vault, err := services.CreateVault(root, passphrase)
+	if err != nil {
+		return err
+	}
+	a.files.MountVault(vault)
+	a.vaultContentsChanged()
+	return nil
+}
+
+// UnlockVault opens the vault at path so its tree shows decrypted names.
+func (a *App) UnlockVault(path string, passphrase string) error {
+	vault, err := services.UnlockVault(strings.TrimSpace(path), passphrase)
+	if err != nil {
+		return err
+	}
+	a.files.MountVault(vault)
+	a.vaultIndexChanged(vault.Root(), false)
+	return nil
+}
+
+// LockVault forgets the keys of the vault at path and drops its notes from
+// the workspace index, so searches and queries no longer show their text.
+func (a *App) LockVault(path string) {
+	root := filepath.Clean(strings.TrimSpace(path))
+	a.files.UnmountVault(root)
+	a.vaultIndexChanged(root, true)
+}
+
+// RotateVaultKey re-encrypts the unlocked vault at path under a new master
+// key protected by passphrase (the current or a new one).
+func (a *App) RotateVaultKey(path string, passphrase string) error {
+	vault, err := a.unlockedVault(path)
+	if err != nil {
+		return err
+	}
+	if err := vault.Rotate(passphrase); err != nil {
+		return err
+	}
+	a.vaultContentsChanged()
+	return nil
+}
+
+// ExportVault asks for a destination folder and writes the decrypted vault
+// into a new folder there. It returns the exported folder, or "" when the
+// dialog was cancelled.
+func (a *App) ExportVault(path string) (string, error) {
+	if a.ctx == nil {
+		return "", errors.New("application not ready")
+	}
+	vault, err := a.unlockedVault(path)
+	if err != nil {
+		return "", err
+	}
+
+	selection, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Export Vault As Plain Text",
+		DefaultDirectory: filepath.Dir(vault.Root()),
+	})
+	if err != nil || selection == "" {
+		return "", err
+	}
+
+	dest := filepath.Join(selection, filepath.Base(vault.Root())+" (decrypted)")
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		dest = filepath.Join(selection, fmt.Sprintf("%s (decrypted %d)", filepath.Base(vault.Root()), i))
+	}
+	if err := vault.Export(dest); err != nil {
+		return "", err
+	}
+	a.vaultContentsChanged()
+	return dest, nil
+}
+
+func (a *App) unlockedVault(path string) (*services.Vault, error) {
+	root := filepath.Clean(strings.TrimSpace(path))
+	vault := a.files.Vault(root)
+	if vault == nil || vault.Root() != root {
+		if services.IsVault(root) {
+			return nil, services.ErrVaultLocked
+		}
+		return nil, fmt.Errorf("%s is not a vault", root)
+	}
+	return vault, nil
+}
+
+// vaultState reports whether dir is the root of a locked or unlocked vault.
+func (a *App) vaultState(dir string) string {
+	if vault := a.files.Vault(dir); vault != nil {
+		if vault.Root() == filepath.Clean(dir) {
+			return vaultUnlocked
+		}
+		return ""
+	}
+	if services.IsVault(dir) {
+		return vaultLocked
+	}
+	return ""
+}
+
+// vaultIndexChanged removes the notes below root from the workspace index
+// after a lock, or reads them again after an unlock.
+func (a *App) vaultIndexChanged(root string, locked bool) {
+	if locked {
+		a.index.Remove(root)
+	} else {
+		a.refreshIndexedFile(root)
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventWorkspaceIndexed, a.index.Root())
+	}
+	a.filesChanged()
+}
+
+// vaultContentsChanged refreshes what depends on files a vault operation
+// rewrote or removed.
+func (a *App) vaultContentsChanged() {
+	if root := a.activeWorkspaceRoot(); root != "" {
+		a.reindexWorkspace(root)
+	}
+	a.filesChanged()
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func TestLockVaultPurgesIndex(t *testing.T) {
+	root := t.TempDir()
+	vaultRoot := filepath.Join(root, "vault")
+	for path, content := range map[string]string{
+		filepath.Join(root, "plain.md"):     "- [ ] plain task\n",
+		filepath.Join(vaultRoot, "todo.md"): "- [ ] secret task\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vault, err := services.CreateVault(vaultRoot, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := services.NewFileService()
+	files.MountVault(vault)
+	defer files.UnmountAllVaults()
+	a := &App{files: files, index: services.NewWorkspaceIndex(files), workspaceRoot: root}
+
+	taskTexts := func() string {
+		t.Helper()
+		tasks, err := a.QueryTasks(services.TaskFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts := []string{}
+		for _, task := range tasks {
+			texts = append(texts, task.Text)
+		}
+		return strings.Join(texts, ", ")
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+		want string
+	}{
+		{name: "unlocked", run: func() error { return nil }, want: "plain task, secret task"},
+		{name: "locked", run: func() error { a.LockVault(vaultRoot + string(filepath.Separator)); return nil }, want: "plain task"},
+		{name: "unlocked again", run: func() error { return a.UnlockVault(vaultRoot, "passphrase") }, want: "plain task, secret task"},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := taskTexts(); got != step.want {
+			t.Errorf("%s: tasks = %q, want %q", step.name, got, step.want)
+		}
+	}
+	stats, err := a.WorkspaceStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Notes != 2 {
+		t.Errorf("workspace stats count %d notes, want 2", stats.Notes)
+	}
+}
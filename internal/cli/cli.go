@@ -0,0 +1,212 @@
+// Package cli implements the headless subcommands of MarkdownDaoNote. They run
+// without starting the Wails runtime and reuse the same services as the app.
+//
+// Exit codes: 0 success, 1 problems found (lint issues, broken links, no search
+// results), 2 usage error, 3 runtime failure.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+const (
+	ExitOK       = 0
+	ExitProblems = 1
+	ExitUsage    = 2
+	ExitFailure  = 3
+)
+
+// Env carries the standard streams so commands can be driven from tests and scripts.
+type Env struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+type command struct {
+	summary string
+	run     func(env Env, args []string) int
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"export":      {summary: "Render Markdown files to standalone HTML", run: runExport},
+		"convert":     {summary: "Convert a Markdown document to html, text or json", run: runConvert},
+		"lint":        {summary: "Check Markdown files for style problems", run: runLint},
+		"search":      {summary: "Search notes in a folder", run: runSearch},
+		"replace":     {summary: "Replace text in the notes of a folder", run: runReplace},
+		"check-links": {summary: "Report broken local links and headings", run: runCheckLinks},
+		"new":         {summary: "Create a new note", run: runNew},
+		"help":        {summary: "Show help for a command", run: runHelp},
+	}
+}
+
+// IsCommand reports whether the first command-line argument names a subcommand.
+func IsCommand(name string) bool {
+	if name == "-h" || name == "--help" {
+		return true
+	}
+	_, ok := commands[name]
+	return ok
+}
+
+// Run dispatches args (without the program name) and returns the process exit code.
+func Run(env Env, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		printUsage(env.Stdout)
+		return ExitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.Stderr, "unknown command %q\n\n", args[0])
+		printUsage(env.Stderr)
+		return ExitUsage
+	}
+	return cmd.run(env, args[1:])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: MarkdownDaoNote <command> [flags] [args]")
+	fmt.Fprintln(w, "       MarkdownDaoNote [file ...]    open files in the editor")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'MarkdownDaoNote help <command>' for command flags.")
+}
+
+func runHelp(env Env, args []string) int {
+	if len(args) == 0 {
+		printUsage(env.Stdout)
+		return ExitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok || args[0] == "help" {
+		printUsage(env.Stdout)
+		return ExitOK
+	}
+	return cmd.run(env, []string{"-h"})
+}
+
+// newFlagSet builds a flag set that reports errors instead of exiting.
+func newFlagSet(env Env, name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(env.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(env.Stderr, "Usage: MarkdownDaoNote %s %s\n\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses args, allowing flags after positional arguments, and maps
+// flag errors onto exit codes. ok is false when the command should stop and
+// return code.
+func parseFlags(flags *flag.FlagSet, args []string) (code int, ok bool) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return ExitOK, false
+			}
+			return ExitUsage, false
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		if args[0] == "--" {
+			positional = append(positional, args[1:]...)
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	// 重新解析以便 flags.Args() 返回全部位置参数
+	_ = flags.Parse(append([]string{"--"}, positional...))
+	return ExitOK, true
+}
+
+// stringList is a repeatable string flag that also accepts comma-separated values.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// fail prints an error and returns the runtime failure exit code.
+func fail(env Env, err error) int {
+	fmt.Fprintf(env.Stderr, "error: %v\n", err)
+	return ExitFailure
+}
+
+// markdownInput is a Markdown file named on the command line. Rel is the path
+// relative to the folder argument it was found in (or the base name).
+type markdownInput struct {
+	Path string
+	Rel  string
+}
+
+// expandMarkdownArgs turns files and folders into a list of Markdown files.
+func expandMarkdownArgs(args []string) ([]markdownInput, error) {
+	inputs := []markdownInput{}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			inputs = append(inputs, markdownInput{Path: arg, Rel: filepath.Base(arg)})
+			continue
+		}
+		err = services.WalkMarkdownFiles(arg, func(path string, _ fs.FileInfo) error {
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				rel = filepath.Base(path)
+			}
+			inputs = append(inputs, markdownInput{Path: path, Rel: rel})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+type exportResult struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+func runExport(env Env, args []string) int {
+	flags := newFlagSet(env, "export", "[flags] <file|folder>...")
+	out := flags.String("out", "", "output file (single input) or folder; defaults to next to each source")
+	asJSON := flags.Bool("json", false, "print results as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	inputs, err := expandMarkdownArgs(flags.Args())
+	if err != nil {
+		return fail(env, err)
+	}
+
+	singleFile := len(inputs) == 1 && strings.EqualFold(filepath.Ext(*out), ".html")
+	results := make([]exportResult, 0, len(inputs))
+	failed := false
+	for _, input := range inputs {
+		target := exportTarget(input, *out, singleFile)
+		result := exportResult{Input: input.Path, Output: target}
+		if err := exportFile(input.Path, target); err != nil {
+			result.Error = err.Error()
+			failed = true
+		}
+		results = append(results, result)
+	}
+
+	if *asJSON {
+		if err := writeJSON(env.Stdout, results); err != nil {
+			return fail(env, err)
+		}
+	} else {
+		for _, result := range results {
+			if result.Error != "" {
+				fmt.Fprintf(env.Stderr, "%s: %s\n", result.Input, result.Error)
+				continue
+			}
+			fmt.Fprintf(env.Stdout, "%s -> %s\n", result.Input, result.Output)
+		}
+	}
+
+	if failed {
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+func exportTarget(input markdownInput, out string, singleFile bool) string {
+	switch {
+	case out == "":
+		return strings.TrimSuffix(input.Path, filepath.Ext(input.Path)) + ".html"
+	case singleFile:
+		return out
+	default:
+		// 保留源文件夹内的相对结构，避免同名文件互相覆盖
+		return filepath.Join(out, strings.TrimSuffix(input.Rel, filepath.Ext(input.Rel))+".html")
+	}
+}
+
+func exportFile(file string, target string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	fallback := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	document, err := services.RenderHTMLDocument(string(data), fallback)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, []byte(document), 0o644)
+}
+
+type convertResult struct {
+	FrontMatter map[string]interface{} `json:"frontMatter"`
+	Title       string                 `json:"title"`
+	HTML        string                 `json:"html"`
+	Text        string                 `json:"text"`
+}
+
+func runConvert(env Env, args []string) int {
+	flags := newFlagSet(env, "convert", "--to html|text|json [flags] <file|->")
+	to := flags.String("to", "html", "target format: html, text or json")
+	out := flags.String("out", "", "write to file instead of stdout")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	var data []byte
+	var err error
+	if flags.Arg(0) == "-" {
+		data, err = io.ReadAll(env.Stdin)
+	} else {
+		data, err = os.ReadFile(flags.Arg(0))
+	}
+	if err != nil {
+		return fail(env, err)
+	}
+	content := string(data)
+
+	var output string
+	switch strings.ToLower(*to) {
+	case "html":
+		output, err = services.RenderHTML(content)
+	case "text", "txt":
+		output = services.RenderPlainText(content)
+	case "json":
+		output, err = convertToJSON(content)
+	default:
+		fmt.Fprintf(env.Stderr, "unsupported format %q\n", *to)
+		return ExitUsage
+	}
+	if err != nil {
+		return fail(env, err)
+	}
+
+	if *out == "" {
+		fmt.Fprint(env.Stdout, output)
+		return ExitOK
+	}
+	if err := os.WriteFile(*out, []byte(output), 0o644); err != nil {
+		return fail(env, err)
+	}
+	return ExitOK
+}
+
+func convertToJSON(content string) (string, error) {
+	frontMatter, _, err := services.ParseFrontMatter(content)
+	if err != nil {
+		return "", errors.New("invalid front matter: " + err.Error())
+	}
+	html, err := services.RenderHTML(content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = writeJSON(&buf, convertResult{
+		FrontMatter: frontMatter,
+		Title:       services.DocumentTitle(content),
+		HTML:        html,
+		Text:        services.RenderPlainText(content),
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
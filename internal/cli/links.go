@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func runCheckLinks(env Env, args []string) int {
+	flags := newFlagSet(env, "check-links", "[flags] [folder|file]...")
+	var include, exclude stringList
+	external := flags.Bool("external", false, "also check http(s) links")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout for each external request")
+	root := flags.String("root", "", "workspace root used for /absolute links (defaults to the folder argument)")
+	asJSON := flags.Bool("json", false, "print issues as JSON")
+	flags.Var(&include, "include", "glob of files to include (repeatable)")
+	flags.Var(&exclude, "exclude", "glob of files to exclude (repeatable)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	targets := flags.Args()
+	if len(targets) == 0 {
+		targets = []string{"."}
+	}
+	opts := services.LinkCheckOptions{Include: include, Exclude: exclude, External: *external, Timeout: *timeout}
+
+	issues := []services.LinkIssue{}
+	for _, target := range targets {
+		info, err := os.Stat(target)
+		if err != nil {
+			return fail(env, err)
+		}
+
+		if info.IsDir() {
+			found, err := services.CheckLinks(*root, target, opts)
+			if err != nil {
+				return fail(env, err)
+			}
+			issues = append(issues, found...)
+			continue
+		}
+
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return fail(env, err)
+		}
+		workspace := *root
+		if workspace == "" {
+			workspace = filepath.Dir(target)
+		}
+		issues = append(issues, services.CheckDocumentLinks(workspace, target, string(data), opts)...)
+	}
+
+	if *asJSON {
+		if err := writeJSON(env.Stdout, issues); err != nil {
+			return fail(env, err)
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintf(env.Stdout, "%s:%d:%d: %s (%s)\n", issue.Path, issue.Line, issue.Column, issue.Target, issue.Reason)
+		}
+	}
+
+	if len(issues) > 0 {
+		return ExitProblems
+	}
+	return ExitOK
+}
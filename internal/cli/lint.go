@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+type lintResult struct {
+	Path        string                    `json:"path"`
+	Diagnostics []services.LintDiagnostic `json:"diagnostics"`
+	Fixed       int                       `json:"fixed,omitempty"`
+}
+
+func runLint(env Env, args []string) int {
+	flags := newFlagSet(env, "lint", "[flags] <file|folder>...")
+	asJSON := flags.Bool("json", false, "print diagnostics as JSON")
+	fix := flags.Bool("fix", false, "apply safe fixes and report what is left")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	inputs, err := expandMarkdownArgs(flags.Args())
+	if err != nil {
+		return fail(env, err)
+	}
+
+	// 每个目录使用最近的 .markdowndaonote-lint.yml
+	configs := map[string]services.LintConfig{}
+	files := services.NewFileService()
+	results := make([]lintResult, 0, len(inputs))
+	total := 0
+	for _, input := range inputs {
+		dir := filepath.Dir(input.Path)
+		config, ok := configs[dir]
+		if !ok {
+			if config, err = services.LoadLintConfig(dir); err != nil {
+				return fail(env, err)
+			}
+			configs[dir] = config
+		}
+
+		data, err := os.ReadFile(input.Path)
+		if err != nil {
+			return fail(env, err)
+		}
+		content := string(data)
+		fixed := 0
+		if *fix {
+			if content, fixed = services.FixDocument(content, config); fixed > 0 {
+				if err := files.Write(input.Path, content); err != nil {
+					return fail(env, err)
+				}
+			}
+		}
+		diagnostics := services.LintDocument(content, config)
+		total += len(diagnostics)
+		results = append(results, lintResult{Path: input.Path, Diagnostics: diagnostics, Fixed: fixed})
+	}
+
+	if *asJSON {
+		if err := writeJSON(env.Stdout, results); err != nil {
+			return fail(env, err)
+		}
+	} else {
+		for _, result := range results {
+			if result.Fixed > 0 {
+				fmt.Fprintf(env.Stdout, "%s: fixed %d problem(s)\n", result.Path, result.Fixed)
+			}
+			for _, d := range result.Diagnostics {
+				fmt.Fprintf(env.Stdout, "%s:%d:%d: %s: %s [%s]\n", result.Path, d.Line, d.Column, d.Severity, d.Message, d.Rule)
+			}
+		}
+	}
+
+	if total > 0 {
+		return ExitProblems
+	}
+	return ExitOK
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+type newResult struct {
+	Path string `json:"path"`
+}
+
+func runNew(env Env, args []string) int {
+	flags := newFlagSet(env, "new", "[flags] <path>")
+	title := flags.String("title", "", "heading written into the note (defaults to the file name)")
+	templateName := flags.String("template", "", "template name or file; <path> may then be a folder named by the template's filename pattern")
+	vars := templateVars{}
+	flags.Var(vars, "var", "template variable as name=value (repeatable)")
+	force := flags.Bool("force", false, "overwrite an existing file")
+	asJSON := flags.Bool("json", false, "print the created path as JSON")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	path := flags.Arg(0)
+	content := ""
+	if *templateName != "" {
+		var err error
+		if path, content, err = renderNewFromTemplate(path, *templateName, strings.TrimSpace(*title), vars); err != nil {
+			return fail(env, err)
+		}
+	} else {
+		if filepath.Ext(path) == "" {
+			path += ".md"
+		}
+		heading := strings.TrimSpace(*title)
+		if heading == "" {
+			heading = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		content = "# " + heading + "\n"
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fail(env, fmt.Errorf("%s: %w", path, os.ErrExist))
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fail(env, err)
+	}
+
+	files := services.NewFileService()
+	if err := files.CreateDirectory(filepath.Dir(path)); err != nil {
+		return fail(env, err)
+	}
+	if err := files.CreateFile(path); err != nil {
+		return fail(env, err)
+	}
+	if err := files.Write(path, content); err != nil {
+		return fail(env, err)
+	}
+
+	if *asJSON {
+		absolute, _ := filepath.Abs(path)
+		if err := writeJSON(env.Stdout, newResult{Path: absolute}); err != nil {
+			return fail(env, err)
+		}
+		return ExitOK
+	}
+	fmt.Fprintln(env.Stdout, path)
+	return ExitOK
+}
+
+// renderNewFromTemplate returns the note path and content for `new --template`.
+// A target that is a folder (existing, or ending in a separator) takes the
+// file name from the template.
+func renderNewFromTemplate(target string, name string, title string, vars templateVars) (string, string, error) {
+	isDir := strings.HasSuffix(target, "/") || strings.HasSuffix(target, string(filepath.Separator))
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		isDir = true
+	}
+	dir := filepath.Dir(target)
+	if isDir {
+		dir = filepath.Clean(target)
+	} else if title == "" {
+		title = strings.TrimSuffix(filepath.Base(target), filepath.Ext(target))
+	}
+	if title != "" {
+		vars["title"] = title
+	}
+
+	path, err := services.FindTemplate(name, templateSearchDirs(dir)...)
+	if err != nil {
+		return "", "", err
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	rendered, err := services.RenderTemplate(string(source), vars, time.Now())
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	if isDir {
+		return filepath.Join(dir, rendered.Filename), rendered.Content, nil
+	}
+	if filepath.Ext(target) == "" {
+		target += ".md"
+	}
+	return target, rendered.Content, nil
+}
+
+// templateSearchDirs lists the config templates followed by the .templates
+// folders of dir and its parents, nearest last so it wins.
+func templateSearchDirs(dir string) []string {
+	dirs := []string{filepath.Join(services.NewSettingsService().Dir(), "templates")}
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		return dirs
+	}
+	ancestors := []string{}
+	for current := absolute; ; {
+		ancestors = append(ancestors, filepath.Join(current, services.TemplateDirName))
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		dirs = append(dirs, ancestors[i])
+	}
+	return dirs
+}
+
+// templateVars is a repeatable name=value flag.
+type templateVars map[string]string
+
+func (v templateVars) String() string {
+	parts := make([]string, 0, len(v))
+	for name, value := range v {
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (v templateVars) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	v[strings.TrimSpace(name)] = val
+	return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func runReplace(env Env, args []string) int {
+	flags := newFlagSet(env, "replace", "[flags] <pattern> <replacement> [folder]")
+	var include, exclude stringList
+	regex := flags.Bool("regex", false, "treat the pattern as a regular expression ($1 and ${name} expand groups)")
+	caseSensitive := flags.Bool("case-sensitive", false, "match case")
+	wholeWord := flags.Bool("word", false, "match whole words only")
+	allFiles := flags.Bool("all-files", false, "replace in every text file, not only Markdown")
+	dryRun := flags.Bool("dry-run", false, "print the changes without writing them")
+	asJSON := flags.Bool("json", false, "print changes as JSON")
+	flags.Var(&include, "include", "glob of files to include (repeatable)")
+	flags.Var(&exclude, "exclude", "glob of files to exclude (repeatable)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() < 2 || flags.NArg() > 3 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	root := "."
+	if flags.NArg() == 3 {
+		root = flags.Arg(2)
+	}
+
+	opts := services.ReplaceOptions{
+		SearchOptions: services.SearchOptions{
+			Query:         flags.Arg(0),
+			Regex:         *regex,
+			CaseSensitive: *caseSensitive,
+			WholeWord:     *wholeWord,
+			Include:       include,
+			Exclude:       exclude,
+			AllFiles:      *allFiles,
+		},
+		Replacement: flags.Arg(1),
+	}
+	files := services.NewFileService()
+	preview, err := files.PreviewReplace(root, opts)
+	if err != nil {
+		return fail(env, err)
+	}
+
+	if !*dryRun && preview.Changes > 0 {
+		selections := make([]services.ReplaceSelection, 0, len(preview.Files))
+		for _, file := range preview.Files {
+			selection := services.ReplaceSelection{Path: file.Path, Hash: file.Hash}
+			for _, change := range file.Changes {
+				selection.Offsets = append(selection.Offsets, change.Offset)
+			}
+			selections = append(selections, selection)
+		}
+		if _, _, err := files.ReplaceInWorkspace(root, opts, selections); err != nil {
+			return fail(env, err)
+		}
+	}
+
+	if *asJSON {
+		if err := writeJSON(env.Stdout, preview); err != nil {
+			return fail(env, err)
+		}
+	} else {
+		for _, file := range preview.Files {
+			for _, change := range file.Changes {
+				fmt.Fprintf(env.Stdout, "%s:%d:%d: %s\n", file.Path, change.Line, change.Column, change.Preview)
+			}
+		}
+	}
+
+	if preview.Changes == 0 {
+		return ExitProblems
+	}
+	return ExitOK
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/yourname/MarkdownDaoNote/internal/services"
+)
+
+func runSearch(env Env, args []string) int {
+	flags := newFlagSet(env, "search", "[flags] <query> [folder]")
+	var include, exclude stringList
+	regex := flags.Bool("regex", false, "treat the query as a regular expression")
+	caseSensitive := flags.Bool("case-sensitive", false, "match case")
+	wholeWord := flags.Bool("word", false, "match whole words only")
+	allFiles := flags.Bool("all-files", false, "search every text file, not only Markdown")
+	maxResults := flags.Int("max", 0, "stop after this many matches (0 = unlimited)")
+	asJSON := flags.Bool("json", false, "print matches as JSON")
+	flags.Var(&include, "include", "glob of files to include (repeatable)")
+	flags.Var(&exclude, "exclude", "glob of files to exclude (repeatable)")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	root := "."
+	if flags.NArg() == 2 {
+		root = flags.Arg(1)
+	}
+
+	matches, err := services.SearchWorkspace(root, services.SearchOptions{
+		Query:         flags.Arg(0),
+		Regex:         *regex,
+		CaseSensitive: *caseSensitive,
+		WholeWord:     *wholeWord,
+		Include:       include,
+		Exclude:       exclude,
+		AllFiles:      *allFiles,
+		MaxResults:    *maxResults,
+	})
+	if err != nil {
+		return fail(env, err)
+	}
+
+	if *asJSON {
+		if err := writeJSON(env.Stdout, matches); err != nil {
+			return fail(env, err)
+		}
+	} else {
+		for _, m := range matches {
+			fmt.Fprintf(env.Stdout, "%s:%d:%d: %s\n", m.Path, m.Line, m.Column, m.Text)
+		}
+	}
+
+	if len(matches) == 0 {
+		return ExitProblems
+	}
+	return ExitOK
+}
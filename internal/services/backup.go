@@ -0,0 +1,525 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupTarget configures S3 backups of one workspace folder.
+type BackupTarget struct {
+	Workspace string `json:"workspace"`
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	// Prefix separates several workspaces in one bucket.
+	Prefix    string `json:"prefix"`
+	AccessKey string `json:"accessKey"`
+	// SecretKey is kept in the SecretStore; settings.json written by older
+	// versions may still hold it.
+	SecretKey string `json:"secretKey,omitempty"`
+	// IntervalHours schedules a backup every n hours; zero disables it.
+	IntervalHours int             `json:"intervalHours"`
+	Retention     BackupRetention `json:"retention"`
+}
+
+// BackupRetention decides which snapshots survive pruning. A snapshot is kept
+// when any rule selects it; with every rule at zero nothing is pruned.
+type BackupRetention struct {
+	KeepLast    int `json:"keepLast"`
+	KeepDaily   int `json:"keepDaily"`
+	KeepWeekly  int `json:"keepWeekly"`
+	KeepMonthly int `json:"keepMonthly"`
+}
+
+// Enabled reports whether any retention rule is set.
+func (r BackupRetention) Enabled() bool {
+	return r.KeepLast > 0 || r.KeepDaily > 0 || r.KeepWeekly > 0 || r.KeepMonthly > 0
+}
+
+// BackupFile is a file in a snapshot, stored as a list of chunk hashes.
+type BackupFile struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	Chunks  []string    `json:"chunks"`
+}
+
+// BackupSnapshot is the manifest written for every backup.
+type BackupSnapshot struct {
+	ID        string       `json:"id"`
+	Time      time.Time    `json:"time"`
+	Host      string       `json:"host"`
+	Workspace string       `json:"workspace"`
+	Files     []BackupFile `json:"files"`
+}
+
+// BackupSummary lists a snapshot without its files.
+type BackupSummary struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	Host  string    `json:"host"`
+	Files int       `json:"files"`
+	Size  int64     `json:"size"`
+}
+
+// BackupReport describes a finished backup.
+type BackupReport struct {
+	Snapshot      BackupSummary `json:"snapshot"`
+	NewChunks     int           `json:"newChunks"`
+	ReusedChunks  int           `json:"reusedChunks"`
+	UploadedBytes int64         `json:"uploadedBytes"`
+	Pruned        []string      `json:"pruned"`
+}
+
+// BackupProgress is reported while backing up or restoring.
+type BackupProgress struct {
+	Phase string `json:"phase"`
+	Path  string `json:"path,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// BackupStore keeps content-addressed snapshots of a folder in an S3 bucket:
+// files are split into content-defined chunks stored once under
+// "<prefix>/chunks/", and each snapshot is a manifest under "<prefix>/snapshots/".
+type BackupStore struct {
+	client *S3Client
+	prefix string
+}
+
+const (
+	backupChunksDir    = "chunks/"
+	backupSnapshotsDir = "snapshots/"
+	backupIDLayout     = "20060102T150405.000Z"
+	// backupIDLayoutV1 names snapshots taken by older versions.
+	backupIDLayoutV1 = "20060102T150405Z"
+)
+
+// NewBackupStore creates the store described by target.
+func NewBackupStore(target BackupTarget) (*BackupStore, error) {
+	client, err := NewS3Client(target.Endpoint, target.Region, target.Bucket, target.AccessKey, target.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(strings.TrimSpace(target.Prefix), "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &BackupStore{client: client, prefix: prefix}, nil
+}
+
+// Backup uploads a new snapshot of root. Chunks already in the bucket are
+// not uploaded again.
+func (s *BackupStore) Backup(ctx context.Context, root string, progress func(BackupProgress)) (*BackupReport, error) {
+	if progress == nil {
+		progress = func(BackupProgress) {}
+	}
+	root = filepath.Clean(root)
+
+	progress(BackupProgress{Phase: "scanning"})
+	existing, err := s.chunkSet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	type localFile struct {
+		path string
+		info fs.FileInfo
+	}
+	var files []localFile
+	err = WalkWorkspaceFiles(root, false, func(file string, info fs.FileInfo) error {
+		if !hiddenFileName(info.Name()) {
+			files = append(files, localFile{file, info})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := s.snapshotTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	snapshot := BackupSnapshot{ID: now.Format(backupIDLayout), Time: now, Host: host, Workspace: root, Files: []BackupFile{}}
+	report := &BackupReport{}
+
+	for i, file := range files {
+		rel, err := filepath.Rel(root, file.path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		progress(BackupProgress{Phase: "uploading", Path: rel, Done: i, Total: len(files)})
+
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			// 备份期间被删除的文件跳过
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		entry := BackupFile{Path: rel, Size: int64(len(data)), Mode: file.info.Mode().Perm(), ModTime: file.info.ModTime().UTC(), Chunks: []string{}}
+		for _, chunk := range splitChunks(data) {
+			sum := sha256.Sum256(chunk)
+			id := fmt.Sprintf("%x", sum)
+			entry.Chunks = append(entry.Chunks, id)
+			if _, ok := existing[id]; ok {
+				report.ReusedChunks++
+				continue
+			}
+			compressed, err := gzipBytes(chunk)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.client.PutObject(ctx, s.chunkKey(id), compressed); err != nil {
+				return nil, err
+			}
+			existing[id] = struct{}{}
+			report.NewChunks++
+			report.UploadedBytes += int64(len(compressed))
+		}
+		snapshot.Files = append(snapshot.Files, entry)
+	}
+
+	// 清单最后写入：只有全部数据块上传成功的快照才可见
+	manifest, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.PutObject(ctx, s.snapshotKey(snapshot.ID), manifest); err != nil {
+		return nil, err
+	}
+
+	report.Snapshot = summarizeSnapshot(snapshot)
+	progress(BackupProgress{Phase: "done", Done: len(files), Total: len(files)})
+	return report, nil
+}
+
+// Snapshots lists the snapshots in the bucket, newest first.
+func (s *BackupStore) Snapshots(ctx context.Context) ([]BackupSummary, error) {
+	objects, err := s.client.ListObjects(ctx, s.prefix+backupSnapshotsDir)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []BackupSummary{}
+	for _, object := range objects {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(object.Key, s.prefix+backupSnapshotsDir), ".json")
+		if !ok {
+			continue
+		}
+		snapshot, err := s.Snapshot(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summarizeSnapshot(*snapshot))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Time.After(summaries[j].Time) })
+	return summaries, nil
+}
+
+// Snapshot reads the manifest of snapshot id.
+func (s *BackupStore) Snapshot(ctx context.Context, id string) (*BackupSnapshot, error) {
+	if !validBackupID(id) {
+		return nil, fmt.Errorf("invalid snapshot id %q", id)
+	}
+	data, err := s.client.GetObject(ctx, s.snapshotKey(id))
+	if err != nil {
+		return nil, err
+	}
+	var snapshot BackupSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("corrupt snapshot %s: %w", id, err)
+	}
+	return &snapshot, nil
+}
+
+// Restore writes the files of snapshot id below destination. rel selects a
+// single file or a folder; empty restores everything. Existing files are
+// overwritten. It returns the restored paths relative to destination.
+func (s *BackupStore) Restore(ctx context.Context, id string, rel string, destination string, progress func(BackupProgress)) ([]string, error) {
+	if progress == nil {
+		progress = func(BackupProgress) {}
+	}
+	snapshot, err := s.Snapshot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rel = strings.Trim(filepath.ToSlash(rel), "/")
+	var selected []BackupFile
+	for _, file := range snapshot.Files {
+		if rel == "" || file.Path == rel || strings.HasPrefix(file.Path, rel+"/") {
+			selected = append(selected, file)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%s is not in snapshot %s", rel, id)
+	}
+
+	restored := []string{}
+	for i, file := range selected {
+		progress(BackupProgress{Phase: "restoring", Path: file.Path, Done: i, Total: len(selected)})
+		// 清单来自远端，拒绝逃逸出目标目录的路径；反斜杠在 Windows 上是分隔符
+		clean := path.Clean(file.Path)
+		if clean == "." || strings.Contains(file.Path, "\\") || !filepath.IsLocal(filepath.FromSlash(clean)) {
+			return restored, fmt.Errorf("snapshot %s contains unsafe path %q", id, file.Path)
+		}
+
+		var content bytes.Buffer
+		for _, chunk := range file.Chunks {
+			data, err := s.readChunk(ctx, chunk)
+			if err != nil {
+				return restored, fmt.Errorf("%s: %w", file.Path, err)
+			}
+			content.Write(data)
+		}
+
+		target := filepath.Join(destination, filepath.FromSlash(clean))
+		if err := writeLocalFile(target, content.Bytes()); err != nil {
+			return restored, err
+		}
+		if file.Mode != 0 {
+			_ = os.Chmod(target, file.Mode.Perm())
+		}
+		_ = os.Chtimes(target, file.ModTime, file.ModTime)
+		restored = append(restored, clean)
+	}
+	progress(BackupProgress{Phase: "done", Done: len(selected), Total: len(selected)})
+	return restored, nil
+}
+
+// Prune deletes the snapshots not kept by retention, then the chunks no
+// remaining snapshot refers to. It returns the removed snapshot ids.
+func (s *BackupStore) Prune(ctx context.Context, retention BackupRetention) ([]string, error) {
+	removed := []string{}
+	if !retention.Enabled() {
+		return removed, nil
+	}
+	summaries, err := s.Snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := selectRetained(summaries, retention)
+	for _, summary := range summaries {
+		if _, ok := keep[summary.ID]; ok {
+			continue
+		}
+		if err := s.client.DeleteObject(ctx, s.snapshotKey(summary.ID)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, summary.ID)
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	used := map[string]struct{}{}
+	for id := range keep {
+		snapshot, err := s.Snapshot(ctx, id)
+		if err != nil {
+			return removed, err
+		}
+		for _, file := range snapshot.Files {
+			for _, chunk := range file.Chunks {
+				used[chunk] = struct{}{}
+			}
+		}
+	}
+	chunks, err := s.chunkSet(ctx)
+	if err != nil {
+		return removed, err
+	}
+	for chunk := range chunks {
+		if _, ok := used[chunk]; ok {
+			continue
+		}
+		if err := s.client.DeleteObject(ctx, s.chunkKey(chunk)); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// selectRetained applies retention to snapshots sorted newest first.
+func selectRetained(summaries []BackupSummary, retention BackupRetention) map[string]struct{} {
+	keep := map[string]struct{}{}
+	for i := 0; i < retention.KeepLast && i < len(summaries); i++ {
+		keep[summaries[i].ID] = struct{}{}
+	}
+
+	bucketRule := func(limit int, bucket func(time.Time) string) {
+		seen := map[string]struct{}{}
+		for _, summary := range summaries {
+			if len(seen) >= limit {
+				return
+			}
+			key := bucket(summary.Time.Local())
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			// 每个时间段保留最新的一个快照
+			seen[key] = struct{}{}
+			keep[summary.ID] = struct{}{}
+		}
+	}
+	bucketRule(retention.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	bucketRule(retention.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return strconv.Itoa(year) + "-W" + strconv.Itoa(week)
+	})
+	bucketRule(retention.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+	return keep
+}
+
+// snapshotTime returns the time naming a new snapshot, moved past any
+// snapshot already stored under the same id.
+func (s *BackupStore) snapshotTime(ctx context.Context) (time.Time, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for {
+		_, err := s.client.GetObject(ctx, s.snapshotKey(now.Format(backupIDLayout)))
+		if errors.Is(err, ErrS3NotFound) {
+			return now, nil
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		now = now.Add(time.Millisecond)
+	}
+}
+
+func validBackupID(id string) bool {
+	for _, layout := range []string{backupIDLayout, backupIDLayoutV1} {
+		if parsed, err := time.Parse(layout, id); err == nil && parsed.Format(layout) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *BackupStore) chunkSet(ctx context.Context) (map[string]struct{}, error) {
+	objects, err := s.client.ListObjects(ctx, s.prefix+backupChunksDir)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make(map[string]struct{}, len(objects))
+	for _, object := range objects {
+		chunks[path.Base(object.Key)] = struct{}{}
+	}
+	return chunks, nil
+}
+
+func (s *BackupStore) readChunk(ctx context.Context, id string) ([]byte, error) {
+	compressed, err := s.client.GetObject(ctx, s.chunkKey(id))
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s: %w", id, err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s: %w", id, err)
+	}
+	if sum := sha256.Sum256(data); fmt.Sprintf("%x", sum) != id {
+		return nil, fmt.Errorf("chunk %s is corrupt", id)
+	}
+	return data, nil
+}
+
+func (s *BackupStore) chunkKey(id string) string {
+	return s.prefix + backupChunksDir + id[:2] + "/" + id
+}
+
+func (s *BackupStore) snapshotKey(id string) string {
+	return s.prefix + backupSnapshotsDir + id + ".json"
+}
+
+func summarizeSnapshot(snapshot BackupSnapshot) BackupSummary {
+	summary := BackupSummary{ID: snapshot.ID, Time: snapshot.Time, Host: snapshot.Host, Files: len(snapshot.Files)}
+	for _, file := range snapshot.Files {
+		summary.Size += file.Size
+	}
+	return summary
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// 内容定义分块（gear 滚动哈希）：插入或删除内容只影响附近的块，其余块在
+// 快照间得以复用。块长按笔记的大小选取，小于最小块长的文件整体作为一块。
+const (
+	chunkMinSize = 2 << 10
+	chunkMaxSize = 64 << 10
+	// 取哈希的高位：低位只取决于最近几个字节，在重复的文本上难以命中
+	chunkMask = (1<<13 - 1) << 51 // 平均约 10 KiB
+)
+
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		sum := sha256.Sum256([]byte("markdowndaonote-gear-" + strconv.Itoa(i)))
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
+
+func splitChunks(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	var chunks [][]byte
+	for len(data) > 0 {
+		size := chunkBoundary(data)
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return chunks
+}
+
+func chunkBoundary(data []byte) int {
+	if len(data) <= chunkMinSize {
+		return len(data)
+	}
+	limit := len(data)
+	if limit > chunkMaxSize {
+		limit = chunkMaxSize
+	}
+	var hash uint64
+	for i := chunkMinSize; i < limit; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 serves the part of the S3 API used by S3Client from memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// fakeS3PageSize is small so that listing follows continuation tokens.
+const fakeS3PageSize = 3
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "bucket" {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r.URL.Query().Get("prefix"), r.URL.Query().Get("continuation-token"))
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string, token string) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var result s3ListResult
+	if len(keys) > fakeS3PageSize {
+		keys = keys[:fakeS3PageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, struct {
+			Key          string `xml:"Key"`
+			Size         int64  `xml:"Size"`
+			LastModified string `xml:"LastModified"`
+		}{Key: key, Size: int64(len(f.objects[key])), LastModified: time.Now().UTC().Format(time.RFC3339)})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) keys(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newTestBackupStore(t *testing.T) (*BackupStore, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	store, err := NewBackupStore(BackupTarget{Endpoint: server.URL, Bucket: "bucket", Prefix: "notes", AccessKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, fake
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	store, fake := newTestBackupStore(t)
+	root := t.TempDir()
+	tree := map[string]string{"a.md": "alpha", "dir/b.md": "beta", "dir/sub/c.md": "gamma", "empty.md": ""}
+	writeTree(t, root, tree)
+	writeTree(t, root, map[string]string{".git/HEAD": "ref"})
+
+	ctx := context.Background()
+	first, err := store.Backup(ctx, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Backup(ctx, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Snapshot.ID == second.Snapshot.ID {
+		t.Fatalf("two backups share the id %s", first.Snapshot.ID)
+	}
+	if second.NewChunks != 0 || second.ReusedChunks != first.NewChunks {
+		t.Errorf("second backup uploaded %d and reused %d chunks, want 0 and %d", second.NewChunks, second.ReusedChunks, first.NewChunks)
+	}
+	summaries, err := store.Snapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].ID != second.Snapshot.ID || summaries[0].Files != len(tree) {
+		t.Errorf("snapshots = %+v", summaries)
+	}
+	if len(fake.keys("notes/snapshots/")) != 2 {
+		t.Errorf("snapshot objects = %v", fake.keys("notes/snapshots/"))
+	}
+
+	tests := []struct {
+		rel  string
+		want map[string]string
+	}{
+		{rel: "", want: tree},
+		{rel: "dir", want: map[string]string{"dir/b.md": "beta", "dir/sub/c.md": "gamma"}},
+		{rel: "dir/sub/c.md", want: map[string]string{"dir/sub/c.md": "gamma"}},
+	}
+	for _, tt := range tests {
+		destination := t.TempDir()
+		restored, err := store.Restore(ctx, first.Snapshot.ID, tt.rel, destination, nil)
+		if err != nil {
+			t.Fatalf("restore %q: %v", tt.rel, err)
+		}
+		if len(restored) != len(tt.want) {
+			t.Errorf("restore %q: restored %v", tt.rel, restored)
+		}
+		if got := readTree(t, destination); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("restore %q: tree = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+	if _, err := store.Restore(ctx, first.Snapshot.ID, "missing.md", t.TempDir(), nil); err == nil {
+		t.Error("restoring a path missing from the snapshot succeeded")
+	}
+}
+
+func TestBackupCarriesVaults(t *testing.T) {
+	store, _ := newTestBackupStore(t)
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "secret"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	vault, err := CreateVault(filepath.Join(root, "secret"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.WriteFile(filepath.Join(vault.Root(), "plan.md"), []byte("plan\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	report, err := store.Backup(ctx, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := t.TempDir()
+	if _, err := store.Restore(ctx, report.Snapshot.ID, "", destination, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := UnlockVault(filepath.Join(destination, "secret"), "passphrase")
+	if err != nil {
+		t.Fatalf("unlocking the restored vault: %v", err)
+	}
+	data, err := restored.ReadFile(filepath.Join(restored.Root(), "plan.md"))
+	if err != nil || string(data) != "plan\n" {
+		t.Errorf("restored note = %q, %v", data, err)
+	}
+}
+
+func TestBackupChunksNotes(t *testing.T) {
+	store, _ := newTestBackupStore(t)
+	root := t.TempDir()
+	random := rand.New(rand.NewSource(1))
+	words := []string{"note", "link", "task", "heading", "list", "quote", "code", "table"}
+	var builder strings.Builder
+	for builder.Len() < 200<<10 {
+		builder.WriteString(words[random.Intn(len(words))])
+		builder.WriteByte(" \n"[random.Intn(2)])
+	}
+	note := builder.String()
+	writeTree(t, root, map[string]string{"long.md": note})
+
+	ctx := context.Background()
+	first, err := store.Backup(ctx, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NewChunks < 4 {
+		t.Fatalf("a 200 KiB note was split into %d chunks", first.NewChunks)
+	}
+
+	// 中间插入一段文字，只有附近的块需要重新上传
+	edited := note[:len(note)/2] + "an inserted paragraph\n" + note[len(note)/2:]
+	writeTree(t, root, map[string]string{"long.md": edited})
+	second, err := store.Backup(ctx, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.NewChunks > 2 || second.ReusedChunks < first.NewChunks-2 {
+		t.Errorf("after a small edit uploaded %d and reused %d of %d chunks", second.NewChunks, second.ReusedChunks, first.NewChunks)
+	}
+
+	destination := t.TempDir()
+	if _, err := store.Restore(ctx, second.Snapshot.ID, "long.md", destination, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destination, "long.md")); string(data) != edited {
+		t.Error("restored note differs from the backed up one")
+	}
+}
+
+func TestBackupRestoreRejectsUnsafePaths(t *testing.T) {
+	tests := []string{"../evil.md", "/evil.md", "dir/../../evil.md", `..\evil.md`, `dir\evil.md`, ""}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			store, fake := newTestBackupStore(t)
+			id := time.Now().UTC().Format(backupIDLayout)
+			manifest, _ := json.Marshal(BackupSnapshot{ID: id, Time: time.Now(), Files: []BackupFile{{Path: name, Chunks: []string{}}}})
+			fake.objects[store.snapshotKey(id)] = manifest
+
+			parent := t.TempDir()
+			destination := filepath.Join(parent, "workspace")
+			if _, err := store.Restore(context.Background(), id, "", destination, nil); err == nil {
+				t.Errorf("restoring %q succeeded", name)
+			}
+			if got := readTree(t, parent); len(got) != 0 {
+				t.Errorf("restore wrote %v", got)
+			}
+		})
+	}
+}
+
+func TestBackupSnapshotIDs(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"20260102T030405.678Z", true},
+		{"20260102T030405Z", true},
+		{"20260102T030405.6Z", false},
+		{"../20260102T030405Z", false},
+		{"latest", false},
+	}
+	for _, tt := range tests {
+		if got := validBackupID(tt.id); got != tt.want {
+			t.Errorf("validBackupID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestBackupPrune(t *testing.T) {
+	store, fake := newTestBackupStore(t)
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.md": "old"})
+	ctx := context.Background()
+	if _, err := store.Backup(ctx, root, nil); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]string{"a.md": "new"})
+	latest, err := store.Backup(ctx, root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.Prune(ctx, BackupRetention{KeepLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] == latest.Snapshot.ID {
+		t.Errorf("removed %v, want the older snapshot", removed)
+	}
+	if want := []string{store.snapshotKey(latest.Snapshot.ID)}; !reflect.DeepEqual(fake.keys("notes/snapshots/"), want) {
+		t.Errorf("snapshots left = %v, want %v", fake.keys("notes/snapshots/"), want)
+	}
+	if chunks := fake.keys("notes/chunks/"); len(chunks) != 1 {
+		t.Errorf("chunks left = %v, want only the chunk of the latest snapshot", chunks)
+	}
+	destination := t.TempDir()
+	if _, err := store.Restore(ctx, latest.Snapshot.ID, "", destination, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, destination); !reflect.DeepEqual(got, map[string]string{"a.md": "new"}) {
+		t.Errorf("restored %v", got)
+	}
+}
+
+func TestSelectRetained(t *testing.T) {
+	day := func(d int, hour int) BackupSummary {
+		at := time.Date(2026, 3, d, hour, 0, 0, 0, time.Local)
+		return BackupSummary{ID: fmt.Sprintf("%02d-%02d", d, hour), Time: at}
+	}
+	// 从新到旧
+	summaries := []BackupSummary{day(10, 18), day(10, 9), day(9, 12), day(2, 8), day(1, 8)}
+	tests := []struct {
+		name      string
+		retention BackupRetention
+		want      []string
+	}{
+		{name: "last", retention: BackupRetention{KeepLast: 2}, want: []string{"10-09", "10-18"}},
+		{name: "daily", retention: BackupRetention{KeepDaily: 3}, want: []string{"02-08", "09-12", "10-18"}},
+		{name: "weekly", retention: BackupRetention{KeepWeekly: 2}, want: []string{"02-08", "10-18"}},
+		{name: "monthly", retention: BackupRetention{KeepMonthly: 5}, want: []string{"10-18"}},
+		{name: "combined", retention: BackupRetention{KeepLast: 1, KeepDaily: 2}, want: []string{"09-12", "10-18"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for id := range selectRetained(summaries, tt.retention) {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptedNoteExt marks Markdown notes stored encrypted on disk.
+const EncryptedNoteExt = ".md.enc"
+
+var (
+	// ErrNoteLocked is returned when an encrypted note is used without its key.
+	ErrNoteLocked = errors.New("encrypted note is locked")
+	// ErrWrongPassphrase is returned when a passphrase does not open a note.
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted note")
+)
+
+// 加密文件格式：
+//
+//	magic "MDDNENC1" | logN | r | p | salt(16) | nonce(24) | ciphertext+tag
+//
+// 头部整体作为 AEAD 附加数据，任何改动都会导致解密失败。
+var encryptedNoteMagic = []byte("MDDNENC1")
+
+const (
+	noteSaltSize   = 16
+	noteHeaderSize = 8 + 3 + noteSaltSize
+	noteKeySize    = chacha20poly1305.KeySize
+
+	// scrypt 参数：N=2^15, r=8, p=1，约 32 MiB 内存
+	noteScryptLogN = 15
+	noteScryptR    = 8
+	noteScryptP    = 1
+)
+
+// IsEncryptedNote reports whether path names an encrypted note.
+func IsEncryptedNote(path string) bool {
+	return strings.HasSuffix(strings.ToLower(filepath.Base(path)), EncryptedNoteExt)
+}
+
+// NoteKey is a key derived from a passphrase together with its scrypt
+// parameters, so notes can be re-encrypted without asking again.
+type NoteKey struct {
+	header []byte
+	key    []byte
+}
+
+// NewNoteKey derives a key for a new encrypted note with a fresh salt.
+func NewNoteKey(passphrase string) (*NoteKey, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+	header := make([]byte, noteHeaderSize)
+	copy(header, encryptedNoteMagic)
+	header[8], header[9], header[10] = noteScryptLogN, noteScryptR, noteScryptP
+	if _, err := rand.Read(header[11:]); err != nil {
+		return nil, err
+	}
+	return deriveNoteKey(header, passphrase)
+}
+
+// OpenNote derives the key of an existing note from passphrase and decrypts it.
+func OpenNote(data []byte, passphrase string) (*NoteKey, []byte, error) {
+	if len(data) < noteHeaderSize+chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead ||
+		!bytes.Equal(data[:8], encryptedNoteMagic) {
+		return nil, nil, errors.New("not an encrypted note")
+	}
+	key, err := deriveNoteKey(data[:noteHeaderSize], passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := key.Decrypt(data)
+	if err != nil {
+		key.Wipe()
+		return nil, nil, err
+	}
+	return key, plaintext, nil
+}
+
+func deriveNoteKey(header []byte, passphrase string) (*NoteKey, error) {
+	key, err := scryptKey(passphrase, header[11:noteHeaderSize], int(header[8]), int(header[9]), int(header[10]))
+	if err != nil {
+		return nil, err
+	}
+	return &NoteKey{header: append([]byte(nil), header...), key: key}, nil
+}
+
+// scryptKey derives a 256-bit key from passphrase with N=2^logN.
+func scryptKey(passphrase string, salt []byte, logN int, r int, p int) ([]byte, error) {
+	// 拒绝异常参数，避免恶意文件耗尽内存
+	if logN < 10 || logN > 22 || r < 1 || r > 32 || p < 1 || p > 16 {
+		return nil, fmt.Errorf("unsupported key parameters N=2^%d r=%d p=%d", logN, r, p)
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<logN, r, p, noteKeySize)
+}
+
+// Encrypt seals plaintext with a fresh random nonce.
+func (k *NoteKey) Encrypt(plaintext []byte) ([]byte, error) {
+	if k.key == nil {
+		return nil, ErrNoteLocked
+	}
+	aead, err := chacha20poly1305.NewX(k.key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(k.header)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out = append(out, k.header...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, k.header), nil
+}
+
+// Decrypt opens data sealed with this key.
+func (k *NoteKey) Decrypt(data []byte) ([]byte, error) {
+	if k.key == nil {
+		return nil, ErrNoteLocked
+	}
+	if len(data) < noteHeaderSize+chacha20poly1305.NonceSizeX ||
+		subtle.ConstantTimeCompare(data[:noteHeaderSize], k.header) != 1 {
+		return nil, ErrWrongPassphrase
+	}
+	aead, err := chacha20poly1305.NewX(k.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := data[noteHeaderSize : noteHeaderSize+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, data[noteHeaderSize+aead.NonceSize():], k.header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// clone returns a copy with its own key material.
+func (k *NoteKey) clone() *NoteKey {
+	return &NoteKey{header: append([]byte(nil), k.header...), key: append([]byte(nil), k.key...)}
+}
+
+// Wipe zeroes the key material.
+func (k *NoteKey) Wipe() {
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	k.key = nil
+}
+
+// NoteKeyring keeps the keys of unlocked notes in memory and forgets each
+// one after it has not been used for the idle timeout.
+type NoteKeyring struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	keys    map[string]*keyringEntry
+	onLock  func(path string)
+}
+
+type keyringEntry struct {
+	key   *NoteKey
+	timer *time.Timer
+}
+
+// NewNoteKeyring creates a keyring; onLock (optional) is called with the path
+// of each key that expires.
+func NewNoteKeyring(timeout time.Duration, onLock func(path string)) *NoteKeyring {
+	return &NoteKeyring{timeout: timeout, keys: map[string]*keyringEntry{}, onLock: onLock}
+}
+
+// SetTimeout changes the idle timeout for keys used from now on.
+func (r *NoteKeyring) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	r.timeout = timeout
+	r.mu.Unlock()
+}
+
+// Put stores key for path, replacing (and wiping) any previous key.
+func (r *NoteKeyring) Put(path string, key *NoteKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.keys[path]; ok && old.key != key {
+		old.timer.Stop()
+		old.key.Wipe()
+	}
+	entry := &keyringEntry{key: key}
+	entry.timer = time.AfterFunc(r.timeout, func() { r.expire(path, entry) })
+	r.keys[path] = entry
+}
+
+// Get returns a copy of the key of path and restarts its idle timeout. The
+// copy stays usable when the key expires meanwhile; callers wipe it when done.
+func (r *NoteKeyring) Get(path string) (*NoteKey, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.keys[path]
+	if !ok {
+		return nil, false
+	}
+	entry.timer.Reset(r.timeout)
+	return entry.key.clone(), true
+}
+
+// MoveTree re-registers the keys of oldPath and of every note below it under
+// newPath after a rename.
+func (r *NoteKeyring) MoveTree(oldPath string, newPath string) {
+	moved := map[string]*NoteKey{}
+	r.mu.Lock()
+	for notePath, entry := range r.keys {
+		if notePath == oldPath || isWithinRoot(oldPath, notePath) {
+			entry.timer.Stop()
+			delete(r.keys, notePath)
+			rel, _ := filepath.Rel(oldPath, notePath)
+			moved[filepath.Join(newPath, rel)] = entry.key
+		}
+	}
+	r.mu.Unlock()
+	for notePath, key := range moved {
+		r.Put(notePath, key)
+	}
+}
+
+// Lock forgets the key of path.
+func (r *NoteKeyring) Lock(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.keys[path]; ok {
+		entry.timer.Stop()
+		entry.key.Wipe()
+		delete(r.keys, path)
+	}
+}
+
+// LockTree forgets the keys of path and of every note below it.
+func (r *NoteKeyring) LockTree(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for notePath, entry := range r.keys {
+		if notePath == path || isWithinRoot(path, notePath) {
+			entry.timer.Stop()
+			entry.key.Wipe()
+			delete(r.keys, notePath)
+		}
+	}
+}
+
+// LockAll forgets every key.
+func (r *NoteKeyring) LockAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for path, entry := range r.keys {
+		entry.timer.Stop()
+		entry.key.Wipe()
+		delete(r.keys, path)
+	}
+}
+
+func (r *NoteKeyring) expire(path string, entry *keyringEntry) {
+	r.mu.Lock()
+	current, ok := r.keys[path]
+	if !ok || current != entry {
+		r.mu.Unlock()
+		return
+	}
+	entry.key.Wipe()
+	delete(r.keys, path)
+	onLock := r.onLock
+	r.mu.Unlock()
+
+	if onLock != nil {
+		onLock(path)
+	}
+}
+
+// ReadEncrypted loads and decrypts an encrypted note.
+func (s *FileService) ReadEncrypted(path string, key *NoteKey) (string, error) {
+	data, err := s.ReadBytes(path)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := key.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// WriteEncrypted encrypts content and replaces path atomically, so neither
+// the file nor its temporary copy ever holds plaintext.
+func (s *FileService) WriteEncrypted(path string, content string, key *NoteKey) error {
+	data, err := key.Encrypt([]byte(content))
+	if err != nil {
+		return err
+	}
+	if v := s.Vault(path); v != nil {
+		return v.WriteFile(path, data)
+	}
+	if err := checkNotInLockedVault(path); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
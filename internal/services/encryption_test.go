@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenNote(t *testing.T) {
+	key, err := NewNoteKey("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := func(i int) []byte {
+		data := append([]byte(nil), sealed...)
+		data[i] ^= 1
+		return data
+	}
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		wantErr    bool
+		// wrong reports whether the error is ErrWrongPassphrase
+		wrong bool
+	}{
+		{name: "right passphrase", data: sealed, passphrase: "passphrase"},
+		{name: "wrong passphrase", data: sealed, passphrase: "other", wantErr: true, wrong: true},
+		{name: "changed salt", data: tampered(noteHeaderSize - 1), passphrase: "passphrase", wantErr: true, wrong: true},
+		{name: "changed ciphertext", data: tampered(len(sealed) - 1), passphrase: "passphrase", wantErr: true, wrong: true},
+		{name: "plain Markdown", data: []byte("# Not encrypted, but long enough to pass the size check....."), passphrase: "passphrase", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, plaintext, err := OpenNote(tt.data, tt.passphrase)
+			if !tt.wantErr {
+				if err != nil || string(plaintext) != "secret" {
+					t.Fatalf("OpenNote = %q, %v", plaintext, err)
+				}
+				opened.Wipe()
+				return
+			}
+			if err == nil {
+				t.Fatal("OpenNote succeeded")
+			}
+			if errors.Is(err, ErrWrongPassphrase) != tt.wrong {
+				t.Errorf("err = %v, ErrWrongPassphrase %v", err, tt.wrong)
+			}
+		})
+	}
+
+	key.Wipe()
+	if _, err := key.Encrypt([]byte("x")); !errors.Is(err, ErrNoteLocked) {
+		t.Errorf("Encrypt after Wipe: err = %v", err)
+	}
+}
+
+func TestEncryptedNoteFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diary"+EncryptedNoteExt)
+	if !IsEncryptedNote(path) || IsEncryptedNote("diary.md") {
+		t.Fatal("IsEncryptedNote misclassifies notes")
+	}
+	key, err := NewNoteKey("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Wipe()
+	files := NewFileService()
+	if err := files.WriteEncrypted(path, "dear diary", key); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTree(t, filepath.Dir(path)); got["diary"+EncryptedNoteExt] == "dear diary" {
+		t.Fatal("plaintext written to disk")
+	}
+	content, err := files.ReadEncrypted(path, key)
+	if err != nil || content != "dear diary" {
+		t.Errorf("ReadEncrypted = %q, %v", content, err)
+	}
+}
+
+func TestNoteKeyringGetReturnsCopy(t *testing.T) {
+	key, err := NewNoteKey("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := NewNoteKeyring(time.Hour, nil)
+	ring.Put("a", key)
+
+	copied, ok := ring.Get("a")
+	if !ok {
+		t.Fatal("key missing")
+	}
+	// 使用期间密钥被锁定（例如超时）不应影响已取出的副本
+	ring.Lock("a")
+	if plaintext, err := copied.Decrypt(sealed); err != nil || string(plaintext) != "secret" {
+		t.Errorf("Decrypt after Lock = %q, %v", plaintext, err)
+	}
+	copied.Wipe()
+	if _, ok := ring.Get("a"); ok {
+		t.Error("locked key still returned")
+	}
+}
+
+func TestNoteKeyringTrees(t *testing.T) {
+	ring := NewNoteKeyring(time.Hour, nil)
+	for _, path := range []string{"dir/a", "dir/sub/b", "dirty/c", "d"} {
+		key, err := NewNoteKey("passphrase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ring.Put(filepath.FromSlash(path), key)
+	}
+	held := func(paths ...string) {
+		t.Helper()
+		for _, path := range paths {
+			key, ok := ring.Get(filepath.FromSlash(path))
+			if !ok {
+				t.Errorf("key of %s missing", path)
+				continue
+			}
+			key.Wipe()
+		}
+	}
+	gone := func(paths ...string) {
+		t.Helper()
+		for _, path := range paths {
+			if _, ok := ring.Get(filepath.FromSlash(path)); ok {
+				t.Errorf("key of %s still held", path)
+			}
+		}
+	}
+
+	ring.MoveTree("dir", "folder")
+	held("folder/a", "folder/sub/b", "dirty/c", "d")
+	gone("dir/a", "dir/sub/b")
+
+	ring.LockTree("folder")
+	held("dirty/c", "d")
+	gone("folder/a", "folder/sub/b")
+}
+
+func TestNoteKeyringConcurrentExpiry(t *testing.T) {
+	key, err := NewNoteKey("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := NewNoteKeyring(time.Hour, nil)
+	ring.Put("a", key)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				copied, ok := ring.Get("a")
+				if !ok {
+					return
+				}
+				if _, err := copied.Decrypt(sealed); err != nil {
+					t.Errorf("Decrypt: %v", err)
+				}
+				copied.Wipe()
+			}
+		}()
+	}
+	ring.LockAll()
+	wg.Wait()
+}
+
+func TestNoteKeyringExpiry(t *testing.T) {
+	key, err := NewNoteKey("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := make(chan string, 1)
+	ring := NewNoteKeyring(10*time.Millisecond, func(path string) { expired <- path })
+	ring.Put("a", key)
+	select {
+	case path := <-expired:
+		if path != "a" {
+			t.Errorf("expired %q", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("key did not expire")
+	}
+	if _, ok := ring.Get("a"); ok {
+		t.Error("expired key still returned")
+	}
+}
@@ -1,34 +1,118 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
-// FileService encapsulates basic file I/O helpers.
-type FileService struct{}
+// FileService encapsulates basic file I/O helpers. Paths inside an unlocked
+// vault are read and written through the vault.
+type FileService struct {
+	mu     sync.RWMutex
+	vaults map[string]*Vault
+}
 
 // NewFileService constructs a FileService instance.
 func NewFileService() *FileService {
-	return &FileService{}
+	return &FileService{vaults: map[string]*Vault{}}
+}
+
+// MountVault routes file operations below the vault root through v.
+func (s *FileService) MountVault(v *Vault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.vaults[v.Root()]; ok && old != v {
+		old.Lock()
+	}
+	s.vaults[v.Root()] = v
+}
+
+// UnmountVault locks the vault at root and stops routing through it.
+func (s *FileService) UnmountVault(root string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root = filepath.Clean(root)
+	if v, ok := s.vaults[root]; ok {
+		v.Lock()
+		delete(s.vaults, root)
+	}
+}
+
+// UnmountAllVaults locks every unlocked vault.
+func (s *FileService) UnmountAllVaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for root, v := range s.vaults {
+		v.Lock()
+		delete(s.vaults, root)
+	}
+}
+
+// Vault returns the unlocked vault containing path, or nil.
+func (s *FileService) Vault(path string) *Vault {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, v := range s.vaults {
+		if v.Contains(path) {
+			return v
+		}
+	}
+	return nil
 }
 
 // Read loads file contents as UTF-8 text.
 func (s *FileService) Read(path string) (string, error) {
-	bytes, err := os.ReadFile(path)
+	bytes, err := s.ReadBytes(path)
 	if err != nil {
 		return "", err
 	}
 	return string(bytes), nil
 }
 
+// ReadBytes loads raw file contents.
+func (s *FileService) ReadBytes(path string) ([]byte, error) {
+	if v := s.Vault(path); v != nil {
+		return v.ReadFile(path)
+	}
+	return os.ReadFile(path)
+}
+
 // Write stores UTF-8 content to the given path.
 func (s *FileService) Write(path string, content string) error {
+	if v := s.Vault(path); v != nil {
+		return v.WriteFile(path, []byte(content))
+	}
+	if err := checkNotInLockedVault(path); err != nil {
+		return err
+	}
 	return os.WriteFile(path, []byte(content), fs.FileMode(0o644))
 }
 
+// writeAtomic replaces the file at path in one step, sealing it when it lies
+// in an unlocked vault.
+func (s *FileService) writeAtomic(path string, data []byte) error {
+	if v := s.Vault(path); v != nil {
+		return v.WriteFile(path, data)
+	}
+	if err := checkNotInLockedVault(path); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
 // CreateFile creates a new empty file at the given path.
 func (s *FileService) CreateFile(path string) error {
+	if v := s.Vault(path); v != nil {
+		return v.WriteFile(path, nil)
+	}
+	if err := checkNotInLockedVault(path); err != nil {
+		return err
+	}
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -36,47 +120,125 @@ func (s *FileService) CreateFile(path string) error {
 	return file.Close()
 }
 
+// CreateNew creates the file at path with content in one step. It fails with
+// fs.ErrExist instead of replacing a file that is already there.
+func (s *FileService) CreateNew(path string, content string) error {
+	if v := s.Vault(path); v != nil {
+		return v.CreateFile(path, []byte(content))
+	}
+	if err := checkNotInLockedVault(path); err != nil {
+		return err
+	}
+	return createFileExclusive(path, []byte(content))
+}
+
+// createFileExclusive writes data to a file that must not exist yet; a
+// failed write removes the partial file again.
+func createFileExclusive(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
 // CreateDirectory creates a new directory at the given path.
 func (s *FileService) CreateDirectory(path string) error {
+	if v := s.Vault(path); v != nil {
+		return v.MkdirAll(path)
+	}
+	if err := checkNotInLockedVault(path); err != nil {
+		return err
+	}
 	return os.MkdirAll(path, fs.FileMode(0o755))
 }
 
 // DeleteFile removes a file or directory at the given path.
 func (s *FileService) DeleteFile(path string) error {
+	if v := s.Vault(path); v != nil {
+		if v.Root() != filepath.Clean(path) {
+			return v.RemoveAll(path)
+		}
+		s.UnmountVault(v.Root())
+	}
 	return os.RemoveAll(path)
 }
 
+// Lstat describes the file or directory at the given path.
+func (s *FileService) Lstat(path string) (fs.FileInfo, error) {
+	if v := s.Vault(path); v != nil {
+		return v.Lstat(path)
+	}
+	return os.Lstat(path)
+}
+
+// ReadDir lists the directory at the given path.
+func (s *FileService) ReadDir(path string) ([]fs.DirEntry, error) {
+	if v := s.Vault(path); v != nil {
+		return v.ReadDir(path)
+	}
+	return os.ReadDir(path)
+}
+
 // RenameFile renames a file or directory from oldPath to newPath.
 func (s *FileService) RenameFile(oldPath, newPath string) error {
-	// 添加调试信息
-	println("重命名调试 - 原始路径:", oldPath)
-	println("重命名调试 - 新路径:", newPath)
-
-	// 检查原始文件是否存在
-	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
-		println("错误: 原始文件不存在")
+	oldVault, newVault := s.Vault(oldPath), s.Vault(newPath)
+	if oldVault != nil && oldVault.Root() == filepath.Clean(oldPath) {
+		// 移动保险库本身，需要重新解锁
+		s.UnmountVault(oldVault.Root())
+		oldVault, newVault = nil, s.Vault(newPath)
+	}
+	if oldVault != newVault {
+		return errors.New("cannot move items into or out of a vault")
+	}
+	if oldVault != nil {
+		return oldVault.Rename(oldPath, newPath)
+	}
+	if err := checkNotInLockedVault(newPath); err != nil {
 		return err
 	}
 
-	// 检查目标路径是否已存在
+	if _, err := os.Stat(oldPath); err != nil {
+		return err
+	}
 	if _, err := os.Stat(newPath); err == nil {
-		println("错误: 目标路径已存在")
 		return os.ErrExist
 	}
+	return os.Rename(oldPath, newPath)
+}
 
-	// 使用更安全的重命名方法
-	err := os.Rename(oldPath, newPath)
-	if err != nil {
-		println("重命名失败:", err.Error())
-		return err
+// checkNotInLockedVault refuses to write below a locked vault, where the
+// file would be stored in plaintext next to the ciphertext.
+func checkNotInLockedVault(path string) error {
+	dir := filepath.Dir(filepath.Clean(path))
+	for {
+		if IsVault(dir) {
+			return fmt.Errorf("%s: %w", dir, ErrVaultLocked)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
 	}
+}
 
-	// 验证重命名是否成功
-	if _, err := os.Stat(newPath); os.IsNotExist(err) {
-		println("错误: 重命名后文件不存在")
-		return err
+// IsMarkdownFile reports whether the file name carries a Markdown extension.
+func IsMarkdownFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".md", ".markdown", ".mdown", ".mkd", ".mdx":
+		return true
+	default:
+		return false
 	}
-
-	println("重命名成功")
-	return nil
 }
@@ -0,0 +1,645 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// FormatOptions selects the canonical style written by FormatDocument. They
+// come from the format section of LintConfigName:
+//
+//	format:
+//	  bullet: "-"
+//	  prose_wrap: always
+//	  width: 80
+//
+// Unset values follow the list-marker and line-length lint rules, then the
+// defaults of DefaultFormatOptions.
+type FormatOptions struct {
+	// Bullet is the unordered list marker: "-", "*" or "+".
+	Bullet string `yaml:"bullet"`
+	// OrderedList numbers items one after another ("increment") or repeats
+	// the first number ("one").
+	OrderedList string `yaml:"ordered_list"`
+	// Emphasis is "*" or "_"; Strong is "**" or "__".
+	Emphasis string `yaml:"emphasis"`
+	Strong   string `yaml:"strong"`
+	// Headings is "atx" (# Title) or "setext" (underlined, levels 1 and 2).
+	Headings string `yaml:"headings"`
+	// ProseWrap rewraps paragraphs at Width ("always"), joins each into a
+	// single line ("never") or keeps their line breaks ("preserve").
+	ProseWrap string `yaml:"prose_wrap"`
+	Width     int    `yaml:"width"`
+	// KeepTables leaves tables as written instead of aligning their columns.
+	KeepTables bool `yaml:"keep_tables"`
+}
+
+// DefaultFormatOptions returns the style used when nothing is configured.
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		Bullet:      "-",
+		OrderedList: "increment",
+		Emphasis:    "*",
+		Strong:      "**",
+		Headings:    "atx",
+		ProseWrap:   "preserve",
+		Width:       80,
+	}
+}
+
+// normalize fills unset options with defaults and rejects unknown values.
+func (o FormatOptions) normalize() (FormatOptions, error) {
+	defaults := DefaultFormatOptions()
+	fields := []struct {
+		name    string
+		value   *string
+		allowed []string
+	}{
+		{"bullet", &o.Bullet, []string{"-", "*", "+"}},
+		{"ordered_list", &o.OrderedList, []string{"increment", "one"}},
+		{"emphasis", &o.Emphasis, []string{"*", "_"}},
+		{"strong", &o.Strong, []string{"**", "__"}},
+		{"headings", &o.Headings, []string{"atx", "setext"}},
+		{"prose_wrap", &o.ProseWrap, []string{"preserve", "always", "never"}},
+	}
+	fallback := []string{defaults.Bullet, defaults.OrderedList, defaults.Emphasis, defaults.Strong, defaults.Headings, defaults.ProseWrap}
+	for i, field := range fields {
+		*field.value = strings.ToLower(strings.TrimSpace(*field.value))
+		if *field.value == "" {
+			*field.value = fallback[i]
+			continue
+		}
+		valid := false
+		for _, allowed := range field.allowed {
+			valid = valid || *field.value == allowed
+		}
+		if !valid {
+			return o, fmt.Errorf("format %s: unknown value %q", field.name, *field.value)
+		}
+	}
+	if o.Width <= 0 {
+		o.Width = defaults.Width
+	}
+	return o, nil
+}
+
+// FormatDocument rewrites a Markdown document in the canonical style:
+// aligned tables, uniform list markers and numbering, uniform emphasis
+// markers and heading style, and paragraphs wrapped per ProseWrap. Front
+// matter and code blocks are never changed.
+func FormatDocument(content string, options FormatOptions) string {
+	content, restore := normalizeLineEndings(content)
+	content = formatMarkdown(content, options)
+	if trimmed := strings.TrimRight(content, "\n"); trimmed != "" {
+		content = trimmed + "\n"
+	}
+	return restore(content)
+}
+
+// FormatRange formats only the blocks touching lines startLine to endLine
+// (1-based, inclusive) and returns the whole document.
+func FormatRange(content string, startLine int, endLine int, options FormatOptions) string {
+	content, restore := normalizeLineEndings(content)
+	doc := parseDocument(content)
+	lines := doc.lines
+	if startLine < doc.bodyLine {
+		startLine = doc.bodyLine
+	}
+	if endLine > len(lines) {
+		endLine = len(lines)
+	}
+	if startLine > endLine {
+		return restore(content)
+	}
+
+	// 扩展到空行边界，保证列表、表格和段落完整
+	for startLine > doc.bodyLine && (strings.TrimSpace(lines[startLine-2]) != "" || doc.codeLines[startLine-1]) {
+		startLine--
+	}
+	for endLine < len(lines) && (strings.TrimSpace(lines[endLine]) != "" || doc.codeLines[endLine+1]) {
+		endLine++
+	}
+
+	// 前置空行使以 --- 开头的片段不被当作 front matter
+	snippet := "\n" + strings.Join(lines[startLine-1:endLine], "\n")
+	formatted := strings.TrimPrefix(formatMarkdown(snippet, options), "\n")
+
+	result := append([]string{}, lines[:startLine-1]...)
+	result = append(result, formatted)
+	result = append(result, lines[endLine:]...)
+	out := strings.Join(result, "\n")
+	if strings.HasSuffix(content, "\n") {
+		out += "\n"
+	}
+	return restore(out)
+}
+
+func formatMarkdown(content string, options FormatOptions) string {
+	options, err := options.normalize()
+	if err != nil {
+		options = DefaultFormatOptions()
+	}
+	// 每一轮基于上一轮的结果重新解析，避免修改范围重叠
+	for _, pass := range []func(*parsedDocument, FormatOptions) []textEdit{formatEmphasis, formatBlocks, formatParagraphs} {
+		doc := parseDocument(content)
+		content, _ = applyTextEdits(content, pass(doc, options))
+	}
+	return content
+}
+
+// normalizeLineEndings converts CRLF documents to LF for formatting and
+// returns the function converting the result back.
+func normalizeLineEndings(content string) (string, func(string) string) {
+	if !strings.Contains(content, "\r\n") {
+		return content, func(s string) string { return s }
+	}
+	return strings.ReplaceAll(content, "\r\n", "\n"), func(s string) string {
+		return strings.ReplaceAll(s, "\n", "\r\n")
+	}
+}
+
+// edit records a replacement of body offsets start to end.
+func (d *parsedDocument) edit(start int, end int, text string) textEdit {
+	return textEdit{start: d.offset + start, end: d.offset + end, text: text}
+}
+
+// lineBounds returns the body offsets of the start and end (before the line
+// break) of the line holding offset.
+func (d *parsedDocument) lineBounds(offset int) (int, int) {
+	start := bytes.LastIndexByte(d.source[:offset], '\n') + 1
+	end := bytes.IndexByte(d.source[offset:], '\n')
+	if end < 0 {
+		return start, len(d.source)
+	}
+	return start, offset + end
+}
+
+func formatEmphasis(doc *parsedDocument, options FormatOptions) []textEdit {
+	edits := []textEdit{}
+	source := doc.source
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		emphasis, ok := node.(*ast.Emphasis)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		// 定界符紧邻首尾文本节点；首尾不是文本时无法可靠定位，保持原样
+		first, firstOK := emphasis.FirstChild().(*ast.Text)
+		last, lastOK := emphasis.LastChild().(*ast.Text)
+		if !firstOK || !lastOK {
+			return ast.WalkContinue, nil
+		}
+		level := emphasis.Level
+		open, close := first.Segment.Start-level, last.Segment.Stop
+		if open < 0 || close+level > len(source) {
+			return ast.WalkContinue, nil
+		}
+		delimiter := string(source[open:first.Segment.Start])
+		if delimiter != string(source[close:close+level]) || strings.Trim(delimiter, "*") != "" && strings.Trim(delimiter, "_") != "" {
+			return ast.WalkContinue, nil
+		}
+
+		want := options.Emphasis
+		if level == 2 {
+			want = options.Strong
+		}
+		if delimiter == want {
+			return ast.WalkContinue, nil
+		}
+		// 单词内部的 _ 不构成强调
+		if want[0] == '_' && (isWordRuneBefore(source, open) || isWordRuneAt(source, close+level)) {
+			return ast.WalkContinue, nil
+		}
+		edits = append(edits, doc.edit(open, open+level, want), doc.edit(close, close+level, want))
+		return ast.WalkContinue, nil
+	})
+	return edits
+}
+
+func isWordRuneBefore(source []byte, offset int) bool {
+	r, _ := utf8.DecodeLastRune(source[:offset])
+	return offset > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isWordRuneAt(source []byte, offset int) bool {
+	r, _ := utf8.DecodeRune(source[offset:])
+	return offset < len(source) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// formatBlocks normalizes list markers and numbering, headings and tables,
+// whose edits never overlap.
+func formatBlocks(doc *parsedDocument, options FormatOptions) []textEdit {
+	edits := []textEdit{}
+	bullets := map[*ast.List]byte{}
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.List:
+			if n.IsOrdered() {
+				edits = append(edits, formatOrderedList(doc, n, options)...)
+			} else {
+				edits = append(edits, formatBulletList(doc, n, options, bullets)...)
+			}
+		case *ast.Heading:
+			if n.Parent() == doc.root {
+				edits = append(edits, formatHeading(doc, n, options)...)
+			}
+			return ast.WalkSkipChildren, nil
+		case *east.Table:
+			if n.Parent() == doc.root && !options.KeepTables {
+				edits = append(edits, formatTable(doc, n)...)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return edits
+}
+
+func formatBulletList(doc *parsedDocument, list *ast.List, options FormatOptions, bullets map[*ast.List]byte) []textEdit {
+	want := options.Bullet[0]
+	// 相邻的两个列表靠不同标记区分，统一后会合并成一个，因此交替使用
+	if previous, ok := list.PreviousSibling().(*ast.List); ok && !previous.IsOrdered() && bullets[previous] == want {
+		want = alternateBullet(want)
+	}
+	bullets[list] = want
+	if list.Marker == want {
+		return nil
+	}
+
+	edits := []textEdit{}
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		_, delim, ok := listItemMarker(doc.source, item, list.Marker)
+		if !ok {
+			// 只改部分条目会把列表拆开，整体保持原样
+			bullets[list] = list.Marker
+			return nil
+		}
+		edits = append(edits, doc.edit(delim, delim+1, string(want)))
+	}
+	return edits
+}
+
+func alternateBullet(marker byte) byte {
+	if marker == '-' {
+		return '*'
+	}
+	return '-'
+}
+
+func formatOrderedList(doc *parsedDocument, list *ast.List, options FormatOptions) []textEdit {
+	edits := []textEdit{}
+	number := list.Start
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		begin, delim, ok := listItemMarker(doc.source, item, list.Marker)
+		if ok {
+			old := string(doc.source[begin:delim])
+			want := strconv.Itoa(number)
+			// 序号位数变化会改变后续行的缩进要求，多行条目保持原样
+			if want != old && (len(want) == len(old) || doc.line(begin) == doc.line(lastOffset(item, begin))) {
+				edits = append(edits, doc.edit(begin, delim, want))
+			}
+		}
+		if options.OrderedList == "increment" {
+			number++
+		}
+	}
+	return edits
+}
+
+// lastOffset returns the last source offset of node's text, or fallback.
+func lastOffset(node ast.Node, fallback int) int {
+	last := fallback
+	_ = ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && child.Type() == ast.TypeBlock {
+			if lines := child.Lines(); lines.Len() > 0 && lines.At(lines.Len()-1).Start > last {
+				last = lines.At(lines.Len() - 1).Start
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return last
+}
+
+func formatHeading(doc *parsedDocument, heading *ast.Heading, options FormatOptions) []textEdit {
+	lines := heading.Lines()
+	if lines.Len() == 0 {
+		return nil
+	}
+	start, _ := doc.lineBounds(lines.At(0).Start)
+	_, end := doc.lineBounds(lines.At(lines.Len() - 1).Start)
+	atx := strings.HasPrefix(strings.TrimLeft(string(doc.source[start:end]), " "), "#")
+	if !atx {
+		// setext 标题包含下一行的下划线
+		if end >= len(doc.source) {
+			return nil
+		}
+		_, end = doc.lineBounds(end + 1)
+	}
+
+	parts := make([]string, 0, lines.Len())
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		parts = append(parts, strings.TrimSpace(string(segment.Value(doc.source))))
+	}
+	text := strings.Join(parts, " ")
+	if text == "" {
+		return nil
+	}
+
+	var want string
+	if options.Headings == "setext" && heading.Level <= 2 {
+		underline := "="
+		if heading.Level == 2 {
+			underline = "-"
+		}
+		width := displayWidth(text)
+		if width < 3 {
+			width = 3
+		}
+		want = text + "\n" + strings.Repeat(underline, width)
+	} else {
+		// 以 # 结尾的文本写成 ATX 会被当作结束标记
+		if !atx && strings.HasSuffix(text, "#") {
+			return nil
+		}
+		want = strings.Repeat("#", heading.Level) + " " + text
+	}
+	if want == string(doc.source[start:end]) {
+		return nil
+	}
+	return []textEdit{doc.edit(start, end, want)}
+}
+
+func formatTable(doc *parsedDocument, table *east.Table) []textEdit {
+	first, last := doc.tableSpan(table)
+	if first == 0 || last > len(doc.lines) {
+		return nil
+	}
+	columns := len(table.Alignments)
+
+	rows := [][]string{}
+	for line := first; line <= last; line++ {
+		if line == first+1 {
+			continue
+		}
+		text := doc.lines[line-1]
+		if strings.HasPrefix(strings.TrimSpace(text), ">") {
+			return nil
+		}
+		cells := splitTableRow(text)
+		if len(cells) > columns {
+			// 多余的单元格不会显示，但删除会丢失文字
+			return nil
+		}
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+		rows = append(rows, cells)
+	}
+
+	widths := make([]int, columns)
+	for i := range widths {
+		widths[i] = 3
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if w := displayWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	var out strings.Builder
+	writeRow := func(cells []string) {
+		out.WriteString("|")
+		for i, cell := range cells {
+			padding := widths[i] - displayWidth(cell)
+			left := 0
+			switch table.Alignments[i] {
+			case east.AlignRight:
+				left = padding
+			case east.AlignCenter:
+				left = padding / 2
+			}
+			out.WriteString(" " + strings.Repeat(" ", left) + cell + strings.Repeat(" ", padding-left) + " |")
+		}
+	}
+	writeRow(rows[0])
+	out.WriteString("\n|")
+	for i, alignment := range table.Alignments {
+		dashes := widths[i]
+		switch alignment {
+		case east.AlignLeft:
+			out.WriteString(" :" + strings.Repeat("-", dashes-1) + " |")
+		case east.AlignRight:
+			out.WriteString(" " + strings.Repeat("-", dashes-1) + ": |")
+		case east.AlignCenter:
+			out.WriteString(" :" + strings.Repeat("-", dashes-2) + ": |")
+		default:
+			out.WriteString(" " + strings.Repeat("-", dashes) + " |")
+		}
+	}
+	for _, row := range rows[1:] {
+		out.WriteString("\n")
+		writeRow(row)
+	}
+
+	start := doc.index.starts[first-1] - doc.offset
+	end := doc.index.starts[last-1] - doc.offset + len(doc.lines[last-1])
+	if out.String() == string(doc.source[start:end]) {
+		return nil
+	}
+	return []textEdit{doc.edit(start, end, out.String())}
+}
+
+// splitTableRow splits a table line into trimmed cells at unescaped pipes;
+// GFM splits cells at pipes even inside code spans.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	cells := []string{}
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			cell.WriteByte(line[i])
+			cell.WriteByte(line[i+1])
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// 以这些内容开头的行会变成标题、列表、引用、表格、代码块或 setext 下划线
+var unsafeLineStart = regexp.MustCompile("^(?:[#>+*=|<`~-]|\\d+[.)])")
+
+func formatParagraphs(doc *parsedDocument, options FormatOptions) []textEdit {
+	if options.ProseWrap == "preserve" {
+		return nil
+	}
+	edits := []textEdit{}
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		// 紧凑列表的条目文字是 TextBlock 而不是 Paragraph
+		if !entering || (node.Kind() != ast.KindParagraph && node.Kind() != ast.KindTextBlock) {
+			return ast.WalkContinue, nil
+		}
+		if edit, ok := formatParagraph(doc, node, options); ok {
+			edits = append(edits, edit)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return edits
+}
+
+func formatParagraph(doc *parsedDocument, paragraph ast.Node, options FormatOptions) (textEdit, bool) {
+	lines := paragraph.Lines()
+	if lines.Len() == 0 {
+		return textEdit{}, false
+	}
+	start, _ := doc.lineBounds(lines.At(0).Start)
+	_, end := doc.lineBounds(lines.At(lines.Len() - 1).Start)
+	firstPrefix := string(doc.source[start:lines.At(0).Start])
+	if strings.ContainsRune(firstPrefix, '\t') {
+		return textEdit{}, false
+	}
+	// 后续行的前缀：保留引用标记，列表标记换成等宽空格
+	continuation := strings.Map(func(r rune) rune {
+		if r == '>' || r == ' ' {
+			return r
+		}
+		return ' '
+	}, firstPrefix)
+
+	// 按硬换行分段，段内的软换行按 ProseWrap 重排
+	type chunk struct {
+		text  string
+		brk   string
+		empty bool
+	}
+	chunks := []chunk{{empty: true}}
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		raw := strings.TrimRight(string(segment.Value(doc.source)), "\r\n")
+		current := &chunks[len(chunks)-1]
+		text := strings.TrimSpace(raw)
+		if current.empty {
+			current.text, current.empty = text, false
+		} else {
+			current.text = joinProse(current.text, text)
+		}
+		if i < lines.Len()-1 {
+			switch {
+			case strings.HasSuffix(raw, "\\"):
+				chunks = append(chunks, chunk{empty: true})
+			case strings.HasSuffix(raw, "  "):
+				current.brk = "  "
+				chunks = append(chunks, chunk{empty: true})
+			}
+		}
+	}
+
+	width := options.Width - displayWidth(continuation)
+	if width < 20 {
+		width = 20
+	}
+	out := []string{}
+	for _, c := range chunks {
+		if options.ProseWrap == "never" {
+			out = append(out, c.text+c.brk)
+			continue
+		}
+		wrapped := wrapProse(c.text, width)
+		wrapped[len(wrapped)-1] += c.brk
+		out = append(out, wrapped...)
+	}
+	for i := range out {
+		if i == 0 {
+			out[i] = firstPrefix + out[i]
+		} else {
+			out[i] = strings.TrimRight(continuation, " ") + " " + out[i]
+			if strings.TrimSpace(continuation) == "" {
+				out[i] = continuation + strings.TrimLeft(out[i], " ")
+			}
+		}
+	}
+
+	result := strings.Join(out, "\n")
+	if result == string(doc.source[start:end]) {
+		return textEdit{}, false
+	}
+	return doc.edit(start, end, result), true
+}
+
+// joinProse joins two lines of a paragraph, without a space between CJK
+// characters since those scripts do not separate words.
+func joinProse(left string, right string) string {
+	if left == "" || right == "" {
+		return left + right
+	}
+	last, _ := utf8.DecodeLastRuneInString(left)
+	first, _ := utf8.DecodeRuneInString(right)
+	if isWideRune(last) && isWideRune(first) {
+		return left + right
+	}
+	return left + " " + right
+}
+
+// wrapProse breaks text at spaces into lines of at most width columns. A
+// word that would start a line as Markdown syntax stays on the previous line.
+func wrapProse(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	lines := []string{words[0]}
+	lineWidth := displayWidth(words[0])
+	for _, word := range words[1:] {
+		wordWidth := displayWidth(word)
+		if lineWidth+1+wordWidth <= width || unsafeLineStart.MatchString(word) {
+			lines[len(lines)-1] += " " + word
+			lineWidth += 1 + wordWidth
+			continue
+		}
+		lines = append(lines, word)
+		lineWidth = wordWidth
+	}
+	return lines
+}
+
+// displayWidth counts columns, two for East Asian wide characters.
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case isWideRune(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+func isWideRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r) || (r >= 0x3000 && r <= 0x303f) || (r >= 0xff01 && r <= 0xff60) || (r >= 0xffe0 && r <= 0xffe6)
+}
@@ -0,0 +1,171 @@
+package services
+
+import "testing"
+
+func TestFormatDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		options FormatOptions
+		content string
+		want    string
+	}{
+		{
+			name:    "bullets and numbering",
+			content: "* a\n* b\n\n1. one\n1. two\n",
+			want:    "- a\n- b\n\n1. one\n2. two\n",
+		},
+		{
+			name:    "adjacent lists keep distinct markers",
+			content: "* a\n\n+ b\n",
+			want:    "- a\n\n* b\n",
+		},
+		{
+			name:    "emphasis style",
+			options: FormatOptions{Emphasis: "_", Strong: "__"},
+			content: "*a* and **b**\n",
+			want:    "_a_ and __b__\n",
+		},
+		{
+			name:    "setext headings",
+			options: FormatOptions{Headings: "setext"},
+			content: "# Title\n\n## Part\n\n### Deep\n",
+			want:    "Title\n=====\n\nPart\n----\n\n### Deep\n",
+		},
+		{
+			name:    "table alignment",
+			content: "| a | bb |\n|---|:-:|\n| ccc | d |\n",
+			want:    "| a   | bb  |\n| --- | :-: |\n| ccc |  d  |\n",
+		},
+		{
+			name:    "code is left alone",
+			content: "```\n* not a list\n```\n",
+			want:    "```\n* not a list\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDocument(tt.content, tt.options); got != tt.want {
+				t.Errorf("FormatDocument = %q, want %q", got, tt.want)
+			}
+			// 格式化结果再格式化不应变化
+			if got := FormatDocument(tt.want, tt.options); got != tt.want {
+				t.Errorf("not idempotent: %q", got)
+			}
+		})
+	}
+}
+
+func TestFormatDocumentLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		options FormatOptions
+		content string
+		want    string
+	}{
+		{
+			name:    "setext to atx",
+			content: "Title\n=====\n\nPart\n---\n",
+			want:    "# Title\n\n## Part\n",
+		},
+		{
+			name:    "setext underline matches the heading width",
+			options: FormatOptions{Headings: "setext"},
+			content: "# 中文标题\n\n## Part *one*\n",
+			want:    "中文标题\n========\n\nPart *one*\n----------\n",
+		},
+		{
+			name:    "table with wide characters and missing cells",
+			content: "|name|值|\n|:--|--:|\n|中文|1|\n|x|\n",
+			want:    "| name |  值 |\n| :--- | --: |\n| 中文 |   1 |\n| x    |     |\n",
+		},
+		{
+			name:    "escaped pipes stay in their cell",
+			content: "| a | b |\n|---|---|\n| x \\| y | z |\n",
+			want:    "| a      | b   |\n| ------ | --- |\n| x \\| y | z   |\n",
+		},
+		{
+			name:    "tables kept as written",
+			options: FormatOptions{KeepTables: true},
+			content: "|a|b|\n|-|-|\n|c|d|\n",
+			want:    "|a|b|\n|-|-|\n|c|d|\n",
+		},
+		{
+			name:    "prose wrapped at width",
+			options: FormatOptions{ProseWrap: "always", Width: 20},
+			content: "one two three four five six seven eight\n",
+			want:    "one two three four\nfive six seven eight\n",
+		},
+		{
+			name:    "prose joined",
+			options: FormatOptions{ProseWrap: "never"},
+			content: "one two\nthree\n\n- item\n  continued\n",
+			want:    "one two three\n\n- item continued\n",
+		},
+		{
+			name:    "front matter untouched",
+			content: "---\nlist:\n* a\n---\n\n* b\n",
+			want:    "---\nlist:\n* a\n---\n\n- b\n",
+		},
+		{
+			name:    "CRLF kept",
+			content: "* a\r\n* b\r\n",
+			want:    "- a\r\n- b\r\n",
+		},
+		{
+			name:    "invalid options fall back to defaults",
+			options: FormatOptions{Bullet: "#"},
+			content: "+ a\n",
+			want:    "- a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDocument(tt.content, tt.options); got != tt.want {
+				t.Errorf("FormatDocument = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRange(t *testing.T) {
+	content := "* a\n* b\n\n| x | yy |\n|---|---|\n| 1 | 2 |\n\n* c\n"
+	tests := []struct {
+		name       string
+		start, end int
+		want       string
+	}{
+		{
+			name:  "only the touched list",
+			start: 2, end: 2,
+			want: "- a\n- b\n\n| x | yy |\n|---|---|\n| 1 | 2 |\n\n* c\n",
+		},
+		{
+			name:  "selection grows to the whole table",
+			start: 5, end: 5,
+			want: "* a\n* b\n\n| x   | yy  |\n| --- | --- |\n| 1   | 2   |\n\n* c\n",
+		},
+		{
+			name:  "range past the end",
+			start: 8, end: 100,
+			want: "* a\n* b\n\n| x | yy |\n|---|---|\n| 1 | 2 |\n\n- c\n",
+		},
+		{
+			name:  "empty range",
+			start: 5, end: 4,
+			want: content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatRange(content, tt.start, tt.end, FormatOptions{}); got != tt.want {
+				t.Errorf("FormatRange(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+
+	// front matter 不参与格式化
+	withFrontMatter := "---\n* a\n---\n* b\n"
+	if got, want := FormatRange(withFrontMatter, 1, 4, FormatOptions{}), "---\n* a\n---\n- b\n"; got != want {
+		t.Errorf("FormatRange with front matter = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var inlineTagPattern = regexp.MustCompile(`(?:^|[\s(\[,;])#([\p{L}\p{N}_][\p{L}\p{N}_/\-]*)`)
+
+// SplitFrontMatter separates a leading YAML front matter block from the body.
+// The returned front matter excludes the `---` delimiters. When the document
+// has no front matter, the whole content is returned as the body.
+func SplitFrontMatter(content string) (frontMatter string, body string, ok bool) {
+	normalized := strings.TrimPrefix(content, "\ufeff")
+	if !strings.HasPrefix(normalized, "---\n") && !strings.HasPrefix(normalized, "---\r\n") {
+		return "", content, false
+	}
+
+	rest := normalized[strings.Index(normalized, "\n")+1:]
+	offset := 0
+	for offset <= len(rest) {
+		end := strings.Index(rest[offset:], "\n")
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		trimmed := strings.TrimRight(line, "\r")
+		if trimmed == "---" || trimmed == "..." {
+			body := ""
+			if end >= 0 {
+				body = rest[offset+end+1:]
+			}
+			return rest[:offset], body, true
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+
+	return "", content, false
+}
+
+// FrontMatterLineCount returns the number of lines occupied by the front
+// matter block including both delimiters, or 0 when there is none.
+func FrontMatterLineCount(content string) int {
+	frontMatter, _, ok := SplitFrontMatter(content)
+	if !ok {
+		return 0
+	}
+	return strings.Count(frontMatter, "\n") + 2
+}
+
+// ParseFrontMatter decodes the YAML front matter of a document into a map.
+func ParseFrontMatter(content string) (map[string]interface{}, string, error) {
+	raw, body, ok := SplitFrontMatter(content)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return map[string]interface{}{}, body, nil
+	}
+
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(raw), &fields); err != nil {
+		return map[string]interface{}{}, body, err
+	}
+	return fields, body, nil
+}
+
+// ExtractTags collects tags from the front matter `tags` field and inline
+// `#tag` occurrences outside of code. Tags are returned lowercased, without
+// the leading hash and sorted.
+func ExtractTags(frontMatter map[string]interface{}, body string) []string {
+	seen := map[string]struct{}{}
+	add := func(tag string) {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag != "" {
+			seen[tag] = struct{}{}
+		}
+	}
+
+	for _, key := range []string{"tags", "tag"} {
+		switch value := frontMatter[key].(type) {
+		case string:
+			for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+				add(part)
+			}
+		case []interface{}:
+			for _, item := range value {
+				if s, ok := item.(string); ok {
+					add(s)
+				}
+			}
+		}
+	}
+
+	inFence := false
+	fenceMarker := ""
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if marker := fenceOpening(trimmed); marker != "" {
+			if !inFence {
+				inFence, fenceMarker = true, marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) {
+				inFence = false
+				continue
+			}
+		}
+		if inFence {
+			continue
+		}
+		for _, match := range inlineTagPattern.FindAllStringSubmatch(stripInlineCode(line), -1) {
+			add(match[1])
+		}
+	}
+
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// fenceOpening reports the fence marker (``` or ~~~) a trimmed line starts with.
+func fenceOpening(trimmed string) string {
+	switch {
+	case strings.HasPrefix(trimmed, "```"):
+		return "```"
+	case strings.HasPrefix(trimmed, "~~~"):
+		return "~~~"
+	default:
+		return ""
+	}
+}
+
+// stripInlineCode blanks out `code spans` so they are ignored by scanners.
+func stripInlineCode(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+
+	var builder strings.Builder
+	inCode := false
+	for _, r := range line {
+		if r == '`' {
+			inCode = !inCode
+			builder.WriteRune(' ')
+			continue
+		}
+		if inCode {
+			builder.WriteRune(' ')
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
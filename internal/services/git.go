@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git file states reported for tree decorations.
+const (
+	GitModified   = "modified"
+	GitAdded      = "added"
+	GitDeleted    = "deleted"
+	GitRenamed    = "renamed"
+	GitUntracked  = "untracked"
+	GitIgnored    = "ignored"
+	GitConflicted = "conflicted"
+)
+
+const gitCommandTimeout = 30 * time.Second
+
+// ErrGitUnavailable is returned when the git binary cannot be found.
+var ErrGitUnavailable = errors.New("git is not installed")
+
+// GitStatus summarises a repository: the branch, its upstream distance and
+// the state of every changed, untracked or ignored path.
+type GitStatus struct {
+	Root     string `json:"root"`
+	Branch   string `json:"branch"`
+	Detached bool   `json:"detached"`
+	Upstream string `json:"upstream"`
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+	// Files maps slash-separated paths relative to Root to a Git* state.
+	// Untracked and ignored directories end with "/".
+	Files map[string]string `json:"files"`
+}
+
+// FindGitRoot returns the repository containing path, if any.
+func FindGitRoot(path string) (string, bool) {
+	dir := filepath.Clean(path)
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		// 工作树和子模块中 .git 是指向真实目录的文件
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// ReadGitStatus runs `git status` in root. Without a git binary only the
+// branch is filled in, read from .git/HEAD.
+func ReadGitStatus(root string) (*GitStatus, error) {
+	status := &GitStatus{Root: filepath.Clean(root), Files: map[string]string{}}
+
+	output, err := RunGit(root, "status", "--porcelain=v2", "--branch", "-z", "--ignored=matching")
+	if errors.Is(err, ErrGitUnavailable) {
+		status.Branch, status.Detached = readGitHead(root)
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parseGitStatus(output, status)
+	return status, nil
+}
+
+// StatusOf returns the decoration for an absolute path inside the repository.
+// Directories report the most significant state among their contents.
+func (s *GitStatus) StatusOf(path string, isDir bool) string {
+	if s == nil {
+		return ""
+	}
+	rel, err := filepath.Rel(s.Root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+
+	// 被忽略或未跟踪的目录覆盖其下所有内容
+	for i := strings.IndexByte(rel, '/'); i >= 0; i = nextSlash(rel, i) {
+		if state, ok := s.Files[rel[:i+1]]; ok {
+			return state
+		}
+	}
+
+	if !isDir {
+		return s.Files[rel]
+	}
+	if state, ok := s.Files[rel+"/"]; ok {
+		return state
+	}
+
+	prefix := rel + "/"
+	result := ""
+	for file, state := range s.Files {
+		if !strings.HasPrefix(file, prefix) || state == GitIgnored {
+			continue
+		}
+		if gitStateRank(state) > gitStateRank(result) {
+			result = state
+		}
+	}
+	// 目录内的增删改统一显示为已修改
+	if result != "" && result != GitConflicted && result != GitUntracked {
+		result = GitModified
+	}
+	return result
+}
+
+func nextSlash(rel string, i int) int {
+	next := strings.IndexByte(rel[i+1:], '/')
+	if next < 0 {
+		return -1
+	}
+	return i + 1 + next
+}
+
+func gitStateRank(state string) int {
+	switch state {
+	case GitConflicted:
+		return 4
+	case GitModified, GitAdded, GitDeleted, GitRenamed:
+		return 3
+	case GitUntracked:
+		return 2
+	case GitIgnored:
+		return 1
+	}
+	return 0
+}
+
+// parseGitStatus reads `git status --porcelain=v2 --branch -z` output.
+func parseGitStatus(output []byte, status *GitStatus) {
+	records := strings.Split(string(output), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+
+		switch record[0] {
+		case '#':
+			parseGitBranchHeader(record, status)
+		case '1':
+			if fields := strings.SplitN(record, " ", 9); len(fields) == 9 {
+				status.Files[fields[8]] = gitChangeState(fields[1], false)
+			}
+		case '2':
+			// 重命名记录后紧跟原路径
+			if fields := strings.SplitN(record, " ", 10); len(fields) == 10 {
+				status.Files[fields[9]] = gitChangeState(fields[1], true)
+			}
+			i++
+		case 'u':
+			if fields := strings.SplitN(record, " ", 11); len(fields) == 11 {
+				status.Files[fields[10]] = GitConflicted
+			}
+		case '?':
+			status.Files[strings.TrimPrefix(record, "? ")] = GitUntracked
+		case '!':
+			status.Files[strings.TrimPrefix(record, "! ")] = GitIgnored
+		}
+	}
+}
+
+func parseGitBranchHeader(record string, status *GitStatus) {
+	fields := strings.Fields(record)
+	if len(fields) < 3 {
+		return
+	}
+	switch fields[1] {
+	case "branch.head":
+		status.Branch = fields[2]
+		status.Detached = fields[2] == "(detached)"
+	case "branch.upstream":
+		status.Upstream = fields[2]
+	case "branch.ab":
+		if len(fields) == 4 {
+			status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+			status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+		}
+	}
+}
+
+func gitChangeState(xy string, renamed bool) string {
+	switch {
+	case strings.ContainsRune(xy, 'D'):
+		return GitDeleted
+	case renamed:
+		return GitRenamed
+	case strings.HasPrefix(xy, "A"):
+		return GitAdded
+	default:
+		return GitModified
+	}
+}
+
+// readGitHead reads the current branch straight from the repository files.
+func readGitHead(root string) (string, bool) {
+	gitDir := filepath.Join(root, ".git")
+	if data, err := os.ReadFile(gitDir); err == nil {
+		// .git 文件形如 "gitdir: ../.git/worktrees/x"
+		if target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:"); ok {
+			gitDir = strings.TrimSpace(target)
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(root, gitDir)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", false
+	}
+	head := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(head, "ref: refs/heads/"); ok {
+		return ref, false
+	}
+	return "(detached)", true
+}
+
+// RunGit runs git with args inside dir and returns its standard output.
+// Failures carry git's own error message.
+func RunGit(dir string, args ...string) ([]byte, error) {
+	return RunGitInput(dir, nil, args...)
+}
+
+// RunGitInput is RunGit with data fed to git's standard input.
+func RunGitInput(dir string, input []byte, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrGitUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	// 禁止 git 弹出凭据或编辑器提示，避免在后台阻塞
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_OPTIONAL_LOCKS=0", "LC_ALL=C")
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return stdout.Bytes(), fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.Bytes(), nil
+}
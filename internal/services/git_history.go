@@ -0,0 +1,380 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitCommit is one entry of a file's history.
+type GitCommit struct {
+	Hash      string `json:"hash"`
+	ShortHash string `json:"shortHash"`
+	Author    string `json:"author"`
+	Email     string `json:"email"`
+	Date      string `json:"date"`
+	Subject   string `json:"subject"`
+}
+
+// GitDiffLine is a line of a diff hunk. Kind is "context", "add" or "delete";
+// line numbers are 1-based and zero on the side the line does not exist.
+type GitDiffLine struct {
+	Kind    string `json:"kind"`
+	OldLine int    `json:"oldLine"`
+	NewLine int    `json:"newLine"`
+	Text    string `json:"text"`
+}
+
+// GitDiffHunk is a contiguous block of changes.
+type GitDiffHunk struct {
+	Header string        `json:"header"`
+	Lines  []GitDiffLine `json:"lines"`
+}
+
+// GitDiffRow pairs the two sides of a side-by-side diff; a nil side is blank.
+type GitDiffRow struct {
+	Left  *GitDiffLine `json:"left"`
+	Right *GitDiffLine `json:"right"`
+}
+
+// GitDiff compares a working tree file with HEAD, both as the raw unified
+// diff and split into hunks and side-by-side rows.
+type GitDiff struct {
+	Path       string        `json:"path"`
+	Unified    string        `json:"unified"`
+	Hunks      []GitDiffHunk `json:"hunks"`
+	SideBySide []GitDiffRow  `json:"sideBySide"`
+}
+
+// GitSyncResult reports what GitQuickSync did.
+type GitSyncResult struct {
+	Committed bool   `json:"committed"`
+	Hash      string `json:"hash"`
+	Pulled    bool   `json:"pulled"`
+	Pushed    bool   `json:"pushed"`
+	Message   string `json:"message"`
+}
+
+var (
+	hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+	// 修订号只允许常见的 ref 字符，防止被当作 git 选项解析
+	revisionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._/~^@{}-]*$`)
+)
+
+// GitRelPath converts path to a slash-separated path inside the repository.
+func GitRelPath(root string, path string) (string, error) {
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the repository %s", path, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// GitStage adds paths to the index.
+func GitStage(root string, paths ...string) error {
+	return runGitPaths(root, []string{"add", "-A"}, paths)
+}
+
+// GitUnstage removes paths from the index, keeping the working tree.
+func GitUnstage(root string, paths ...string) error {
+	return runGitPaths(root, []string{"reset", "-q"}, paths)
+}
+
+func runGitPaths(root string, args []string, paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("no paths given")
+	}
+	args = append(args, "--")
+	for _, path := range paths {
+		rel, err := GitRelPath(root, path)
+		if err != nil {
+			return err
+		}
+		args = append(args, rel)
+	}
+	_, err := RunGit(root, args...)
+	return err
+}
+
+// GitCommitStaged commits the index and returns the new commit hash.
+func GitCommitStaged(root string, message string) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", errors.New("commit message is required")
+	}
+	// 通过标准输入传递提交信息，保留多行内容
+	if _, err := RunGitInput(root, []byte(message), "commit", "--quiet", "-F", "-"); err != nil {
+		return "", err
+	}
+	output, err := RunGit(root, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GitDiffFile compares path in the working tree with HEAD. Files that are not
+// in HEAD yet show as entirely added.
+func GitDiffFile(root string, path string) (*GitDiff, error) {
+	rel, err := GitRelPath(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := RunGit(root, "diff", "--no-color", "--no-ext-diff", "HEAD", "--", rel)
+	if err != nil || !gitPathInHead(root, rel) {
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			if err != nil {
+				return nil, err
+			}
+			return nil, readErr
+		}
+		output = []byte(addedFileDiff(rel, string(data)))
+	}
+
+	diff := &GitDiff{Path: path, Unified: string(output), Hunks: parseUnifiedDiff(string(output))}
+	diff.SideBySide = sideBySideRows(diff.Hunks)
+	return diff, nil
+}
+
+func gitPathInHead(root string, rel string) bool {
+	_, err := RunGit(root, "cat-file", "-e", "HEAD:"+rel)
+	return err == nil
+}
+
+func addedFileDiff(rel string, content string) string {
+	lines := splitDiffLines(content)
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", rel, len(lines))
+	for _, line := range lines {
+		builder.WriteString("+" + line + "\n")
+	}
+	return builder.String()
+}
+
+func splitDiffLines(content string) []string {
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
+
+// parseUnifiedDiff splits `git diff` output into hunks with line numbers.
+func parseUnifiedDiff(output string) []GitDiffHunk {
+	hunks := []GitDiffHunk{}
+	var current *GitDiffHunk
+	oldLine, newLine := 0, 0
+
+	for _, line := range strings.Split(output, "\n") {
+		if match := hunkHeaderPattern.FindStringSubmatch(line); match != nil {
+			hunks = append(hunks, GitDiffHunk{Header: line})
+			current = &hunks[len(hunks)-1]
+			oldLine, _ = strconv.Atoi(match[1])
+			newLine, _ = strconv.Atoi(match[2])
+			continue
+		}
+		if current == nil || line == "" {
+			continue
+		}
+
+		text := strings.TrimSuffix(line[1:], "\r")
+		switch line[0] {
+		case ' ':
+			current.Lines = append(current.Lines, GitDiffLine{Kind: "context", OldLine: oldLine, NewLine: newLine, Text: text})
+			oldLine++
+			newLine++
+		case '-':
+			current.Lines = append(current.Lines, GitDiffLine{Kind: "delete", OldLine: oldLine, Text: text})
+			oldLine++
+		case '+':
+			current.Lines = append(current.Lines, GitDiffLine{Kind: "add", NewLine: newLine, Text: text})
+			newLine++
+		}
+	}
+	return hunks
+}
+
+// sideBySideRows pairs each run of deletions with the additions following it.
+func sideBySideRows(hunks []GitDiffHunk) []GitDiffRow {
+	rows := []GitDiffRow{}
+	for _, hunk := range hunks {
+		lines := hunk.Lines
+		for i := 0; i < len(lines); {
+			if lines[i].Kind == "context" {
+				line := lines[i]
+				rows = append(rows, GitDiffRow{Left: &line, Right: &line})
+				i++
+				continue
+			}
+
+			var deleted, added []GitDiffLine
+			for i < len(lines) && lines[i].Kind == "delete" {
+				deleted = append(deleted, lines[i])
+				i++
+			}
+			for i < len(lines) && lines[i].Kind == "add" {
+				added = append(added, lines[i])
+				i++
+			}
+			for j := 0; j < len(deleted) || j < len(added); j++ {
+				var row GitDiffRow
+				if j < len(deleted) {
+					row.Left = &deleted[j]
+				}
+				if j < len(added) {
+					row.Right = &added[j]
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// GitFileLog lists the commits touching path, newest first, following renames.
+func GitFileLog(root string, path string, limit int) ([]GitCommit, error) {
+	rel, err := GitRelPath(root, path)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	output, err := RunGit(root, "log", "--follow", "-n", strconv.Itoa(limit),
+		"--format=%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e", "--", rel)
+	if err != nil {
+		// 尚无提交的仓库没有历史
+		if !gitHasCommits(root) {
+			return []GitCommit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := []GitCommit{}
+	for _, record := range strings.Split(string(output), "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		commits = append(commits, GitCommit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Email:     fields[3],
+			Date:      fields[4],
+			Subject:   fields[5],
+		})
+	}
+	return commits, nil
+}
+
+func gitHasCommits(root string) bool {
+	_, err := RunGit(root, "rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+// GitShowFile returns path as it was at revision, looking the file up under
+// its earlier name when it has been renamed since.
+func GitShowFile(root string, revision string, path string) (string, error) {
+	if !revisionPattern.MatchString(revision) {
+		return "", fmt.Errorf("invalid revision %q", revision)
+	}
+	rel, err := GitRelPath(root, path)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := RunGit(root, "show", revision+":"+rel)
+	if err == nil {
+		return string(output), nil
+	}
+
+	// 文件后来被重命名：在该提交中查找改名前的路径
+	names, nameErr := RunGit(root, "log", "--follow", "--name-only", "--format=%H", "--", rel)
+	if nameErr != nil {
+		return "", err
+	}
+	if previous := gitPathAtRevision(string(names), revision); previous != "" && previous != rel {
+		if output, showErr := RunGit(root, "show", revision+":"+previous); showErr == nil {
+			return string(output), nil
+		}
+	}
+	return "", err
+}
+
+// gitPathAtRevision reads `git log --follow --name-only --format=%H` output.
+func gitPathAtRevision(output string, revision string) string {
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		hash := strings.TrimSpace(lines[i])
+		if hash == "" || !strings.HasPrefix(hash, revision) {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if name := strings.TrimSpace(lines[j]); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// GitQuickSync stages everything, commits when there are changes, then pulls
+// with rebase and pushes when the branch has an upstream (or an "origin"
+// remote to publish to).
+func GitQuickSync(root string, message string) (GitSyncResult, error) {
+	var result GitSyncResult
+
+	if _, err := RunGit(root, "add", "-A"); err != nil {
+		return result, err
+	}
+	if _, err := RunGit(root, "diff", "--cached", "--quiet"); err != nil {
+		if strings.TrimSpace(message) == "" {
+			message = "Update notes " + time.Now().Format("2006-01-02 15:04")
+		}
+		hash, err := GitCommitStaged(root, message)
+		if err != nil {
+			return result, err
+		}
+		result.Committed, result.Hash = true, hash
+	}
+
+	status, err := ReadGitStatus(root)
+	if err != nil {
+		return result, err
+	}
+	if status.Detached {
+		return result, errors.New("cannot sync a detached HEAD")
+	}
+
+	if status.Upstream == "" {
+		if _, err := RunGit(root, "remote", "get-url", "origin"); err != nil {
+			result.Message = "no remote configured; changes were committed locally"
+			return result, nil
+		}
+		if _, err := RunGit(root, "push", "--set-upstream", "origin", status.Branch); err != nil {
+			return result, err
+		}
+		result.Pushed = true
+		result.Message = "published " + status.Branch + " to origin"
+		return result, nil
+	}
+
+	if _, err := RunGit(root, "pull", "--rebase", "--autostash"); err != nil {
+		return result, err
+	}
+	result.Pulled = true
+	if _, err := RunGit(root, "push"); err != nil {
+		return result, err
+	}
+	result.Pushed = true
+	result.Message = "synced with " + status.Upstream
+	return result, nil
+}
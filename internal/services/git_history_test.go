@@ -0,0 +1,176 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitTest runs git in dir and fails the test on error.
+func gitTest(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := RunGit(dir, args...)
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// initGitRepo creates a repository with a local identity, so commits work
+// without any global git configuration.
+func initGitRepo(t *testing.T, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitTest(t, dir, "init", "--quiet", "--initial-branch=main")
+	configureGitIdentity(t, dir)
+}
+
+func configureGitIdentity(t *testing.T, dir string) {
+	t.Helper()
+	gitTest(t, dir, "config", "user.name", "Test")
+	gitTest(t, dir, "config", "user.email", "test@example.com")
+	gitTest(t, dir, "config", "commit.gpgsign", "false")
+}
+
+func TestGitStageCommitAndHistory(t *testing.T) {
+	root := t.TempDir()
+	initGitRepo(t, root)
+	note := filepath.Join(root, "notes", "a.md")
+	writeTree(t, root, map[string]string{"notes/a.md": "one\ntwo\n"})
+
+	// 尚未提交的文件整篇显示为新增
+	diff, err := GitDiffFile(root, note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Hunks) != 1 || len(diff.Hunks[0].Lines) != 2 || diff.Hunks[0].Lines[0].Kind != "add" {
+		t.Errorf("diff of a new file = %+v", diff.Hunks)
+	}
+	if commits, err := GitFileLog(root, note, 0); err != nil || len(commits) != 0 {
+		t.Errorf("log before the first commit = %v, %v", commits, err)
+	}
+
+	if err := GitStage(root, note); err != nil {
+		t.Fatal(err)
+	}
+	if err := GitUnstage(root, note); err != nil {
+		t.Fatal(err)
+	}
+	if staged := gitTest(t, root, "diff", "--cached", "--name-only"); staged != "" {
+		t.Errorf("staged after unstage: %q", staged)
+	}
+	if err := GitStage(root, note); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GitCommitStaged(root, "  "); err == nil {
+		t.Error("committing without a message succeeded")
+	}
+	first, err := GitCommitStaged(root, "Add a\n\nwith a body")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTree(t, root, map[string]string{"notes/a.md": "one\n2\n"})
+	diff, err = GitDiffFile(root, note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.SideBySide) != 2 || diff.SideBySide[1].Left.Text != "two" || diff.SideBySide[1].Right.Text != "2" {
+		t.Errorf("side by side = %+v", diff.SideBySide)
+	}
+
+	// 改名后仍能按旧提交查看内容
+	renamed := filepath.Join(root, "notes", "b.md")
+	gitTest(t, root, "mv", "notes/a.md", "notes/b.md")
+	if err := GitStage(root, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GitCommitStaged(root, "Rename a to b"); err != nil {
+		t.Fatal(err)
+	}
+
+	commits, err := GitFileLog(root, renamed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[1].Hash != first || commits[1].Subject != "Add a" {
+		t.Fatalf("log = %+v", commits)
+	}
+	if content, err := GitShowFile(root, commits[1].ShortHash, renamed); err != nil || content != "one\ntwo\n" {
+		t.Errorf("show at first commit = %q, %v", content, err)
+	}
+	if _, err := GitShowFile(root, "--output=/tmp/x", renamed); err == nil {
+		t.Error("an option was accepted as a revision")
+	}
+	if _, err := GitRelPath(root, filepath.Join(filepath.Dir(root), "elsewhere.md")); err == nil {
+		t.Error("a path outside the repository was accepted")
+	}
+}
+
+func TestGitQuickSyncWithBareRemote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	base := t.TempDir()
+	remote := filepath.Join(base, "remote.git")
+	gitTest(t, base, "init", "--quiet", "--bare", "--initial-branch=main", remote)
+
+	alice := filepath.Join(base, "alice")
+	if err := os.Mkdir(alice, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	initGitRepo(t, alice)
+
+	// 没有远程时只在本地提交
+	writeTree(t, alice, map[string]string{"a.md": "from alice\n"})
+	result, err := GitQuickSync(alice, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Committed || result.Pushed || !strings.HasPrefix(result.Message, "no remote") {
+		t.Errorf("sync without remote = %+v", result)
+	}
+
+	// 第一次同步把分支发布到 origin 并设置上游
+	gitTest(t, alice, "remote", "add", "origin", remote)
+	result, err = GitQuickSync(alice, "nothing new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Committed || !result.Pushed || result.Pulled {
+		t.Errorf("publishing sync = %+v", result)
+	}
+
+	bob := filepath.Join(base, "bob")
+	gitTest(t, base, "clone", "--quiet", remote, bob)
+	configureGitIdentity(t, bob)
+	writeTree(t, bob, map[string]string{"b.md": "from bob\n"})
+	if result, err = GitQuickSync(bob, "Add b"); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Committed || !result.Pulled || !result.Pushed {
+		t.Errorf("sync from the clone = %+v", result)
+	}
+
+	// 双方都有新提交：先变基拉取再推送
+	writeTree(t, alice, map[string]string{"a.md": "edited by alice\n"})
+	if result, err = GitQuickSync(alice, "Edit a"); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Committed || !result.Pulled || !result.Pushed {
+		t.Errorf("sync with diverged history = %+v", result)
+	}
+	if data, err := os.ReadFile(filepath.Join(alice, "b.md")); err != nil || string(data) != "from bob\n" {
+		t.Errorf("b.md in alice = %q, %v", data, err)
+	}
+	if subject := gitTest(t, remote, "log", "-1", "--format=%s", "main"); subject != "Edit a" {
+		t.Errorf("remote head = %q, want %q", subject, "Edit a")
+	}
+	if count := gitTest(t, remote, "rev-list", "--count", "main"); count != "3" {
+		t.Errorf("remote has %s commits, want 3", count)
+	}
+}
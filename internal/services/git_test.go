@@ -0,0 +1,166 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	records := []string{
+		"# branch.oid 1234567890abcdef",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"1 .M N... 100644 100644 100644 aaaa bbbb notes/a.md",
+		"1 A. N... 000000 100644 100644 0000 cccc notes/new note.md",
+		"1 D. N... 100644 000000 000000 dddd 0000 gone.md",
+		"2 R. N... 100644 100644 100644 eeee eeee R100 notes/renamed 中文.md",
+		"notes/old 中文.md",
+		"2 RD N... 100644 100644 000000 ffff ffff R100 moved.md",
+		"old-moved.md",
+		"u UU N... 100644 100644 100644 100644 1111 2222 3333 conflict.md",
+		"? drafts/",
+		"? notes/untracked.md",
+		"! build/",
+		"! notes/.cache",
+	}
+	status := &GitStatus{Files: map[string]string{}}
+	parseGitStatus([]byte(strings.Join(records, "\x00")+"\x00"), status)
+
+	if status.Branch != "main" || status.Detached || status.Upstream != "origin/main" || status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("branch = %+v", status)
+	}
+	want := map[string]string{
+		"notes/a.md":          GitModified,
+		"notes/new note.md":   GitAdded,
+		"gone.md":             GitDeleted,
+		"notes/renamed 中文.md": GitRenamed,
+		"moved.md":            GitDeleted,
+		"conflict.md":         GitConflicted,
+		"drafts/":             GitUntracked,
+		"notes/untracked.md":  GitUntracked,
+		"build/":              GitIgnored,
+		"notes/.cache":        GitIgnored,
+	}
+	if !reflect.DeepEqual(status.Files, want) {
+		t.Errorf("files = %v, want %v", status.Files, want)
+	}
+
+	detached := &GitStatus{Files: map[string]string{}}
+	parseGitStatus([]byte("# branch.oid 1234\x00# branch.head (detached)\x00"), detached)
+	if detached.Branch != "(detached)" || !detached.Detached || detached.Upstream != "" || detached.Ahead != 0 {
+		t.Errorf("detached = %+v", detached)
+	}
+}
+
+func TestGitStatusOf(t *testing.T) {
+	root := t.TempDir()
+	status := &GitStatus{Root: root, Files: map[string]string{
+		"notes/a.md":         GitAdded,
+		"notes/untracked.md": GitUntracked,
+		"notes/.cache":       GitIgnored,
+		"drafts/":            GitUntracked,
+		"build/":             GitIgnored,
+		"other/conflict.md":  GitConflicted,
+		"other/b.md":         GitModified,
+		"quiet/.cache":       GitIgnored,
+	}}
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  string
+	}{
+		{rel: "notes/a.md", want: GitAdded},
+		{rel: "notes/clean.md"},
+		{rel: "notes", isDir: true, want: GitModified},
+		{rel: "other", isDir: true, want: GitConflicted},
+		{rel: "drafts", isDir: true, want: GitUntracked},
+		{rel: "drafts/deep/x.md", want: GitUntracked},
+		{rel: "build/out/x.html", want: GitIgnored},
+		{rel: "quiet", isDir: true},
+		{rel: "clean", isDir: true},
+	}
+	for _, tt := range tests {
+		if got := status.StatusOf(filepath.Join(root, filepath.FromSlash(tt.rel)), tt.isDir); got != tt.want {
+			t.Errorf("StatusOf(%s) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+	if got := status.StatusOf(filepath.Dir(root), true); got != "" {
+		t.Errorf("StatusOf(parent) = %q", got)
+	}
+}
+
+func TestReadGitStatus(t *testing.T) {
+	origin := t.TempDir()
+	initGitRepo(t, origin)
+	writeTree(t, origin, map[string]string{"a.md": "a\n", "old name.md": "old\n"})
+	gitTest(t, origin, "add", "-A")
+	gitTest(t, origin, "commit", "--quiet", "-m", "first")
+
+	root := filepath.Join(t.TempDir(), "clone")
+	gitTest(t, origin, "clone", "--quiet", origin, root)
+	configureGitIdentity(t, root)
+
+	// 远端多一个提交，本地多两个提交
+	writeTree(t, origin, map[string]string{"a.md": "a from origin\n"})
+	gitTest(t, origin, "commit", "--quiet", "-am", "origin")
+	for _, message := range []string{"one", "two"} {
+		writeTree(t, root, map[string]string{message + ".md": message})
+		gitTest(t, root, "add", "-A")
+		gitTest(t, root, "commit", "--quiet", "-m", message)
+	}
+	gitTest(t, root, "fetch", "--quiet")
+
+	writeTree(t, root, map[string]string{
+		".gitignore":      "*.log\nbuild/\n",
+		"a.md":            "changed\n",
+		"spaced 中文.md":    "untracked\n",
+		"drafts/x.md":     "untracked\n",
+		"build/out.html":  "ignored\n",
+		"debug.log":       "ignored\n",
+		"notes/staged.md": "staged\n",
+	})
+	gitTest(t, root, "add", "notes/staged.md")
+	gitTest(t, root, "mv", "old name.md", "new name ü.md")
+
+	status, err := ReadGitStatus(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "main" || status.Upstream != "origin/main" || status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("branch = %s, upstream %s, +%d -%d", status.Branch, status.Upstream, status.Ahead, status.Behind)
+	}
+	want := map[string]string{
+		".gitignore":      GitUntracked,
+		"a.md":            GitModified,
+		"spaced 中文.md":    GitUntracked,
+		"drafts/":         GitUntracked,
+		"build/":          GitIgnored,
+		"debug.log":       GitIgnored,
+		"notes/staged.md": GitAdded,
+		"new name ü.md":   GitRenamed,
+	}
+	if !reflect.DeepEqual(status.Files, want) {
+		t.Errorf("files = %v, want %v", status.Files, want)
+	}
+}
+
+func TestReadGitHead(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"repo/.git/HEAD": "ref: refs/heads/feature/x\n",
+		"real/HEAD":      "1234567890abcdef\n",
+		"worktree/.git":  "gitdir: ../real\n",
+	})
+	if branch, detached := readGitHead(filepath.Join(root, "repo")); branch != "feature/x" || detached {
+		t.Errorf("repo head = %q, %v", branch, detached)
+	}
+	if branch, detached := readGitHead(filepath.Join(root, "worktree")); branch != "(detached)" || !detached {
+		t.Errorf("worktree head = %q, %v", branch, detached)
+	}
+	if branch, _ := readGitHead(root); branch != "" {
+		t.Errorf("head outside a repository = %q", branch)
+	}
+}
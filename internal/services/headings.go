@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var slugSeparatorPattern = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// HeadingSlug mirrors the preview renderer (editor.md): the heading text is
+// lowercased and every run of non-word characters becomes "-". Headings made
+// only of CJK characters are encoded the way JavaScript's escape() does.
+func HeadingSlug(text string) string {
+	text = strings.TrimSpace(text)
+	if isAllCJK(text) {
+		var builder strings.Builder
+		for _, r := range text {
+			fmt.Fprintf(&builder, "u%04X", r)
+		}
+		return builder.String()
+	}
+	return slugSeparatorPattern.ReplaceAllString(strings.ToLower(text), "-")
+}
+
+// HeadingAnchorID returns the element id the preview assigns to a heading.
+func HeadingAnchorID(level int, text string) string {
+	return fmt.Sprintf("h%d-%s", level, HeadingSlug(text))
+}
+
+// headingPlainText strips inline links and emphasis markers from the
+// Markdown text of a heading.
+func headingPlainText(markdown string) string {
+	source := []byte("# " + strings.TrimSpace(markdown))
+	root := newMarkdown().Parser().Parse(text.NewReader(source))
+	if heading, ok := root.FirstChild().(*ast.Heading); ok {
+		return inlinePlainText(heading, source)
+	}
+	return strings.TrimSpace(markdown)
+}
+
+// inlinePlainText returns the text of the inline nodes below node the way
+// the preview shows it: emphasis, link and HTML markup is dropped, escapes
+// and entities are resolved and code spans are kept as written, so
+// "my_var" and "`a*b`" keep their underscore and asterisk.
+func inlinePlainText(node ast.Node, source []byte) string {
+	var builder strings.Builder
+	_ = ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch child := child.(type) {
+		case *ast.Text:
+			value := child.Segment.Value(source)
+			if _, code := child.Parent().(*ast.CodeSpan); !code {
+				value = util.ResolveEntityNames(util.ResolveNumericReferences(util.UnescapePunctuations(value)))
+			}
+			builder.Write(value)
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				builder.WriteByte(' ')
+			}
+		case *ast.String:
+			builder.Write(child.Value)
+		case *ast.AutoLink:
+			builder.Write(child.Label(source))
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(builder.String())
+}
+
+func isAllCJK(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if r < 0x4e00 || r > 0x9fa5 {
+			return false
+		}
+	}
+	return true
+}
+
+// documentAnchors collects every anchor a link may target inside a document:
+// the raw heading text (used by the preview's <a name>), the preview id and a
+// GitHub-style slug for compatibility with notes written elsewhere.
+func documentAnchors(content string) map[string]struct{} {
+	anchors := map[string]struct{}{}
+	forEachHeading(content, func(_ int, level int, text string) bool {
+		for _, anchor := range headingAnchors(level, text) {
+			anchors[anchor] = struct{}{}
+		}
+		return true
+	})
+	return anchors
+}
+
+// FindHeadingLine returns the 1-based line of the first heading matching
+// heading by text, preview id or GitHub slug, or 0 when there is none.
+func FindHeadingLine(content string, heading string) int {
+	heading = strings.TrimPrefix(strings.TrimSpace(heading), "#")
+	if heading == "" {
+		return 0
+	}
+	lower := strings.ToLower(heading)
+	found := 0
+	forEachHeading(content, func(line int, level int, text string) bool {
+		for _, anchor := range headingAnchors(level, text) {
+			if anchor == heading || anchor == lower {
+				found = line
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func headingAnchors(level int, text string) []string {
+	return []string{text, strings.ToLower(text), HeadingAnchorID(level, text), githubSlug(text)}
+}
+
+// forEachHeading calls visit with the 1-based line, level and plain text of
+// every ATX heading outside code fences until visit returns false.
+func forEachHeading(content string, visit func(line int, level int, text string) bool) {
+	lines := strings.Split(content, "\n")
+	inFence := false
+	fenceMarker := ""
+
+	for i := FrontMatterLineCount(content); i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		trimmed := strings.TrimSpace(line)
+		if marker := fenceOpening(trimmed); marker != "" {
+			if !inFence {
+				inFence, fenceMarker = true, marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) {
+				inFence = false
+				continue
+			}
+		}
+		if inFence {
+			continue
+		}
+
+		match := headingLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if !visit(i+1, len(match[1]), headingPlainText(match[2])) {
+			return
+		}
+	}
+}
+
+func githubSlug(text string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-':
+			builder.WriteRune(r)
+		case r == ' ':
+			builder.WriteRune('-')
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,73 @@
+package services
+
+import "testing"
+
+func TestHeadingSlugs(t *testing.T) {
+	tests := []struct {
+		heading string
+		text    string
+		anchor  string
+		github  string
+	}{
+		{heading: "## my_var", text: "my_var", anchor: "h2-my_var", github: "my_var"},
+		{heading: "## `a*b` usage", text: "a*b usage", anchor: "h2-a-b-usage", github: "ab-usage"},
+		{heading: "# *Emphasis* and __strong__", text: "Emphasis and strong", anchor: "h1-emphasis-and-strong", github: "emphasis-and-strong"},
+		{heading: "### ~~Old~~ [New](new.md) API", text: "Old New API", anchor: "h3-old-new-api", github: "old-new-api"},
+		{heading: `## 2 \* 3 &amp; more`, text: "2 * 3 & more", anchor: "h2-2-3-more", github: "2--3--more"},
+		{heading: "## Title <small>v2</small> ##", text: "Title v2", anchor: "h2-title-v2", github: "title-v2"},
+		{heading: "## 中文标题", text: "中文标题", anchor: "h2-u4E2Du6587u6807u9898", github: "中文标题"},
+		{heading: "Setext *heading*\n---", text: "Setext heading", anchor: "h2-setext-heading", github: "setext-heading"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.heading, func(t *testing.T) {
+			outline := DocumentOutline(tt.heading + "\n")
+			if len(outline) != 1 {
+				t.Fatalf("outline = %+v", outline)
+			}
+			heading := outline[0]
+			if heading.Text != tt.text || heading.Anchor != tt.anchor {
+				t.Errorf("outline text %q anchor %q, want %q %q", heading.Text, heading.Anchor, tt.text, tt.anchor)
+			}
+			if got := githubSlug(heading.Text); got != tt.github {
+				t.Errorf("githubSlug = %q, want %q", got, tt.github)
+			}
+		})
+	}
+}
+
+func TestHeadingPlainText(t *testing.T) {
+	tests := map[string]string{
+		"my_var":               "my_var",
+		"`a*b` and `c_d`":      "a*b and c_d",
+		"**bold** _it_":        "bold it",
+		"[link](x.md) ![i](y)": "link i",
+		"snake_case_name":      "snake_case_name",
+		"  padded  ":           "padded",
+	}
+	for markdown, want := range tests {
+		if got := headingPlainText(markdown); got != want {
+			t.Errorf("headingPlainText(%q) = %q, want %q", markdown, got, want)
+		}
+	}
+}
+
+func TestFindHeadingLine(t *testing.T) {
+	const note = "# Top\n\n```\n## Fenced\n```\n\n## my_var\n\n## `a*b`\n"
+	tests := []struct {
+		heading string
+		want    int
+	}{
+		{heading: "#h2-my_var", want: 7},
+		{heading: "my_var", want: 7},
+		{heading: "h2-a-b", want: 9},
+		{heading: "ab", want: 9},
+		{heading: "top", want: 1},
+		{heading: "fenced", want: 0},
+		{heading: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := FindHeadingLine(note, tt.heading); got != tt.want {
+			t.Errorf("FindHeadingLine(%q) = %d, want %d", tt.heading, got, tt.want)
+		}
+	}
+}
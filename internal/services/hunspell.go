@@ -0,0 +1,728 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Dictionary is a Hunspell dictionary: the words of a .dic file and the affix
+// rules of its .aff file. It covers the subset of the format used by common
+// dictionaries: prefixes and suffixes with cross products and twofold
+// suffixes, flag aliases, compounding by flag, and the REP and TRY tables
+// for suggestions.
+type Dictionary struct {
+	Language string
+
+	words    map[string][]flagSet
+	flagMode string
+	flagIDs  map[string]uint16
+	aliases  []flagSet
+
+	prefixes      map[rune][]*affixRule
+	emptyPrefixes []*affixRule
+	suffixes      map[rune][]*affixRule
+	emptySuffixes []*affixRule
+
+	try       []rune
+	rep       [][2]string
+	wordChars string
+	ignore    string
+
+	// 特殊标志，0 表示词典未定义
+	forbidden      uint16
+	needAffix      uint16
+	noSuggest      uint16
+	keepCase       uint16
+	onlyInCompound uint16
+	compoundFlag   uint16
+	compoundBegin  uint16
+	compoundMiddle uint16
+	compoundEnd    uint16
+	compoundMin    int
+}
+
+// flagSet holds the interned flags of a word or an affix continuation.
+type flagSet []uint16
+
+func (s flagSet) has(flag uint16) bool {
+	if flag == 0 {
+		return false
+	}
+	for _, f := range s {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+type affixRule struct {
+	flag      uint16
+	cross     bool
+	strip     string
+	add       string
+	cont      flagSet
+	condition []conditionChar
+}
+
+// conditionChar is one position of an affix condition: any character, one
+// of chars, or none of chars.
+type conditionChar struct {
+	chars  string
+	negate bool
+	any    bool
+}
+
+// LoadDictionary reads a Hunspell dictionary from its .aff and .dic files.
+func LoadDictionary(affPath string, dicPath string) (*Dictionary, error) {
+	aff, err := os.ReadFile(affPath)
+	if err != nil {
+		return nil, err
+	}
+	dic, err := os.ReadFile(dicPath)
+	if err != nil {
+		return nil, err
+	}
+
+	decode, err := hunspellDecoder(aff)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", affPath, err)
+	}
+	affText, err := decode(aff)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", affPath, err)
+	}
+	dicText, err := decode(dic)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dicPath, err)
+	}
+
+	d := &Dictionary{
+		Language:    strings.TrimSuffix(filepath.Base(affPath), filepath.Ext(affPath)),
+		words:       map[string][]flagSet{},
+		flagIDs:     map[string]uint16{},
+		prefixes:    map[rune][]*affixRule{},
+		suffixes:    map[rune][]*affixRule{},
+		compoundMin: 3,
+	}
+	if err := d.parseAffixes(affText); err != nil {
+		return nil, fmt.Errorf("%s: %w", affPath, err)
+	}
+	d.parseWords(dicText)
+	return d, nil
+}
+
+// hunspellDecoder returns a function decoding dictionary files from the
+// encoding named by the SET line of the .aff file.
+func hunspellDecoder(aff []byte) (func([]byte) (string, error), error) {
+	name := ""
+	for _, line := range bytes.Split(aff, []byte("\n")) {
+		fields := strings.Fields(string(line))
+		if len(fields) >= 2 && fields[0] == "SET" {
+			name = strings.ToLower(fields[1])
+			break
+		}
+	}
+	if name == "" || name == "utf-8" || name == "utf8" {
+		return func(data []byte) (string, error) {
+			return string(bytes.TrimPrefix(data, []byte("\ufeff"))), nil
+		}, nil
+	}
+
+	// Hunspell 的编码名与 WHATWG 名称不同
+	switch {
+	case strings.HasPrefix(name, "iso8859-"):
+		name = "iso-8859-" + strings.TrimPrefix(name, "iso8859-")
+	case strings.HasPrefix(name, "microsoft-cp"):
+		name = "windows-" + strings.TrimPrefix(name, "microsoft-cp")
+	}
+	encoding, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported dictionary encoding %q", name)
+	}
+	return func(data []byte) (string, error) {
+		decoded, err := encoding.NewDecoder().Bytes(data)
+		return string(decoded), err
+	}, nil
+}
+
+func (d *Dictionary) parseAffixes(text string) error {
+	// 每个 PFX/SFX 标志先有一行头部（交叉组合与规则数），再跟若干规则行
+	remaining := map[string]int{}
+	cross := map[string]bool{}
+	aliasHeader := false
+
+	for lineNo, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		value := ""
+		if len(fields) > 1 {
+			value = fields[1]
+		}
+		switch fields[0] {
+		case "FLAG":
+			d.flagMode = strings.ToLower(value)
+		case "TRY":
+			d.try = []rune(value)
+		case "WORDCHARS":
+			d.wordChars = value
+		case "IGNORE":
+			d.ignore = value
+		case "REP":
+			// 首行是条目数；"_" 代表空格
+			if len(fields) >= 3 {
+				d.rep = append(d.rep, [2]string{
+					strings.ReplaceAll(fields[1], "_", " "),
+					strings.ReplaceAll(fields[2], "_", " "),
+				})
+			}
+		case "AF":
+			if !aliasHeader {
+				aliasHeader = true
+				continue
+			}
+			d.aliases = append(d.aliases, d.parseRawFlags(value))
+		case "FORBIDDENWORD":
+			d.forbidden = d.flagID(value)
+		case "NEEDAFFIX", "PSEUDOROOT":
+			d.needAffix = d.flagID(value)
+		case "NOSUGGEST":
+			d.noSuggest = d.flagID(value)
+		case "KEEPCASE":
+			d.keepCase = d.flagID(value)
+		case "ONLYINCOMPOUND":
+			d.onlyInCompound = d.flagID(value)
+		case "COMPOUNDFLAG":
+			d.compoundFlag = d.flagID(value)
+		case "COMPOUNDBEGIN":
+			d.compoundBegin = d.flagID(value)
+		case "COMPOUNDMIDDLE":
+			d.compoundMiddle = d.flagID(value)
+		case "COMPOUNDEND":
+			d.compoundEnd = d.flagID(value)
+		case "COMPOUNDMIN":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				d.compoundMin = n
+			}
+		case "PFX", "SFX":
+			if len(fields) < 4 {
+				return fmt.Errorf("line %d: incomplete %s entry", lineNo+1, fields[0])
+			}
+			key := fields[0] + " " + fields[1]
+			if remaining[key] == 0 {
+				count, err := strconv.Atoi(fields[3])
+				if err != nil {
+					return fmt.Errorf("line %d: invalid %s rule count %q", lineNo+1, fields[0], fields[3])
+				}
+				remaining[key] = count
+				cross[key] = fields[2] == "Y"
+				continue
+			}
+			remaining[key]--
+			rule := &affixRule{flag: d.flagID(fields[1]), cross: cross[key], strip: fields[2]}
+			if rule.strip == "0" {
+				rule.strip = ""
+			}
+			add, cont, _ := strings.Cut(fields[3], "/")
+			if add != "0" {
+				rule.add = add
+			}
+			rule.cont = d.parseFlags(cont)
+			if len(fields) >= 5 {
+				rule.condition = parseCondition(fields[4])
+			}
+			d.addRule(fields[0] == "PFX", rule)
+		}
+	}
+	return nil
+}
+
+func (d *Dictionary) addRule(prefix bool, rule *affixRule) {
+	switch {
+	case prefix && rule.add == "":
+		d.emptyPrefixes = append(d.emptyPrefixes, rule)
+	case prefix:
+		first := []rune(rule.add)[0]
+		d.prefixes[first] = append(d.prefixes[first], rule)
+	case rule.add == "":
+		d.emptySuffixes = append(d.emptySuffixes, rule)
+	default:
+		runes := []rune(rule.add)
+		last := runes[len(runes)-1]
+		d.suffixes[last] = append(d.suffixes[last], rule)
+	}
+}
+
+func (d *Dictionary) parseWords(text string) {
+	lines := strings.Split(text, "\n")
+	// 第一行是词条数
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if tab := strings.IndexByte(line, '\t'); tab >= 0 {
+			line = line[:tab]
+		}
+
+		// 词中的 "\/" 是字面斜杠，第一个未转义的 / 之后是标志
+		word, flags := line, ""
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if line[i] == '/' {
+				word, flags = line[:i], line[i+1:]
+				break
+			}
+		}
+		if space := strings.IndexAny(flags, " \t"); space >= 0 {
+			flags = flags[:space]
+		} else if flags == "" {
+			if space := strings.IndexAny(word, " \t"); space >= 0 {
+				word = word[:space]
+			}
+		}
+		word = d.stripIgnored(strings.ReplaceAll(word, `\/`, "/"))
+		if word == "" {
+			continue
+		}
+		d.words[word] = append(d.words[word], d.parseFlags(flags))
+	}
+}
+
+// parseFlags decodes the flags of a word or continuation, resolving AF
+// aliases.
+func (d *Dictionary) parseFlags(raw string) flagSet {
+	if raw == "" {
+		return nil
+	}
+	if len(d.aliases) > 0 {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 1 && n <= len(d.aliases) {
+			return d.aliases[n-1]
+		}
+	}
+	return d.parseRawFlags(raw)
+}
+
+func (d *Dictionary) parseRawFlags(raw string) flagSet {
+	var names []string
+	switch d.flagMode {
+	case "long":
+		runes := []rune(raw)
+		for i := 0; i+1 < len(runes); i += 2 {
+			names = append(names, string(runes[i:i+2]))
+		}
+	case "num":
+		names = strings.Split(raw, ",")
+	default:
+		for _, r := range raw {
+			names = append(names, string(r))
+		}
+	}
+	set := make(flagSet, 0, len(names))
+	for _, name := range names {
+		set = append(set, d.flagID(name))
+	}
+	return set
+}
+
+// flagID interns a flag name; IDs start at 1 so 0 means no flag.
+func (d *Dictionary) flagID(name string) uint16 {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0
+	}
+	if id, ok := d.flagIDs[name]; ok {
+		return id
+	}
+	id := uint16(len(d.flagIDs) + 1)
+	d.flagIDs[name] = id
+	return id
+}
+
+func parseCondition(pattern string) []conditionChar {
+	if pattern == "." {
+		return nil
+	}
+	var condition []conditionChar
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '.':
+			condition = append(condition, conditionChar{any: true})
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			set := runes[i+1 : end]
+			negate := len(set) > 0 && set[0] == '^'
+			if negate {
+				set = set[1:]
+			}
+			condition = append(condition, conditionChar{chars: string(set), negate: negate})
+			i = end
+		default:
+			condition = append(condition, conditionChar{chars: string(runes[i])})
+		}
+	}
+	return condition
+}
+
+func matchCondition(condition []conditionChar, runes []rune) bool {
+	for i, c := range condition {
+		if c.any {
+			continue
+		}
+		if strings.ContainsRune(c.chars, runes[i]) == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRoot checks the rule's condition against the start (prefixes) or
+// end (suffixes) of the root word.
+func (r *affixRule) matchesRoot(root string, prefix bool) bool {
+	if len(r.condition) == 0 {
+		return true
+	}
+	runes := []rune(root)
+	if len(runes) < len(r.condition) {
+		return false
+	}
+	if prefix {
+		return matchCondition(r.condition, runes[:len(r.condition)])
+	}
+	return matchCondition(r.condition, runes[len(runes)-len(r.condition):])
+}
+
+func (d *Dictionary) eachPrefix(word string, visit func(*affixRule) bool) bool {
+	if word == "" {
+		return false
+	}
+	first := []rune(word)[0]
+	for _, rules := range [][]*affixRule{d.prefixes[first], d.emptyPrefixes} {
+		for _, rule := range rules {
+			if strings.HasPrefix(word, rule.add) && visit(rule) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *Dictionary) eachSuffix(word string, visit func(*affixRule) bool) bool {
+	if word == "" {
+		return false
+	}
+	runes := []rune(word)
+	last := runes[len(runes)-1]
+	for _, rules := range [][]*affixRule{d.suffixes[last], d.emptySuffixes} {
+		for _, rule := range rules {
+			if strings.HasSuffix(word, rule.add) && visit(rule) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check reports whether word is spelled correctly. Capitalized and upper-case
+// words may also match their lowercase or capitalized dictionary forms.
+func (d *Dictionary) Check(word string) bool {
+	return d.check(word, false)
+}
+
+func (d *Dictionary) check(word string, suggesting bool) bool {
+	word = d.stripIgnored(word)
+	if word == "" {
+		return true
+	}
+	for i, form := range caseVariants(word) {
+		if d.isForbidden(form) {
+			if i == 0 {
+				return false
+			}
+			continue
+		}
+		accept := func(flags flagSet, affixed bool) bool {
+			if flags.has(d.forbidden) || flags.has(d.onlyInCompound) {
+				return false
+			}
+			if !affixed && flags.has(d.needAffix) {
+				return false
+			}
+			// 大小写改变后匹配到的词，词典要求保持大小写时不接受
+			if i > 0 && flags.has(d.keepCase) {
+				return false
+			}
+			return !suggesting || !flags.has(d.noSuggest)
+		}
+		if d.derive(form, accept) {
+			return true
+		}
+		// 建议阶段跳过复合词拆分，候选太多时过慢
+		if !suggesting && d.checkCompound(form, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dictionary) isForbidden(word string) bool {
+	for _, flags := range d.words[word] {
+		if flags.has(d.forbidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// derive reports whether word is a dictionary word, or a dictionary word with
+// affixes its flags allow, for which accept returns true.
+func (d *Dictionary) derive(word string, accept func(flags flagSet, affixed bool) bool) bool {
+	for _, flags := range d.words[word] {
+		if accept(flags, false) {
+			return true
+		}
+	}
+	if d.deriveSuffix(word, 0, accept) {
+		return true
+	}
+	return d.eachPrefix(word, func(prefix *affixRule) bool {
+		stem := prefix.strip + word[len(prefix.add):]
+		if stem == "" || !prefix.matchesRoot(stem, true) {
+			return false
+		}
+		for _, flags := range d.words[stem] {
+			if flags.has(prefix.flag) && accept(flags, true) {
+				return true
+			}
+		}
+		return prefix.cross && d.deriveSuffix(stem, prefix.flag, accept)
+	})
+}
+
+// deriveSuffix strips one suffix, or two when the inner suffix allows the
+// outer one. With prefixFlag set only cross-product suffixes apply and the
+// root must also allow the prefix.
+func (d *Dictionary) deriveSuffix(word string, prefixFlag uint16, accept func(flags flagSet, affixed bool) bool) bool {
+	return d.eachSuffix(word, func(suffix *affixRule) bool {
+		if prefixFlag != 0 && !suffix.cross {
+			return false
+		}
+		root := word[:len(word)-len(suffix.add)] + suffix.strip
+		if root == "" || !suffix.matchesRoot(root, false) {
+			return false
+		}
+		for _, flags := range d.words[root] {
+			if !flags.has(suffix.flag) {
+				continue
+			}
+			if prefixFlag != 0 && !flags.has(prefixFlag) && !suffix.cont.has(prefixFlag) {
+				continue
+			}
+			if accept(flags, true) {
+				return true
+			}
+		}
+		if prefixFlag != 0 {
+			return false
+		}
+		return d.eachSuffix(root, func(inner *affixRule) bool {
+			if !inner.cont.has(suffix.flag) {
+				return false
+			}
+			base := root[:len(root)-len(inner.add)] + inner.strip
+			if base == "" || !inner.matchesRoot(base, false) {
+				return false
+			}
+			for _, flags := range d.words[base] {
+				if flags.has(inner.flag) && accept(flags, true) {
+					return true
+				}
+			}
+			return false
+		})
+	})
+}
+
+// checkCompound splits word into parts flagged for compounding; only the
+// last part may carry affixes.
+func (d *Dictionary) checkCompound(word string, depth int) bool {
+	if (d.compoundFlag == 0 && d.compoundBegin == 0) || depth > 3 {
+		return false
+	}
+	runes := []rune(word)
+	if len(runes) < 2*d.compoundMin {
+		return false
+	}
+	position := d.compoundBegin
+	if depth > 0 {
+		position = d.compoundMiddle
+	}
+	for i := d.compoundMin; i <= len(runes)-d.compoundMin; i++ {
+		head, tail := string(runes[:i]), string(runes[i:])
+		headOK := false
+		for _, flags := range d.words[head] {
+			if !flags.has(d.forbidden) && (flags.has(d.compoundFlag) || flags.has(position)) {
+				headOK = true
+				break
+			}
+		}
+		if !headOK {
+			continue
+		}
+		tailOK := d.derive(tail, func(flags flagSet, _ bool) bool {
+			return !flags.has(d.forbidden) && (flags.has(d.compoundFlag) || flags.has(d.compoundEnd))
+		})
+		if tailOK || d.checkCompound(tail, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggest returns up to limit correctly spelled words close to word: case
+// fixes, REP replacements, swapped, replaced, missing and extra characters,
+// then splits into two words.
+func (d *Dictionary) Suggest(word string, limit int) []string {
+	suggestions := []string{}
+	seen := map[string]bool{word: true}
+	full := func(candidate string) bool {
+		if !seen[candidate] {
+			seen[candidate] = true
+			// REP 和拆词会产生带空格的候选，逐词检查
+			correct := true
+			for _, part := range strings.Split(candidate, " ") {
+				correct = correct && part != "" && d.check(part, true)
+			}
+			if correct {
+				suggestions = append(suggestions, candidate)
+			}
+		}
+		return len(suggestions) >= limit
+	}
+
+	for _, form := range append(caseVariants(word)[1:], capitalize(strings.ToLower(word))) {
+		if full(form) {
+			return suggestions
+		}
+	}
+	for _, rep := range d.rep {
+		for start := 0; ; {
+			index := strings.Index(word[start:], rep[0])
+			if index < 0 || rep[0] == "" {
+				break
+			}
+			index += start
+			if full(word[:index] + rep[1] + word[index+len(rep[0]):]) {
+				return suggestions
+			}
+			start = index + len(rep[0])
+		}
+	}
+
+	runes := []rune(word)
+	chars := d.try
+	if len(chars) == 0 {
+		chars = []rune("esianrtolcdugmphbyfvkwzxjq")
+	}
+	edit := func(build func(out []rune) []rune) bool {
+		return full(string(build(make([]rune, 0, len(runes)+1))))
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] != runes[i+1] && edit(func(out []rune) []rune {
+			out = append(out, runes[:i]...)
+			return append(append(append(out, runes[i+1]), runes[i]), runes[i+2:]...)
+		}) {
+			return suggestions
+		}
+	}
+	for i := range runes {
+		for _, c := range chars {
+			if c != runes[i] && edit(func(out []rune) []rune {
+				return append(append(append(out, runes[:i]...), c), runes[i+1:]...)
+			}) {
+				return suggestions
+			}
+		}
+	}
+	for i := range runes {
+		if edit(func(out []rune) []rune {
+			return append(append(out, runes[:i]...), runes[i+1:]...)
+		}) {
+			return suggestions
+		}
+	}
+	for i := 0; i <= len(runes); i++ {
+		for _, c := range chars {
+			if edit(func(out []rune) []rune {
+				return append(append(append(out, runes[:i]...), c), runes[i:]...)
+			}) {
+				return suggestions
+			}
+		}
+	}
+	for i := 1; i < len(runes); i++ {
+		left, right := string(runes[:i]), string(runes[i:])
+		if full(left + " " + right) {
+			return suggestions
+		}
+	}
+	return suggestions
+}
+
+// stripIgnored removes the characters listed by IGNORE, such as Arabic
+// diacritics, before lookup.
+func (d *Dictionary) stripIgnored(word string) string {
+	if d.ignore == "" {
+		return word
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(d.ignore, r) {
+			return -1
+		}
+		return r
+	}, word)
+}
+
+// caseVariants returns word followed by the forms a dictionary may list it
+// under: lowercase for capitalized words, capitalized and lowercase for
+// upper-case words. Mixed-case words such as "HeLlo" must match as written.
+func caseVariants(word string) []string {
+	variants := []string{word}
+	runes := []rune(word)
+	if len(runes) == 0 || !unicode.IsUpper(runes[0]) {
+		return variants
+	}
+	lower := strings.ToLower(word)
+	switch {
+	case word == strings.ToUpper(word) && len(runes) > 1:
+		variants = append(variants, capitalize(lower), lower)
+	case word == capitalize(lower):
+		variants = append(variants, lower)
+	}
+	return variants
+}
+
+func capitalize(word string) string {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return word
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadTestDictionary(t *testing.T) *Dictionary {
+	t.Helper()
+	dictionary, err := LoadDictionary(filepath.Join("testdata", "en_TEST.aff"), filepath.Join("testdata", "en_TEST.dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dictionary
+}
+
+func TestDictionaryCheck(t *testing.T) {
+	dictionary := loadTestDictionary(t)
+	if dictionary.Language != "en_TEST" {
+		t.Errorf("Language = %q", dictionary.Language)
+	}
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{word: "city", want: true},
+		{word: "cities", want: true},
+		{word: "citys"},
+		{word: "days", want: true},
+		{word: "daies"},
+		{word: "baked", want: true},
+		{word: "bakeed"},
+		{word: "locked", want: true},
+		{word: "unlock", want: true},
+		{word: "unlocked", want: true},
+		{word: "relock", want: true},
+		// R 不允许与后缀交叉组合，条件 [^e] 排除 e 开头的词根
+		{word: "relocked"},
+		{word: "reenter"},
+		{word: "kindness", want: true},
+		{word: "kindnesses", want: true},
+		{word: "kindnesss"},
+		{word: "workshop", want: true},
+		{word: "shopwork", want: true},
+		{word: "workwork", want: true},
+		{word: "worksh"},
+		{word: "walked", want: true},
+		{word: "walk"},
+		{word: "irregardless"},
+		{word: "Cities", want: true},
+		{word: "PARIS", want: true},
+		{word: "paris"},
+		{word: "ipad", want: true},
+		{word: "Ipad"},
+		{word: "CiTy"},
+		{word: "e-mail", want: true},
+	}
+	for _, tt := range tests {
+		if got := dictionary.Check(tt.word); got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestDictionarySuggest(t *testing.T) {
+	dictionary := loadTestDictionary(t)
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "fone", want: "phone"},
+		{word: "wrld", want: "world"},
+		{word: "kindnesss", want: "kindness"},
+		{word: "cty", want: "city"},
+		{word: "seethe", want: "see the"},
+	}
+	for _, tt := range tests {
+		suggestions := dictionary.Suggest(tt.word, 5)
+		found := false
+		for _, suggestion := range suggestions {
+			found = found || suggestion == tt.want
+		}
+		if !found {
+			t.Errorf("Suggest(%q) = %v, want %q among them", tt.word, suggestions, tt.want)
+		}
+	}
+	if suggestions := dictionary.Suggest("irregardles", 5); len(suggestions) != 0 {
+		t.Errorf("forbidden word suggested: %v", suggestions)
+	}
+}
+
+func TestDictionaryFlagModes(t *testing.T) {
+	tests := []struct {
+		name string
+		aff  string
+		dic  string
+	}{
+		{
+			name: "single characters",
+			aff:  "PFX B Y 1\nPFX B 0 re .\nSFX A Y 1\nSFX A 0 s .\n",
+			dic:  "1\ncook/AB\n",
+		},
+		{
+			name: "UTF-8 characters",
+			aff:  "FLAG UTF-8\nPFX ü Y 1\nPFX ü 0 re .\nSFX é Y 1\nSFX é 0 s .\n",
+			dic:  "1\ncook/éü\n",
+		},
+		{
+			name: "long",
+			aff:  "FLAG long\nPFX Bb Y 1\nPFX Bb 0 re .\nSFX Aa Y 1\nSFX Aa 0 s .\n",
+			dic:  "1\ncook/AaBb\n",
+		},
+		{
+			name: "numbers",
+			aff:  "FLAG num\nPFX 7 Y 1\nPFX 7 0 re .\nSFX 101 Y 1\nSFX 101 0 s .\n",
+			dic:  "1\ncook/101,7\n",
+		},
+		{
+			name: "aliases",
+			aff:  "AF 1\nAF AB\nPFX B Y 1\nPFX B 0 re .\nSFX A Y 1\nSFX A 0 s .\n",
+			dic:  "1\ncook/1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, map[string]string{"x.aff": tt.aff, "x.dic": tt.dic})
+			dictionary, err := LoadDictionary(filepath.Join(dir, "x.aff"), filepath.Join(dir, "x.dic"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for word, want := range map[string]bool{"cook": true, "cooks": true, "recook": true, "recooks": true, "cooked": false, "cookre": false} {
+				if got := dictionary.Check(word); got != want {
+					t.Errorf("Check(%q) = %v, want %v", word, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDictionaryEncoding(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"x.aff": "SET ISO8859-1\n", "x.dic": "1\ncaf\xe9\n"})
+	dictionary, err := LoadDictionary(filepath.Join(dir, "x.aff"), filepath.Join(dir, "x.dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dictionary.Check("café") {
+		t.Error("Latin-1 word not decoded")
+	}
+}
+
+func TestLoadDictionaryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		aff  string
+		want string
+	}{
+		{name: "incomplete entry", aff: "SFX A Y\n", want: "line 1: incomplete SFX entry"},
+		{name: "invalid rule count", aff: "# header\nPFX A Y many\n", want: `line 2: invalid PFX rule count "many"`},
+		{name: "unknown encoding", aff: "SET KOI9-X\n", want: "unsupported dictionary encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, map[string]string{"x.aff": tt.aff, "x.dic": "1\nword\n"})
+			_, err := LoadDictionary(filepath.Join(dir, "x.aff"), filepath.Join(dir, "x.dic"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"x.aff": "SET UTF-8\n"})
+	if _, err := LoadDictionary(filepath.Join(dir, "x.aff"), filepath.Join(dir, "x.dic")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing .dic: err = %v", err)
+	}
+}
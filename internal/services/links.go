@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DocumentLink is a link or image reference found in a Markdown document.
+type DocumentLink struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+	Target string `json:"target"`
+	Image  bool   `json:"image"`
+}
+
+// LinkIssue describes a link whose target could not be resolved.
+type LinkIssue struct {
+	Path    string `json:"path"`
+	RelPath string `json:"relPath"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Target  string `json:"target"`
+	Reason  string `json:"reason"`
+}
+
+// LinkCheckOptions tunes CheckLinks.
+type LinkCheckOptions struct {
+	Include  []string
+	Exclude  []string
+	External bool
+	Timeout  time.Duration
+}
+
+var (
+	inlineLinkPattern    = regexp.MustCompile(`(!?)\[((?:[^\[\]]|\[[^\]]*\])*)\]\(\s*(<[^>]*>|[^)\s]+)(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*\)`)
+	referenceDefPattern  = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*(<[^>]*>|\S+)`)
+	urlSchemePattern     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.\-]*:`)
+	externalSchemePrefix = []string{"http://", "https://"}
+)
+
+// ExtractLinks returns inline links, images and reference definitions outside code.
+func ExtractLinks(content string) []DocumentLink {
+	links := []DocumentLink{}
+	lines := strings.Split(content, "\n")
+	inFence := false
+	fenceMarker := ""
+
+	for i := FrontMatterLineCount(content); i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		trimmed := strings.TrimSpace(line)
+		if marker := fenceOpening(trimmed); marker != "" {
+			if !inFence {
+				inFence, fenceMarker = true, marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) {
+				inFence = false
+				continue
+			}
+		}
+		if inFence {
+			continue
+		}
+
+		if def := referenceDefPattern.FindStringSubmatchIndex(line); def != nil {
+			links = append(links, DocumentLink{
+				Line:   i + 1,
+				Column: utf8.RuneCountInString(line[:def[4]]) + 1,
+				Text:   line[def[2]:def[3]],
+				Target: strings.Trim(line[def[4]:def[5]], "<>"),
+			})
+			continue
+		}
+
+		scan := stripInlineCode(line)
+		for _, loc := range inlineLinkPattern.FindAllStringSubmatchIndex(scan, -1) {
+			links = append(links, DocumentLink{
+				Line:   i + 1,
+				Column: utf8.RuneCountInString(scan[:loc[0]]) + 1,
+				Text:   scan[loc[4]:loc[5]],
+				Target: strings.Trim(scan[loc[6]:loc[7]], "<>"),
+				Image:  loc[3] > loc[2],
+			})
+		}
+	}
+	return links
+}
+
+// IsExternalLink reports whether target carries a URL scheme (http:, mailto:, …).
+func IsExternalLink(target string) bool {
+	return urlSchemePattern.MatchString(target) || strings.HasPrefix(target, "//")
+}
+
+// SplitLinkTarget separates a local link target into its decoded path and anchor.
+func SplitLinkTarget(target string) (string, string) {
+	path, anchor, _ := strings.Cut(target, "#")
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+	if decoded, err := url.PathUnescape(anchor); err == nil {
+		anchor = decoded
+	}
+	return path, anchor
+}
+
+// ResolveLinkPath maps a local link path to a filesystem path. Absolute link
+// paths are resolved against the workspace root.
+func ResolveLinkPath(root string, documentPath string, linkPath string) string {
+	if strings.HasPrefix(linkPath, "/") && root != "" {
+		return filepath.Join(root, filepath.FromSlash(linkPath))
+	}
+	return filepath.Join(filepath.Dir(documentPath), filepath.FromSlash(linkPath))
+}
+
+// CheckDocumentLinks validates every local link of a single document.
+func CheckDocumentLinks(root string, documentPath string, content string, opts LinkCheckOptions) []LinkIssue {
+	issues := []LinkIssue{}
+	var ownAnchors map[string]struct{}
+	targetAnchors := map[string]map[string]struct{}{}
+	client := &http.Client{Timeout: opts.Timeout}
+
+	for _, link := range ExtractLinks(content) {
+		issue := LinkIssue{Path: documentPath, Line: link.Line, Column: link.Column, Target: link.Target}
+		if link.Target == "" {
+			issue.Reason = "empty link target"
+			issues = append(issues, issue)
+			continue
+		}
+
+		if IsExternalLink(link.Target) {
+			if opts.External && hasExternalScheme(link.Target) {
+				if reason := checkExternalLink(client, link.Target); reason != "" {
+					issue.Reason = reason
+					issues = append(issues, issue)
+				}
+			}
+			continue
+		}
+
+		linkPath, anchor := SplitLinkTarget(link.Target)
+		if linkPath == "" {
+			if ownAnchors == nil {
+				ownAnchors = documentAnchors(content)
+			}
+			if !hasAnchor(ownAnchors, anchor) {
+				issue.Reason = "heading not found"
+				issues = append(issues, issue)
+			}
+			continue
+		}
+
+		resolved := ResolveLinkPath(root, documentPath, linkPath)
+		info, err := os.Stat(resolved)
+		if err != nil {
+			issue.Reason = "file not found"
+			issues = append(issues, issue)
+			continue
+		}
+
+		if anchor == "" || info.IsDir() || !IsMarkdownFile(resolved) {
+			continue
+		}
+		anchors, ok := targetAnchors[resolved]
+		if !ok {
+			data, err := os.ReadFile(resolved)
+			if err != nil {
+				continue
+			}
+			anchors = documentAnchors(string(data))
+			targetAnchors[resolved] = anchors
+		}
+		if !hasAnchor(anchors, anchor) {
+			issue.Reason = "heading not found"
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// CheckLinks validates links in every Markdown note below dir. Absolute link
+// paths resolve against the workspace root, which defaults to dir; RelPath
+// and the include and exclude globs are relative to dir.
+func CheckLinks(root string, dir string, opts LinkCheckOptions) ([]LinkIssue, error) {
+	clean := filepath.Clean(dir)
+	if root == "" {
+		root = clean
+	}
+	issues := []LinkIssue{}
+
+	err := WalkMarkdownFiles(clean, func(path string, _ fs.FileInfo) error {
+		rel, err := filepath.Rel(clean, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !MatchGlobs(rel, opts.Include, opts.Exclude) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, issue := range CheckDocumentLinks(root, path, string(data), opts) {
+			issue.RelPath = rel
+			issues = append(issues, issue)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+func hasAnchor(anchors map[string]struct{}, anchor string) bool {
+	if anchor == "" {
+		return true
+	}
+	if _, ok := anchors[anchor]; ok {
+		return true
+	}
+	_, ok := anchors[strings.ToLower(anchor)]
+	return ok
+}
+
+func hasExternalScheme(target string) bool {
+	lower := strings.ToLower(target)
+	for _, prefix := range externalSchemePrefix {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkExternalLink(client *http.Client, target string) string {
+	timeout := client.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return "invalid URL"
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "request failed: " + err.Error()
+	}
+	resp.Body.Close()
+
+	// 部分站点不支持 HEAD，退回 GET 再确认一次
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		resp, err = client.Do(req)
+		if err != nil {
+			return "request failed: " + err.Error()
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode >= 400 {
+		return "HTTP " + resp.Status
+	}
+	return ""
+}
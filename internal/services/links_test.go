@@ -0,0 +1,75 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	const note = "---\nlink: [x](front.md)\n---\n[a](a.md) ![img](<my img.png> \"title\")\n`[code](no.md)` [b](b.md#part)\n```\n[fenced](no.md)\n```\n[ref]: ref.md\n"
+	got := ExtractLinks(note)
+	want := []DocumentLink{
+		{Line: 4, Column: 1, Text: "a", Target: "a.md"},
+		{Line: 4, Column: 11, Text: "img", Target: "my img.png", Image: true},
+		{Line: 5, Column: 17, Text: "b", Target: "b.md#part"},
+		{Line: 9, Column: 8, Text: "ref", Target: "ref.md"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractLinks = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"index.md":         "# Index\n\n## Part\n",
+		"docs/guide.md":    "[ok](/index.md#h2-part) [rel](../index.md) [gone](missing.md) [top](/docs/missing.md) [self](#h1-nope)\n\n# Guide\n",
+		"docs/sub/note.md": "[up](../guide.md#h1-guide) [bad](/index.md#h2-other)\n",
+		"other/x.md":       "[broken](nowhere.md)\n",
+	})
+
+	tests := []struct {
+		name string
+		root string
+		dir  string
+		opts LinkCheckOptions
+		want []string
+	}{
+		{
+			name: "folder inside the workspace",
+			root: root,
+			dir:  filepath.Join(root, "docs"),
+			want: []string{"guide.md:#h1-nope", "guide.md:/docs/missing.md", "guide.md:missing.md", "sub/note.md:/index.md#h2-other"},
+		},
+		{
+			name: "root defaults to the folder",
+			dir:  filepath.Join(root, "docs"),
+			want: []string{"guide.md:#h1-nope", "guide.md:/docs/missing.md", "guide.md:/index.md#h2-part", "guide.md:missing.md", "sub/note.md:/index.md#h2-other"},
+		},
+		{
+			name: "exclude glob",
+			root: root,
+			dir:  root,
+			opts: LinkCheckOptions{Exclude: []string{"docs/**"}},
+			want: []string{"other/x.md:nowhere.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := CheckLinks(tt.root, tt.dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, issue := range issues {
+				got = append(got, issue.RelPath+":"+issue.Target)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,811 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+// LintConfigName is the file configuring the linter for the folder holding
+// it and everything below.
+const LintConfigName = ".markdowndaonote-lint.yml"
+
+// Lint severities; "off" disables a rule in the configuration.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+	LintInfo    = "info"
+	lintOff     = "off"
+)
+
+// LintDiagnostic is a style problem found in a Markdown document.
+// Lines and columns are 1-based; columns count runes.
+type LintDiagnostic struct {
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+	Severity  string `json:"severity"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	// Fix is set when the problem can be corrected without changing how the
+	// document renders.
+	Fix *LintFix `json:"fix,omitempty"`
+}
+
+// LintFix replaces a range, addressed like LintDiagnostic, with Text.
+type LintFix struct {
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Text      string `json:"text"`
+}
+
+// LintConfig selects the rules to run. A configuration file looks like:
+//
+//	default: true          # run rules not listed below
+//	rules:
+//	  heading-increment: error
+//	  no-bare-urls: off
+//	  line-length:
+//	    severity: warning
+//	    max: 100
+//
+// The same file holds the formatter style under format (see FormatOptions)
+// and the table of contents levels under toc (see TOCOptions).
+type LintConfig struct {
+	// Default enables the rules that Rules does not mention.
+	Default bool
+	Rules   map[string]LintRuleConfig
+	Format  FormatOptions
+	TOC     TOCOptions
+}
+
+// LintRuleConfig overrides the severity and options of one rule.
+type LintRuleConfig struct {
+	Severity string
+	Options  map[string]interface{}
+}
+
+// DefaultLintConfig runs every rule with its default severity and options.
+func DefaultLintConfig() LintConfig {
+	return LintConfig{Default: true, Rules: map[string]LintRuleConfig{}}
+}
+
+type lintConfigFile struct {
+	Default *bool                `yaml:"default"`
+	Rules   map[string]yaml.Node `yaml:"rules"`
+	Format  FormatOptions        `yaml:"format"`
+	TOC     TOCOptions           `yaml:"toc"`
+}
+
+// ParseLintConfig decodes a LintConfigName file.
+func ParseLintConfig(data []byte) (LintConfig, error) {
+	config := DefaultLintConfig()
+	var file lintConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return config, err
+	}
+	if file.Default != nil {
+		config.Default = *file.Default
+	}
+
+	for name, node := range file.Rules {
+		if findLintRule(name) == nil {
+			return config, fmt.Errorf("unknown lint rule %q", name)
+		}
+		rule := LintRuleConfig{Options: map[string]interface{}{}}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			var enabled bool
+			if node.Decode(&enabled) == nil && node.Tag == "!!bool" {
+				if !enabled {
+					rule.Severity = lintOff
+				}
+			} else {
+				rule.Severity = strings.ToLower(strings.TrimSpace(node.Value))
+			}
+		case yaml.MappingNode:
+			if err := node.Decode(&rule.Options); err != nil {
+				return config, fmt.Errorf("lint rule %s: %w", name, err)
+			}
+			if severity, ok := rule.Options["severity"].(string); ok {
+				rule.Severity = strings.ToLower(strings.TrimSpace(severity))
+			}
+			if enabled, ok := rule.Options["enabled"].(bool); ok && !enabled {
+				rule.Severity = lintOff
+			}
+			delete(rule.Options, "severity")
+			delete(rule.Options, "enabled")
+		default:
+			return config, fmt.Errorf("lint rule %s: expected a severity or options", name)
+		}
+		switch rule.Severity {
+		case "", LintError, LintWarning, LintInfo, lintOff:
+		default:
+			return config, fmt.Errorf("lint rule %s: unknown severity %q", name, rule.Severity)
+		}
+		config.Rules[name] = rule
+	}
+	if _, err := file.Format.normalize(); err != nil {
+		return config, err
+	}
+	config.Format = file.Format
+	if _, err := file.TOC.normalize(); err != nil {
+		return config, err
+	}
+	config.TOC = file.TOC
+	return config, nil
+}
+
+// FormatOptions returns the formatter style, taking unset values from the
+// list-marker style and line-length max where those are configured.
+func (c LintConfig) FormatOptions() FormatOptions {
+	options := c.Format
+	if options.Bullet == "" {
+		switch lintOptions(c.Rules["list-marker"].Options).string("style", "") {
+		case "-", "dash":
+			options.Bullet = "-"
+		case "*", "asterisk":
+			options.Bullet = "*"
+		case "+", "plus":
+			options.Bullet = "+"
+		}
+	}
+	if options.Width <= 0 {
+		options.Width = lintOptions(c.Rules["line-length"].Options).int("max", 0)
+	}
+	options, err := options.normalize()
+	if err != nil {
+		return DefaultFormatOptions()
+	}
+	return options
+}
+
+// TOCOptions returns the table of contents levels, listed with the bullet
+// of the formatter style.
+func (c LintConfig) TOCOptions() TOCOptions {
+	options := c.TOC
+	options.Bullet = c.FormatOptions().Bullet
+	options, err := options.normalize()
+	if err != nil {
+		return DefaultTOCOptions()
+	}
+	return options
+}
+
+// LoadLintConfig returns the configuration for files in dir: the nearest
+// LintConfigName in dir or its parents, or the defaults when there is none.
+func LoadLintConfig(dir string) (LintConfig, error) {
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		return DefaultLintConfig(), err
+	}
+	for current := absolute; ; {
+		data, err := os.ReadFile(filepath.Join(current, LintConfigName))
+		if err == nil {
+			config, err := ParseLintConfig(data)
+			if err != nil {
+				return config, fmt.Errorf("%s: %w", filepath.Join(current, LintConfigName), err)
+			}
+			return config, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return DefaultLintConfig(), err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return DefaultLintConfig(), nil
+		}
+		current = parent
+	}
+}
+
+// lintRule is a check with its default severity. check reports problems
+// through doc.report or doc.reportRange.
+type lintRule struct {
+	name     string
+	severity string
+	check    func(doc *lintDocument, options lintOptions)
+}
+
+var lintRules = []lintRule{
+	{name: "heading-increment", severity: LintWarning, check: lintHeadingIncrement},
+	{name: "no-trailing-spaces", severity: LintWarning, check: lintTrailingSpaces},
+	{name: "no-hard-tabs", severity: LintInfo, check: lintHardTabs},
+	{name: "no-multiple-blanks", severity: LintWarning, check: lintMultipleBlanks},
+	{name: "list-marker", severity: LintWarning, check: lintListMarker},
+	{name: "line-length", severity: LintInfo, check: lintLineLength},
+	{name: "no-bare-urls", severity: LintWarning, check: lintBareURLs},
+}
+
+func findLintRule(name string) *lintRule {
+	for i := range lintRules {
+		if lintRules[i].name == name {
+			return &lintRules[i]
+		}
+	}
+	return nil
+}
+
+// LintDocument checks a Markdown document against the enabled rules.
+func LintDocument(content string, config LintConfig) []LintDiagnostic {
+	doc := newLintDocument(content)
+	for _, rule := range lintRules {
+		override, configured := config.Rules[rule.name]
+		if !configured && !config.Default {
+			continue
+		}
+		severity := rule.severity
+		if override.Severity != "" {
+			severity = override.Severity
+		}
+		if severity == lintOff {
+			continue
+		}
+		doc.rule, doc.severity = rule.name, severity
+		rule.check(doc, lintOptions(override.Options))
+	}
+
+	diagnostics := doc.diagnostics
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics
+}
+
+// FixDocument applies every available fix, re-linting until nothing changes,
+// and returns the new content with the number of fixes applied.
+func FixDocument(content string, config LintConfig) (string, int) {
+	total := 0
+	for pass := 0; pass < 5; pass++ {
+		fixed, applied := ApplyLintFixes(content, LintDocument(content, config))
+		if applied == 0 {
+			break
+		}
+		content, total = fixed, total+applied
+	}
+	return content, total
+}
+
+// ApplyLintFixes applies the fixes of diagnostics to content. Fixes that
+// overlap an earlier one are skipped; the count of applied fixes is returned.
+func ApplyLintFixes(content string, diagnostics []LintDiagnostic) (string, int) {
+	index := newLineIndex(content)
+	edits := []textEdit{}
+	for _, diagnostic := range diagnostics {
+		fix := diagnostic.Fix
+		if fix == nil {
+			continue
+		}
+		start, ok := index.offset(fix.Line, fix.Column)
+		end, endOK := index.offset(fix.EndLine, fix.EndColumn)
+		if !ok || !endOK || end < start {
+			continue
+		}
+		edits = append(edits, textEdit{start: start, end: end, text: fix.Text})
+	}
+	return applyTextEdits(content, edits)
+}
+
+// textEdit replaces the bytes start to end of a document with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyTextEdits applies edits in order of position, skipping any that
+// overlaps an earlier one, and returns the count applied.
+func applyTextEdits(content string, edits []textEdit) (string, int) {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out strings.Builder
+	position, applied := 0, 0
+	for _, e := range edits {
+		if e.start < position {
+			continue
+		}
+		out.WriteString(content[position:e.start])
+		out.WriteString(e.text)
+		position = e.end
+		applied++
+	}
+	out.WriteString(content[position:])
+	return out.String(), applied
+}
+
+// parsedDocument is a Markdown document with its AST and the lines taken
+// by code, HTML, tables and headings. The front matter is not parsed.
+type parsedDocument struct {
+	content string
+	index   lineIndex
+	// lines holds every line without its line break; a final empty line
+	// after a trailing newline is dropped.
+	lines []string
+	// bodyLine is the first line after the front matter.
+	bodyLine int
+	source   []byte
+	offset   int
+	root     ast.Node
+
+	codeLines    map[int]bool
+	htmlLines    map[int]bool
+	tableLines   map[int]bool
+	headingLines map[int]bool
+}
+
+// lintDocument is a parsed document shared by the rules, collecting the
+// diagnostics of the rule being run.
+type lintDocument struct {
+	*parsedDocument
+
+	rule        string
+	severity    string
+	diagnostics []LintDiagnostic
+}
+
+func newLintDocument(content string) *lintDocument {
+	return &lintDocument{parsedDocument: parseDocument(content), diagnostics: []LintDiagnostic{}}
+}
+
+func parseDocument(content string) *parsedDocument {
+	_, body, _ := SplitFrontMatter(content)
+	doc := &parsedDocument{
+		content:      content,
+		index:        newLineIndex(content),
+		lines:        strings.Split(content, "\n"),
+		bodyLine:     FrontMatterLineCount(content) + 1,
+		source:       []byte(body),
+		offset:       len(content) - len(body),
+		codeLines:    map[int]bool{},
+		htmlLines:    map[int]bool{},
+		tableLines:   map[int]bool{},
+		headingLines: map[int]bool{},
+	}
+	if strings.HasSuffix(content, "\n") {
+		doc.lines = doc.lines[:len(doc.lines)-1]
+	}
+	for i, line := range doc.lines {
+		doc.lines[i] = strings.TrimRight(line, "\r")
+	}
+	doc.root = newMarkdown().Parser().Parse(text.NewReader(doc.source))
+
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.FencedCodeBlock:
+			first, last := doc.blockLines(n)
+			if n.Info != nil {
+				first = doc.line(n.Info.Segment.Start)
+			} else if first > 0 {
+				first--
+			}
+			if last > 0 && last < len(doc.lines) && fenceOpening(strings.TrimSpace(doc.lines[last])) != "" {
+				last++
+			}
+			markLines(doc.codeLines, first, last)
+		case *ast.CodeBlock:
+			first, last := doc.blockLines(n)
+			markLines(doc.codeLines, first, last)
+		case *ast.HTMLBlock:
+			first, last := doc.blockLines(n)
+			if n.HasClosure() {
+				last = doc.line(n.ClosureLine.Start)
+			}
+			markLines(doc.htmlLines, first, last)
+		case *ast.Heading:
+			first, last := doc.blockLines(n)
+			markLines(doc.headingLines, first, last)
+		case *east.Table:
+			first, last := doc.tableSpan(n)
+			markLines(doc.tableLines, first, last)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return doc
+}
+
+// line returns the 1-based line of an offset into the parsed body.
+func (d *parsedDocument) line(offset int) int {
+	line, _ := d.index.position(d.offset + offset)
+	return line
+}
+
+// blockLines returns the first and last line of a block's content, 0 when empty.
+func (d *parsedDocument) blockLines(node ast.Node) (int, int) {
+	lines := node.Lines()
+	if lines.Len() == 0 {
+		return 0, 0
+	}
+	last := lines.At(lines.Len() - 1)
+	end := last.Stop
+	if end > last.Start {
+		end--
+	}
+	return d.line(lines.At(0).Start), d.line(end)
+}
+
+// tableSpan returns the first and last line of a table, 0 when no cell has
+// text. Each row of a GFM table is one line, the delimiter row following the header.
+func (d *parsedDocument) tableSpan(table *east.Table) (int, int) {
+	rows := table.ChildCount()
+	row := 0
+	for child := table.FirstChild(); child != nil; child = child.NextSibling() {
+		for cell := child.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			if cell.Lines().Len() == 0 {
+				continue
+			}
+			first := d.line(cell.Lines().At(0).Start) - row
+			if row > 0 {
+				first--
+			}
+			return first, first + rows
+		}
+		row++
+	}
+	return 0, 0
+}
+
+func markLines(set map[int]bool, first int, last int) {
+	if first <= 0 {
+		return
+	}
+	for line := first; line <= last; line++ {
+		set[line] = true
+	}
+}
+
+// reportRange adds a diagnostic for the rule being run covering body
+// offsets start to end, with fix as the replacement when not nil.
+func (d *lintDocument) reportRange(message string, start int, end int, fix *string) {
+	line, column := d.index.position(d.offset + start)
+	endLine, endColumn := d.index.position(d.offset + end)
+	diagnostic := LintDiagnostic{
+		Rule: d.rule, Message: message, Severity: d.severity,
+		Line: line, Column: column, EndLine: endLine, EndColumn: endColumn,
+	}
+	if fix != nil {
+		diagnostic.Fix = &LintFix{Line: line, Column: column, EndLine: endLine, EndColumn: endColumn, Text: *fix}
+	}
+	d.diagnostics = append(d.diagnostics, diagnostic)
+}
+
+func (d *lintDocument) report(diagnostic LintDiagnostic) {
+	diagnostic.Rule, diagnostic.Severity = d.rule, d.severity
+	d.diagnostics = append(d.diagnostics, diagnostic)
+}
+
+// bodyLines calls visit for each line after the front matter.
+func (d *parsedDocument) bodyLines(visit func(lineNo int, line string)) {
+	for i := d.bodyLine - 1; i < len(d.lines); i++ {
+		visit(i+1, d.lines[i])
+	}
+}
+
+func lintHeadingIncrement(doc *lintDocument, _ lintOptions) {
+	previousLevel := 0
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if previousLevel > 0 && heading.Level > previousLevel+1 {
+			if line, _ := doc.blockLines(heading); line > 0 {
+				doc.report(wholeLine(fmt.Sprintf("Heading level jumps from h%d to h%d", previousLevel, heading.Level), line, doc.lines[line-1]))
+			}
+		}
+		previousLevel = heading.Level
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+func lintTrailingSpaces(doc *lintDocument, options lintOptions) {
+	// 行尾两个空格是 Markdown 硬换行，默认允许保留
+	breakSpaces := options.int("br_spaces", 2)
+	doc.bodyLines(func(lineNo int, line string) {
+		stripped := strings.TrimRight(line, " \t")
+		if len(stripped) == len(line) || stripped == "" || doc.codeLines[lineNo] {
+			return
+		}
+		if breakSpaces > 0 && strings.TrimRight(line, " ") == stripped && len(line)-len(stripped) == breakSpaces {
+			return
+		}
+		start := utf8.RuneCountInString(stripped) + 1
+		end := utf8.RuneCountInString(line) + 1
+		fixStart := start
+		// 多于硬换行的空格只删掉多出的部分，不丢失换行
+		if breakSpaces > 0 && strings.TrimRight(line, " ") == stripped && len(line)-len(stripped) > breakSpaces {
+			fixStart += breakSpaces
+		}
+		doc.report(LintDiagnostic{
+			Message: "Trailing whitespace",
+			Line:    lineNo, Column: start, EndLine: lineNo, EndColumn: end,
+			Fix: &LintFix{Line: lineNo, Column: fixStart, EndLine: lineNo, EndColumn: end},
+		})
+	})
+}
+
+func lintHardTabs(doc *lintDocument, options lintOptions) {
+	codeBlocks := options.bool("code_blocks", false)
+	doc.bodyLines(func(lineNo int, line string) {
+		if doc.codeLines[lineNo] && !codeBlocks {
+			return
+		}
+		if idx := strings.IndexByte(line, '\t'); idx >= 0 {
+			column := utf8.RuneCountInString(line[:idx]) + 1
+			doc.report(LintDiagnostic{
+				Message: "Hard tab character",
+				Line:    lineNo, Column: column, EndLine: lineNo, EndColumn: column + 1,
+			})
+		}
+	})
+}
+
+func lintMultipleBlanks(doc *lintDocument, options lintOptions) {
+	maximum := options.int("maximum", 1)
+	if maximum < 1 {
+		maximum = 1
+	}
+	runStart := 0
+	flush := func(runEnd int) {
+		if runStart == 0 || runEnd-runStart+1 <= maximum {
+			return
+		}
+		first := runStart + maximum
+		// 删除多余空行前的换行符，文件末尾的空行也能正确删除
+		doc.report(LintDiagnostic{
+			Message: "Multiple consecutive blank lines",
+			Line:    first, Column: 1, EndLine: runEnd, EndColumn: 1,
+			Fix: &LintFix{
+				Line: first - 1, Column: utf8.RuneCountInString(doc.lines[first-2]) + 1,
+				EndLine: runEnd, EndColumn: utf8.RuneCountInString(doc.lines[runEnd-1]) + 1,
+			},
+		})
+	}
+	doc.bodyLines(func(lineNo int, line string) {
+		if strings.TrimSpace(line) == "" && !doc.codeLines[lineNo] {
+			if runStart == 0 {
+				runStart = lineNo
+			}
+			return
+		}
+		flush(lineNo - 1)
+		runStart = 0
+	})
+	flush(len(doc.lines))
+}
+
+func lintListMarker(doc *lintDocument, options lintOptions) {
+	expected := byte(0)
+	switch options.string("style", "consistent") {
+	case "-", "dash":
+		expected = '-'
+	case "*", "asterisk":
+		expected = '*'
+	case "+", "plus":
+		expected = '+'
+	}
+
+	bullets := map[*ast.List]byte{}
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		list, ok := node.(*ast.List)
+		if !entering || !ok || list.IsOrdered() {
+			return ast.WalkContinue, nil
+		}
+		if expected == 0 {
+			expected = list.Marker
+		}
+		want := expected
+		// 与 formatBulletList 一致：相邻列表交替使用标记，否则修复后会合并
+		if previous, ok := list.PreviousSibling().(*ast.List); ok && !previous.IsOrdered() && bullets[previous] == want {
+			want = alternateBullet(want)
+		}
+		bullets[list] = want
+		if list.Marker == want {
+			return ast.WalkContinue, nil
+		}
+		for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+			_, position, ok := listItemMarker(doc.source, item, list.Marker)
+			if !ok {
+				continue
+			}
+			replacement := string(want)
+			doc.reportRange(fmt.Sprintf("List marker %q should be %q", list.Marker, want), position, position+1, &replacement)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// listItemMarker finds the marker of a list item: the first non-blank text
+// (after any blockquote markers) of the line its content starts on. It
+// returns where the marker starts (at its digits for ordered lists) and the
+// offset of the marker character itself.
+func listItemMarker(source []byte, item ast.Node, marker byte) (int, int, bool) {
+	var content ast.Node
+	for node := item.FirstChild(); node != nil; node = node.FirstChild() {
+		if node.Type() == ast.TypeBlock && node.Lines().Len() > 0 {
+			content = node
+			break
+		}
+	}
+	if content == nil {
+		return 0, 0, false
+	}
+	start := content.Lines().At(0).Start
+	lineStart := bytes.LastIndexByte(source[:start], '\n') + 1
+	delim := start - 1
+	for delim >= lineStart && (source[delim] == ' ' || source[delim] == '\t') {
+		delim--
+	}
+	if delim < lineStart || source[delim] != marker {
+		return 0, 0, false
+	}
+	begin := delim
+	if marker == '.' || marker == ')' {
+		for begin > lineStart && source[begin-1] >= '0' && source[begin-1] <= '9' {
+			begin--
+		}
+		if begin == delim {
+			return 0, 0, false
+		}
+	}
+	for _, c := range source[lineStart:begin] {
+		if c != ' ' && c != '\t' && c != '>' {
+			return 0, 0, false
+		}
+	}
+	return begin, delim, true
+}
+
+func lintLineLength(doc *lintDocument, options lintOptions) {
+	maximum := options.int("max", 120)
+	codeBlocks := options.bool("code_blocks", false)
+	tables := options.bool("tables", false)
+	headings := options.bool("headings", true)
+	doc.bodyLines(func(lineNo int, line string) {
+		if (doc.codeLines[lineNo] && !codeBlocks) || (doc.tableLines[lineNo] && !tables) ||
+			(doc.headingLines[lineNo] && !headings) || doc.htmlLines[lineNo] {
+			return
+		}
+		runes := []rune(line)
+		if len(runes) <= maximum {
+			return
+		}
+		// 超出部分没有空白（如长链接）时无法换行，不报告
+		if !strings.ContainsAny(string(runes[maximum:]), " \t") {
+			return
+		}
+		doc.report(LintDiagnostic{
+			Message: fmt.Sprintf("Line is %d characters long (maximum %d)", len(runes), maximum),
+			Line:    lineNo, Column: maximum + 1, EndLine: lineNo, EndColumn: len(runes) + 1,
+		})
+	})
+}
+
+// lintBareURLs reports URLs turned into links only by the GFM autolink
+// extension. Their position is found by searching the source after the
+// preceding text, since autolink nodes carry no segment.
+func lintBareURLs(doc *lintDocument, _ lintOptions) {
+	cursor := 0
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if node.Type() == ast.TypeBlock && node.Lines().Len() > 0 {
+			cursor = node.Lines().At(0).Start
+		}
+		switch n := node.(type) {
+		case *ast.Text:
+			cursor = n.Segment.Stop
+		case *ast.AutoLink:
+			label := n.Label(doc.source)
+			index := bytes.Index(doc.source[cursor:], label)
+			if index < 0 {
+				return ast.WalkContinue, nil
+			}
+			start := cursor + index
+			end := start + len(label)
+			cursor = end
+			if start > 0 && doc.source[start-1] == '<' {
+				return ast.WalkContinue, nil
+			}
+			var fix *string
+			if n.AutoLinkType == ast.AutoLinkEmail || bytes.Contains(label, []byte("://")) {
+				wrapped := "<" + string(label) + ">"
+				fix = &wrapped
+			}
+			doc.reportRange("Bare URL; wrap it in <> or make it a link", start, end, fix)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func wholeLine(message string, line int, text string) LintDiagnostic {
+	return LintDiagnostic{
+		Message:   message,
+		Line:      line,
+		Column:    1,
+		EndLine:   line,
+		EndColumn: utf8.RuneCountInString(text) + 1,
+	}
+}
+
+// lintOptions holds a rule's options from the configuration file.
+type lintOptions map[string]interface{}
+
+func (o lintOptions) int(name string, fallback int) int {
+	if value, ok := o[name].(int); ok {
+		return value
+	}
+	return fallback
+}
+
+func (o lintOptions) bool(name string, fallback bool) bool {
+	if value, ok := o[name].(bool); ok {
+		return value
+	}
+	return fallback
+}
+
+func (o lintOptions) string(name string, fallback string) string {
+	if value, ok := o[name].(string); ok {
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+	return fallback
+}
+
+// lineIndex converts between byte offsets and 1-based line and rune columns.
+type lineIndex struct {
+	content string
+	starts  []int
+}
+
+func newLineIndex(content string) lineIndex {
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return lineIndex{content: content, starts: starts}
+}
+
+func (x lineIndex) position(offset int) (int, int) {
+	line := sort.Search(len(x.starts), func(i int) bool { return x.starts[i] > offset }) - 1
+	if line < 0 {
+		line = 0
+	}
+	return line + 1, utf8.RuneCountInString(x.content[x.starts[line]:offset]) + 1
+}
+
+func (x lineIndex) offset(line int, column int) (int, bool) {
+	if line < 1 || line > len(x.starts) || column < 1 {
+		return 0, false
+	}
+	offset := x.starts[line-1]
+	for i := 1; i < column; i++ {
+		if offset >= len(x.content) || x.content[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(x.content[offset:])
+		offset += size
+	}
+	return offset, true
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// lintOnly runs a single rule with options.
+func lintOnly(rule string, options map[string]interface{}) LintConfig {
+	return LintConfig{Rules: map[string]LintRuleConfig{rule: {Options: options}}}
+}
+
+func TestFixDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LintConfig
+		content string
+		want    string
+	}{
+		{
+			name:    "trailing spaces removed",
+			config:  lintOnly("no-trailing-spaces", nil),
+			content: "a \nb\t\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "hard break kept",
+			config:  lintOnly("no-trailing-spaces", nil),
+			content: "line  \nnext\n",
+			want:    "line  \nnext\n",
+		},
+		{
+			name:    "extra spaces trimmed to the hard break",
+			config:  lintOnly("no-trailing-spaces", nil),
+			content: "line     \nnext\n",
+			want:    "line  \nnext\n",
+		},
+		{
+			name:    "hard break disabled",
+			config:  lintOnly("no-trailing-spaces", map[string]interface{}{"br_spaces": 0}),
+			content: "line   \nnext\n",
+			want:    "line\nnext\n",
+		},
+		{
+			name:    "trailing spaces in code kept",
+			config:  lintOnly("no-trailing-spaces", nil),
+			content: "```\ncode   \n```\n",
+			want:    "```\ncode   \n```\n",
+		},
+		{
+			name:    "list markers made consistent",
+			config:  lintOnly("list-marker", nil),
+			content: "- a\n\ntext\n\n* b\n* c\n",
+			want:    "- a\n\ntext\n\n- b\n- c\n",
+		},
+		{
+			name:    "adjacent lists alternate",
+			config:  lintOnly("list-marker", map[string]interface{}{"style": "dash"}),
+			content: "+ a\n+ b\n\n* c\n",
+			want:    "- a\n- b\n\n* c\n",
+		},
+		{
+			name:    "adjacent lists already alternating",
+			config:  lintOnly("list-marker", nil),
+			content: "- a\n\n* b\n\n- c\n",
+			want:    "- a\n\n* b\n\n- c\n",
+		},
+		{
+			name:    "multiple blanks",
+			config:  lintOnly("no-multiple-blanks", nil),
+			content: "a\n\n\n\nb\n\n\n",
+			want:    "a\n\nb\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := FixDocument(tt.content, tt.config)
+			if got != tt.want {
+				t.Errorf("FixDocument = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestListMarkerFixMatchesFormatter keeps the lint fix and the formatter
+// from disagreeing, which would make them undo each other.
+func TestListMarkerFixMatchesFormatter(t *testing.T) {
+	inputs := []string{
+		"* a\n\n+ b\n\n* c\n",
+		"- a\n- b\n\n1. one\n\n* c\n",
+		"* a\n  + nested\n",
+	}
+	options := DefaultFormatOptions()
+	for _, input := range inputs {
+		fixed, _ := FixDocument(input, lintOnly("list-marker", map[string]interface{}{"style": options.Bullet}))
+		if formatted := FormatDocument(input, options); fixed != formatted {
+			t.Errorf("input %q: lint fix %q, formatter %q", input, fixed, formatted)
+		}
+		if diagnostics := LintDocument(fixed, lintOnly("list-marker", map[string]interface{}{"style": options.Bullet})); len(diagnostics) != 0 {
+			t.Errorf("input %q: fixed text still reports %v", input, diagnostics)
+		}
+	}
+}
+
+func TestLintDiagnostics(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LintConfig
+		content string
+		want    []string
+	}{
+		{name: "heading increment", config: lintOnly("heading-increment", nil), content: "# a\n\n### c\n", want: []string{"heading-increment:3"}},
+		{name: "hard tab outside code", config: lintOnly("no-hard-tabs", nil), content: "a\tb\n\n    \tcode\n", want: []string{"no-hard-tabs:1"}},
+		{name: "disabled by default false", config: LintConfig{}, content: "# a\n\n### c\n", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, diagnostic := range LintDocument(tt.content, tt.config) {
+				got = append(got, fmt.Sprintf("%s:%d", diagnostic.Rule, diagnostic.Line))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diagnostics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLintConfig(t *testing.T) {
+	config, err := ParseLintConfig([]byte("default: false\nrules:\n  line-length:\n    severity: error\n    max: 100\n  no-bare-urls: off\n  list-marker: false\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]LintRuleConfig{
+		"line-length":  {Severity: LintError, Options: map[string]interface{}{"max": 100}},
+		"no-bare-urls": {Severity: lintOff, Options: map[string]interface{}{}},
+		"list-marker":  {Severity: lintOff, Options: map[string]interface{}{}},
+	}
+	if config.Default || !reflect.DeepEqual(config.Rules, want) {
+		t.Errorf("config = %+v", config)
+	}
+	for _, bad := range []string{"rules:\n  no-such-rule: error\n", "rules:\n  line-length: loud\n", "rules:\n  line-length: [1]\n"} {
+		if _, err := ParseLintConfig([]byte(bad)); err == nil {
+			t.Errorf("ParseLintConfig(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// TOCStartMarker and TOCEndMarker enclose the table of contents kept up to
+// date by UpdateTOC.
+const (
+	TOCStartMarker = "<!-- toc -->"
+	TOCEndMarker   = "<!-- tocstop -->"
+)
+
+var (
+	tocStartPattern = regexp.MustCompile(`(?i)^\s{0,3}<!--\s*toc\s*-->\s*$`)
+	tocEndPattern   = regexp.MustCompile(`(?i)^\s{0,3}<!--\s*(?:tocstop|/toc)\s*-->\s*$`)
+	tocEscaper      = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`)
+)
+
+// OutlineHeading is a heading of the document outline. Slug and Anchor
+// follow the preview renderer, so "#" + Anchor links to the heading there.
+type OutlineHeading struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Slug   string `json:"slug"`
+	Anchor string `json:"anchor"`
+}
+
+// TOCOptions selects the headings listed by the table of contents. They
+// come from the toc section of LintConfigName:
+//
+//	toc:
+//	  min_level: 2
+//	  max_level: 3
+type TOCOptions struct {
+	MinLevel int `yaml:"min_level"`
+	MaxLevel int `yaml:"max_level"`
+	// Bullet is the list marker, taken from the format section.
+	Bullet string `yaml:"-"`
+}
+
+// DefaultTOCOptions lists every heading.
+func DefaultTOCOptions() TOCOptions {
+	return TOCOptions{MinLevel: 1, MaxLevel: 6, Bullet: "-"}
+}
+
+// normalize fills unset options with defaults and rejects invalid levels.
+func (o TOCOptions) normalize() (TOCOptions, error) {
+	defaults := DefaultTOCOptions()
+	if o.MinLevel == 0 {
+		o.MinLevel = defaults.MinLevel
+	}
+	if o.MaxLevel == 0 {
+		o.MaxLevel = defaults.MaxLevel
+	}
+	if o.MinLevel < 1 || o.MinLevel > 6 || o.MaxLevel < 1 || o.MaxLevel > 6 {
+		return o, fmt.Errorf("toc levels must be between 1 and 6")
+	}
+	if o.MinLevel > o.MaxLevel {
+		return o, fmt.Errorf("toc min_level %d is above max_level %d", o.MinLevel, o.MaxLevel)
+	}
+	if o.Bullet == "" {
+		o.Bullet = defaults.Bullet
+	}
+	return o, nil
+}
+
+// DocumentOutline returns the ATX and setext headings of a document in
+// order, skipping code blocks and front matter.
+func DocumentOutline(content string) []OutlineHeading {
+	return parseDocument(content).outline()
+}
+
+func (d *parsedDocument) outline() []OutlineHeading {
+	headings := []OutlineHeading{}
+	_ = ast.Walk(d.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if entry, ok := d.outlineHeading(heading); ok {
+			headings = append(headings, entry)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// outlineHeading describes a heading; ok is false for empty headings.
+func (d *parsedDocument) outlineHeading(heading *ast.Heading) (OutlineHeading, bool) {
+	text := inlinePlainText(heading, d.source)
+	if text == "" {
+		return OutlineHeading{}, false
+	}
+	return OutlineHeading{
+		Level:  heading.Level,
+		Text:   text,
+		Line:   d.line(heading.Lines().At(0).Start),
+		Slug:   HeadingSlug(text),
+		Anchor: HeadingAnchorID(heading.Level, text),
+	}, true
+}
+
+// headingSource returns the Markdown text of a heading on one line.
+func (d *parsedDocument) headingSource(heading *ast.Heading) string {
+	lines := heading.Lines()
+	parts := make([]string, 0, lines.Len())
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		parts = append(parts, strings.TrimSpace(string(segment.Value(d.source))))
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// RenderTOC returns the table of contents as a nested list of links. Each
+// heading is nested one level below the closest preceding higher heading,
+// so skipped levels do not indent the list into a code block.
+func RenderTOC(headings []OutlineHeading, options TOCOptions) string {
+	options, err := options.normalize()
+	if err != nil {
+		options = DefaultTOCOptions()
+	}
+	var builder strings.Builder
+	parents := []int{}
+	for _, heading := range headings {
+		if heading.Level < options.MinLevel || heading.Level > options.MaxLevel {
+			continue
+		}
+		for len(parents) > 0 && parents[len(parents)-1] >= heading.Level {
+			parents = parents[:len(parents)-1]
+		}
+		fmt.Fprintf(&builder, "%s%s [%s](#%s)\n", strings.Repeat("  ", len(parents)), options.Bullet, tocEscaper.Replace(heading.Text), heading.Anchor)
+		parents = append(parents, heading.Level)
+	}
+	return builder.String()
+}
+
+// UpdateTOC regenerates the table of contents after the first TOCStartMarker
+// outside code, up to the TOCEndMarker that closes it (added when missing).
+// ok is false when the document has no marker.
+func UpdateTOC(content string, options TOCOptions) (updated string, ok bool) {
+	content, restore := normalizeLineEndings(content)
+	doc := parseDocument(content)
+	start, end := doc.tocBlock()
+	if start < 0 {
+		return restore(content), false
+	}
+	lines := strings.Split(content, "\n")
+	replacement := tocLines(doc, options)
+	if end < 0 {
+		// 缺少结束标记时只在开始标记后插入，不吞掉后面的内容
+		end = start + 1
+		replacement = append(replacement, TOCEndMarker)
+	}
+	lines = append(lines[:start+1], append(replacement, lines[end:]...)...)
+	return restore(strings.Join(lines, "\n")), true
+}
+
+// InsertTOC updates the table of contents of a document, or inserts a new
+// marked one before line (1-based) when there is none.
+func InsertTOC(content string, line int, options TOCOptions) string {
+	if updated, ok := UpdateTOC(content, options); ok {
+		return updated
+	}
+	content, restore := normalizeLineEndings(content)
+	doc := parseDocument(content)
+	lines := strings.Split(content, "\n")
+	if strings.HasSuffix(content, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+	if line < doc.bodyLine {
+		line = doc.bodyLine
+	}
+	// 不插入到代码块中间
+	for line <= len(lines) && doc.codeLines[line] {
+		line++
+	}
+	if line > len(lines)+1 {
+		line = len(lines) + 1
+	}
+
+	block := append([]string{TOCStartMarker}, tocLines(doc, options)...)
+	block = append(block, TOCEndMarker)
+	if line > 1 && strings.TrimSpace(lines[line-2]) != "" {
+		block = append([]string{""}, block...)
+	}
+	if line <= len(lines) && strings.TrimSpace(lines[line-1]) != "" {
+		block = append(block, "")
+	}
+	lines = append(lines[:line-1], append(block, lines[line-1:]...)...)
+	return restore(strings.Join(lines, "\n") + "\n")
+}
+
+// tocBlock returns the 0-based lines of the TOC markers, -1 when missing.
+func (d *parsedDocument) tocBlock() (int, int) {
+	start := -1
+	for i := d.bodyLine - 1; i < len(d.lines); i++ {
+		if d.codeLines[i+1] {
+			continue
+		}
+		if start < 0 && tocStartPattern.MatchString(d.lines[i]) {
+			start = i
+			continue
+		}
+		if start >= 0 {
+			if tocEndPattern.MatchString(d.lines[i]) {
+				return start, i
+			}
+			if tocStartPattern.MatchString(d.lines[i]) {
+				break
+			}
+		}
+	}
+	return start, -1
+}
+
+// tocLines returns the list between the markers, set off by blank lines.
+func tocLines(doc *parsedDocument, options TOCOptions) []string {
+	list := strings.TrimSuffix(RenderTOC(doc.outline(), options), "\n")
+	if list == "" {
+		return []string{""}
+	}
+	lines := append([]string{""}, strings.Split(list, "\n")...)
+	return append(lines, "")
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PeriodicKind names the period a periodic note covers.
+type PeriodicKind string
+
+const (
+	PeriodDaily   PeriodicKind = "daily"
+	PeriodWeekly  PeriodicKind = "weekly"
+	PeriodMonthly PeriodicKind = "monthly"
+	PeriodYearly  PeriodicKind = "yearly"
+)
+
+// PeriodicKinds lists every kind of periodic note.
+var PeriodicKinds = []PeriodicKind{PeriodDaily, PeriodWeekly, PeriodMonthly, PeriodYearly}
+
+// ErrNoPeriodicNote is returned when no note exists in the requested direction.
+var ErrNoPeriodicNote = errors.New("no periodic note found")
+
+// PeriodicNoteConfig places one kind of periodic note.
+//
+// Folder is relative to the workspace. Format names the note below Folder
+// (without extension, "/" creates subfolders) using the Go reference date
+// tokens 2006, 06, 01, 1, 02, 2, Jan, January, Mon and Monday plus GGGG for
+// the ISO week-numbering year and WW for the ISO week; text in [brackets] is
+// copied literally. Template optionally names the template new notes start from.
+type PeriodicNoteConfig struct {
+	Folder   string `json:"folder"`
+	Format   string `json:"format"`
+	Template string `json:"template,omitempty"`
+}
+
+// PeriodicSettings configures the periodic notes of every workspace. Empty
+// fields fall back to DefaultPeriodicSettings.
+type PeriodicSettings struct {
+	Daily   PeriodicNoteConfig `json:"daily"`
+	Weekly  PeriodicNoteConfig `json:"weekly"`
+	Monthly PeriodicNoteConfig `json:"monthly"`
+	Yearly  PeriodicNoteConfig `json:"yearly"`
+}
+
+// DefaultPeriodicSettings keeps journals below "journal" in the workspace.
+func DefaultPeriodicSettings() PeriodicSettings {
+	return PeriodicSettings{
+		Daily:   PeriodicNoteConfig{Folder: "journal", Format: "2006-01-02"},
+		Weekly:  PeriodicNoteConfig{Folder: "journal/weekly", Format: "GGGG-[W]WW"},
+		Monthly: PeriodicNoteConfig{Folder: "journal/monthly", Format: "2006-01"},
+		Yearly:  PeriodicNoteConfig{Folder: "journal/yearly", Format: "2006"},
+	}
+}
+
+// Config returns the configuration of kind with defaults filled in.
+func (s PeriodicSettings) Config(kind PeriodicKind) (PeriodicNoteConfig, error) {
+	defaults := DefaultPeriodicSettings()
+	var config, fallback PeriodicNoteConfig
+	switch kind {
+	case PeriodDaily:
+		config, fallback = s.Daily, defaults.Daily
+	case PeriodWeekly:
+		config, fallback = s.Weekly, defaults.Weekly
+	case PeriodMonthly:
+		config, fallback = s.Monthly, defaults.Monthly
+	case PeriodYearly:
+		config, fallback = s.Yearly, defaults.Yearly
+	default:
+		return PeriodicNoteConfig{}, fmt.Errorf("unknown periodic note kind %q", kind)
+	}
+	config.Folder = filepath.ToSlash(strings.Trim(strings.TrimSpace(config.Folder), `/\`))
+	config.Format = strings.TrimSpace(config.Format)
+	config.Template = strings.TrimSpace(config.Template)
+	if config.Folder == "" {
+		config.Folder = fallback.Folder
+	}
+	if config.Format == "" {
+		config.Format = fallback.Format
+	}
+	return config, nil
+}
+
+// Normalize returns the settings with defaults filled in.
+func (s PeriodicSettings) Normalize() PeriodicSettings {
+	s.Daily, _ = s.Config(PeriodDaily)
+	s.Weekly, _ = s.Config(PeriodWeekly)
+	s.Monthly, _ = s.Config(PeriodMonthly)
+	s.Yearly, _ = s.Config(PeriodYearly)
+	return s
+}
+
+// Validate checks that every folder stays inside the workspace and every
+// format identifies its period.
+func (s PeriodicSettings) Validate() error {
+	for _, kind := range PeriodicKinds {
+		config, err := s.Config(kind)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsLocal(filepath.FromSlash(config.Folder)) {
+			return fmt.Errorf("%s notes: folder %q must be inside the workspace", kind, config.Folder)
+		}
+		if _, err := parsePeriodFormat(kind, config.Format); err != nil {
+			return fmt.Errorf("%s notes: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// PeriodStart returns the first day of the period of kind that contains t.
+// Weeks start on Monday as in ISO 8601.
+func PeriodStart(kind PeriodicKind, t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch kind {
+	case PeriodWeekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case PeriodMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case PeriodYearly:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// PeriodicNote is an existing periodic note.
+type PeriodicNote struct {
+	Kind PeriodicKind `json:"kind"`
+	// Date is the first day of the period as YYYY-MM-DD.
+	Date  string `json:"date"`
+	Path  string `json:"path"`
+	Words int    `json:"words"`
+
+	start time.Time
+}
+
+// PeriodicCalendar lists the notes shown in a month view: the daily notes
+// of the month and the weekly notes of the weeks overlapping it.
+type PeriodicCalendar struct {
+	Year  int            `json:"year"`
+	Month int            `json:"month"`
+	Days  []PeriodicNote `json:"days"`
+	Weeks []PeriodicNote `json:"weeks"`
+	// MonthNote is the monthly note's path, empty when it does not exist.
+	MonthNote string `json:"monthNote,omitempty"`
+}
+
+// PeriodicNotes locates the periodic notes of a workspace.
+type PeriodicNotes struct {
+	files    *FileService
+	root     string
+	settings PeriodicSettings
+}
+
+// NewPeriodicNotes returns the periodic notes of the workspace at root,
+// read through files.
+func NewPeriodicNotes(files *FileService, root string, settings PeriodicSettings) *PeriodicNotes {
+	return &PeriodicNotes{files: files, root: filepath.Clean(root), settings: settings.Normalize()}
+}
+
+// Path returns the note of kind covering t, whether or not it exists.
+func (p *PeriodicNotes) Path(kind PeriodicKind, t time.Time) (string, error) {
+	config, format, err := p.format(kind)
+	if err != nil {
+		return "", err
+	}
+	name := format.render(PeriodStart(kind, t))
+	return filepath.Join(p.root, filepath.FromSlash(config.Folder), filepath.FromSlash(name)+".md"), nil
+}
+
+// Title is the name of the note of kind covering t, used as its heading.
+func (p *PeriodicNotes) Title(kind PeriodicKind, t time.Time) (string, error) {
+	_, format, err := p.format(kind)
+	if err != nil {
+		return "", err
+	}
+	return pathBase(format.render(PeriodStart(kind, t))), nil
+}
+
+// Identify reports which periodic note path is and the start of its period.
+func (p *PeriodicNotes) Identify(path string) (PeriodicKind, time.Time, bool) {
+	rel, err := filepath.Rel(p.root, filepath.Clean(path))
+	if err != nil || !filepath.IsLocal(rel) || !IsMarkdownFile(rel) {
+		return "", time.Time{}, false
+	}
+	rel = filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+	for _, kind := range PeriodicKinds {
+		config, format, err := p.format(kind)
+		if err != nil || !strings.HasPrefix(rel, config.Folder+"/") {
+			continue
+		}
+		if start, ok := format.parse(kind, strings.TrimPrefix(rel, config.Folder+"/")); ok {
+			return kind, start, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// Notes lists the existing notes of kind ordered by date.
+func (p *PeriodicNotes) Notes(kind PeriodicKind) ([]PeriodicNote, error) {
+	config, format, err := p.format(kind)
+	if err != nil {
+		return nil, err
+	}
+	folder := filepath.Join(p.root, filepath.FromSlash(config.Folder))
+
+	notes := []PeriodicNote{}
+	err = p.files.WalkWorkspaceFiles(folder, true, func(path string, _ fs.FileInfo) error {
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+		if start, ok := format.parse(kind, rel); ok {
+			notes = append(notes, PeriodicNote{Kind: kind, Date: start.Format("2006-01-02"), Path: path, start: start})
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return notes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].start.Before(notes[j].start) })
+	return notes, nil
+}
+
+// Adjacent returns the existing note of the same kind closest to path in the
+// direction of offset (negative for earlier notes), skipping missing periods.
+func (p *PeriodicNotes) Adjacent(path string, offset int) (PeriodicNote, error) {
+	kind, start, ok := p.Identify(path)
+	if !ok {
+		return PeriodicNote{}, errors.New("not a periodic note")
+	}
+	if offset == 0 {
+		return PeriodicNote{}, errors.New("offset must not be zero")
+	}
+	notes, err := p.Notes(kind)
+	if err != nil {
+		return PeriodicNote{}, err
+	}
+
+	index := sort.Search(len(notes), func(i int) bool { return !notes[i].start.Before(start) })
+	if offset > 0 {
+		// 当前笔记可能已存在，跳过同一周期
+		if index < len(notes) && notes[index].start.Equal(start) {
+			index++
+		}
+		index += offset - 1
+	} else {
+		index += offset
+	}
+	if index < 0 || index >= len(notes) {
+		return PeriodicNote{}, fmt.Errorf("%w in that direction", ErrNoPeriodicNote)
+	}
+	return notes[index], nil
+}
+
+// Calendar returns the notes of the month with their word counts.
+func (p *PeriodicNotes) Calendar(year int, month time.Month) (PeriodicCalendar, error) {
+	if month < time.January || month > time.December {
+		return PeriodicCalendar{}, fmt.Errorf("invalid month %d", month)
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+	next := first.AddDate(0, 1, 0)
+	calendar := PeriodicCalendar{Year: year, Month: int(month), Days: []PeriodicNote{}, Weeks: []PeriodicNote{}}
+
+	days, err := p.Notes(PeriodDaily)
+	if err != nil {
+		return calendar, err
+	}
+	for _, note := range days {
+		if !note.start.Before(first) && note.start.Before(next) {
+			calendar.Days = append(calendar.Days, p.withWordCount(note))
+		}
+	}
+
+	weeks, err := p.Notes(PeriodWeekly)
+	if err != nil {
+		return calendar, err
+	}
+	for _, note := range weeks {
+		if note.start.Before(next) && note.start.AddDate(0, 0, 7).After(first) {
+			calendar.Weeks = append(calendar.Weeks, p.withWordCount(note))
+		}
+	}
+
+	monthPath, err := p.Path(PeriodMonthly, first)
+	if err != nil {
+		return calendar, err
+	}
+	if info, err := p.files.Lstat(monthPath); err == nil && info.Mode().IsRegular() {
+		calendar.MonthNote = monthPath
+	}
+	return calendar, nil
+}
+
+func (p *PeriodicNotes) format(kind PeriodicKind) (PeriodicNoteConfig, periodFormat, error) {
+	config, err := p.settings.Config(kind)
+	if err != nil {
+		return config, nil, err
+	}
+	format, err := parsePeriodFormat(kind, config.Format)
+	return config, format, err
+}
+
+func (p *PeriodicNotes) withWordCount(note PeriodicNote) PeriodicNote {
+	if data, err := p.files.ReadBytes(note.Path); err == nil {
+		note.Words = DocumentStatistics(string(data)).Words
+	}
+	return note
+}
+
+func pathBase(name string) string {
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		return name[index+1:]
+	}
+	return name
+}
+
+// periodToken is one element of a parsed format: a date token or literal text.
+type periodToken struct {
+	token   string
+	literal string
+}
+
+type periodFormat []periodToken
+
+// 按长度排列，保证 "January" 先于 "Jan"、"2006" 先于 "2"
+var periodFormatTokens = []string{"January", "Monday", "GGGG", "2006", "Jan", "Mon", "WW", "01", "02", "06", "1", "2"}
+
+func parsePeriodFormat(kind PeriodicKind, format string) (periodFormat, error) {
+	var tokens periodFormat
+	for rest := format; rest != ""; {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("format %q: unclosed [", format)
+			}
+			tokens = append(tokens, periodToken{literal: rest[1:end]})
+			rest = rest[end+1:]
+			continue
+		}
+		matched := false
+		for _, token := range periodFormatTokens {
+			if strings.HasPrefix(rest, token) {
+				tokens = append(tokens, periodToken{token: token})
+				rest = rest[len(token):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			tokens = append(tokens, periodToken{literal: rest[:1]})
+			rest = rest[1:]
+		}
+	}
+
+	has := func(names ...string) bool {
+		for _, t := range tokens {
+			for _, name := range names {
+				if t.token == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	year := has("2006", "06")
+	month := has("01", "1", "Jan", "January")
+	var ok bool
+	var period string
+	switch kind {
+	case PeriodDaily:
+		ok, period = year && month && has("02", "2"), "day"
+	case PeriodWeekly:
+		ok, period = has("GGGG") && has("WW"), "week"
+	case PeriodMonthly:
+		ok, period = year && month, "month"
+	case PeriodYearly:
+		ok, period = year, "year"
+	}
+	if !ok {
+		return nil, fmt.Errorf("format %q does not identify a %s", format, period)
+	}
+
+	name := tokens.render(time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC))
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "\\\x00") {
+			return nil, fmt.Errorf("format %q does not produce a valid file name", format)
+		}
+	}
+	return tokens, nil
+}
+
+func (f periodFormat) render(t time.Time) string {
+	isoYear, week := t.ISOWeek()
+	var b strings.Builder
+	for _, token := range f {
+		switch token.token {
+		case "":
+			b.WriteString(token.literal)
+		case "GGGG":
+			fmt.Fprintf(&b, "%04d", isoYear)
+		case "WW":
+			fmt.Fprintf(&b, "%02d", week)
+		default:
+			b.WriteString(t.Format(token.token))
+		}
+	}
+	return b.String()
+}
+
+// parse recovers the period start from a rendered name. Names that do not
+// render back to themselves (e.g. 2026-02-30) are rejected.
+func (f periodFormat) parse(kind PeriodicKind, name string) (time.Time, bool) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, token := range f {
+		switch token.token {
+		case "":
+			pattern.WriteString(regexp.QuoteMeta(token.literal))
+		case "GGGG", "2006":
+			pattern.WriteString(`(\d{4})`)
+		case "WW", "01", "02", "06":
+			pattern.WriteString(`(\d{2})`)
+		case "1", "2":
+			pattern.WriteString(`(\d{1,2})`)
+		default:
+			pattern.WriteString(`([A-Za-z]+)`)
+		}
+	}
+	pattern.WriteString("$")
+	match := regexp.MustCompile(pattern.String()).FindStringSubmatch(name)
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	year, month, day, isoYear, week := 0, 1, 1, 0, 1
+	group := 1
+	for _, token := range f {
+		if token.token == "" {
+			continue
+		}
+		value := match[group]
+		group++
+		number, _ := strconv.Atoi(value)
+		switch token.token {
+		case "2006":
+			year = number
+		case "06":
+			year = 2000 + number
+			if number >= 69 {
+				year = 1900 + number
+			}
+		case "01", "1":
+			month = number
+		case "Jan", "January":
+			parsed, err := time.Parse(token.token, value)
+			if err != nil {
+				return time.Time{}, false
+			}
+			month = int(parsed.Month())
+		case "02", "2":
+			day = number
+		case "GGGG":
+			isoYear = number
+		case "WW":
+			week = number
+		}
+	}
+
+	var start time.Time
+	if kind == PeriodWeekly {
+		// 1 月 4 日总在第 1 周内
+		jan4 := time.Date(isoYear, time.January, 4, 0, 0, 0, 0, time.Local)
+		start = PeriodStart(PeriodWeekly, jan4).AddDate(0, 0, 7*(week-1))
+	} else {
+		start = PeriodStart(kind, time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local))
+	}
+	if f.render(start) != name {
+		return time.Time{}, false
+	}
+	return start, true
+}
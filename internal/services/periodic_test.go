@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.Local)
+}
+
+func TestPeriodFormatRender(t *testing.T) {
+	tests := []struct {
+		kind   PeriodicKind
+		format string
+		date   time.Time
+		want   string
+	}{
+		{kind: PeriodDaily, format: "2006-01-02", date: day(2026, time.October, 19), want: "2026-10-19"},
+		{kind: PeriodDaily, format: "2006/01 January/2006-01-02 Monday", date: day(2026, time.October, 19), want: "2026/10 October/2026-10-19 Monday"},
+		{kind: PeriodDaily, format: "[Day] 2 Jan 06, Mon", date: day(2026, time.March, 5), want: "Day 5 Mar 26, Thu"},
+		{kind: PeriodDaily, format: "1-2-2006", date: day(2026, time.March, 5), want: "3-5-2026"},
+		// ISO 周年：第 1 周包含 1 月 4 日，年末几天可能属于下一年的第 1 周
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", date: day(2026, time.January, 1), want: "2026-W01"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", date: day(2024, time.December, 30), want: "2025-W01"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", date: day(2026, time.December, 31), want: "2026-W53"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", date: day(2027, time.January, 3), want: "2026-W53"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", date: day(2027, time.January, 4), want: "2027-W01"},
+		{kind: PeriodWeekly, format: "GGGG/[Week] WW", date: day(2021, time.January, 1), want: "2020/Week 53"},
+		{kind: PeriodMonthly, format: "2006-01", date: day(2026, time.October, 19), want: "2026-10"},
+		{kind: PeriodMonthly, format: "2006/January", date: day(2026, time.October, 19), want: "2026/October"},
+		{kind: PeriodYearly, format: "2006", date: day(2026, time.October, 19), want: "2026"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.want, func(t *testing.T) {
+			format, err := parsePeriodFormat(tt.kind, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := format.render(tt.date); got != tt.want {
+				t.Errorf("render = %q, want %q", got, tt.want)
+			}
+			start, ok := format.parse(tt.kind, tt.want)
+			if want := PeriodStart(tt.kind, tt.date); !ok || !start.Equal(want) {
+				t.Errorf("parse(%q) = %v, %v, want %v", tt.want, start, ok, want)
+			}
+		})
+	}
+}
+
+func TestPeriodFormatParseRejects(t *testing.T) {
+	tests := []struct {
+		kind   PeriodicKind
+		format string
+		name   string
+	}{
+		{kind: PeriodDaily, format: "2006-01-02", name: "2026-02-30"},
+		{kind: PeriodDaily, format: "2006-01-02", name: "2026-13-01"},
+		{kind: PeriodDaily, format: "2006-01-02", name: "2026-1-05"},
+		{kind: PeriodDaily, format: "2006-01-02", name: "2026-10-19 notes"},
+		{kind: PeriodDaily, format: "2 Jan 2006", name: "05 Jan 2026"},
+		{kind: PeriodDaily, format: "2 Jan 2006", name: "5 Foo 2026"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", name: "2026-W00"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", name: "2026-W54"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", name: "2025-W53"},
+		{kind: PeriodWeekly, format: "GGGG-[W]WW", name: "2026-42"},
+		{kind: PeriodMonthly, format: "2006-01", name: "2026-00"},
+	}
+	for _, tt := range tests {
+		format, err := parsePeriodFormat(tt.kind, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := format.parse(tt.kind, tt.name); ok {
+			t.Errorf("%s parsed %q as %v", tt.format, tt.name, start)
+		}
+	}
+}
+
+func TestPeriodicSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings PeriodicSettings
+		want     string
+	}{
+		{name: "defaults", settings: PeriodicSettings{}},
+		{name: "daily without day", settings: PeriodicSettings{Daily: PeriodicNoteConfig{Format: "2006-01"}}, want: `daily notes: format "2006-01" does not identify a day`},
+		{name: "weekly without ISO year", settings: PeriodicSettings{Weekly: PeriodicNoteConfig{Format: "2006-[W]WW"}}, want: "does not identify a week"},
+		{name: "unclosed literal", settings: PeriodicSettings{Monthly: PeriodicNoteConfig{Format: "2006-01 [month"}}, want: "unclosed ["},
+		{name: "parent folder in format", settings: PeriodicSettings{Yearly: PeriodicNoteConfig{Format: "../2006"}}, want: "does not produce a valid file name"},
+		{name: "folder outside the workspace", settings: PeriodicSettings{Daily: PeriodicNoteConfig{Folder: "../journal"}}, want: "must be inside the workspace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodicNotesPaths(t *testing.T) {
+	root := t.TempDir()
+	notes := NewPeriodicNotes(NewFileService(), root, PeriodicSettings{})
+	tests := []struct {
+		kind  PeriodicKind
+		date  time.Time
+		want  string
+		title string
+	}{
+		{kind: PeriodDaily, date: day(2026, time.October, 19).Add(15 * time.Hour), want: "journal/2026-10-19.md", title: "2026-10-19"},
+		{kind: PeriodWeekly, date: day(2027, time.January, 2), want: "journal/weekly/2026-W53.md", title: "2026-W53"},
+		{kind: PeriodMonthly, date: day(2026, time.October, 19), want: "journal/monthly/2026-10.md", title: "2026-10"},
+		{kind: PeriodYearly, date: day(2026, time.October, 19), want: "journal/yearly/2026.md", title: "2026"},
+	}
+	for _, tt := range tests {
+		path, err := notes.Path(tt.kind, tt.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); path != want {
+			t.Errorf("Path(%s) = %s, want %s", tt.kind, path, want)
+		}
+		if title, err := notes.Title(tt.kind, tt.date); err != nil || title != tt.title {
+			t.Errorf("Title(%s) = %q, %v, want %q", tt.kind, title, err, tt.title)
+		}
+		kind, start, ok := notes.Identify(path)
+		if !ok || kind != tt.kind || !start.Equal(PeriodStart(tt.kind, tt.date)) {
+			t.Errorf("Identify(%s) = %s, %v, %v", tt.want, kind, start, ok)
+		}
+	}
+	for _, rel := range []string{"journal/2026-02-30.md", "journal/weekly/2026-10-19.md", "notes/2026-10-19.md", "journal/2026-10-19.txt"} {
+		if kind, _, ok := notes.Identify(filepath.Join(root, filepath.FromSlash(rel))); ok {
+			t.Errorf("Identify(%s) = %s", rel, kind)
+		}
+	}
+}
+
+func TestPeriodicNotesAdjacentAndCalendar(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"journal/2026-10-17.md":       "a",
+		"journal/2026-10-19.md":       "one two three",
+		"journal/2026-10-25.md":       "b",
+		"journal/2026-11-01.md":       "c",
+		"journal/2026-02-30.md":       "not a date",
+		"journal/ideas.md":            "not periodic",
+		"journal/weekly/2026-W39.md":  "",
+		"journal/weekly/2026-W40.md":  "",
+		"journal/weekly/2026-W42.md":  "four five",
+		"journal/weekly/2026-W44.md":  "",
+		"journal/monthly/2026-10.md":  "",
+		"journal/yearly/2026.md":      "",
+		"journal/monthly/2026-11.md/": "",
+	})
+	notes := NewPeriodicNotes(NewFileService(), root, PeriodicSettings{})
+	daily := func(date string) string {
+		return filepath.Join(root, "journal", date+".md")
+	}
+
+	tests := []struct {
+		from   string
+		offset int
+		want   string
+	}{
+		{from: "2026-10-19", offset: 1, want: "2026-10-25"},
+		{from: "2026-10-19", offset: -1, want: "2026-10-17"},
+		{from: "2026-10-19", offset: 2, want: "2026-11-01"},
+		{from: "2026-10-19", offset: 3},
+		{from: "2026-10-17", offset: -1},
+		// 当前周期还没有笔记时，从它的位置向两侧查找
+		{from: "2026-10-20", offset: 1, want: "2026-10-25"},
+		{from: "2026-10-20", offset: -1, want: "2026-10-19"},
+		{from: "2026-12-01", offset: -2, want: "2026-10-25"},
+	}
+	for _, tt := range tests {
+		note, err := notes.Adjacent(daily(tt.from), tt.offset)
+		if tt.want == "" {
+			if !errors.Is(err, ErrNoPeriodicNote) {
+				t.Errorf("Adjacent(%s, %d) = %+v, %v, want ErrNoPeriodicNote", tt.from, tt.offset, note, err)
+			}
+			continue
+		}
+		if err != nil || note.Date != tt.want || note.Path != daily(tt.want) || note.Kind != PeriodDaily {
+			t.Errorf("Adjacent(%s, %d) = %+v, %v, want %s", tt.from, tt.offset, note, err, tt.want)
+		}
+	}
+	weekly := filepath.Join(root, "journal", "weekly", "2026-W42.md")
+	if note, err := notes.Adjacent(weekly, -1); err != nil || note.Date != "2026-09-28" {
+		t.Errorf("previous week = %+v, %v", note, err)
+	}
+	if _, err := notes.Adjacent(daily("2026-10-19"), 0); err == nil {
+		t.Error("zero offset accepted")
+	}
+	if _, err := notes.Adjacent(filepath.Join(root, "journal", "ideas.md"), 1); err == nil {
+		t.Error("non-periodic note accepted")
+	}
+
+	calendar, err := notes.Calendar(2026, time.October)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summarize := func(list []PeriodicNote) []string {
+		summary := []string{}
+		for _, note := range list {
+			summary = append(summary, note.Date+" "+strings.Repeat("w", note.Words))
+		}
+		return summary
+	}
+	if got, want := summarize(calendar.Days), []string{"2026-10-17 w", "2026-10-19 www", "2026-10-25 w"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("October days = %q, want %q", got, want)
+	}
+	if got, want := summarize(calendar.Weeks), []string{"2026-09-28 ", "2026-10-12 ww", "2026-10-26 "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("October weeks = %q, want %q", got, want)
+	}
+	if want := filepath.Join(root, "journal", "monthly", "2026-10.md"); calendar.Year != 2026 || calendar.Month != 10 || calendar.MonthNote != want {
+		t.Errorf("October = %d-%d, month note %q", calendar.Year, calendar.Month, calendar.MonthNote)
+	}
+
+	calendar, err = notes.Calendar(2026, time.November)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summarize(calendar.Days), []string{"2026-11-01 w"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("November days = %q, want %q", got, want)
+	}
+	if got, want := summarize(calendar.Weeks), []string{"2026-10-26 "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("November weeks = %q, want %q", got, want)
+	}
+	if calendar.MonthNote != "" {
+		t.Errorf("November month note = %q", calendar.MonthNote)
+	}
+
+	if _, err := notes.Calendar(2026, 13); err == nil {
+		t.Error("month 13 accepted")
+	}
+	if calendar, err := NewPeriodicNotes(NewFileService(), t.TempDir(), PeriodicSettings{}).Calendar(2026, time.October); err != nil || len(calendar.Days) != 0 {
+		t.Errorf("empty workspace = %+v, %v", calendar, err)
+	}
+}
@@ -0,0 +1,968 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// QueryResult is the tabular output of a note query.
+type QueryResult struct {
+	Columns []string   `json:"columns"`
+	Rows    []QueryRow `json:"rows"`
+}
+
+// QueryRow is one note matched by a query together with its projected values.
+type QueryRow struct {
+	Path   string        `json:"path"`
+	Values []interface{} `json:"values"`
+}
+
+// Query is a parsed note query, e.g.
+//
+//	TABLE status, due FROM "meetings" AND #project WHERE status = "open" SORT date DESC LIMIT 20
+type Query struct {
+	columns []queryColumn
+	source  querySource
+	where   queryExpr
+	sorts   []querySort
+	limit   int
+}
+
+type queryColumn struct {
+	label string
+	expr  queryExpr
+}
+
+type querySort struct {
+	expr       queryExpr
+	descending bool
+}
+
+// ParseQuery compiles the query language into an executable Query.
+func ParseQuery(input string) (*Query, error) {
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// RunQuery parses and evaluates a query against the given notes.
+func RunQuery(input string, notes []NoteMeta) (QueryResult, error) {
+	query, err := ParseQuery(input)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	return query.Execute(notes, time.Now()), nil
+}
+
+// Execute evaluates the query against the notes. now anchors `today` and `now`.
+func (q *Query) Execute(notes []NoteMeta, now time.Time) QueryResult {
+	env := queryEnv{now: now}
+	matched := make([]NoteMeta, 0, len(notes))
+	for _, note := range notes {
+		if q.source != nil && !q.source.matches(note) {
+			continue
+		}
+		if q.where != nil && !truthy(q.where.eval(note, env)) {
+			continue
+		}
+		matched = append(matched, note)
+	}
+
+	if len(q.sorts) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool {
+			for _, s := range q.sorts {
+				cmp := compareValues(s.expr.eval(matched[i], env), s.expr.eval(matched[j], env))
+				if cmp == 0 {
+					continue
+				}
+				if s.descending {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	if q.limit > 0 && len(matched) > q.limit {
+		matched = matched[:q.limit]
+	}
+
+	columns := q.columns
+	if len(columns) == 0 {
+		columns = []queryColumn{{label: "File", expr: fieldExpr{name: "file.link"}}}
+	}
+
+	result := QueryResult{
+		Columns: make([]string, 0, len(columns)),
+		Rows:    make([]QueryRow, 0, len(matched)),
+	}
+	for _, column := range columns {
+		result.Columns = append(result.Columns, column.label)
+	}
+	for _, note := range matched {
+		row := QueryRow{Path: note.Path, Values: make([]interface{}, 0, len(columns))}
+		for _, column := range columns {
+			row.Values = append(row.Values, displayValue(column.expr.eval(note, env)))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+// ---- sources ----
+
+type querySource interface {
+	matches(note NoteMeta) bool
+}
+
+type folderSource struct{ folder string }
+
+func (s folderSource) matches(note NoteMeta) bool {
+	folder := strings.Trim(s.folder, "/")
+	if folder == "" {
+		return true
+	}
+	rel := note.RelPath
+	return rel == folder || strings.HasPrefix(rel, folder+"/") ||
+		strings.TrimSuffix(rel, path.Ext(rel)) == folder
+}
+
+type tagSource struct{ tag string }
+
+func (s tagSource) matches(note NoteMeta) bool {
+	return hasTag(note.Tags, s.tag)
+}
+
+type notSource struct{ inner querySource }
+
+func (s notSource) matches(note NoteMeta) bool { return !s.inner.matches(note) }
+
+type binarySource struct {
+	and         bool
+	left, right querySource
+}
+
+func (s binarySource) matches(note NoteMeta) bool {
+	if s.and {
+		return s.left.matches(note) && s.right.matches(note)
+	}
+	return s.left.matches(note) || s.right.matches(note)
+}
+
+func hasTag(tags []string, wanted string) bool {
+	wanted = strings.ToLower(strings.TrimPrefix(wanted, "#"))
+	for _, tag := range tags {
+		// 嵌套标签：#project 同时匹配 #project/alpha
+		if tag == wanted || strings.HasPrefix(tag, wanted+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ---- expressions ----
+
+type queryEnv struct {
+	now time.Time
+}
+
+type queryExpr interface {
+	eval(note NoteMeta, env queryEnv) interface{}
+}
+
+type literalExpr struct{ value interface{} }
+
+func (e literalExpr) eval(NoteMeta, queryEnv) interface{} { return e.value }
+
+type keywordExpr struct{ name string }
+
+func (e keywordExpr) eval(_ NoteMeta, env queryEnv) interface{} {
+	if e.name == "today" {
+		y, m, d := env.now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, env.now.Location())
+	}
+	return env.now
+}
+
+type fieldExpr struct{ name string }
+
+func (e fieldExpr) eval(note NoteMeta, _ queryEnv) interface{} {
+	return lookupField(note, e.name)
+}
+
+type notExpr struct{ inner queryExpr }
+
+func (e notExpr) eval(note NoteMeta, env queryEnv) interface{} {
+	return !truthy(e.inner.eval(note, env))
+}
+
+type logicalExpr struct {
+	and         bool
+	left, right queryExpr
+}
+
+func (e logicalExpr) eval(note NoteMeta, env queryEnv) interface{} {
+	left := truthy(e.left.eval(note, env))
+	if e.and {
+		return left && truthy(e.right.eval(note, env))
+	}
+	return left || truthy(e.right.eval(note, env))
+}
+
+type compareExpr struct {
+	op          string
+	left, right queryExpr
+}
+
+func (e compareExpr) eval(note NoteMeta, env queryEnv) interface{} {
+	left := e.left.eval(note, env)
+	right := e.right.eval(note, env)
+
+	switch e.op {
+	case "contains":
+		return containsValue(left, right)
+	case "!contains":
+		return !containsValue(left, right)
+	case "=":
+		return equalValues(left, right)
+	case "!=":
+		return !equalValues(left, right)
+	}
+
+	if left == nil || right == nil {
+		return false
+	}
+	cmp := compareValues(left, right)
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func lookupField(note NoteMeta, name string) interface{} {
+	switch strings.ToLower(name) {
+	case "file.path":
+		return note.RelPath
+	case "file.name":
+		return note.Name
+	case "file.link":
+		return note.Name
+	case "file.folder":
+		folder := path.Dir(note.RelPath)
+		if folder == "." {
+			return ""
+		}
+		return folder
+	case "file.ext":
+		return strings.TrimPrefix(path.Ext(note.RelPath), ".")
+	case "file.size":
+		return float64(note.Size)
+	case "file.mtime":
+		return note.ModTime
+	case "file.tags", "tags":
+		tags := make([]interface{}, 0, len(note.Tags))
+		for _, tag := range note.Tags {
+			tags = append(tags, tag)
+		}
+		return tags
+	}
+
+	var current interface{} = note.FrontMatter
+	for _, part := range strings.Split(name, ".") {
+		fields, ok := asStringMap(current)
+		if !ok {
+			return nil
+		}
+		value, found := fields[part]
+		if !found {
+			for key, candidate := range fields {
+				if strings.EqualFold(key, part) {
+					value, found = candidate, true
+					break
+				}
+			}
+		}
+		if !found {
+			return nil
+		}
+		current = value
+	}
+	return normalizeValue(current)
+}
+
+func asStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return typed, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			converted[fmt.Sprint(key)] = item
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed)
+	case int64:
+		return float64(typed)
+	case uint64:
+		return float64(typed)
+	case float32:
+		return float64(typed)
+	case []string:
+		items := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, item)
+		}
+		return items
+	}
+	return value
+}
+
+var queryDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02",
+}
+
+// ParseLooseDate parses the date formats commonly found in front matter.
+func ParseLooseDate(value string) (time.Time, bool) {
+	trimmed := strings.TrimSpace(value)
+	if len(trimmed) < 8 || trimmed[0] < '0' || trimmed[0] > '9' {
+		return time.Time{}, false
+	}
+	for _, layout := range queryDateLayouts {
+		if parsed, err := time.ParseInLocation(layout, trimmed, time.Local); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func truthy(value interface{}) bool {
+	switch typed := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return typed
+	case string:
+		return typed != ""
+	case float64:
+		return typed != 0
+	case []interface{}:
+		return len(typed) > 0
+	case time.Time:
+		return !typed.IsZero()
+	}
+	return true
+}
+
+func equalValues(left, right interface{}) bool {
+	if list, ok := left.([]interface{}); ok {
+		if _, rightIsList := right.([]interface{}); !rightIsList {
+			return containsValue(list, right)
+		}
+	}
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	return compareValues(left, right) == 0
+}
+
+func containsValue(haystack, needle interface{}) bool {
+	switch typed := haystack.(type) {
+	case []interface{}:
+		for _, item := range typed {
+			if s, ok := item.(string); ok {
+				if n, ok := needle.(string); ok && strings.EqualFold(s, strings.TrimPrefix(n, "#")) {
+					return true
+				}
+			}
+			if item != nil && needle != nil && compareValues(item, needle) == 0 {
+				return true
+			}
+		}
+		return false
+	case string:
+		n, ok := needle.(string)
+		return ok && strings.Contains(strings.ToLower(typed), strings.ToLower(n))
+	}
+	return false
+}
+
+// compareValues orders two values; nil sorts after everything else.
+func compareValues(left, right interface{}) int {
+	if left == nil || right == nil {
+		switch {
+		case left == nil && right == nil:
+			return 0
+		case left == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	if lt, ok := asTime(left); ok {
+		if rt, ok := asTime(right); ok {
+			switch {
+			case lt.Before(rt):
+				return -1
+			case lt.After(rt):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if lf, ok := asNumber(left); ok {
+		if rf, ok := asNumber(right); ok {
+			switch {
+			case lf < rf:
+				return -1
+			case lf > rf:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if lb, ok := left.(bool); ok {
+		if rb, ok := right.(bool); ok {
+			switch {
+			case lb == rb:
+				return 0
+			case !lb:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+
+	return strings.Compare(strings.ToLower(displayString(left)), strings.ToLower(displayString(right)))
+}
+
+func asTime(value interface{}) (time.Time, bool) {
+	switch typed := value.(type) {
+	case time.Time:
+		return typed, true
+	case string:
+		return ParseLooseDate(typed)
+	}
+	return time.Time{}, false
+}
+
+func asNumber(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case float64:
+		return typed, true
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+func displayString(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case time.Time:
+		if typed.Hour() == 0 && typed.Minute() == 0 && typed.Second() == 0 {
+			return typed.Format("2006-01-02")
+		}
+		return typed.Format("2006-01-02 15:04")
+	case float64:
+		if typed == math.Trunc(typed) && math.Abs(typed) < 1e15 {
+			return strconv.FormatInt(int64(typed), 10)
+		}
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(typed))
+		for _, item := range typed {
+			parts = append(parts, displayString(item))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// displayValue converts evaluated values into JSON-friendly cell values.
+func displayValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case nil, bool, float64, string:
+		return typed
+	case []interface{}:
+		items := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, displayValue(item))
+		}
+		return items
+	}
+	return displayString(value)
+}
+
+// ---- lexer ----
+
+type queryTokenKind int
+
+const (
+	tokenEOF queryTokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenTag
+	tokenOperator
+	tokenComma
+	tokenLParen
+	tokenRParen
+	tokenMinus
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	text  string
+	pos   int
+	upper string
+}
+
+func lexQuery(input string) ([]queryToken, error) {
+	runes := []rune(input)
+	tokens := []queryToken{}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			quote := r
+			var builder strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != quote; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				builder.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("query: unterminated string at %d", i)
+			}
+			tokens = append(tokens, queryToken{kind: tokenString, text: builder.String(), pos: i})
+			i = j + 1
+		case r == '#':
+			j := i + 1
+			for j < len(runes) && isQueryIdentRune(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("query: empty tag at %d", i)
+			}
+			tokens = append(tokens, queryToken{kind: tokenTag, text: string(runes[i+1 : j]), pos: i})
+			i = j
+		case r == ',':
+			tokens = append(tokens, queryToken{kind: tokenComma, text: ",", pos: i})
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case r == '=' || r == '<' || r == '>' || r == '!':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				tokens = append(tokens, queryToken{kind: tokenOperator, text: "!", pos: i})
+				i++
+				continue
+			}
+			tokens = append(tokens, queryToken{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		case r == '-' && (i+1 >= len(runes) || !unicode.IsDigit(runes[i+1])):
+			tokens = append(tokens, queryToken{kind: tokenMinus, text: "-", pos: i})
+			i++
+		case unicode.IsDigit(r) || r == '-':
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: tokenNumber, text: string(runes[i:j]), pos: i})
+			i = j
+		case isQueryIdentRune(r):
+			j := i
+			for j < len(runes) && (isQueryIdentRune(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			text := string(runes[i:j])
+			tokens = append(tokens, queryToken{kind: tokenIdent, text: text, upper: strings.ToUpper(text), pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("query: unexpected character %q at %d", r, i)
+		}
+	}
+
+	return append(tokens, queryToken{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+func isQueryIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '/' || r == '-'
+}
+
+// ---- parser ----
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken { return p.tokens[p.pos] }
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *queryParser) isKeyword(words ...string) bool {
+	token := p.peek()
+	if token.kind != tokenIdent {
+		return false
+	}
+	for _, word := range words {
+		if token.upper == word {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("query: %s (at %d)", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *queryParser) parseQuery() (*Query, error) {
+	query := &Query{}
+
+	if p.isKeyword("TABLE") {
+		p.next()
+		columns, err := p.parseColumns()
+		if err != nil {
+			return nil, err
+		}
+		query.columns = columns
+	} else if p.isKeyword("LIST") {
+		p.next()
+	}
+
+	for p.peek().kind != tokenEOF {
+		switch {
+		case p.isKeyword("FROM"):
+			p.next()
+			source, err := p.parseSourceOr()
+			if err != nil {
+				return nil, err
+			}
+			query.source = source
+		case p.isKeyword("WHERE"):
+			p.next()
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			query.where = expr
+		case p.isKeyword("SORT"):
+			p.next()
+			sorts, err := p.parseSorts()
+			if err != nil {
+				return nil, err
+			}
+			query.sorts = append(query.sorts, sorts...)
+		case p.isKeyword("LIMIT"):
+			p.next()
+			token := p.next()
+			limit, err := strconv.Atoi(token.text)
+			if token.kind != tokenNumber || err != nil || limit < 0 {
+				return nil, p.errorf("LIMIT expects a positive number")
+			}
+			query.limit = limit
+		default:
+			return nil, p.errorf("unexpected %q", p.peek().text)
+		}
+	}
+
+	return query, nil
+}
+
+func (p *queryParser) parseColumns() ([]queryColumn, error) {
+	columns := []queryColumn{}
+	if p.isKeyword("FROM", "WHERE", "SORT", "LIMIT") || p.peek().kind == tokenEOF {
+		return columns, nil
+	}
+
+	columns = append(columns, queryColumn{label: "File", expr: fieldExpr{name: "file.link"}})
+	for {
+		start := p.peek()
+		expr, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		label := start.text
+		if p.isKeyword("AS") {
+			p.next()
+			alias := p.next()
+			if alias.kind != tokenString && alias.kind != tokenIdent {
+				return nil, p.errorf("AS expects a column name")
+			}
+			label = alias.text
+		}
+		columns = append(columns, queryColumn{label: label, expr: expr})
+
+		if p.peek().kind != tokenComma {
+			return columns, nil
+		}
+		p.next()
+	}
+}
+
+func (p *queryParser) parseSorts() ([]querySort, error) {
+	sorts := []querySort{}
+	for {
+		expr, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		entry := querySort{expr: expr}
+		if p.isKeyword("DESC", "DESCENDING") {
+			p.next()
+			entry.descending = true
+		} else if p.isKeyword("ASC", "ASCENDING") {
+			p.next()
+		}
+		sorts = append(sorts, entry)
+
+		if p.peek().kind != tokenComma {
+			return sorts, nil
+		}
+		p.next()
+	}
+}
+
+func (p *queryParser) parseSourceOr() (querySource, error) {
+	left, err := p.parseSourceAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseSourceAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binarySource{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseSourceAnd() (querySource, error) {
+	left, err := p.parseSourceUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseSourceUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binarySource{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseSourceUnary() (querySource, error) {
+	token := p.next()
+	switch {
+	case token.kind == tokenMinus || (token.kind == tokenOperator && token.text == "!") ||
+		(token.kind == tokenIdent && token.upper == "NOT"):
+		inner, err := p.parseSourceUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notSource{inner: inner}, nil
+	case token.kind == tokenString:
+		return folderSource{folder: filepathToSlash(token.text)}, nil
+	case token.kind == tokenTag:
+		return tagSource{tag: token.text}, nil
+	case token.kind == tokenLParen:
+		inner, err := p.parseSourceOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, p.errorf("missing ')' in FROM")
+		}
+		return inner, nil
+	}
+	return nil, p.errorf("FROM expects a \"folder\" or #tag")
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.isKeyword("NOT") || (p.peek().kind == tokenOperator && p.peek().text == "!") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.peek()
+	op := ""
+	switch {
+	case token.kind == tokenOperator && token.text != "!":
+		op = token.text
+	case p.isKeyword("CONTAINS"):
+		op = "contains"
+	case token.kind == tokenOperator && token.text == "!" &&
+		p.tokens[p.pos+1].kind == tokenIdent && p.tokens[p.pos+1].upper == "CONTAINS":
+		p.next()
+		op = "!contains"
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *queryParser) parseOperand() (queryExpr, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenString:
+		return literalExpr{value: token.text}, nil
+	case tokenNumber:
+		value, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid number %q", token.text)
+		}
+		return literalExpr{value: value}, nil
+	case tokenTag:
+		return literalExpr{value: token.text}, nil
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, p.errorf("missing ')'")
+		}
+		return expr, nil
+	case tokenIdent:
+		switch token.upper {
+		case "TRUE":
+			return literalExpr{value: true}, nil
+		case "FALSE":
+			return literalExpr{value: false}, nil
+		case "NULL":
+			return literalExpr{value: nil}, nil
+		case "TODAY":
+			return keywordExpr{name: "today"}, nil
+		case "NOW":
+			return keywordExpr{name: "now"}, nil
+		}
+		return fieldExpr{name: token.text}, nil
+	}
+	if token.kind == tokenEOF {
+		return nil, errors.New("query: unexpected end of query")
+	}
+	return nil, fmt.Errorf("query: unexpected %q (at %d)", token.text, token.pos)
+}
+
+func filepathToSlash(value string) string {
+	return strings.ReplaceAll(value, "\\", "/")
+}
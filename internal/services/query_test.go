@@ -0,0 +1,140 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var queryNow = time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local)
+
+func queryNotes() []NoteMeta {
+	note := func(rel string, size int64, modTime time.Time, frontMatter map[string]interface{}, tags ...string) NoteMeta {
+		name := strings.TrimSuffix(rel[strings.LastIndex(rel, "/")+1:], ".md")
+		return NoteMeta{Path: rel, RelPath: rel, Name: name, Size: size, ModTime: modTime, FrontMatter: frontMatter, Tags: tags}
+	}
+	earlier := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	return []NoteMeta{
+		note("meetings/standup.md", 120, time.Date(2026, 10, 19, 8, 0, 0, 0, time.Local), map[string]interface{}{
+			"status":    "open",
+			"due":       "2026-10-20",
+			"priority":  2,
+			"owner":     map[string]interface{}{"name": "Ann"},
+			"attendees": []interface{}{"Ann", "Bo"},
+		}, "project/alpha", "daily"),
+		note("meetings/retro.md", 80, earlier, map[string]interface{}{
+			"status":   "done",
+			"due":      "2026-10-01",
+			"priority": 1,
+			"title":    `He said "hi"`,
+		}, "project"),
+		note("journal/2026-10-19.md", 40, earlier, map[string]interface{}{"mood": "good, calm"}, "daily"),
+		note("inbox.md", 10, earlier, map[string]interface{}{"Status": "Open", "priority": 3}),
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "LIST", want: []string{"meetings/standup.md", "meetings/retro.md", "journal/2026-10-19.md", "inbox.md"}},
+
+		// 优先级：NOT 高于 AND，AND 高于 OR
+		{query: `LIST WHERE status = "open" OR priority = 1 AND due < today`, want: []string{"meetings/standup.md", "meetings/retro.md", "inbox.md"}},
+		{query: `LIST WHERE (status = "open" OR priority = 1) AND due < today`, want: []string{"meetings/retro.md"}},
+		{query: `LIST WHERE NOT status = "open" AND priority`, want: []string{"meetings/retro.md"}},
+		{query: `LIST WHERE !(status = "done")`, want: []string{"meetings/standup.md", "journal/2026-10-19.md", "inbox.md"}},
+		{query: `list where priority > -1 and priority < 2.5`, want: []string{"meetings/standup.md", "meetings/retro.md"}},
+
+		// 引号
+		{query: `LIST WHERE mood = 'good, calm'`, want: []string{"journal/2026-10-19.md"}},
+		{query: `LIST WHERE title = "He said \"hi\""`, want: []string{"meetings/retro.md"}},
+
+		// front matter：键不区分大小写，嵌套字段用点号，列表按元素比较
+		{query: `LIST WHERE STATUS = "OPEN"`, want: []string{"meetings/standup.md", "inbox.md"}},
+		{query: `LIST WHERE owner.name = "ann"`, want: []string{"meetings/standup.md"}},
+		{query: `LIST WHERE attendees contains "bo"`, want: []string{"meetings/standup.md"}},
+		{query: `LIST WHERE attendees = "Ann"`, want: []string{"meetings/standup.md"}},
+		{query: `LIST WHERE mood !contains "calm"`, want: []string{"meetings/standup.md", "meetings/retro.md", "inbox.md"}},
+		{query: `LIST WHERE due >= "2026-10-02"`, want: []string{"meetings/standup.md"}},
+
+		// file.* 字段
+		{query: `LIST WHERE file.folder = "meetings"`, want: []string{"meetings/standup.md", "meetings/retro.md"}},
+		{query: `LIST WHERE file.name = "inbox"`, want: []string{"inbox.md"}},
+		{query: `LIST WHERE file.ext = "md" AND file.size < 50`, want: []string{"journal/2026-10-19.md", "inbox.md"}},
+		{query: `LIST WHERE file.mtime >= today`, want: []string{"meetings/standup.md"}},
+		{query: `LIST WHERE file.path contains "2026"`, want: []string{"journal/2026-10-19.md"}},
+
+		// 标签
+		{query: `LIST WHERE tags contains "#daily"`, want: []string{"meetings/standup.md", "journal/2026-10-19.md"}},
+		{query: `LIST FROM #project`, want: []string{"meetings/standup.md", "meetings/retro.md"}},
+		{query: `LIST FROM #project AND -"meetings/retro"`, want: []string{"meetings/standup.md"}},
+		{query: `LIST FROM "meetings" OR #daily`, want: []string{"meetings/standup.md", "meetings/retro.md", "journal/2026-10-19.md"}},
+		{query: `LIST FROM NOT (#project OR #daily)`, want: []string{"inbox.md"}},
+
+		// 排序和数量限制
+		{query: `LIST SORT priority`, want: []string{"meetings/retro.md", "meetings/standup.md", "inbox.md", "journal/2026-10-19.md"}},
+		{query: `LIST SORT file.folder, file.name DESC LIMIT 3`, want: []string{"inbox.md", "journal/2026-10-19.md", "meetings/standup.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, row := range query.Execute(queryNotes(), queryNow).Rows {
+				got = append(got, row.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryTable(t *testing.T) {
+	query, err := ParseQuery(`TABLE status AS "State", due, priority, tags FROM "meetings" SORT due`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := query.Execute(queryNotes(), queryNow)
+	want := QueryResult{
+		Columns: []string{"File", "State", "due", "priority", "tags"},
+		Rows: []QueryRow{
+			{Path: "meetings/retro.md", Values: []interface{}{"retro", "done", "2026-10-01", 1.0, []interface{}{"project"}}},
+			{Path: "meetings/standup.md", Values: []interface{}{"standup", "open", "2026-10-20", 2.0, []interface{}{"project/alpha", "daily"}}},
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: `LIST WHERE status = "open`, want: "unterminated string"},
+		{query: `LIST FROM #`, want: "empty tag"},
+		{query: `LIST WHERE a @ b`, want: "unexpected character"},
+		{query: `LIST LIMIT -1`, want: "LIMIT expects a positive number"},
+		{query: `LIST LIMIT ten`, want: "LIMIT expects a positive number"},
+		{query: `LIST FROM status`, want: "FROM expects"},
+		{query: `LIST FROM ("a"`, want: "missing ')' in FROM"},
+		{query: `LIST WHERE (a = 1`, want: "missing ')'"},
+		{query: `LIST WHERE`, want: "unexpected end of query"},
+		{query: `LIST GROUP BY status`, want: `unexpected "GROUP"`},
+		{query: `TABLE a AS , b`, want: "AS expects a column name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if _, err := ParseQuery(tt.query); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,594 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// SplitOptions controls SplitNote.
+type SplitOptions struct {
+	// Level is the heading level (1-6) that starts a new note.
+	Level int `json:"level"`
+	// Folder receives the new notes, relative to the folder of the note;
+	// empty keeps them beside it.
+	Folder string `json:"folder"`
+	// Promote shifts the headings of each section so it starts at level 1.
+	Promote bool `json:"promote"`
+	// Numbered prefixes the file names with the section number ("01 ").
+	Numbered bool `json:"numbered"`
+}
+
+// SplitResult lists the notes written by SplitNote. The original note
+// becomes the index; Content is its new text.
+type SplitResult struct {
+	Index   string   `json:"index"`
+	Content string   `json:"content"`
+	Files   []string `json:"files"`
+}
+
+// MergeOptions controls MergeNotes.
+type MergeOptions struct {
+	// Shift is added to every heading level, capped at 6.
+	Shift int `json:"shift"`
+	// Titles starts each note's part with a heading holding its title (front
+	// matter title, leading level 1 heading or file name) at level Shift, or 1.
+	Titles bool `json:"titles"`
+	// DeleteSources removes the merged notes afterwards.
+	DeleteSources bool `json:"deleteSources"`
+}
+
+// MergeResult describes the note written by MergeNotes.
+type MergeResult struct {
+	Path    string   `json:"path"`
+	Content string   `json:"content"`
+	Deleted []string `json:"deleted"`
+}
+
+var (
+	linkPathEscaper = strings.NewReplacer("%", "%25", " ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+	fileNameCleaner = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "", "?", "", "\"", "", "<", "", ">", "", "|", "-")
+)
+
+// movedAnchor is where a heading ends up after splitting or merging.
+type movedAnchor struct {
+	path   string
+	anchor string
+}
+
+// SplitNote splits content, the text of the note at path, into one note per
+// heading of options.Level. Text before the first such heading and under
+// higher level headings stays in the note, which links to the new notes in
+// place of their sections. Relative links and images are rebased on the
+// new folder and links to moved headings point into the new notes. Existing
+// files are never overwritten; names get a number instead.
+func (s *FileService) SplitNote(path string, content string, options SplitOptions) (SplitResult, error) {
+	result := SplitResult{Index: path, Files: []string{}}
+	if IsEncryptedNote(path) {
+		return result, errors.New("encrypted notes cannot be split")
+	}
+	if options.Level < 1 || options.Level > 6 {
+		return result, fmt.Errorf("heading level must be between 1 and 6, got %d", options.Level)
+	}
+	path = filepath.Clean(path)
+	dir := filepath.Dir(path)
+	targetDir := dir
+	if folder := strings.TrimSpace(options.Folder); folder != "" {
+		if filepath.IsAbs(folder) {
+			return result, errors.New("folder must be relative to the note")
+		}
+		targetDir = filepath.Join(dir, filepath.FromSlash(folder))
+		if !isWithinRoot(dir, targetDir) {
+			return result, errors.New("folder must be inside the note's folder")
+		}
+	}
+
+	content, restore := normalizeLineEndings(content)
+	doc := parseDocument(content)
+	lines := doc.lines
+
+	// 只按顶层标题切分，引用和列表中的标题随所在段落移动
+	type chunk struct {
+		heading OutlineHeading
+		first   int
+		last    int
+		path    string
+		delta   int
+	}
+	chunks := []chunk{}
+	for node := doc.root.FirstChild(); node != nil; node = node.NextSibling() {
+		heading, ok := node.(*ast.Heading)
+		if !ok || heading.Level > options.Level {
+			continue
+		}
+		entry, ok := doc.outlineHeading(heading)
+		if !ok {
+			continue
+		}
+		if count := len(chunks); count > 0 {
+			chunks[count-1].last = entry.Line - 2
+		}
+		chunks = append(chunks, chunk{heading: entry, first: entry.Line - 1, last: len(lines) - 1})
+	}
+
+	taken := map[string]bool{path: true}
+	sections := 0
+	for i := range chunks {
+		if chunks[i].heading.Level != options.Level {
+			continue
+		}
+		sections++
+		name := cleanFileName(chunks[i].heading.Text)
+		if options.Numbered {
+			name = fmt.Sprintf("%02d %s", sections, name)
+		}
+		chunks[i].path = s.availableNotePath(filepath.Join(targetDir, name+".md"), taken)
+		taken[chunks[i].path] = true
+		if options.Promote {
+			chunks[i].delta = 1 - options.Level
+		}
+	}
+	if sections == 0 {
+		return result, fmt.Errorf("the note has no level %d headings to split at", options.Level)
+	}
+
+	// 记录每个标题移动后的文件和锚点，用于改写文内锚点链接
+	anchors := map[string]movedAnchor{}
+	for _, heading := range doc.outline() {
+		target := movedAnchor{path: path, anchor: heading.Anchor}
+		for _, c := range chunks {
+			if c.path != "" && heading.Line-1 >= c.first && heading.Line-1 <= c.last {
+				target = movedAnchor{path: c.path, anchor: HeadingAnchorID(shiftLevel(heading.Level, c.delta), heading.Text)}
+			}
+		}
+		for _, anchor := range headingAnchors(heading.Level, heading.Text) {
+			if _, ok := anchors[anchor]; !ok {
+				anchors[anchor] = target
+			}
+		}
+	}
+
+	var index []string
+	end := len(lines)
+	if len(chunks) > 0 {
+		end = chunks[0].first
+	}
+	index = append(index, lines[:end]...)
+	listOpen := false
+	for _, c := range chunks {
+		if c.path == "" {
+			if listOpen {
+				index = append(index, "")
+				listOpen = false
+			}
+			index = append(index, lines[c.first:c.last+1]...)
+			continue
+		}
+		if !listOpen {
+			index = trimTrailingBlank(index)
+			if len(index) > 0 {
+				index = append(index, "")
+			}
+			listOpen = true
+		}
+		index = append(index, fmt.Sprintf("- [%s](%s)", tocEscaper.Replace(c.heading.Text), relativeLink(path, c.path, "")))
+
+		section := strings.Join(trimTrailingBlank(lines[c.first:c.last+1]), "\n") + "\n"
+		section = shiftHeadings(section, c.delta)
+		section = relocateLinks(section, path, c.path, path, anchors)
+		if err := s.writeNewNote(c.path, restore(section)); err != nil {
+			return result, err
+		}
+		result.Files = append(result.Files, c.path)
+	}
+
+	indexContent := strings.Join(trimTrailingBlank(index), "\n") + "\n"
+	indexContent = relocateLinks(indexContent, path, path, path, anchors)
+	result.Content = restore(indexContent)
+	if err := s.writeAtomic(path, []byte(result.Content)); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// MergeNotes joins the notes at paths, in order, into a new note at target.
+// Front matter is dropped and heading levels are shifted. Relative links
+// and images are rebased on the folder of target; links between the merged
+// notes become links to their headings in the new note.
+func (s *FileService) MergeNotes(paths []string, target string, options MergeOptions) (MergeResult, error) {
+	result := MergeResult{Path: target, Deleted: []string{}}
+	if len(paths) == 0 {
+		return result, errors.New("select the notes to merge")
+	}
+	if options.Shift < 0 || options.Shift > 5 {
+		return result, fmt.Errorf("heading shift must be between 0 and 5, got %d", options.Shift)
+	}
+	target = filepath.Clean(target)
+	result.Path = target
+	if !IsMarkdownFile(target) {
+		return result, errors.New("the merged note must be a Markdown file")
+	}
+	if _, err := s.Lstat(target); err == nil {
+		return result, fmt.Errorf("%s already exists", filepath.Base(target))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+
+	type part struct {
+		path  string
+		body  string
+		title string
+	}
+	parts := make([]part, 0, len(paths))
+	seen := map[string]bool{}
+	for _, source := range paths {
+		source = filepath.Clean(source)
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		if IsEncryptedNote(source) || !IsMarkdownFile(source) {
+			return result, fmt.Errorf("%s is not a plain Markdown note", filepath.Base(source))
+		}
+		data, err := s.ReadBytes(source)
+		if err != nil {
+			return result, err
+		}
+		text, _ := normalizeLineEndings(string(data))
+		_, body, _ := SplitFrontMatter(text)
+		parts = append(parts, part{path: source, body: body, title: mergeTitle(source, text)})
+	}
+
+	titleLevel := options.Shift
+	if titleLevel < 1 {
+		titleLevel = 1
+	}
+	// 先确定每个笔记的标题合并后的锚点，再改写链接
+	anchors := map[string]map[string]movedAnchor{}
+	for i, p := range parts {
+		body := p.body
+		fileAnchors := map[string]movedAnchor{}
+		if options.Titles {
+			body = dropTitleHeading(body, p.title)
+			titleAnchor := movedAnchor{path: target, anchor: HeadingAnchorID(titleLevel, headingPlainText(p.title))}
+			fileAnchors[""] = titleAnchor
+			for _, anchor := range headingAnchors(1, headingPlainText(p.title)) {
+				fileAnchors[anchor] = titleAnchor
+			}
+			body = strings.Repeat("#", titleLevel) + " " + p.title + "\n\n" + strings.TrimLeft(body, "\n")
+			body = shiftHeadingsAfter(body, options.Shift, 1)
+		} else {
+			body = shiftHeadings(body, options.Shift)
+		}
+		for _, heading := range DocumentOutline(p.body) {
+			moved := movedAnchor{path: target, anchor: HeadingAnchorID(shiftLevel(heading.Level, options.Shift), heading.Text)}
+			if _, ok := fileAnchors[""]; !ok {
+				fileAnchors[""] = moved
+			}
+			for _, anchor := range headingAnchors(heading.Level, heading.Text) {
+				if _, ok := fileAnchors[anchor]; !ok {
+					fileAnchors[anchor] = moved
+				}
+			}
+		}
+		anchors[p.path] = fileAnchors
+		parts[i].body = body
+	}
+
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		body := relocateMergedLinks(p.body, p.path, target, anchors)
+		if body = strings.Trim(body, "\n"); body != "" {
+			texts = append(texts, body)
+		}
+	}
+	result.Content = strings.Join(texts, "\n\n") + "\n"
+	if err := s.writeNewNote(target, result.Content); err != nil {
+		return result, err
+	}
+
+	if options.DeleteSources {
+		for _, p := range parts {
+			if err := s.DeleteFile(p.path); err != nil {
+				return result, err
+			}
+			result.Deleted = append(result.Deleted, p.path)
+		}
+	}
+	return result, nil
+}
+
+// shiftHeadings adds delta to the level of every top-level heading, writing
+// them as ATX headings. Headings in quotes and lists keep their level.
+func shiftHeadings(content string, delta int) string {
+	return shiftHeadingsAfter(content, delta, 0)
+}
+
+// shiftHeadingsAfter shifts the top-level headings after the first skip.
+func shiftHeadingsAfter(content string, delta int, skip int) string {
+	if delta == 0 {
+		return content
+	}
+	doc := parseDocument(content)
+	edits := []textEdit{}
+	for node := doc.root.FirstChild(); node != nil; node = node.NextSibling() {
+		heading, ok := node.(*ast.Heading)
+		if !ok || heading.Lines().Len() == 0 {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		lines := heading.Lines()
+		start, _ := doc.lineBounds(lines.At(0).Start)
+		_, end := doc.lineBounds(lines.At(lines.Len() - 1).Start)
+		if !strings.HasPrefix(strings.TrimLeft(string(doc.source[start:end]), " "), "#") && end < len(doc.source) {
+			// setext 标题包含下一行的下划线
+			_, end = doc.lineBounds(end + 1)
+		}
+		text := doc.headingSource(heading)
+		if strings.HasSuffix(text, "#") {
+			text = text[:len(text)-1] + `\#`
+		}
+		edits = append(edits, doc.edit(start, end, strings.Repeat("#", shiftLevel(heading.Level, delta))+" "+text))
+	}
+	shifted, _ := applyTextEdits(content, edits)
+	return shifted
+}
+
+// mergeTitle returns the front matter title of a note, its level 1 heading
+// when the note starts with one, or its file name.
+func mergeTitle(path string, content string) string {
+	frontMatter, body, err := ParseFrontMatter(content)
+	if err == nil {
+		if title, ok := frontMatter["title"].(string); ok && strings.TrimSpace(title) != "" {
+			return strings.TrimSpace(title)
+		}
+	}
+	doc := parseDocument(body)
+	if heading, ok := doc.root.FirstChild().(*ast.Heading); ok && heading.Level == 1 {
+		if title := doc.headingSource(heading); title != "" {
+			return title
+		}
+	}
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// dropTitleHeading removes a leading level 1 heading equal to title.
+func dropTitleHeading(body string, title string) string {
+	doc := parseDocument(body)
+	first := doc.root.FirstChild()
+	heading, ok := first.(*ast.Heading)
+	if !ok || heading.Level != 1 || heading.Lines().Len() == 0 {
+		return body
+	}
+	entry, ok := doc.outlineHeading(heading)
+	if !ok || entry.Text != headingPlainText(title) {
+		return body
+	}
+	lines := doc.lines
+	last := entry.Line - 1 + heading.Lines().Len() - 1
+	if last+1 < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[entry.Line-1]), "#") {
+		last++
+	}
+	return strings.Join(lines[last+1:], "\n") + "\n"
+}
+
+// relocateLinks rewrites the local links of content, which moved from the
+// note at from to the note at to. Anchor links and links to original (the
+// note being split) follow the headings in anchors.
+func relocateLinks(content string, from string, to string, original string, anchors map[string]movedAnchor) string {
+	dir := filepath.Dir(from)
+	return rewriteLinkTargets(content, func(target string) (string, bool) {
+		if target == "" || IsExternalLink(target) {
+			return target, false
+		}
+		linkPath, anchor := SplitLinkTarget(target)
+		_, rawAnchor, _ := strings.Cut(target, "#")
+		if strings.HasPrefix(linkPath, "/") {
+			return target, false
+		}
+		resolved := from
+		if linkPath != "" {
+			resolved = filepath.Join(dir, filepath.FromSlash(linkPath))
+		}
+		if resolved == original && anchor != "" {
+			if moved, ok := lookupAnchor(anchors, anchor); ok {
+				return relativeLink(to, moved.path, moved.anchor), true
+			}
+		}
+		if linkPath == "" || filepath.Dir(to) == dir {
+			return target, false
+		}
+		return relativeLink(to, resolved, rawAnchor), true
+	})
+}
+
+// relocateMergedLinks rewrites the links of a note merged into target;
+// links into merged notes point at their headings in target.
+func relocateMergedLinks(content string, from string, target string, anchors map[string]map[string]movedAnchor) string {
+	dir := filepath.Dir(from)
+	return rewriteLinkTargets(content, func(link string) (string, bool) {
+		if link == "" || IsExternalLink(link) {
+			return link, false
+		}
+		linkPath, anchor := SplitLinkTarget(link)
+		_, rawAnchor, _ := strings.Cut(link, "#")
+		if strings.HasPrefix(linkPath, "/") {
+			return link, false
+		}
+		resolved := from
+		if linkPath != "" {
+			resolved = filepath.Join(dir, filepath.FromSlash(linkPath))
+		}
+		if fileAnchors, ok := anchors[resolved]; ok {
+			if moved, ok := lookupAnchor(fileAnchors, anchor); ok {
+				return "#" + moved.anchor, true
+			}
+		}
+		if linkPath == "" || filepath.Dir(target) == dir {
+			return link, false
+		}
+		return relativeLink(target, resolved, rawAnchor), true
+	})
+}
+
+func lookupAnchor(anchors map[string]movedAnchor, anchor string) (movedAnchor, bool) {
+	if moved, ok := anchors[anchor]; ok {
+		return moved, true
+	}
+	moved, ok := anchors[strings.ToLower(anchor)]
+	return moved, ok
+}
+
+// rewriteLinkTargets replaces the targets of inline links, images and
+// reference definitions outside code with the result of rewrite.
+func rewriteLinkTargets(content string, rewrite func(target string) (string, bool)) string {
+	lines := strings.Split(content, "\n")
+	inFence := false
+	fenceMarker := ""
+	for i := FrontMatterLineCount(content); i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if marker := fenceOpening(trimmed); marker != "" {
+			if !inFence {
+				inFence, fenceMarker = true, marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) {
+				inFence = false
+				continue
+			}
+		}
+		if inFence {
+			continue
+		}
+
+		var spans [][2]int
+		if def := referenceDefPattern.FindStringSubmatchIndex(line); def != nil {
+			spans = append(spans, [2]int{def[4], def[5]})
+		} else {
+			for _, loc := range inlineLinkPattern.FindAllStringSubmatchIndex(maskInlineCode(line), -1) {
+				spans = append(spans, [2]int{loc[6], loc[7]})
+			}
+		}
+		for j := len(spans) - 1; j >= 0; j-- {
+			start, end := spans[j][0], spans[j][1]
+			target := strings.Trim(line[start:end], "<>")
+			if replaced, ok := rewrite(target); ok {
+				line = line[:start] + replaced + line[end:]
+			}
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maskInlineCode blanks code spans byte for byte so offsets stay valid.
+func maskInlineCode(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+	masked := []byte(line)
+	inCode := false
+	for i, b := range masked {
+		if b == '`' {
+			inCode = !inCode
+			masked[i] = ' '
+			continue
+		}
+		if inCode {
+			masked[i] = ' '
+		}
+	}
+	return string(masked)
+}
+
+// relativeLink returns the link from the note at from to path, with anchor.
+func relativeLink(from string, path string, anchor string) string {
+	link := ""
+	if filepath.Clean(path) != filepath.Clean(from) {
+		rel, err := filepath.Rel(filepath.Dir(from), path)
+		if err != nil {
+			rel = path
+		}
+		link = linkPathEscaper.Replace(filepath.ToSlash(rel))
+	}
+	if anchor != "" {
+		link += "#" + anchor
+	}
+	return link
+}
+
+func shiftLevel(level int, delta int) int {
+	level += delta
+	if level < 1 {
+		return 1
+	}
+	if level > 6 {
+		return 6
+	}
+	return level
+}
+
+func trimTrailingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// cleanFileName turns heading text into a file name without the extension.
+func cleanFileName(text string) string {
+	name := strings.Join(strings.Fields(fileNameCleaner.Replace(text)), " ")
+	name = strings.Trim(name, ". ")
+	if runes := []rune(name); len(runes) > 80 {
+		name = strings.TrimSpace(string(runes[:80]))
+	}
+	if name == "" {
+		name = "Untitled"
+	}
+	return name
+}
+
+// availableNotePath appends " 2", " 3", … until path is neither taken nor
+// on disk.
+func (s *FileService) availableNotePath(path string, taken map[string]bool) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 2; ; i++ {
+		if _, err := s.Lstat(candidate); errors.Is(err, os.ErrNotExist) && !taken[candidate] {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s %d%s", base, i, ext)
+	}
+}
+
+// writeNewNote writes a note that must not exist yet, creating its folder.
+// Inside a vault the note is sealed like every other write.
+func (s *FileService) writeNewNote(path string, content string) error {
+	if err := s.CreateDirectory(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if v := s.Vault(path); v != nil {
+		if _, err := v.Lstat(path); err == nil {
+			return fmt.Errorf("%s: %w", path, os.ErrExist)
+		}
+		return v.WriteFile(path, []byte(content))
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitNote(t *testing.T) {
+	const note = "# Book\n\nIntro.\n\n## First\n\nSee [second](#second).\n\n### Detail\n\nMore.\n\n## Second\n\n![img](img/a.png)\n"
+	tests := []struct {
+		name    string
+		options SplitOptions
+		index   string
+		files   map[string]string
+	}{
+		{
+			name:    "beside the note",
+			options: SplitOptions{Level: 2},
+			index:   "# Book\n\nIntro.\n\n- [First](First.md)\n- [Second](Second.md)\n",
+			files: map[string]string{
+				"First.md":  "## First\n\nSee [second](Second.md#h2-second).\n\n### Detail\n\nMore.\n",
+				"Second.md": "## Second\n\n![img](img/a.png)\n",
+			},
+		},
+		{
+			name:    "numbered and promoted into a folder",
+			options: SplitOptions{Level: 2, Folder: "parts", Promote: true, Numbered: true},
+			index:   "# Book\n\nIntro.\n\n- [First](parts/01%20First.md)\n- [Second](parts/02%20Second.md)\n",
+			files: map[string]string{
+				"parts/01 First.md":  "# First\n\nSee [second](02%20Second.md#h1-second).\n\n## Detail\n\nMore.\n",
+				"parts/02 Second.md": "# Second\n\n![img](../img/a.png)\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "book.md")
+			writeTree(t, root, map[string]string{"book.md": note})
+			result, err := NewFileService().SplitNote(path, note, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Content != tt.index {
+				t.Errorf("index = %q, want %q", result.Content, tt.index)
+			}
+			want := map[string]string{"book.md": tt.index}
+			for name, content := range tt.files {
+				want[name] = content
+			}
+			if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+				t.Errorf("tree = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSplitNoteKeepsExistingFiles(t *testing.T) {
+	root := t.TempDir()
+	const note = "## Part\n\ntext\n"
+	writeTree(t, root, map[string]string{"note.md": note, "Part.md": "mine"})
+	result, err := NewFileService().SplitNote(filepath.Join(root, "note.md"), note, SplitOptions{Level: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(root, "Part 2.md")}; !reflect.DeepEqual(result.Files, want) {
+		t.Errorf("Files = %v, want %v", result.Files, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "Part.md")); string(data) != "mine" {
+		t.Errorf("existing note overwritten: %q", data)
+	}
+}
+
+func TestMergeNotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		options MergeOptions
+		want    string
+	}{
+		{
+			name:    "shift",
+			options: MergeOptions{Shift: 1},
+			want:    "## A\n\nSee [b](#h2-b).\n\n## B\n\nText.\n",
+		},
+		{
+			name:    "titles",
+			options: MergeOptions{Titles: true, Shift: 1},
+			want:    "# A\n\nSee [b](#h1-b).\n\n# B\n\nText.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				"a.md": "---\ntitle: A\n---\n# A\n\nSee [b](b.md).\n",
+				"b.md": "# B\n\nText.\n",
+			})
+			target := filepath.Join(root, "merged.md")
+			result, err := NewFileService().MergeNotes([]string{filepath.Join(root, "a.md"), filepath.Join(root, "b.md")}, target, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Content != tt.want {
+				t.Errorf("content = %q, want %q", result.Content, tt.want)
+			}
+			if data, _ := os.ReadFile(target); string(data) != tt.want {
+				t.Errorf("written = %q", data)
+			}
+		})
+	}
+}
+
+func TestSplitAndMergeInsideVault(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"placeholder.md": "x"})
+	vault, err := CreateVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	files.MountVault(vault)
+	defer files.UnmountAllVaults()
+
+	const note = "## Secret one\n\nalpha\n\n## Secret two\n\nbeta\n"
+	path := filepath.Join(root, "plan.md")
+	if err := files.Write(path, note); err != nil {
+		t.Fatal(err)
+	}
+	result, err := files.SplitNote(path, note, SplitOptions{Level: 2, Folder: "parts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := files.MergeNotes(result.Files, filepath.Join(root, "again.md"), MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(merged.Content, "alpha") || !strings.Contains(merged.Content, "beta") {
+		t.Errorf("merged = %q", merged.Content)
+	}
+
+	for name, content := range readTree(t, root) {
+		if strings.Contains(name, "Secret") || strings.Contains(name, "parts") || strings.Contains(content, "alpha") || strings.Contains(content, "Secret") {
+			t.Errorf("plaintext on disk: %s = %q", name, content)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// RenameOptions controls a batch rename of the files of one folder. With
+// Regex set, the names matching Pattern are rewritten by Replacement, where
+// $1 and ${name} expand capture groups. Otherwise each file (of those
+// matching Pattern, when given) is named by Template, for example
+// "{{index:03}}-{{slug title}}.md".
+type RenameOptions struct {
+	Template    string `json:"template"`
+	Regex       bool   `json:"regex"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+	// Sort orders the files before they are numbered: "name" (the default),
+	// "title" or "modified".
+	Sort     string `json:"sort"`
+	Start    int    `json:"start"`
+	AllFiles bool   `json:"allFiles"`
+}
+
+// RenameItem is one rename of a batch. Conflict explains why the preview
+// will not rename the file; it is empty when the rename can be applied.
+type RenameItem struct {
+	Path     string `json:"path"`
+	NewPath  string `json:"newPath"`
+	Name     string `json:"name"`
+	NewName  string `json:"newName"`
+	Title    string `json:"title"`
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// RenamePreview lists the renames of a batch in numbering order.
+type RenamePreview struct {
+	Items     []RenameItem `json:"items"`
+	Conflicts int          `json:"conflicts"`
+}
+
+// RenameResult reports the files renamed by BatchRename and the notes whose
+// links were rewritten to follow them.
+type RenameResult struct {
+	Renamed []RenameItem `json:"renamed"`
+	Updated []string     `json:"updated"`
+	Links   int          `json:"links"`
+}
+
+type renameCandidate struct {
+	path     string
+	name     string
+	title    string
+	modified time.Time
+}
+
+// PreviewBatchRename computes the new names of the files in folder without
+// renaming anything. Hidden files and folders are left alone; renames whose
+// target is taken, or shared with another file of the batch, are marked as
+// conflicts.
+func (s *FileService) PreviewBatchRename(folder string, opts RenameOptions) (RenamePreview, error) {
+	preview := RenamePreview{Items: []RenameItem{}}
+	if opts.Regex && opts.Pattern == "" {
+		return preview, errors.New("a pattern is required")
+	}
+	if !opts.Regex && strings.TrimSpace(opts.Template) == "" {
+		return preview, errors.New("a name template is required")
+	}
+	var pattern *regexp.Regexp
+	if opts.Pattern != "" {
+		re, err := regexp.Compile(opts.Pattern)
+		if err != nil {
+			return preview, fmt.Errorf("invalid pattern: %w", err)
+		}
+		pattern = re
+	}
+	var nameTemplate *template.Template
+	if !opts.Regex {
+		parsed, err := parseRenameTemplate(opts.Template)
+		if err != nil {
+			return preview, err
+		}
+		nameTemplate = parsed
+	}
+
+	folder = filepath.Clean(folder)
+	entries, err := s.ReadDir(folder)
+	if err != nil {
+		return preview, err
+	}
+	candidates := []renameCandidate{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !entry.Type().IsRegular() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !opts.AllFiles && !IsMarkdownFile(name) {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(name) {
+			continue
+		}
+		candidate := renameCandidate{path: filepath.Join(folder, name), name: name}
+		if info, err := entry.Info(); err == nil {
+			candidate.modified = info.ModTime()
+		}
+		candidate.title = strings.TrimSuffix(name, filepath.Ext(name))
+		if IsMarkdownFile(name) {
+			if data, err := s.ReadBytes(candidate.path); err == nil {
+				if title := headingPlainText(DocumentTitle(string(data))); title != "" {
+					candidate.title = title
+				}
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	sortRenameCandidates(candidates, opts.Sort)
+
+	start := opts.Start
+	if start <= 0 {
+		start = 1
+	}
+	for i, candidate := range candidates {
+		var newName string
+		if opts.Regex {
+			newName = pattern.ReplaceAllString(candidate.name, opts.Replacement)
+		} else {
+			rendered, err := renderRenameTemplate(nameTemplate, candidate, start+i)
+			if err != nil {
+				return preview, fmt.Errorf("%s: %w", candidate.name, err)
+			}
+			newName = rendered
+		}
+		newName = strings.TrimSpace(fileNameCleaner.Replace(newName))
+		if newName == candidate.name {
+			continue
+		}
+		item := RenameItem{
+			Path:    candidate.path,
+			NewPath: filepath.Join(folder, newName),
+			Name:    candidate.name,
+			NewName: newName,
+			Title:   candidate.title,
+		}
+		if newName == "" || strings.HasPrefix(newName, ".") {
+			item.Conflict = "invalid file name"
+		}
+		preview.Items = append(preview.Items, item)
+	}
+
+	markRenameConflicts(preview.Items, entries)
+	for _, item := range preview.Items {
+		if item.Conflict != "" {
+			preview.Conflicts++
+		}
+	}
+	return preview, nil
+}
+
+// BatchRename renames the files of items through RenameFile and rewrites
+// the links of the notes below root that point at them. Every file is first
+// moved to a temporary name, so names can be swapped or shifted within the
+// batch; when a rename fails, the files already renamed are moved back.
+// Links in encrypted notes and vaults are not rewritten.
+func (s *FileService) BatchRename(root string, items []RenameItem) (RenameResult, error) {
+	result := RenameResult{Renamed: []RenameItem{}, Updated: []string{}}
+	root = filepath.Clean(root)
+
+	sources := map[string]bool{}
+	targets := map[string]bool{}
+	for i, item := range items {
+		item.Path, item.NewPath = filepath.Clean(item.Path), filepath.Clean(item.NewPath)
+		items[i] = item
+		if !isWithinRoot(root, item.Path) || filepath.Dir(item.NewPath) != filepath.Dir(item.Path) {
+			return result, fmt.Errorf("%s cannot be renamed to %s", item.Path, item.NewPath)
+		}
+		sources[strings.ToLower(item.Path)] = true
+		key := strings.ToLower(item.NewPath)
+		if targets[key] {
+			return result, fmt.Errorf("two files would be named %s", filepath.Base(item.NewPath))
+		}
+		targets[key] = true
+	}
+	for _, item := range items {
+		if sources[strings.ToLower(item.NewPath)] {
+			continue
+		}
+		if _, err := s.Lstat(item.NewPath); err == nil {
+			return result, fmt.Errorf("%s already exists; preview again", filepath.Base(item.NewPath))
+		}
+	}
+
+	// 先全部移到临时名，再改成新名，允许批次内互换或顺延名称
+	type step struct{ from, to string }
+	done := []step{}
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			_ = s.RenameFile(done[i].to, done[i].from)
+		}
+	}
+	temporary := make([]string, len(items))
+	for i, item := range items {
+		temporary[i] = filepath.Join(filepath.Dir(item.Path), fmt.Sprintf(".markdowndaonote-rename-%d-%d", os.Getpid(), i))
+		if err := s.RenameFile(item.Path, temporary[i]); err != nil {
+			rollback()
+			return result, fmt.Errorf("rename %s: %w", item.Name, err)
+		}
+		done = append(done, step{from: item.Path, to: temporary[i]})
+	}
+	for i, item := range items {
+		if err := s.RenameFile(temporary[i], item.NewPath); err != nil {
+			rollback()
+			return result, fmt.Errorf("rename %s: %w", item.Name, err)
+		}
+		done = append(done, step{from: temporary[i], to: item.NewPath})
+	}
+
+	moved := map[string]string{}
+	for _, item := range items {
+		item.Conflict = ""
+		result.Renamed = append(result.Renamed, item)
+		moved[item.Path] = item.NewPath
+	}
+	updated, links, err := s.rewriteMovedLinks(root, moved, false)
+	result.Updated, result.Links = updated, links
+	return result, err
+}
+
+// BatchRenameLinkSources returns the notes below root whose links
+// BatchRename would rewrite for items, without changing anything.
+func (s *FileService) BatchRenameLinkSources(root string, items []RenameItem) ([]string, error) {
+	moved := map[string]string{}
+	for _, item := range items {
+		moved[filepath.Clean(item.Path)] = filepath.Clean(item.NewPath)
+	}
+	sources, _, err := s.rewriteMovedLinks(filepath.Clean(root), moved, true)
+	return sources, err
+}
+
+// rewriteMovedLinks points the links of the notes below root at the new
+// paths of moved files, which maps old paths to new ones. Notes in unlocked
+// vaults are rewritten too. A dry run only reports the notes it would change.
+func (s *FileService) rewriteMovedLinks(root string, moved map[string]string, dryRun bool) ([]string, int, error) {
+	updated := []string{}
+	links := 0
+	var firstErr error
+	err := s.WalkWorkspaceFiles(root, true, func(path string, _ fs.FileInfo) error {
+		if IsEncryptedNote(path) {
+			return nil
+		}
+		data, err := s.ReadBytes(path)
+		if err != nil {
+			return nil
+		}
+		count := 0
+		content := rewriteLinkTargets(string(data), func(target string) (string, bool) {
+			if target == "" || IsExternalLink(target) {
+				return target, false
+			}
+			linkPath, _ := SplitLinkTarget(target)
+			if linkPath == "" {
+				return target, false
+			}
+			// 批量改名不换文件夹，改名后的笔记中的相对链接照常解析
+			newPath, ok := moved[ResolveLinkPath(root, path, linkPath)]
+			if !ok {
+				return target, false
+			}
+			base, prefix := filepath.Dir(path), ""
+			if strings.HasPrefix(linkPath, "/") {
+				base, prefix = root, "/"
+			}
+			rel, err := filepath.Rel(base, newPath)
+			if err != nil {
+				return target, false
+			}
+			link := prefix + linkPathEscaper.Replace(filepath.ToSlash(rel))
+			if _, anchor, found := strings.Cut(target, "#"); found {
+				link += "#" + anchor
+			}
+			count++
+			return link, true
+		})
+		if count == 0 {
+			return nil
+		}
+		if dryRun {
+			updated = append(updated, path)
+			links += count
+			return nil
+		}
+		if err := s.writeAtomic(path, []byte(content)); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return nil
+		}
+		updated = append(updated, path)
+		links += count
+		return nil
+	})
+	if err == nil {
+		err = firstErr
+	}
+	return updated, links, err
+}
+
+// markRenameConflicts marks renames onto a name that is taken by a file
+// staying in the folder or by another rename. Names are compared without
+// case, as on Windows and macOS. Dropping a conflicting rename keeps its
+// file in place, so the check repeats until nothing changes.
+func markRenameConflicts(items []RenameItem, entries []fs.DirEntry) {
+	for changed := true; changed; {
+		changed = false
+		occupied := map[string]bool{}
+		for _, entry := range entries {
+			occupied[strings.ToLower(entry.Name())] = true
+		}
+		targets := map[string]int{}
+		for _, item := range items {
+			if item.Conflict == "" {
+				delete(occupied, strings.ToLower(item.Name))
+				targets[strings.ToLower(item.NewName)]++
+			}
+		}
+		for i, item := range items {
+			if item.Conflict != "" {
+				continue
+			}
+			key := strings.ToLower(item.NewName)
+			switch {
+			case targets[key] > 1:
+				items[i].Conflict = "another file would get the same name"
+			case occupied[key]:
+				items[i].Conflict = "a file with this name already exists"
+			default:
+				continue
+			}
+			changed = true
+		}
+	}
+}
+
+// parseRenameTemplate parses a file name template. Besides the helpers of
+// note templates it offers index ({{index:03}} pads to three digits),
+// title, name (without extension), ext and date (the modification time).
+func parseRenameTemplate(source string) (*template.Template, error) {
+	funcs := templateTextFuncs()
+	// 占位函数，渲染时按文件替换
+	for _, name := range []string{"title", "name", "ext"} {
+		funcs[name] = func() string { return "" }
+	}
+	funcs["index"] = func(...string) string { return "" }
+	funcs["date"] = func(...string) string { return "" }
+	parsed, err := template.New("name").Funcs(funcs).Option("missingkey=zero").Parse(expandTemplateShorthand(source))
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	return parsed, nil
+}
+
+// renderRenameTemplate names candidate, the index-th file of the batch. A
+// result without the original extension gets it appended, unless it is
+// already a Markdown name.
+func renderRenameTemplate(parsed *template.Template, candidate renameCandidate, index int) (string, error) {
+	ext := filepath.Ext(candidate.name)
+	parsed = parsed.Funcs(template.FuncMap{
+		"title": func() string { return candidate.title },
+		"name":  func() string { return strings.TrimSuffix(candidate.name, ext) },
+		"ext":   func() string { return ext },
+		"index": func(width ...string) string {
+			digits, _ := strconv.Atoi(firstOr(width, "0"))
+			return fmt.Sprintf("%0*d", digits, index)
+		},
+		"date": func(layout ...string) string {
+			return candidate.modified.Format(firstOr(layout, "2006-01-02"))
+		},
+	})
+	var out limitedBuffer
+	if err := parsed.Execute(&out, nil); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(out.String())
+	if !strings.HasSuffix(strings.ToLower(name), strings.ToLower(ext)) && !(IsMarkdownFile(name) && IsMarkdownFile(candidate.name)) {
+		name += ext
+	}
+	return name, nil
+}
+
+func sortRenameCandidates(candidates []renameCandidate, order string) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch order {
+		case "title":
+			if !strings.EqualFold(a.title, b.title) {
+				return naturalLess(a.title, b.title)
+			}
+		case "modified":
+			if !a.modified.Equal(b.modified) {
+				return a.modified.Before(b.modified)
+			}
+		}
+		return naturalLess(a.name, b.name)
+	})
+}
+
+// naturalLess compares strings without case, ordering runs of digits by
+// their value so "2 Notes" sorts before "10 Notes".
+func naturalLess(a, b string) bool {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if ra[i] != rb[j] {
+			return ra[i] < rb[j]
+		}
+		i++
+		j++
+	}
+	return len(ra)-i < len(rb)-j
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchRenameRewritesLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		vault bool
+	}{
+		{name: "plain folder"},
+		{name: "unlocked vault", vault: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			files := NewFileService()
+			defer files.UnmountAllVaults()
+			if tt.vault {
+				writeTree(t, root, map[string]string{"placeholder.md": "x"})
+				vault, err := CreateVault(root, "passphrase")
+				if err != nil {
+					t.Fatal(err)
+				}
+				files.MountVault(vault)
+			}
+			write := func(rel string, content string) {
+				t.Helper()
+				path := filepath.Join(root, filepath.FromSlash(rel))
+				if err := files.CreateDirectory(filepath.Dir(path)); err != nil {
+					t.Fatal(err)
+				}
+				if err := files.Write(path, content); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("notes/draft-a.md", "# A\n\nsee [b](draft-b.md#top)\n")
+			write("notes/draft-b.md", "# B\n")
+			write("index.md", "[a](notes/draft-a.md) and [root](/notes/draft-b.md) and [web](https://example.com/draft-a.md)\n")
+
+			folder := filepath.Join(root, "notes")
+			preview, err := files.PreviewBatchRename(folder, RenameOptions{Regex: true, Pattern: `^draft-(.*)$`, Replacement: "$1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sources, err := files.BatchRenameLinkSources(root, preview.Items)
+			if err != nil || len(sources) != 2 {
+				t.Errorf("link sources = %v, %v, want 2 notes", sources, err)
+			}
+			result, err := files.BatchRename(root, preview.Items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Renamed) != 2 || result.Links != 3 || len(result.Updated) != 2 {
+				t.Errorf("result = %+v, want 2 renames and 3 links in 2 notes", result)
+			}
+
+			want := map[string]string{
+				"index.md":   "[a](notes/a.md) and [root](/notes/b.md) and [web](https://example.com/draft-a.md)\n",
+				"notes/a.md": "# A\n\nsee [b](b.md#top)\n",
+				"notes/b.md": "# B\n",
+			}
+			for rel, content := range want {
+				got, err := files.Read(filepath.Join(root, filepath.FromSlash(rel)))
+				if err != nil || got != content {
+					t.Errorf("%s = %q, %v; want %q", rel, got, err, content)
+				}
+			}
+			if tt.vault {
+				for name, content := range readTree(t, root) {
+					if strings.Contains(name, "notes") || strings.Contains(content, "[a]") {
+						t.Errorf("plaintext on disk: %s", name)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// newMarkdown returns the goldmark converter used for exports.
+func newMarkdown() goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+}
+
+// RenderHTML converts Markdown into an HTML fragment, dropping front matter.
+func RenderHTML(content string) (string, error) {
+	_, body, _ := SplitFrontMatter(content)
+
+	var buf bytes.Buffer
+	if err := newMarkdown().Convert([]byte(body), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const exportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="MarkdownDaoNote">
+<title>%s</title>
+<style>
+body { max-width: 860px; margin: 2rem auto; padding: 0 1rem; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.6; color: #24292e; }
+pre, code { font-family: SFMono-Regular, Consolas, "Liberation Mono", Menlo, monospace; }
+pre { background: #f6f8fa; padding: 1em; overflow: auto; border-radius: 4px; }
+code { background: #f6f8fa; padding: .1em .3em; border-radius: 3px; }
+pre code { background: none; padding: 0; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #dfe2e5; padding: 6px 13px; }
+blockquote { margin: 0; padding: 0 1em; color: #6a737d; border-left: .25em solid #dfe2e5; }
+img { max-width: 100%%; }
+</style>
+</head>
+<body>
+%s</body>
+</html>
+`
+
+// RenderHTMLDocument renders Markdown into a standalone HTML page.
+func RenderHTMLDocument(content string, fallbackTitle string) (string, error) {
+	fragment, err := RenderHTML(content)
+	if err != nil {
+		return "", err
+	}
+
+	title := DocumentTitle(content)
+	if title == "" {
+		title = fallbackTitle
+	}
+	return fmt.Sprintf(exportTemplate, html.EscapeString(title), fragment), nil
+}
+
+// DocumentTitle returns the front matter `title` or the first heading of a document.
+func DocumentTitle(content string) string {
+	frontMatter, body, err := ParseFrontMatter(content)
+	if err == nil {
+		if title, ok := frontMatter["title"].(string); ok && strings.TrimSpace(title) != "" {
+			return strings.TrimSpace(title)
+		}
+	}
+
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fenceOpening(trimmed) != "" {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if match := headingLinePattern.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil {
+			return strings.TrimSpace(match[2])
+		}
+	}
+	return ""
+}
+
+var (
+	plainLinkPattern     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	plainEmphasisPattern = regexp.MustCompile("[*~`]+")
+	plainPrefixPattern   = regexp.MustCompile(`^\s*(?:#{1,6}\s+|>\s?|[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)`)
+)
+
+// RenderPlainText strips Markdown syntax, keeping the readable text.
+func RenderPlainText(content string) string {
+	_, body, _ := SplitFrontMatter(content)
+
+	lines := strings.Split(body, "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if fenceOpening(strings.TrimSpace(line)) != "" {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		line = plainPrefixPattern.ReplaceAllString(line, "")
+		line = plainLinkPattern.ReplaceAllString(line, "$1")
+		line = plainEmphasisPattern.ReplaceAllString(line, "")
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReplaceOptions is a workspace search with the text that replaces each
+// match. With Regex set, $1 and ${name} in Replacement expand capture
+// groups; otherwise Replacement is literal.
+type ReplaceOptions struct {
+	SearchOptions
+	Replacement string `json:"replacement"`
+}
+
+// ReplaceChange is one replacement of a dry run. Offset identifies it when
+// applying a selection; Text is the line before and Preview the line after.
+type ReplaceChange struct {
+	Offset      int    `json:"offset"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Length      int    `json:"length"`
+	Match       string `json:"match"`
+	Replacement string `json:"replacement"`
+	Text        string `json:"text"`
+	Preview     string `json:"preview"`
+}
+
+// ReplaceFile lists the changes of one file. Hash fingerprints the content
+// the preview was computed from, so stale selections are refused.
+type ReplaceFile struct {
+	Path    string          `json:"path"`
+	RelPath string          `json:"relPath"`
+	Hash    string          `json:"hash"`
+	Changes []ReplaceChange `json:"changes"`
+}
+
+// ReplacePreview is the dry run of a workspace replacement.
+type ReplacePreview struct {
+	Files   []ReplaceFile `json:"files"`
+	Changes int           `json:"changes"`
+}
+
+// ReplaceSelection picks the changes of a file to apply by their offsets.
+type ReplaceSelection struct {
+	Path    string `json:"path"`
+	Hash    string `json:"hash"`
+	Offsets []int  `json:"offsets"`
+}
+
+// ReplaceResult reports the files rewritten by ReplaceInWorkspace or reverted by
+// ReplaceUndo.Revert.
+type ReplaceResult struct {
+	Files   []string `json:"files"`
+	Changes int      `json:"changes"`
+}
+
+// ReplaceUndo restores the files of one ReplaceInWorkspace.
+type ReplaceUndo struct {
+	service *FileService
+	files   []replaceSnapshot
+	changes int
+}
+
+type replaceSnapshot struct {
+	path   string
+	before []byte
+	after  string
+}
+
+type replaceMatch struct {
+	start, end  int
+	replacement string
+}
+
+// PreviewReplace returns every change ReplaceInWorkspace would make below
+// root without writing anything. Encrypted notes are never searched.
+func (s *FileService) PreviewReplace(root string, opts ReplaceOptions) (ReplacePreview, error) {
+	preview := ReplacePreview{Files: []ReplaceFile{}}
+	re, err := CompileSearchPattern(opts.SearchOptions)
+	if err != nil {
+		return preview, err
+	}
+
+	clean := filepath.Clean(root)
+	errLimit := errors.New("limit reached")
+	walkErr := s.WalkWorkspaceFiles(clean, !opts.AllFiles, func(path string, _ fs.FileInfo) error {
+		rel, err := filepath.Rel(clean, path)
+		if err != nil || IsEncryptedNote(path) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !MatchGlobs(rel, opts.Include, opts.Exclude) {
+			return nil
+		}
+		data, err := s.ReadBytes(path)
+		if err != nil || isBinaryContent(data) {
+			return nil
+		}
+
+		content := string(data)
+		matches := findReplacements(content, re, opts)
+		if len(matches) == 0 {
+			return nil
+		}
+		if opts.MaxResults > 0 && preview.Changes+len(matches) > opts.MaxResults {
+			matches = matches[:opts.MaxResults-preview.Changes]
+		}
+		file := ReplaceFile{Path: path, RelPath: rel, Hash: contentHash(data), Changes: make([]ReplaceChange, 0, len(matches))}
+		index := newLineIndex(content)
+		for _, match := range matches {
+			line, column := index.position(match.start)
+			lineStart := index.starts[line-1]
+			lineEnd := strings.IndexByte(content[match.end:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(content)
+			} else {
+				lineEnd += match.end
+			}
+			firstLineEnd := strings.IndexByte(content[lineStart:], '\n')
+			if firstLineEnd < 0 {
+				firstLineEnd = len(content)
+			} else {
+				firstLineEnd += lineStart
+			}
+			file.Changes = append(file.Changes, ReplaceChange{
+				Offset:      match.start,
+				Line:        line,
+				Column:      column,
+				Length:      utf8.RuneCountInString(content[match.start:match.end]),
+				Match:       content[match.start:match.end],
+				Replacement: match.replacement,
+				Text:        strings.TrimRight(content[lineStart:firstLineEnd], "\r"),
+				Preview:     strings.TrimRight(content[lineStart:match.start]+match.replacement+content[match.end:lineEnd], "\r"),
+			})
+		}
+		preview.Files = append(preview.Files, file)
+		preview.Changes += len(file.Changes)
+		if opts.MaxResults > 0 && preview.Changes >= opts.MaxResults {
+			return errLimit
+		}
+		return nil
+	})
+	if walkErr != nil && !errors.Is(walkErr, errLimit) {
+		return preview, walkErr
+	}
+	return preview, nil
+}
+
+// ReplaceInWorkspace applies the selected changes of a preview. Every file is
+// checked against its preview hash before anything is written, and files
+// already written are restored when a later write fails, so either all
+// files change or none do. The returned undo reverts the whole replacement.
+func (s *FileService) ReplaceInWorkspace(root string, opts ReplaceOptions, selections []ReplaceSelection) (ReplaceResult, *ReplaceUndo, error) {
+	result := ReplaceResult{Files: []string{}}
+	re, err := CompileSearchPattern(opts.SearchOptions)
+	if err != nil {
+		return result, nil, err
+	}
+
+	clean := filepath.Clean(root)
+	undo := &ReplaceUndo{service: s}
+	for _, selection := range selections {
+		if len(selection.Offsets) == 0 {
+			continue
+		}
+		path := filepath.Clean(selection.Path)
+		if !isWithinRoot(clean, path) || IsEncryptedNote(path) {
+			return result, nil, fmt.Errorf("%s is not a file of the workspace", selection.Path)
+		}
+		data, err := s.ReadBytes(path)
+		if err != nil {
+			return result, nil, err
+		}
+		if contentHash(data) != selection.Hash {
+			return result, nil, fmt.Errorf("%s changed since the preview; search again", filepath.Base(path))
+		}
+
+		wanted := map[int]bool{}
+		for _, offset := range selection.Offsets {
+			wanted[offset] = true
+		}
+		edits := []textEdit{}
+		for _, match := range findReplacements(string(data), re, opts) {
+			if wanted[match.start] {
+				edits = append(edits, textEdit{start: match.start, end: match.end, text: match.replacement})
+			}
+		}
+		if len(edits) != len(wanted) {
+			return result, nil, fmt.Errorf("%s no longer matches the preview; search again", filepath.Base(path))
+		}
+		after, _ := applyTextEdits(string(data), edits)
+		undo.files = append(undo.files, replaceSnapshot{path: path, before: data, after: after})
+		result.Changes += len(edits)
+	}
+
+	written, err := s.writeSnapshots(undo.files, false)
+	if err != nil {
+		return result, nil, err
+	}
+	result.Files = written
+	undo.changes = result.Changes
+	return result, undo, nil
+}
+
+// Files returns the files the replacement rewrote.
+func (u *ReplaceUndo) Files() []string {
+	paths := make([]string, len(u.files))
+	for i, snapshot := range u.files {
+		paths[i] = snapshot.path
+	}
+	return paths
+}
+
+// Revert restores the files to their content before the replacement. A
+// file edited since then is left alone and the revert is refused.
+func (u *ReplaceUndo) Revert() (ReplaceResult, error) {
+	result := ReplaceResult{Files: []string{}}
+	for _, snapshot := range u.files {
+		data, err := u.service.ReadBytes(snapshot.path)
+		if err != nil {
+			return result, err
+		}
+		if string(data) != snapshot.after {
+			return result, fmt.Errorf("%s changed after the replacement; undo is no longer possible", filepath.Base(snapshot.path))
+		}
+	}
+	written, err := u.service.writeSnapshots(u.files, true)
+	if err != nil {
+		return result, err
+	}
+	result.Files, result.Changes = written, u.changes
+	return result, nil
+}
+
+// writeSnapshots writes the content after the replacement to every file,
+// or the content before it when reverting, restoring the files already
+// written when one fails.
+func (s *FileService) writeSnapshots(snapshots []replaceSnapshot, revert bool) ([]string, error) {
+	content := func(snapshot replaceSnapshot, revert bool) []byte {
+		if revert {
+			return snapshot.before
+		}
+		return []byte(snapshot.after)
+	}
+	written := []string{}
+	for i, snapshot := range snapshots {
+		if err := s.writeAtomic(snapshot.path, content(snapshot, revert)); err != nil {
+			// 回滚已写入的文件，保证要么全部替换要么全部不变
+			for _, done := range snapshots[:i] {
+				_ = s.writeAtomic(done.path, content(done, !revert))
+			}
+			return []string{}, err
+		}
+		written = append(written, snapshot.path)
+	}
+	return written, nil
+}
+
+// findReplacements returns the non-empty matches of re with their expanded
+// replacements, in the order SearchContent reports them.
+func findReplacements(content string, re *regexp.Regexp, opts ReplaceOptions) []replaceMatch {
+	matches := []replaceMatch{}
+	for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		replacement := opts.Replacement
+		if opts.Regex {
+			replacement = string(re.ExpandString(nil, opts.Replacement, content, loc))
+		}
+		matches = append(matches, replaceMatch{start: loc[0], end: loc[1], replacement: replacement})
+	}
+	return matches
+}
+
+func contentHash(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
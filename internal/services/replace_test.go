@@ -0,0 +1,205 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replaceSummary lists the changes of a preview as "rel:line:column match>replacement".
+func replaceSummary(preview ReplacePreview) []string {
+	summary := []string{}
+	for _, file := range preview.Files {
+		for _, change := range file.Changes {
+			summary = append(summary, fmt.Sprintf("%s:%d:%d %s>%s", file.RelPath, change.Line, change.Column, change.Match, change.Replacement))
+		}
+	}
+	return summary
+}
+
+// selectAll picks every change of a preview.
+func selectAll(preview ReplacePreview) []ReplaceSelection {
+	selections := []ReplaceSelection{}
+	for _, file := range preview.Files {
+		selection := ReplaceSelection{Path: file.Path, Hash: file.Hash}
+		for _, change := range file.Changes {
+			selection.Offsets = append(selection.Offsets, change.Offset)
+		}
+		selections = append(selections, selection)
+	}
+	return selections
+}
+
+func TestPreviewReplace(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.md":          "Draft 1 and draft 2\n",
+		"c.txt":         "draft 5\n",
+		"notes/b.md":    "intro\ndraft 3\n",
+		"notes/skip.md": "draft 4\n",
+	})
+
+	tests := []struct {
+		name string
+		opts ReplaceOptions
+		want []string
+	}{
+		{
+			name: "numbered captures",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: `draft (\d)`, Regex: true}, Replacement: "v$1"},
+			want: []string{"a.md:1:1 Draft 1>v1", "a.md:1:13 draft 2>v2", "notes/b.md:2:1 draft 3>v3", "notes/skip.md:1:1 draft 4>v4"},
+		},
+		{
+			name: "named captures",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: `(?P<word>draft) (?P<n>\d)`, Regex: true, CaseSensitive: true}, Replacement: "${n}-${word}"},
+			want: []string{"a.md:1:13 draft 2>2-draft", "notes/b.md:2:1 draft 3>3-draft", "notes/skip.md:1:1 draft 4>4-draft"},
+		},
+		{
+			name: "literal replacement",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: "draft 1"}, Replacement: "$1"},
+			want: []string{"a.md:1:1 Draft 1>$1"},
+		},
+		{
+			name: "include and exclude",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: "draft", Include: []string{"notes/*.md"}, Exclude: []string{"skip.md"}}, Replacement: "x"},
+			want: []string{"notes/b.md:2:1 draft>x"},
+		},
+		{
+			name: "excluded folder",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: "draft", Exclude: []string{"notes/"}}, Replacement: "x"},
+			want: []string{"a.md:1:1 Draft>x", "a.md:1:13 draft>x"},
+		},
+		{
+			name: "all files",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: "draft", AllFiles: true, Include: []string{"*.txt"}}, Replacement: "x"},
+			want: []string{"c.txt:1:1 draft>x"},
+		},
+		{
+			name: "limit",
+			opts: ReplaceOptions{SearchOptions: SearchOptions{Query: "draft", MaxResults: 3}, Replacement: "x"},
+			want: []string{"a.md:1:1 Draft>x", "a.md:1:13 draft>x", "notes/b.md:2:1 draft>x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, err := NewFileService().PreviewReplace(root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := replaceSummary(preview); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %q, want %q", got, tt.want)
+			}
+			if preview.Changes != len(tt.want) {
+				t.Errorf("Changes = %d, want %d", preview.Changes, len(tt.want))
+			}
+		})
+	}
+
+	preview, err := NewFileService().PreviewReplace(root, tests[0].opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := preview.Files[0].Changes[1]; change.Text != "Draft 1 and draft 2" || change.Preview != "Draft 1 and v2" {
+		t.Errorf("change = %+v", change)
+	}
+}
+
+func TestReplaceInWorkspace(t *testing.T) {
+	files := NewFileService()
+	opts := ReplaceOptions{SearchOptions: SearchOptions{Query: `draft (\d)`, Regex: true}, Replacement: "v$1"}
+	setup := func(t *testing.T) (string, ReplacePreview) {
+		t.Helper()
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{"a.md": "draft 1 and draft 2\n", "b.md": "draft 3\n"})
+		preview, err := files.PreviewReplace(root, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root, preview
+	}
+
+	t.Run("selected changes", func(t *testing.T) {
+		root, preview := setup(t)
+		selections := selectAll(preview)
+		selections[0].Offsets = selections[0].Offsets[1:]
+		result, _, err := files.ReplaceInWorkspace(root, opts, selections)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Changes != 2 || len(result.Files) != 2 {
+			t.Errorf("result = %+v", result)
+		}
+		want := map[string]string{"a.md": "draft 1 and v2\n", "b.md": "v3\n"}
+		if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("tree = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stale preview", func(t *testing.T) {
+		root, preview := setup(t)
+		writeTree(t, root, map[string]string{"b.md": "draft 3 edited\n"})
+		_, _, err := files.ReplaceInWorkspace(root, opts, selectAll(preview))
+		if err == nil || !strings.Contains(err.Error(), "changed since the preview") {
+			t.Fatalf("err = %v", err)
+		}
+		want := map[string]string{"a.md": "draft 1 and draft 2\n", "b.md": "draft 3 edited\n"}
+		if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("tree = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("failed write rolls back", func(t *testing.T) {
+		root := t.TempDir()
+		// 文件名本身合法，但写入用的临时文件名超出长度限制
+		long := strings.Repeat("n", 245) + ".md"
+		writeTree(t, root, map[string]string{"a.md": "draft 1\n", long: "draft 2\n"})
+		preview, err := files.PreviewReplace(root, opts)
+		if err != nil || len(preview.Files) != 2 || preview.Files[0].RelPath != "a.md" {
+			t.Fatalf("preview = %+v, %v", preview, err)
+		}
+		if _, _, err := files.ReplaceInWorkspace(root, opts, selectAll(preview)); err == nil {
+			t.Fatal("replacement with a failing write succeeded")
+		}
+		want := map[string]string{"a.md": "draft 1\n", long: "draft 2\n"}
+		if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("tree = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("undo", func(t *testing.T) {
+		root, preview := setup(t)
+		_, undo, err := files.ReplaceInWorkspace(root, opts, selectAll(preview))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := undo.Revert()
+		if err != nil || result.Changes != 3 || len(result.Files) != 2 {
+			t.Fatalf("Revert = %+v, %v", result, err)
+		}
+		want := map[string]string{"a.md": "draft 1 and draft 2\n", "b.md": "draft 3\n"}
+		if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("tree = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("undo after a later edit", func(t *testing.T) {
+		root, preview := setup(t)
+		_, undo, err := files.ReplaceInWorkspace(root, opts, selectAll(preview))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "b.md"), []byte("v3 and more\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := undo.Revert(); err == nil || !strings.Contains(err.Error(), "b.md changed") {
+			t.Fatalf("Revert err = %v", err)
+		}
+		want := map[string]string{"a.md": "v1 and v2\n", "b.md": "v3 and more\n"}
+		if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("tree = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrS3NotFound is returned when an object does not exist.
+var ErrS3NotFound = errors.New("object not found")
+
+const s3RequestTimeout = 2 * time.Minute
+
+// S3Client is a minimal client for S3-compatible object storage (AWS, MinIO,
+// Ceph, ...). Requests use path-style addressing and AWS Signature Version 4.
+type S3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+	now       func() time.Time
+}
+
+// S3Object describes a listed object.
+type S3Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// NewS3Client creates a client for bucket at endpoint, e.g. "http://localhost:9000".
+func NewS3Client(endpoint string, region string, bucket string, accessKey string, secretKey string) (*S3Client, error) {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("unsupported S3 endpoint %q", endpoint)
+	}
+	if strings.TrimSpace(bucket) == "" {
+		return nil, errors.New("bucket is required")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	return &S3Client{
+		endpoint:  parsed,
+		region:    region,
+		bucket:    strings.TrimSpace(bucket),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		http:      &http.Client{Timeout: s3RequestTimeout},
+		now:       time.Now,
+	}, nil
+}
+
+// PutObject uploads data under key.
+func (c *S3Client) PutObject(ctx context.Context, key string, data []byte) error {
+	response, err := c.do(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return s3StatusError(http.MethodPut, key, response)
+	}
+	return nil
+}
+
+// GetObject downloads the object stored under key.
+func (c *S3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	response, err := c.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, s3StatusError(http.MethodGet, key, response)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// DeleteObject removes key; deleting a missing object is not an error.
+func (c *S3Client) DeleteObject(ctx context.Context, key string) error {
+	response, err := c.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotFound {
+		return s3StatusError(http.MethodDelete, key, response)
+	}
+	return nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ListObjects returns every object whose key starts with prefix.
+func (c *S3Client) ListObjects(ctx context.Context, prefix string) ([]S3Object, error) {
+	objects := []S3Object{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		response, err := c.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			err := s3StatusError("LIST", prefix, response)
+			response.Body.Close()
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("LIST %s: %w", prefix, err)
+		}
+
+		for _, item := range result.Contents {
+			modified, _ := time.Parse(time.RFC3339, item.LastModified)
+			objects = append(objects, S3Object{Key: item.Key, Size: item.Size, LastModified: modified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (c *S3Client) do(ctx context.Context, method string, key string, query url.Values, body []byte) (*http.Response, error) {
+	target := *c.endpoint
+	target.Path = c.endpoint.Path + "/" + c.bucket
+	if key != "" {
+		target.Path += "/" + key
+	}
+	target.RawPath = s3EscapePath(target.Path)
+	target.RawQuery = s3CanonicalQuery(query)
+
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	request, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	request.ContentLength = int64(len(body))
+	c.sign(request, target.RawPath, body)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = cancelOnClose{response.Body, cancel}
+	return response, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header.
+func (c *S3Client) sign(request *http.Request, canonicalURI string, body []byte) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := hashBytes(body)
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.accessKey == "" {
+		// 匿名访问（例如公开桶）不签名
+		return
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		canonicalURI,
+		request.URL.RawQuery,
+		"host:" + request.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashBytes([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), day)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath percent-encodes every byte outside the SigV4 unreserved set,
+// keeping the slashes between segments.
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' {
+			builder.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", b)
+	}
+	return builder.String()
+}
+
+func s3StatusError(method string, key string, response *http.Response) error {
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, key, ErrS3NotFound)
+	}
+	var detail struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+	if xml.Unmarshal(data, &detail) == nil && detail.Code != "" {
+		return fmt.Errorf("%s %s: %s: %s", method, key, detail.Code, detail.Message)
+	}
+	return fmt.Errorf("%s %s: %s", method, key, response.Status)
+}
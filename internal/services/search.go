@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchOptions configures a workspace text search.
+type SearchOptions struct {
+	Query         string   `json:"query"`
+	Regex         bool     `json:"regex"`
+	CaseSensitive bool     `json:"caseSensitive"`
+	WholeWord     bool     `json:"wholeWord"`
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	AllFiles      bool     `json:"allFiles"`
+	MaxResults    int      `json:"maxResults"`
+}
+
+// SearchMatch is a single hit. Line and Column are 1-based; Column and Length count runes.
+type SearchMatch struct {
+	Path    string `json:"path"`
+	RelPath string `json:"relPath"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Length  int    `json:"length"`
+	Text    string `json:"text"`
+}
+
+// CompileSearchPattern turns search options into a regular expression.
+func CompileSearchPattern(opts SearchOptions) (*regexp.Regexp, error) {
+	if opts.Query == "" {
+		return nil, errors.New("search query is required")
+	}
+
+	pattern := opts.Query
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	// 多行模式让 ^ 和 $ 匹配每一行
+	return regexp.Compile("(?m)" + pattern)
+}
+
+// SearchWorkspace scans files beneath root for the query and returns every match.
+func SearchWorkspace(root string, opts SearchOptions) ([]SearchMatch, error) {
+	re, err := CompileSearchPattern(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	clean := filepath.Clean(root)
+	matches := []SearchMatch{}
+	errLimit := errors.New("limit reached")
+
+	walkErr := WalkWorkspaceFiles(clean, !opts.AllFiles, func(path string, _ fs.FileInfo) error {
+		rel, err := filepath.Rel(clean, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !MatchGlobs(rel, opts.Include, opts.Exclude) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || isBinaryContent(data) {
+			return nil
+		}
+
+		for _, match := range SearchContent(string(data), re) {
+			match.Path = path
+			match.RelPath = rel
+			matches = append(matches, match)
+			if opts.MaxResults > 0 && len(matches) >= opts.MaxResults {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	if walkErr != nil && !errors.Is(walkErr, errLimit) {
+		return nil, walkErr
+	}
+	return matches, nil
+}
+
+// SearchContent finds every match of re inside content.
+func SearchContent(content string, re *regexp.Regexp) []SearchMatch {
+	matches := []SearchMatch{}
+	lineStart := 0
+	line := 1
+
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		for {
+			next := strings.IndexByte(content[lineStart:], '\n')
+			if next < 0 || lineStart+next >= loc[0] {
+				break
+			}
+			lineStart += next + 1
+			line++
+		}
+
+		lineEnd := strings.IndexByte(content[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += lineStart
+		}
+
+		matches = append(matches, SearchMatch{
+			Line:   line,
+			Column: utf8.RuneCountInString(content[lineStart:loc[0]]) + 1,
+			Length: utf8.RuneCountInString(content[loc[0]:loc[1]]),
+			Text:   strings.TrimRight(content[lineStart:lineEnd], "\r"),
+		})
+	}
+	return matches
+}
+
+// MatchGlobs reports whether a slash-separated relative path passes the include
+// and exclude globs. Patterns without a slash match the base name; `**` spans folders.
+func MatchGlobs(rel string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matchGlob(pattern, rel) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlob(pattern string, rel string) bool {
+	pattern = strings.TrimSpace(filepathToSlash(pattern))
+	if pattern == "" {
+		return false
+	}
+	target := rel
+	if !strings.Contains(pattern, "/") {
+		target = rel[strings.LastIndex(rel, "/")+1:]
+	}
+	pattern = strings.TrimPrefix(pattern, "./")
+
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	// 目录模式（如 drafts/）匹配其下所有文件
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(target, pattern) || re.MatchString(target)
+	}
+	return re.MatchString(target)
+}
+
+func globToRegexp(pattern string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					builder.WriteString("(?:.*/)?")
+				} else {
+					builder.WriteString(".*")
+				}
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if strings.HasSuffix(pattern, "/") {
+		builder.WriteString(".*")
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+func isBinaryContent(data []byte) bool {
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	return bytes.IndexByte(sample, 0) >= 0
+}
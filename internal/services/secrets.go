@@ -0,0 +1,33 @@
+package services
+
+import "errors"
+
+// ErrNoSecretStore is returned when the system offers no credential store.
+var ErrNoSecretStore = errors.New("no credential store is available")
+
+const secretService = "MarkdownDaoNote"
+
+// SecretStore keeps credentials such as S3 secret keys out of settings.json:
+// in the login keychain on macOS, the Secret Service (via secret-tool) on
+// Linux and files encrypted with DPAPI on Windows.
+type SecretStore struct {
+	dir string
+}
+
+// NewSecretStore creates a store; dir holds the encrypted files on Windows.
+func NewSecretStore(dir string) *SecretStore {
+	return &SecretStore{dir: dir}
+}
+
+// Get returns the secret saved under name, or "" when there is none.
+func (s *SecretStore) Get(name string) (string, error) {
+	return s.load(name)
+}
+
+// Set saves secret under name; an empty secret deletes it.
+func (s *SecretStore) Set(name string, secret string) error {
+	if secret == "" {
+		return s.remove(name)
+	}
+	return s.store(name, secret)
+}
@@ -0,0 +1,61 @@
+//go:build darwin
+
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// security 在条目不存在时以 44 退出
+const keychainItemNotFound = 44
+
+func (s *SecretStore) load(name string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", secretService, "-a", name, "-w").Output()
+	if err != nil {
+		return "", keychainError(err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func (s *SecretStore) store(name string, secret string) error {
+	// 通过 -i 从标准输入传入命令，密钥不出现在进程参数里
+	command := exec.Command("security", "-i")
+	command.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		keychainQuote(secretService), keychainQuote(name), keychainQuote(secret)))
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return keychainError(err)
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return fmt.Errorf("keychain: %s", message)
+	}
+	return nil
+}
+
+func (s *SecretStore) remove(name string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", secretService, "-a", name).Run()
+	if err != nil {
+		return keychainError(err)
+	}
+	return nil
+}
+
+func keychainQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func keychainError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == keychainItemNotFound {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrNoSecretStore
+	}
+	return fmt.Errorf("keychain: %w", err)
+}
@@ -0,0 +1,62 @@
+//go:build !windows && !darwin
+
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+func (s *SecretStore) load(name string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("secret-tool", "lookup", "service", secretService, "account", name)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		// 找不到条目时 secret-tool 以 1 退出且没有错误信息
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.TrimSpace(stderr.String()) == "" {
+			return "", nil
+		}
+		return "", secretToolError(err, &stderr)
+	}
+	return stdout.String(), nil
+}
+
+func (s *SecretStore) store(name string, secret string) error {
+	var stderr bytes.Buffer
+	command := exec.Command("secret-tool", "store", "--label", secretService+" "+name, "service", secretService, "account", name)
+	command.Stdin = strings.NewReader(secret)
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return secretToolError(err, &stderr)
+	}
+	return nil
+}
+
+func (s *SecretStore) remove(name string) error {
+	var stderr bytes.Buffer
+	command := exec.Command("secret-tool", "clear", "service", secretService, "account", name)
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.TrimSpace(stderr.String()) == "" {
+			return nil
+		}
+		return secretToolError(err, &stderr)
+	}
+	return nil
+}
+
+func secretToolError(err error, stderr *bytes.Buffer) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%w: install secret-tool (libsecret)", ErrNoSecretStore)
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return fmt.Errorf("secret-tool: %s", message)
+	}
+	return fmt.Errorf("secret-tool: %w", err)
+}
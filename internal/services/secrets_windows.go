@@ -0,0 +1,74 @@
+//go:build windows
+
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func (s *SecretStore) path(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(s.dir, "secrets", hex.EncodeToString(sum[:]))
+}
+
+func (s *SecretStore) load(name string) (string, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	plain, err := dpapi(data, false)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (s *SecretStore) store(name string, secret string) error {
+	data, err := dpapi([]byte(secret), true)
+	if err != nil {
+		return err
+	}
+	path := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (s *SecretStore) remove(name string) error {
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// dpapi encrypts or decrypts data with the key of the current Windows user.
+func dpapi(data []byte, protect bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	var err error
+	if protect {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
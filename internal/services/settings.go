@@ -21,6 +21,24 @@ type Settings struct {
 	LastFile     string `json:"lastFile"`
 	EditorTheme  string `json:"editorTheme"`
 	PreviewTheme string `json:"previewTheme"`
+	// Workspaces lists recently opened workspace folders, most recent first.
+	// markdowndaonote:// links may only reach files inside these folders.
+	Workspaces []string `json:"workspaces,omitempty"`
+	// SyncTargets holds the WebDAV sync configuration of each workspace.
+	SyncTargets []SyncTarget `json:"syncTargets,omitempty"`
+	// BackupTargets holds the S3 backup configuration of each workspace.
+	BackupTargets []BackupTarget `json:"backupTargets,omitempty"`
+	// EncryptionTimeoutMinutes is how long the key of an encrypted note stays
+	// in memory without being used; 0 means the default of 15 minutes.
+	EncryptionTimeoutMinutes int `json:"encryptionTimeoutMinutes,omitempty"`
+	// PeriodicNotes places the daily, weekly, monthly and yearly notes.
+	PeriodicNotes PeriodicSettings `json:"periodicNotes"`
+	// FormatOnSave formats Markdown notes with FormatDocument when saving.
+	FormatOnSave bool `json:"formatOnSave,omitempty"`
+	// TOCOnSave refreshes the marked table of contents of notes when saving.
+	TOCOnSave bool `json:"tocOnSave,omitempty"`
+	// Spellcheck selects the dictionaries for checking spelling as you type.
+	Spellcheck SpellcheckSettings `json:"spellcheck"`
 }
 
 // SettingsService manages persistence of editor settings.
@@ -39,6 +57,11 @@ func NewSettingsService() *SettingsService {
 	return &SettingsService{path: path}
 }
 
+// Dir returns the folder holding the settings file and other local state.
+func (s *SettingsService) Dir() string {
+	return filepath.Dir(s.path)
+}
+
 // Load reads settings from disk or returns defaults.
 func (s *SettingsService) Load() (Settings, error) {
 	defaults := Settings{
@@ -48,6 +71,7 @@ func (s *SettingsService) Load() (Settings, error) {
 		WordWrap:     true,
 		EditorTheme:  "default",
 		PreviewTheme: "default",
+		Spellcheck:   SpellcheckSettings{Enabled: true},
 	}
 
 	data, err := os.ReadFile(s.path)
@@ -75,8 +99,9 @@ func (s *SettingsService) Save(settings Settings) error {
 		return err
 	}
 
+	// 设置中包含同步与备份凭据，仅当前用户可读
 	tmp := s.path + ".tmp"
-	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
 		return err
 	}
 
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+const (
+	// DictionaryDirName is the folder of Hunspell dictionaries in the
+	// settings directory; each language is a <name>.aff and <name>.dic pair,
+	// directly inside or in a subfolder.
+	DictionaryDirName = "dictionaries"
+	// PersonalDictionaryName holds the words added by the user, one per line,
+	// next to settings.json.
+	PersonalDictionaryName = "dictionary.txt"
+	// WorkspaceWordListName holds the words accepted in one workspace, one per
+	// line, at the workspace root.
+	WorkspaceWordListName = ".markdowndaonote-words.txt"
+)
+
+// ErrNoDictionary is returned when none of the requested languages has an
+// installed dictionary.
+var ErrNoDictionary = errors.New("no spell-check dictionary installed")
+
+// SpellcheckSettings turns spell checking on and picks the dictionaries used
+// for notes that do not name their own languages. No languages means every
+// installed dictionary.
+type SpellcheckSettings struct {
+	Enabled   bool     `json:"enabled"`
+	Languages []string `json:"languages,omitempty"`
+}
+
+// SpellingError is a misspelled word with 1-based positions; columns count
+// characters and the end is exclusive.
+type SpellingError struct {
+	Word        string   `json:"word"`
+	Line        int      `json:"line"`
+	Column      int      `json:"column"`
+	EndLine     int      `json:"endLine"`
+	EndColumn   int      `json:"endColumn"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// SpellChecker checks notes against the Hunspell dictionaries installed in
+// the settings directory, loading each dictionary on first use.
+type SpellChecker struct {
+	dir string
+
+	mu           sync.Mutex
+	dictionaries map[string]*Dictionary
+}
+
+// NewSpellChecker returns a checker using the dictionaries and personal
+// dictionary of the settings directory dir.
+func NewSpellChecker(dir string) *SpellChecker {
+	return &SpellChecker{dir: dir, dictionaries: map[string]*Dictionary{}}
+}
+
+// DictionaryDir returns where dictionaries are installed.
+func (c *SpellChecker) DictionaryDir() string {
+	return filepath.Join(c.dir, DictionaryDirName)
+}
+
+// Languages lists the installed dictionaries by name, such as en_US.
+func (c *SpellChecker) Languages() ([]string, error) {
+	paths, err := c.dictionaryPaths()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// dictionaryPaths maps dictionary names to their .aff files.
+func (c *SpellChecker) dictionaryPaths() (map[string]string, error) {
+	paths := map[string]string{}
+	for _, pattern := range []string{"*.aff", filepath.Join("*", "*.aff")} {
+		matches, err := filepath.Glob(filepath.Join(c.DictionaryDir(), pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, aff := range matches {
+			name := strings.TrimSuffix(filepath.Base(aff), filepath.Ext(aff))
+			if _, err := os.Stat(strings.TrimSuffix(aff, filepath.Ext(aff)) + ".dic"); err != nil {
+				continue
+			}
+			if _, ok := paths[name]; !ok {
+				paths[name] = aff
+			}
+		}
+	}
+	return paths, nil
+}
+
+// load returns the dictionaries matching languages. A language matches a
+// dictionary of the same name ignoring case and "-"/"_", or, given only a
+// language code such as "en", the first dictionary of that language.
+func (c *SpellChecker) load(languages []string) ([]*Dictionary, error) {
+	paths, err := c.dictionaryPaths()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var dictionaries []*Dictionary
+	loaded := map[string]bool{}
+	for _, language := range languages {
+		name := matchDictionary(names, language)
+		if name == "" || loaded[name] {
+			continue
+		}
+		loaded[name] = true
+		dictionary, ok := c.dictionaries[name]
+		if !ok {
+			aff := paths[name]
+			if dictionary, err = LoadDictionary(aff, strings.TrimSuffix(aff, filepath.Ext(aff))+".dic"); err != nil {
+				return nil, err
+			}
+			c.dictionaries[name] = dictionary
+		}
+		dictionaries = append(dictionaries, dictionary)
+	}
+	if len(dictionaries) == 0 {
+		return nil, fmt.Errorf("%w for %s in %s", ErrNoDictionary, strings.Join(languages, ", "), c.DictionaryDir())
+	}
+	return dictionaries, nil
+}
+
+func matchDictionary(names []string, language string) string {
+	wanted := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(language), "-", "_"))
+	if wanted == "" {
+		return ""
+	}
+	for _, name := range names {
+		if strings.ToLower(name) == wanted {
+			return name
+		}
+	}
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), wanted+"_") {
+			return name
+		}
+	}
+	return ""
+}
+
+// DocumentLanguages returns the languages a note names in its front matter,
+// as spellcheck: [en_US, de_DE] or lang: en, or fallback when it names none.
+// spellcheck: false turns checking off and returns nil.
+func DocumentLanguages(content string, fallback []string) []string {
+	fields, _, err := ParseFrontMatter(content)
+	if err != nil {
+		return fallback
+	}
+	if value, ok := fields["spellcheck"]; ok {
+		switch v := value.(type) {
+		case bool:
+			if !v {
+				return nil
+			}
+		case string:
+			if languages := strings.FieldsFunc(v, isLanguageSeparator); len(languages) > 0 {
+				return languages
+			}
+		case []interface{}:
+			var languages []string
+			for _, item := range v {
+				if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+					languages = append(languages, strings.TrimSpace(s))
+				}
+			}
+			if len(languages) > 0 {
+				return languages
+			}
+		}
+	}
+	for _, key := range []string{"lang", "language"} {
+		if v, ok := fields[key].(string); ok {
+			if languages := strings.FieldsFunc(v, isLanguageSeparator); len(languages) > 0 {
+				return languages
+			}
+		}
+	}
+	return fallback
+}
+
+func isLanguageSeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
+// 不参与拼写检查的片段：URL、邮箱、wiki 链接与 #标签
+var spellSkipPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.|mailto:)[^\s<>()\[\]]+`),
+	regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`),
+	regexp.MustCompile(`\[\[[^\]\n]*\]\]`),
+	regexp.MustCompile(`(?:^|\s)#[\p{L}\p{N}_/-]+`),
+}
+
+// CheckDocument returns the misspelled words of content outside front
+// matter, code, HTML, URLs, wiki links and tags. A word is correct when any
+// of the languages' dictionaries accepts it or it is listed in words, where
+// a lowercase entry accepts every capitalization.
+func (c *SpellChecker) CheckDocument(content string, languages []string, words []string) ([]SpellingError, error) {
+	misspelled := []SpellingError{}
+	if len(languages) == 0 {
+		return misspelled, nil
+	}
+	dictionaries, err := c.load(languages)
+	if err != nil {
+		return misspelled, err
+	}
+
+	// 词典 WORDCHARS 中的标点（如 - 和 .）位于字母之间时属于单词
+	inner := "'’"
+	for _, dictionary := range dictionaries {
+		for _, r := range dictionary.wordChars {
+			if unicode.IsPunct(r) && !strings.ContainsRune(inner, r) {
+				inner += string(r)
+			}
+		}
+	}
+
+	known := newWordList(words)
+	doc := parseDocument(content)
+	var skipped [][2]int
+	for _, pattern := range spellSkipPatterns {
+		for _, match := range pattern.FindAllIndex(doc.source, -1) {
+			skipped = append(skipped, [2]int{match[0], match[1]})
+		}
+	}
+
+	checked := map[string]bool{}
+	suggestions := map[string][]string{}
+	isCorrect := func(word string) bool {
+		if correct, ok := checked[word]; ok {
+			return correct
+		}
+		correct := known.has(word)
+		for _, dictionary := range dictionaries {
+			if correct {
+				break
+			}
+			correct = dictionary.Check(word) ||
+				strings.ContainsRune(word, '’') && dictionary.Check(strings.ReplaceAll(word, "’", "'"))
+		}
+		checked[word] = correct
+		return correct
+	}
+
+	_ = ast.Walk(doc.root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.CodeSpan, *ast.AutoLink, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			spellTokens(doc.source, n.Segment.Start, n.Segment.Stop, inner, func(start int, end int) {
+				word := string(doc.source[start:end])
+				if !isSpellable(word) || overlapsRange(skipped, start, end) || isCorrect(word) {
+					return
+				}
+				if _, ok := suggestions[word]; !ok {
+					suggestions[word] = suggestSpelling(dictionaries, word, 6)
+				}
+				line, column := doc.index.position(doc.offset + start)
+				endLine, endColumn := doc.index.position(doc.offset + end)
+				misspelled = append(misspelled, SpellingError{
+					Word: word, Line: line, Column: column, EndLine: endLine, EndColumn: endColumn,
+					Suggestions: suggestions[word],
+				})
+			})
+		}
+		return ast.WalkContinue, nil
+	})
+	return misspelled, nil
+}
+
+// spellTokens calls visit with the byte range of each word between start and
+// stop: letters, marks and digits, joined by the inner characters when a
+// letter follows.
+func spellTokens(source []byte, start int, stop int, inner string, visit func(start int, end int)) {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r)
+	}
+	for i := start; i < stop; {
+		r, size := utf8.DecodeRune(source[i:stop])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+		begin := i
+		for i < stop {
+			r, size := utf8.DecodeRune(source[i:stop])
+			if isWordRune(r) {
+				i += size
+				continue
+			}
+			if strings.ContainsRune(inner, r) && i+size < stop {
+				if next, _ := utf8.DecodeRune(source[i+size : stop]); unicode.IsLetter(next) {
+					i += size
+					continue
+				}
+			}
+			break
+		}
+		visit(begin, i)
+	}
+}
+
+// isSpellable skips single letters, words with digits and scripts written
+// without spaces, which Hunspell dictionaries cannot check.
+func isSpellable(word string) bool {
+	if utf8.RuneCountInString(word) < 2 {
+		return false
+	}
+	for _, r := range word {
+		if unicode.IsDigit(r) || unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana,
+			unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar, unicode.Tibetan) {
+			return false
+		}
+	}
+	return true
+}
+
+func overlapsRange(ranges [][2]int, start int, end int) bool {
+	for _, r := range ranges {
+		if start < r[1] && r[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestSpelling merges the suggestions of each dictionary in turn.
+func suggestSpelling(dictionaries []*Dictionary, word string, limit int) []string {
+	lists := make([][]string, len(dictionaries))
+	for i, dictionary := range dictionaries {
+		lists[i] = dictionary.Suggest(word, limit)
+	}
+	merged := []string{}
+	seen := map[string]bool{}
+	for i := 0; len(merged) < limit; i++ {
+		progressed := false
+		for _, list := range lists {
+			if i >= len(list) {
+				continue
+			}
+			progressed = true
+			if !seen[list[i]] && len(merged) < limit {
+				seen[list[i]] = true
+				merged = append(merged, list[i])
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return merged
+}
+
+// wordList is a set of accepted words. A lowercase entry accepts every
+// capitalization and a capitalized one its upper-case form.
+type wordList map[string]bool
+
+func newWordList(words []string) wordList {
+	list := wordList{}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			list[word] = true
+		}
+	}
+	return list
+}
+
+func (l wordList) has(word string) bool {
+	if l[word] {
+		return true
+	}
+	lower := strings.ToLower(word)
+	if l[lower] {
+		return true
+	}
+	return word == strings.ToUpper(word) && l[capitalize(lower)]
+}
+
+// PersonalWords returns the words of the personal dictionary.
+func (c *SpellChecker) PersonalWords() ([]string, error) {
+	return readWordList(filepath.Join(c.dir, PersonalDictionaryName))
+}
+
+// SetPersonalWords replaces the personal dictionary.
+func (c *SpellChecker) SetPersonalWords(words []string) error {
+	return writeWordList(filepath.Join(c.dir, PersonalDictionaryName), words)
+}
+
+// AddPersonalWord adds word to the personal dictionary.
+func (c *SpellChecker) AddPersonalWord(word string) error {
+	return addToWordList(filepath.Join(c.dir, PersonalDictionaryName), word)
+}
+
+// WorkspaceWords returns the word list of the workspace at root.
+func WorkspaceWords(root string) ([]string, error) {
+	return readWordList(filepath.Join(root, WorkspaceWordListName))
+}
+
+// AddWorkspaceWord adds word to the word list of the workspace at root.
+func AddWorkspaceWord(root string, word string) error {
+	return addToWordList(filepath.Join(root, WorkspaceWordListName), word)
+}
+
+func readWordList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	words := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		// # 开头的行是注释
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, nil
+}
+
+func writeWordList(path string, words []string) error {
+	unique := map[string]bool{}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" && !strings.HasPrefix(word, "#") {
+			unique[word] = true
+		}
+	}
+	sorted := make([]string, 0, len(unique))
+	for word := range unique {
+		sorted = append(sorted, word)
+	}
+	sort.Strings(sorted)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data := strings.Join(sorted, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	return writeFileAtomic(path, []byte(data))
+}
+
+func addToWordList(path string, word string) error {
+	word = strings.TrimSpace(word)
+	if word == "" || strings.ContainsAny(word, "\r\n") {
+		return fmt.Errorf("invalid word %q", word)
+	}
+	words, err := readWordList(path)
+	if err != nil {
+		return err
+	}
+	return writeWordList(path, append(words, word))
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestSpellChecker(t *testing.T) *SpellChecker {
+	t.Helper()
+	checker := NewSpellChecker(t.TempDir())
+	dir := filepath.Join(checker.DictionaryDir(), "en")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"en_TEST.aff", "en_TEST.dic"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return checker
+}
+
+func TestCheckDocument(t *testing.T) {
+	checker := newTestSpellChecker(t)
+	content := "---\n" +
+		"title: mispeled\n" +
+		"---\n" +
+		"# Cities and kindnesss\n" +
+		"\n" +
+		"Visit https://example.com/mispeled and see [[mispeled]] and #mispeled.\n" +
+		"Mail me@mispeled.org and <b title=\"mispeled\">the</b> e-mail.\n" +
+		"Use `mispeled` and\n" +
+		"\n" +
+		"```\n" +
+		"mispeled\n" +
+		"```\n" +
+		"\n" +
+		"    mispeled\n" +
+		"\n" +
+		"The world says wrld and Wrld.\n"
+
+	// 小写的自定义词接受任何大小写
+	got, err := checker.CheckDocument(content, []string{"en"}, []string{"wrld"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SpellingError{
+		{Word: "kindnesss", Line: 4, Column: 14, EndLine: 4, EndColumn: 23, Suggestions: []string{"kindness", "kindnesses"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %+v, want %+v", got, want)
+	}
+
+	got, err = checker.CheckDocument("The world says wrld and Wrld.\n", []string{"en_test"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := []string{}
+	for _, misspelled := range got {
+		words = append(words, misspelled.Word)
+	}
+	if !reflect.DeepEqual(words, []string{"wrld", "Wrld"}) {
+		t.Errorf("misspelled = %v", words)
+	}
+}
+
+func TestCheckDocumentWithoutDictionary(t *testing.T) {
+	checker := newTestSpellChecker(t)
+	if _, err := checker.CheckDocument("text", []string{"fr"}, nil); err == nil {
+		t.Error("checking against a missing dictionary succeeded")
+	}
+	if got, err := checker.CheckDocument("mispeled", nil, nil); err != nil || len(got) != 0 {
+		t.Errorf("no languages: %v, %v", got, err)
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// WritingHistoryName stores the words written per day in each workspace,
+// next to settings.json.
+const WritingHistoryName = "writing-history.json"
+
+// 阅读速度：拉丁文字每分钟 230 词，中日文每分钟 400 字
+const (
+	readingWordsPerMinute = 230
+	readingCJKPerMinute   = 400
+	// 历史记录只保留最近一年
+	writingHistoryDays = 366
+)
+
+// DocumentStats counts the prose of a note. Words count each Chinese or
+// Japanese character as one word; characters exclude Markdown syntax, code
+// blocks and front matter.
+type DocumentStats struct {
+	Words              int `json:"words"`
+	CJKCharacters      int `json:"cjkCharacters"`
+	Characters         int `json:"characters"`
+	CharactersNoSpaces int `json:"charactersNoSpaces"`
+	Paragraphs         int `json:"paragraphs"`
+	ReadingMinutes     int `json:"readingMinutes"`
+	Headings           int `json:"headings"`
+	Links              int `json:"links"`
+	Images             int `json:"images"`
+	CodeBlocks         int `json:"codeBlocks"`
+}
+
+// add accumulates other into s; reading time is recomputed from the totals.
+func (s *DocumentStats) add(other DocumentStats) {
+	s.Words += other.Words
+	s.CJKCharacters += other.CJKCharacters
+	s.Characters += other.Characters
+	s.CharactersNoSpaces += other.CharactersNoSpaces
+	s.Paragraphs += other.Paragraphs
+	s.Headings += other.Headings
+	s.Links += other.Links
+	s.Images += other.Images
+	s.CodeBlocks += other.CodeBlocks
+	s.ReadingMinutes = readingMinutes(s.Words-s.CJKCharacters, s.CJKCharacters)
+}
+
+// DocumentStatistics computes the statistics of a Markdown document.
+func DocumentStatistics(content string) DocumentStats {
+	stats := DocumentStats{}
+	_, body, _ := SplitFrontMatter(content)
+	source := []byte(body)
+	root := newMarkdown().Parser().Parse(text.NewReader(source))
+
+	var plain strings.Builder
+	_ = ast.Walk(root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if node.Type() == ast.TypeBlock {
+				plain.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Heading:
+			stats.Headings++
+		case *ast.Paragraph:
+			stats.Paragraphs++
+		case *ast.Link:
+			stats.Links++
+		case *ast.AutoLink:
+			// 自动链接的网址不计入正文字数
+			stats.Links++
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			stats.Images++
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			stats.CodeBlocks++
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			plain.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				plain.WriteByte(' ')
+			}
+		case *ast.String:
+			plain.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	stats.Words, stats.CJKCharacters = countWords(plain.String())
+	for _, r := range plain.String() {
+		if r == '\n' {
+			continue
+		}
+		stats.Characters++
+		if !unicode.IsSpace(r) {
+			stats.CharactersNoSpaces++
+		}
+	}
+	stats.ReadingMinutes = readingMinutes(stats.Words-stats.CJKCharacters, stats.CJKCharacters)
+	return stats
+}
+
+// countWords counts runs of letters and digits as words and each Chinese or
+// Japanese character as a word of its own, since those scripts are written
+// without spaces. Korean separates words with spaces and counts as words.
+func countWords(text string) (words int, cjk int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			words++
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		case r == '\'' || r == '’' || r == '-' || r == '_':
+			// 单词内的撇号和连字符不拆分单词
+		default:
+			inWord = false
+		}
+	}
+	return words, cjk
+}
+
+func readingMinutes(words int, cjk int) int {
+	if words <= 0 && cjk <= 0 {
+		return 0
+	}
+	seconds := words*60/readingWordsPerMinute + cjk*60/readingCJKPerMinute
+	minutes := (seconds + 59) / 60
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes
+}
+
+// NoteWords is the word count of one note in workspace statistics.
+type NoteWords struct {
+	Path    string `json:"path"`
+	RelPath string `json:"relPath"`
+	Words   int    `json:"words"`
+}
+
+// WorkspaceStats sums the statistics of every indexed note.
+type WorkspaceStats struct {
+	Notes   int           `json:"notes"`
+	Totals  DocumentStats `json:"totals"`
+	Longest []NoteWords   `json:"longest"`
+}
+
+// Stats aggregates the statistics of the indexed notes, listing the ten
+// longest.
+func (w *WorkspaceIndex) Stats() WorkspaceStats {
+	stats := WorkspaceStats{Longest: []NoteWords{}}
+	w.mu.RLock()
+	for _, meta := range w.notes {
+		stats.Notes++
+		stats.Totals.add(meta.Stats)
+		stats.Longest = append(stats.Longest, NoteWords{Path: meta.Path, RelPath: meta.RelPath, Words: meta.Stats.Words})
+	}
+	w.mu.RUnlock()
+
+	sort.Slice(stats.Longest, func(i, j int) bool {
+		if stats.Longest[i].Words != stats.Longest[j].Words {
+			return stats.Longest[i].Words > stats.Longest[j].Words
+		}
+		return stats.Longest[i].RelPath < stats.Longest[j].RelPath
+	})
+	if len(stats.Longest) > 10 {
+		stats.Longest = stats.Longest[:10]
+	}
+	return stats
+}
+
+// DailyWords is the net number of words written on a day (YYYY-MM-DD).
+type DailyWords struct {
+	Date  string `json:"date"`
+	Words int    `json:"words"`
+}
+
+// WritingProgress reports a workspace's daily goal, today's words, the
+// streak of days reaching the goal (any words when there is no goal) and
+// the recent days, oldest first.
+type WritingProgress struct {
+	Goal   int          `json:"goal"`
+	Today  int          `json:"today"`
+	Streak int          `json:"streak"`
+	Days   []DailyWords `json:"days"`
+}
+
+// WritingHistory records the words written per day in each workspace.
+type WritingHistory struct {
+	path string
+	mu   sync.Mutex
+}
+
+type writingHistoryFile struct {
+	Workspaces map[string]*workspaceWriting `json:"workspaces"`
+}
+
+type workspaceWriting struct {
+	Goal int            `json:"goal,omitempty"`
+	Days map[string]int `json:"days"`
+}
+
+// NewWritingHistory returns the history stored in the settings directory dir.
+func NewWritingHistory(dir string) *WritingHistory {
+	return &WritingHistory{path: filepath.Join(dir, WritingHistoryName)}
+}
+
+// Record adds words, which may be negative after deleting text, to the day
+// of now in the workspace at root.
+func (h *WritingHistory) Record(root string, now time.Time, words int) error {
+	if words == 0 {
+		return nil
+	}
+	return h.update(root, func(workspace *workspaceWriting) {
+		workspace.Days[now.Format("2006-01-02")] += words
+		// 清理一年前的记录，保持文件很小
+		cutoff := now.AddDate(0, 0, -writingHistoryDays).Format("2006-01-02")
+		for day := range workspace.Days {
+			if day < cutoff {
+				delete(workspace.Days, day)
+			}
+		}
+	})
+}
+
+// SetGoal sets the daily word goal of the workspace at root; 0 removes it.
+func (h *WritingHistory) SetGoal(root string, goal int) error {
+	if goal < 0 {
+		return errors.New("goal must not be negative")
+	}
+	return h.update(root, func(workspace *workspaceWriting) {
+		workspace.Goal = goal
+	})
+}
+
+// Progress returns the goal and the last days (including today) of the
+// workspace at root.
+func (h *WritingHistory) Progress(root string, now time.Time, days int) (WritingProgress, error) {
+	h.mu.Lock()
+	file, err := h.load()
+	h.mu.Unlock()
+	if err != nil {
+		return WritingProgress{Days: []DailyWords{}}, err
+	}
+	workspace := file.Workspaces[filepath.Clean(root)]
+	if workspace == nil {
+		workspace = &workspaceWriting{Days: map[string]int{}}
+	}
+	if days < 1 {
+		days = 1
+	}
+
+	progress := WritingProgress{Goal: workspace.Goal, Days: make([]DailyWords, 0, days)}
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		progress.Days = append(progress.Days, DailyWords{Date: date, Words: workspace.Days[date]})
+	}
+	progress.Today = workspace.Days[now.Format("2006-01-02")]
+
+	reached := func(words int) bool {
+		if workspace.Goal > 0 {
+			return words >= workspace.Goal
+		}
+		return words > 0
+	}
+	// 今天尚未达成时从昨天起算，连续天数不会在当天中断
+	day := now
+	if !reached(progress.Today) {
+		day = day.AddDate(0, 0, -1)
+	}
+	for reached(workspace.Days[day.Format("2006-01-02")]) {
+		progress.Streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return progress, nil
+}
+
+func (h *WritingHistory) update(root string, change func(workspace *workspaceWriting)) error {
+	root = filepath.Clean(strings.TrimSpace(root))
+	if root == "" || root == "." {
+		return errors.New("workspace root is required")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	file, err := h.load()
+	if err != nil {
+		return err
+	}
+	workspace := file.Workspaces[root]
+	if workspace == nil {
+		workspace = &workspaceWriting{Days: map[string]int{}}
+		file.Workspaces[root] = workspace
+	}
+	change(workspace)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(h.path, data)
+}
+
+func (h *WritingHistory) load() (writingHistoryFile, error) {
+	file := writingHistoryFile{Workspaces: map[string]*workspaceWriting{}}
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, err
+	}
+	if file.Workspaces == nil {
+		file.Workspaces = map[string]*workspaceWriting{}
+	}
+	for _, workspace := range file.Workspaces {
+		if workspace.Days == nil {
+			workspace.Days = map[string]int{}
+		}
+	}
+	return file, nil
+}
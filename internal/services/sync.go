@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncTarget configures WebDAV synchronization of one workspace folder.
+type SyncTarget struct {
+	Workspace string `json:"workspace"`
+	URL       string `json:"url"`
+	Username  string `json:"username"`
+	// Password is kept in the SecretStore by the application; settings.json
+	// never holds it.
+	Password string `json:"password,omitempty"`
+	// IntervalMinutes schedules a sync every n minutes; zero disables it.
+	IntervalMinutes int  `json:"intervalMinutes"`
+	SyncOnSave      bool `json:"syncOnSave"`
+}
+
+// SyncFileState is what the last successful sync saw of a file on both sides.
+type SyncFileState struct {
+	Hash string `json:"hash"`
+	ETag string `json:"etag"`
+}
+
+// SyncState is the local state database of a sync target, persisted as JSON.
+type SyncState struct {
+	URL      string                   `json:"url"`
+	LastSync time.Time                `json:"lastSync"`
+	Files    map[string]SyncFileState `json:"files"`
+}
+
+// SyncProgress is reported while a sync runs.
+type SyncProgress struct {
+	Phase string `json:"phase"`
+	Path  string `json:"path,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// SyncReport summarises a finished sync. Paths are relative to the workspace.
+type SyncReport struct {
+	Uploaded      []string  `json:"uploaded"`
+	Downloaded    []string  `json:"downloaded"`
+	DeletedLocal  []string  `json:"deletedLocal"`
+	DeletedRemote []string  `json:"deletedRemote"`
+	Conflicts     []string  `json:"conflicts"`
+	Skipped       []string  `json:"skipped"` // 受保护而未替换或删除的本地文件
+	Errors        []string  `json:"errors"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+}
+
+// Changed reports whether the sync modified the local folder.
+func (r *SyncReport) Changed() bool {
+	return len(r.Downloaded) > 0 || len(r.DeletedLocal) > 0 || len(r.Conflicts) > 0
+}
+
+// SyncEngine performs bidirectional sync between a folder and a WebDAV
+// collection. A file changed on one side since the last sync is copied to the
+// other; changed on both sides, the local version wins and the remote one is
+// kept next to it as a conflict copy.
+type SyncEngine struct {
+	root      string
+	client    *WebDAVClient
+	statePath string
+	progress  func(SyncProgress)
+	protected func(path string) bool
+}
+
+// NewSyncEngine creates an engine for target that keeps its state in stateDir.
+func NewSyncEngine(target SyncTarget, stateDir string, progress func(SyncProgress)) (*SyncEngine, error) {
+	root := filepath.Clean(strings.TrimSpace(target.Workspace))
+	if root == "" || root == "." {
+		return nil, errors.New("workspace root is required")
+	}
+	client, err := NewWebDAVClient(target.URL, target.Username, target.Password)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(SyncProgress) {}
+	}
+	return &SyncEngine{
+		root:      root,
+		client:    client,
+		statePath: filepath.Join(stateDir, SyncStateKey(root, target.URL)+".json"),
+		progress:  progress,
+		protected: func(string) bool { return false },
+	}, nil
+}
+
+// SetProtected makes the engine leave alone local files for which protected
+// returns true, such as notes with unsaved changes in the editor. They are
+// reported as skipped and synced again next time.
+func (e *SyncEngine) SetProtected(protected func(path string) bool) {
+	e.protected = protected
+}
+
+// SyncStateKey names the state file of a workspace and remote URL pair.
+func SyncStateKey(root string, remoteURL string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(root) + "\n" + strings.TrimSpace(remoteURL)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Run performs one sync. Failures of individual files are collected in the
+// report; the error is only set when the sync could not run at all.
+func (e *SyncEngine) Run(ctx context.Context) (*SyncReport, error) {
+	report := &SyncReport{StartedAt: time.Now()}
+
+	state, err := e.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	e.progress(SyncProgress{Phase: "scanning"})
+	local, err := e.scanLocal()
+	if err != nil {
+		return nil, err
+	}
+	remote, err := e.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]struct{}{}
+	for rel := range local {
+		paths[rel] = struct{}{}
+	}
+	for rel := range remote {
+		paths[rel] = struct{}{}
+	}
+	for rel := range state.Files {
+		paths[rel] = struct{}{}
+	}
+	ordered := make([]string, 0, len(paths))
+	for rel := range paths {
+		ordered = append(ordered, rel)
+	}
+	sort.Strings(ordered)
+
+	for i, rel := range ordered {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		e.progress(SyncProgress{Phase: "syncing", Path: rel, Done: i, Total: len(ordered)})
+
+		// 状态文件也可能被改坏，本地路径统一在这里把关
+		if !localRelativePath(rel) {
+			report.Errors = append(report.Errors, fmt.Sprintf("unsafe path %q", rel))
+			continue
+		}
+		localHash, hasLocal := local[rel]
+		remoteEntry, hasRemote := remote[rel]
+		previous, known := state.Files[rel]
+		if err := e.syncFile(ctx, rel, localHash, hasLocal, remoteEntry, hasRemote, previous, known, state, report); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	state.LastSync = time.Now()
+	if err := e.saveState(state); err != nil {
+		return nil, err
+	}
+	report.FinishedAt = time.Now()
+	e.progress(SyncProgress{Phase: "done", Done: len(ordered), Total: len(ordered)})
+	return report, nil
+}
+
+func (e *SyncEngine) syncFile(
+	ctx context.Context,
+	rel string,
+	localHash string,
+	hasLocal bool,
+	remoteEntry WebDAVEntry,
+	hasRemote bool,
+	previous SyncFileState,
+	known bool,
+	state *SyncState,
+	report *SyncReport,
+) error {
+	localChanged := !known || localHash != previous.Hash
+	remoteChanged := !known || remoteEntry.ETag != previous.ETag
+
+	switch {
+	case hasLocal && hasRemote:
+		switch {
+		case !localChanged && !remoteChanged:
+			return nil
+		case localChanged && !remoteChanged:
+			return e.upload(ctx, rel, remoteEntry.ETag, state, report)
+		case !localChanged && remoteChanged:
+			return e.download(ctx, rel, state, report)
+		}
+		return e.resolveConflict(ctx, rel, localHash, remoteEntry.ETag, state, report)
+
+	case hasLocal:
+		// 远端已删除：本地未修改则一并删除，否则重新上传
+		if known && !localChanged {
+			if e.protected(e.localPath(rel)) {
+				report.Skipped = append(report.Skipped, rel)
+				return nil
+			}
+			if err := os.Remove(e.localPath(rel)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			delete(state.Files, rel)
+			report.DeletedLocal = append(report.DeletedLocal, rel)
+			return nil
+		}
+		return e.upload(ctx, rel, "", state, report)
+
+	case hasRemote:
+		// 本地已删除：远端未修改则一并删除，否则重新下载
+		if known && !remoteChanged {
+			if err := e.client.Delete(ctx, rel, remoteEntry.ETag); err != nil {
+				return err
+			}
+			delete(state.Files, rel)
+			report.DeletedRemote = append(report.DeletedRemote, rel)
+			return nil
+		}
+		return e.download(ctx, rel, state, report)
+	}
+
+	delete(state.Files, rel)
+	return nil
+}
+
+func (e *SyncEngine) upload(ctx context.Context, rel string, etag string, state *SyncState, report *SyncReport) error {
+	data, err := os.ReadFile(e.localPath(rel))
+	if err != nil {
+		return err
+	}
+	if err := e.client.MkdirAll(ctx, path.Dir(rel)); err != nil {
+		return err
+	}
+	newETag, err := e.client.Put(ctx, rel, data, etag)
+	if err != nil {
+		return err
+	}
+	state.Files[rel] = SyncFileState{Hash: hashBytes(data), ETag: newETag}
+	report.Uploaded = append(report.Uploaded, rel)
+	return nil
+}
+
+func (e *SyncEngine) download(ctx context.Context, rel string, state *SyncState, report *SyncReport) error {
+	if e.protected(e.localPath(rel)) {
+		report.Skipped = append(report.Skipped, rel)
+		return nil
+	}
+	data, etag, err := e.client.Get(ctx, rel)
+	if err != nil {
+		return err
+	}
+	if err := writeLocalFile(e.localPath(rel), data); err != nil {
+		return err
+	}
+	state.Files[rel] = SyncFileState{Hash: hashBytes(data), ETag: etag}
+	report.Downloaded = append(report.Downloaded, rel)
+	return nil
+}
+
+// resolveConflict handles a file changed on both sides. Identical content is
+// simply recorded; otherwise the remote version is saved as a conflict copy
+// and the local version is uploaded over it.
+func (e *SyncEngine) resolveConflict(ctx context.Context, rel string, localHash string, remoteETag string, state *SyncState, report *SyncReport) error {
+	data, etag, err := e.client.Get(ctx, rel)
+	if err != nil {
+		return err
+	}
+	if etag == "" {
+		etag = remoteETag
+	}
+	if hashBytes(data) == localHash {
+		state.Files[rel] = SyncFileState{Hash: localHash, ETag: etag}
+		return nil
+	}
+
+	copyRel := conflictCopyPath(rel, time.Now(), func(candidate string) bool {
+		_, err := os.Stat(e.localPath(candidate))
+		return err == nil
+	})
+	if err := writeLocalFile(e.localPath(copyRel), data); err != nil {
+		return err
+	}
+	report.Conflicts = append(report.Conflicts, copyRel)
+
+	if err := e.upload(ctx, rel, etag, state, report); err != nil {
+		return err
+	}
+	return e.upload(ctx, copyRel, "", state, report)
+}
+
+// conflictCopyPath returns "dir/name (conflict 2006-01-02 150405).ext",
+// numbered when that name is taken.
+func conflictCopyPath(rel string, now time.Time, exists func(string) bool) string {
+	ext := path.Ext(rel)
+	stem := strings.TrimSuffix(rel, ext)
+	base := fmt.Sprintf("%s (conflict %s)", stem, now.Format("2006-01-02 150405"))
+	candidate := base + ext
+	for n := 2; exists(candidate); n++ {
+		candidate = fmt.Sprintf("%s %d%s", base, n, ext)
+	}
+	return candidate
+}
+
+// scanLocal hashes every non-hidden file below the root, plus vault
+// configs.
+func (e *SyncEngine) scanLocal() (map[string]string, error) {
+	files := map[string]string{}
+	err := WalkWorkspaceFiles(e.root, false, func(file string, _ fs.FileInfo) error {
+		if hiddenFileName(filepath.Base(file)) {
+			return nil
+		}
+		rel, err := filepath.Rel(e.root, file)
+		if err != nil {
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil
+		}
+		files[filepath.ToSlash(rel)] = hashBytes(data)
+		return nil
+	})
+	return files, err
+}
+
+func (e *SyncEngine) localPath(rel string) string {
+	return filepath.Join(e.root, filepath.FromSlash(rel))
+}
+
+func (e *SyncEngine) loadState() (*SyncState, error) {
+	state := &SyncState{URL: e.client.base.String(), Files: map[string]SyncFileState{}}
+	data, err := os.ReadFile(e.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("corrupt sync state %s: %w", e.statePath, err)
+	}
+	if state.Files == nil {
+		state.Files = map[string]SyncFileState{}
+	}
+	return state, nil
+}
+
+func (e *SyncEngine) saveState(state *SyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 状态目录仅当前用户可访问
+	if err := os.MkdirAll(filepath.Dir(e.statePath), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(e.statePath, data)
+}
+
+func writeLocalFile(target string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(target, data)
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
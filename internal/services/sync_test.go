@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer serves dir over WebDAV at /dav/ behind basic auth.
+func newWebDAVServer(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.Dir(dir),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "me" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSyncEngineWithWebDAV(t *testing.T) {
+	remote := t.TempDir()
+	if err := os.Mkdir(filepath.Join(remote, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	server := newWebDAVServer(t, remote)
+	local := t.TempDir()
+	target := SyncTarget{Workspace: local, URL: server.URL + "/dav/notes", Username: "me", Password: "secret"}
+	stateDir := t.TempDir()
+	remoteRoot := filepath.Join(remote, "notes")
+
+	writeTree(t, local, map[string]string{"a.md": "a\n", "sub/b.md": "b\n", ".hidden/x.md": "x\n"})
+
+	protected := map[string]bool{}
+	steps := []struct {
+		name       string
+		local      map[string]string
+		remote     map[string]string
+		removeLoc  []string
+		removeRem  []string
+		protect    []string
+		want       SyncReport
+		wantLocal  map[string]string
+		wantRemote map[string]string
+	}{
+		{
+			name:       "first sync uploads",
+			want:       SyncReport{Uploaded: []string{"a.md", "sub/b.md"}},
+			wantRemote: map[string]string{"a.md": "a\n", "sub/b.md": "b\n"},
+		},
+		{
+			name:      "remote edit is downloaded",
+			remote:    map[string]string{"a.md": "a from server\n"},
+			want:      SyncReport{Downloaded: []string{"a.md"}},
+			wantLocal: map[string]string{"a.md": "a from server\n", "sub/b.md": "b\n", ".hidden/x.md": "x\n"},
+		},
+		{
+			name:      "unsaved file is not overwritten",
+			remote:    map[string]string{"sub/b.md": "b from server\n"},
+			protect:   []string{"sub/b.md"},
+			want:      SyncReport{Skipped: []string{"sub/b.md"}},
+			wantLocal: map[string]string{"a.md": "a from server\n", "sub/b.md": "b\n", ".hidden/x.md": "x\n"},
+		},
+		{
+			name:      "skipped file syncs once saved",
+			want:      SyncReport{Downloaded: []string{"sub/b.md"}},
+			wantLocal: map[string]string{"a.md": "a from server\n", "sub/b.md": "b from server\n", ".hidden/x.md": "x\n"},
+		},
+		{
+			name:       "local deletion reaches the server",
+			removeLoc:  []string{"a.md"},
+			want:       SyncReport{DeletedRemote: []string{"a.md"}},
+			wantRemote: map[string]string{"sub/b.md": "b from server\n"},
+		},
+		{
+			name:      "unsaved file is not deleted",
+			removeRem: []string{"sub/b.md"},
+			protect:   []string{"sub/b.md"},
+			want:      SyncReport{Skipped: []string{"sub/b.md"}},
+			wantLocal: map[string]string{"sub/b.md": "b from server\n", ".hidden/x.md": "x\n"},
+		},
+		{
+			name:      "server deletion reaches the folder",
+			want:      SyncReport{DeletedLocal: []string{"sub/b.md"}},
+			wantLocal: map[string]string{".hidden/x.md": "x\n"},
+		},
+	}
+
+	for _, step := range steps {
+		writeTree(t, local, step.local)
+		writeTree(t, remoteRoot, step.remote)
+		for _, rel := range step.removeLoc {
+			if err := os.Remove(filepath.Join(local, rel)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, rel := range step.removeRem {
+			if err := os.Remove(filepath.Join(remoteRoot, rel)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for key := range protected {
+			delete(protected, key)
+		}
+		for _, rel := range step.protect {
+			protected[filepath.Join(local, filepath.FromSlash(rel))] = true
+		}
+
+		engine, err := NewSyncEngine(target, stateDir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetProtected(func(path string) bool { return protected[path] })
+		report, err := engine.Run(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := syncChanges(report); !reflect.DeepEqual(got, syncChanges(&step.want)) {
+			t.Errorf("%s: report = %+v, want %+v", step.name, got, step.want)
+		}
+		if step.wantLocal != nil {
+			if got := readTree(t, local); !reflect.DeepEqual(got, step.wantLocal) {
+				t.Errorf("%s: local = %v, want %v", step.name, got, step.wantLocal)
+			}
+		}
+		if step.wantRemote != nil {
+			if got := readTree(t, remoteRoot); !reflect.DeepEqual(got, step.wantRemote) {
+				t.Errorf("%s: remote = %v, want %v", step.name, got, step.wantRemote)
+			}
+		}
+	}
+}
+
+func TestSyncEngineConflict(t *testing.T) {
+	remote := t.TempDir()
+	server := newWebDAVServer(t, remote)
+	local := t.TempDir()
+	target := SyncTarget{Workspace: local, URL: server.URL + "/dav/", Username: "me", Password: "secret"}
+	stateDir := t.TempDir()
+
+	run := func() *SyncReport {
+		t.Helper()
+		engine, err := NewSyncEngine(target, stateDir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := engine.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	writeTree(t, local, map[string]string{"a.md": "base\n"})
+	run()
+	writeTree(t, local, map[string]string{"a.md": "local edit\n"})
+	writeTree(t, remote, map[string]string{"a.md": "server edit\n"})
+
+	report := run()
+	if len(report.Conflicts) != 1 || !strings.HasPrefix(report.Conflicts[0], "a (conflict ") {
+		t.Fatalf("conflicts = %v", report.Conflicts)
+	}
+	copyRel := report.Conflicts[0]
+	want := map[string]string{"a.md": "local edit\n", copyRel: "server edit\n"}
+	if got := readTree(t, local); !reflect.DeepEqual(got, want) {
+		t.Errorf("local = %v, want %v", got, want)
+	}
+	if got := readTree(t, remote); !reflect.DeepEqual(got, want) {
+		t.Errorf("remote = %v, want %v", got, want)
+	}
+	if report := run(); len(syncChanges(report)) != 0 {
+		t.Errorf("sync after resolving = %+v, want no changes", syncChanges(report))
+	}
+}
+
+func TestSyncEngineCarriesVaults(t *testing.T) {
+	server := newWebDAVServer(t, t.TempDir())
+	first := t.TempDir()
+	if err := os.Mkdir(filepath.Join(first, "secret"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	vault, err := CreateVault(filepath.Join(first, "secret"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.WriteFile(filepath.Join(vault.Root(), "plan.md"), []byte("plan\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := t.TempDir()
+	for _, local := range []string{first, second} {
+		target := SyncTarget{Workspace: local, URL: server.URL + "/dav/", Username: "me", Password: "secret"}
+		engine, err := NewSyncEngine(target, t.TempDir(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := engine.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copied, err := UnlockVault(filepath.Join(second, "secret"), "passphrase")
+	if err != nil {
+		t.Fatalf("unlocking the synced vault: %v", err)
+	}
+	data, err := copied.ReadFile(filepath.Join(copied.Root(), "plan.md"))
+	if err != nil || string(data) != "plan\n" {
+		t.Errorf("synced note = %q, %v", data, err)
+	}
+}
+
+func TestSyncEngineRejectsUnsafeRemotePaths(t *testing.T) {
+	hrefs := []string{"/dav/", "/dav/good.md", "/dav/..%2Fevil.md", "/dav/sub/..%2F..%2Fevil.md", "/dav/a%5C..%5C..%5Cevil.md", "/dav/."}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" {
+			w.Write([]byte("remote\n"))
+			return
+		}
+		var body strings.Builder
+		body.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		for _, href := range hrefs {
+			body.WriteString(`<d:response><d:href>` + href + `</d:href><d:propstat><d:status>HTTP/1.1 200 OK</d:status>` +
+				`<d:prop><d:getetag>"1"</d:getetag></d:prop></d:propstat></d:response>`)
+		}
+		body.WriteString(`</d:multistatus>`)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(body.String()))
+	}))
+	t.Cleanup(server.Close)
+
+	parent := t.TempDir()
+	local := filepath.Join(parent, "notes")
+	if err := os.Mkdir(local, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewSyncEngine(SyncTarget{Workspace: local, URL: server.URL + "/dav/"}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"downloaded": {"good.md"}}; !reflect.DeepEqual(syncChanges(report), want) {
+		t.Errorf("changes = %v, want %v", syncChanges(report), want)
+	}
+	if got, want := readTree(t, parent), map[string]string{"notes/good.md": "remote\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+}
+
+func TestSyncEngineRejectedCredentials(t *testing.T) {
+	server := newWebDAVServer(t, t.TempDir())
+	target := SyncTarget{Workspace: t.TempDir(), URL: server.URL + "/dav/", Username: "me", Password: "wrong"}
+	engine, err := NewSyncEngine(target, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("err = %v, want an authentication failure", err)
+	}
+}
+
+// syncChanges keeps the non-empty file lists of report for comparison.
+func syncChanges(report *SyncReport) map[string][]string {
+	changes := map[string][]string{}
+	for name, paths := range map[string][]string{
+		"uploaded":      report.Uploaded,
+		"downloaded":    report.Downloaded,
+		"deletedLocal":  report.DeletedLocal,
+		"deletedRemote": report.DeletedRemote,
+		"conflicts":     report.Conflicts,
+		"skipped":       report.Skipped,
+		"errors":        report.Errors,
+	} {
+		if len(paths) > 0 {
+			changes[name] = paths
+		}
+	}
+	return changes
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TaskItem is a GFM checkbox item extracted from a note.
+type TaskItem struct {
+	Path     string   `json:"path"`
+	RelPath  string   `json:"relPath"`
+	Line     int      `json:"line"`
+	Raw      string   `json:"raw"`
+	Text     string   `json:"text"`
+	Done     bool     `json:"done"`
+	Heading  string   `json:"heading"`
+	Due      string   `json:"due"`
+	Priority string   `json:"priority"`
+	Tags     []string `json:"tags"`
+}
+
+// TaskFilter narrows workspace task queries. Zero values match everything.
+type TaskFilter struct {
+	Status    string `json:"status"` // all | open | done
+	Folder    string `json:"folder"`
+	Tag       string `json:"tag"`
+	Priority  string `json:"priority"`
+	DueBefore string `json:"dueBefore"`
+	DueAfter  string `json:"dueAfter"`
+	Text      string `json:"text"`
+}
+
+// ErrTaskChanged is returned when a task line no longer matches the expected content.
+var ErrTaskChanged = errors.New("task line changed on disk")
+
+var (
+	taskLinePattern    = regexp.MustCompile(`^(\s*(?:[-*+]|\d+[.)])\s+\[)([ xX])(\]\s?)(.*)$`)
+	taskDuePattern     = regexp.MustCompile(`(?:📅\s*|\bdue::?\s*)(\d{4}-\d{2}-\d{2})`)
+	taskPriorityTag    = regexp.MustCompile(`\bpriority::?\s*(highest|high|medium|low|lowest)\b`)
+	headingLinePattern = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+)
+
+var taskPriorityEmoji = map[string]string{
+	"🔺": "highest",
+	"⏫": "high",
+	"🔼": "medium",
+	"🔽": "low",
+	"⏬": "lowest",
+}
+
+var taskPriorityRank = map[string]int{
+	"highest": 0,
+	"high":    1,
+	"medium":  2,
+	"":        3,
+	"low":     4,
+	"lowest":  5,
+}
+
+// ExtractTasks scans Markdown content for checkbox items, skipping front matter and code.
+func ExtractTasks(content string) []TaskItem {
+	lines := strings.Split(content, "\n")
+	tasks := []TaskItem{}
+	heading := ""
+	inFence := false
+	fenceMarker := ""
+
+	for i := FrontMatterLineCount(content); i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if marker := fenceOpening(trimmed); marker != "" {
+			if !inFence {
+				inFence, fenceMarker = true, marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) {
+				inFence = false
+				continue
+			}
+		}
+		if inFence {
+			continue
+		}
+
+		if match := headingLinePattern.FindStringSubmatch(line); match != nil {
+			heading = match[2]
+			continue
+		}
+
+		task, ok := parseTaskLine(line)
+		if !ok {
+			continue
+		}
+		task.Line = i + 1
+		task.Heading = heading
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+func parseTaskLine(line string) (TaskItem, bool) {
+	match := taskLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return TaskItem{}, false
+	}
+
+	text := strings.TrimSpace(match[4])
+	task := TaskItem{
+		Raw:  line,
+		Text: text,
+		Done: match[2] != " ",
+	}
+
+	if due := taskDuePattern.FindStringSubmatch(text); due != nil {
+		task.Due = due[1]
+	}
+	for emoji, priority := range taskPriorityEmoji {
+		if strings.Contains(text, emoji) {
+			task.Priority = priority
+			break
+		}
+	}
+	if task.Priority == "" {
+		if priority := taskPriorityTag.FindStringSubmatch(strings.ToLower(text)); priority != nil {
+			task.Priority = priority[1]
+		}
+	}
+	task.Tags = ExtractTags(nil, text)
+	return task, true
+}
+
+// FilterTasks applies a TaskFilter and sorts by due date, priority and location.
+func FilterTasks(tasks []TaskItem, filter TaskFilter) []TaskItem {
+	status := strings.ToLower(strings.TrimSpace(filter.Status))
+	folder := strings.Trim(filepathToSlash(filter.Folder), "/")
+	text := strings.ToLower(strings.TrimSpace(filter.Text))
+
+	result := make([]TaskItem, 0, len(tasks))
+	for _, task := range tasks {
+		switch status {
+		case "open":
+			if task.Done {
+				continue
+			}
+		case "done":
+			if !task.Done {
+				continue
+			}
+		}
+		if folder != "" && task.RelPath != folder && !strings.HasPrefix(task.RelPath, folder+"/") {
+			continue
+		}
+		if filter.Tag != "" && !hasTag(task.Tags, filter.Tag) {
+			continue
+		}
+		if filter.Priority != "" && !strings.EqualFold(task.Priority, filter.Priority) {
+			continue
+		}
+		if filter.DueBefore != "" && (task.Due == "" || task.Due > filter.DueBefore) {
+			continue
+		}
+		if filter.DueAfter != "" && (task.Due == "" || task.Due < filter.DueAfter) {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.ToLower(task.Text), text) {
+			continue
+		}
+		result = append(result, task)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Due != b.Due {
+			// 有截止日期的任务排在前面
+			if a.Due == "" || b.Due == "" {
+				return b.Due == ""
+			}
+			return a.Due < b.Due
+		}
+		if taskPriorityRank[a.Priority] != taskPriorityRank[b.Priority] {
+			return taskPriorityRank[a.Priority] < taskPriorityRank[b.Priority]
+		}
+		if a.RelPath != b.RelPath {
+			return a.RelPath < b.RelPath
+		}
+		return a.Line < b.Line
+	})
+	return result
+}
+
+// ToggleTaskInFile flips the checkbox on the given 1-based line, reading and
+// writing notes of unlocked vaults as plaintext. The current line must equal
+// expected, the line as it was indexed, guarding against edits made since.
+// The updated task is returned.
+func (s *FileService) ToggleTaskInFile(path string, line int, expected string) (TaskItem, error) {
+	data, err := s.ReadBytes(path)
+	if err != nil {
+		return TaskItem{}, err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return TaskItem{}, fmt.Errorf("line %d is out of range", line)
+	}
+
+	current := lines[line-1]
+	carriage := strings.HasSuffix(current, "\r")
+	current = strings.TrimSuffix(current, "\r")
+	if current != strings.TrimSuffix(expected, "\r") {
+		return TaskItem{}, ErrTaskChanged
+	}
+
+	match := taskLinePattern.FindStringSubmatch(current)
+	if match == nil {
+		return TaskItem{}, ErrTaskChanged
+	}
+
+	mark := "x"
+	if match[2] != " " {
+		mark = " "
+	}
+	updated := match[1] + mark + match[3] + match[4]
+	if carriage {
+		lines[line-1] = updated + "\r"
+	} else {
+		lines[line-1] = updated
+	}
+
+	if err := s.writeAtomic(path, []byte(strings.Join(lines, "\n"))); err != nil {
+		return TaskItem{}, err
+	}
+
+	task, _ := parseTaskLine(updated)
+	task.Path = path
+	task.Line = line
+	return task, nil
+}
+
+// writeFileAtomic replaces path via a temporary sibling file, keeping the file mode.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
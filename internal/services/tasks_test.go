@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToggleTaskInFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		line     int
+		expected string
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "check",
+			content:  "# Todo\n- [ ] write\n",
+			line:     2,
+			expected: "- [ ] write",
+			want:     "# Todo\n- [x] write\n",
+		},
+		{
+			name:     "uncheck keeps CRLF",
+			content:  "1. [X] ship\r\n",
+			line:     1,
+			expected: "1. [X] ship\r",
+			want:     "1. [ ] ship\r\n",
+		},
+		{
+			name:     "line edited since indexing",
+			content:  "- [ ] write tests\n",
+			line:     1,
+			expected: "- [ ] write",
+			want:     "- [ ] write tests\n",
+			wantErr:  ErrTaskChanged,
+		},
+		{
+			name:    "nothing to compare against",
+			content: "- [ ] write\n",
+			line:    1,
+			want:    "- [ ] write\n",
+			wantErr: ErrTaskChanged,
+		},
+		{
+			name:     "no longer a task",
+			content:  "write\n",
+			line:     1,
+			expected: "write",
+			want:     "write\n",
+			wantErr:  ErrTaskChanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "todo.md")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			task, err := NewFileService().ToggleTaskInFile(path, tt.line, tt.expected)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (task.Path != path || task.Line != tt.line) {
+				t.Errorf("task = %+v, want %s:%d", task, path, tt.line)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("file = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestToggleTaskInFileOutOfRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.md")
+	if err := os.WriteFile(path, []byte("- [ ] a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []int{0, 3} {
+		if _, err := NewFileService().ToggleTaskInFile(path, line, "- [ ] a"); err == nil {
+			t.Errorf("line %d: toggle succeeded", line)
+		}
+	}
+}
+
+func TestToggleTaskInFileInsideVault(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"todo.md": "- [ ] secret\n"})
+	vault, err := CreateVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	files.MountVault(vault)
+	defer files.UnmountAllVaults()
+
+	path := filepath.Join(root, "todo.md")
+	task, err := files.ToggleTaskInFile(path, 1, "- [ ] secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.Done {
+		t.Errorf("task = %+v, want done", task)
+	}
+	if got, err := files.Read(path); err != nil || got != "- [x] secret\n" {
+		t.Errorf("note = %q, %v", got, err)
+	}
+	for name, content := range readTree(t, root) {
+		if strings.Contains(name, "todo") || strings.Contains(content, "secret") {
+			t.Errorf("plaintext on disk: %s", name)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateDirName is the workspace folder holding note templates. Being
+// hidden keeps templates out of search, queries and the index.
+const TemplateDirName = ".templates"
+
+// DefaultTemplateFilename names notes whose template has no filename pattern.
+const DefaultTemplateFilename = "{{title}}.md"
+
+const maxTemplateOutput = 4 << 20
+
+var (
+	// {{date:2006-01-02}} 是 {{date "2006-01-02"}} 的简写
+	templateShorthandPattern = regexp.MustCompile(`\{\{(-?\s*)([A-Za-z_][A-Za-z0-9_]*):([^}]*?)(\s*-?)\}\}`)
+	templateHeaderPattern    = regexp.MustCompile(`(?s)\A<!--\s*template\s*\n(.*?)-->[ \t]*\r?\n?`)
+	templateSlugSeparator    = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// cursorMarker stands for {{cursor}} until the final text is known.
+const cursorMarker = "\x00cursor\x00"
+
+// NoteTemplate describes a template file. A template may start with an HTML
+// comment holding YAML options, which is not copied into notes:
+//
+//	<!-- template
+//	description: Weekly meeting
+//	filename: "{{date:2006-01-02}}-{{slug title}}.md"
+//	-->
+type NoteTemplate struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	// Prompts lists the values asked from the user via {{prompt "Name"}}.
+	Prompts []string `json:"prompts,omitempty"`
+	// Error explains why the template cannot be used; ListTemplates reports
+	// broken templates this way instead of failing.
+	Error string `json:"error,omitempty"`
+}
+
+// RenderedNote is the result of applying a template.
+type RenderedNote struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+	// CursorLine and CursorColumn (1-based) locate {{cursor}}; 0 without one.
+	CursorLine   int `json:"cursorLine"`
+	CursorColumn int `json:"cursorColumn"`
+}
+
+type templateHeader struct {
+	Description string `yaml:"description"`
+	Filename    string `yaml:"filename"`
+}
+
+// ListTemplates lists the templates in dirs. A template in a later dir
+// replaces one with the same name in an earlier dir; missing dirs are skipped
+// and templates that fail to load are listed with Error set.
+func ListTemplates(dirs ...string) ([]NoteTemplate, error) {
+	byName := map[string]NoteTemplate{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !IsMarkdownFile(entry.Name()) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			tmpl, err := LoadTemplate(path)
+			if err != nil {
+				tmpl = NoteTemplate{Name: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), Path: path, Error: err.Error()}
+			}
+			byName[strings.ToLower(tmpl.Name)] = tmpl
+		}
+	}
+
+	templates := make([]NoteTemplate, 0, len(byName))
+	for _, tmpl := range byName {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+	return templates, nil
+}
+
+// FindTemplate resolves name (a path or a template name with or without
+// extension) against dirs; later dirs take precedence.
+func FindTemplate(name string, dirs ...string) (string, error) {
+	if name == "" {
+		return "", errors.New("template name is required")
+	}
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+		return name, nil
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("template %s: %w", name, os.ErrNotExist)
+	}
+
+	templates, err := ListTemplates(dirs...)
+	if err != nil {
+		return "", err
+	}
+	wanted := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	for _, tmpl := range templates {
+		if strings.ToLower(tmpl.Name) == wanted {
+			return tmpl.Path, nil
+		}
+	}
+	return "", fmt.Errorf("template %q not found", name)
+}
+
+// LoadTemplate reads the options and prompts of the template at path.
+func LoadTemplate(path string) (NoteTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return NoteTemplate{}, err
+	}
+	header, body, err := splitTemplate(string(data))
+	if err != nil {
+		return NoteTemplate{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	tmpl := NoteTemplate{
+		Name:        strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Path:        path,
+		Description: header.Description,
+		Filename:    header.Filename,
+	}
+	// 空变量试渲染一次，收集 {{prompt}} 用到的名称
+	seen := map[string]bool{}
+	collect := func(name string) string {
+		if !seen[name] {
+			seen[name] = true
+			tmpl.Prompts = append(tmpl.Prompts, name)
+		}
+		return ""
+	}
+	for _, source := range []string{header.Filename, body} {
+		if _, err := executeTemplate(source, nil, time.Now(), collect); err != nil {
+			return NoteTemplate{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// RenderTemplate applies the template source with vars. The title variable
+// defaults to "Untitled"; prompts read the variable of the same name.
+func RenderTemplate(source string, vars map[string]string, now time.Time) (RenderedNote, error) {
+	header, body, err := splitTemplate(source)
+	if err != nil {
+		return RenderedNote{}, err
+	}
+	prompt := func(name string) string { return vars[name] }
+
+	content, err := executeTemplate(body, vars, now, prompt)
+	if err != nil {
+		return RenderedNote{}, err
+	}
+	pattern := header.Filename
+	if pattern == "" {
+		pattern = DefaultTemplateFilename
+	}
+	filename, err := executeTemplate(pattern, vars, now, prompt)
+	if err != nil {
+		return RenderedNote{}, fmt.Errorf("filename: %w", err)
+	}
+	filename, err = templateFilename(filename)
+	if err != nil {
+		return RenderedNote{}, err
+	}
+
+	note := RenderedNote{Filename: filename}
+	if index := strings.Index(content, cursorMarker); index >= 0 {
+		before := content[:index]
+		note.CursorLine = strings.Count(before, "\n") + 1
+		note.CursorColumn = len([]rune(before[strings.LastIndex(before, "\n")+1:])) + 1
+	}
+	note.Content = strings.ReplaceAll(content, cursorMarker, "")
+	return note, nil
+}
+
+func splitTemplate(source string) (templateHeader, string, error) {
+	var header templateHeader
+	match := templateHeaderPattern.FindStringSubmatchIndex(source)
+	if match == nil {
+		return header, source, nil
+	}
+	if err := yaml.Unmarshal([]byte(source[match[2]:match[3]]), &header); err != nil {
+		return header, "", fmt.Errorf("template options: %w", err)
+	}
+	return header, source[match[1]:], nil
+}
+
+// executeTemplate runs source with a function set that can only format
+// values: templates cannot read files, run commands or produce unbounded output.
+func executeTemplate(source string, vars map[string]string, now time.Time, prompt func(string) string) (string, error) {
+	title := strings.TrimSpace(vars["title"])
+	if title == "" {
+		title = "Untitled"
+	}
+	funcs := template.FuncMap{
+		"date": func(layout ...string) string {
+			return now.Format(firstOr(layout, "2006-01-02"))
+		},
+		"time": func(layout ...string) string {
+			return now.Format(firstOr(layout, "15:04"))
+		},
+		"now":    func() time.Time { return now },
+		"title":  func() string { return title },
+		"cursor": func() string { return cursorMarker },
+		"prompt": func(name string) string { return prompt(name) },
+		"var":    func(name string) string { return vars[name] },
+	}
+	for name, fn := range templateTextFuncs() {
+		funcs[name] = fn
+	}
+
+	parsed, err := template.New("note").Funcs(funcs).Option("missingkey=zero").Parse(expandTemplateShorthand(source))
+	if err != nil {
+		return "", err
+	}
+	if err := checkTemplateNodes(parsed.Tree.Root); err != nil {
+		return "", err
+	}
+	if len(parsed.Templates()) > 1 {
+		return "", errors.New("templates cannot define other templates")
+	}
+
+	data := map[string]string{}
+	for key, value := range vars {
+		data[key] = value
+	}
+	var out limitedBuffer
+	if err := parsed.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// checkTemplateNodes rejects the actions whose running time does not depend
+// on the output size, which limitedBuffer bounds: {{range}} over anything
+// but the template variables, e.g. {{range 99999999999}}, and calls of other
+// templates, which can recurse.
+func checkTemplateNodes(node parse.Node) error {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			if err := checkTemplateNodes(child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkTemplateBranch(&node.BranchNode)
+	case *parse.WithNode:
+		return checkTemplateBranch(&node.BranchNode)
+	case *parse.RangeNode:
+		cmds := node.Pipe.Cmds
+		if len(cmds) != 1 || len(cmds[0].Args) != 1 {
+			return fmt.Errorf("range can only loop over the template variables: %s", node.Pipe)
+		}
+		switch cmds[0].Args[0].(type) {
+		case *parse.DotNode, *parse.FieldNode:
+		default:
+			return fmt.Errorf("range can only loop over the template variables: %s", node.Pipe)
+		}
+		return checkTemplateBranch(&node.BranchNode)
+	case *parse.TemplateNode:
+		return fmt.Errorf("templates cannot call other templates: %s", node)
+	}
+	return nil
+}
+
+func checkTemplateBranch(branch *parse.BranchNode) error {
+	if err := checkTemplateNodes(branch.List); err != nil {
+		return err
+	}
+	return checkTemplateNodes(branch.ElseList)
+}
+
+// templateTextFuncs are the string helpers shared by note and file name
+// templates.
+func templateTextFuncs() template.FuncMap {
+	return template.FuncMap{
+		"slug":  templateSlug,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"replace": func(old string, replacement string, s string) string {
+			return strings.ReplaceAll(s, old, replacement)
+		},
+		"default": func(fallback string, value string) string {
+			if strings.TrimSpace(value) == "" {
+				return fallback
+			}
+			return value
+		},
+	}
+}
+
+// expandTemplateShorthand rewrites {{name:arg}} as {{name "arg"}}.
+func expandTemplateShorthand(source string) string {
+	return templateShorthandPattern.ReplaceAllStringFunc(source, func(match string) string {
+		parts := templateShorthandPattern.FindStringSubmatch(match)
+		return "{{" + parts[1] + parts[2] + " " + strconv.Quote(parts[3]) + parts[4] + "}}"
+	})
+}
+
+// templateFilename cleans a rendered file name the way batch rename does and
+// adds ".md" when it has no Markdown extension.
+func templateFilename(name string) (string, error) {
+	name = strings.TrimSpace(fileNameCleaner.Replace(strings.ReplaceAll(name, "\x00", "")))
+	if name == "" || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid file name %q from template", name)
+	}
+	if IsEncryptedNote(name) {
+		return "", errors.New("templates cannot create encrypted notes; use Save As with a .md.enc name")
+	}
+	if !IsMarkdownFile(name) {
+		name += ".md"
+	}
+	return name, nil
+}
+
+// templateSlug lowercases text and joins its words with "-", keeping
+// non-Latin letters so CJK titles stay readable in file names.
+func templateSlug(text string) string {
+	slug := templateSlugSeparator.ReplaceAllString(strings.ToLower(strings.TrimSpace(text)), "-")
+	return strings.TrimFunc(slug, func(r rune) bool { return r == '-' || unicode.IsSpace(r) })
+}
+
+func firstOr(values []string, fallback string) string {
+	if len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return fallback
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxTemplateOutput {
+		return 0, errors.New("template output is too large")
+	}
+	return b.Buffer.Write(p)
+}
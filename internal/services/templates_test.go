@@ -0,0 +1,126 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	now := time.Date(2026, 3, 9, 14, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		source  string
+		vars    map[string]string
+		want    RenderedNote
+		wantErr string
+	}{
+		{
+			name:   "default title and file name",
+			source: "# {{title}}\n",
+			want:   RenderedNote{Filename: "Untitled.md", Content: "# Untitled\n"},
+		},
+		{
+			name:   "shorthand, prompts and cursor",
+			source: "<!-- template\nfilename: \"{{date:2006-01-02}}-{{slug title}}\"\n-->\n# {{title}}\n\nWith {{prompt \"Who\"}} at {{time}}: {{cursor}}\n",
+			vars:   map[string]string{"title": "Weekly Sync", "Who": "Ann"},
+			want:   RenderedNote{Filename: "2026-03-09-weekly-sync.md", Content: "# Weekly Sync\n\nWith Ann at 14:05: \n", CursorLine: 3, CursorColumn: 20},
+		},
+		{
+			name:   "title with path characters",
+			source: "# {{title}}\n",
+			vars:   map[string]string{"title": "Q1/Q2: plan?"},
+			want:   RenderedNote{Filename: "Q1-Q2- plan.md", Content: "# Q1/Q2: plan?\n"},
+		},
+		{
+			name:   "range over the variables",
+			source: "{{range $k, $v := .}}{{$k}}={{$v}};{{end}}",
+			vars:   map[string]string{"a": "1", "b": "2"},
+			want:   RenderedNote{Filename: "Untitled.md", Content: "a=1;b=2;"},
+		},
+		{name: "range over an integer", source: "{{range 99999999999}}x{{end}}", wantErr: "range"},
+		{name: "range over a variable", source: "{{$n := 99999999999}}{{range $n}}{{end}}", wantErr: "range"},
+		{name: "range nested in if", source: "{{if true}}{{range 5}}{{end}}{{end}}", wantErr: "range"},
+		{name: "recursive template", source: "{{define \"a\"}}{{template \"a\"}}{{template \"a\"}}{{end}}{{template \"a\"}}", wantErr: "templates"},
+		{name: "block", source: "{{block \"b\" .}}x{{end}}", wantErr: "templates"},
+		{name: "hidden file name", source: "<!-- template\nfilename: \"../{{title}}\"\n-->\n", wantErr: "invalid file name"},
+		{name: "encrypted file name", source: "<!-- template\nfilename: \"a.md.enc\"\n-->\n", wantErr: "encrypted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			var got RenderedNote
+			var err error
+			go func() {
+				defer close(done)
+				got, err = RenderTemplate(tt.source, tt.vars, now)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RenderTemplate did not finish")
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTemplate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	global := t.TempDir()
+	workspace := t.TempDir()
+	writeTree(t, global, map[string]string{
+		"Daily.md":   "<!-- template\ndescription: global\n-->\n",
+		"Meeting.md": "{{prompt \"Who\"}} {{prompt \"Where\"}} {{prompt \"Who\"}}",
+		"notes.txt":  "not a template",
+	})
+	writeTree(t, workspace, map[string]string{
+		"daily.md":  "<!-- template\ndescription: workspace\n-->\n",
+		"Broken.md": "{{if}}",
+		"Loop.md":   "{{range 99999999999}}{{end}}",
+	})
+
+	templates, err := ListTemplates(global, workspace, filepath.Join(workspace, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]NoteTemplate{}
+	var names []string
+	for _, tmpl := range templates {
+		names = append(names, tmpl.Name)
+		got[tmpl.Name] = tmpl
+	}
+	if want := []string{"Broken", "daily", "Loop", "Meeting"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+	if got["daily"].Description != "workspace" {
+		t.Errorf("daily = %+v, want the workspace template", got["daily"])
+	}
+	if want := []string{"Who", "Where"}; !reflect.DeepEqual(got["Meeting"].Prompts, want) {
+		t.Errorf("prompts = %v, want %v", got["Meeting"].Prompts, want)
+	}
+	for _, name := range []string{"Broken", "Loop"} {
+		if got[name].Error == "" || got[name].Path == "" {
+			t.Errorf("%s = %+v, want an error", name, got[name])
+		}
+	}
+	if got["daily"].Error != "" || got["Meeting"].Error != "" {
+		t.Error("working templates reported as broken")
+	}
+
+	if path, err := FindTemplate("meeting", global, workspace); err != nil || path != filepath.Join(global, "Meeting.md") {
+		t.Errorf("FindTemplate = %q, %v", path, err)
+	}
+}
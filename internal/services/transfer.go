@@ -0,0 +1,547 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy decides what a copy or move does with an item whose name is
+// taken in the destination.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the existing file; folders are merged.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing file; folders are merged.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictKeepBoth gives the incoming file or folder a numbered name.
+	ConflictKeepBoth ConflictPolicy = "keepBoth"
+)
+
+// TransferProgress reports a running copy or move. Done and Total count files.
+type TransferProgress struct {
+	Phase string `json:"phase"`
+	Path  string `json:"path,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// TransferItem is a top-level item of a copy or move and where it went.
+type TransferItem struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TransferResult reports a copy, move or duplicate, also one that stopped
+// halfway. Files counts the files copied or moved; Skipped lists the
+// sources left alone because their name was taken; Moved lists every file
+// or folder a move has put somewhere else, so open documents can follow
+// them. Error tells why the transfer stopped early.
+type TransferResult struct {
+	Items   []TransferItem `json:"items"`
+	Files   int            `json:"files"`
+	Skipped []string       `json:"skipped"`
+	Moved   []TransferItem `json:"moved"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// progressInterval limits progress reports on large trees to one per so
+// many files.
+const progressInterval = 25
+
+type transfer struct {
+	files    *FileService
+	ctx      context.Context
+	policy   ConflictPolicy
+	move     bool
+	result   TransferResult
+	done     int
+	total    int
+	progress func(TransferProgress)
+}
+
+// Copy copies files and folders (recursively) into the folder destination.
+// The copy stops between two files when ctx is cancelled; the files already
+// copied are kept and reported.
+func (s *FileService) Copy(ctx context.Context, sources []string, destination string, policy ConflictPolicy, progress func(TransferProgress)) (TransferResult, error) {
+	return s.transfer(ctx, sources, destination, policy, false, progress)
+}
+
+// Move moves files and folders into the folder destination. An item is
+// renamed when possible and copied then deleted otherwise, e.g. into or out
+// of a vault; a folder meeting an existing one is merged into it unless the
+// policy keeps both.
+func (s *FileService) Move(ctx context.Context, sources []string, destination string, policy ConflictPolicy, progress func(TransferProgress)) (TransferResult, error) {
+	return s.transfer(ctx, sources, destination, policy, true, progress)
+}
+
+// Duplicate copies a file or folder next to itself as "Name copy.md",
+// "Name copy 2.md", ….
+func (s *FileService) Duplicate(ctx context.Context, path string, progress func(TransferProgress)) (TransferResult, error) {
+	path = filepath.Clean(path)
+	t := &transfer{files: s, ctx: ctx, policy: ConflictSkip, progress: progress}
+	t.result = TransferResult{Items: []TransferItem{}, Skipped: []string{}, Moved: []TransferItem{}}
+	if _, err := s.Lstat(path); err != nil {
+		return t.result, err
+	}
+	target := s.availableName(path, " copy", 1)
+	t.total = s.countFiles(path)
+	t.report("copying", path)
+	if err := t.copyEntry(path, target); err != nil {
+		return t.result, err
+	}
+	t.result.Items = append(t.result.Items, TransferItem{From: path, To: target})
+	t.report("done", "")
+	return t.result, nil
+}
+
+func (s *FileService) transfer(ctx context.Context, sources []string, destination string, policy ConflictPolicy, move bool, progress func(TransferProgress)) (TransferResult, error) {
+	t := &transfer{files: s, ctx: ctx, policy: policy, move: move, progress: progress}
+	t.result = TransferResult{Items: []TransferItem{}, Skipped: []string{}, Moved: []TransferItem{}}
+	switch policy {
+	case ConflictSkip, ConflictOverwrite, ConflictKeepBoth:
+	default:
+		return t.result, fmt.Errorf("unknown conflict policy %q", policy)
+	}
+	destination = filepath.Clean(destination)
+	if info, err := s.Lstat(destination); err != nil {
+		return t.result, err
+	} else if !info.IsDir() {
+		return t.result, fmt.Errorf("%s is not a folder", destination)
+	}
+
+	t.report("scanning", "")
+	cleaned := make([]string, 0, len(sources))
+	for _, source := range sources {
+		source = filepath.Clean(source)
+		if _, err := s.Lstat(source); err != nil {
+			return t.result, err
+		}
+		if source == destination || isWithinRoot(source, destination) {
+			return t.result, fmt.Errorf("cannot put %s into itself", filepath.Base(source))
+		}
+		t.total += s.countFiles(source)
+		cleaned = append(cleaned, source)
+	}
+
+	for _, source := range cleaned {
+		target := filepath.Join(destination, filepath.Base(source))
+		if move && target == source {
+			continue
+		}
+		target, err := t.place(source, target)
+		if err == nil && target != "" {
+			t.result.Items = append(t.result.Items, TransferItem{From: source, To: target})
+		}
+		if err != nil {
+			return t.result, err
+		}
+	}
+	t.report("done", "")
+	return t.result, nil
+}
+
+// place copies or moves source to target following the conflict policy and
+// returns where it ended up, or "" when it was skipped.
+func (t *transfer) place(source string, target string) (string, error) {
+	if t.policy == ConflictKeepBoth {
+		if _, err := t.files.Lstat(target); err == nil {
+			target = t.files.availableName(target, "", 2)
+		}
+	}
+	skipped := len(t.result.Skipped)
+	var err error
+	if t.move {
+		err = t.moveEntry(source, target)
+	} else {
+		err = t.copyEntry(source, target)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(t.result.Skipped) > skipped && t.result.Skipped[len(t.result.Skipped)-1] == source {
+		return "", nil
+	}
+	return target, nil
+}
+
+// copyEntry copies the file or folder source to target, merging folders.
+// Vaults are copied as they are stored on disk.
+func (t *transfer) copyEntry(source string, target string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	info, err := t.files.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && IsVault(source) {
+		if _, err := t.files.Lstat(target); err != nil {
+			return t.copyVault(source, target)
+		}
+		if t.policy != ConflictOverwrite {
+			t.skipTree(source)
+			return nil
+		}
+		return t.replace(source, target, func(temporary string) error {
+			return t.copyVault(source, temporary)
+		})
+	}
+	if info.IsDir() {
+		if existing, err := t.files.Lstat(target); err == nil && !existing.IsDir() {
+			if t.policy != ConflictOverwrite {
+				t.skip(source)
+				return nil
+			}
+			return t.replace(source, target, func(temporary string) error {
+				return t.copyEntry(source, temporary)
+			})
+		}
+		if err := t.files.CreateDirectory(target); err != nil {
+			return err
+		}
+		entries, err := t.files.ReadDir(source)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := t.copyEntry(filepath.Join(source, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	if _, err := t.files.Lstat(target); err == nil {
+		// 复制到自身所在位置时没有可覆盖的内容
+		if t.policy != ConflictOverwrite || target == source {
+			t.skip(source)
+			return nil
+		}
+		err := t.replace(source, target, func(temporary string) error {
+			return t.files.copyFile(source, temporary)
+		})
+		if err != nil {
+			return err
+		}
+	} else if err := t.files.copyFile(source, target); err != nil {
+		return err
+	}
+	t.result.Files++
+	t.advance(source, 1)
+	return nil
+}
+
+// moveEntry moves the file or folder source to target, merging folders.
+func (t *transfer) moveEntry(source string, target string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	info, err := t.files.Lstat(source)
+	if err != nil {
+		return err
+	}
+	vault := info.IsDir() && IsVault(source)
+	count := t.files.countFiles(source)
+	if existing, err := t.files.Lstat(target); err == nil {
+		switch {
+		case info.IsDir() && existing.IsDir() && !vault:
+			return t.mergeFolder(source, target)
+		case t.policy != ConflictOverwrite:
+			t.skipTree(source)
+			return nil
+		}
+		renamed := false
+		err := t.replace(source, target, func(temporary string) error {
+			if err := t.files.RenameFile(source, temporary); err == nil {
+				renamed = true
+				t.result.Files += count
+				t.advance(source, count)
+				return nil
+			}
+			if vault {
+				return t.copyVault(source, temporary)
+			}
+			return t.copyEntry(source, temporary)
+		})
+		if err != nil {
+			return err
+		}
+		if !renamed {
+			if err := t.files.DeleteFile(source); err != nil {
+				return err
+			}
+		}
+		t.moved(source, target)
+		return nil
+	}
+
+	if err := t.files.RenameFile(source, target); err == nil {
+		t.result.Files += count
+		t.advance(source, count)
+		t.moved(source, target)
+		return nil
+	}
+	// 无法直接改名（跨设备或跨保险库）时复制后删除
+	switch {
+	case vault:
+		if err := t.copyVault(source, target); err != nil {
+			return err
+		}
+	case info.IsDir():
+		return t.mergeFolder(source, target)
+	default:
+		if err := t.files.copyFile(source, target); err != nil {
+			return err
+		}
+		t.result.Files++
+		t.advance(source, 1)
+	}
+	if err := t.files.DeleteFile(source); err != nil {
+		return err
+	}
+	t.moved(source, target)
+	return nil
+}
+
+// replace swaps the existing target for a new item that write puts at a
+// temporary name next to it, so a copy that fails or is cancelled halfway
+// leaves target untouched. A target containing source is refused, since
+// deleting it would delete source as well.
+func (t *transfer) replace(source string, target string, write func(temporary string) error) error {
+	if target == source || isWithinRoot(target, source) {
+		return fmt.Errorf("cannot replace %s with an item inside it", filepath.Base(target))
+	}
+	temporary := t.files.temporaryName(target)
+	if err := write(temporary); err != nil {
+		t.discard(source, temporary)
+		return err
+	}
+	if err := t.files.DeleteFile(target); err != nil {
+		t.discard(source, temporary)
+		return err
+	}
+	return t.files.RenameFile(temporary, target)
+}
+
+// discard removes a replacement that was not swapped in. A move that has
+// already renamed source to the temporary name is put back instead.
+func (t *transfer) discard(source string, temporary string) {
+	if _, err := t.files.Lstat(temporary); err != nil {
+		return
+	}
+	if _, err := t.files.Lstat(source); err != nil {
+		_ = t.files.RenameFile(temporary, source)
+		return
+	}
+	_ = t.files.DeleteFile(temporary)
+}
+
+// copyVault copies the vault at source as it is stored on disk, so the copy
+// stays encrypted and opens with the same passphrase whether or not source
+// is unlocked.
+func (t *transfer) copyVault(source string, target string) error {
+	if t.files.Vault(target) != nil || checkNotInLockedVault(target) != nil {
+		return fmt.Errorf("%s: a vault cannot be stored inside another vault", filepath.Base(source))
+	}
+	return t.copyRaw(source, target)
+}
+
+func (t *transfer) copyRaw(source string, target string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if err := copyPlainFile(source, target); err != nil {
+			return err
+		}
+		t.result.Files++
+		t.advance(source, 1)
+		return nil
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := t.copyRaw(filepath.Join(source, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFolder moves the entries of source into the existing folder target
+// and removes source once it is empty.
+func (t *transfer) mergeFolder(source string, target string) error {
+	if err := t.files.CreateDirectory(target); err != nil {
+		return err
+	}
+	entries, err := t.files.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := t.moveEntry(filepath.Join(source, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+			return err
+		}
+	}
+	if remaining, err := t.files.ReadDir(source); err == nil && len(remaining) == 0 {
+		return t.files.DeleteFile(source)
+	}
+	return nil
+}
+
+func (t *transfer) moved(source string, target string) {
+	t.result.Moved = append(t.result.Moved, TransferItem{From: source, To: target})
+}
+
+func (t *transfer) skip(path string) {
+	t.result.Skipped = append(t.result.Skipped, path)
+	t.advance(path, 1)
+}
+
+// skipTree records the files of a folder left behind by a move.
+func (t *transfer) skipTree(path string) {
+	count := t.files.countFiles(path)
+	if count == 0 {
+		return
+	}
+	t.result.Skipped = append(t.result.Skipped, path)
+	t.advance(path, count)
+}
+
+// advance counts files as handled, reporting progress every so many.
+func (t *transfer) advance(path string, files int) {
+	before := t.done
+	t.done += files
+	if t.done/progressInterval != before/progressInterval {
+		phase := "copying"
+		if t.move {
+			phase = "moving"
+		}
+		t.report(phase, path)
+	}
+}
+
+func (t *transfer) report(phase string, path string) {
+	if t.progress != nil {
+		t.progress(TransferProgress{Phase: phase, Path: path, Done: t.done, Total: t.total})
+	}
+}
+
+// copyFile copies a regular file, streaming it unless either side is in a
+// vault, which needs the whole content to seal or open it.
+func (s *FileService) copyFile(source string, target string) error {
+	if s.Vault(source) != nil || s.Vault(target) != nil {
+		data, err := s.ReadBytes(source)
+		if err != nil {
+			return err
+		}
+		return s.Write(target, string(data))
+	}
+	if err := checkNotInLockedVault(target); err != nil {
+		return err
+	}
+	return copyPlainFile(source, target)
+}
+
+// copyPlainFile streams a file outside any vault, keeping its permissions.
+func copyPlainFile(source string, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// countFiles counts the regular files of path, which may be a file.
+func (s *FileService) countFiles(path string) int {
+	info, err := s.Lstat(path)
+	if err != nil {
+		return 0
+	}
+	if !info.IsDir() {
+		if info.Mode().IsRegular() {
+			return 1
+		}
+		return 0
+	}
+	entries, err := s.ReadDir(path)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, entry := range entries {
+		count += s.countFiles(filepath.Join(path, entry.Name()))
+	}
+	return count
+}
+
+// availableName returns the first free name for path with suffix and a
+// number from first on: "Note copy.md", "Note copy 2.md" for a duplicate,
+// "Note 2.md", "Note 3.md" when keeping both. The number is left out while
+// it is 1.
+func (s *FileService) availableName(path string, suffix string, first int) string {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	if IsEncryptedNote(name) {
+		ext = name[len(name)-len(EncryptedNoteExt):]
+	}
+	if info, err := s.Lstat(path); err == nil && info.IsDir() {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	for i := first; ; i++ {
+		candidate := base + suffix
+		if i > 1 {
+			candidate += fmt.Sprintf(" %d", i)
+		}
+		candidate = filepath.Join(dir, candidate+ext)
+		if _, err := s.Lstat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
+
+// temporaryName returns a free hidden name next to path for an item that
+// is about to replace it.
+func (s *FileService) temporaryName(path string) string {
+	dir := filepath.Dir(path)
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf(".markdowndaonote-transfer-%d-%d", os.Getpid(), i))
+		if _, err := s.Lstat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
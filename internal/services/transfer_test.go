@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree creates the files of tree below root; keys are slash paths and
+// a trailing slash makes an empty folder.
+func writeTree(t *testing.T, root string, tree map[string]string) {
+	t.Helper()
+	for name, content := range tree {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree returns every file below root keyed by its slash path.
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		tree[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestTransferConflictPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		move    bool
+		policy  ConflictPolicy
+		source  string
+		want    map[string]string
+		files   int
+		skipped []string
+	}{
+		{
+			name:    "copy skip keeps existing file",
+			policy:  ConflictSkip,
+			source:  "src/a.md",
+			want:    map[string]string{"src/a.md": "new", "dst/a.md": "old", "src/dir/x.md": "x", "dst/dir/y.md": "y"},
+			skipped: []string{"src/a.md"},
+		},
+		{
+			name:   "copy overwrite replaces file",
+			policy: ConflictOverwrite,
+			source: "src/a.md",
+			want:   map[string]string{"src/a.md": "new", "dst/a.md": "new", "src/dir/x.md": "x", "dst/dir/y.md": "y"},
+			files:  1,
+		},
+		{
+			name:   "copy keep both numbers the name",
+			policy: ConflictKeepBoth,
+			source: "src/a.md",
+			want:   map[string]string{"src/a.md": "new", "dst/a.md": "old", "dst/a 2.md": "new", "src/dir/x.md": "x", "dst/dir/y.md": "y"},
+			files:  1,
+		},
+		{
+			name:   "copy merges folders",
+			policy: ConflictSkip,
+			source: "src/dir",
+			want:   map[string]string{"src/a.md": "new", "dst/a.md": "old", "src/dir/x.md": "x", "dst/dir/x.md": "x", "dst/dir/y.md": "y"},
+			files:  1,
+		},
+		{
+			name:   "copy keep both copies folder under new name",
+			policy: ConflictKeepBoth,
+			source: "src/dir",
+			want:   map[string]string{"src/a.md": "new", "dst/a.md": "old", "src/dir/x.md": "x", "dst/dir/y.md": "y", "dst/dir 2/x.md": "x"},
+			files:  1,
+		},
+		{
+			name:    "move skip leaves source",
+			move:    true,
+			policy:  ConflictSkip,
+			source:  "src/a.md",
+			want:    map[string]string{"src/a.md": "new", "dst/a.md": "old", "src/dir/x.md": "x", "dst/dir/y.md": "y"},
+			skipped: []string{"src/a.md"},
+		},
+		{
+			name:   "move overwrite replaces file",
+			move:   true,
+			policy: ConflictOverwrite,
+			source: "src/a.md",
+			want:   map[string]string{"dst/a.md": "new", "src/dir/x.md": "x", "dst/dir/y.md": "y"},
+			files:  1,
+		},
+		{
+			name:   "move merges folders",
+			move:   true,
+			policy: ConflictOverwrite,
+			source: "src/dir",
+			want:   map[string]string{"src/a.md": "new", "dst/a.md": "old", "dst/dir/x.md": "x", "dst/dir/y.md": "y"},
+			files:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{
+				"src/a.md":     "new",
+				"src/dir/x.md": "x",
+				"dst/a.md":     "old",
+				"dst/dir/y.md": "y",
+			})
+			files := NewFileService()
+			run := files.Copy
+			if tt.move {
+				run = files.Move
+			}
+			source := filepath.Join(root, filepath.FromSlash(tt.source))
+			result, err := run(context.Background(), []string{source}, filepath.Join(root, "dst"), tt.policy, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readTree(t, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tree = %v, want %v", got, tt.want)
+			}
+			if result.Files != tt.files {
+				t.Errorf("Files = %d, want %d", result.Files, tt.files)
+			}
+			var skipped []string
+			for _, path := range result.Skipped {
+				rel, _ := filepath.Rel(root, path)
+				skipped = append(skipped, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("Skipped = %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestTransferRefusesToReplaceAncestor(t *testing.T) {
+	for _, move := range []bool{false, true} {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{"A/A": "inner", "A/other.md": "keep"})
+		files := NewFileService()
+		run := files.Copy
+		if move {
+			run = files.Move
+		}
+		_, err := run(context.Background(), []string{filepath.Join(root, "A", "A")}, root, ConflictOverwrite, nil)
+		if err == nil {
+			t.Errorf("move=%v: replacing the folder holding the source succeeded", move)
+		}
+		want := map[string]string{"A/A": "inner", "A/other.md": "keep"}
+		if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+			t.Errorf("move=%v: tree = %v, want %v", move, got, want)
+		}
+	}
+}
+
+func TestTransferOverwriteFolderWithFile(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"src/note": "file", "dst/note/old.md": "old"})
+	files := NewFileService()
+	_, err := files.Move(context.Background(), []string{filepath.Join(root, "src", "note")}, filepath.Join(root, "dst"), ConflictOverwrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"dst/note": "file"}
+	if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+}
+
+func TestDuplicateNaming(t *testing.T) {
+	tests := []struct {
+		name  string
+		tree  map[string]string
+		path  string
+		want  string
+		files int
+	}{
+		{name: "file", tree: map[string]string{"a.md": "a"}, path: "a.md", want: "a copy.md", files: 1},
+		{name: "second copy", tree: map[string]string{"a.md": "a", "a copy.md": "c"}, path: "a.md", want: "a copy 2.md", files: 1},
+		{name: "encrypted note", tree: map[string]string{"a" + EncryptedNoteExt: "x"}, path: "a" + EncryptedNoteExt, want: "a copy" + EncryptedNoteExt, files: 1},
+		{name: "folder keeps dots", tree: map[string]string{"v1.2/x.md": "x"}, path: "v1.2", want: "v1.2 copy", files: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, tt.tree)
+			result, err := NewFileService().Duplicate(context.Background(), filepath.Join(root, tt.path), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Items) != 1 || result.Items[0].To != filepath.Join(root, tt.want) {
+				t.Errorf("Items = %v, want copy at %s", result.Items, tt.want)
+			}
+			if result.Files != tt.files {
+				t.Errorf("Files = %d, want %d", result.Files, tt.files)
+			}
+		})
+	}
+}
+
+func TestTransferCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"src/a.md": "a", "src/b.md": "b", "dst/": ""})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := NewFileService().Copy(ctx, []string{filepath.Join(root, "src")}, filepath.Join(root, "dst"), ConflictSkip, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if result.Files != 0 || len(result.Items) != 0 {
+		t.Errorf("result = %+v, want nothing copied", result)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "dst")); len(entries) != 0 {
+		t.Errorf("dst has %d entries after a cancelled copy", len(entries))
+	}
+}
+
+func TestTransferReportsPartialMove(t *testing.T) {
+	root := t.TempDir()
+	tree := map[string]string{"dst/dir/": ""}
+	for i := 0; i < 2*progressInterval; i++ {
+		tree[fmt.Sprintf("src/dir/%02d.md", i)] = "x"
+	}
+	writeTree(t, root, tree)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result, err := NewFileService().Move(ctx, []string{filepath.Join(root, "src", "dir")}, filepath.Join(root, "dst"), ConflictSkip, func(p TransferProgress) {
+		if p.Phase == "moving" {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(result.Moved) != progressInterval || result.Files != progressInterval {
+		t.Fatalf("moved %d, Files %d, want %d", len(result.Moved), result.Files, progressInterval)
+	}
+	for _, item := range result.Moved {
+		if _, err := os.Stat(item.From); err == nil {
+			t.Errorf("%s is still at its old path", item.From)
+		}
+		if _, err := os.Stat(item.To); err != nil {
+			t.Errorf("%s is missing", item.To)
+		}
+	}
+}
+
+func TestCopyVaultAsStored(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"notes/vault/secret.md": "secret", "dst/": ""})
+	vault, err := CreateVault(filepath.Join(root, "notes", "vault"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Lock()
+
+	files := NewFileService()
+	if _, err := files.Copy(context.Background(), []string{filepath.Join(root, "notes")}, filepath.Join(root, "dst"), ConflictSkip, nil); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := UnlockVault(filepath.Join(root, "dst", "notes", "vault"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Lock()
+	data, err := copied.ReadFile(filepath.Join(root, "dst", "notes", "vault", "secret.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "secret" {
+		t.Errorf("copied vault note = %q", data)
+	}
+}
@@ -0,0 +1,725 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// VaultConfigName marks a folder as an encrypted vault. It holds the master
+// key wrapped with the passphrase; everything else below the folder is
+// ciphertext with encrypted names.
+const VaultConfigName = ".markdowndaonote-vault.json"
+
+// ErrVaultLocked is returned when a vault is used after it has been locked.
+var ErrVaultLocked = errors.New("vault is locked")
+
+const (
+	vaultVersion     = 1
+	vaultStagingName = ".markdowndaonote-vault-staging"
+	// 提交暂存时旧内容先移到这里，全部换好后才删除
+	vaultRetiredName = ".markdowndaonote-vault-retired"
+	// 加密后的文件名（base32）不超过 255 字节
+	vaultMaxNameSize = 119
+)
+
+var (
+	vaultContentMagic = []byte("MDDNVLT1")
+	vaultWrapAAD      = []byte("markdowndaonote vault v1")
+	vaultNameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+type vaultConfig struct {
+	Version    int    `json:"version"`
+	LogN       int    `json:"logN"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// IsVault reports whether dir is the root of a vault.
+func IsVault(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, VaultConfigName))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// hiddenFileName reports whether a file is left out of sync and backups.
+// The vault config is hidden but must travel with the vault: without it the
+// copied ciphertext can never be unlocked.
+func hiddenFileName(name string) bool {
+	return strings.HasPrefix(name, ".") && name != VaultConfigName
+}
+
+// Vault maps logical paths below its root to encrypted blobs. Each path
+// component is encrypted deterministically, so a logical path always maps
+// to the same physical path without an index; file contents are sealed with
+// a fresh nonce on every write. Hidden entries directly in the root (the
+// config file, .git, ...) are not part of the vault.
+type Vault struct {
+	root string
+
+	mu         sync.RWMutex
+	nameMAC    []byte
+	nameKey    []byte
+	contentKey []byte
+}
+
+// CreateVault turns dir into a vault protected by passphrase. Files already
+// in the folder are encrypted and their plaintext removed.
+func CreateVault(dir string, passphrase string) (*Vault, error) {
+	root := filepath.Clean(dir)
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+	if IsVault(root) {
+		return nil, fmt.Errorf("%s is already a vault", root)
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a folder", root)
+	}
+
+	master, err := randomBytes(noteKeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(master)
+	vault := &Vault{root: root}
+	vault.setKeys(master)
+
+	// 先在暂存目录写好密文，写入配置后再替换明文
+	staging := filepath.Join(root, vaultStagingName)
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	err = copyVaultTree(root, staging, true,
+		func(name string) (string, bool, error) {
+			encrypted, err := vault.encryptName(name)
+			return encrypted, true, err
+		},
+		vault.seal,
+	)
+	if err == nil {
+		err = writeVaultConfig(root, master, passphrase)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := vault.commitStaging(); err != nil {
+		return nil, err
+	}
+	return vault, nil
+}
+
+// UnlockVault opens the vault at dir with passphrase.
+func UnlockVault(dir string, passphrase string) (*Vault, error) {
+	root := filepath.Clean(dir)
+	data, err := os.ReadFile(filepath.Join(root, VaultConfigName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s is not a vault", root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config vaultConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("reading vault config: %w", err)
+	}
+	if config.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported vault version %d", config.Version)
+	}
+
+	kek, err := scryptKey(passphrase, config.Salt, config.LogN, config.R, config.P)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(kek)
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Nonce) != aead.NonceSize() {
+		return nil, errors.New("corrupted vault config")
+	}
+	master, err := aead.Open(nil, config.Nonce, config.WrappedKey, vaultWrapAAD)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	defer wipeBytes(master)
+
+	vault := &Vault{root: root}
+	vault.setKeys(master)
+	if err := vault.recoverStaging(); err != nil {
+		vault.Lock()
+		return nil, err
+	}
+	return vault, nil
+}
+
+// Root returns the folder of the vault.
+func (v *Vault) Root() string {
+	return v.root
+}
+
+// Contains reports whether path is the vault root or lies below it.
+func (v *Vault) Contains(path string) bool {
+	rel, err := filepath.Rel(v.root, filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Lock wipes the keys; the vault cannot be used afterwards.
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	wipeBytes(v.nameMAC)
+	wipeBytes(v.nameKey)
+	wipeBytes(v.contentKey)
+	v.nameMAC, v.nameKey, v.contentKey = nil, nil, nil
+}
+
+// ReadFile decrypts the file at the logical path.
+func (v *Vault) ReadFile(path string) ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(physical)
+	if err != nil {
+		return nil, v.logicalError(err, path)
+	}
+	return v.open(data)
+}
+
+// WriteFile encrypts data and replaces the file at the logical path atomically.
+func (v *Vault) WriteFile(path string, data []byte) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return err
+	}
+	sealed, err := v.seal(data)
+	if err != nil {
+		return err
+	}
+	return v.logicalError(writeFileAtomic(physical, sealed), path)
+}
+
+// CreateFile encrypts data into a new file at the logical path; it fails
+// with fs.ErrExist when the file is already there.
+func (v *Vault) CreateFile(path string, data []byte) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return err
+	}
+	sealed, err := v.seal(data)
+	if err != nil {
+		return err
+	}
+	return v.logicalError(createFileExclusive(physical, sealed), path)
+}
+
+// MkdirAll creates the folder at the logical path with its parents.
+func (v *Vault) MkdirAll(path string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return err
+	}
+	return v.logicalError(os.MkdirAll(physical, 0o755), path)
+}
+
+// RemoveAll deletes the file or folder at the logical path.
+func (v *Vault) RemoveAll(path string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return err
+	}
+	if physical == v.root {
+		return errors.New("cannot delete the vault root from inside the vault")
+	}
+	return v.logicalError(os.RemoveAll(physical), path)
+}
+
+// Rename moves a file or folder inside the vault; newPath must not exist.
+func (v *Vault) Rename(oldPath string, newPath string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	oldPhysical, err := v.physicalPath(oldPath)
+	if err != nil {
+		return err
+	}
+	newPhysical, err := v.physicalPath(newPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(oldPhysical); err != nil {
+		return v.logicalError(err, oldPath)
+	}
+	if _, err := os.Lstat(newPhysical); err == nil {
+		return os.ErrExist
+	}
+	return v.logicalError(os.Rename(oldPhysical, newPhysical), newPath)
+}
+
+// Lstat describes the entry at the logical path.
+func (v *Vault) Lstat(path string) (fs.FileInfo, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(physical)
+	if err != nil {
+		return nil, v.logicalError(err, path)
+	}
+	if physical == v.root {
+		return info, nil
+	}
+	return vaultFileInfo{FileInfo: info, name: filepath.Base(path)}, nil
+}
+
+// ReadDir lists the folder at the logical path with decrypted names.
+func (v *Vault) ReadDir(path string) ([]fs.DirEntry, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	physical, err := v.physicalPath(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(physical)
+	if err != nil {
+		return nil, v.logicalError(err, path)
+	}
+	result := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		name, err := v.decryptName(entry.Name())
+		if err != nil {
+			// 配置文件、.git 等不属于保险库
+			continue
+		}
+		result = append(result, vaultDirEntry{DirEntry: entry, name: name})
+	}
+	return result, nil
+}
+
+// Rotate re-encrypts every name and file under a new master key wrapped
+// with passphrase, which may be the current one or a new one.
+func (v *Vault) Rotate(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("passphrase is required")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.contentKey == nil {
+		return ErrVaultLocked
+	}
+
+	master, err := randomBytes(noteKeySize)
+	if err != nil {
+		return err
+	}
+	defer wipeBytes(master)
+	next := &Vault{root: v.root}
+	next.setKeys(master)
+
+	staging := filepath.Join(v.root, vaultStagingName)
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	err = copyVaultTree(v.root, staging, true,
+		func(name string) (string, bool, error) {
+			plain, err := v.decryptName(name)
+			if err != nil {
+				return "", false, nil
+			}
+			encrypted, err := next.encryptName(plain)
+			return encrypted, true, err
+		},
+		func(data []byte) ([]byte, error) {
+			plain, err := v.open(data)
+			if err != nil {
+				return nil, err
+			}
+			return next.seal(plain)
+		},
+	)
+	if err == nil {
+		err = writeVaultConfig(v.root, master, passphrase)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+
+	// 配置已写入新密钥，从此刻起只有新密文有效
+	wipeBytes(v.nameMAC)
+	wipeBytes(v.nameKey)
+	wipeBytes(v.contentKey)
+	v.nameMAC, v.nameKey, v.contentKey = next.nameMAC, next.nameKey, next.contentKey
+	return v.commitStaging()
+}
+
+// Export writes the decrypted contents of the vault to dest, which must not
+// exist yet.
+func (v *Vault) Export(dest string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.contentKey == nil {
+		return ErrVaultLocked
+	}
+	dest = filepath.Clean(dest)
+	if v.Contains(dest) {
+		return errors.New("cannot export a vault into itself")
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	err := copyVaultTree(v.root, dest, true,
+		func(name string) (string, bool, error) {
+			plain, err := v.decryptName(name)
+			return plain, err == nil, nil
+		},
+		v.open,
+	)
+	if err != nil {
+		os.RemoveAll(dest)
+	}
+	return err
+}
+
+func (v *Vault) setKeys(master []byte) {
+	v.nameMAC = deriveSubkey(master, "name-mac")
+	v.nameKey = deriveSubkey(master, "name")
+	v.contentKey = deriveSubkey(master, "content")
+}
+
+// physicalPath maps a logical path to the encrypted path on disk.
+func (v *Vault) physicalPath(path string) (string, error) {
+	if v.contentKey == nil {
+		return "", ErrVaultLocked
+	}
+	rel, err := filepath.Rel(v.root, filepath.Clean(path))
+	if err != nil || !v.Contains(path) {
+		return "", fmt.Errorf("%s is outside the vault %s", path, v.root)
+	}
+	if rel == "." {
+		return v.root, nil
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		if parts[i], err = v.encryptName(part); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(append([]string{v.root}, parts...)...), nil
+}
+
+// logicalError replaces the physical path in a *fs.PathError with the
+// logical one, so errors never show ciphertext names.
+func (v *Vault) logicalError(err error, path string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: path, Err: pathErr.Err}
+	}
+	return err
+}
+
+// encryptName seals a name with a nonce derived from the name itself, so the
+// same name always yields the same ciphertext.
+func (v *Vault) encryptName(name string) (string, error) {
+	if len(name) > vaultMaxNameSize {
+		return "", fmt.Errorf("name %q is too long for a vault", name)
+	}
+	aead, err := chacha20poly1305.NewX(v.nameKey)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, v.nameMAC)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+	sealed := aead.Seal(append([]byte(nil), nonce...), nonce, []byte(name), nil)
+	// 小写 base32 在不区分大小写的文件系统上也安全
+	return strings.ToLower(vaultNameEncoding.EncodeToString(sealed)), nil
+}
+
+func (v *Vault) decryptName(physical string) (string, error) {
+	raw, err := vaultNameEncoding.DecodeString(strings.ToUpper(physical))
+	if err != nil || len(raw) < chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return "", errors.New("not a vault name")
+	}
+	aead, err := chacha20poly1305.NewX(v.nameKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("not a vault name")
+	}
+	name := string(plain)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid name %q in vault", name)
+	}
+	return name, nil
+}
+
+func (v *Vault) seal(plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(v.contentKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	out := append(append([]byte(nil), vaultContentMagic...), nonce...)
+	return aead.Seal(out, nonce, plaintext, vaultContentMagic), nil
+}
+
+func (v *Vault) open(data []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(v.contentKey)
+	if err != nil {
+		return nil, err
+	}
+	header := len(vaultContentMagic) + aead.NonceSize()
+	if len(data) < header || !bytes.Equal(data[:len(vaultContentMagic)], vaultContentMagic) {
+		return nil, errors.New("not a vault file")
+	}
+	plain, err := aead.Open(nil, data[len(vaultContentMagic):header], data[header:], vaultContentMagic)
+	if err != nil {
+		return nil, errors.New("vault file is corrupted")
+	}
+	return plain, nil
+}
+
+// commitStaging replaces the vault contents with the staged tree. The old
+// entries are first moved aside into a retired folder, which is renamed to
+// its final name once all of them are there; only after every staged entry
+// has reached the root is anything deleted. Each step can be repeated, so a
+// commit interrupted by a crash is finished by running it again.
+func (v *Vault) commitStaging() error {
+	staging := filepath.Join(v.root, vaultStagingName)
+	retired := filepath.Join(v.root, vaultRetiredName)
+	if _, err := os.Lstat(staging); errors.Is(err, os.ErrNotExist) {
+		// 暂存已全部换入，只剩清理旧内容
+		return os.RemoveAll(retired)
+	}
+	if _, err := os.Lstat(retired); errors.Is(err, os.ErrNotExist) {
+		// 旧内容未全部移开前根目录里只有旧条目，可以放心重复移动
+		partial := retired + ".partial"
+		if err := os.MkdirAll(partial, 0o755); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(v.root)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if err := os.Rename(filepath.Join(v.root, entry.Name()), filepath.Join(partial, entry.Name())); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(partial, retired); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	staged, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	for _, entry := range staged {
+		if err := os.Rename(filepath.Join(staging, entry.Name()), filepath.Join(v.root, entry.Name())); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(staging); err != nil {
+		return err
+	}
+	return os.RemoveAll(retired)
+}
+
+// recoverStaging finishes or discards a rotation interrupted by a crash. A
+// commit that had started moving entries is finished; otherwise staged
+// entries readable with the current key were committed by the config.
+func (v *Vault) recoverStaging() error {
+	retired := filepath.Join(v.root, vaultRetiredName)
+	for _, marker := range []string{retired, retired + ".partial"} {
+		if _, err := os.Lstat(marker); err == nil {
+			return v.commitStaging()
+		}
+	}
+	staging := filepath.Join(v.root, vaultStagingName)
+	entries, err := os.ReadDir(staging)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := v.decryptName(entry.Name()); err == nil {
+			return v.commitStaging()
+		}
+	}
+	return os.RemoveAll(staging)
+}
+
+// copyVaultTree recreates src below dst, translating every name with rename
+// (entries it rejects are skipped) and every file with transform. Hidden
+// entries directly in src are left out when skipHidden is set.
+func copyVaultTree(src string, dst string, skipHidden bool, rename func(string) (string, bool, error), transform func([]byte) ([]byte, error)) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if skipHidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name, ok, err := rename(entry.Name())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		source := filepath.Join(src, entry.Name())
+		target := filepath.Join(dst, name)
+
+		switch {
+		case entry.IsDir():
+			if err := copyVaultTree(source, target, false, rename, transform); err != nil {
+				return err
+			}
+		case entry.Type().IsRegular():
+			data, err := os.ReadFile(source)
+			if err != nil {
+				return err
+			}
+			converted, err := transform(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			if err := os.WriteFile(target, converted, 0o644); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: only files and folders can be stored in a vault", source)
+		}
+	}
+	return nil
+}
+
+func writeVaultConfig(root string, master []byte, passphrase string) error {
+	salt, err := randomBytes(noteSaltSize)
+	if err != nil {
+		return err
+	}
+	kek, err := scryptKey(passphrase, salt, noteScryptLogN, noteScryptR, noteScryptP)
+	if err != nil {
+		return err
+	}
+	defer wipeBytes(kek)
+	aead, err := chacha20poly1305.NewX(kek)
+	if err != nil {
+		return err
+	}
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(vaultConfig{
+		Version:    vaultVersion,
+		LogN:       noteScryptLogN,
+		R:          noteScryptR,
+		P:          noteScryptP,
+		Salt:       salt,
+		Nonce:      nonce,
+		WrappedKey: aead.Seal(nil, nonce, master, vaultWrapAAD),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(root, VaultConfigName), data)
+}
+
+func deriveSubkey(master []byte, label string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func wipeBytes(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
+
+// vaultFileInfo and vaultDirEntry report the decrypted name of an entry.
+type vaultFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i vaultFileInfo) Name() string { return i.name }
+
+type vaultDirEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e vaultDirEntry) Name() string { return e.name }
+
+func (e vaultDirEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return vaultFileInfo{FileInfo: info, name: e.name}, nil
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.md": "alpha", "dir/b.md": "beta", ".git/HEAD": "ref"})
+	vault, err := CreateVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Lock()
+
+	for name, content := range readTree(t, root) {
+		if strings.Contains(name, "a.md") || strings.Contains(name, "b.md") || content == "alpha" || content == "beta" {
+			t.Errorf("plaintext %s left on disk", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, ".git", "HEAD")); err != nil {
+		t.Errorf("hidden entry was encrypted: %v", err)
+	}
+
+	if _, err := UnlockVault(root, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v", err)
+	}
+	unlocked, err := UnlockVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlocked.Lock()
+	data, err := unlocked.ReadFile(filepath.Join(root, "dir", "b.md"))
+	if err != nil || string(data) != "beta" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+}
+
+func TestVaultRotate(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.md": "alpha", "dir/b.md": "beta"})
+	vault, err := CreateVault(root, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Lock()
+	if err := vault.Rotate("new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, vaultStagingName)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("staging left behind: %v", err)
+	}
+	if _, err := UnlockVault(root, "old"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("old passphrase still opens the vault: %v", err)
+	}
+	rotated, err := UnlockVault(root, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rotated.Lock()
+	for name, want := range map[string]string{"a.md": "alpha", "dir/b.md": "beta"} {
+		data, err := rotated.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+}
+
+// TestVaultCommitStagingResumes interrupts a commit at each step and checks
+// that running it again leaves exactly the staged entries.
+func TestVaultCommitStagingResumes(t *testing.T) {
+	retired := vaultRetiredName
+	staging := vaultStagingName
+	tests := []struct {
+		name string
+		tree map[string]string
+	}{
+		{
+			name: "not started",
+			tree: map[string]string{"old1": "o", "old2": "o", staging + "/new1": "n", staging + "/new2": "n"},
+		},
+		{
+			name: "moving old entries aside",
+			tree: map[string]string{retired + ".partial/old1": "o", "old2": "o", staging + "/new1": "n", staging + "/new2": "n"},
+		},
+		{
+			name: "moving staged entries in",
+			tree: map[string]string{retired + "/old1": "o", retired + "/old2": "o", "new1": "n", staging + "/new2": "n"},
+		},
+		{
+			name: "all staged entries in",
+			tree: map[string]string{retired + "/old1": "o", retired + "/old2": "o", "new1": "n", "new2": "n", staging + "/": ""},
+		},
+		{
+			name: "deleting old entries",
+			tree: map[string]string{retired + "/old2": "o", "new1": "n", "new2": "n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			tree := map[string]string{VaultConfigName: "{}"}
+			for name, content := range tt.tree {
+				tree[name] = content
+			}
+			writeTree(t, root, tree)
+			vault := &Vault{root: root}
+			if err := vault.commitStaging(); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{VaultConfigName: "{}", "new1": "n", "new2": "n"}
+			if got := readTree(t, root); !reflect.DeepEqual(got, want) {
+				t.Errorf("tree = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestVaultRecoverStagingFinishesCommit(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.md": "alpha"})
+	vault, err := CreateVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Lock()
+
+	// 模拟提交中途崩溃：密文已移开一半，暂存里留着另一半
+	entries, _ := os.ReadDir(root)
+	var encrypted string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			encrypted = entry.Name()
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, vaultStagingName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, vaultRetiredName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, encrypted), filepath.Join(root, vaultStagingName, encrypted)); err != nil {
+		t.Fatal(err)
+	}
+
+	unlocked, err := UnlockVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlocked.Lock()
+	data, err := unlocked.ReadFile(filepath.Join(root, "a.md"))
+	if err != nil || string(data) != "alpha" {
+		t.Fatalf("after recovery a.md = %q, %v", data, err)
+	}
+	for _, name := range []string{vaultStagingName, vaultRetiredName} {
+		if _, err := os.Stat(filepath.Join(root, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind", name)
+		}
+	}
+}
+
+func TestVaultRecoverStagingDiscardsUncommitted(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.md": "alpha"})
+	vault, err := CreateVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Lock()
+	// 配置写入前崩溃：暂存里的名字当前密钥无法解开
+	writeTree(t, root, map[string]string{vaultStagingName + "/not-a-vault-name": "x"})
+
+	unlocked, err := UnlockVault(root, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlocked.Lock()
+	if _, err := os.Stat(filepath.Join(root, vaultStagingName)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("uncommitted staging kept: %v", err)
+	}
+	if data, err := unlocked.ReadFile(filepath.Join(root, "a.md")); err != nil || string(data) != "alpha" {
+		t.Errorf("a.md = %q, %v", data, err)
+	}
+}
+
+func TestFileServiceWalkThroughVaults(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"note.md": "n", "open/a.md": "a", "open/b.txt": "b", "closed/c.md": "c"})
+	open, err := CreateVault(filepath.Join(root, "open"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := CreateVault(filepath.Join(root, "closed"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Lock()
+
+	files := NewFileService()
+	files.MountVault(open)
+	defer files.UnmountAllVaults()
+
+	tests := []struct {
+		markdownOnly bool
+		want         []string
+	}{
+		{markdownOnly: true, want: []string{"note.md", "open/a.md"}},
+		{markdownOnly: false, want: []string{"note.md", "open/a.md", "open/b.txt"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := files.WalkWorkspaceFiles(root, tt.markdownOnly, func(path string, _ fs.FileInfo) error {
+			rel, _ := filepath.Rel(root, path)
+			got = append(got, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("markdownOnly=%v: visited %v, want %v", tt.markdownOnly, got, tt.want)
+		}
+	}
+
+	index := NewWorkspaceIndex(files)
+	if err := index.Build(root); err != nil {
+		t.Fatal(err)
+	}
+	var indexed []string
+	for _, note := range index.Notes() {
+		indexed = append(indexed, note.RelPath)
+	}
+	if want := []string{"note.md", "open/a.md"}; !reflect.DeepEqual(indexed, want) {
+		t.Errorf("indexed %v, want %v", indexed, want)
+	}
+}
+
+func TestFileServiceCreateNew(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"taken.md": "old", "vault/a.md": "a"})
+	vault, err := CreateVault(filepath.Join(root, "vault"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := NewFileService()
+	files.MountVault(vault)
+	defer files.UnmountAllVaults()
+
+	for _, path := range []string{filepath.Join(root, "new.md"), filepath.Join(root, "vault", "new.md")} {
+		if err := files.CreateNew(path, "fresh"); err != nil {
+			t.Fatalf("CreateNew(%s): %v", path, err)
+		}
+		if content, err := files.Read(path); err != nil || content != "fresh" {
+			t.Errorf("Read(%s) = %q, %v", path, content, err)
+		}
+		if err := files.CreateNew(path, "again"); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("CreateNew over %s: err = %v", path, err)
+		}
+	}
+	if err := files.CreateNew(filepath.Join(root, "taken.md"), "new"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("CreateNew over a plain note: err = %v", err)
+	}
+	if content, _ := files.Read(filepath.Join(root, "taken.md")); content != "old" {
+		t.Errorf("existing note replaced with %q", content)
+	}
+
+	files.UnmountAllVaults()
+	if err := files.CreateNew(filepath.Join(root, "vault", "locked.md"), "x"); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("CreateNew in a locked vault: err = %v", err)
+	}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrWebDAVPrecondition is returned when an If-Match/If-None-Match condition
+// fails, i.e. the remote file changed since it was last seen.
+var ErrWebDAVPrecondition = errors.New("remote file changed")
+
+const webdavRequestTimeout = 60 * time.Second
+
+// WebDAVClient talks to a WebDAV collection such as a Nextcloud folder.
+type WebDAVClient struct {
+	base     *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+// WebDAVEntry is a remote file below the client's base collection.
+type WebDAVEntry struct {
+	// Path is slash-separated and relative to the base collection.
+	Path    string
+	ETag    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// NewWebDAVClient creates a client for the collection at rawURL.
+func NewWebDAVClient(rawURL string, username string, password string) (*WebDAVClient, error) {
+	base, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported WebDAV URL %q", rawURL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return &WebDAVClient{
+		base:     base,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: webdavRequestTimeout},
+	}, nil
+}
+
+// List walks the whole collection and returns its files keyed by path.
+// Hidden files and folders are skipped, matching WalkWorkspaceFiles.
+func (c *WebDAVClient) List(ctx context.Context) (map[string]WebDAVEntry, error) {
+	files := map[string]WebDAVEntry{}
+	// Depth: infinity 常被服务器禁用，逐层列出
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		entries, err := c.propfind(ctx, dir, "1")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Path == dir || hiddenWebDAVPath(entry.Path) {
+				continue
+			}
+			if entry.IsDir {
+				pending = append(pending, entry.Path)
+				continue
+			}
+			files[entry.Path] = entry
+		}
+	}
+	return files, nil
+}
+
+// Stat returns the properties of a single remote file.
+func (c *WebDAVClient) Stat(ctx context.Context, rel string) (WebDAVEntry, error) {
+	entries, err := c.propfind(ctx, rel, "0")
+	if err != nil {
+		return WebDAVEntry{}, err
+	}
+	if len(entries) == 0 {
+		return WebDAVEntry{}, fmt.Errorf("no properties returned for %s", rel)
+	}
+	return entries[0], nil
+}
+
+// Get downloads a file and returns its content and ETag.
+func (c *WebDAVClient) Get(ctx context.Context, rel string) ([]byte, string, error) {
+	response, err := c.do(ctx, http.MethodGet, rel, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, "", webdavStatusError(http.MethodGet, rel, response)
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, response.Header.Get("ETag"), nil
+}
+
+// Put uploads data. With an ETag the upload only succeeds if the remote file
+// still has it; with an empty ETag it only succeeds if the file is new.
+// It returns the new ETag.
+func (c *WebDAVClient) Put(ctx context.Context, rel string, data []byte, etag string) (string, error) {
+	headers := map[string]string{"Content-Type": "application/octet-stream"}
+	if etag != "" {
+		headers["If-Match"] = etag
+	} else {
+		headers["If-None-Match"] = "*"
+	}
+
+	response, err := c.do(ctx, http.MethodPut, rel, data, headers)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed:
+		return "", fmt.Errorf("%s: %w", rel, ErrWebDAVPrecondition)
+	default:
+		return "", webdavStatusError(http.MethodPut, rel, response)
+	}
+
+	if newETag := response.Header.Get("ETag"); newETag != "" {
+		return newETag, nil
+	}
+	// 部分服务器的 PUT 响应不带 ETag，需要再查询一次
+	entry, err := c.Stat(ctx, rel)
+	if err != nil {
+		return "", err
+	}
+	return entry.ETag, nil
+}
+
+// Delete removes a remote file, provided it still has etag when one is given.
+func (c *WebDAVClient) Delete(ctx context.Context, rel string, etag string) error {
+	var headers map[string]string
+	if etag != "" {
+		headers = map[string]string{"If-Match": etag}
+	}
+	response, err := c.do(ctx, http.MethodDelete, rel, nil, headers)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("%s: %w", rel, ErrWebDAVPrecondition)
+	}
+	return webdavStatusError(http.MethodDelete, rel, response)
+}
+
+// MkdirAll creates the collection dir and any missing parents.
+func (c *WebDAVClient) MkdirAll(ctx context.Context, dir string) error {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return nil
+	}
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		response, err := c.do(ctx, "MKCOL", current+"/", nil, nil)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		// 405 表示集合已存在
+		if response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusMethodNotAllowed {
+			return webdavStatusError("MKCOL", current, response)
+		}
+	}
+	return nil
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop>
+<d:resourcetype/><d:getetag/><d:getcontentlength/><d:getlastmodified/>
+</d:prop></d:propfind>`
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ETag          string `xml:"getetag"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (c *WebDAVClient) propfind(ctx context.Context, rel string, depth string) ([]WebDAVEntry, error) {
+	target := rel
+	if target != "" && depth != "0" {
+		target += "/"
+	}
+	response, err := c.do(ctx, "PROPFIND", target, []byte(propfindBody), map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusMultiStatus {
+		return nil, webdavStatusError("PROPFIND", rel, response)
+	}
+
+	var status webdavMultistatus
+	if err := xml.NewDecoder(response.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: %w", rel, err)
+	}
+
+	entries := make([]WebDAVEntry, 0, len(status.Responses))
+	for _, item := range status.Responses {
+		entryPath, ok := c.relativeHref(item.Href)
+		if !ok {
+			continue
+		}
+		entry := WebDAVEntry{Path: entryPath}
+		for _, propstat := range item.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			prop := propstat.Prop
+			entry.IsDir = prop.ResourceType.Collection != nil
+			entry.ETag = prop.ETag
+			entry.Size, _ = strconv.ParseInt(prop.ContentLength, 10, 64)
+			entry.ModTime, _ = http.ParseTime(prop.LastModified)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// relativeHref maps a multistatus href back to a path below the base.
+func (c *WebDAVClient) relativeHref(href string) (string, bool) {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	rel, ok := strings.CutPrefix(parsed.Path, c.base.Path)
+	if !ok {
+		// 服务器可能返回未编码或不带末尾斜杠的基础路径
+		if strings.TrimSuffix(parsed.Path, "/") != strings.TrimSuffix(c.base.Path, "/") {
+			return "", false
+		}
+		rel = ""
+	}
+	rel = strings.Trim(rel, "/")
+	// 路径来自服务器，拒绝逃逸出工作区的路径；反斜杠在 Windows 上是分隔符
+	if rel != "" && !localRelativePath(rel) {
+		return "", false
+	}
+	return rel, true
+}
+
+// localRelativePath reports whether the slash-separated rel names a file
+// inside the folder it is joined to, on every platform.
+func localRelativePath(rel string) bool {
+	return rel != "." && path.Clean(rel) == rel && !strings.Contains(rel, "\\") && filepath.IsLocal(filepath.FromSlash(rel))
+}
+
+func (c *WebDAVClient) do(ctx context.Context, method string, rel string, body []byte, headers map[string]string) (*http.Response, error) {
+	target := c.base.JoinPath(strings.Split(rel, "/")...)
+	if (rel == "" || strings.HasSuffix(rel, "/")) && !strings.HasSuffix(target.Path, "/") {
+		target.Path += "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webdavRequestTimeout)
+	request, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if c.username != "" || c.password != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = cancelOnClose{response.Body, cancel}
+	return response, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func webdavStatusError(method string, rel string, response *http.Response) error {
+	if response.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%s %s: authentication failed", method, rel)
+	}
+	return fmt.Errorf("%s %s: %s", method, rel, response.Status)
+}
+
+func hiddenWebDAVPath(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		// 最后一段是文件名，保险库配置要同步
+		if i == len(parts)-1 && !hiddenFileName(part) {
+			return false
+		}
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
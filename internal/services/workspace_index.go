@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NoteMeta captures the indexed metadata of a single Markdown note.
+type NoteMeta struct {
+	Path        string                 `json:"path"`
+	RelPath     string                 `json:"relPath"`
+	Name        string                 `json:"name"`
+	Size        int64                  `json:"size"`
+	ModTime     time.Time              `json:"modTime"`
+	FrontMatter map[string]interface{} `json:"frontMatter"`
+	Tags        []string               `json:"tags"`
+	Tasks       []TaskItem             `json:"tasks,omitempty"`
+	Stats       DocumentStats          `json:"stats"`
+}
+
+// WorkspaceIndex keeps an in-memory index of Markdown notes beneath a root
+// folder. Notes of unlocked vaults are read through files.
+type WorkspaceIndex struct {
+	mu    sync.RWMutex
+	files *FileService
+	root  string
+	notes map[string]*NoteMeta
+}
+
+// NewWorkspaceIndex constructs an empty WorkspaceIndex reading through files.
+func NewWorkspaceIndex(files *FileService) *WorkspaceIndex {
+	return &WorkspaceIndex{files: files, notes: map[string]*NoteMeta{}}
+}
+
+// Root returns the folder currently indexed.
+func (w *WorkspaceIndex) Root() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.root
+}
+
+// Build scans root recursively and replaces the index contents.
+func (w *WorkspaceIndex) Build(root string) error {
+	clean := filepath.Clean(strings.TrimSpace(root))
+	if clean == "" || clean == "." {
+		return errors.New("workspace root is required")
+	}
+
+	notes := map[string]*NoteMeta{}
+	err := w.files.WalkWorkspaceFiles(clean, true, func(path string, info fs.FileInfo) error {
+		meta, err := w.readNoteMeta(clean, path, info)
+		if err != nil {
+			// 单个文件解析失败不影响整体索引
+			return nil
+		}
+		notes[path] = meta
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.root = clean
+	w.notes = notes
+	w.mu.Unlock()
+	return nil
+}
+
+// Update re-reads a note (or every note below a folder), adding it to the index
+// when it lives under the root.
+func (w *WorkspaceIndex) Update(path string) error {
+	w.mu.RLock()
+	root := w.root
+	w.mu.RUnlock()
+	if root == "" || !isWithinRoot(root, path) {
+		return nil
+	}
+
+	clean := filepath.Clean(path)
+	info, err := w.files.Lstat(clean)
+	if errors.Is(err, os.ErrNotExist) {
+		w.Remove(clean)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return w.files.WalkWorkspaceFiles(clean, true, func(child string, _ fs.FileInfo) error {
+			return w.Update(child)
+		})
+	}
+	if !IsMarkdownFile(clean) || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	meta, err := w.readNoteMeta(root, clean, info)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.notes[clean] = meta
+	w.mu.Unlock()
+	return nil
+}
+
+// Remove drops a note (or every note below a folder) from the index.
+func (w *WorkspaceIndex) Remove(path string) {
+	clean := filepath.Clean(path)
+	prefix := clean + string(filepath.Separator)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key := range w.notes {
+		if key == clean || strings.HasPrefix(key, prefix) {
+			delete(w.notes, key)
+		}
+	}
+}
+
+// Notes returns a snapshot of every indexed note sorted by relative path.
+func (w *WorkspaceIndex) Notes() []NoteMeta {
+	w.mu.RLock()
+	notes := make([]NoteMeta, 0, len(w.notes))
+	for _, meta := range w.notes {
+		notes = append(notes, *meta)
+	}
+	w.mu.RUnlock()
+
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].RelPath < notes[j].RelPath
+	})
+	return notes
+}
+
+// Tasks returns every indexed checkbox item matching the filter.
+func (w *WorkspaceIndex) Tasks(filter TaskFilter) []TaskItem {
+	w.mu.RLock()
+	tasks := []TaskItem{}
+	for _, meta := range w.notes {
+		tasks = append(tasks, meta.Tasks...)
+	}
+	w.mu.RUnlock()
+
+	return FilterTasks(tasks, filter)
+}
+
+// Task looks up the indexed task at the given file and 1-based line.
+func (w *WorkspaceIndex) Task(path string, line int) (TaskItem, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	meta, ok := w.notes[filepath.Clean(path)]
+	if !ok {
+		return TaskItem{}, false
+	}
+	for _, task := range meta.Tasks {
+		if task.Line == line {
+			return task, true
+		}
+	}
+	return TaskItem{}, false
+}
+
+// WalkMarkdownFiles visits every Markdown file below root, skipping hidden folders.
+func WalkMarkdownFiles(root string, visit func(path string, info fs.FileInfo) error) error {
+	return WalkWorkspaceFiles(root, true, visit)
+}
+
+// WalkWorkspaceFiles visits regular files below root, skipping hidden folders.
+// When markdownOnly is set, non-Markdown files are ignored. Files are visited
+// as stored on disk, so vault contents stay encrypted; backups and sync rely
+// on that.
+func WalkWorkspaceFiles(root string, markdownOnly bool, visit func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if markdownOnly && !IsMarkdownFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		return visit(path, info)
+	})
+}
+
+// WalkWorkspaceFiles is WalkWorkspaceFiles seen through the vaults: files
+// of unlocked vaults are visited under their plain paths, locked vaults are
+// left out.
+func (s *FileService) WalkWorkspaceFiles(root string, markdownOnly bool, visit func(path string, info fs.FileInfo) error) error {
+	root = filepath.Clean(root)
+	info, err := s.Lstat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() || markdownOnly && !IsMarkdownFile(info.Name()) {
+			return nil
+		}
+		return visit(root, info)
+	}
+	if _, err := s.ReadDir(root); err != nil {
+		return err
+	}
+	return s.walkFiles(root, markdownOnly, visit)
+}
+
+func (s *FileService) walkFiles(dir string, markdownOnly bool, visit func(path string, info fs.FileInfo) error) error {
+	if IsVault(dir) && s.Vault(dir) == nil {
+		// 锁定的保险库里只有密文
+		return nil
+	}
+	entries, err := s.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if err := s.walkFiles(path, markdownOnly, visit); err != nil {
+				return err
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() || markdownOnly && !IsMarkdownFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if err := visit(path, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WorkspaceIndex) readNoteMeta(root string, path string, info fs.FileInfo) (*NoteMeta, error) {
+	data, err := w.files.ReadBytes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	frontMatter, body, fmErr := ParseFrontMatter(string(data))
+	if fmErr != nil {
+		frontMatter = map[string]interface{}{}
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	relPath := filepath.ToSlash(rel)
+	tasks := ExtractTasks(string(data))
+	for i := range tasks {
+		tasks[i].Path = path
+		tasks[i].RelPath = relPath
+	}
+
+	return &NoteMeta{
+		Path:        path,
+		RelPath:     relPath,
+		Name:        strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		FrontMatter: frontMatter,
+		Tags:        ExtractTags(frontMatter, body),
+		Tasks:       tasks,
+		Stats:       DocumentStatistics(string(data)),
+	}, nil
+}
+
+func isWithinRoot(root string, path string) bool {
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
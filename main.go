@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -10,9 +11,16 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 
 	"github.com/yourname/MarkdownDaoNote/internal/app"
+	"github.com/yourname/MarkdownDaoNote/internal/cli"
+	"github.com/yourname/MarkdownDaoNote/internal/services"
 )
 
 func main() {
+	// 子命令以无界面模式运行，不启动 Wails
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(cli.Env{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}, os.Args[1:]))
+	}
+
 	// 获取可执行文件所在目录
 	exePath, err := os.Executable()
 	if err != nil {
@@ -33,15 +41,44 @@ func main() {
 
 	log.Println("Starting MarkdownDaoNote application...")
 
+	cwd, _ := os.Getwd()
+	var request app.LaunchRequest
+	if len(os.Args) == 2 && app.IsLaunchURL(os.Args[1]) {
+		// 通过 markdowndaonote:// 链接启动（浏览器、聊天工具等）
+		request, err = app.ParseLaunchURL(os.Args[1], app.RememberedWorkspaces(), services.NewFileService())
+	} else {
+		request, err = app.ParseLaunchArgs(os.Args[1:], cwd)
+	}
+	if err != nil {
+		log.Printf("Invalid arguments: %v", err)
+		fmt.Fprintf(os.Stderr, "MarkdownDaoNote: %v\n", err)
+		os.Exit(cli.ExitUsage)
+	}
+
 	application := app.New()
 	log.Printf("Args(%d): %v", len(os.Args), os.Args)
-	// Windows: if launched via file association, the shell passes the file path as arg1
-	if len(os.Args) > 1 {
-		// Only accept the first argument as a file path
-		log.Printf("Startup open path detected: %s", os.Args[1])
-		application.SetStartupOpenPath(os.Args[1])
-	} else {
-		log.Printf("No startup file argument detected")
+	log.Printf("Launch request: %d file(s), folder %q, wait=%v, new-window=%v",
+		len(request.Files), request.Folder, request.Wait, request.NewWindow)
+
+	// 已有实例在运行时转发启动参数并退出（--new-window 则总是新开进程）
+	if !request.NewWindow {
+		forwarded, err := application.ForwardToExistingInstance(request)
+		if err != nil {
+			log.Printf("Forwarding to existing instance failed: %v", err)
+		}
+		if forwarded {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "MarkdownDaoNote: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
+	if err := application.SetLaunchRequest(request); err != nil {
+		log.Printf("Launch request failed: %v", err)
+		fmt.Fprintf(os.Stderr, "MarkdownDaoNote: %v\n", err)
+		os.Exit(1)
 	}
 	log.Println("Application instance created")
 
@@ -0,0 +1,164 @@
+// Package client drives a running MarkdownDaoNote editor over its local
+// JSON-RPC socket (a named pipe on Windows).
+//
+//	c, err := client.Dial()
+//	if err != nil { ... }
+//	defer c.Close()
+//	tabs, err := c.ListTabs()
+//
+// Only the user running the editor can connect: the socket lives in a
+// private directory and every connection authenticates with a token the
+// editor writes next to it on startup.
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dialTimeout = 2 * time.Second
+
+// Client is an authenticated connection to the editor. It is safe for
+// concurrent use; calls are sent one at a time.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+}
+
+// Dial connects to the editor run by the current user.
+func Dial() (*Client, error) {
+	endpoint, err := DefaultEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return Connect(endpoint)
+}
+
+// Connect connects to endpoint and authenticates with its token file.
+func Connect(endpoint Endpoint) (*Client, error) {
+	data, err := os.ReadFile(endpoint.TokenPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("editor is not running: %w", err)
+		}
+		return nil, err
+	}
+
+	conn, err := dialEndpoint(endpoint.Address, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("editor is not running: %w", err)
+	}
+
+	c := &Client{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.Call(MethodAuth, AuthParams{Token: strings.TrimSpace(string(data))}, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call invokes method with params and decodes the result into result, which
+// may be nil. Errors returned by the editor are of type *Error.
+func (c *Client) Call(method string, params interface{}, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	request := Request{JSONRPC: Version, ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = data
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("no response from editor: %w", err)
+	}
+	var response Response
+	if err := json.Unmarshal(line, &response); err != nil {
+		return fmt.Errorf("invalid response from editor: %w", err)
+	}
+	if string(response.ID) != string(id) {
+		return fmt.Errorf("response id %s does not match request id %s", response.ID, id)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// ListTabs returns the open documents in tab order.
+func (c *Client) ListTabs() ([]Tab, error) {
+	var tabs []Tab
+	err := c.Call(MethodListTabs, nil, &tabs)
+	return tabs, err
+}
+
+// GetBuffer returns the editor content of path, or of the active tab when
+// path is empty.
+func (c *Client) GetBuffer(path string) (Buffer, error) {
+	var buffer Buffer
+	err := c.Call(MethodGetBuffer, PathParams{Path: path}, &buffer)
+	return buffer, err
+}
+
+// SetBuffer replaces the content of an open document without saving it.
+func (c *Client) SetBuffer(path string, content string) error {
+	return c.Call(MethodSetBuffer, SetBufferParams{Path: path, Content: content}, nil)
+}
+
+// InsertText inserts text at the cursor of path, or of the active tab.
+func (c *Client) InsertText(path string, text string) error {
+	return c.Call(MethodInsertText, InsertTextParams{Path: path, Text: text}, nil)
+}
+
+// Open opens an absolute path in the editor, optionally at a 1-based
+// line and column (zero leaves the cursor at the top).
+func (c *Client) Open(path string, line int, column int) error {
+	return c.Call(MethodOpen, OpenParams{Path: path, Line: line, Column: column}, nil)
+}
+
+// Save writes the buffer of path, or of the active tab, to disk.
+func (c *Client) Save(path string) (string, error) {
+	var result PathResult
+	err := c.Call(MethodSave, PathParams{Path: path}, &result)
+	return result.Path, err
+}
+
+// Export renders the buffer of path, or of the active tab, to output as
+// "html" or "text".
+func (c *Client) Export(path string, output string, format string) (string, error) {
+	var result PathResult
+	err := c.Call(MethodExport, ExportParams{Path: path, Output: output, Format: format}, &result)
+	return result.Path, err
+}
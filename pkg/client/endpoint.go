@@ -0,0 +1,19 @@
+package client
+
+// AppName names the per-user directory and pipe used by the editor.
+const AppName = "MarkdownDaoNote"
+
+// Endpoint locates a running editor instance.
+type Endpoint struct {
+	// Dir holds the instance lock and the RPC token; it is private to the user.
+	Dir string
+	// Address is the Unix socket path or, on Windows, the named pipe.
+	Address string
+	// TokenPath is the file holding the token required by MethodAuth.
+	TokenPath string
+}
+
+// DefaultEndpoint returns the endpoint of the editor run by the current user.
+func DefaultEndpoint() (Endpoint, error) {
+	return EndpointFor(AppName)
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EndpointFor returns the socket location used by appName: a directory in
+// $XDG_RUNTIME_DIR, or a per-uid directory below the temporary directory.
+func EndpointFor(appName string) (Endpoint, error) {
+	var dir string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		dir = filepath.Join(runtimeDir, appName)
+	} else {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appName, os.Getuid()))
+	}
+	return Endpoint{
+		Dir:       dir,
+		Address:   filepath.Join(dir, "instance.sock"),
+		TokenPath: filepath.Join(dir, "rpc.token"),
+	}, nil
+}
+
+func dialEndpoint(address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", address, timeout)
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// EndpointFor returns the named pipe used by appName. The pipe name carries
+// the user's SID so sessions of different users never meet.
+func EndpointFor(appName string) (Endpoint, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, appName)
+
+	sid, err := CurrentUserSID()
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return Endpoint{
+		Dir:       dir,
+		Address:   fmt.Sprintf(`\\.\pipe\%s-%s`, appName, sid),
+		TokenPath: filepath.Join(dir, "rpc.token"),
+	}, nil
+}
+
+// CurrentUserSID returns the string SID of the user running the process.
+func CurrentUserSID() (string, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	return user.User.Sid.String(), nil
+}
+
+func dialEndpoint(address string, timeout time.Duration) (net.Conn, error) {
+	return winio.DialPipe(address, &timeout)
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON-RPC 2.0 methods understood by a running editor. Every connection must
+// call MethodAuth with the token published next to the instance socket first.
+const (
+	MethodAuth       = "auth"
+	MethodListTabs   = "editor.listTabs"
+	MethodGetBuffer  = "editor.getBuffer"
+	MethodSetBuffer  = "editor.setBuffer"
+	MethodInsertText = "editor.insertText"
+	MethodOpen       = "editor.open"
+	MethodSave       = "editor.save"
+	MethodExport     = "editor.export"
+)
+
+// Standard JSON-RPC error codes plus the ones specific to the editor.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeUnauthorized   = -32001
+	CodeNotReady       = -32002
+)
+
+// Version is the JSON-RPC version sent in every message.
+const Version = "2.0"
+
+// Request is a JSON-RPC request. Requests without an ID are notifications.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response; exactly one of Result and Error is set.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// AuthParams authenticates a connection.
+type AuthParams struct {
+	Token string `json:"token"`
+}
+
+// Tab describes a document open in the editor.
+type Tab struct {
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Dirty  bool   `json:"dirty"`
+	Active bool   `json:"active"`
+}
+
+// Buffer is the current, possibly unsaved, content of an open document.
+type Buffer struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Dirty   bool   `json:"dirty"`
+}
+
+// PathParams selects a document; an empty Path means the active tab.
+type PathParams struct {
+	Path string `json:"path,omitempty"`
+}
+
+// SetBufferParams replaces the content of an open document without saving it.
+type SetBufferParams struct {
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content"`
+}
+
+// InsertTextParams inserts Text at the cursor, replacing the selection.
+// A Path other than the active tab switches to that tab first.
+type InsertTextParams struct {
+	Path string `json:"path,omitempty"`
+	Text string `json:"text"`
+}
+
+// OpenParams opens an absolute file path, optionally at a 1-based position.
+type OpenParams struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// ExportParams renders an open document to Output. Format is "html" (the
+// default) or "text".
+type ExportParams struct {
+	Path   string `json:"path,omitempty"`
+	Output string `json:"output"`
+	Format string `json:"format,omitempty"`
+}
+
+// PathResult reports the file a save or export wrote.
+type PathResult struct {
+	Path string `json:"path"`
+}